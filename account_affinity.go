@@ -0,0 +1,163 @@
+package blockchain_health
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+)
+
+// defaultAffinityWindow is how long an idle account stays pinned to a node
+const defaultAffinityWindow = 60 * time.Second
+
+// accountAffinity pins EVM accounts to nodes. An account keeps its node while
+// it is in the selection and the account was seen within the window, so its
+// nonce reads and transactions hit the same mempool.
+type accountAffinity struct {
+	window time.Duration
+	now    func() time.Time
+
+	mutex sync.Mutex
+	pins  map[string]accountPin
+	swept time.Time
+}
+
+// accountPin is the node an account is pinned to
+type accountPin struct {
+	key     string
+	expires time.Time
+}
+
+// newAccountAffinity creates the affinity table for a window
+func newAccountAffinity(window time.Duration) *accountAffinity {
+	return &accountAffinity{
+		window: window,
+		now:    time.Now,
+		pins:   make(map[string]accountPin),
+	}
+}
+
+// pick returns the index of the node for the account among the node keys.
+// A new pin goes to the node ranking highest for the account by rendezvous
+// hashing, so accounts spread evenly and mostly keep their node as the pool
+// changes.
+func (a *accountAffinity) pick(account string, keys []string) int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := a.now()
+	if now.Sub(a.swept) >= a.window {
+		for pinned, pin := range a.pins {
+			if !now.Before(pin.expires) {
+				delete(a.pins, pinned)
+			}
+		}
+		a.swept = now
+	}
+
+	chosen := -1
+	if pin, ok := a.pins[account]; ok && now.Before(pin.expires) {
+		for i, key := range keys {
+			if key == pin.key {
+				chosen = i
+				break
+			}
+		}
+	}
+	if chosen < 0 {
+		var best uint64
+		for i, key := range keys {
+			h := fnv.New64a()
+			_, _ = h.Write([]byte(account + "|" + key))
+			if score := h.Sum64(); chosen < 0 || score > best {
+				chosen, best = i, score
+			}
+		}
+	}
+
+	a.pins[account] = accountPin{key: keys[chosen], expires: now.Add(a.window)}
+	return chosen
+}
+
+// affinityAccount returns the account of a nonce-sensitive JSON-RPC call:
+// the address of eth_getTransactionCount, the from of eth_sendTransaction or
+// the signer of eth_sendRawTransaction. Other requests, including batches,
+// return "".
+func affinityAccount(r *http.Request) string {
+	if r == nil || r.Method != http.MethodPost {
+		return ""
+	}
+	body, _, err := readRPCBody(r, 0)
+	if err != nil {
+		return ""
+	}
+	calls, batch, err := parseRPCCalls(body)
+	if err != nil || batch || len(calls) != 1 {
+		return ""
+	}
+
+	switch calls[0].Method {
+	case "eth_getTransactionCount":
+		var params []json.RawMessage
+		var address string
+		if json.Unmarshal(calls[0].Params, &params) != nil || len(params) == 0 || json.Unmarshal(params[0], &address) != nil {
+			return ""
+		}
+		return strings.ToLower(address)
+
+	case "eth_sendTransaction":
+		var params []struct {
+			From string `json:"from"`
+		}
+		if json.Unmarshal(calls[0].Params, &params) != nil || len(params) == 0 {
+			return ""
+		}
+		return strings.ToLower(params[0].From)
+
+	case "eth_sendRawTransaction":
+		var params []string
+		if json.Unmarshal(calls[0].Params, &params) != nil || len(params) == 0 {
+			return ""
+		}
+		raw, err := hex.DecodeString(strings.TrimPrefix(params[0], "0x"))
+		if err != nil {
+			return ""
+		}
+		sender, err := transactionSender(raw)
+		if err != nil {
+			return ""
+		}
+		return sender
+	}
+	return ""
+}
+
+// applyAccountAffinity keeps the upstream the request's account is pinned to
+func (b *BlockchainHealthUpstream) applyAccountAffinity(account string, upstreams []*reverseproxy.Upstream, infos []selectionInfo) ([]*reverseproxy.Upstream, []selectionInfo) {
+	if len(upstreams) < 2 || len(infos) != len(upstreams) {
+		return upstreams, infos
+	}
+
+	keys := make([]string, len(infos))
+	for i, info := range infos {
+		keys[i] = info.name
+		if node := b.findNodeConfig(info.name); node != nil {
+			keys[i] = node.key()
+		}
+	}
+	chosen := b.affinity.pick(account, keys)
+
+	if b.metrics != nil {
+		for i, info := range infos {
+			if i != chosen {
+				b.metrics.upstreamsExcluded.WithLabelValues(info.name, info.serviceType, "account_affinity").Inc()
+			}
+		}
+	}
+	return upstreams[chosen : chosen+1], infos[chosen : chosen+1]
+}
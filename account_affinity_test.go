@@ -0,0 +1,132 @@
+package blockchain_health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+)
+
+func TestAccountAffinity_Pick(t *testing.T) {
+	now := time.Date(2026, time.October, 1, 12, 0, 0, 0, time.UTC)
+	affinity := newAccountAffinity(time.Minute)
+	affinity.now = func() time.Time { return now }
+
+	keys := []string{"a", "b", "c"}
+	first := affinity.pick("0xabc", keys)
+	for i := 0; i < 10; i++ {
+		if got := affinity.pick("0xabc", keys); got != first {
+			t.Fatalf("expected the account to stay on %s, got %s", keys[first], keys[got])
+		}
+	}
+
+	// A pinned account keeps its node as others join, while it is active
+	joined := append([]string{"d", "e", "f", "g"}, keys[first])
+	if got := affinity.pick("0xabc", joined); joined[got] != keys[first] {
+		t.Errorf("expected the pin to survive a new node, got %s", joined[got])
+	}
+
+	// Once its node leaves the selection, the account moves
+	var rest []string
+	for _, key := range keys {
+		if key != keys[first] {
+			rest = append(rest, key)
+		}
+	}
+	moved := rest[affinity.pick("0xabc", rest)]
+	if got := keys[affinity.pick("0xabc", keys)]; got != moved {
+		t.Errorf("expected the account to stay on its new node %s, got %s", moved, got)
+	}
+
+	// Idle pins expire and are swept
+	now = now.Add(2 * time.Minute)
+	affinity.pick("0xdef", keys)
+	if _, ok := affinity.pins["0xabc"]; ok {
+		t.Error("expected the idle pin to be swept")
+	}
+
+	// Accounts spread across the nodes
+	seen := make(map[int]bool)
+	for _, account := range []string{"0x01", "0x02", "0x03", "0x04", "0x05", "0x06", "0x07", "0x08", "0x09", "0x0a"} {
+		seen[affinity.pick(account, keys)] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("expected accounts to use more than one node, got %v", seen)
+	}
+}
+
+func TestAffinityAccount(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"nonce read", `{"jsonrpc":"2.0","id":1,"method":"eth_getTransactionCount","params":["0xABCDEF0000000000000000000000000000000001","pending"]}`, "0xabcdef0000000000000000000000000000000001"},
+		{"send transaction", `{"jsonrpc":"2.0","id":1,"method":"eth_sendTransaction","params":[{"from":"0x00000000000000000000000000000000000000AA","to":"0x01"}]}`, "0x00000000000000000000000000000000000000aa"},
+		{"raw transaction", `{"jsonrpc":"2.0","id":1,"method":"eth_sendRawTransaction","params":["0xf86c098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a76400008025a028ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276a067cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83"]}`, testSender},
+		{"invalid raw transaction", `{"jsonrpc":"2.0","id":1,"method":"eth_sendRawTransaction","params":["0xdeadbeef"]}`, ""},
+		{"other method", `{"jsonrpc":"2.0","id":1,"method":"eth_getBalance","params":["0x01","latest"]}`, ""},
+		{"batch", `[{"jsonrpc":"2.0","id":1,"method":"eth_getTransactionCount","params":["0x01","latest"]}]`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if got := affinityAccount(r); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestApplyAccountAffinity(t *testing.T) {
+	nodes := []NodeConfig{
+		{Name: "a", URL: "http://a:8545", Type: NodeTypeEVM, Weight: 100},
+		{Name: "b", URL: "http://b:8545", Type: NodeTypeEVM, Weight: 100},
+		{Name: "c", URL: "http://c:8545", Type: NodeTypeEVM, Weight: 100},
+	}
+	upstream := &BlockchainHealthUpstream{
+		config:   &Config{Nodes: nodes},
+		affinity: newAccountAffinity(time.Minute),
+		metrics:  NewMetrics(),
+	}
+	upstreams := []*reverseproxy.Upstream{{Dial: "a:8545"}, {Dial: "b:8545"}, {Dial: "c:8545"}}
+	infos := []selectionInfo{{name: "a"}, {name: "b"}, {name: "c"}}
+
+	kept, keptInfos := upstream.applyAccountAffinity("0xabc", upstreams, infos)
+	if len(kept) != 1 || len(keptInfos) != 1 {
+		t.Fatalf("expected one upstream, got %d", len(kept))
+	}
+	// The nonce read and the transaction that follows go to the same node
+	again, _ := upstream.applyAccountAffinity("0xabc", upstreams, infos)
+	if again[0] != kept[0] {
+		t.Errorf("expected %s again, got %s", kept[0].Dial, again[0].Dial)
+	}
+}
+
+func TestAccountAffinity_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:8545
+			type evm
+		}
+		account_affinity 2m
+	}`)
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if !b.AccountAffinity.Enabled || b.AccountAffinity.Window != "2m" {
+		t.Errorf("unexpected account affinity: %+v", b.AccountAffinity)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+	b.AccountAffinity.Window = "soon"
+	if err := b.validate(); err == nil {
+		t.Error("expected an invalid window to be rejected")
+	}
+}
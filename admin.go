@@ -0,0 +1,207 @@
+package blockchain_health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/caddyserver/caddy/v2"
+	"go.uber.org/zap"
+)
+
+// adminTrafficSplitPath is the admin API path of the traffic splits
+const adminTrafficSplitPath = "/blockchain_health/traffic_split/"
+
+// adminPoolsPath is the admin API path of the pools
+const adminPoolsPath = "/blockchain_health/pools/"
+
+// adminChaosPath is the admin API path of the injected faults
+const adminChaosPath = "/blockchain_health/chaos/"
+
+// AdminAPI exposes runtime controls of the blockchain_health pools on
+// Caddy's admin endpoint:
+//
+//	GET    /blockchain_health/traffic_split/             list the traffic splits
+//	GET    /blockchain_health/traffic_split/<name>       show one split
+//	PUT    /blockchain_health/traffic_split/<name>       replace its weights
+//	GET    /blockchain_health/pools/                     list the pools of the app
+//	GET    /blockchain_health/nodes/[<pool>]             show the effective nodes
+//	GET    /blockchain_health/chaos/                     list the pools accepting faults
+//	GET    /blockchain_health/chaos/<pool>               show the faults of a pool
+//	PUT    /blockchain_health/chaos/<pool>/<node>        inject a fault into a node
+//	DELETE /blockchain_health/chaos/<pool>[/<node>]      clear the faults
+//	GET    /blockchain_health/quarantine/[<pool>]        list the quarantined nodes
+//	PUT    /blockchain_health/quarantine/<pool>/<node>   quarantine a node
+//	DELETE /blockchain_health/quarantine/<pool>/<node>   release a node
+//	GET    /blockchain_health/replacements/[<pool>]      list the node replacements
+//	PUT    /blockchain_health/replacements/<pool>/<node> replace a node
+//	DELETE /blockchain_health/replacements/<pool>/<node> abort a soaking replacement
+//
+// Weights, faults, quarantines and replacements changed through the API last
+// until the next config reload.
+type AdminAPI struct{}
+
+func init() {
+	caddy.RegisterModule(AdminAPI{})
+}
+
+// CaddyModule returns the Caddy module information.
+func (AdminAPI) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "admin.api.blockchain_health",
+		New: func() caddy.Module { return new(AdminAPI) },
+	}
+}
+
+// Routes implements caddy.AdminRouter
+func (a AdminAPI) Routes() []caddy.AdminRoute {
+	return []caddy.AdminRoute{
+		{Pattern: adminTrafficSplitPath, Handler: caddy.AdminHandlerFunc(a.handleTrafficSplit)},
+		{Pattern: adminPoolsPath, Handler: caddy.AdminHandlerFunc(a.handlePools)},
+		{Pattern: adminNodesPath, Handler: caddy.AdminHandlerFunc(a.handleNodes)},
+		{Pattern: adminChaosPath, Handler: caddy.AdminHandlerFunc(a.handleChaos)},
+		{Pattern: adminQuarantinePath, Handler: caddy.AdminHandlerFunc(a.handleQuarantine)},
+		{Pattern: adminReplacementsPath, Handler: caddy.AdminHandlerFunc(a.handleReplacements)},
+	}
+}
+
+// trafficSplitState is the admin API representation of a traffic split
+type trafficSplitState struct {
+	Name    string         `json:"name"`
+	Label   string         `json:"label"`
+	Weights map[string]int `json:"weights"`
+}
+
+// handleTrafficSplit serves the traffic split routes
+func (a AdminAPI) handleTrafficSplit(w http.ResponseWriter, r *http.Request) error {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, adminTrafficSplitPath), "/")
+
+	if name == "" {
+		if r.Method != http.MethodGet {
+			return caddy.APIError{HTTPStatus: http.StatusMethodNotAllowed, Err: fmt.Errorf("method %s not allowed", r.Method)}
+		}
+		states := []trafficSplitState{}
+		trafficSplits.Range(func(_, value any) bool {
+			states = append(states, splitState(value.(*trafficSplit)))
+			return true
+		})
+		sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+		return writeAdminJSON(w, states)
+	}
+
+	split, ok := lookupTrafficSplit(name)
+	if !ok {
+		return caddy.APIError{HTTPStatus: http.StatusNotFound, Err: fmt.Errorf("unknown traffic split %q", name)}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		// Show the split as it is
+
+	case http.MethodPut, http.MethodPost:
+		var body struct {
+			Weights map[string]int `json:"weights"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return caddy.APIError{HTTPStatus: http.StatusBadRequest, Err: fmt.Errorf("decoding request: %w", err)}
+		}
+		if err := split.setWeights(body.Weights); err != nil {
+			return caddy.APIError{HTTPStatus: http.StatusBadRequest, Err: err}
+		}
+
+	default:
+		return caddy.APIError{HTTPStatus: http.StatusMethodNotAllowed, Err: fmt.Errorf("method %s not allowed", r.Method)}
+	}
+
+	return writeAdminJSON(w, splitState(split))
+}
+
+// handlePools lists the pools of the running blockchain_health apps
+func (a AdminAPI) handlePools(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return caddy.APIError{HTTPStatus: http.StatusMethodNotAllowed, Err: fmt.Errorf("method %s not allowed", r.Method)}
+	}
+	pools := []poolSummary{}
+	activeApps.Range(func(key, _ any) bool {
+		pools = append(pools, key.(*App).summaries()...)
+		return true
+	})
+	sort.SliceStable(pools, func(i, j int) bool { return pools[i].Name < pools[j].Name })
+	return writeAdminJSON(w, pools)
+}
+
+// handleChaos serves the chaos routes
+func (a AdminAPI) handleChaos(w http.ResponseWriter, r *http.Request) error {
+	name, node, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, adminChaosPath), "/"), "/")
+
+	if name == "" {
+		if r.Method != http.MethodGet {
+			return caddy.APIError{HTTPStatus: http.StatusMethodNotAllowed, Err: fmt.Errorf("method %s not allowed", r.Method)}
+		}
+		states := []chaosPoolState{}
+		chaosPools.Range(func(_, value any) bool {
+			states = append(states, value.(*chaosPool).snapshot())
+			return true
+		})
+		sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+		return writeAdminJSON(w, states)
+	}
+
+	chaos, ok := lookupChaosPool(name)
+	if !ok {
+		return caddy.APIError{HTTPStatus: http.StatusNotFound, Err: fmt.Errorf("no pool %q with chaos enabled", name)}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		// Show the faults as they are
+
+	case http.MethodPut, http.MethodPost:
+		if node == "" {
+			return caddy.APIError{HTTPStatus: http.StatusBadRequest, Err: fmt.Errorf("a fault is set per node")}
+		}
+		var fault ChaosFault
+		if err := json.NewDecoder(r.Body).Decode(&fault); err != nil {
+			return caddy.APIError{HTTPStatus: http.StatusBadRequest, Err: fmt.Errorf("decoding request: %w", err)}
+		}
+		if err := chaos.setFault(node, fault); err != nil {
+			return caddy.APIError{HTTPStatus: http.StatusBadRequest, Err: err}
+		}
+		caddy.Log().Named("blockchain_health").Warn("chaos fault injected",
+			zap.String("pool", name),
+			zap.String("node", node),
+			zap.String("latency", fault.Latency),
+			zap.Float64("error_rate", fault.ErrorRate),
+			zap.Bool("height_freeze", fault.HeightFreeze))
+
+	case http.MethodDelete:
+		chaos.clearFault(node)
+		caddy.Log().Named("blockchain_health").Info("chaos faults cleared",
+			zap.String("pool", name),
+			zap.String("node", node))
+
+	default:
+		return caddy.APIError{HTTPStatus: http.StatusMethodNotAllowed, Err: fmt.Errorf("method %s not allowed", r.Method)}
+	}
+
+	return writeAdminJSON(w, chaos.snapshot())
+}
+
+// splitState returns the admin API representation of a split
+func splitState(split *trafficSplit) trafficSplitState {
+	label, weights := split.snapshot()
+	return trafficSplitState{Name: split.name, Label: label, Weights: weights}
+}
+
+// writeAdminJSON writes an admin API response
+func writeAdminJSON(w http.ResponseWriter, v any) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(v)
+}
+
+// Interface guards
+var (
+	_ caddy.AdminRouter = (*AdminAPI)(nil)
+)
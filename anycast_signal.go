@@ -0,0 +1,88 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// validate checks the anycast_signal settings
+func (c *AnycastSignalConfig) validate() error {
+	if c.WithdrawOn != "" && (!isValidPoolState(string(c.WithdrawOn)) || c.WithdrawOn == PoolHealthy) {
+		return fmt.Errorf("invalid anycast_signal withdraw_on %q (must be degraded, chain_halted, critical or down)", c.WithdrawOn)
+	}
+	if info, err := os.Stat(filepath.Dir(c.File)); err != nil || !info.IsDir() {
+		return fmt.Errorf("anycast_signal file %s: directory does not exist", c.File)
+	}
+	return nil
+}
+
+// anycastSignal writes the readiness of the chain to a file after every
+// health check, as "up <state>" or "down <state>". The file is rewritten
+// even when nothing changed, so health scripts can treat a stale file as
+// down when Caddy stops checking.
+type anycastSignal struct {
+	config *AnycastSignalConfig
+	logger *zap.Logger
+
+	mutex   sync.Mutex
+	lastErr string // last write error, logged once until it changes
+}
+
+// write replaces the signal file with the readiness for the state
+func (s *anycastSignal) write(state PoolState) {
+	status := "up"
+	if state.severity() >= s.config.WithdrawOn.severity() {
+		status = "down"
+	}
+	err := writeFileAtomic(s.config.File, []byte(status+" "+string(state)+"\n"))
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch {
+	case err != nil && err.Error() != s.lastErr:
+		s.logger.Error("failed to write anycast signal file",
+			zap.String("file", s.config.File),
+			zap.Error(err))
+		s.lastErr = err.Error()
+	case err == nil && s.lastErr != "":
+		s.logger.Info("anycast signal file written again", zap.String("file", s.config.File))
+		s.lastErr = ""
+	}
+}
+
+// writeFileAtomic writes a file through a temporary file in the same
+// directory, so readers never see a partial write
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// writeAnycastSignal writes the worst state of the chain's pools to the
+// signal file, if configured, so pools sharing a pool state name agree
+func (h *HealthChecker) writeAnycastSignal(live *chainState) {
+	if h.anycastSignal == nil {
+		return
+	}
+	if state, ok := live.state(); ok {
+		h.anycastSignal.write(state)
+	}
+}
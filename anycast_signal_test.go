@@ -0,0 +1,107 @@
+package blockchain_health
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestAnycastSignal_WritesChainReadiness(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "osmosis.state")
+	logger := zaptest.NewLogger(t)
+
+	// The RPC and REST pools of a chain share a pool state
+	rpc := createTestUpstream(nil, logger).healthChecker
+	rest := createTestUpstream(nil, logger).healthChecker
+	for _, checker := range []*HealthChecker{rpc, rest} {
+		state, err := registerChainState("anycast-test", checker)
+		if err != nil {
+			t.Fatalf("registering pool state: %v", err)
+		}
+		defer releaseChainState(state, checker)
+		checker.state = state
+	}
+	rpc.config.PoolState = PoolStateConfig{DegradedBelow: defaultDegradedBelow, CriticalBelow: defaultCriticalBelow}
+	config := &AnycastSignalConfig{File: file, WithdrawOn: PoolCritical}
+	rpc.anycastSignal = &anycastSignal{config: config, logger: logger}
+
+	read := func() string {
+		t.Helper()
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("reading signal file: %v", err)
+		}
+		return string(data)
+	}
+
+	rpc.updatePoolState([]*NodeHealth{{Name: "a", Healthy: true}, {Name: "b", Healthy: true}})
+	if got := read(); got != "up healthy\n" {
+		t.Fatalf("expected up healthy, got %q", got)
+	}
+
+	// The chain is as bad as its worst pool
+	rest.updatePoolState([]*NodeHealth{{Name: "c", Healthy: false}})
+	rpc.updatePoolState([]*NodeHealth{{Name: "a", Healthy: true}, {Name: "b", Healthy: false}})
+	if got := read(); got != "down down\n" {
+		t.Fatalf("expected down down, got %q", got)
+	}
+
+	rest.updatePoolState([]*NodeHealth{{Name: "c", Healthy: true}})
+	rpc.updatePoolState([]*NodeHealth{{Name: "a", Healthy: true}, {Name: "b"}, {Name: "d"}})
+	if got := read(); got != "down critical\n" {
+		t.Fatalf("expected down critical, got %q", got)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(file))
+	if len(entries) != 1 {
+		t.Errorf("expected only the signal file, found %d entries", len(entries))
+	}
+}
+
+func TestAnycastSignal_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:26657
+			type cosmos
+		}
+		anycast_signal {
+			file /run/caddy/osmosis.state
+			withdraw_on critical
+		}
+	}`)
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if b.AnycastSignal.File != "/run/caddy/osmosis.state" || b.AnycastSignal.WithdrawOn != PoolCritical {
+		t.Fatalf("unexpected anycast_signal settings: %+v", b.AnycastSignal)
+	}
+
+	d = caddyfile.NewTestDispenser(`blockchain_health {
+		anycast_signal {
+			withdraw_on critical
+		}
+	}`)
+	if err := new(BlockchainHealthUpstream).UnmarshalCaddyfile(d); err == nil {
+		t.Fatal("expected an error without a file")
+	}
+}
+
+func TestAnycastSignalConfig_Validate(t *testing.T) {
+	dir := t.TempDir()
+	for name, config := range map[string]AnycastSignalConfig{
+		"missing directory":   {File: filepath.Join(dir, "missing", "osmosis.state")},
+		"healthy withdraw_on": {File: filepath.Join(dir, "osmosis.state"), WithdrawOn: PoolHealthy},
+	} {
+		if err := config.validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	valid := AnycastSignalConfig{File: filepath.Join(dir, "osmosis.state")}
+	if err := valid.validate(); err != nil {
+		t.Errorf("expected valid config, got %v", err)
+	}
+}
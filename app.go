@@ -0,0 +1,269 @@
+package blockchain_health
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/caddyserver/caddy/v2"
+	"go.uber.org/zap"
+)
+
+// App owns the node pools of a config: their discovery, health checks and
+// metrics. The blockchain_health upstream source only routes requests
+// through a pool of the app, so pools are checked once however many sites
+// use them.
+//
+// Named pools are configured in the app and referenced with pool=<name>:
+//
+//	{
+//		blockchain_health {
+//			pool cosmos-main {
+//				node a { ... }
+//			}
+//		}
+//	}
+//
+//	reverse_proxy {
+//		dynamic blockchain_health pool=cosmos-main
+//	}
+//
+// Pools configured inline in a dynamic blockchain_health block are added to
+// the app as well, and blocks with the same configuration share one pool.
+type App struct {
+	// Pools by name, each configured like a blockchain_health upstream
+	Pools map[string]*BlockchainHealthUpstream `json:"pools,omitempty"`
+
+	ctx    caddy.Context
+	logger *zap.Logger
+
+	// Pools configured inline, by configuration, and how many upstreams
+	// use each pool
+	inline map[string]*BlockchainHealthUpstream
+	refs   map[*BlockchainHealthUpstream]int
+	mutex  sync.Mutex
+}
+
+func init() {
+	caddy.RegisterModule(&App{})
+}
+
+// CaddyModule returns the Caddy module information.
+func (*App) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "blockchain_health",
+		New: func() caddy.Module { return new(App) },
+	}
+}
+
+// Provision provisions every named pool, starting its health checks
+func (a *App) Provision(ctx caddy.Context) error {
+	a.ctx = ctx
+	a.logger = ctx.Logger()
+	a.inline = make(map[string]*BlockchainHealthUpstream)
+	a.refs = make(map[*BlockchainHealthUpstream]int)
+
+	for _, name := range a.poolNames() {
+		pool := a.Pools[name]
+		if pool.Pool != "" {
+			return fmt.Errorf("pool %s: a named pool cannot reference another pool", name)
+		}
+		if err := pool.applyDefaults(ctx); err != nil {
+			return fmt.Errorf("pool %s: %w", name, err)
+		}
+		if err := pool.provisionPool(ctx); err != nil {
+			return fmt.Errorf("pool %s: %w", name, err)
+		}
+	}
+	return a.subscribeDrain(ctx)
+}
+
+// Validate implements caddy.Validator.
+func (a *App) Validate() error {
+	for _, name := range a.poolNames() {
+		if err := a.Pools[name].validate(); err != nil {
+			return fmt.Errorf("pool %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Start implements caddy.App. The pools start checking when provisioned.
+func (a *App) Start() error {
+	activeApps.Store(a, struct{}{})
+	return nil
+}
+
+// Stop implements caddy.App.
+func (a *App) Stop() error {
+	activeApps.Delete(a)
+	return nil
+}
+
+// Cleanup stops the health checks of every pool
+func (a *App) Cleanup() error {
+	a.mutex.Lock()
+	pools := make([]*BlockchainHealthUpstream, 0, len(a.Pools)+len(a.inline))
+	for _, name := range a.poolNames() {
+		pools = append(pools, a.Pools[name])
+	}
+	for _, pool := range a.inline {
+		pools = append(pools, pool)
+	}
+	a.inline = nil
+	a.mutex.Unlock()
+
+	var errs []error
+	for _, pool := range pools {
+		// Pools that failed to provision have nothing to clean up
+		if pool.logger == nil {
+			continue
+		}
+		if err := pool.cleanup(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// poolNames returns the names of the named pools in a stable order
+func (a *App) poolNames() []string {
+	names := make([]string, 0, len(a.Pools))
+	for name := range a.Pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// inlinePool returns the pool for an upstream configured inline, creating
+// and provisioning it on first use. Upstreams with the same configuration
+// share the pool.
+func (a *App) inlinePool(b *BlockchainHealthUpstream) (*BlockchainHealthUpstream, error) {
+	key, err := json.Marshal(b)
+	if err != nil {
+		return nil, fmt.Errorf("encoding pool configuration: %w", err)
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.inline == nil {
+		return nil, fmt.Errorf("blockchain_health app is not provisioned")
+	}
+	if pool, ok := a.inline[string(key)]; ok {
+		a.refs[pool]++
+		return pool, nil
+	}
+
+	// The pool gets its own copy of the configuration, leaving the upstream
+	// a thin consumer
+	pool := new(BlockchainHealthUpstream)
+	if err := json.Unmarshal(key, pool); err != nil {
+		return nil, fmt.Errorf("copying pool configuration: %w", err)
+	}
+	if err := pool.provisionPool(a.ctx); err != nil {
+		if pool.logger != nil {
+			_ = pool.cleanup()
+		}
+		return nil, err
+	}
+	a.inline[string(key)] = pool
+	a.refs[pool] = 1
+	return pool, nil
+}
+
+// namedPool returns the named pool an upstream references
+func (a *App) namedPool(name string) (*BlockchainHealthUpstream, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	pool, ok := a.Pools[name]
+	if ok {
+		a.refs[pool]++
+	}
+	return pool, ok
+}
+
+// poolSummary is the admin API representation of a pool
+type poolSummary struct {
+	Name      string `json:"name"`
+	Named     bool   `json:"named"`
+	Upstreams int    `json:"upstreams"`
+	Nodes     int    `json:"nodes"`
+	Healthy   int    `json:"healthy"`
+}
+
+// summaries describes the pools of the app. Inline pools are named after
+// their pool state name.
+func (a *App) summaries() []poolSummary {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var pools []poolSummary
+	summarize := func(name string, named bool, pool *BlockchainHealthUpstream) {
+		summary := poolSummary{Name: name, Named: named, Upstreams: a.refs[pool]}
+		if pool.config != nil {
+			summary.Nodes = len(pool.config.nodeList())
+			for _, health := range pool.getCachedHealthResults() {
+				if health != nil && health.Healthy {
+					summary.Healthy++
+				}
+			}
+		}
+		pools = append(pools, summary)
+	}
+	for _, name := range a.poolNames() {
+		summarize(name, true, a.Pools[name])
+	}
+	for _, pool := range a.inline {
+		summarize(pool.poolStateName(), false, pool)
+	}
+	sort.SliceStable(pools, func(i, j int) bool { return pools[i].Name < pools[j].Name })
+	return pools
+}
+
+// loadApp returns the blockchain_health app of the config being provisioned,
+// loading it if the config does not set it up. Standalone contexts have no
+// app, and the upstream provisions its pool itself.
+func loadApp(ctx caddy.Context) (*App, bool) {
+	module, ok := loadCaddyApp(ctx, "blockchain_health")
+	if !ok {
+		return nil, false
+	}
+	app, ok := module.(*App)
+	return app, ok
+}
+
+// loadCaddyApp returns an app of the config being provisioned, loading it
+// if the config does not set it up, or false for contexts made outside a
+// config, such as in the probe command
+func loadCaddyApp(ctx caddy.Context, name string) (any, bool) {
+	if ctx.Context == nil || !hasConfig(ctx) {
+		return nil, false
+	}
+	module, err := ctx.App(name)
+	if err != nil {
+		return nil, false
+	}
+	return module, true
+}
+
+// hasConfig reports whether ctx belongs to a loaded config. ctx.App needs one,
+// but Caddy doesn't tell; without a config, FileSystems returns a new empty
+// map on every call instead of the config's.
+func hasConfig(ctx caddy.Context) bool {
+	return ctx.FileSystems() == ctx.FileSystems()
+}
+
+// activeApps holds the running apps, for the admin API. Two apps are
+// running while a config reload is in progress.
+var activeApps sync.Map // *App -> struct{}
+
+// Interface guards
+var (
+	_ caddy.App          = (*App)(nil)
+	_ caddy.Provisioner  = (*App)(nil)
+	_ caddy.Validator    = (*App)(nil)
+	_ caddy.CleanerUpper = (*App)(nil)
+)
@@ -0,0 +1,100 @@
+package blockchain_health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+)
+
+func TestApp_SharesInlinePools(t *testing.T) {
+	node := createVersionedEVMServer(t, "Geth/v1.13.14")
+	defer node.Close()
+
+	if err := caddy.Load([]byte(`{"admin": {"disabled": true}, "apps": {"blockchain_health": {}}}`), true); err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	stopped := false
+	defer func() {
+		if !stopped {
+			_ = caddy.Stop()
+		}
+	}()
+	ctx := caddy.ActiveContext()
+
+	site := func(interval string) *BlockchainHealthUpstream {
+		t.Helper()
+		b := &BlockchainHealthUpstream{
+			Nodes:       []NodeConfig{{Name: "a", URL: node.URL, Type: NodeTypeEVM, Weight: 1}},
+			HealthCheck: HealthCheckConfig{Interval: interval},
+		}
+		if err := b.Provision(ctx); err != nil {
+			t.Fatalf("provisioning site: %v", err)
+		}
+		if err := b.Validate(); err != nil {
+			t.Fatalf("validating site: %v", err)
+		}
+		return b
+	}
+
+	// Sites with the same pool configuration share one set of checks
+	first, second, other := site("1s"), site("1s"), site("2s")
+	if first.shared == nil || first.shared != second.shared {
+		t.Fatal("expected identical sites to share a pool")
+	}
+	if other.shared == first.shared {
+		t.Error("expected a differently configured site to get its own pool")
+	}
+	if first.healthChecker != nil || first.metrics != nil {
+		t.Error("expected the site to leave checks and metrics to the app")
+	}
+
+	upstreams, err := second.GetUpstreams(httptest.NewRequest(http.MethodPost, "/", nil))
+	if err != nil || len(upstreams) != 1 {
+		t.Errorf("expected the pool's node, got %v (%v)", upstreams, err)
+	}
+
+	// The admin API lists the pools with the sites using them
+	w := httptest.NewRecorder()
+	if err := (AdminAPI{}).handlePools(w, httptest.NewRequest(http.MethodGet, adminPoolsPath, nil)); err != nil {
+		t.Fatalf("listing pools: %v", err)
+	}
+	var pools []poolSummary
+	if err := json.NewDecoder(w.Body).Decode(&pools); err != nil {
+		t.Fatalf("decoding pools: %v", err)
+	}
+	upstreamCounts := map[int]int{}
+	for _, pool := range pools {
+		upstreamCounts[pool.Upstreams]++
+		if pool.Nodes != 1 || pool.Named {
+			t.Errorf("unexpected pool: %+v", pool)
+		}
+	}
+	if len(pools) != 2 || upstreamCounts[2] != 1 || upstreamCounts[1] != 1 {
+		t.Errorf("expected a pool shared by two sites and one used by one, got %+v", pools)
+	}
+
+	// Sites leave the pool to the app, which cleans it up with the config
+	pool := first.shared
+	if err := first.Cleanup(); err != nil || pool.metrics == nil {
+		t.Fatalf("expected the pool to outlive the site: %v", err)
+	}
+	if err := caddy.Stop(); err != nil {
+		t.Fatalf("stopping: %v", err)
+	}
+	stopped = true
+	if pool.metrics != nil {
+		t.Error("expected the app to clean up its pools")
+	}
+}
+
+func TestLoadApp_WithoutConfig(t *testing.T) {
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	defer cancel()
+	if _, ok := loadApp(ctx); ok {
+		t.Error("expected no app outside a config")
+	}
+}
@@ -0,0 +1,236 @@
+package blockchain_health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Backfill defaults: requests are marked with X-Traffic-Class: backfill
+const (
+	defaultBackfillHeader      = "X-Traffic-Class"
+	defaultBackfillHeaderValue = "backfill"
+)
+
+// trafficClassVar is the request variable holding the traffic class of a
+// request, set to trafficClassBackfill for backfill requests
+const (
+	trafficClassVar      = "blockchain_health.traffic_class"
+	trafficClassBackfill = "backfill"
+)
+
+// Backfill is a middleware placed in front of reverse_proxy that gives
+// historical backfills, such as indexers syncing from genesis, their own
+// traffic class. Backfill requests are recognized by a header or by the API
+// key resolved from KeyFrom, and are only routed to the pool's backfill
+// nodes (metadata backfill=true), so they do not starve interactive users.
+// At most MaxConcurrent are proxied at once; the rest wait up to MaxWait for
+// a slot before being answered 429 with Retry-After.
+type Backfill struct {
+	Header      string `json:"header,omitempty"`
+	HeaderValue string `json:"header_value,omitempty"`
+
+	// KeyFrom resolves the API key of a request, and Keys lists the keys of
+	// backfill clients
+	KeyFrom []Source `json:"key_from,omitempty"`
+	Keys    []string `json:"keys,omitempty"`
+
+	MaxConcurrent int            `json:"max_concurrent,omitempty"`
+	MaxWait       caddy.Duration `json:"max_wait,omitempty"`
+	RetryAfter    caddy.Duration `json:"retry_after,omitempty"`
+
+	keys  map[string]bool
+	slots chan struct{}
+}
+
+func init() {
+	caddy.RegisterModule(&Backfill{})
+}
+
+var bfMetrics *BackfillMetrics
+
+// CaddyModule returns the Caddy module information.
+func (*Backfill) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.blockchain_backfill",
+		New: func() caddy.Module { return new(Backfill) },
+	}
+}
+
+// Provision applies defaults, sets up the concurrency slots and registers
+// metrics
+func (h *Backfill) Provision(ctx caddy.Context) error {
+	if h.Header == "" && len(h.Keys) == 0 {
+		h.Header = defaultBackfillHeader
+		h.HeaderValue = defaultBackfillHeaderValue
+	}
+	if h.RetryAfter == 0 {
+		h.RetryAfter = caddy.Duration(defaultBackpressureRetryAfter)
+	}
+	h.keys = make(map[string]bool, len(h.Keys))
+	for _, key := range h.Keys {
+		h.keys[key] = true
+	}
+	if h.MaxConcurrent > 0 {
+		h.slots = make(chan struct{}, h.MaxConcurrent)
+	}
+
+	var registerer prometheus.Registerer
+	if reg := ctx.GetMetricsRegistry(); reg != nil {
+		registerer = reg
+	} else {
+		registerer = prometheus.DefaultRegisterer
+	}
+	metrics, err := acquireBackfillMetrics(registerer)
+	if err != nil {
+		return err
+	}
+	bfMetrics = metrics
+	return nil
+}
+
+// Validate checks configuration correctness
+func (h *Backfill) Validate() error {
+	if h.HeaderValue != "" && h.Header == "" {
+		return fmt.Errorf("header_value requires header")
+	}
+	for i, s := range h.KeyFrom {
+		switch s.Type {
+		case "placeholder", "header", "query":
+		default:
+			return fmt.Errorf("key_from[%d]: invalid type %q, must be placeholder, header, or query", i, s.Type)
+		}
+	}
+	if len(h.Keys) > 0 && len(h.KeyFrom) == 0 {
+		return fmt.Errorf("keys require key_from")
+	}
+	if h.MaxConcurrent < 0 {
+		return fmt.Errorf("max_concurrent must not be negative")
+	}
+	if h.MaxWait < 0 {
+		return fmt.Errorf("max_wait must not be negative")
+	}
+	if h.RetryAfter < 0 {
+		return fmt.Errorf("retry_after must not be negative")
+	}
+	return nil
+}
+
+// ServeHTTP marks backfill requests and holds them to MaxConcurrent
+func (h *Backfill) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	if !h.isBackfill(r) {
+		return next.ServeHTTP(w, r)
+	}
+	caddyhttp.SetVar(r.Context(), trafficClassVar, trafficClassBackfill)
+
+	if h.slots != nil {
+		acquired, err := h.acquire(r.Context())
+		if err != nil {
+			return err
+		}
+		if !acquired {
+			if bfMetrics != nil {
+				bfMetrics.rejectedTotal.Inc()
+			}
+			w.Header().Set("Retry-After", retryAfterSeconds(time.Duration(h.RetryAfter)))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return nil
+		}
+		defer func() { <-h.slots }()
+	}
+
+	if bfMetrics != nil {
+		bfMetrics.inFlight.Inc()
+		defer bfMetrics.inFlight.Dec()
+	}
+	return next.ServeHTTP(w, r)
+}
+
+// isBackfill reports whether a request carries the backfill header or the API
+// key of a backfill client
+func (h *Backfill) isBackfill(r *http.Request) bool {
+	if h.Header != "" {
+		value := strings.TrimSpace(r.Header.Get(h.Header))
+		if value != "" && (h.HeaderValue == "" || strings.EqualFold(value, h.HeaderValue)) {
+			return true
+		}
+	}
+	if len(h.keys) > 0 {
+		if key := resolveSource(r, h.KeyFrom); key != "" && h.keys[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// acquire takes a concurrency slot, waiting up to MaxWait for one to free up
+func (h *Backfill) acquire(ctx context.Context) (bool, error) {
+	select {
+	case h.slots <- struct{}{}:
+		return true, nil
+	default:
+	}
+	if h.MaxWait <= 0 {
+		return false, nil
+	}
+
+	start := time.Now()
+	timer := time.NewTimer(time.Duration(h.MaxWait))
+	defer timer.Stop()
+	select {
+	case h.slots <- struct{}{}:
+		if bfMetrics != nil {
+			bfMetrics.queuedSeconds.WithLabelValues("served").Observe(time.Since(start).Seconds())
+		}
+		return true, nil
+	case <-timer.C:
+		if bfMetrics != nil {
+			bfMetrics.queuedSeconds.WithLabelValues("rejected").Observe(time.Since(start).Seconds())
+		}
+		return false, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// isBackfillRequest reports whether blockchain_backfill marked the request
+// as a backfill
+func isBackfillRequest(ctx context.Context) bool {
+	class, _ := caddyhttp.GetVar(ctx, trafficClassVar).(string)
+	return class == trafficClassBackfill
+}
+
+// isBackfill reports whether the node serves backfill requests (metadata
+// backfill=true)
+func (n NodeConfig) isBackfill() bool {
+	value := n.Metadata["backfill"]
+	if value == "" {
+		return false
+	}
+	backfill, _ := strconv.ParseBool(value)
+	return backfill
+}
+
+// hasBackfillNodes reports whether any node is designated for backfills
+func hasBackfillNodes(nodes []NodeConfig) bool {
+	for _, node := range nodes {
+		if node.isBackfill() {
+			return true
+		}
+	}
+	return false
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*Backfill)(nil)
+	_ caddy.Validator             = (*Backfill)(nil)
+	_ caddyhttp.MiddlewareHandler = (*Backfill)(nil)
+)
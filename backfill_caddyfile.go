@@ -0,0 +1,95 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	httpcaddyfile "github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+func init() {
+	// Register Caddyfile directive for this handler
+	httpcaddyfile.RegisterHandlerDirective("blockchain_backfill", parseBackfillCaddyfile)
+	httpcaddyfile.RegisterDirectiveOrder("blockchain_backfill", httpcaddyfile.Before, "reverse_proxy")
+}
+
+func parseBackfillCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	bf := new(Backfill)
+	if err := bf.UnmarshalCaddyfile(h.Dispenser); err != nil {
+		return nil, err
+	}
+	return bf, nil
+}
+
+// UnmarshalCaddyfile implements caddyfile.Unmarshaler for blockchain_backfill
+func (h *Backfill) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	for d.Next() {
+		for d.NextBlock(0) {
+			switch d.Val() {
+			case "header":
+				// Syntax: header <name> [<value>]
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				h.Header = d.Val()
+				if d.NextArg() {
+					h.HeaderValue = d.Val()
+				}
+
+			case "key_from":
+				s, err := parseSource(d)
+				if err != nil {
+					return err
+				}
+				h.KeyFrom = append(h.KeyFrom, s)
+
+			case "keys":
+				args := d.RemainingArgs()
+				if len(args) == 0 {
+					return d.ArgErr()
+				}
+				h.Keys = append(h.Keys, args...)
+
+			case "max_concurrent":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				limit, err := strconv.Atoi(d.Val())
+				if err != nil {
+					return d.Errf("invalid max_concurrent: %v", err)
+				}
+				h.MaxConcurrent = limit
+
+			case "max_wait", "retry_after":
+				name := d.Val()
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				dur, err := time.ParseDuration(d.Val())
+				if err != nil {
+					return d.Errf("invalid %s: %v", name, err)
+				}
+				if name == "max_wait" {
+					h.MaxWait = caddy.Duration(dur)
+				} else {
+					h.RetryAfter = caddy.Duration(dur)
+				}
+
+			default:
+				return d.Errf("unknown directive: %s", d.Val())
+			}
+		}
+	}
+
+	if err := h.Validate(); err != nil {
+		return fmt.Errorf("blockchain_backfill validation: %w", err)
+	}
+	return nil
+}
+
+// Interface guard
+var _ caddyfile.Unmarshaler = (*Backfill)(nil)
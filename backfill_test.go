@@ -0,0 +1,178 @@
+package blockchain_health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+func newTestBackfill(t *testing.T, h *Backfill) *Backfill {
+	t.Helper()
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	t.Cleanup(cancel)
+	if err := h.Provision(ctx); err != nil {
+		t.Fatalf("Provision failed: %v", err)
+	}
+	return h
+}
+
+func newBackfillRequest(header, apiKey string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "http://127.0.0.1/", nil)
+	if header != "" {
+		r.Header.Set("X-Traffic-Class", header)
+	}
+	if apiKey != "" {
+		r.Header.Set("X-API-Key", apiKey)
+	}
+	return r.WithContext(context.WithValue(r.Context(), caddyhttp.VarsCtxKey, map[string]any{}))
+}
+
+func TestBackfill_Classifies(t *testing.T) {
+	h := newTestBackfill(t, &Backfill{
+		Header:  "X-Traffic-Class",
+		KeyFrom: []Source{{Type: "header", Name: "X-API-Key"}},
+		Keys:    []string{"indexer"},
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		apiKey string
+		want   bool
+	}{
+		{name: "header", header: "backfill", want: true},
+		{name: "any header value", header: "bulk", want: true},
+		{name: "backfill key", apiKey: "indexer", want: true},
+		{name: "interactive key", apiKey: "wallet"},
+		{name: "unmarked"},
+	}
+	for _, tt := range tests {
+		r := newBackfillRequest(tt.header, tt.apiKey)
+		next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error { return nil })
+		if err := h.ServeHTTP(httptest.NewRecorder(), r, next); err != nil {
+			t.Fatalf("%s: ServeHTTP failed: %v", tt.name, err)
+		}
+		if got := isBackfillRequest(r.Context()); got != tt.want {
+			t.Errorf("%s: expected backfill=%v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	// Without a header or keys, X-Traffic-Class: backfill marks backfills
+	h = newTestBackfill(t, &Backfill{})
+	if !h.isBackfill(newBackfillRequest("Backfill", "")) || h.isBackfill(newBackfillRequest("interactive", "")) {
+		t.Error("expected the default header value to be matched")
+	}
+}
+
+func TestBackfill_CapsConcurrency(t *testing.T) {
+	h := newTestBackfill(t, &Backfill{MaxConcurrent: 1, MaxWait: caddy.Duration(20 * time.Millisecond)})
+
+	// The first backfill holds the only slot until released
+	release := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- h.ServeHTTP(httptest.NewRecorder(), newBackfillRequest("backfill", ""), caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			close(started)
+			<-release
+			return nil
+		}))
+	}()
+	<-started
+
+	served := false
+	next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		served = true
+		return nil
+	})
+	rec := httptest.NewRecorder()
+	if err := h.ServeHTTP(rec, newBackfillRequest("backfill", ""), next); err != nil {
+		t.Fatalf("ServeHTTP failed: %v", err)
+	}
+	if served || rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected a second backfill to get 429 with Retry-After, got %d (served=%v)", rec.Code, served)
+	}
+
+	// Interactive requests are not held
+	if err := h.ServeHTTP(httptest.NewRecorder(), newBackfillRequest("", ""), next); err != nil || !served {
+		t.Errorf("expected interactive requests to pass, got %v", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("first backfill failed: %v", err)
+	}
+	served = false
+	if err := h.ServeHTTP(httptest.NewRecorder(), newBackfillRequest("backfill", ""), next); err != nil || !served {
+		t.Errorf("expected the freed slot to be taken, got %v", err)
+	}
+}
+
+func TestBackfill_PinnedToBackfillNodes(t *testing.T) {
+	interactive := createEVMServer(t, 1000, false)
+	defer interactive.Close()
+	backfill := createEVMServer(t, 1000, false)
+	defer backfill.Close()
+
+	nodes := []NodeConfig{
+		{Name: "interactive", URL: interactive.URL, Type: NodeTypeEVM, Weight: 1},
+		{Name: "backfill", URL: backfill.URL, Type: NodeTypeEVM, Weight: 1, Metadata: map[string]string{"backfill": "true"}},
+	}
+	upstream := createTestUpstream(nodes, zaptest.NewLogger(t))
+
+	r := newBackfillRequest("", "")
+	caddyhttp.SetVar(r.Context(), trafficClassVar, trafficClassBackfill)
+	upstreams, err := upstream.GetUpstreams(r)
+	if err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+	if len(upstreams) != 1 || upstreams[0].Dial != getDynamicTestHostFromURL(backfill.URL) {
+		t.Errorf("expected backfills to reach only the backfill node, got %v", upstreams)
+	}
+
+	// Interactive requests may use every node
+	if upstreams, err := upstream.GetUpstreams(newBackfillRequest("", "")); err != nil || len(upstreams) != 2 {
+		t.Errorf("expected interactive requests to use both nodes, got %d (%v)", len(upstreams), err)
+	}
+
+	// Backfills fail rather than spill onto interactive nodes
+	backfill.Close()
+	upstream.healthChecker.cache.Clear()
+	if _, err := upstream.GetUpstreams(r); !errors.Is(err, ErrNoHealthyUpstreams) {
+		t.Errorf("expected backfills to fail without a healthy backfill node, got %v", err)
+	}
+}
+
+func TestBackfill_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_backfill {
+		header X-Traffic-Class backfill
+		key_from header X-API-Key
+		keys indexer-1 indexer-2
+		max_concurrent 8
+		max_wait 5s
+		retry_after 2s
+	}`)
+	var h Backfill
+	if err := h.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if h.Header != "X-Traffic-Class" || h.HeaderValue != "backfill" || len(h.KeyFrom) != 1 || len(h.Keys) != 2 ||
+		h.MaxConcurrent != 8 || h.MaxWait != caddy.Duration(5*time.Second) || h.RetryAfter != caddy.Duration(2*time.Second) {
+		t.Errorf("unexpected backfill config: %+v", h)
+	}
+
+	d = caddyfile.NewTestDispenser(`blockchain_backfill {
+		keys indexer-1
+	}`)
+	if err := new(Backfill).UnmarshalCaddyfile(d); err == nil {
+		t.Error("expected keys without key_from to be rejected")
+	}
+}
@@ -0,0 +1,158 @@
+package blockchain_health
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Backpressure defaults
+const (
+	defaultBackpressureRetryAfter    = time.Second
+	defaultBackpressureRetryInterval = 50 * time.Millisecond
+)
+
+// noUpstreamsMessage is the error reverse_proxy returns when every upstream is
+// unavailable, which for this module's pool means every node is at its
+// in-flight limit (weight)
+const noUpstreamsMessage = "no upstreams available"
+
+// noUpstreamsVar is the request variable set when the upstream module refused
+// to select any node (e.g. fallback_strategy error), which is not saturation
+const noUpstreamsVar = "blockchain_health.no_upstreams"
+
+// Backpressure is a middleware placed in front of reverse_proxy that turns a
+// saturated pool into 429 responses with Retry-After. Requests can optionally
+// wait up to MaxWait for a node to free up before being rejected.
+type Backpressure struct {
+	RetryAfter    caddy.Duration `json:"retry_after,omitempty"`
+	MaxWait       caddy.Duration `json:"max_wait,omitempty"`
+	RetryInterval caddy.Duration `json:"retry_interval,omitempty"`
+}
+
+func init() {
+	caddy.RegisterModule(&Backpressure{})
+}
+
+var bpMetrics *BackpressureMetrics
+
+// CaddyModule returns the Caddy module information.
+func (*Backpressure) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.blockchain_backpressure",
+		New: func() caddy.Module { return new(Backpressure) },
+	}
+}
+
+// Provision applies defaults and registers metrics
+func (h *Backpressure) Provision(ctx caddy.Context) error {
+	if h.RetryAfter == 0 {
+		h.RetryAfter = caddy.Duration(defaultBackpressureRetryAfter)
+	}
+	if h.RetryInterval == 0 {
+		h.RetryInterval = caddy.Duration(defaultBackpressureRetryInterval)
+	}
+
+	var registerer prometheus.Registerer
+	if reg := ctx.GetMetricsRegistry(); reg != nil {
+		registerer = reg
+	} else {
+		registerer = prometheus.DefaultRegisterer
+	}
+	metrics, err := acquireBackpressureMetrics(registerer)
+	if err != nil {
+		return err
+	}
+	bpMetrics = metrics
+	return nil
+}
+
+// Validate checks configuration correctness
+func (h *Backpressure) Validate() error {
+	if h.RetryAfter < 0 {
+		return fmt.Errorf("retry_after must not be negative")
+	}
+	if h.MaxWait < 0 {
+		return fmt.Errorf("max_wait must not be negative")
+	}
+	if h.RetryInterval < 0 {
+		return fmt.Errorf("retry_interval must not be negative")
+	}
+	return nil
+}
+
+// ServeHTTP forwards the request and, when the pool is saturated, retries it
+// until MaxWait passes before answering 429 with Retry-After.
+func (h *Backpressure) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	start := time.Now()
+	deadline := start.Add(time.Duration(h.MaxWait))
+	interval := time.Duration(h.RetryInterval)
+	if interval <= 0 {
+		interval = defaultBackpressureRetryInterval
+	}
+
+	queued := false
+	for {
+		err := next.ServeHTTP(w, r)
+		if !isPoolSaturated(err) || caddyhttp.GetVar(r.Context(), noUpstreamsVar) != nil {
+			if queued && bpMetrics != nil {
+				bpMetrics.queuedSeconds.WithLabelValues("served").Observe(time.Since(start).Seconds())
+			}
+			return err
+		}
+
+		// Wait for a node to free up while the bounded queue time allows it
+		if time.Now().Add(interval).After(deadline) {
+			break
+		}
+		queued = true
+		select {
+		case <-r.Context().Done():
+			return r.Context().Err()
+		case <-time.After(interval):
+		}
+	}
+
+	if bpMetrics != nil {
+		if queued {
+			bpMetrics.queuedSeconds.WithLabelValues("rejected").Observe(time.Since(start).Seconds())
+		}
+		bpMetrics.rejectedTotal.WithLabelValues(r.Method).Inc()
+	}
+
+	w.Header().Set("Retry-After", retryAfterSeconds(time.Duration(h.RetryAfter)))
+	w.WriteHeader(http.StatusTooManyRequests)
+	return nil
+}
+
+// isPoolSaturated reports whether reverse_proxy found no available upstream
+func isPoolSaturated(err error) bool {
+	var handlerErr caddyhttp.HandlerError
+	if !errors.As(err, &handlerErr) {
+		return false
+	}
+	return handlerErr.StatusCode == http.StatusServiceUnavailable &&
+		handlerErr.Err != nil && handlerErr.Err.Error() == noUpstreamsMessage
+}
+
+// retryAfterSeconds formats a duration as a Retry-After value in whole seconds
+func retryAfterSeconds(d time.Duration) string {
+	if d <= 0 {
+		d = defaultBackpressureRetryAfter
+	}
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*Backpressure)(nil)
+	_ caddy.Validator             = (*Backpressure)(nil)
+	_ caddyhttp.MiddlewareHandler = (*Backpressure)(nil)
+)
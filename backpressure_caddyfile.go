@@ -0,0 +1,62 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	httpcaddyfile "github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+func init() {
+	// Register Caddyfile directive for this handler
+	httpcaddyfile.RegisterHandlerDirective("blockchain_backpressure", parseBackpressureCaddyfile)
+	httpcaddyfile.RegisterDirectiveOrder("blockchain_backpressure", httpcaddyfile.Before, "reverse_proxy")
+}
+
+func parseBackpressureCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	bp := new(Backpressure)
+	if err := bp.UnmarshalCaddyfile(h.Dispenser); err != nil {
+		return nil, err
+	}
+	return bp, nil
+}
+
+// UnmarshalCaddyfile implements caddyfile.Unmarshaler for blockchain_backpressure
+func (h *Backpressure) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	for d.Next() {
+		for d.NextBlock(0) {
+			var target *caddy.Duration
+			switch d.Val() {
+			case "retry_after":
+				target = &h.RetryAfter
+			case "max_wait":
+				target = &h.MaxWait
+			case "retry_interval":
+				target = &h.RetryInterval
+			default:
+				return d.Errf("unknown directive: %s", d.Val())
+			}
+
+			name := d.Val()
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			dur, err := time.ParseDuration(d.Val())
+			if err != nil {
+				return d.Errf("invalid %s: %v", name, err)
+			}
+			*target = caddy.Duration(dur)
+		}
+	}
+
+	if err := h.Validate(); err != nil {
+		return fmt.Errorf("blockchain_backpressure validation: %w", err)
+	}
+	return nil
+}
+
+// Interface guard
+var _ caddyfile.Unmarshaler = (*Backpressure)(nil)
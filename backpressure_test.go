@@ -0,0 +1,123 @@
+package blockchain_health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// saturatedHandler reports a saturated pool for the first `saturated` calls
+type saturatedHandler struct {
+	saturated int
+	calls     int
+}
+
+func (s *saturatedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) error {
+	s.calls++
+	if s.calls <= s.saturated {
+		return caddyhttp.Error(http.StatusServiceUnavailable, errors.New(noUpstreamsMessage))
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func TestBackpressure_RejectsWithRetryAfter(t *testing.T) {
+	h := &Backpressure{RetryAfter: caddy.Duration(1500 * time.Millisecond)}
+
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://127.0.0.1/", nil)
+	next := &saturatedHandler{saturated: 100}
+
+	if err := h.ServeHTTP(rec, r, next); err != nil {
+		t.Fatalf("ServeHTTP returned error: %v", err)
+	}
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Fatalf("expected Retry-After 2, got %q", got)
+	}
+	if next.calls != 1 {
+		t.Fatalf("expected no queueing without max_wait, got %d calls", next.calls)
+	}
+}
+
+func TestBackpressure_QueuesUntilNodeFreesUp(t *testing.T) {
+	h := &Backpressure{
+		MaxWait:       caddy.Duration(time.Second),
+		RetryInterval: caddy.Duration(10 * time.Millisecond),
+	}
+
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://127.0.0.1/", nil)
+	next := &saturatedHandler{saturated: 3}
+
+	if err := h.ServeHTTP(rec, r, next); err != nil {
+		t.Fatalf("ServeHTTP returned error: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 after queueing, got %d", rec.Code)
+	}
+	if next.calls != 4 {
+		t.Fatalf("expected 4 attempts, got %d", next.calls)
+	}
+}
+
+func TestBackpressure_PassesThroughOtherErrors(t *testing.T) {
+	h := &Backpressure{MaxWait: caddy.Duration(time.Second)}
+
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://127.0.0.1/", nil)
+	next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return caddyhttp.Error(http.StatusBadGateway, errors.New("dial failed"))
+	})
+
+	err := h.ServeHTTP(rec, r, next)
+	var handlerErr caddyhttp.HandlerError
+	if !errors.As(err, &handlerErr) || handlerErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected the 502 to pass through, got %v", err)
+	}
+}
+
+func TestBackpressure_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_backpressure {
+		retry_after 3s
+		max_wait 500ms
+		retry_interval 25ms
+	}`)
+
+	var h Backpressure
+	if err := h.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if time.Duration(h.RetryAfter) != 3*time.Second || time.Duration(h.MaxWait) != 500*time.Millisecond || time.Duration(h.RetryInterval) != 25*time.Millisecond {
+		t.Errorf("unexpected config: %+v", h)
+	}
+}
+
+func TestBackpressure_IgnoresRefusedSelection(t *testing.T) {
+	h := &Backpressure{MaxWait: caddy.Duration(time.Second)}
+
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://127.0.0.1/", nil)
+	r = r.WithContext(context.WithValue(r.Context(), caddyhttp.VarsCtxKey, map[string]any{}))
+
+	// The upstream module refused to select nodes, so reverse_proxy found none
+	next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		caddyhttp.SetVar(r.Context(), noUpstreamsVar, ErrNoHealthyUpstreams)
+		return caddyhttp.Error(http.StatusServiceUnavailable, errors.New(noUpstreamsMessage))
+	})
+
+	err := h.ServeHTTP(rec, r, next)
+	var handlerErr caddyhttp.HandlerError
+	if !errors.As(err, &handlerErr) || handlerErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected the 503 to pass through, got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package blockchain_health
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// BeaconClient is a consensus client implementation
+type BeaconClient string
+
+// Known consensus clients
+const (
+	BeaconClientPrysm      BeaconClient = "prysm"
+	BeaconClientLighthouse BeaconClient = "lighthouse"
+	BeaconClientTeku       BeaconClient = "teku"
+	BeaconClientNimbus     BeaconClient = "nimbus"
+	BeaconClientLodestar   BeaconClient = "lodestar"
+	BeaconClientGrandine   BeaconClient = "grandine"
+	BeaconClientUnknown    BeaconClient = "unknown"
+)
+
+// clientDetectionRetry is how long a failed client detection is remembered
+// before the node is asked again
+const clientDetectionRetry = 5 * time.Minute
+
+// beaconQuirks adjusts the health check to a consensus client
+type beaconQuirks struct {
+	// syncTolerance is how many slots behind its sync target a node
+	// reporting is_syncing still counts as synced
+	syncTolerance uint64
+
+	// readinessPath is a client endpoint answering 200 only once the node
+	// is ready to serve
+	readinessPath string
+}
+
+// beaconClientQuirks holds the adjustments per client. Clients without an
+// entry are checked by the standard Beacon API only.
+var beaconClientQuirks = map[BeaconClient]beaconQuirks{
+	// Nimbus reports is_syncing while a single slot behind around slot
+	// boundaries
+	BeaconClientNimbus: {syncTolerance: 1},
+	// Teku only reports ready once it has a head and peers to follow it
+	BeaconClientTeku: {readinessPath: "/teku/v1/admin/readiness"},
+}
+
+// isValidBeaconClient reports whether s names a known consensus client
+func isValidBeaconClient(s string) bool {
+	switch BeaconClient(s) {
+	case BeaconClientPrysm, BeaconClientLighthouse, BeaconClientTeku, BeaconClientNimbus,
+		BeaconClientLodestar, BeaconClientGrandine:
+		return true
+	}
+	return false
+}
+
+// parseBeaconClient returns the client from a /eth/v1/node/version string
+// such as "Lighthouse/v4.5.0-441fc16/x86_64-linux"
+func parseBeaconClient(version string) BeaconClient {
+	name, _, _ := strings.Cut(version, "/")
+	name = strings.ToLower(strings.TrimSpace(name))
+	if isValidBeaconClient(name) {
+		return BeaconClient(name)
+	}
+	return BeaconClientUnknown
+}
+
+// clientEntry is the detected client of a node URL
+type clientEntry struct {
+	client  string
+	expires time.Time // zero once detected
+}
+
+// beaconVersionResponse represents /eth/v1/node/version response
+type beaconVersionResponse struct {
+	Data struct {
+		Version string `json:"version"`
+	} `json:"data"`
+}
+
+// clientFor returns the consensus client of a node, honoring a per-node
+// "beacon_client" metadata override. Detected clients are remembered per URL.
+func (b *BeaconHandler) clientFor(ctx context.Context, node NodeConfig) BeaconClient {
+	if client := node.Metadata["beacon_client"]; client != "" {
+		return BeaconClient(client)
+	}
+
+	b.clientsMutex.Lock()
+	entry, ok := b.clients[node.URL]
+	b.clientsMutex.Unlock()
+	if ok && (entry.expires.IsZero() || time.Now().Before(entry.expires)) {
+		return BeaconClient(entry.client)
+	}
+
+	client, err := b.getClient(ctx, node.URL)
+	entry = clientEntry{client: string(client)}
+	if err != nil {
+		b.logger.Debug("Beacon client detection failed", zap.String("node", node.Name), zap.Error(err))
+		entry = clientEntry{client: string(BeaconClientUnknown), expires: time.Now().Add(clientDetectionRetry)}
+	}
+
+	b.clientsMutex.Lock()
+	b.clients[node.URL] = entry
+	b.clientsMutex.Unlock()
+	return BeaconClient(entry.client)
+}
+
+// getClient queries /eth/v1/node/version for the node's client
+func (b *BeaconHandler) getClient(ctx context.Context, baseURL string) (BeaconClient, error) {
+	versionURL := fmt.Sprintf("%s/eth/v1/node/version", strings.TrimSuffix(baseURL, "/"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, versionURL, nil)
+	if err != nil {
+		return BeaconClientUnknown, fmt.Errorf("creating version request: %w", err)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return BeaconClientUnknown, fmt.Errorf("version request failed: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			b.logger.Debug("Failed to close response body", zap.Error(err))
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return BeaconClientUnknown, statusError("version", resp.StatusCode)
+	}
+
+	var version beaconVersionResponse
+	if err := decodeJSON(resp, &version); err != nil {
+		return BeaconClientUnknown, fmt.Errorf("decoding version response: %w", err)
+	}
+	return parseBeaconClient(version.Data.Version), nil
+}
+
+// checkReadiness requires a client readiness endpoint to answer 200
+func (b *BeaconHandler) checkReadiness(ctx context.Context, baseURL, path string) error {
+	readinessURL := strings.TrimSuffix(baseURL, "/") + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, readinessURL, nil)
+	if err != nil {
+		return fmt.Errorf("creating readiness request: %w", err)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("readiness request failed: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			b.logger.Debug("Failed to close response body", zap.Error(err))
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("not ready: %w", statusError("readiness", resp.StatusCode))
+	}
+	return nil
+}
+
+// beaconQuantity is an unsigned quantity that clients send either as a
+// decimal string, as the spec requires, or as a JSON number
+type beaconQuantity uint64
+
+// UnmarshalJSON accepts "123", 123 and null
+func (q *beaconQuantity) UnmarshalJSON(data []byte) error {
+	data = bytes.Trim(data, `"`)
+	if len(data) == 0 || string(data) == "null" {
+		*q = 0
+		return nil
+	}
+	value, err := parseHeight(string(data))
+	if err != nil {
+		return fmt.Errorf("invalid quantity %s: %w", data, err)
+	}
+	*q = beaconQuantity(value)
+	return nil
+}
@@ -0,0 +1,142 @@
+package blockchain_health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// createClientBeaconServer serves a Beacon node running the given client
+// version, syncing within distance slots of its head. Teku nodes answer
+// their readiness endpoint with readiness.
+func createClientBeaconServer(t *testing.T, version string, syncing bool, distance uint64, readiness int, versionCalls *atomic.Int32) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/eth/v1/node/version":
+			if versionCalls != nil {
+				versionCalls.Add(1)
+			}
+			_, _ = fmt.Fprintf(w, `{"data":{"version":%q}}`, version)
+		case "/eth/v1/node/syncing":
+			// Slots as JSON numbers, as some client versions send them
+			_, _ = fmt.Fprintf(w, `{"data":{"is_syncing":%t,"head_slot":3200,"sync_distance":%d}}`, syncing, distance)
+		case "/eth/v1/beacon/states/head/finality_checkpoints":
+			_, _ = w.Write([]byte(`{"data":{"finalized":{"epoch":"98"}}}`))
+		case "/teku/v1/admin/readiness":
+			w.WriteHeader(readiness)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestParseBeaconClient(t *testing.T) {
+	tests := map[string]BeaconClient{
+		"Prysm/v5.0.3 (linux amd64)":                                              BeaconClientPrysm,
+		"Lighthouse/v4.5.0-441fc16/x86_64-linux":                                  BeaconClientLighthouse,
+		"teku/v24.1.0/linux-x86_64/-eclipseadoptium-openjdk64bitservervm-java-21": BeaconClientTeku,
+		"Nimbus/v24.1.2-0a6d6a-stateofus":                                         BeaconClientNimbus,
+		"Lodestar/v1.15.0/8f2f2b2":                                                BeaconClientLodestar,
+		"Grandine/0.4.1":                                                          BeaconClientGrandine,
+		"Caplin/v1":                                                               BeaconClientUnknown,
+		"":                                                                        BeaconClientUnknown,
+	}
+	for version, want := range tests {
+		if got := parseBeaconClient(version); got != want {
+			t.Errorf("%q: expected %s, got %s", version, want, got)
+		}
+	}
+}
+
+func TestBeaconQuantity(t *testing.T) {
+	for input, want := range map[string]uint64{`"3200"`: 3200, `3200`: 3200, `null`: 0, `""`: 0} {
+		var q beaconQuantity
+		if err := json.Unmarshal([]byte(input), &q); err != nil {
+			t.Errorf("%s: %v", input, err)
+			continue
+		}
+		if uint64(q) != want {
+			t.Errorf("%s: expected %d, got %d", input, want, q)
+		}
+	}
+	var q beaconQuantity
+	if err := json.Unmarshal([]byte(`"-1"`), &q); err == nil {
+		t.Error("expected a negative quantity to be rejected")
+	}
+}
+
+func TestBeaconHandler_ClientQuirks(t *testing.T) {
+	tests := []struct {
+		name      string
+		version   string
+		syncing   bool
+		distance  uint64
+		readiness int
+		client    BeaconClient
+		healthy   bool
+	}{
+		{name: "nimbus one slot behind", version: "Nimbus/v24.1.2", syncing: true, distance: 1, client: BeaconClientNimbus, healthy: true},
+		{name: "nimbus syncing", version: "Nimbus/v24.1.2", syncing: true, distance: 40, client: BeaconClientNimbus},
+		{name: "lighthouse one slot behind", version: "Lighthouse/v4.5.0", syncing: true, distance: 1, client: BeaconClientLighthouse},
+		{name: "teku ready", version: "teku/v24.1.0", readiness: http.StatusOK, client: BeaconClientTeku, healthy: true},
+		{name: "teku not ready", version: "teku/v24.1.0", readiness: http.StatusServiceUnavailable, client: BeaconClientTeku},
+		{name: "unknown client", version: "Caplin/v1", client: BeaconClientUnknown, healthy: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := createClientBeaconServer(t, tt.version, tt.syncing, tt.distance, tt.readiness, nil)
+			defer server.Close()
+
+			handler := NewBeaconHandler(5*time.Second, zaptest.NewLogger(t))
+			health, err := handler.CheckHealth(context.Background(), NodeConfig{Name: tt.name, URL: server.URL, Type: NodeTypeBeacon})
+			if err != nil {
+				t.Fatalf("CheckHealth failed: %v", err)
+			}
+			if health.Client != string(tt.client) {
+				t.Errorf("expected client %s, got %s", tt.client, health.Client)
+			}
+			if health.Healthy != tt.healthy {
+				t.Errorf("expected healthy=%v, got %v (%s)", tt.healthy, health.Healthy, health.LastError)
+			}
+			if health.BlockHeight != 3200 {
+				t.Errorf("expected head slot 3200, got %d", health.BlockHeight)
+			}
+		})
+	}
+}
+
+func TestBeaconHandler_ClientDetectionCached(t *testing.T) {
+	var calls atomic.Int32
+	server := createClientBeaconServer(t, "Lighthouse/v4.5.0", false, 0, 0, &calls)
+	defer server.Close()
+
+	handler := NewBeaconHandler(5*time.Second, zaptest.NewLogger(t))
+	node := NodeConfig{Name: "lighthouse", URL: server.URL, Type: NodeTypeBeacon}
+	for i := 0; i < 3; i++ {
+		if _, err := handler.CheckHealth(context.Background(), node); err != nil {
+			t.Fatalf("CheckHealth failed: %v", err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected the client to be detected once, got %d version calls", n)
+	}
+
+	// The metadata override skips detection
+	node = NodeConfig{Name: "pinned", URL: server.URL + "/", Type: NodeTypeBeacon, Metadata: map[string]string{"beacon_client": "nimbus"}}
+	health, err := handler.CheckHealth(context.Background(), node)
+	if err != nil {
+		t.Fatalf("CheckHealth failed: %v", err)
+	}
+	if health.Client != string(BeaconClientNimbus) || calls.Load() != 1 {
+		t.Errorf("expected the configured client without detection, got %s", health.Client)
+	}
+}
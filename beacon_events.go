@@ -0,0 +1,85 @@
+package blockchain_health
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// eventStreamProbeTimeout bounds how long the event stream probe waits for
+// the response headers. Beacon nodes send them as soon as the stream opens;
+// a proxy buffering the stream holds them back.
+const eventStreamProbeTimeout = 2 * time.Second
+
+// servesEventStream reports whether a Beacon node serves event consumers
+// (metadata event_stream=true), so its event stream is probed
+func (n NodeConfig) servesEventStream() bool {
+	value := n.Metadata["event_stream"]
+	if value == "" {
+		return false
+	}
+	enabled, _ := strconv.ParseBool(value)
+	return enabled
+}
+
+// checkEventStream opens the node's head event stream and records whether it
+// is usable: answered 200 as text/event-stream within
+// eventStreamProbeTimeout. The stream is closed once the headers arrive, as
+// waiting for an event would take up to a slot.
+func (b *BeaconHandler) checkEventStream(ctx context.Context, node NodeConfig, health *NodeHealth) {
+	if err := b.openEventStream(ctx, node.URL); err != nil {
+		b.logger.Debug("Beacon event stream unavailable", zap.String("node", node.Name), zap.Error(err))
+		health.EventStreamBroken = true
+	}
+}
+
+// openEventStream subscribes to head events and checks the response headers
+func (b *BeaconHandler) openEventStream(ctx context.Context, baseURL string) error {
+	ctx, cancel := context.WithTimeout(ctx, eventStreamProbeTimeout)
+	defer cancel()
+
+	eventsURL := fmt.Sprintf("%s/eth/v1/events?topics=head", strings.TrimSuffix(baseURL, "/"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, eventsURL, nil)
+	if err != nil {
+		return fmt.Errorf("creating event stream request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("event stream request failed: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			b.logger.Debug("Failed to close response body", zap.Error(err))
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return statusError("event stream", resp.StatusCode)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		return fmt.Errorf("event stream answered with content type %q", resp.Header.Get("Content-Type"))
+	}
+	return nil
+}
+
+// isEventStreamRequest reports whether a request subscribes to the Beacon
+// API event stream
+func isEventStreamRequest(r *http.Request) bool {
+	if r == nil || r.URL == nil {
+		return false
+	}
+	rest, ok := strings.CutPrefix(r.URL.Path, "/eth/")
+	if !ok {
+		return false
+	}
+	_, rest, ok = strings.Cut(rest, "/")
+	return ok && strings.TrimSuffix(rest, "/") == "events"
+}
@@ -0,0 +1,116 @@
+package blockchain_health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// createEventStreamServer serves a Beacon node whose head event stream
+// answers with the given content type, or 404 when it is empty
+func createEventStreamServer(t *testing.T, contentType string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/eth/v1/node/syncing":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"data": {"is_syncing": false, "head_slot": "1000"}}`))
+		case "/eth/v1/events":
+			if contentType == "" || r.URL.Query().Get("topics") != "head" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", contentType)
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestIsEventStreamRequest(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "/eth/v1/events", want: true},
+		{path: "/eth/v1/events/", want: true},
+		{path: "/eth/v1/node/syncing"},
+		{path: "/eth/v1/beacon/headers/head"},
+		{path: "/events"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path+"?topics=head", nil)
+		if got := isEventStreamRequest(r); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestBeaconHandler_CheckEventStream(t *testing.T) {
+	handler := NewBeaconHandler(5*time.Second, zaptest.NewLogger(t))
+
+	tests := []struct {
+		name        string
+		contentType string
+		broken      bool
+	}{
+		{name: "event stream", contentType: "text/event-stream; charset=utf-8"},
+		{name: "buffered into JSON", contentType: "application/json", broken: true},
+		{name: "not served", broken: true},
+	}
+	for _, tt := range tests {
+		server := createEventStreamServer(t, tt.contentType)
+		node := NodeConfig{Name: "beacon", URL: server.URL, Type: NodeTypeBeacon, Metadata: map[string]string{"event_stream": "true"}}
+		health, err := handler.CheckHealth(context.Background(), node)
+		server.Close()
+		if err != nil {
+			t.Fatalf("%s: CheckHealth failed: %v", tt.name, err)
+		}
+		if !health.Healthy || health.EventStreamBroken != tt.broken {
+			t.Errorf("%s: expected a healthy node with broken event stream %v, got healthy %v, broken %v",
+				tt.name, tt.broken, health.Healthy, health.EventStreamBroken)
+		}
+	}
+
+	// Nodes not serving event consumers are not probed
+	server := createEventStreamServer(t, "")
+	defer server.Close()
+	health, err := handler.CheckHealth(context.Background(), NodeConfig{Name: "beacon", URL: server.URL, Type: NodeTypeBeacon})
+	if err != nil || health.EventStreamBroken {
+		t.Errorf("expected the event stream to be left unprobed, got %v (%v)", health.EventStreamBroken, err)
+	}
+}
+
+func TestEventStream_Routing(t *testing.T) {
+	working := createEventStreamServer(t, "text/event-stream")
+	defer working.Close()
+	broken := createEventStreamServer(t, "application/json")
+	defer broken.Close()
+
+	metadata := map[string]string{"event_stream": "true"}
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "working", URL: working.URL, Type: NodeTypeBeacon, Weight: 1, Metadata: metadata},
+		{Name: "broken", URL: broken.URL, Type: NodeTypeBeacon, Weight: 1, Metadata: metadata},
+	}, zaptest.NewLogger(t))
+
+	// Event subscriptions avoid the broken stream
+	upstreams, err := upstream.GetUpstreams(httptest.NewRequest(http.MethodGet, "/eth/v1/events?topics=head", nil))
+	if err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+	if len(upstreams) != 1 || upstreams[0].Dial != getDynamicTestHostFromURL(working.URL) {
+		t.Errorf("expected event subscriptions to reach only the working node, got %v", upstreams)
+	}
+
+	// REST requests still use both nodes
+	upstreams, err = upstream.GetUpstreams(httptest.NewRequest(http.MethodGet, "/eth/v1/node/version", nil))
+	if err != nil || len(upstreams) != 2 {
+		t.Errorf("expected REST requests to use both nodes, got %d (%v)", len(upstreams), err)
+	}
+}
@@ -0,0 +1,141 @@
+package blockchain_health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// defaultFinalityEpochThreshold is how many epochs a Beacon node's finalized
+// checkpoint may trail the pool's before it is unsafe for validator duties
+const defaultFinalityEpochThreshold = 2
+
+// beaconFinalityResponse represents the
+// /eth/v1/beacon/states/head/finality_checkpoints response
+type beaconFinalityResponse struct {
+	Data struct {
+		Finalized struct {
+			Epoch heightText `json:"epoch"`
+		} `json:"finalized"`
+	} `json:"data"`
+}
+
+// getFinalizedEpoch returns the epoch of the node's finalized checkpoint
+func (b *BeaconHandler) getFinalizedEpoch(ctx context.Context, baseURL string) (uint64, error) {
+	finalityURL := fmt.Sprintf("%s/eth/v1/beacon/states/head/finality_checkpoints", strings.TrimSuffix(baseURL, "/"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, finalityURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("creating finality request: %w", err)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("finality request failed: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			b.logger.Debug("Failed to close response body", zap.Error(err))
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, statusError("finality", resp.StatusCode)
+	}
+
+	var finality beaconFinalityResponse
+	if err := decodeJSON(resp, &finality); err != nil {
+		return 0, fmt.Errorf("decoding finality response: %w", err)
+	}
+	epoch, err := parseHeight(string(finality.Data.Finalized.Epoch))
+	if err != nil {
+		return 0, fmt.Errorf("parsing finalized epoch: %w", err)
+	}
+	return epoch, nil
+}
+
+// checkFinality records the finalized epoch of a healthy Beacon node. Nodes
+// whose finality cannot be read are treated as stale.
+func (b *BeaconHandler) checkFinality(ctx context.Context, node NodeConfig, health *NodeHealth) {
+	epoch, err := b.getFinalizedEpoch(ctx, node.URL)
+	if err != nil {
+		b.logger.Debug("Beacon finality unavailable", zap.String("node", node.Name), zap.Error(err))
+		health.FinalityStale = true
+		return
+	}
+	health.FinalizedEpoch = epoch
+}
+
+// validatorSafe reports whether a Beacon node can serve validator duties: it
+// is fully verified rather than optimistic, its execution client is online
+// and its finalized checkpoint keeps up with the pool
+func (h *NodeHealth) validatorSafe() bool {
+	return !h.Optimistic && !h.ELOffline && !h.FinalityStale
+}
+
+// finalityEpochThreshold returns the allowed finalized epoch lag
+func (h *HealthChecker) finalityEpochThreshold() uint64 {
+	if h.config.BlockValidation.FinalityEpochThreshold > 0 {
+		return uint64(h.config.BlockValidation.FinalityEpochThreshold)
+	}
+	return defaultFinalityEpochThreshold
+}
+
+// validateFinality marks Beacon nodes whose finalized epoch trails the best
+// in the pool by more than the threshold. Finality is compared within the
+// pool, so a network that stops finalizing does not leave validators without
+// a node.
+func (h *HealthChecker) validateFinality(nodes []*NodeHealth) {
+	var finalized uint64
+	for _, node := range nodes {
+		if node.Healthy && node.FinalizedEpoch > finalized {
+			finalized = node.FinalizedEpoch
+		}
+	}
+
+	threshold := h.finalityEpochThreshold()
+	for _, node := range nodes {
+		// Nodes whose finality could not be read stay stale
+		if node.FinalizedEpoch == 0 {
+			continue
+		}
+		stale := node.FinalizedEpoch+threshold < finalized
+		if stale && !node.FinalityStale {
+			h.logger.Debug("Beacon node finality is stale",
+				zap.String("node", node.Name),
+				zap.Uint64("finalized_epoch", node.FinalizedEpoch),
+				zap.Uint64("pool_finalized_epoch", finalized))
+		}
+		node.FinalityStale = stale
+	}
+}
+
+// isValidatorRequest reports whether a Beacon API request serves validator
+// duties: the validator endpoints and the publishing of blocks and pool
+// operations such as attestations
+func isValidatorRequest(r *http.Request) bool {
+	if r == nil || r.URL == nil {
+		return false
+	}
+	rest, ok := strings.CutPrefix(r.URL.Path, "/eth/")
+	if !ok {
+		return false
+	}
+	// Drop the API version
+	if _, rest, ok = strings.Cut(rest, "/"); !ok {
+		return false
+	}
+	rest = strings.TrimSuffix(rest, "/")
+
+	switch {
+	case strings.HasPrefix(rest, "validator/"):
+		return true
+	case r.Method != http.MethodPost:
+		return false
+	case rest == "beacon/blocks", rest == "beacon/blinded_blocks", strings.HasPrefix(rest, "beacon/pool/"):
+		return true
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package blockchain_health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"go.uber.org/zap/zaptest"
+)
+
+// createFinalityBeaconServer serves a synced Beacon node at headSlot with the
+// given optimistic and execution client flags. A zero finalized epoch leaves
+// the finality endpoint unavailable.
+func createFinalityBeaconServer(t *testing.T, headSlot, finalized uint64, optimistic, elOffline bool) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/eth/v1/node/syncing":
+			_, _ = fmt.Fprintf(w, `{"data":{"is_syncing":false,"is_optimistic":%t,"el_offline":%t,"head_slot":"%d"}}`, optimistic, elOffline, headSlot)
+		case "/eth/v1/beacon/states/head/finality_checkpoints":
+			if finalized == 0 {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"data":{"finalized":{"epoch":"%d","root":"0x00"}}}`, finalized)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestBeaconHandler_Finality(t *testing.T) {
+	handler := NewBeaconHandler(5*time.Second, zaptest.NewLogger(t))
+
+	tests := []struct {
+		name       string
+		server     *httptest.Server
+		optimistic bool
+		elOffline  bool
+		finalized  uint64
+		stale      bool
+	}{
+		{name: "verified", server: createFinalityBeaconServer(t, 3200, 98, false, false), finalized: 98},
+		{name: "optimistic", server: createFinalityBeaconServer(t, 3200, 98, true, false), optimistic: true, finalized: 98},
+		{name: "execution client offline", server: createFinalityBeaconServer(t, 3200, 98, false, true), elOffline: true, finalized: 98},
+		{name: "finality unavailable", server: createFinalityBeaconServer(t, 3200, 0, false, false), stale: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer tt.server.Close()
+			health, err := handler.CheckHealth(context.Background(), NodeConfig{Name: tt.name, URL: tt.server.URL, Type: NodeTypeBeacon})
+			if err != nil {
+				t.Fatalf("CheckHealth failed: %v", err)
+			}
+			if !health.Healthy {
+				t.Fatalf("expected the node to stay healthy for reads: %s", health.LastError)
+			}
+			if health.Optimistic != tt.optimistic || health.ELOffline != tt.elOffline || health.FinalizedEpoch != tt.finalized || health.FinalityStale != tt.stale {
+				t.Errorf("unexpected finality state: %+v", health)
+			}
+			if safe := !tt.optimistic && !tt.elOffline && !tt.stale; health.validatorSafe() != safe {
+				t.Errorf("expected validatorSafe=%v", safe)
+			}
+		})
+	}
+}
+
+func TestValidateFinality(t *testing.T) {
+	checker := createTestUpstream(nil, zaptest.NewLogger(t)).healthChecker
+
+	nodes := []*NodeHealth{
+		{Name: "leader", Healthy: true, FinalizedEpoch: 100},
+		{Name: "within", Healthy: true, FinalizedEpoch: 98},
+		{Name: "behind", Healthy: true, FinalizedEpoch: 97},
+		{Name: "unknown", Healthy: true, FinalityStale: true},
+	}
+	checker.validateFinality(nodes)
+	for _, node := range nodes {
+		if stale := node.Name == "behind" || node.Name == "unknown"; node.FinalityStale != stale {
+			t.Errorf("%s: expected stale=%v", node.Name, stale)
+		}
+	}
+
+	// A node that catches up is cleared
+	nodes[2].FinalizedEpoch = 100
+	checker.validateFinality(nodes)
+	if nodes[2].FinalityStale {
+		t.Error("expected the node to be cleared once its finality caught up")
+	}
+
+	checker.config.BlockValidation.FinalityEpochThreshold = 5
+	nodes[1].FinalizedEpoch = 95
+	checker.validateFinality(nodes)
+	if nodes[1].FinalityStale {
+		t.Error("expected the configured threshold to be used")
+	}
+}
+
+func TestIsValidatorRequest(t *testing.T) {
+	tests := []struct {
+		method, path string
+		want         bool
+	}{
+		{http.MethodPost, "/eth/v1/validator/duties/attester/100", true},
+		{http.MethodGet, "/eth/v3/validator/blocks/3200", true},
+		{http.MethodGet, "/eth/v1/validator/attestation_data", true},
+		{http.MethodPost, "/eth/v2/beacon/blocks", true},
+		{http.MethodPost, "/eth/v1/beacon/blinded_blocks", true},
+		{http.MethodPost, "/eth/v1/beacon/pool/attestations", true},
+		{http.MethodGet, "/eth/v1/beacon/pool/attestations", false},
+		{http.MethodGet, "/eth/v2/beacon/blocks/head", false},
+		{http.MethodGet, "/eth/v1/node/syncing", false},
+		{http.MethodPost, "/", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		if got := isValidatorRequest(r); got != tt.want {
+			t.Errorf("%s %s: expected %v, got %v", tt.method, tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestValidatorRequestsAvoidUnsafeBeaconNodes(t *testing.T) {
+	safe := createFinalityBeaconServer(t, 3200, 98, false, false)
+	defer safe.Close()
+	optimistic := createFinalityBeaconServer(t, 3200, 98, true, false)
+	defer optimistic.Close()
+	stale := createFinalityBeaconServer(t, 3200, 90, false, false)
+	defer stale.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "safe", URL: safe.URL, Type: NodeTypeBeacon, ChainType: "test-beacon", Weight: 1},
+		{Name: "optimistic", URL: optimistic.URL, Type: NodeTypeBeacon, ChainType: "test-beacon", Weight: 1},
+		{Name: "stale", URL: stale.URL, Type: NodeTypeBeacon, ChainType: "test-beacon", Weight: 1},
+	}, zaptest.NewLogger(t))
+
+	reads, err := upstream.GetUpstreams(httptest.NewRequest(http.MethodGet, "/eth/v1/beacon/headers/head", nil))
+	if err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+	if len(reads) != 3 {
+		t.Errorf("expected every node for reads, got %d", len(reads))
+	}
+
+	duties, err := upstream.GetUpstreams(httptest.NewRequest(http.MethodPost, "/eth/v1/validator/duties/attester/100", nil))
+	if err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+	if len(duties) != 1 || duties[0].Dial != getDynamicTestHostFromURL(safe.URL) {
+		t.Errorf("expected only the safe node for validator duties, got %v", duties)
+	}
+
+	// Without a safe node, validator requests fail rather than fall back
+	upstream.config.setNodes(upstream.config.Nodes[1:])
+	upstream.healthChecker.cache.Clear()
+	if _, err := upstream.GetUpstreams(httptest.NewRequest(http.MethodPost, "/eth/v1/validator/duties/attester/100", nil)); !errors.Is(err, ErrNoHealthyUpstreams) {
+		t.Errorf("expected ErrNoHealthyUpstreams, got %v", err)
+	}
+}
+
+func TestFinalityEpochThreshold_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:5052
+			type beacon
+		}
+		finality_epoch_threshold 4
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if b.BlockValidation.FinalityEpochThreshold != 4 {
+		t.Errorf("expected threshold 4, got %d", b.BlockValidation.FinalityEpochThreshold)
+	}
+
+	b.BlockValidation.FinalityEpochThreshold = -1
+	if err := b.validate(); err == nil {
+		t.Error("expected a negative threshold to be rejected")
+	}
+}
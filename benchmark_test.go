@@ -10,6 +10,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
 )
@@ -450,3 +452,133 @@ func BenchmarkStressTest(b *testing.B) {
 	cancel()
 	wg.Wait()
 }
+
+// newCachedBenchmarkUpstream returns an upstream of five nodes that all have
+// a cached healthy result, logging at its production level, and a request
+// carrying Caddy's vars
+func newCachedBenchmarkUpstream(tb testing.TB) (*BlockchainHealthUpstream, *http.Request) {
+	tb.Helper()
+	nodes := make([]NodeConfig, 5)
+	for i := range nodes {
+		nodes[i] = NodeConfig{
+			Name:     fmt.Sprintf("node-%d", i),
+			URL:      fmt.Sprintf("http://10.0.0.%d:26657", i+1),
+			Type:     NodeTypeCosmos,
+			Weight:   100,
+			Metadata: map[string]string{"service_type": "rpc"},
+		}
+	}
+	memoizeKeys(nodes) // as provisioning does
+	upstream := createBenchmarkUpstream(nodes, zap.NewNop())
+	upstream.cache = NewHealthCache(time.Hour)
+	for _, node := range nodes {
+		upstream.cache.Set(node.key(), &NodeHealth{Name: node.Name, URL: node.URL, Healthy: true, BlockHeight: 12345})
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), caddyhttp.VarsCtxKey, map[string]any{}))
+	return upstream, req
+}
+
+// BenchmarkGetUpstreamsCached measures the request path alone, with every
+// node's health cached
+func BenchmarkGetUpstreamsCached(b *testing.B) {
+	upstream, req := newCachedBenchmarkUpstream(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := upstream.GetUpstreams(req); err != nil {
+			b.Fatalf("GetUpstreams failed: %v", err)
+		}
+	}
+}
+
+// BenchmarkGetUpstreamsCachedParallel measures the cached request path under
+// concurrent requests, where shared locks would contend
+func BenchmarkGetUpstreamsCachedParallel(b *testing.B) {
+	upstream, req := newCachedBenchmarkUpstream(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		// Each request carries its own vars
+		req := req.WithContext(context.WithValue(req.Context(), caddyhttp.VarsCtxKey, map[string]any{}))
+		for pb.Next() {
+			if _, err := upstream.GetUpstreams(req); err != nil {
+				b.Errorf("GetUpstreams failed: %v", err)
+				return
+			}
+		}
+	})
+}
+
+// TestGetUpstreams_Allocations guards the request path against allocation
+// regressions. While node health is cached, requests share the upstreams
+// and allocate nothing.
+func TestGetUpstreams_Allocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations are not stable under the race detector")
+	}
+	upstream, req := newCachedBenchmarkUpstream(t)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := upstream.GetUpstreams(req); err != nil {
+			t.Fatalf("GetUpstreams failed: %v", err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations per GetUpstreams call, got %.0f", allocs)
+	}
+}
+
+// TestGetUpstreams_SharedUntilHealthChanges checks that requests share the
+// upstreams of a snapshot only while the cache and quarantines are unchanged
+func TestGetUpstreams_SharedUntilHealthChanges(t *testing.T) {
+	upstream, req := newCachedBenchmarkUpstream(t)
+	get := func() []*reverseproxy.Upstream {
+		t.Helper()
+		upstreams, err := upstream.GetUpstreams(req)
+		if err != nil {
+			t.Fatalf("GetUpstreams failed: %v", err)
+		}
+		return upstreams
+	}
+
+	first := get()
+	if len(first) != 5 {
+		t.Fatalf("expected 5 upstreams, got %d", len(first))
+	}
+	if second := get(); &second[0] != &first[0] {
+		t.Error("expected requests to share the upstreams while node health is cached")
+	}
+
+	// Another proxy using the same pool gets its own upstreams
+	other := &BlockchainHealthUpstream{shared: upstream}
+	if upstreams, err := other.GetUpstreams(req); err != nil || &upstreams[0] == &first[0] {
+		t.Errorf("expected another upstream source to get its own upstreams (err %v)", err)
+	}
+
+	// A second hedged attempt leaves the shared list alone
+	hedged := req.WithContext(context.WithValue(req.Context(), caddyhttp.VarsCtxKey, map[string]any{hedgeExcludeVar: first[0].Dial}))
+	if upstreams, err := upstream.GetUpstreams(hedged); err != nil || len(upstreams) != 4 {
+		t.Fatalf("expected 4 upstreams for the hedged attempt, got %d (err %v)", len(upstreams), err)
+	}
+	if first[0].Dial != "10.0.0.1:26657" {
+		t.Errorf("expected the shared upstreams to be unchanged, got %s first", first[0].Dial)
+	}
+
+	// A quarantine is seen by the next request
+	if err := upstream.healthChecker.quarantineNode("node-0", time.Now()); err != nil {
+		t.Fatalf("quarantineNode failed: %v", err)
+	}
+	if upstreams := get(); len(upstreams) != 4 {
+		t.Errorf("expected 4 upstreams after quarantining a node, got %d", len(upstreams))
+	}
+
+	// So is a new result
+	upstream.cache.Set(upstream.config.Nodes[1].key(), &NodeHealth{Name: "node-1", URL: upstream.config.Nodes[1].URL, Healthy: false})
+	if upstreams := get(); len(upstreams) != 3 {
+		t.Errorf("expected 3 upstreams after a node turned unhealthy, got %d", len(upstreams))
+	}
+}
@@ -0,0 +1,245 @@
+package blockchain_health
+
+import (
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// chainBlockTimes are the typical block times of well-known chains, by chain
+// type and chain preset, for converting time-based height thresholds to
+// blocks. Beacon heights are slots.
+var chainBlockTimes = map[string]time.Duration{
+	"cosmos-hub":           6 * time.Second,
+	"ethereum":             12 * time.Second,
+	"base":                 2 * time.Second,
+	"optimism":             2 * time.Second,
+	"arbitrum":             250 * time.Millisecond,
+	"polygon":              2 * time.Second,
+	string(NodeTypeBeacon): 12 * time.Second,
+}
+
+// nodeChain returns the chain a node's height is compared within: its chain
+// type, or its protocol when it has none
+func nodeChain(node NodeConfig) string {
+	if node.ChainType != "" {
+		return node.ChainType
+	}
+	return string(node.Type)
+}
+
+// blockTime returns the block time of a chain: the configured block_time,
+// else the block time measured from the pool leader, else the typical block
+// time of the chain type or preset, or 0 if unknown
+func (h *HealthChecker) blockTime(chain string) time.Duration {
+	if d, err := time.ParseDuration(h.config.BlockValidation.BlockTime); err == nil && d > 0 {
+		return d
+	}
+	if d := h.measuredBlockTime(chain); d > 0 {
+		return d
+	}
+	if d, ok := chainBlockTimes[chain]; ok {
+		return d
+	}
+	preset := h.config.Chain.ChainPreset
+	if preset == "cosmos" {
+		preset = "cosmos-hub"
+	}
+	if d, ok := chainBlockTimes[preset]; ok {
+		return d
+	}
+	return 0
+}
+
+// heightThreshold returns how many blocks a node of the chain may trail the
+// pool leader. A time-based threshold is converted with the chain's block
+// time, rounding up; without a known block time the block threshold applies.
+func (h *HealthChecker) heightThreshold(chain string) int {
+	staleness, err := time.ParseDuration(h.config.BlockValidation.HeightThresholdTime)
+	if err != nil || staleness <= 0 {
+		return h.config.BlockValidation.HeightThreshold
+	}
+	blockTime := h.blockTime(chain)
+	if blockTime <= 0 {
+		if _, warned := h.blockTimeWarned.LoadOrStore(chain, true); !warned {
+			h.logger.Warn("no block time known for chain, using the block height threshold; set block_time",
+				zap.String("chain", chain),
+				zap.Duration("height_threshold", staleness),
+				zap.Int("blocks", h.config.BlockValidation.HeightThreshold))
+		}
+		return h.config.BlockValidation.HeightThreshold
+	}
+	return blocksWithin(staleness, blockTime)
+}
+
+// blocksWithin returns how many blocks are produced within d, rounding up
+// and at least 1
+func blocksWithin(d, blockTime time.Duration) int {
+	blocks := int((d + blockTime - 1) / blockTime)
+	if blocks < 1 {
+		return 1
+	}
+	return blocks
+}
+
+const (
+	// blockTimeSmoothing is the weight of each new sample in the measured
+	// block time, an exponentially weighted moving average
+	blockTimeSmoothing = 0.2
+
+	// blockTimeMinSamples is how many samples a measured block time needs
+	// before it replaces the typical block time
+	blockTimeMinSamples = 3
+
+	// defaultHaltMultiple is how many block times without a new block mean
+	// the chain halted, unless halt_multiple is set
+	defaultHaltMultiple = 10
+)
+
+// blockTimeEstimate tracks the block production rate of a chain from the
+// height of its pool leader at consecutive health checks
+type blockTimeEstimate struct {
+	height   uint64
+	at       time.Time
+	estimate time.Duration
+	samples  int
+	halted   bool
+}
+
+// observeHeight records the pool leader's height of a chain as of the time it
+// was checked. Each advance
+// yields a sample of the time per block since the previous advance; a sample
+// spanning a halt is discarded so the halt doesn't skew the estimate.
+func (h *HealthChecker) observeHeight(chain string, height uint64, now time.Time) {
+	if height == 0 {
+		return
+	}
+
+	h.blockTimesMutex.Lock()
+	state, exists := h.blockTimes[chain]
+	if !exists {
+		h.blockTimes[chain] = &blockTimeEstimate{height: height, at: now}
+		h.blockTimesMutex.Unlock()
+		return
+	}
+
+	if height <= state.height {
+		stalled := now.Sub(state.at)
+		h.blockTimesMutex.Unlock()
+
+		// blockTime takes the lock for the measured estimate
+		blockTime := h.blockTime(chain)
+		if blockTime <= 0 || stalled <= time.Duration(h.haltMultiple())*blockTime {
+			return
+		}
+		h.blockTimesMutex.Lock()
+		halted := state.halted
+		state.halted = true
+		h.blockTimesMutex.Unlock()
+		if !halted {
+			h.logger.Warn("chain halted: the pool leader produced no block",
+				zap.String("chain", chain),
+				zap.Uint64("height", height),
+				zap.Duration("since", stalled),
+				zap.Duration("block_time", blockTime))
+			h.emit(eventChainHalted, map[string]any{
+				"pool":    h.poolName(),
+				"chain":   chain,
+				"network": h.config.Chain.Network,
+				"height":  height,
+				"since":   stalled.String(),
+			})
+		}
+		return
+	}
+
+	resumed := state.halted
+	if elapsed := now.Sub(state.at); !resumed && elapsed > 0 {
+		sample := elapsed / time.Duration(height-state.height)
+		if state.samples == 0 {
+			state.estimate = sample
+		} else {
+			state.estimate = time.Duration(math.Round(
+				(1-blockTimeSmoothing)*float64(state.estimate) + blockTimeSmoothing*float64(sample)))
+		}
+		state.samples++
+	}
+	state.height = height
+	state.at = now
+	state.halted = false
+	estimate, samples := state.estimate, state.samples
+	h.blockTimesMutex.Unlock()
+
+	if resumed {
+		h.logger.Info("chain resumed block production",
+			zap.String("chain", chain),
+			zap.Uint64("height", height))
+		h.emit(eventChainResumed, map[string]any{
+			"pool":    h.poolName(),
+			"chain":   chain,
+			"network": h.config.Chain.Network,
+			"height":  height,
+		})
+	}
+	if samples >= blockTimeMinSamples && h.metrics != nil {
+		h.metrics.blockTime.WithLabelValues(chain, h.config.Chain.Network).Set(estimate.Seconds())
+	}
+}
+
+// lastChecked returns when the most recent of the results was checked, so
+// cached results are observed at their check time rather than now
+func lastChecked(nodes []*NodeHealth) time.Time {
+	var latest time.Time
+	for _, node := range nodes {
+		if node.LastCheck.After(latest) {
+			latest = node.LastCheck
+		}
+	}
+	if latest.IsZero() {
+		return time.Now()
+	}
+	return latest
+}
+
+// haltMultiple returns how many block times without a new block mean the
+// chain halted
+func (h *HealthChecker) haltMultiple() int {
+	if h.config.BlockValidation.HaltMultiple > 0 {
+		return h.config.BlockValidation.HaltMultiple
+	}
+	return defaultHaltMultiple
+}
+
+// chainHalted reports whether the pool leader of the chain stopped producing
+// blocks
+func (h *HealthChecker) chainHalted(chain string) bool {
+	h.blockTimesMutex.Lock()
+	defer h.blockTimesMutex.Unlock()
+	state, exists := h.blockTimes[chain]
+	return exists && state.halted
+}
+
+// anyChainHalted reports whether a chain of the pool is halted
+func (h *HealthChecker) anyChainHalted() bool {
+	h.blockTimesMutex.Lock()
+	defer h.blockTimesMutex.Unlock()
+	for _, state := range h.blockTimes {
+		if state.halted {
+			return true
+		}
+	}
+	return false
+}
+
+// measuredBlockTime returns the block time measured for a chain, or 0 until
+// enough samples were taken
+func (h *HealthChecker) measuredBlockTime(chain string) time.Duration {
+	h.blockTimesMutex.Lock()
+	defer h.blockTimesMutex.Unlock()
+	state, exists := h.blockTimes[chain]
+	if !exists || state.samples < blockTimeMinSamples {
+		return 0
+	}
+	return state.estimate
+}
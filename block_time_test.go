@@ -0,0 +1,243 @@
+package blockchain_health
+
+import (
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestBlocksWithin(t *testing.T) {
+	tests := []struct {
+		d, blockTime time.Duration
+		want         int
+	}{
+		{30 * time.Second, 12 * time.Second, 3},
+		{30 * time.Second, 400 * time.Millisecond, 75},
+		{24 * time.Second, 12 * time.Second, 2},
+		{time.Second, 12 * time.Second, 1},
+	}
+	for _, tt := range tests {
+		if got := blocksWithin(tt.d, tt.blockTime); got != tt.want {
+			t.Errorf("blocksWithin(%v, %v) = %d, want %d", tt.d, tt.blockTime, got, tt.want)
+		}
+	}
+}
+
+func TestHeightThreshold_TimeBased(t *testing.T) {
+	upstream := createTestUpstream(nil, zaptest.NewLogger(t))
+	checker := upstream.healthChecker
+	checker.config.BlockValidation.HeightThresholdTime = "30s"
+
+	tests := map[string]int{
+		"ethereum": 3,   // 12s blocks
+		"arbitrum": 120, // 250ms blocks
+		"akash":    5,   // unknown block time: the block threshold applies
+	}
+	for chain, want := range tests {
+		if got := checker.heightThreshold(chain); got != want {
+			t.Errorf("%s: expected %d blocks, got %d", chain, want, got)
+		}
+	}
+
+	// A configured block time applies to every chain of the pool
+	checker.config.BlockValidation.BlockTime = "400ms"
+	if got := checker.heightThreshold("akash"); got != 75 {
+		t.Errorf("expected 75 blocks with 400ms blocks, got %d", got)
+	}
+
+	// Presets supply the block time of generic chains
+	checker.config.BlockValidation.BlockTime = ""
+	checker.config.Chain.ChainPreset = "cosmos-hub"
+	if got := checker.heightThreshold("cosmos"); got != 5 {
+		t.Errorf("expected 5 blocks with the Cosmos Hub's 6s blocks, got %d", got)
+	}
+}
+
+func TestHeightThreshold_TimeBasedExclusion(t *testing.T) {
+	leader := createEVMServer(t, 1000, false)
+	defer leader.Close()
+	lagging := createEVMServer(t, 996, false)
+	defer lagging.Close()
+
+	for chain, wantHealthy := range map[string]bool{"ethereum": false, "base": true} {
+		nodes := []NodeConfig{
+			{Name: "leader", URL: leader.URL, Type: NodeTypeEVM, ChainType: chain, Weight: 1},
+			{Name: "lagging", URL: lagging.URL, Type: NodeTypeEVM, ChainType: chain, Weight: 1},
+		}
+		upstream := createTestUpstream(nodes, zaptest.NewLogger(t))
+		upstream.config.BlockValidation.HeightThresholdTime = "30s"
+
+		results, err := upstream.healthChecker.CheckAllNodes(t.Context())
+		if err != nil {
+			t.Fatalf("CheckAllNodes failed: %v", err)
+		}
+		for _, result := range results {
+			if result.Name == "lagging" && result.Healthy != wantHealthy {
+				t.Errorf("%s: expected a node 4 blocks behind to be healthy=%v within 30s", chain, wantHealthy)
+			}
+		}
+	}
+}
+
+func TestHeightThreshold_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:8545
+			type evm
+		}
+		height_threshold 30s
+		block_time 400ms
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if b.BlockValidation.HeightThresholdTime != "30s" || b.BlockValidation.BlockTime != "400ms" || b.BlockValidation.HeightThreshold != 0 {
+		t.Errorf("unexpected block validation %+v", b.BlockValidation)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+
+	b.BlockValidation.BlockTime = "fast"
+	if err := b.validate(); err == nil {
+		t.Error("expected an invalid block time to be rejected")
+	}
+}
+
+func TestObserveHeight_MeasuresBlockTime(t *testing.T) {
+	upstream := createTestUpstream(nil, zaptest.NewLogger(t))
+	checker := upstream.healthChecker
+	checker.config.BlockValidation.HeightThresholdTime = "30s"
+
+	start := time.Now()
+	checker.observeHeight("akash", 100, start)
+	for i := 1; i <= blockTimeMinSamples; i++ {
+		if checker.measuredBlockTime("akash") != 0 {
+			t.Fatalf("expected no measured block time after %d samples", i-1)
+		}
+		// Five blocks per 10s check
+		checker.observeHeight("akash", uint64(100+5*i), start.Add(time.Duration(i)*10*time.Second))
+	}
+
+	if got := checker.measuredBlockTime("akash"); got != 2*time.Second {
+		t.Errorf("expected a measured block time of 2s, got %v", got)
+	}
+	if got := checker.heightThreshold("akash"); got != 15 {
+		t.Errorf("expected 15 blocks with the measured 2s blocks, got %d", got)
+	}
+
+	// A configured block time wins over the measurement
+	checker.config.BlockValidation.BlockTime = "6s"
+	if got := checker.heightThreshold("akash"); got != 5 {
+		t.Errorf("expected 5 blocks with the configured 6s blocks, got %d", got)
+	}
+}
+
+func TestObserveHeight_HaltDiscardsSample(t *testing.T) {
+	upstream := createTestUpstream(nil, zaptest.NewLogger(t))
+	checker := upstream.healthChecker
+
+	start := time.Now()
+	for i := 0; i <= blockTimeMinSamples; i++ {
+		checker.observeHeight("akash", uint64(100+i), start.Add(time.Duration(i)*time.Second))
+	}
+	last := start.Add(blockTimeMinSamples * time.Second)
+
+	// No block for well over 10 block times, then production resumes
+	checker.observeHeight("akash", 100+blockTimeMinSamples, last.Add(time.Minute))
+	if !checker.blockTimes["akash"].halted {
+		t.Fatal("expected the chain to be detected as halted")
+	}
+	checker.observeHeight("akash", 101+blockTimeMinSamples, last.Add(2*time.Minute))
+	if checker.blockTimes["akash"].halted {
+		t.Error("expected the halt to end with a new block")
+	}
+	if got := checker.measuredBlockTime("akash"); got != time.Second {
+		t.Errorf("expected the halt to be left out of the measured block time, got %v", got)
+	}
+}
+
+func TestChainHalt_SuppressesLagEjection(t *testing.T) {
+	leader := createEVMServer(t, 1000, false)
+	defer leader.Close()
+	lagging := createEVMServer(t, 990, false)
+	defer lagging.Close()
+
+	nodes := []NodeConfig{
+		{Name: "leader", URL: leader.URL, Type: NodeTypeEVM, ChainType: "ethereum", Weight: 1},
+		{Name: "lagging", URL: lagging.URL, Type: NodeTypeEVM, ChainType: "ethereum", Weight: 1},
+	}
+	upstream := createTestUpstream(nodes, zaptest.NewLogger(t))
+	checker := upstream.healthChecker
+
+	// The leader was last seen producing block 1000 ten minutes ago, well over
+	// 10 of Ethereum's 12s blocks
+	checker.blockTimes["ethereum"] = &blockTimeEstimate{height: 1000, at: time.Now().Add(-10 * time.Minute)}
+
+	results, err := checker.CheckAllNodes(t.Context())
+	if err != nil {
+		t.Fatalf("CheckAllNodes failed: %v", err)
+	}
+	if !checker.chainHalted("ethereum") {
+		t.Fatal("expected the chain to be detected as halted")
+	}
+	for _, result := range results {
+		if !result.Healthy {
+			t.Errorf("expected %s to stay healthy while the chain is halted: %s", result.Name, result.LastError)
+		}
+	}
+	if got := checker.classifyPool(results); got != PoolChainHalted {
+		t.Errorf("expected the pool to be chain_halted, got %s", got)
+	}
+
+	// Without a halt the lagging node is ejected again
+	checker.blockTimes["ethereum"].halted = false
+	checker.blockTimes["ethereum"].at = time.Now()
+	checker.cache.Clear()
+	results, err = checker.CheckAllNodes(t.Context())
+	if err != nil {
+		t.Fatalf("CheckAllNodes failed: %v", err)
+	}
+	for _, result := range results {
+		if result.Name == "lagging" && result.Healthy {
+			t.Error("expected the lagging node to be ejected once the chain produces blocks")
+		}
+	}
+}
+
+func TestHaltMultiple(t *testing.T) {
+	upstream := createTestUpstream(nil, zaptest.NewLogger(t))
+	checker := upstream.healthChecker
+	checker.config.BlockValidation.BlockTime = "1s"
+	checker.config.BlockValidation.HaltMultiple = 3
+
+	start := time.Now()
+	checker.observeHeight("akash", 100, start)
+	checker.observeHeight("akash", 100, start.Add(2*time.Second))
+	if checker.chainHalted("akash") {
+		t.Fatal("expected no halt within 3 block times")
+	}
+	checker.observeHeight("akash", 100, start.Add(4*time.Second))
+	if !checker.chainHalted("akash") {
+		t.Error("expected a halt after 3 block times without a block")
+	}
+
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:8545
+			type evm
+		}
+		halt_multiple 20
+	}`)
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if b.BlockValidation.HaltMultiple != 20 {
+		t.Errorf("expected halt_multiple 20, got %d", b.BlockValidation.HaltMultiple)
+	}
+}
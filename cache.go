@@ -7,9 +7,9 @@ import (
 // NewHealthCache creates a new health cache with the specified duration
 func NewHealthCache(duration time.Duration) *HealthCache {
 	cache := &HealthCache{
-		cache:    make(map[string]*CacheEntry),
 		duration: duration,
 	}
+	cache.entries.Store(&map[string]*CacheEntry{})
 
 	// Start cleanup goroutine
 	go cache.cleanup()
@@ -19,34 +19,104 @@ func NewHealthCache(duration time.Duration) *HealthCache {
 
 // Get retrieves a cached health result
 func (hc *HealthCache) Get(nodeName string) *NodeHealth {
-	hc.mutex.RLock()
-	defer hc.mutex.RUnlock()
-
-	entry, exists := hc.cache[nodeName]
+	entry, exists := hc.snapshot()[nodeName]
 	if !exists {
+		hc.misses.Add(1)
 		return nil
 	}
 
 	// Check if entry has expired
 	if time.Now().After(entry.ExpiresAt) {
 		// Don't delete here to avoid write lock, let cleanup handle it
+		hc.misses.Add(1)
 		return nil
 	}
 
+	hc.hits.Add(1)
 	return entry.Health
 }
 
+// appendNodes appends the cached results of the nodes to results from one
+// snapshot and clock read. It stops at the first node without a fresh result
+// and returns how many nodes it found.
+func (hc *HealthCache) appendNodes(results []*NodeHealth, nodes []NodeConfig) ([]*NodeHealth, int) {
+	results, found, _ := hc.appendNodesFrom(hc.entries.Load(), results, nodes)
+	return results, found
+}
+
+// appendNodesFrom is appendNodes reading the given entries, and also returns
+// when the first of the results found expires
+func (hc *HealthCache) appendNodesFrom(entries *map[string]*CacheEntry, results []*NodeHealth, nodes []NodeConfig) ([]*NodeHealth, int, time.Time) {
+	now := time.Now()
+	var expires time.Time
+	for i, node := range nodes {
+		entry, exists := (*entries)[node.key()]
+		if !exists || now.After(entry.ExpiresAt) {
+			hc.hits.Add(uint64(i))
+			hc.misses.Add(1)
+			return results, i, expires
+		}
+		if expires.IsZero() || entry.ExpiresAt.Before(expires) {
+			expires = entry.ExpiresAt
+		}
+		results = append(results, entry.Health)
+	}
+	hc.hits.Add(uint64(len(nodes)))
+	return results, len(nodes), expires
+}
+
+// generation returns the current entries. A write stores new entries, so
+// the pointer identifies the cache contents.
+func (hc *HealthCache) generation() *map[string]*CacheEntry {
+	return hc.entries.Load()
+}
+
 // Set stores a health result in the cache
 func (hc *HealthCache) Set(nodeName string, health *NodeHealth) {
+	hc.SetWithTTL(nodeName, health, hc.duration)
+}
+
+// SetWithTTL stores a health result in the cache for a specific duration
+func (hc *HealthCache) SetWithTTL(nodeName string, health *NodeHealth, ttl time.Duration) {
 	hc.mutex.Lock()
 	defer hc.mutex.Unlock()
 
 	entry := &CacheEntry{
 		Health:    health,
-		ExpiresAt: time.Now().Add(hc.duration),
+		ExpiresAt: time.Now().Add(ttl),
 	}
 
-	hc.cache[nodeName] = entry
+	entries := hc.copyEntries()
+	entries[nodeName] = entry
+	hc.entries.Store(&entries)
+}
+
+// cacheUpdate is a processed copy of a cached health result
+type cacheUpdate struct {
+	key           string
+	old, replaced *NodeHealth
+}
+
+// replace swaps cached results for their processed copies, keeping their
+// expiry. Results cached again since they were read are left alone.
+func (hc *HealthCache) replace(updates []cacheUpdate) {
+	hc.mutex.Lock()
+	defer hc.mutex.Unlock()
+
+	var entries map[string]*CacheEntry
+	for _, update := range updates {
+		entry, exists := hc.snapshot()[update.key]
+		if !exists || entry.Health != update.old {
+			continue
+		}
+		if entries == nil {
+			entries = hc.copyEntries()
+		}
+		entries[update.key] = &CacheEntry{Health: update.replaced, ExpiresAt: entry.ExpiresAt}
+	}
+	if entries != nil {
+		hc.entries.Store(&entries)
+	}
 }
 
 // Delete removes a cached entry
@@ -54,7 +124,12 @@ func (hc *HealthCache) Delete(nodeName string) {
 	hc.mutex.Lock()
 	defer hc.mutex.Unlock()
 
-	delete(hc.cache, nodeName)
+	if _, exists := hc.snapshot()[nodeName]; !exists {
+		return
+	}
+	entries := hc.copyEntries()
+	delete(entries, nodeName)
+	hc.entries.Store(&entries)
 }
 
 // Clear removes all cached entries
@@ -62,15 +137,28 @@ func (hc *HealthCache) Clear() {
 	hc.mutex.Lock()
 	defer hc.mutex.Unlock()
 
-	hc.cache = make(map[string]*CacheEntry)
+	hc.entries.Store(&map[string]*CacheEntry{})
 }
 
 // Size returns the number of cached entries
 func (hc *HealthCache) Size() int {
-	hc.mutex.RLock()
-	defer hc.mutex.RUnlock()
+	return len(hc.snapshot())
+}
 
-	return len(hc.cache)
+// snapshot returns the current entries, which must not be modified
+func (hc *HealthCache) snapshot() map[string]*CacheEntry {
+	return *hc.entries.Load()
+}
+
+// copyEntries returns a copy of the current entries for a writer to modify
+// and store. Callers hold the mutex.
+func (hc *HealthCache) copyEntries() map[string]*CacheEntry {
+	current := hc.snapshot()
+	entries := make(map[string]*CacheEntry, len(current)+1)
+	for name, entry := range current {
+		entries[name] = entry
+	}
+	return entries
 }
 
 // cleanup periodically removes expired entries
@@ -89,32 +177,47 @@ func (hc *HealthCache) removeExpired() {
 	defer hc.mutex.Unlock()
 
 	now := time.Now()
-	for nodeName, entry := range hc.cache {
+	var entries map[string]*CacheEntry
+	for nodeName, entry := range hc.snapshot() {
 		if now.After(entry.ExpiresAt) {
-			delete(hc.cache, nodeName)
+			if entries == nil {
+				entries = hc.copyEntries()
+			}
+			delete(entries, nodeName)
 		}
 	}
+	if entries != nil {
+		hc.entries.Store(&entries)
+	}
+}
+
+// hitRatio returns the share of lookups that found a fresh result, or false
+// before the first lookup
+func (hc *HealthCache) hitRatio() (float64, bool) {
+	hits, misses := hc.hits.Load(), hc.misses.Load()
+	if hits+misses == 0 {
+		return 0, false
+	}
+	return float64(hits) / float64(hits+misses), true
 }
 
 // GetStats returns cache statistics
 func (hc *HealthCache) GetStats() map[string]interface{} {
-	hc.mutex.RLock()
-	defer hc.mutex.RUnlock()
-
+	entries := hc.snapshot()
 	stats := make(map[string]interface{})
-	stats["total_entries"] = len(hc.cache)
+	stats["total_entries"] = len(entries)
 	stats["cache_duration"] = hc.duration.String()
 
 	// Count expired entries
 	now := time.Now()
 	expiredCount := 0
-	for _, entry := range hc.cache {
+	for _, entry := range entries {
 		if now.After(entry.ExpiresAt) {
 			expiredCount++
 		}
 	}
 	stats["expired_entries"] = expiredCount
-	stats["valid_entries"] = len(hc.cache) - expiredCount
+	stats["valid_entries"] = len(entries) - expiredCount
 
 	return stats
 }
@@ -1,6 +1,8 @@
 package blockchain_health
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 )
@@ -163,3 +165,91 @@ func TestHealthCache_Stats(t *testing.T) {
 		t.Errorf("Expected valid_entries=0 after expiration, got %v", stats["valid_entries"])
 	}
 }
+
+func TestNodeKey_IgnoresName(t *testing.T) {
+	a := NodeConfig{Name: "cosmos-rpc-0", URL: "http://10.0.0.1:26657", Type: NodeTypeCosmos}
+	b := NodeConfig{Name: "cosmos-rpc-1", URL: "http://10.0.0.1:26657", Type: NodeTypeCosmos}
+	if a.key() != b.key() {
+		t.Errorf("expected nodes with the same URL and type to share a key, got %s and %s", a.key(), b.key())
+	}
+
+	c := NodeConfig{Name: "cosmos-rpc-0", URL: "http://10.0.0.1:26657", Type: NodeTypeEVM}
+	if a.key() == c.key() {
+		t.Error("expected nodes of different types to have different keys")
+	}
+
+	// A renamed node keeps its cached result
+	cache := NewHealthCache(time.Minute)
+	cache.Set(a.key(), &NodeHealth{Name: a.Name, Healthy: true})
+	if cache.Get(b.key()) == nil {
+		t.Error("expected the cached result to be found under the new name")
+	}
+}
+
+func TestNodeKey_Memoized(t *testing.T) {
+	nodes := []NodeConfig{{Name: "a", URL: "http://10.0.0.1:26657", Type: NodeTypeCosmos}}
+	want := nodes[0].key()
+	memoizeKeys(nodes)
+	if got := nodes[0].key(); got != want {
+		t.Errorf("expected the memoized key %s, got %s", want, got)
+	}
+
+	// A copy given another URL, as for alternate endpoints, has its own key
+	endpoint := nodes[0]
+	endpoint.URL = "http://10.0.0.2:26657"
+	if endpoint.key() == want {
+		t.Error("expected a copy with another URL not to keep the memoized key")
+	}
+}
+
+func TestHealthCache_SnapshotsUnaffectedByWrites(t *testing.T) {
+	cache := NewHealthCache(time.Minute)
+	defer cache.Clear()
+
+	nodes := []NodeConfig{
+		{Name: "node-1", URL: "http://10.0.0.1:26657", Type: NodeTypeCosmos},
+		{Name: "node-2", URL: "http://10.0.0.2:26657", Type: NodeTypeCosmos},
+	}
+	for _, node := range nodes {
+		cache.Set(node.key(), &NodeHealth{Name: node.Name, Healthy: true})
+	}
+
+	// Readers keep going while health checks write and remove results
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				results, _ := cache.appendNodes(nil, nodes)
+				for _, result := range results {
+					if result == nil {
+						t.Error("expected cached results to never be nil")
+						return
+					}
+				}
+			}
+		}()
+	}
+	for i := 0; i < 1000; i++ {
+		name := fmt.Sprintf("other-%d", i%10)
+		cache.Set(name, &NodeHealth{Name: name})
+		cache.Delete(name)
+	}
+	close(stop)
+	wg.Wait()
+
+	results, found := cache.appendNodes(nil, nodes)
+	if found != len(nodes) || len(results) != len(nodes) {
+		t.Errorf("expected both nodes to stay cached, found %d", found)
+	}
+	if cache.Size() != len(nodes) {
+		t.Errorf("expected %d entries, got %d", len(nodes), cache.Size())
+	}
+}
@@ -0,0 +1,105 @@
+package blockchain_health
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestCandidateNodesNeverReceiveTraffic verifies candidate nodes are health
+// checked and compared against the pool but excluded from upstreams
+func TestCandidateNodesNeverReceiveTraffic(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	poolServer := createCosmosServer(t, 1000, false)
+	defer poolServer.Close()
+	candidateServer := createCosmosServer(t, 1003, false)
+	defer candidateServer.Close()
+
+	nodes := []NodeConfig{
+		{Name: "pool", URL: poolServer.URL, Type: NodeTypeCosmos, Weight: 1},
+		{
+			Name:     "candidate",
+			URL:      candidateServer.URL,
+			Type:     NodeTypeCosmos,
+			Weight:   1,
+			Metadata: map[string]string{"candidate": "true"},
+		},
+	}
+
+	upstream := createTestUpstream(nodes, logger)
+
+	results, err := upstream.healthChecker.CheckAllNodes(context.Background())
+	if err != nil {
+		t.Fatalf("CheckAllNodes failed: %v", err)
+	}
+
+	for _, result := range results {
+		if !result.Healthy {
+			t.Errorf("Expected node %s to be healthy", result.Name)
+		}
+		// The candidate is ahead of the pool; the pool node must not be
+		// penalised because leader height ignores candidates
+		if result.Name == "pool" && result.BlocksBehindPool != 0 {
+			t.Errorf("Expected pool node to lead the pool, got %d blocks behind", result.BlocksBehindPool)
+		}
+		if result.Name == "candidate" && result.BlocksBehindPool != -3 {
+			t.Errorf("Expected candidate to be 3 blocks ahead of the pool, got %d", result.BlocksBehindPool)
+		}
+	}
+
+	upstreams, err := upstream.GetUpstreams(&http.Request{})
+	if err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+	if len(upstreams) != 1 {
+		t.Fatalf("Expected only the pool node to be selected, got %d upstreams", len(upstreams))
+	}
+	if upstreams[0].Dial != getDynamicTestHostFromURL(poolServer.URL) {
+		t.Errorf("Expected pool node upstream, got %s", upstreams[0].Dial)
+	}
+}
+
+// TestCandidateNodesExcludedFromPoolGauges verifies candidates are reported
+// in their own gauges rather than the pool's healthy/unhealthy totals
+func TestCandidateNodesExcludedFromPoolGauges(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	poolServer := createCosmosServer(t, 1000, false)
+	defer poolServer.Close()
+	candidateServer := createCosmosServer(t, 1000, true) // catching up
+	defer candidateServer.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "pool", URL: poolServer.URL, Type: NodeTypeCosmos, Weight: 1},
+		{Name: "candidate", URL: candidateServer.URL, Type: NodeTypeCosmos, Weight: 1, Metadata: map[string]string{"candidate": "true"}},
+	}, logger)
+
+	if _, err := upstream.healthChecker.CheckAllNodes(context.Background()); err != nil {
+		t.Fatalf("CheckAllNodes failed: %v", err)
+	}
+
+	metrics := upstream.healthChecker.metrics
+	gauges := map[string]struct {
+		gauge    prometheus.Gauge
+		expected float64
+	}{
+		"healthy_nodes":             {metrics.healthyNodes, 1},
+		"unhealthy_nodes":           {metrics.unhealthyNodes, 0},
+		"healthy_candidate_nodes":   {metrics.healthyCandidates, 0},
+		"unhealthy_candidate_nodes": {metrics.unhealthyCandidates, 1},
+	}
+	for name, g := range gauges {
+		var m dto.Metric
+		if err := g.gauge.Write(&m); err != nil {
+			t.Fatalf("reading %s: %v", name, err)
+		}
+		if got := m.GetGauge().GetValue(); got != g.expected {
+			t.Errorf("Expected %s=%v, got %v", name, g.expected, got)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+)
+
+// Chain lag header defaults
+const (
+	defaultChainLagHeader = "X-Chain-Lag"
+	defaultChainLagMin    = 1
+)
+
+// chainLagVar is the request variable holding the estimated blocks behind of
+// each potentially lagging upstream, keyed by dial address
+const chainLagVar = "blockchain_health.chain_lag"
+
+// ChainLag is a middleware placed in front of reverse_proxy that marks
+// responses served by potentially lagging upstreams (fallback or dry-run
+// nodes) with how many blocks behind the node is estimated to be, so clients
+// can decide whether to trust the data.
+type ChainLag struct {
+	Header  string `json:"header,omitempty"`
+	MinLag  int64  `json:"min_lag,omitempty"`
+	Warning *bool  `json:"warning,omitempty"`
+}
+
+func init() {
+	caddy.RegisterModule(&ChainLag{})
+}
+
+// CaddyModule returns the Caddy module information.
+func (*ChainLag) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.blockchain_chain_lag",
+		New: func() caddy.Module { return new(ChainLag) },
+	}
+}
+
+// Provision applies defaults
+func (h *ChainLag) Provision(ctx caddy.Context) error {
+	if h.Header == "" {
+		h.Header = defaultChainLagHeader
+	}
+	if h.MinLag == 0 {
+		h.MinLag = defaultChainLagMin
+	}
+	if h.Warning == nil {
+		warning := true
+		h.Warning = &warning
+	}
+	return nil
+}
+
+// Validate checks configuration correctness
+func (h *ChainLag) Validate() error {
+	if h.MinLag < 0 {
+		return fmt.Errorf("min_lag must not be negative")
+	}
+	if strings.ContainsAny(h.Header, " \t\r\n:") {
+		return fmt.Errorf("invalid header name %q", h.Header)
+	}
+	return nil
+}
+
+// ServeHTTP adds the lag headers once the upstream for the response is known
+func (h *ChainLag) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	return next.ServeHTTP(&chainLagWriter{
+		ResponseWriterWrapper: &caddyhttp.ResponseWriterWrapper{ResponseWriter: w},
+		request:               r,
+		lag:                   h,
+	}, r)
+}
+
+// chainLagWriter sets the lag headers before the response header is written
+type chainLagWriter struct {
+	*caddyhttp.ResponseWriterWrapper
+	request     *http.Request
+	lag         *ChainLag
+	wroteHeader bool
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *chainLagWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.setHeaders()
+	}
+	w.ResponseWriterWrapper.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (w *chainLagWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriterWrapper.Write(p)
+}
+
+// ReadFrom implements io.ReaderFrom
+func (w *chainLagWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriterWrapper.ReadFrom(r)
+}
+
+// setHeaders looks up the lag of the upstream reverse_proxy picked
+func (w *chainLagWriter) setHeaders() {
+	lags, ok := caddyhttp.GetVar(w.request.Context(), chainLagVar).(map[string]int64)
+	if !ok {
+		return
+	}
+	repl, ok := w.request.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer)
+	if !ok {
+		return
+	}
+	lag, ok := lags[repl.ReplaceAll("{http.reverse_proxy.upstream.hostport}", "")]
+	if !ok || lag < w.lag.MinLag {
+		return
+	}
+
+	w.Header().Set(w.lag.Header, strconv.FormatInt(lag, 10))
+	if w.lag.Warning == nil || *w.lag.Warning {
+		// 199 is the miscellaneous warning code (RFC 7234 section 5.5)
+		w.Header().Add("Warning", fmt.Sprintf(`199 blockchain_health "upstream is an estimated %d blocks behind"`, lag))
+	}
+}
+
+// laggingUpstreams returns the estimated blocks behind of the selected
+// upstreams that may be lagging: fallback and dry-run nodes. Healthy nodes
+// are within the configured thresholds and are not reported. Block validation
+// skips unhealthy nodes, so the lag is estimated against the highest height
+// any pool node reported; nodes without a known height are not reported.
+func laggingUpstreams(healthResults []*NodeHealth, upstreams []*reverseproxy.Upstream, infos []selectionInfo) map[string]int64 {
+	if !slices.ContainsFunc(infos, lagReported) {
+		return nil
+	}
+
+	byName := make(map[string]*NodeHealth, len(healthResults))
+	var head uint64
+	for _, health := range healthResults {
+		byName[health.Name] = health
+		if health.BlockHeight > head {
+			head = health.BlockHeight
+		}
+	}
+
+	var lags map[string]int64
+	for i, info := range infos {
+		if i >= len(upstreams) {
+			break
+		}
+		if !lagReported(info) {
+			continue
+		}
+		health, ok := byName[info.name]
+		if !ok || health.BlockHeight == 0 {
+			continue
+		}
+		if lags == nil {
+			lags = make(map[string]int64)
+		}
+		lags[upstreams[i].Dial] = int64(head - health.BlockHeight)
+	}
+	return lags
+}
+
+// lagReported reports whether the lag of a selected node is reported: only
+// fallback and dry-run selections may lag
+func lagReported(info selectionInfo) bool {
+	return strings.HasPrefix(info.reason, "fallback_") || info.reason == "dry_run"
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*ChainLag)(nil)
+	_ caddy.Validator             = (*ChainLag)(nil)
+	_ caddyhttp.MiddlewareHandler = (*ChainLag)(nil)
+	_ io.ReaderFrom               = (*chainLagWriter)(nil)
+)
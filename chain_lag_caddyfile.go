@@ -0,0 +1,70 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	httpcaddyfile "github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+func init() {
+	// Register Caddyfile directive for this handler
+	httpcaddyfile.RegisterHandlerDirective("blockchain_chain_lag", parseChainLagCaddyfile)
+	httpcaddyfile.RegisterDirectiveOrder("blockchain_chain_lag", httpcaddyfile.Before, "reverse_proxy")
+}
+
+func parseChainLagCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	cl := new(ChainLag)
+	if err := cl.UnmarshalCaddyfile(h.Dispenser); err != nil {
+		return nil, err
+	}
+	return cl, nil
+}
+
+// UnmarshalCaddyfile implements caddyfile.Unmarshaler for blockchain_chain_lag
+func (h *ChainLag) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	for d.Next() {
+		for d.NextBlock(0) {
+			switch d.Val() {
+			case "header":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				h.Header = d.Val()
+
+			case "min_lag":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				n, err := strconv.ParseInt(d.Val(), 10, 64)
+				if err != nil {
+					return d.Errf("invalid min_lag: %v", err)
+				}
+				h.MinLag = n
+
+			case "warning":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				enabled, err := strconv.ParseBool(d.Val())
+				if err != nil {
+					return d.Errf("invalid warning value: %v", err)
+				}
+				h.Warning = &enabled
+
+			default:
+				return d.Errf("unknown directive: %s", d.Val())
+			}
+		}
+	}
+
+	if err := h.Validate(); err != nil {
+		return fmt.Errorf("blockchain_chain_lag validation: %w", err)
+	}
+	return nil
+}
+
+// Interface guard
+var _ caddyfile.Unmarshaler = (*ChainLag)(nil)
@@ -0,0 +1,133 @@
+package blockchain_health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestLaggingUpstreams_FallbackNodes(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	// Both nodes are catching up, so the pool falls back to all of them
+	ahead := createCosmosServer(t, 1000, true)
+	defer ahead.Close()
+	behind := createCosmosServer(t, 900, true)
+	defer behind.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "ahead", URL: ahead.URL, Type: NodeTypeCosmos, Weight: 1},
+		{Name: "behind", URL: behind.URL, Type: NodeTypeCosmos, Weight: 1},
+	}, logger)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(context.WithValue(r.Context(), caddyhttp.VarsCtxKey, map[string]any{}))
+	if _, err := upstream.GetUpstreams(r); err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+
+	lags, ok := caddyhttp.GetVar(r.Context(), chainLagVar).(map[string]int64)
+	if !ok {
+		t.Fatal("expected the lag of fallback upstreams to be recorded")
+	}
+	if lag := lags[getDynamicTestHostFromURL(behind.URL)]; lag != 100 {
+		t.Errorf("expected the lagging node to be 100 blocks behind, got %d", lag)
+	}
+	if lag := lags[getDynamicTestHostFromURL(ahead.URL)]; lag != 0 {
+		t.Errorf("expected the leading node not to lag, got %d", lag)
+	}
+}
+
+func TestLaggingUpstreams_HealthyNodesNotReported(t *testing.T) {
+	results := []*NodeHealth{{Name: "leader", BlockHeight: 103}, {Name: "node", BlockHeight: 100}}
+	upstreams := []*reverseproxy.Upstream{{Dial: "node:26657"}}
+	if lags := laggingUpstreams(results, upstreams, []selectionInfo{{name: "node", reason: "healthy"}}); lags != nil {
+		t.Errorf("expected healthy nodes not to be reported, got %v", lags)
+	}
+	lags := laggingUpstreams(results, upstreams, []selectionInfo{{name: "node", reason: "fallback_all"}})
+	if lags["node:26657"] != 3 {
+		t.Errorf("expected the fallback node lag to be reported, got %v", lags)
+	}
+}
+
+func TestChainLag_SetsHeaders(t *testing.T) {
+	tests := []struct {
+		name          string
+		lag           int64
+		minLag        int64
+		warning       bool
+		expectHeader  string
+		expectWarning bool
+	}{
+		{name: "lagging", lag: 12, minLag: 1, warning: true, expectHeader: "12", expectWarning: true},
+		{name: "below_min_lag", lag: 2, minLag: 5, warning: true},
+		{name: "warning_disabled", lag: 12, minLag: 1, expectHeader: "12"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warning := tt.warning
+			h := &ChainLag{MinLag: tt.minLag, Warning: &warning}
+			if err := h.Provision(caddy.Context{}); err != nil {
+				t.Fatalf("Provision failed: %v", err)
+			}
+
+			repl := caddy.NewReplacer()
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			ctx := context.WithValue(r.Context(), caddy.ReplacerCtxKey, repl)
+			ctx = context.WithValue(ctx, caddyhttp.VarsCtxKey, map[string]any{
+				chainLagVar: map[string]int64{"node:8545": tt.lag},
+			})
+			r = r.WithContext(ctx)
+
+			// Stand in for reverse_proxy picking the lagging node
+			next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+				repl.Set("http.reverse_proxy.upstream.hostport", "node:8545")
+				_, err := w.Write([]byte(`{}`))
+				return err
+			})
+
+			rec := httptest.NewRecorder()
+			if err := h.ServeHTTP(rec, r, next); err != nil {
+				t.Fatalf("ServeHTTP returned error: %v", err)
+			}
+			if got := rec.Header().Get("X-Chain-Lag"); got != tt.expectHeader {
+				t.Errorf("expected X-Chain-Lag %q, got %q", tt.expectHeader, got)
+			}
+			if got := rec.Header().Get("Warning"); (got != "") != tt.expectWarning {
+				t.Errorf("unexpected Warning header %q", got)
+			}
+		})
+	}
+}
+
+func TestChainLag_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_chain_lag {
+		header X-Blocks-Behind
+		min_lag 5
+		warning false
+	}`)
+
+	var h ChainLag
+	if err := h.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if h.Header != "X-Blocks-Behind" || h.MinLag != 5 || h.Warning == nil || *h.Warning {
+		t.Errorf("unexpected config: header=%q min_lag=%d warning=%v", h.Header, h.MinLag, h.Warning)
+	}
+
+	d = caddyfile.NewTestDispenser(`blockchain_chain_lag {
+		min_lag -1
+	}`)
+	h = ChainLag{}
+	if err := h.UnmarshalCaddyfile(d); err == nil {
+		t.Error("expected a negative min_lag to be rejected")
+	}
+}
@@ -0,0 +1,147 @@
+package blockchain_health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// chainRouteVar is the request variable holding the chain group a request
+// was routed to by its path prefix
+const chainRouteVar = "blockchain_health.route_chain"
+
+// ChainRoute is a middleware placed in front of reverse_proxy that lets one
+// site serve several chains of a pool under their own path prefixes, such as
+// /cosmos/*, /evm/* and /beacon/*. A request under a prefix has the prefix
+// stripped and is only routed to the nodes of the prefix's chain group (their
+// chain_type, or their type when they have none). Other requests pass
+// through unchanged.
+type ChainRoute struct {
+	Routes []ChainRoutePrefix `json:"routes,omitempty"`
+}
+
+// ChainRoutePrefix binds a path prefix to a chain group
+type ChainRoutePrefix struct {
+	Prefix string `json:"prefix"`
+	Chain  string `json:"chain"`
+}
+
+func init() {
+	caddy.RegisterModule(&ChainRoute{})
+}
+
+// CaddyModule returns the Caddy module information.
+func (*ChainRoute) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.blockchain_chain_route",
+		New: func() caddy.Module { return new(ChainRoute) },
+	}
+}
+
+// Provision normalizes the prefixes and sorts them longest first, so a
+// prefix wins over a shorter one it is nested in
+func (h *ChainRoute) Provision(ctx caddy.Context) error {
+	for i := range h.Routes {
+		h.Routes[i].Prefix = normalizeRoutePrefix(h.Routes[i].Prefix)
+	}
+	slices.SortStableFunc(h.Routes, func(a, b ChainRoutePrefix) int {
+		return len(b.Prefix) - len(a.Prefix)
+	})
+	return nil
+}
+
+// Validate checks configuration correctness
+func (h *ChainRoute) Validate() error {
+	if len(h.Routes) == 0 {
+		return fmt.Errorf("at least one route is required")
+	}
+	seen := make(map[string]bool, len(h.Routes))
+	for _, route := range h.Routes {
+		prefix := normalizeRoutePrefix(route.Prefix)
+		if prefix == "/" {
+			return fmt.Errorf("route prefix %q matches every request", route.Prefix)
+		}
+		if seen[prefix] {
+			return fmt.Errorf("route prefix %s listed more than once", prefix)
+		}
+		seen[prefix] = true
+		if route.Chain == "" {
+			return fmt.Errorf("route prefix %s: chain is required", prefix)
+		}
+	}
+	return nil
+}
+
+// normalizeRoutePrefix gives a prefix a leading slash and no trailing one
+func normalizeRoutePrefix(prefix string) string {
+	return "/" + strings.Trim(prefix, "/")
+}
+
+// ServeHTTP strips the prefix of a routed request and records its chain group
+func (h *ChainRoute) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	for _, route := range h.Routes {
+		rest, ok := cutRoutePrefix(r.URL.Path, route.Prefix)
+		if !ok {
+			continue
+		}
+		r.URL.Path = rest
+		if r.URL.RawPath != "" {
+			if raw, ok := cutRoutePrefix(r.URL.RawPath, route.Prefix); ok {
+				r.URL.RawPath = raw
+			} else {
+				r.URL.RawPath = ""
+			}
+		}
+		caddyhttp.SetVar(r.Context(), chainRouteVar, route.Chain)
+		break
+	}
+	return next.ServeHTTP(w, r)
+}
+
+// cutRoutePrefix strips a prefix from a path at a segment boundary, so /evm
+// matches /evm and /evm/x but not /evmos
+func cutRoutePrefix(path, prefix string) (string, bool) {
+	rest, ok := strings.CutPrefix(path, prefix)
+	if !ok || (rest != "" && rest[0] != '/') {
+		return path, false
+	}
+	if rest == "" {
+		rest = "/"
+	}
+	return rest, true
+}
+
+// routedChain returns the chain group the request was routed to, if any
+func routedChain(ctx context.Context) string {
+	chain, _ := caddyhttp.GetVar(ctx, chainRouteVar).(string)
+	return chain
+}
+
+// routeResults returns the results of the nodes in the chain group the
+// request was routed to, or all results when it was not routed
+func (b *BlockchainHealthUpstream) routeResults(ctx context.Context, results []*NodeHealth) []*NodeHealth {
+	chain := routedChain(ctx)
+	if chain == "" {
+		return results
+	}
+	nodes := b.config.nodeList()
+	routed := make([]*NodeHealth, 0, len(results))
+	for _, health := range results {
+		if node := findNode(nodes, health.Name); node != nil && nodeChain(*node) == chain {
+			routed = append(routed, health)
+		}
+	}
+	return routed
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*ChainRoute)(nil)
+	_ caddy.Validator             = (*ChainRoute)(nil)
+	_ caddyhttp.MiddlewareHandler = (*ChainRoute)(nil)
+)
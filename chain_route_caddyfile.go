@@ -0,0 +1,55 @@
+package blockchain_health
+
+import (
+	"fmt"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	httpcaddyfile "github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+func init() {
+	// Register Caddyfile directive for this handler. It runs ahead of handle
+	// blocks and the other blockchain handlers so they see the stripped path.
+	httpcaddyfile.RegisterHandlerDirective("blockchain_chain_route", parseChainRouteCaddyfile)
+	httpcaddyfile.RegisterDirectiveOrder("blockchain_chain_route", httpcaddyfile.Before, "handle")
+}
+
+func parseChainRouteCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	cr := new(ChainRoute)
+	if err := cr.UnmarshalCaddyfile(h.Dispenser); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// UnmarshalCaddyfile implements caddyfile.Unmarshaler for blockchain_chain_route.
+// Each line of the block binds a path prefix to a chain group:
+//
+//	blockchain_chain_route {
+//	    /cosmos cosmos
+//	    /evm    evm
+//	}
+func (h *ChainRoute) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	for d.Next() {
+		for d.NextBlock(0) {
+			prefix := d.Val()
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			chain := d.Val()
+			if d.NextArg() {
+				return d.ArgErr()
+			}
+			h.Routes = append(h.Routes, ChainRoutePrefix{Prefix: prefix, Chain: chain})
+		}
+	}
+
+	if err := h.Validate(); err != nil {
+		return fmt.Errorf("blockchain_chain_route validation: %w", err)
+	}
+	return nil
+}
+
+// Interface guard
+var _ caddyfile.Unmarshaler = (*ChainRoute)(nil)
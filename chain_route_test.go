@@ -0,0 +1,125 @@
+package blockchain_health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestChainRoute_StripsPrefix(t *testing.T) {
+	h := &ChainRoute{Routes: []ChainRoutePrefix{
+		{Prefix: "/evm", Chain: "evm"},
+		{Prefix: "evm/archive/", Chain: "evm-archive"},
+		{Prefix: "/cosmos", Chain: "cosmos"},
+	}}
+	if err := h.Provision(caddy.Context{}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path, rawPath   string
+		wantPath, chain string
+		wantRawPath     string
+	}{
+		{path: "/evm", wantPath: "/", chain: "evm"},
+		{path: "/evm/x", wantPath: "/x", chain: "evm"},
+		{path: "/evm/archive/x", wantPath: "/x", chain: "evm-archive"},
+		{path: "/cosmos/status", wantPath: "/status", chain: "cosmos"},
+		{path: "/cosmos/a b", rawPath: "/cosmos/a%20b", wantPath: "/a b", wantRawPath: "/a%20b", chain: "cosmos"},
+		{path: "/evmos", wantPath: "/evmos"},
+		{path: "/", wantPath: "/"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.URL.Path, r.URL.RawPath = tt.path, tt.rawPath
+		r = r.WithContext(context.WithValue(r.Context(), caddyhttp.VarsCtxKey, map[string]any{}))
+
+		var seen *http.Request
+		next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			seen = r
+			return nil
+		})
+		if err := h.ServeHTTP(httptest.NewRecorder(), r, next); err != nil {
+			t.Fatal(err)
+		}
+		if seen.URL.Path != tt.wantPath || seen.URL.RawPath != tt.wantRawPath {
+			t.Errorf("%s: expected path %q (raw %q), got %q (raw %q)", tt.path, tt.wantPath, tt.wantRawPath, seen.URL.Path, seen.URL.RawPath)
+		}
+		if got := routedChain(seen.Context()); got != tt.chain {
+			t.Errorf("%s: expected chain %q, got %q", tt.path, tt.chain, got)
+		}
+	}
+}
+
+func TestChainRoute_FiltersUpstreams(t *testing.T) {
+	cosmos := createCosmosServer(t, 1000, false)
+	defer cosmos.Close()
+	evm := createEVMServer(t, 2000, false)
+	defer evm.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "cosmos", URL: cosmos.URL, Type: NodeTypeCosmos, Weight: 100},
+		{Name: "evm", URL: evm.URL, Type: NodeTypeEVM, Weight: 100},
+	}, zaptest.NewLogger(t))
+
+	request := func(chain string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r = r.WithContext(context.WithValue(r.Context(), caddyhttp.VarsCtxKey, map[string]any{}))
+		if chain != "" {
+			caddyhttp.SetVar(r.Context(), chainRouteVar, chain)
+		}
+		return r
+	}
+
+	upstreams, err := upstream.GetUpstreams(request("evm"))
+	if err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+	if len(upstreams) != 1 || upstreams[0].Dial != getDynamicTestHostFromURL(evm.URL) {
+		t.Errorf("expected only the EVM node, got %+v", upstreams)
+	}
+
+	upstreams, err = upstream.GetUpstreams(request(""))
+	if err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+	if len(upstreams) != 2 {
+		t.Errorf("expected an unrouted request to see both nodes, got %d", len(upstreams))
+	}
+
+	if _, err := upstream.GetUpstreams(request("beacon")); !errors.Is(err, ErrNoHealthyUpstreams) {
+		t.Errorf("expected a chain without nodes to fail, got %v", err)
+	}
+}
+
+func TestChainRoute_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_chain_route {
+		/cosmos cosmos
+		/evm/   evm
+	}`)
+	var h ChainRoute
+	if err := h.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if len(h.Routes) != 2 || h.Routes[1] != (ChainRoutePrefix{Prefix: "/evm/", Chain: "evm"}) {
+		t.Errorf("unexpected routes: %+v", h.Routes)
+	}
+
+	for name, input := range map[string]string{
+		"no routes":        `blockchain_chain_route`,
+		"missing chain":    "blockchain_chain_route {\n/evm\n}",
+		"root prefix":      "blockchain_chain_route {\n/ evm\n}",
+		"duplicate prefix": "blockchain_chain_route {\n/evm evm\n/evm/ cosmos\n}",
+	} {
+		if err := new(ChainRoute).UnmarshalCaddyfile(caddyfile.NewTestDispenser(input)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
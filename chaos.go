@@ -0,0 +1,208 @@
+package blockchain_health
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+)
+
+// ChaosFault is a fault injected into the health checks of a node. Faults
+// only change health check results, so routing fails over exactly as it
+// would for a real fault, and thresholds and alerts can be rehearsed.
+type ChaosFault struct {
+	// Latency is added to every check; checks slower than the health check
+	// timeout fail
+	Latency string `json:"latency,omitempty"`
+
+	// ErrorRate is the share of checks (0-1) that fail
+	ErrorRate float64 `json:"error_rate,omitempty"`
+
+	// HeightFreeze keeps reporting the block height the node had when the
+	// fault was set
+	HeightFreeze bool `json:"height_freeze,omitempty"`
+
+	latency      time.Duration
+	frozenHeight uint64
+}
+
+// validate checks a fault and parses its latency
+func (f *ChaosFault) validate() error {
+	if f.Latency != "" {
+		latency, err := time.ParseDuration(f.Latency)
+		if err != nil || latency < 0 {
+			return fmt.Errorf("invalid latency: %s", f.Latency)
+		}
+		f.latency = latency
+	}
+	if f.ErrorRate < 0 || f.ErrorRate > 1 {
+		return fmt.Errorf("error_rate must be between 0 and 1")
+	}
+	return nil
+}
+
+// chaosPool holds the faults injected into the nodes of the pools that
+// enabled chaos under one name
+type chaosPool struct {
+	name string
+
+	mutex  sync.Mutex
+	pools  map[*HealthChecker]struct{}
+	faults map[string]*ChaosFault // by node name
+}
+
+// Destruct implements caddy.Destructor
+func (c *chaosPool) Destruct() error {
+	return nil
+}
+
+// chaosPools holds the pools accepting faults by name
+var chaosPools = caddy.NewUsagePool()
+
+// registerChaosPool adds a pool to the chaos pool with the given name. Faults
+// set before a config reload are cleared by it.
+func registerChaosPool(name string, pool *HealthChecker) (*chaosPool, error) {
+	value, _, err := chaosPools.LoadOrNew(name, func() (caddy.Destructor, error) {
+		return &chaosPool{name: name, pools: make(map[*HealthChecker]struct{}), faults: make(map[string]*ChaosFault)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	chaos := value.(*chaosPool)
+	chaos.mutex.Lock()
+	chaos.faults = make(map[string]*ChaosFault)
+	chaos.pools[pool] = struct{}{}
+	chaos.mutex.Unlock()
+	return chaos, nil
+}
+
+// releaseChaosPool removes a pool from its chaos pool
+func releaseChaosPool(chaos *chaosPool, pool *HealthChecker) {
+	chaos.mutex.Lock()
+	delete(chaos.pools, pool)
+	chaos.mutex.Unlock()
+	_, _ = chaosPools.Delete(chaos.name)
+}
+
+// lookupChaosPool returns the chaos pool with the given name
+func lookupChaosPool(name string) (*chaosPool, bool) {
+	var found *chaosPool
+	chaosPools.Range(func(key, value any) bool {
+		if key == name {
+			found = value.(*chaosPool)
+			return false
+		}
+		return true
+	})
+	return found, found != nil
+}
+
+// hasNode reports whether a node of that name is in one of the pools
+func (c *chaosPool) hasNode(name string) bool {
+	for pool := range c.pools {
+		for _, node := range pool.config.nodeList() {
+			if node.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setFault injects a fault into a node
+func (c *chaosPool) setFault(node string, fault ChaosFault) error {
+	if err := fault.validate(); err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.hasNode(node) {
+		return fmt.Errorf("unknown node %q", node)
+	}
+	c.faults[node] = &fault
+	return nil
+}
+
+// clearFault removes the fault of a node, or of every node for ""
+func (c *chaosPool) clearFault(node string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if node == "" {
+		c.faults = make(map[string]*ChaosFault)
+		return
+	}
+	delete(c.faults, node)
+}
+
+// chaosPoolState is the admin API representation of a chaos pool
+type chaosPoolState struct {
+	Name   string                `json:"name"`
+	Nodes  []string              `json:"nodes"`
+	Faults map[string]ChaosFault `json:"faults"`
+}
+
+// snapshot returns the admin API representation of the pool
+func (c *chaosPool) snapshot() chaosPoolState {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	state := chaosPoolState{Name: c.name, Nodes: []string{}, Faults: make(map[string]ChaosFault, len(c.faults))}
+	for pool := range c.pools {
+		for _, node := range pool.config.nodeList() {
+			state.Nodes = append(state.Nodes, node.Name)
+		}
+	}
+	sort.Strings(state.Nodes)
+	for node, fault := range c.faults {
+		state.Faults[node] = *fault
+	}
+	return state
+}
+
+// inject applies the fault of the node, if any, to its health check result
+func (c *chaosPool) inject(ctx context.Context, node NodeConfig, health *NodeHealth, timeout time.Duration) {
+	c.mutex.Lock()
+	fault, ok := c.faults[node.Name]
+	if !ok {
+		c.mutex.Unlock()
+		return
+	}
+	if fault.HeightFreeze {
+		if fault.frozenHeight == 0 {
+			fault.frozenHeight = health.BlockHeight
+		}
+		health.BlockHeight = fault.frozenHeight
+	}
+	latency, errorRate := fault.latency, fault.ErrorRate
+	c.mutex.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(latency):
+		}
+		health.ResponseTime += latency
+		if timeout > 0 && health.ResponseTime > timeout {
+			health.Healthy = false
+			health.LastError = "chaos: health check timed out"
+			return
+		}
+	}
+	if errorRate > 0 && rand.Float64() < errorRate {
+		health.Healthy = false
+		health.LastError = "chaos: injected error"
+	}
+}
+
+// injectChaos applies the chaos fault of a node to its health check result,
+// if the pool enabled chaos
+func (h *HealthChecker) injectChaos(ctx context.Context, node NodeConfig, health *NodeHealth) {
+	if h.chaos == nil {
+		return
+	}
+	timeout, _ := time.ParseDuration(h.config.HealthCheck.Timeout)
+	h.chaos.inject(ctx, node, health, timeout)
+}
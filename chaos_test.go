@@ -0,0 +1,155 @@
+package blockchain_health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"go.uber.org/zap/zaptest"
+)
+
+// registerTestChaos returns a test checker of the nodes that accepts
+// faults under the given name
+func registerTestChaos(t *testing.T, name string, nodes []NodeConfig) *HealthChecker {
+	t.Helper()
+	checker := createTestUpstream(nodes, zaptest.NewLogger(t)).healthChecker
+	chaos, err := registerChaosPool(name, checker)
+	if err != nil {
+		t.Fatalf("registering chaos pool: %v", err)
+	}
+	t.Cleanup(func() { releaseChaosPool(chaos, checker) })
+	checker.chaos = chaos
+	return checker
+}
+
+func TestChaos_InjectsFaults(t *testing.T) {
+	nodes := []NodeConfig{{Name: "a", Type: NodeTypeEVM}, {Name: "b", Type: NodeTypeEVM}}
+	chaos := registerTestChaos(t, "chaos-inject", nodes).chaos
+	ctx := context.Background()
+
+	if err := chaos.setFault("a", ChaosFault{ErrorRate: 1}); err != nil {
+		t.Fatalf("setFault: %v", err)
+	}
+	health := &NodeHealth{Name: "a", Healthy: true}
+	chaos.inject(ctx, nodes[0], health, time.Second)
+	if health.Healthy || health.LastError != "chaos: injected error" {
+		t.Errorf("expected an injected error, got %+v", health)
+	}
+	health = &NodeHealth{Name: "b", Healthy: true}
+	chaos.inject(ctx, nodes[1], health, time.Second)
+	if !health.Healthy {
+		t.Error("expected node b to be left alone")
+	}
+
+	if err := chaos.setFault("a", ChaosFault{Latency: "30ms"}); err != nil {
+		t.Fatalf("setFault: %v", err)
+	}
+	health = &NodeHealth{Name: "a", Healthy: true, ResponseTime: 5 * time.Millisecond}
+	chaos.inject(ctx, nodes[0], health, time.Second)
+	if !health.Healthy || health.ResponseTime != 35*time.Millisecond {
+		t.Errorf("expected 30ms of added latency, got %+v", health)
+	}
+	health = &NodeHealth{Name: "a", Healthy: true}
+	chaos.inject(ctx, nodes[0], health, 20*time.Millisecond)
+	if health.Healthy || health.LastError != "chaos: health check timed out" {
+		t.Errorf("expected latency above the timeout to fail the check, got %+v", health)
+	}
+
+	if err := chaos.setFault("a", ChaosFault{HeightFreeze: true}); err != nil {
+		t.Fatalf("setFault: %v", err)
+	}
+	for _, height := range []uint64{100, 110, 120} {
+		health = &NodeHealth{Name: "a", Healthy: true, BlockHeight: height}
+		chaos.inject(ctx, nodes[0], health, time.Second)
+		if health.BlockHeight != 100 {
+			t.Errorf("expected the height to stay at 100, got %d", health.BlockHeight)
+		}
+	}
+
+	chaos.clearFault("")
+	health = &NodeHealth{Name: "a", Healthy: true, BlockHeight: 130}
+	chaos.inject(ctx, nodes[0], health, time.Second)
+	if health.BlockHeight != 130 {
+		t.Errorf("expected cleared faults to stop injecting, got %d", health.BlockHeight)
+	}
+}
+
+func TestChaos_FailsProbes(t *testing.T) {
+	server := createVersionedEVMServer(t, "Geth/v1.14.0")
+	defer server.Close()
+
+	nodes := []NodeConfig{{Name: "a", URL: server.URL, Type: NodeTypeEVM}}
+	checker := registerTestChaos(t, "chaos-probe", nodes)
+	if health := checker.probeNode(context.Background(), nodes[0]); !health.Healthy {
+		t.Fatalf("expected the node to be healthy, got %+v", health)
+	}
+
+	if err := checker.chaos.setFault("a", ChaosFault{ErrorRate: 1}); err != nil {
+		t.Fatalf("setFault: %v", err)
+	}
+	if health := checker.probeNode(context.Background(), nodes[0]); health.Healthy {
+		t.Fatal("expected the injected error to fail the probe")
+	}
+}
+
+func TestAdminAPI_Chaos(t *testing.T) {
+	registerTestChaos(t, "chaos-admin", []NodeConfig{{Name: "a"}, {Name: "b"}})
+
+	put := httptest.NewRequest(http.MethodPut, adminChaosPath+"chaos-admin/a", strings.NewReader(`{"latency":"2s","height_freeze":true}`))
+	rec := httptest.NewRecorder()
+	if err := (AdminAPI{}).handleChaos(rec, put); err != nil {
+		t.Fatalf("admin PUT failed: %v", err)
+	}
+	var state chaosPoolState
+	if err := json.Unmarshal(rec.Body.Bytes(), &state); err != nil {
+		t.Fatalf("invalid admin response %s: %v", rec.Body.String(), err)
+	}
+	if fault := state.Faults["a"]; fault.Latency != "2s" || !fault.HeightFreeze {
+		t.Errorf("expected the fault of node a, got %s", rec.Body.String())
+	}
+	if strings.Join(state.Nodes, ",") != "a,b" {
+		t.Errorf("expected nodes a,b, got %v", state.Nodes)
+	}
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPut, adminChaosPath+"chaos-admin/missing", strings.NewReader(`{"error_rate":1}`)),
+		httptest.NewRequest(http.MethodPut, adminChaosPath+"chaos-admin/a", strings.NewReader(`{"error_rate":2}`)),
+		httptest.NewRequest(http.MethodPut, adminChaosPath+"chaos-admin", strings.NewReader(`{"error_rate":1}`)),
+		httptest.NewRequest(http.MethodGet, adminChaosPath+"missing", nil),
+	} {
+		if err := (AdminAPI{}).handleChaos(httptest.NewRecorder(), req); err == nil {
+			t.Errorf("expected %s %s to be rejected", req.Method, req.URL.Path)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	if err := (AdminAPI{}).handleChaos(rec, httptest.NewRequest(http.MethodDelete, adminChaosPath+"chaos-admin/a", nil)); err != nil {
+		t.Fatalf("admin DELETE failed: %v", err)
+	}
+	var cleared chaosPoolState
+	if err := json.Unmarshal(rec.Body.Bytes(), &cleared); err != nil || len(cleared.Faults) != 0 {
+		t.Errorf("expected the fault to be cleared, got %s", rec.Body.String())
+	}
+}
+
+func TestChaos_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:8545
+			type evm
+		}
+		chaos staging-evm
+	}`)
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !b.Chaos.Enabled || b.Chaos.Name != "staging-evm" {
+		t.Fatalf("unexpected chaos settings: %+v", b.Chaos)
+	}
+}
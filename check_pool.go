@@ -0,0 +1,144 @@
+package blockchain_health
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// nodeCheckTimeout bounds a single node check, retries included
+const nodeCheckTimeout = 30 * time.Second
+
+// nodeCheck is a node check queued on or run by the worker pool. done is
+// closed once health is set.
+type nodeCheck struct {
+	node    NodeConfig
+	refresh bool // probe the node even if a cached result is available
+	health  *NodeHealth
+	done    chan struct{}
+	stopped context.Context // the health checker's context
+}
+
+// wait returns the result of the check, or an unhealthy result if ctx ends
+// or the health checker stops first, as a check still queued then is never
+// run. A finished check always wins over an ended ctx.
+func (c *nodeCheck) wait(ctx context.Context) *NodeHealth {
+	select {
+	case <-c.done:
+		return c.health
+	default:
+	}
+
+	select {
+	case <-c.done:
+		return c.health
+	case <-ctx.Done():
+		return c.canceled(ctx)
+	case <-c.stopped.Done():
+		return c.canceled(c.stopped)
+	}
+}
+
+// canceled returns the unhealthy result of a check abandoned as ctx ended
+func (c *nodeCheck) canceled(ctx context.Context) *NodeHealth {
+	return &NodeHealth{
+		Name:      c.node.Name,
+		URL:       c.node.URL,
+		Healthy:   false,
+		LastError: ctx.Err().Error(),
+	}
+}
+
+// startCheck returns the check in flight for the node, queuing a new one if
+// there is none. Runs that overlap, e.g. a request-time check during a slow
+// background check, share the check instead of probing the node twice.
+func (h *HealthChecker) startCheck(node NodeConfig, refresh bool) *nodeCheck {
+	h.startWorkers.Do(h.runWorkers)
+
+	h.inflightMutex.Lock()
+	if check, ok := h.inflight[node.key()]; ok {
+		h.inflightMutex.Unlock()
+		return check
+	}
+	check := &nodeCheck{node: node, refresh: refresh, done: make(chan struct{}), stopped: h.ctx}
+	h.inflight[node.key()] = check
+	h.inflightMutex.Unlock()
+
+	select {
+	case h.jobs <- check:
+	case <-h.ctx.Done():
+		h.finishCheck(check, &NodeHealth{
+			Name:      node.Name,
+			URL:       node.URL,
+			Healthy:   false,
+			LastError: h.ctx.Err().Error(),
+		})
+	}
+	return check
+}
+
+// finishCheck publishes the result of a check and clears it from in flight
+func (h *HealthChecker) finishCheck(check *nodeCheck, health *NodeHealth) {
+	h.inflightMutex.Lock()
+	delete(h.inflight, check.node.key())
+	h.inflightMutex.Unlock()
+
+	check.health = health
+	close(check.done)
+}
+
+// runWorkers starts max_concurrent_checks workers that run queued checks
+// until the health checker is stopped
+func (h *HealthChecker) runWorkers() {
+	workers := h.config.Performance.MaxConcurrentChecks
+	if workers <= 0 {
+		workers = 10
+	}
+	h.jobs = make(chan *nodeCheck, max(len(h.config.nodeList()), workers))
+
+	for i := 0; i < workers; i++ {
+		h.goBackground(func() {
+			for {
+				select {
+				case check := <-h.jobs:
+					h.runCheck(check)
+				case <-h.ctx.Done():
+					return
+				}
+			}
+		})
+	}
+}
+
+// runCheck runs one queued check. The check is bound to the health checker,
+// not to the callers waiting on it, so one caller giving up doesn't fail the
+// check for the others.
+func (h *HealthChecker) runCheck(check *nodeCheck) {
+	ctx, cancel := context.WithTimeout(h.ctx, nodeCheckTimeout)
+	defer cancel()
+
+	h.logger.Debug("checking node health",
+		zap.String("node", check.node.Name),
+		zap.String("url", check.node.URL),
+		zap.String("type", string(check.node.Type)))
+
+	health := h.checkNode(ctx, check)
+
+	h.logger.Debug("node health check completed",
+		zap.String("node", check.node.Name),
+		zap.Bool("healthy", health.Healthy),
+		zap.String("error", health.LastError))
+
+	h.finishCheck(check, health)
+}
+
+// checkNode probes the node or reads its cached result. A panic is recovered
+// into an unknown result, so the check's waiters are still released.
+func (h *HealthChecker) checkNode(ctx context.Context, check *nodeCheck) (health *NodeHealth) {
+	defer h.recoverCheckPanic(check.node, &health)
+	if check.refresh {
+		return h.probeNode(ctx, check.node)
+	}
+	return h.checkSingleNode(ctx, check.node)
+}
@@ -0,0 +1,139 @@
+package blockchain_health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestCheckAllNodes_OverlappingRunsShareChecks(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	var probes atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"result":{"sync_info":{"latest_block_height":"1000","catching_up":false}}}`))
+	}))
+	defer server.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "slow", URL: server.URL, Type: NodeTypeCosmos, Weight: 1},
+	}, logger)
+	defer upstream.healthChecker.Stop()
+
+	var wg sync.WaitGroup
+	results := make([][]*NodeHealth, 3)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = upstream.healthChecker.CheckAllNodes(context.Background())
+		}(i)
+	}
+
+	// Let every run join the check before the node answers
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := probes.Load(); n != 1 {
+		t.Errorf("expected overlapping runs to probe the node once, got %d probes", n)
+	}
+	for i, result := range results {
+		if len(result) != 1 || !result[0].Healthy {
+			t.Errorf("run %d: expected a healthy result, got %+v", i, result)
+		}
+	}
+}
+
+func TestCheckAllNodes_StopCancelsChecks(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "hanging", URL: server.URL, Type: NodeTypeCosmos, Weight: 1},
+	}, logger)
+
+	done := make(chan []*NodeHealth)
+	go func() {
+		results, _ := upstream.healthChecker.CheckAllNodes(upstream.healthChecker.ctx)
+		done <- results
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	upstream.healthChecker.Stop()
+
+	select {
+	case results := <-done:
+		if len(results) != 1 || results[0].Healthy {
+			t.Errorf("expected an unhealthy result after stop, got %+v", results)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected stop to cancel the run in progress")
+	}
+
+	// Checks started after stop fail straight away
+	results, _ := upstream.healthChecker.CheckAllNodes(context.Background())
+	if len(results) != 1 || results[0].Healthy {
+		t.Errorf("expected an unhealthy result after stop, got %+v", results)
+	}
+}
+
+func TestScheduleNodes_SlowNodeDoesNotDelayFastNodes(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	var slowProbes, fastProbes atomic.Int32
+	release := make(chan struct{})
+	defer close(release)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slowProbes.Add(1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fastProbes.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"result":{"sync_info":{"latest_block_height":"1000","catching_up":false}}}`))
+	}))
+	defer fast.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "slow", URL: slow.URL, Type: NodeTypeCosmos, Weight: 1},
+		{Name: "fast", URL: fast.URL, Type: NodeTypeCosmos, Weight: 1},
+	}, logger)
+	upstream.healthChecker.scheduleNodes(50 * time.Millisecond)
+	time.Sleep(400 * time.Millisecond)
+	upstream.healthChecker.Stop()
+
+	if n := fastProbes.Load(); n < 4 {
+		t.Errorf("expected the fast node to be probed every interval, got %d probes", n)
+	}
+	if health := upstream.healthChecker.cache.Get(upstream.healthChecker.nodeKey("fast")); health == nil || !health.Healthy {
+		t.Errorf("expected a fresh healthy result for the fast node, got %+v", health)
+	}
+	if n := slowProbes.Load(); n != 1 {
+		t.Errorf("expected the slow node to be probed once while its check hangs, got %d probes", n)
+	}
+
+	var m dto.Metric
+	if err := upstream.healthChecker.metrics.checksSkipped.Write(&m); err != nil {
+		t.Fatalf("failed to read metric: %v", err)
+	}
+	if m.GetCounter().GetValue() < 1 {
+		t.Error("expected skipped checks of the slow node to be counted")
+	}
+}
@@ -12,6 +12,17 @@ func NewCircuitBreaker(failureThreshold int) *CircuitBreaker {
 	}
 }
 
+// NewErrorRateCircuitBreaker creates a circuit breaker that opens when the
+// error rate reported through RecordErrorRate reaches failureRatio over at
+// least minSamples outcomes, rather than after consecutive failures
+func NewErrorRateCircuitBreaker(failureRatio float64, minSamples int) *CircuitBreaker {
+	return &CircuitBreaker{
+		failureRatio: failureRatio,
+		minSamples:   minSamples,
+		state:        CircuitClosed,
+	}
+}
+
 // CanExecute returns true if the circuit breaker allows execution
 func (cb *CircuitBreaker) CanExecute() bool {
 	cb.mutex.RLock()
@@ -67,7 +78,8 @@ func (cb *CircuitBreaker) RecordFailure() {
 
 	switch cb.state {
 	case CircuitClosed:
-		if cb.failureCount >= cb.failureThreshold {
+		// Error rate breakers open from RecordErrorRate instead
+		if cb.failureRatio <= 0 && cb.failureCount >= cb.failureThreshold {
 			cb.state = CircuitOpen
 		}
 	case CircuitHalfOpen:
@@ -76,6 +88,21 @@ func (cb *CircuitBreaker) RecordFailure() {
 	}
 }
 
+// RecordErrorRate opens a closed error rate breaker when the windowed error
+// rate reaches the failure ratio over enough samples
+func (cb *CircuitBreaker) RecordErrorRate(rate float64, samples int) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	if cb.failureRatio <= 0 || cb.state != CircuitClosed {
+		return
+	}
+	if samples >= cb.minSamples && rate >= cb.failureRatio {
+		cb.state = CircuitOpen
+		cb.lastFailureTime = time.Now()
+	}
+}
+
 // GetState returns the current state of the circuit breaker
 func (cb *CircuitBreaker) GetState() CircuitState {
 	cb.mutex.RLock()
@@ -89,3 +116,14 @@ func (cb *CircuitBreaker) GetFailureCount() int {
 	defer cb.mutex.RUnlock()
 	return cb.failureCount
 }
+
+// String returns the state as reported by the health endpoint
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half_open"
+	}
+	return "closed"
+}
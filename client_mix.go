@@ -0,0 +1,160 @@
+package blockchain_health
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ExecutionClient is an execution client implementation
+type ExecutionClient string
+
+// Known execution clients
+const (
+	ExecutionClientGeth       ExecutionClient = "geth"
+	ExecutionClientNethermind ExecutionClient = "nethermind"
+	ExecutionClientErigon     ExecutionClient = "erigon"
+	ExecutionClientReth       ExecutionClient = "reth"
+	ExecutionClientBesu       ExecutionClient = "besu"
+	ExecutionClientUnknown    ExecutionClient = "unknown"
+)
+
+// isValidExecutionClient reports whether s names a known execution client
+func isValidExecutionClient(s string) bool {
+	switch ExecutionClient(s) {
+	case ExecutionClientGeth, ExecutionClientNethermind, ExecutionClientErigon, ExecutionClientReth, ExecutionClientBesu:
+		return true
+	}
+	return false
+}
+
+// parseExecutionClient returns the client from a web3_clientVersion string
+// such as "Geth/v1.13.14-stable-2bd6bd01/linux-amd64/go1.21.7"
+func parseExecutionClient(version string) ExecutionClient {
+	name, _, _ := strings.Cut(version, "/")
+	name = strings.ToLower(strings.TrimSpace(name))
+	if isValidExecutionClient(name) {
+		return ExecutionClient(name)
+	}
+	return ExecutionClientUnknown
+}
+
+// clientFor returns the execution client of a node, honoring a per-node
+// "execution_client" metadata override. Detected clients are remembered per
+// URL.
+func (e *EVMHandler) clientFor(ctx context.Context, node NodeConfig, url string) ExecutionClient {
+	if client := node.Metadata["execution_client"]; client != "" {
+		return ExecutionClient(client)
+	}
+
+	e.clientsMutex.Lock()
+	entry, ok := e.clients[url]
+	e.clientsMutex.Unlock()
+	if ok && (entry.expires.IsZero() || time.Now().Before(entry.expires)) {
+		return ExecutionClient(entry.client)
+	}
+
+	client, err := e.getClient(ctx, url)
+	entry = clientEntry{client: string(client)}
+	if err != nil {
+		e.logger.Debug("execution client detection failed", zap.String("node", node.Name), zap.Error(err))
+		entry = clientEntry{client: string(ExecutionClientUnknown), expires: time.Now().Add(clientDetectionRetry)}
+	}
+
+	e.clientsMutex.Lock()
+	e.clients[url] = entry
+	e.clientsMutex.Unlock()
+	return ExecutionClient(entry.client)
+}
+
+// getClient queries web3_clientVersion for the node's client
+func (e *EVMHandler) getClient(ctx context.Context, url string) (ExecutionClient, error) {
+	rpcResp, err := e.callJSONRPC(ctx, url, "web3_clientVersion", []interface{}{})
+	if err != nil {
+		return ExecutionClientUnknown, err
+	}
+	version, ok := rpcResp.Result.(string)
+	if !ok {
+		return ExecutionClientUnknown, fmt.Errorf("invalid web3_clientVersion response type")
+	}
+	return parseExecutionClient(version), nil
+}
+
+// poolName returns the pool label of the health checker's metrics
+func (h *HealthChecker) poolName() string {
+	if h.state != nil {
+		return h.state.name
+	}
+	return "default"
+}
+
+// updateClientMix counts the pool's nodes per detected client. Candidates
+// and nodes whose client is not known yet are left out. With
+// client_diversity_warning set, a warning is logged when every node turns
+// out to run the same client.
+func (h *HealthChecker) updateClientMix(results []*NodeHealth) {
+	counts := make(map[string]int)
+	total, unknown := 0, 0
+	for _, health := range results {
+		if health == nil || health.Client == "" || h.isCandidate(health.Name) {
+			continue
+		}
+		counts[health.Client]++
+		total++
+		if health.Client == string(ExecutionClientUnknown) {
+			unknown++
+		}
+	}
+	if total == 0 {
+		return
+	}
+
+	h.mutex.Lock()
+	previous := h.clientMix
+	h.clientMix = counts
+	wasSingle := h.singleClient
+	single := ""
+	if total >= 2 && unknown == 0 && len(counts) == 1 {
+		for client := range counts {
+			single = client
+		}
+	}
+	h.singleClient = single
+	h.mutex.Unlock()
+
+	if h.metrics != nil {
+		pool := h.poolName()
+		for client := range previous {
+			if _, ok := counts[client]; !ok {
+				h.metrics.poolClients.WithLabelValues(pool, client).Set(0)
+			}
+		}
+		largest := 0
+		for client, count := range counts {
+			h.metrics.poolClients.WithLabelValues(pool, client).Set(float64(count))
+			if client != string(ExecutionClientUnknown) && count > largest {
+				largest = count
+			}
+		}
+		if known := total - unknown; known > 0 {
+			h.metrics.poolClientDominance.WithLabelValues(pool).Set(float64(largest) / float64(known))
+		}
+	}
+
+	if !h.config.Monitoring.ClientDiversityWarning || single == wasSingle {
+		return
+	}
+	if single != "" {
+		h.logger.Warn("every node of the pool runs the same client; a client bug would take the whole pool down",
+			zap.String("pool", h.poolName()),
+			zap.String("client", single),
+			zap.Int("nodes", total))
+	} else if wasSingle != "" {
+		h.logger.Info("pool runs more than one client again",
+			zap.String("pool", h.poolName()),
+			zap.Int("clients", len(counts)))
+	}
+}
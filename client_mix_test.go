@@ -0,0 +1,158 @@
+package blockchain_health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// createVersionedEVMServer serves an EVM node reporting the given
+// web3_clientVersion
+func createVersionedEVMServer(t *testing.T, version string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		body := make([]byte, r.ContentLength)
+		_, _ = r.Body.Read(body)
+		if strings.Contains(string(body), "web3_clientVersion") {
+			_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":%q}`, version)
+			return
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x3e8"}`))
+	}))
+}
+
+// poolClientGauges reads the client mix gauges of a pool
+func poolClientGauges(t *testing.T, metrics *Metrics, pool, client string) (float64, float64) {
+	t.Helper()
+	var count, dominance dto.Metric
+	if err := metrics.poolClients.WithLabelValues(pool, client).Write(&count); err != nil {
+		t.Fatalf("reading pool_clients: %v", err)
+	}
+	if err := metrics.poolClientDominance.WithLabelValues(pool).Write(&dominance); err != nil {
+		t.Fatalf("reading pool_client_dominance: %v", err)
+	}
+	return count.GetGauge().GetValue(), dominance.GetGauge().GetValue()
+}
+
+func TestParseExecutionClient(t *testing.T) {
+	tests := map[string]ExecutionClient{
+		"Geth/v1.13.14-stable-2bd6bd01/linux-amd64/go1.21.7":   ExecutionClientGeth,
+		"Nethermind/v1.25.4+20b10b35/linux-x64/dotnet8.0.2":    ExecutionClientNethermind,
+		"erigon/2.58.1/linux-amd64/go1.21.5":                   ExecutionClientErigon,
+		"reth/v0.2.0-beta.2-9ee5ee2f/x86_64-unknown-linux-gnu": ExecutionClientReth,
+		"besu/v24.1.2/linux-x86_64/openjdk-java-17":            ExecutionClientBesu,
+		"bor/v1.2.3": ExecutionClientUnknown,
+		"":           ExecutionClientUnknown,
+	}
+	for version, want := range tests {
+		if got := parseExecutionClient(version); got != want {
+			t.Errorf("%q: expected %s, got %s", version, want, got)
+		}
+	}
+}
+
+func TestEVMHandler_DetectsClient(t *testing.T) {
+	server := createVersionedEVMServer(t, "Nethermind/v1.25.4")
+	defer server.Close()
+
+	handler := NewEVMHandler(5*time.Second, zaptest.NewLogger(t))
+	health, err := handler.CheckHealth(context.Background(), NodeConfig{Name: "n", URL: server.URL, Type: NodeTypeEVM})
+	if err != nil {
+		t.Fatalf("CheckHealth failed: %v", err)
+	}
+	if health.Client != string(ExecutionClientNethermind) {
+		t.Errorf("expected nethermind, got %q", health.Client)
+	}
+
+	// The metadata override skips detection
+	health, _ = handler.CheckHealth(context.Background(), NodeConfig{Name: "r", URL: server.URL, Type: NodeTypeEVM, Metadata: map[string]string{"execution_client": "reth"}})
+	if health.Client != string(ExecutionClientReth) {
+		t.Errorf("expected the configured client, got %q", health.Client)
+	}
+}
+
+func TestUpdateClientMix(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	upstream := createTestUpstream(nil, zap.New(core))
+	checker := upstream.healthChecker
+	checker.config.Monitoring.ClientDiversityWarning = true
+
+	checker.updateClientMix([]*NodeHealth{
+		{Name: "a", Client: "geth"},
+		{Name: "b", Client: "geth"},
+		{Name: "c", Client: "nethermind"},
+	})
+	if count, dominance := poolClientGauges(t, checker.metrics, "default", "geth"); count != 2 || dominance < 0.66 || dominance > 0.67 {
+		t.Errorf("expected 2 geth nodes and a dominance of 2/3, got %v and %v", count, dominance)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("expected no warning for a mixed pool, got %d", logs.Len())
+	}
+
+	// The pool loses its only nethermind node
+	checker.updateClientMix([]*NodeHealth{
+		{Name: "a", Client: "geth"},
+		{Name: "b", Client: "geth"},
+		{Name: "c"},
+	})
+	if count, dominance := poolClientGauges(t, checker.metrics, "default", "nethermind"); count != 0 || dominance != 1 {
+		t.Errorf("expected no nethermind node and a single client, got %v and %v", count, dominance)
+	}
+	if logs.FilterMessageSnippet("same client").Len() != 1 {
+		t.Errorf("expected a single-client warning, got %v", logs.All())
+	}
+
+	// The warning is only logged on the transition
+	checker.updateClientMix([]*NodeHealth{{Name: "a", Client: "geth"}, {Name: "b", Client: "geth"}})
+	if logs.Len() != 1 {
+		t.Errorf("expected the warning once, got %d", logs.Len())
+	}
+
+	// Unknown clients may differ, so they never count as a single client
+	checker.singleClient = ""
+	checker.updateClientMix([]*NodeHealth{{Name: "a", Client: "geth"}, {Name: "b", Client: "unknown"}})
+	if logs.Len() != 1 {
+		t.Errorf("expected no warning with an unknown client, got %d", logs.Len())
+	}
+}
+
+func TestClientDiversityWarning_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:8545
+			type evm
+			metadata {
+				execution_client geth
+			}
+		}
+		client_diversity_warning
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if !b.Monitoring.ClientDiversityWarning {
+		t.Error("expected client_diversity_warning to be enabled")
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+
+	b.Nodes[0].Metadata["execution_client"] = "openethereum"
+	if err := b.validate(); err == nil {
+		t.Error("expected an unknown execution client to be rejected")
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
 )
@@ -13,6 +15,17 @@ import (
 // parseCaddyfile parses the Caddyfile configuration
 func (b *BlockchainHealthUpstream) parseCaddyfile(d *caddyfile.Dispenser) error {
 	for d.Next() {
+		// A pool=<name> argument routes to a pool of the blockchain_health app
+		for d.NextArg() {
+			name, ok := strings.CutPrefix(d.Val(), "pool=")
+			if !ok || name == "" {
+				return d.Errf("unrecognized argument: %s (expected pool=<name>)", d.Val())
+			}
+			b.Pool = name
+		}
+		if b.Pool != "" && d.NextBlock(0) {
+			return d.Errf("pool=%s takes no block; configure the pool in the blockchain_health global option", b.Pool)
+		}
 		for d.NextBlock(0) {
 			switch d.Val() {
 			case "node":
@@ -22,6 +35,13 @@ func (b *BlockchainHealthUpstream) parseCaddyfile(d *caddyfile.Dispenser) error
 				}
 				b.Nodes = append(b.Nodes, node)
 
+			case "host":
+				args := d.RemainingArgs()
+				if len(args) != 2 {
+					return d.ArgErr()
+				}
+				b.Hosts = append(b.Hosts, HostPool{Host: args[0], Pool: args[1]})
+
 			case "external_reference":
 				ref, err := b.parseExternalReference(d)
 				if err != nil {
@@ -57,16 +77,45 @@ func (b *BlockchainHealthUpstream) parseCaddyfile(d *caddyfile.Dispenser) error
 				}
 				b.HealthCheck.RetryDelay = d.Val()
 
-			case "block_height_threshold":
+			case "probe_mode":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				if !isValidCosmosProbeMode(d.Val()) {
+					return d.Errf("invalid probe_mode: %s (must be status, health, or abci_info)", d.Val())
+				}
+				b.HealthCheck.ProbeMode = d.Val()
+
+			case "user_agent":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				b.HealthCheck.UserAgent = d.Val()
+
+			case "block_height_threshold", "height_threshold":
 				if !d.NextArg() {
 					return d.ArgErr()
 				}
+				// A duration such as 30s is converted to blocks with the block time
+				if staleness, err := time.ParseDuration(d.Val()); err == nil {
+					if staleness <= 0 {
+						return d.Errf("invalid block_height_threshold: %s", d.Val())
+					}
+					b.BlockValidation.HeightThresholdTime = d.Val()
+					break
+				}
 				threshold, err := strconv.Atoi(d.Val())
 				if err != nil {
 					return d.Errf("invalid block_height_threshold: %v", err)
 				}
 				b.BlockValidation.HeightThreshold = threshold
 
+			case "block_time":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				b.BlockValidation.BlockTime = d.Val()
+
 			case "external_reference_threshold":
 				if !d.NextArg() {
 					return d.ArgErr()
@@ -77,6 +126,102 @@ func (b *BlockchainHealthUpstream) parseCaddyfile(d *caddyfile.Dispenser) error
 				}
 				b.BlockValidation.ExternalReferenceThreshold = threshold
 
+			case "external_lag_action":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				b.BlockValidation.ExternalLagAction = d.Val()
+				if d.NextArg() {
+					factor, err := strconv.ParseFloat(d.Val(), 64)
+					if err != nil {
+						return d.Errf("invalid external_lag_action weight factor: %v", err)
+					}
+					b.BlockValidation.ExternalLagWeightFactor = factor
+				}
+
+			case "reference_max_ahead":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				blocks, err := strconv.Atoi(d.Val())
+				if err != nil {
+					return d.Errf("invalid reference_max_ahead: %v", err)
+				}
+				b.BlockValidation.ReferenceMaxAhead = blocks
+
+			case "track_earliest_block":
+				track := true
+				if d.NextArg() {
+					value, err := strconv.ParseBool(d.Val())
+					if err != nil {
+						return d.Errf("invalid track_earliest_block: %v", err)
+					}
+					track = value
+				}
+				b.BlockValidation.TrackEarliestBlock = track
+
+			case "track_logs_range":
+				track := true
+				if d.NextArg() {
+					value, err := strconv.ParseBool(d.Val())
+					if err != nil {
+						return d.Errf("invalid track_logs_range: %v", err)
+					}
+					track = value
+				}
+				b.BlockValidation.TrackLogsRange = track
+
+			case "track_finality":
+				track := true
+				if d.NextArg() {
+					value, err := strconv.ParseBool(d.Val())
+					if err != nil {
+						return d.Errf("invalid track_finality: %v", err)
+					}
+					track = value
+				}
+				b.BlockValidation.TrackFinality = track
+
+			case "finalized_threshold":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				threshold, err := strconv.Atoi(d.Val())
+				if err != nil {
+					return d.Errf("invalid finalized_threshold: %v", err)
+				}
+				b.BlockValidation.FinalizedThreshold = threshold
+
+			case "strict_leader_only":
+				b.BlockValidation.StrictLeaderOnly = true
+				if d.NextArg() {
+					threshold, err := strconv.Atoi(d.Val())
+					if err != nil {
+						return d.Errf("invalid strict_leader_only: %v", err)
+					}
+					b.BlockValidation.StrictLeaderThreshold = threshold
+				}
+
+			case "finality_epoch_threshold":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				threshold, err := strconv.Atoi(d.Val())
+				if err != nil {
+					return d.Errf("invalid finality_epoch_threshold: %v", err)
+				}
+				b.BlockValidation.FinalityEpochThreshold = threshold
+
+			case "halt_multiple":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				multiple, err := strconv.Atoi(d.Val())
+				if err != nil {
+					return d.Errf("invalid halt_multiple: %v", err)
+				}
+				b.BlockValidation.HaltMultiple = multiple
+
 			case "cache_duration":
 				if !d.NextArg() {
 					return d.ArgErr()
@@ -119,6 +264,181 @@ func (b *BlockchainHealthUpstream) parseCaddyfile(d *caddyfile.Dispenser) error
 				}
 				b.FailureHandling.CircuitBreakerThreshold = threshold
 
+			case "error_rate_window":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				b.FailureHandling.ErrorRateWindow = d.Val()
+
+			case "error_rate_min_samples":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				samples, err := strconv.Atoi(d.Val())
+				if err != nil {
+					return d.Errf("invalid error_rate_min_samples: %v", err)
+				}
+				b.FailureHandling.ErrorRateMinSamples = samples
+
+			case "throttle_backoff":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				b.FailureHandling.ThrottleBackoff = d.Val()
+
+			case "throttle_weight_factor":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				factor, err := strconv.ParseFloat(d.Val(), 64)
+				if err != nil {
+					return d.Errf("invalid throttle_weight_factor: %v", err)
+				}
+				b.FailureHandling.ThrottleWeightFactor = factor
+
+			case "fallback_strategy":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				b.FailureHandling.FallbackStrategy = d.Val()
+
+			case "max_wait":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				b.FailureHandling.MaxWait = d.Val()
+
+			case "enforce":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				enforce, err := strconv.ParseBool(d.Val())
+				if err != nil {
+					return d.Errf("invalid enforce: %v", err)
+				}
+				b.FailureHandling.Enforce = &enforce
+
+			case "scoring":
+				if err := b.parseScoring(d); err != nil {
+					return err
+				}
+
+			case "traffic_split":
+				if err := b.parseTrafficSplit(d); err != nil {
+					return err
+				}
+
+			case "cost":
+				if err := b.parseCost(d); err != nil {
+					return err
+				}
+
+			case "docker_discovery":
+				if err := b.parseDockerDiscovery(d); err != nil {
+					return err
+				}
+
+			case "inventory":
+				if err := b.parseInventory(d); err != nil {
+					return err
+				}
+
+			case "pool_state":
+				if err := b.parsePoolState(d); err != nil {
+					return err
+				}
+
+			case "dns_failover":
+				if err := b.parseDNSFailover(d); err != nil {
+					return err
+				}
+
+			case "status_page":
+				if err := b.parseStatusPage(d); err != nil {
+					return err
+				}
+
+			case "anycast_signal":
+				if err := b.parseAnycastSignal(d); err != nil {
+					return err
+				}
+
+			case "report":
+				if err := b.parseReport(d); err != nil {
+					return err
+				}
+
+			case "request_signing":
+				if err := b.parseRequestSigning(d); err != nil {
+					return err
+				}
+
+			case "chaos":
+				// chaos [<name>]
+				b.Chaos.Enabled = true
+				if d.NextArg() {
+					b.Chaos.Name = d.Val()
+				}
+				if d.NextArg() {
+					return d.ArgErr()
+				}
+
+			case "mempool_divergence":
+				if err := b.parseMempoolDivergence(d); err != nil {
+					return err
+				}
+
+			case "slo":
+				if err := b.parseSLO(d); err != nil {
+					return err
+				}
+
+			case "validator_mode":
+				if err := b.parseValidatorMode(d); err != nil {
+					return err
+				}
+
+			case "tiering":
+				if err := b.parseTiering(d); err != nil {
+					return err
+				}
+
+			case "flap_quarantine":
+				if err := b.parseFlapQuarantine(d); err != nil {
+					return err
+				}
+
+			case "account_affinity":
+				// Syntax: account_affinity [<window>]
+				b.AccountAffinity.Enabled = true
+				if d.NextArg() {
+					b.AccountAffinity.Window = d.Val()
+				}
+				if d.NextArg() {
+					return d.ArgErr()
+				}
+
+			case "prewarm":
+				if err := b.parsePrewarm(d); err != nil {
+					return err
+				}
+
+			case "strict_validation":
+				if err := b.parseStrictValidation(d); err != nil {
+					return err
+				}
+
+			case "client_diversity_warning":
+				warn := true
+				if d.NextArg() {
+					value, err := strconv.ParseBool(d.Val())
+					if err != nil {
+						return d.Errf("invalid client_diversity_warning: %v", err)
+					}
+					warn = value
+				}
+				b.Monitoring.ClientDiversityWarning = warn
+
 			case "metrics_enabled":
 				if !d.NextArg() {
 					return d.ArgErr()
@@ -130,10 +450,22 @@ func (b *BlockchainHealthUpstream) parseCaddyfile(d *caddyfile.Dispenser) error
 				b.Monitoring.MetricsEnabled = enabled
 
 			case "log_level":
-				if !d.NextArg() {
+				// Syntax: log_level <level> or log_level <component> <level>
+				args := d.RemainingArgs()
+				switch len(args) {
+				case 1:
+					b.Monitoring.LogLevel = args[0]
+				case 2:
+					if !isValidLogComponent(args[0]) {
+						return d.Errf("invalid log_level component: %s (must be 'upstream', 'checker', or 'handlers')", args[0])
+					}
+					if b.Monitoring.ComponentLogLevels == nil {
+						b.Monitoring.ComponentLogLevels = make(map[string]string)
+					}
+					b.Monitoring.ComponentLogLevels[args[0]] = args[1]
+				default:
 					return d.ArgErr()
 				}
-				b.Monitoring.LogLevel = d.Val()
 
 			case "health_endpoint":
 				if !d.NextArg() {
@@ -141,6 +473,18 @@ func (b *BlockchainHealthUpstream) parseCaddyfile(d *caddyfile.Dispenser) error
 				}
 				b.Monitoring.HealthEndpoint = d.Val()
 
+			case "shutdown_drain":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				b.Monitoring.ShutdownDrain = d.Val()
+
+			case "health_cache_ttl":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				b.Monitoring.HealthCacheTTL = d.Val()
+
 			// Environment-based configuration
 			case "servers":
 				servers := []string{}
@@ -191,12 +535,18 @@ func (b *BlockchainHealthUpstream) parseCaddyfile(d *caddyfile.Dispenser) error
 				}
 				b.Chain.ChainType = d.Val()
 
+			case "network":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				b.Chain.Network = d.Val()
+
 			case "node_type":
 				if !d.NextArg() {
 					return d.ArgErr()
 				}
 				nodeType := d.Val()
-				if nodeType != "cosmos" && nodeType != "evm" && nodeType != "beacon" {
+				if _, _, ok := parseNodeType(nodeType); !ok {
 					return d.Errf("invalid node_type: %s (must be 'cosmos', 'evm', or 'beacon')", nodeType)
 				}
 				b.Chain.NodeType = nodeType
@@ -217,7 +567,7 @@ func (b *BlockchainHealthUpstream) parseCaddyfile(d *caddyfile.Dispenser) error
 				if !d.NextArg() {
 					return d.ArgErr()
 				}
-				b.Chain.ServiceType = d.Val()
+				b.Chain.ServiceType = ServiceType(d.Val())
 
 			// Legacy configuration
 			case "legacy_mode":
@@ -266,7 +616,7 @@ func (b *BlockchainHealthUpstream) parseNode(d *caddyfile.Dispenser) (NodeConfig
 		return node, d.ArgErr()
 	}
 	node.Name = d.Val()
-	node.Weight = 100 // default weight
+	node.Weight = defaultNodeWeight
 
 	// Parse the node block
 	for d.NextBlock(1) {
@@ -277,6 +627,13 @@ func (b *BlockchainHealthUpstream) parseNode(d *caddyfile.Dispenser) (NodeConfig
 			}
 			node.URL = d.Val()
 
+		case "alternate_url":
+			args := d.RemainingArgs()
+			if len(args) == 0 {
+				return node, d.ArgErr()
+			}
+			node.AlternateURLs = append(node.AlternateURLs, args...)
+
 		case "api_url":
 			if !d.NextArg() {
 				return node, d.ArgErr()
@@ -294,7 +651,7 @@ func (b *BlockchainHealthUpstream) parseNode(d *caddyfile.Dispenser) (NodeConfig
 				return node, d.ArgErr()
 			}
 			nodeType := d.Val()
-			if nodeType != "cosmos" && nodeType != "evm" && nodeType != "beacon" {
+			if _, _, ok := parseNodeType(nodeType); !ok {
 				return node, d.Errf("invalid node type: %s (must be 'cosmos', 'evm', or 'beacon')", nodeType)
 			}
 			node.Type = NodeType(nodeType)
@@ -318,6 +675,17 @@ func (b *BlockchainHealthUpstream) parseNode(d *caddyfile.Dispenser) (NodeConfig
 			}
 			node.Weight = weight
 
+		case "header":
+			// header <name> <value>
+			args := d.RemainingArgs()
+			if len(args) != 2 {
+				return node, d.ArgErr()
+			}
+			if node.Headers == nil {
+				node.Headers = make(map[string]string)
+			}
+			node.Headers[args[0]] = args[1]
+
 		case "metadata":
 			if node.Metadata == nil {
 				node.Metadata = make(map[string]string)
@@ -349,6 +717,110 @@ func (b *BlockchainHealthUpstream) parseNode(d *caddyfile.Dispenser) (NodeConfig
 	return node, nil
 }
 
+// parseScoring parses a scoring block from the Caddyfile
+func (b *BlockchainHealthUpstream) parseScoring(d *caddyfile.Dispenser) error {
+	b.Scoring.Enabled = true
+
+	for d.NextBlock(1) {
+		switch d.Val() {
+		case "enabled":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			enabled, err := strconv.ParseBool(d.Val())
+			if err != nil {
+				return d.Errf("invalid scoring enabled value: %v", err)
+			}
+			b.Scoring.Enabled = enabled
+
+		case "height_weight", "latency_weight", "error_rate_weight", "peer_weight", "cutoff":
+			key := d.Val()
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			value, err := strconv.ParseFloat(d.Val(), 64)
+			if err != nil {
+				return d.Errf("invalid %s: %v", key, err)
+			}
+			switch key {
+			case "height_weight":
+				b.Scoring.HeightWeight = value
+			case "latency_weight":
+				b.Scoring.LatencyWeight = value
+			case "error_rate_weight":
+				b.Scoring.ErrorRateWeight = value
+			case "peer_weight":
+				b.Scoring.PeerWeight = value
+			case "cutoff":
+				b.Scoring.Cutoff = &value
+			}
+
+		case "max_latency":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.Scoring.MaxLatency = d.Val()
+
+		case "min_peers":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			peers, err := strconv.Atoi(d.Val())
+			if err != nil {
+				return d.Errf("invalid min_peers: %v", err)
+			}
+			b.Scoring.MinPeers = peers
+
+		default:
+			return d.Errf("unknown scoring directive: %s", d.Val())
+		}
+	}
+
+	return nil
+}
+
+// parseTrafficSplit parses a traffic_split block from the Caddyfile:
+//
+//	traffic_split <label> {
+//	    name <name>
+//	    weight <label value> <share>
+//	}
+func (b *BlockchainHealthUpstream) parseTrafficSplit(d *caddyfile.Dispenser) error {
+	if !d.NextArg() {
+		return d.ArgErr()
+	}
+	b.TrafficSplit.Label = d.Val()
+
+	for d.NextBlock(1) {
+		switch d.Val() {
+		case "name":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.TrafficSplit.Name = d.Val()
+
+		case "weight":
+			args := d.RemainingArgs()
+			if len(args) != 2 {
+				return d.ArgErr()
+			}
+			share, err := strconv.Atoi(args[1])
+			if err != nil {
+				return d.Errf("invalid traffic split weight for %s: %v", args[0], err)
+			}
+			if b.TrafficSplit.Weights == nil {
+				b.TrafficSplit.Weights = make(map[string]int)
+			}
+			b.TrafficSplit.Weights[args[0]] = share
+
+		default:
+			return d.Errf("unknown traffic_split directive: %s", d.Val())
+		}
+	}
+
+	return nil
+}
+
 // parseExternalReference parses an external reference block from the Caddyfile
 func (b *BlockchainHealthUpstream) parseExternalReference(d *caddyfile.Dispenser) (ExternalReference, error) {
 	var ref ExternalReference
@@ -389,6 +861,38 @@ func (b *BlockchainHealthUpstream) parseExternalReference(d *caddyfile.Dispenser
 			}
 			ref.Enabled = enabled
 
+		case "requests_per_minute":
+			if !d.NextArg() {
+				return ref, d.ArgErr()
+			}
+			limit, err := strconv.Atoi(d.Val())
+			if err != nil {
+				return ref, d.Errf("invalid requests_per_minute: %v", err)
+			}
+			ref.RequestsPerMinute = limit
+
+		case "burst":
+			if !d.NextArg() {
+				return ref, d.ArgErr()
+			}
+			burst, err := strconv.Atoi(d.Val())
+			if err != nil {
+				return ref, d.Errf("invalid burst: %v", err)
+			}
+			ref.Burst = burst
+
+		case "explorer":
+			if !d.NextArg() {
+				return ref, d.ArgErr()
+			}
+			ref.Explorer = d.Val()
+
+		case "api_key":
+			if !d.NextArg() {
+				return ref, d.ArgErr()
+			}
+			ref.APIKey = d.Val()
+
 		default:
 			return ref, d.Errf("unknown external reference directive: %s", d.Val())
 		}
@@ -435,6 +939,81 @@ func (b *BlockchainHealthUpstream) processEnvironmentConfiguration() error {
 	return nil
 }
 
+// duplicateNode is a node dropped because an earlier node has the same URL
+type duplicateNode struct {
+	kept    string
+	dropped string
+	url     string
+}
+
+// dedupeNodes merges nodes that share a URL, e.g. a server listed in an
+// environment variable and in a node block, so it isn't checked twice or put
+// in the pool twice. The first node wins; the duplicate only fills in
+// metadata keys and URLs the first one lacks. Nodes sharing a URL with
+// different types are a configuration error.
+func dedupeNodes(nodes []NodeConfig) ([]NodeConfig, []duplicateNode, error) {
+	index := make(map[string]int, len(nodes))
+	deduped := make([]NodeConfig, 0, len(nodes))
+	var duplicates []duplicateNode
+
+	for _, node := range nodes {
+		key := normalizeNodeURL(node.URL)
+		i, seen := index[key]
+		if !seen {
+			index[key] = len(deduped)
+			deduped = append(deduped, node)
+			continue
+		}
+
+		kept := &deduped[i]
+		if kept.Type != node.Type {
+			return nil, nil, fmt.Errorf("nodes %s and %s share URL %s with conflicting types %s and %s",
+				kept.Name, node.Name, node.URL, kept.Type, node.Type)
+		}
+		if len(node.Metadata) > 0 {
+			merged := make(map[string]string, len(kept.Metadata)+len(node.Metadata))
+			for k, v := range node.Metadata {
+				merged[k] = v
+			}
+			for k, v := range kept.Metadata {
+				merged[k] = v
+			}
+			kept.Metadata = merged
+		}
+		if kept.APIURL == "" {
+			kept.APIURL = node.APIURL
+		}
+		if kept.WebSocketURL == "" {
+			kept.WebSocketURL = node.WebSocketURL
+		}
+		if len(kept.AlternateURLs) == 0 {
+			kept.AlternateURLs = node.AlternateURLs
+		}
+		if len(kept.Headers) == 0 {
+			kept.Headers = node.Headers
+		}
+		if kept.ChainType == "" {
+			kept.ChainType = node.ChainType
+		}
+		duplicates = append(duplicates, duplicateNode{kept: kept.Name, dropped: node.Name, url: node.URL})
+	}
+
+	return deduped, duplicates, nil
+}
+
+// normalizeNodeURL returns the URL with a lowercase scheme and host and no
+// trailing slash, for comparing node URLs. The path keeps its case since it
+// may hold an API key.
+func normalizeNodeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	return strings.TrimSuffix(u.String(), "/")
+}
+
 // autoDiscoverFromEnvironment discovers servers from environment variables
 func (b *BlockchainHealthUpstream) autoDiscoverFromEnvironment(prefix string) error {
 	// Look for environment variables like COSMOS_RPC_SERVERS, COSMOS_API_SERVERS, etc.
@@ -458,17 +1037,19 @@ func (b *BlockchainHealthUpstream) autoDiscoverFromEnvironment(prefix string) er
 
 // processServerLists processes individual server list configurations
 func (b *BlockchainHealthUpstream) processServerLists() error {
+	env := b.Environment.expand()
+
 	// Process non-EVM servers normally
 	serverConfigs := []struct {
 		servers     string
 		serviceType string
 		chainType   string
 	}{
-		{b.Environment.Servers, "generic", b.Chain.ChainType},
-		{b.Environment.RPCServers, "rpc", "cosmos"},
-		{b.Environment.APIServers, "api", "cosmos"},
-		{b.Environment.WebSocketServers, "websocket", "cosmos"},
-		{b.Environment.EVMServers, "rpc", "evm"},
+		{env.Servers, "generic", b.Chain.ChainType},
+		{env.RPCServers, "rpc", "cosmos"},
+		{env.APIServers, "api", "cosmos"},
+		{env.WebSocketServers, "websocket", "cosmos"},
+		{env.EVMServers, "rpc", "evm"},
 	}
 
 	for _, config := range serverConfigs {
@@ -480,8 +1061,8 @@ func (b *BlockchainHealthUpstream) processServerLists() error {
 	}
 
 	// Handle EVM WebSocket servers with HTTP URL correlation
-	if b.Environment.EVMWSServers != "" {
-		if err := b.parseEVMWebSocketServers(); err != nil {
+	if env.EVMWSServers != "" {
+		if err := b.parseEVMWebSocketServers(env.EVMWSServers, env.EVMServers); err != nil {
 			return fmt.Errorf("parsing EVM WebSocket servers: %w", err)
 		}
 	}
@@ -489,46 +1070,132 @@ func (b *BlockchainHealthUpstream) processServerLists() error {
 	return nil
 }
 
-// parseServersFromEnv parses a space-separated list of servers and creates nodes
+// parseServersFromEnv parses a list of servers separated by whitespace,
+// commas or newlines and creates nodes. Entries may carry annotations, e.g.
+// http://node1:26657;weight=200;region=eu.
 func (b *BlockchainHealthUpstream) parseServersFromEnv(servers, serviceType string) error {
 	if servers == "" {
 		return nil
 	}
 
-	serverList := strings.Fields(servers)
-	for i, serverURL := range serverList {
+	serverList := splitServerList(servers)
+	for i, entry := range serverList {
+		serverURL, annotations, err := parseServerEntry(entry)
+		if err != nil {
+			return err
+		}
 		node, err := b.createNodeFromURL(serverURL, serviceType, i)
 		if err != nil {
 			return fmt.Errorf("creating node from URL %s: %w", serverURL, err)
 		}
+		if err := applyServerAnnotations(&node, annotations); err != nil {
+			return fmt.Errorf("server %s: %w", serverURL, err)
+		}
 		b.Nodes = append(b.Nodes, node)
 	}
 
 	return nil
 }
 
-// parseEVMWebSocketServers parses EVM WebSocket servers and correlates them with HTTP servers
-func (b *BlockchainHealthUpstream) parseEVMWebSocketServers() error {
-	wsServerList := strings.Fields(b.Environment.EVMWSServers)
-	httpServerList := strings.Fields(b.Environment.EVMServers)
+// splitServerList splits a server list on whitespace and commas, so lists
+// written one per line or comma-separated by orchestration tooling work too
+func splitServerList(servers string) []string {
+	return strings.FieldsFunc(servers, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
 
-	// Create a mapping of hostnames to HTTP URLs for correlation
+// parseServerEntry splits a server list entry into its URL, without trailing
+// slashes, and the key=value annotations that follow it, separated by
+// semicolons. The URL must be absolute with an http, https, ws or wss scheme,
+// or a unix URL naming a socket path.
+func parseServerEntry(entry string) (string, map[string]string, error) {
+	parts := strings.Split(entry, ";")
+	parts[0] = strings.TrimRight(parts[0], "/")
+	parsedURL, err := url.Parse(parts[0])
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid server %q: %w", parts[0], err)
+	}
+	switch parsedURL.Scheme {
+	case "http", "https", "ws", "wss":
+		if parsedURL.Host == "" {
+			return "", nil, fmt.Errorf("invalid server %q: missing host", parts[0])
+		}
+	case unixScheme:
+		if parsedURL.Host != "" || parsedURL.Path == "" {
+			return "", nil, fmt.Errorf("invalid server %q: expected a socket path, as in unix:///var/run/node.sock", parts[0])
+		}
+	default:
+		return "", nil, fmt.Errorf("invalid server %q: expected an http, https, ws, wss or unix URL", parts[0])
+	}
+
+	annotations := make(map[string]string, len(parts)-1)
+	for _, part := range parts[1:] {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || key == "" {
+			return "", nil, fmt.Errorf("server %s: invalid annotation %q, expected key=value", parts[0], part)
+		}
+		annotations[key] = value
+	}
+	return parts[0], annotations, nil
+}
+
+// applyServerAnnotations applies server list annotations to a node: name and
+// weight set those fields and any other key is stored as metadata, the same
+// way a node block's metadata is
+func applyServerAnnotations(node *NodeConfig, annotations map[string]string) error {
+	for key, value := range annotations {
+		switch key {
+		case "name":
+			node.Name = value
+		case "weight":
+			weight, err := strconv.Atoi(value)
+			if err != nil || weight <= 0 {
+				return fmt.Errorf("invalid weight %q: must be a positive integer", value)
+			}
+			node.Weight = weight
+		default:
+			node.Metadata[key] = value
+		}
+	}
+	return nil
+}
+
+// parseEVMWebSocketServers parses EVM WebSocket servers and correlates them with HTTP servers
+func (b *BlockchainHealthUpstream) parseEVMWebSocketServers(wsServers, httpServers string) error {
+	wsServerList := splitServerList(wsServers)
+	httpServerList := splitServerList(httpServers)
+	for i, entry := range httpServerList {
+		httpURL, _, err := parseServerEntry(entry)
+		if err != nil {
+			return err
+		}
+		httpServerList[i] = httpURL
+	}
+
+	// Create a mapping of hostnames to HTTP URLs for correlation
 	httpURLByHost := make(map[string]string)
 	for _, httpURL := range httpServerList {
-		if parsedURL, err := url.Parse(httpURL); err == nil {
-			hostname := parsedURL.Hostname()
-			httpURLByHost[hostname] = httpURL
+		if parsedURL, err := url.Parse(httpURL); err == nil && parsedURL.Hostname() != "" {
+			httpURLByHost[parsedURL.Hostname()] = httpURL
 		}
 	}
 
 	// If we have the same number of servers, correlate by index
 	correlateByIndex := len(wsServerList) == len(httpServerList)
 
-	for i, wsURL := range wsServerList {
+	for i, entry := range wsServerList {
+		wsURL, annotations, err := parseServerEntry(entry)
+		if err != nil {
+			return err
+		}
 		node, err := b.createNodeFromURL(wsURL, "websocket", i)
 		if err != nil {
 			return fmt.Errorf("creating WebSocket node from URL %s: %w", wsURL, err)
 		}
+		if err := applyServerAnnotations(&node, annotations); err != nil {
+			return fmt.Errorf("server %s: %w", wsURL, err)
+		}
 
 		// Try to find corresponding HTTP URL
 		var httpURL string
@@ -599,7 +1266,7 @@ func (b *BlockchainHealthUpstream) createNodeFromURL(serverURL, serviceType stri
 		URL:       serverURL,
 		Type:      NodeType(actualNodeType),
 		ChainType: chainType, // Store the specific chain type (e.g., "ethereum", "base", "akash")
-		Weight:    100,       // Default weight
+		Weight:    defaultNodeWeight,
 		Metadata: map[string]string{
 			"service_type":   serviceType,
 			"auto_generated": "true",
@@ -632,8 +1299,9 @@ func (b *BlockchainHealthUpstream) autoDetectServiceType(parsedURL *url.URL) (se
 	return "generic", "cosmos"
 }
 
-// mapChainTypeToProtocol maps specific chain types to their protocol types
-func (b *BlockchainHealthUpstream) mapChainTypeToProtocol(chainType string) string {
+// knownChainProtocol returns the protocol type of a well-known chain type,
+// or "" for any other chain
+func knownChainProtocol(chainType string) string {
 	switch chainType {
 	// Cosmos SDK chains
 	case "cosmos", "cosmos-hub", "akash", "osmosis", "juno", "secret", "stargaze", "regen", "althea-cosmos", "dev-cosmos":
@@ -644,6 +1312,16 @@ func (b *BlockchainHealthUpstream) mapChainTypeToProtocol(chainType string) stri
 	// Beacon/Consensus clients
 	case "beacon", "ethereum-beacon", "prysm", "teku", "lighthouse", "nimbus":
 		return "beacon"
+	}
+	return ""
+}
+
+// mapChainTypeToProtocol maps specific chain types to their protocol types
+func (b *BlockchainHealthUpstream) mapChainTypeToProtocol(chainType string) string {
+	if protocol := knownChainProtocol(chainType); protocol != "" {
+		return protocol
+	}
+	switch chainType {
 	// Dual protocol chains (use the specific service type)
 	case "dual":
 		return "" // Let caller handle this case
@@ -664,7 +1342,9 @@ func (b *BlockchainHealthUpstream) mapChainTypeToProtocol(chainType string) stri
 	}
 }
 
-// generateNodeName generates a unique node name
+// generateNodeName generates a unique node name. Per-node metrics are labeled
+// by name, so the network is part of it to keep the nodes of pools serving
+// several networks of one chain apart.
 func (b *BlockchainHealthUpstream) generateNodeName(chainType, serviceType string, index int) string {
 	if chainType == "" {
 		chainType = "blockchain"
@@ -672,11 +1352,18 @@ func (b *BlockchainHealthUpstream) generateNodeName(chainType, serviceType strin
 	if serviceType == "" {
 		serviceType = "node"
 	}
+	if b.Chain.Network != "" {
+		return fmt.Sprintf("%s-%s-%s-%d", chainType, b.Chain.Network, serviceType, index)
+	}
 	return fmt.Sprintf("%s-%s-%d", chainType, serviceType, index)
 }
 
 // generateWebSocketURL generates WebSocket URL from HTTP URL
 func (b *BlockchainHealthUpstream) generateWebSocketURL(parsedURL *url.URL, chainType string) string {
+	// WebSocket checks don't go over unix sockets
+	if parsedURL.Scheme == unixScheme {
+		return ""
+	}
 	switch chainType {
 	case "cosmos":
 		// Cosmos: convert HTTP to WebSocket and add /websocket path
@@ -783,3 +1470,727 @@ func (b *BlockchainHealthUpstream) addAltheaDefaults() {
 	// No hardcoded external references - let users configure their own
 	// to avoid rate limiting and chain-specific issues
 }
+
+// parseCost parses a cost block from the Caddyfile:
+//
+//	cost {
+//	    name <name>
+//	    monthly_budget <usd>
+//	}
+func (b *BlockchainHealthUpstream) parseCost(d *caddyfile.Dispenser) error {
+	b.Cost.Enabled = true
+
+	for d.NextBlock(1) {
+		switch d.Val() {
+		case "name":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.Cost.Name = d.Val()
+
+		case "monthly_budget":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			budget, err := strconv.ParseFloat(d.Val(), 64)
+			if err != nil {
+				return d.Errf("invalid monthly_budget: %v", err)
+			}
+			b.Cost.MonthlyBudget = budget
+
+		default:
+			return d.Errf("unknown cost directive: %s", d.Val())
+		}
+	}
+
+	return nil
+}
+
+// parseDockerDiscovery parses the docker_discovery block
+func (b *BlockchainHealthUpstream) parseDockerDiscovery(d *caddyfile.Dispenser) error {
+	b.DockerDiscovery.Enabled = true
+
+	for d.NextBlock(1) {
+		switch d.Val() {
+		case "endpoint":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.DockerDiscovery.Endpoint = d.Val()
+
+		case "chain":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.DockerDiscovery.Chain = d.Val()
+
+		case "host":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.DockerDiscovery.Host = d.Val()
+
+		case "refresh":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.DockerDiscovery.Refresh = d.Val()
+
+		case "swarm":
+			b.DockerDiscovery.Swarm = true
+
+		default:
+			return d.Errf("unknown docker_discovery directive: %s", d.Val())
+		}
+	}
+
+	return nil
+}
+
+// parseInventory parses the inventory block
+func (b *BlockchainHealthUpstream) parseInventory(d *caddyfile.Dispenser) error {
+	b.Inventory.Enabled = true
+
+	for d.NextBlock(1) {
+		switch d.Val() {
+		case "name":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.Inventory.Name = d.Val()
+
+		case "token":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.Inventory.Token = d.Val()
+
+		default:
+			return d.Errf("unknown inventory directive: %s", d.Val())
+		}
+	}
+
+	return nil
+}
+
+// parsePoolState parses the pool_state block
+func (b *BlockchainHealthUpstream) parsePoolState(d *caddyfile.Dispenser) error {
+	for d.NextBlock(1) {
+		switch d.Val() {
+		case "name":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.PoolState.Name = d.Val()
+
+		case "degraded_below", "critical_below":
+			directive := d.Val()
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			share, err := strconv.ParseFloat(d.Val(), 64)
+			if err != nil {
+				return d.Errf("invalid %s: %v", directive, err)
+			}
+			if directive == "degraded_below" {
+				b.PoolState.DegradedBelow = share
+			} else {
+				b.PoolState.CriticalBelow = share
+			}
+
+		default:
+			return d.Errf("unknown pool_state directive: %s", d.Val())
+		}
+	}
+
+	return nil
+}
+
+// parseDNSFailover parses the dns_failover block
+func (b *BlockchainHealthUpstream) parseDNSFailover(d *caddyfile.Dispenser) error {
+	b.DNSFailover.Enabled = true
+	for d.NextBlock(1) {
+		switch d.Val() {
+		case "withdraw_on":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.DNSFailover.WithdrawOn = PoolState(d.Val())
+
+		case "after":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.DNSFailover.After = d.Val()
+
+		case "timeout":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.DNSFailover.Timeout = d.Val()
+
+		case "header":
+			args := d.RemainingArgs()
+			if len(args) != 2 {
+				return d.ArgErr()
+			}
+			if b.DNSFailover.Headers == nil {
+				b.DNSFailover.Headers = make(map[string]string)
+			}
+			b.DNSFailover.Headers[args[0]] = args[1]
+
+		case "withdraw", "restore":
+			// <method> <url> [<body>]
+			directive := d.Val()
+			args := d.RemainingArgs()
+			if len(args) < 2 || len(args) > 3 {
+				return d.ArgErr()
+			}
+			request := DNSFailoverRequest{Method: strings.ToUpper(args[0]), URL: args[1]}
+			if len(args) == 3 {
+				request.Body = args[2]
+			}
+			if directive == "withdraw" {
+				b.DNSFailover.Withdraw = request
+			} else {
+				b.DNSFailover.Restore = request
+			}
+
+		default:
+			return d.Errf("unknown dns_failover directive: %s", d.Val())
+		}
+	}
+
+	return nil
+}
+
+// parseStatusPage parses the status_page block
+func (b *BlockchainHealthUpstream) parseStatusPage(d *caddyfile.Dispenser) error {
+	if !d.NextArg() {
+		return d.ArgErr()
+	}
+	b.StatusPage.Provider = d.Val()
+	if d.NextArg() {
+		return d.ArgErr()
+	}
+
+	for d.NextBlock(1) {
+		switch d.Val() {
+		case "url":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.StatusPage.URL = d.Val()
+
+		case "page":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.StatusPage.Page = d.Val()
+
+		case "component":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.StatusPage.Component = d.Val()
+
+		case "api_key":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.StatusPage.APIKey = d.Val()
+
+		case "after":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.StatusPage.After = d.Val()
+
+		case "timeout":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.StatusPage.Timeout = d.Val()
+
+		default:
+			return d.Errf("unknown status_page directive: %s", d.Val())
+		}
+	}
+
+	return nil
+}
+
+// parseAnycastSignal parses the anycast_signal block
+func (b *BlockchainHealthUpstream) parseAnycastSignal(d *caddyfile.Dispenser) error {
+	for d.NextBlock(1) {
+		switch d.Val() {
+		case "file":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.AnycastSignal.File = d.Val()
+
+		case "withdraw_on":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.AnycastSignal.WithdrawOn = PoolState(d.Val())
+
+		default:
+			return d.Errf("unknown anycast_signal directive: %s", d.Val())
+		}
+	}
+	if b.AnycastSignal.File == "" {
+		return d.Err("anycast_signal requires a file")
+	}
+
+	return nil
+}
+
+// parseReport parses the report block:
+//
+//	report [daily|weekly] {
+//	    name <name>
+//	    format json|markdown
+//	    directory <path>
+//	    webhook <url>
+//	    header <name> <value>
+//	    timeout <duration>
+//	}
+func (b *BlockchainHealthUpstream) parseReport(d *caddyfile.Dispenser) error {
+	b.Report.Enabled = true
+	if d.NextArg() {
+		b.Report.Period = d.Val()
+	}
+	if d.NextArg() {
+		return d.ArgErr()
+	}
+	for d.NextBlock(1) {
+		switch d.Val() {
+		case "name":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.Report.Name = d.Val()
+
+		case "format":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.Report.Format = d.Val()
+
+		case "directory":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.Report.Directory = d.Val()
+
+		case "webhook":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.Report.Webhook = d.Val()
+
+		case "timeout":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.Report.Timeout = d.Val()
+
+		case "header":
+			args := d.RemainingArgs()
+			if len(args) != 2 {
+				return d.ArgErr()
+			}
+			if b.Report.Headers == nil {
+				b.Report.Headers = make(map[string]string)
+			}
+			b.Report.Headers[args[0]] = args[1]
+
+		default:
+			return d.Errf("unknown report directive: %s", d.Val())
+		}
+	}
+	if b.Report.Directory == "" && b.Report.Webhook == "" {
+		return d.Err("report requires a directory or a webhook")
+	}
+
+	return nil
+}
+
+// parseRequestSigning parses the request_signing block:
+//
+//	request_signing hmac|jwt {
+//	    secret <secret>
+//	    header <name>
+//	    issuer <iss>
+//	    audience <aud>
+//	    ttl <duration>
+//	}
+func (b *BlockchainHealthUpstream) parseRequestSigning(d *caddyfile.Dispenser) error {
+	if !d.NextArg() {
+		return d.ArgErr()
+	}
+	b.RequestSigning.Method = d.Val()
+	if d.NextArg() {
+		return d.ArgErr()
+	}
+
+	for d.NextBlock(1) {
+		directive := d.Val()
+		if !d.NextArg() {
+			return d.ArgErr()
+		}
+		switch directive {
+		case "secret":
+			b.RequestSigning.Secret = d.Val()
+		case "header":
+			b.RequestSigning.Header = d.Val()
+		case "issuer":
+			b.RequestSigning.Issuer = d.Val()
+		case "audience":
+			b.RequestSigning.Audience = d.Val()
+		case "ttl":
+			b.RequestSigning.TTL = d.Val()
+		default:
+			return d.Errf("unknown request_signing directive: %s", directive)
+		}
+	}
+	if b.RequestSigning.Secret == "" {
+		return d.Err("request_signing requires a secret")
+	}
+
+	return nil
+}
+
+// parseMempoolDivergence parses the mempool_divergence block
+func (b *BlockchainHealthUpstream) parseMempoolDivergence(d *caddyfile.Dispenser) error {
+	b.MempoolDivergence.Enabled = true
+	for d.NextBlock(1) {
+		switch d.Val() {
+		case "interval":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.MempoolDivergence.Interval = d.Val()
+
+		case "threshold", "weight_factor":
+			directive := d.Val()
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			value, err := strconv.ParseFloat(d.Val(), 64)
+			if err != nil {
+				return d.Errf("invalid %s: %v", directive, err)
+			}
+			if directive == "threshold" {
+				b.MempoolDivergence.Threshold = value
+			} else {
+				b.MempoolDivergence.WeightFactor = value
+			}
+
+		case "flag_after":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			samples, err := strconv.Atoi(d.Val())
+			if err != nil {
+				return d.Errf("invalid flag_after: %v", err)
+			}
+			b.MempoolDivergence.FlagAfter = samples
+
+		default:
+			return d.Errf("unknown mempool_divergence directive: %s", d.Val())
+		}
+	}
+
+	return nil
+}
+
+// parseSLO parses the slo block
+func (b *BlockchainHealthUpstream) parseSLO(d *caddyfile.Dispenser) error {
+	b.SLO.Enabled = true
+	for d.NextBlock(1) {
+		switch d.Val() {
+		case "availability":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			share, err := parseShare(d.Val())
+			if err != nil {
+				return d.Errf("invalid availability: %v", err)
+			}
+			b.SLO.Availability = share
+
+		case "latency":
+			// latency <objective> [<target>]
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.SLO.Latency = d.Val()
+			if d.NextArg() {
+				share, err := parseShare(d.Val())
+				if err != nil {
+					return d.Errf("invalid latency target: %v", err)
+				}
+				b.SLO.LatencyTarget = share
+			}
+
+		case "window":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.SLO.Window = d.Val()
+
+		case "burn_rate", "weight_factor":
+			directive := d.Val()
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			value, err := strconv.ParseFloat(d.Val(), 64)
+			if err != nil {
+				return d.Errf("invalid %s: %v", directive, err)
+			}
+			if directive == "burn_rate" {
+				b.SLO.BurnRate = value
+			} else {
+				b.SLO.WeightFactor = value
+			}
+
+		default:
+			return d.Errf("unknown slo directive: %s", d.Val())
+		}
+	}
+
+	return nil
+}
+
+// parseShare parses a share written as a fraction such as 0.05 or a
+// percentage such as 5%
+func parseShare(s string) (float64, error) {
+	value, percent := strings.CutSuffix(s, "%")
+	share, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if percent {
+		share /= 100
+	}
+	return share, nil
+}
+
+// parseValidatorMode parses the validator_mode block
+func (b *BlockchainHealthUpstream) parseValidatorMode(d *caddyfile.Dispenser) error {
+	b.ValidatorMode.Enabled = true
+	for d.NextBlock(1) {
+		switch d.Val() {
+		case "pin_header":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.ValidatorMode.PinHeader = d.Val()
+
+		case "latency_tolerance":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.ValidatorMode.LatencyTolerance = d.Val()
+
+		default:
+			return d.Errf("unknown validator_mode directive: %s", d.Val())
+		}
+	}
+
+	return nil
+}
+
+// parseTiering parses the tiering directive:
+//
+//	tiering [<hot_blocks>] {
+//		hot_blocks <blocks>
+//		hot {
+//			height_threshold <blocks>
+//		}
+//		cold {
+//			height_threshold <blocks>
+//		}
+//	}
+func (b *BlockchainHealthUpstream) parseTiering(d *caddyfile.Dispenser) error {
+	b.Tiering.Enabled = true
+	parseBlocks := func(name string) (int, error) {
+		if !d.NextArg() {
+			return 0, d.ArgErr()
+		}
+		blocks, err := strconv.Atoi(d.Val())
+		if err != nil {
+			return 0, d.Errf("invalid %s: %v", name, err)
+		}
+		return blocks, nil
+	}
+
+	if d.NextArg() {
+		blocks, err := strconv.Atoi(d.Val())
+		if err != nil {
+			return d.Errf("invalid hot_blocks: %v", err)
+		}
+		b.Tiering.HotBlocks = blocks
+	}
+
+	for d.NextBlock(1) {
+		switch d.Val() {
+		case "hot_blocks":
+			blocks, err := parseBlocks("hot_blocks")
+			if err != nil {
+				return err
+			}
+			b.Tiering.HotBlocks = blocks
+
+		case "hot", "cold":
+			tier := &b.Tiering.Hot
+			if d.Val() == tierCold {
+				tier = &b.Tiering.Cold
+			}
+			for d.NextBlock(2) {
+				switch d.Val() {
+				case "height_threshold":
+					threshold, err := parseBlocks("height_threshold")
+					if err != nil {
+						return err
+					}
+					tier.HeightThreshold = threshold
+
+				default:
+					return d.Errf("unknown tier directive: %s", d.Val())
+				}
+			}
+
+		default:
+			return d.Errf("unknown tiering directive: %s", d.Val())
+		}
+	}
+
+	return nil
+}
+
+// parseFlapQuarantine parses the flap_quarantine directive:
+//
+//	flap_quarantine {
+//		max_transitions <count>
+//		window <duration>
+//		cooldown <duration>
+//	}
+func (b *BlockchainHealthUpstream) parseFlapQuarantine(d *caddyfile.Dispenser) error {
+	b.FlapQuarantine.Enabled = true
+	for d.NextBlock(1) {
+		switch d.Val() {
+		case "max_transitions":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			transitions, err := strconv.Atoi(d.Val())
+			if err != nil {
+				return d.Errf("invalid max_transitions: %v", err)
+			}
+			b.FlapQuarantine.MaxTransitions = transitions
+
+		case "window":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.FlapQuarantine.Window = d.Val()
+
+		case "cooldown":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.FlapQuarantine.Cooldown = d.Val()
+
+		default:
+			return d.Errf("unknown flap_quarantine directive: %s", d.Val())
+		}
+	}
+
+	return nil
+}
+
+// parseStrictValidation parses the strict_validation directive:
+//
+//	strict_validation [on|off] {
+//		resolve
+//		metadata_keys <key...>
+//	}
+func (b *BlockchainHealthUpstream) parseStrictValidation(d *caddyfile.Dispenser) error {
+	b.StrictValidation.Enabled = true
+	if d.NextArg() {
+		switch d.Val() {
+		case "on":
+		case "off":
+			b.StrictValidation.Enabled = false
+		default:
+			enabled, err := strconv.ParseBool(d.Val())
+			if err != nil {
+				return d.Errf("invalid strict_validation: %s (must be 'on' or 'off')", d.Val())
+			}
+			b.StrictValidation.Enabled = enabled
+		}
+	}
+	if d.NextArg() {
+		return d.ArgErr()
+	}
+
+	for d.NextBlock(1) {
+		switch d.Val() {
+		case "resolve":
+			if d.NextArg() {
+				return d.ArgErr()
+			}
+			b.StrictValidation.Resolve = true
+
+		case "metadata_keys":
+			keys := d.RemainingArgs()
+			if len(keys) == 0 {
+				return d.ArgErr()
+			}
+			b.StrictValidation.MetadataKeys = append(b.StrictValidation.MetadataKeys, keys...)
+
+		default:
+			return d.Errf("unknown strict_validation directive: %s", d.Val())
+		}
+	}
+
+	return nil
+}
+
+// parsePrewarm parses the prewarm directive:
+//
+//	prewarm [<connections>] {
+//		interval <duration>
+//	}
+func (b *BlockchainHealthUpstream) parsePrewarm(d *caddyfile.Dispenser) error {
+	b.Prewarm.Enabled = true
+	if d.NextArg() {
+		connections, err := strconv.Atoi(d.Val())
+		if err != nil || connections <= 0 {
+			return d.Errf("invalid prewarm connections: %s", d.Val())
+		}
+		b.Prewarm.Connections = connections
+	}
+	if d.NextArg() {
+		return d.ArgErr()
+	}
+
+	for d.NextBlock(1) {
+		switch d.Val() {
+		case "interval":
+			if !d.NextArg() {
+				return d.ArgErr()
+			}
+			b.Prewarm.Interval = d.Val()
+
+		default:
+			return d.Errf("unknown prewarm directive: %s", d.Val())
+		}
+	}
+
+	return nil
+}
@@ -415,6 +415,16 @@ func TestNodeCreationFromURL(t *testing.T) {
 		t.Errorf("Expected EVM WebSocket URL 'ws://localhost:8545', got '%s'", evmNode.WebSocketURL)
 	}
 
+	// Generated names carry the network, so the per-node metrics of the
+	// pools of two networks don't collide
+	mainnet, _ := upstream.createNodeFromURL("http://localhost:8545", "rpc", 0)
+	upstream.Chain.Network = "sepolia"
+	sepolia, _ := upstream.createNodeFromURL("http://localhost:8546", "rpc", 0)
+	upstream.Chain.Network = ""
+	if mainnet.Name != "evm-rpc-0" || sepolia.Name != "evm-sepolia-rpc-0" {
+		t.Errorf("expected per-network node names, got %q and %q", mainnet.Name, sepolia.Name)
+	}
+
 	// Test invalid URL (URL with invalid scheme)
 	_, err = upstream.createNodeFromURL("://invalid-url", "rpc", 0)
 	if err == nil {
@@ -610,3 +620,144 @@ func TestBlockchainHealthUpstream_ParseEVMWebSocketServers_HostnameCorrelation(t
 
 	t.Logf("✅ EVM WebSocket hostname correlation test passed")
 }
+
+// TestDedupeNodes tests merging nodes listed twice
+func TestDedupeNodes(t *testing.T) {
+	nodes := []NodeConfig{
+		{Name: "my-node", URL: "http://localhost:26657", Type: NodeTypeCosmos, Weight: 5, Metadata: map[string]string{"region": "eu", "service_type": "rpc"}},
+		{Name: "cosmos-rpc-0", URL: "http://LOCALHOST:26657/", Type: NodeTypeCosmos, Weight: 1, Metadata: map[string]string{"service_type": "api", "provider": "hetzner"}},
+		{Name: "cosmos-rpc-1", URL: "http://localhost:26658", Type: NodeTypeCosmos, Weight: 1},
+	}
+
+	deduped, duplicates, err := dedupeNodes(nodes)
+	if err != nil {
+		t.Fatalf("dedupeNodes failed: %v", err)
+	}
+	if len(deduped) != 2 || len(duplicates) != 1 {
+		t.Fatalf("Expected 2 nodes and 1 duplicate, got %d nodes and %v", len(deduped), duplicates)
+	}
+	if duplicates[0].kept != "my-node" || duplicates[0].dropped != "cosmos-rpc-0" {
+		t.Errorf("Unexpected duplicate: %+v", duplicates[0])
+	}
+
+	merged := deduped[0]
+	if merged.Name != "my-node" || merged.Weight != 5 {
+		t.Errorf("Expected the first node to win, got %s with weight %d", merged.Name, merged.Weight)
+	}
+	if merged.Metadata["region"] != "eu" || merged.Metadata["provider"] != "hetzner" || merged.Metadata["service_type"] != "rpc" {
+		t.Errorf("Unexpected merged metadata: %v", merged.Metadata)
+	}
+	if _, ok := nodes[0].Metadata["provider"]; ok {
+		t.Error("Expected the original metadata to be left untouched")
+	}
+
+	// Paths keep their case, since they may hold an API key
+	if normalizeNodeURL("https://RPC.example.com/v3/Key/") != "https://rpc.example.com/v3/Key" {
+		t.Errorf("Unexpected normalized URL: %s", normalizeNodeURL("https://RPC.example.com/v3/Key/"))
+	}
+}
+
+// TestDedupeNodes_ConflictingTypes tests that nodes sharing a URL must agree on their type
+func TestDedupeNodes_ConflictingTypes(t *testing.T) {
+	upstream := &BlockchainHealthUpstream{
+		Nodes: []NodeConfig{
+			{Name: "cosmos", URL: "http://localhost:8545", Type: NodeTypeCosmos, Weight: 1},
+			{Name: "evm", URL: "http://localhost:8545", Type: NodeTypeEVM, Weight: 1},
+		},
+	}
+
+	if err := upstream.validate(); err == nil {
+		t.Error("Expected conflicting types on the same URL to be rejected")
+	}
+}
+
+// TestAnnotatedServerEntries tests weights and labels in environment server lists
+func TestAnnotatedServerEntries(t *testing.T) {
+	upstream := &BlockchainHealthUpstream{
+		Environment: EnvironmentConfig{
+			RPCServers: "http://node1:26657;weight=200;region=eu;archive=true http://node2:26657;name=backup",
+		},
+		logger: zaptest.NewLogger(t),
+	}
+
+	if err := upstream.processEnvironmentConfiguration(); err != nil {
+		t.Fatalf("Failed to process environment configuration: %v", err)
+	}
+	if len(upstream.Nodes) != 2 {
+		t.Fatalf("Expected 2 nodes, got %d", len(upstream.Nodes))
+	}
+
+	node := upstream.Nodes[0]
+	if node.URL != "http://node1:26657" || node.Weight != 200 {
+		t.Errorf("Expected node1 with weight 200, got %s with weight %d", node.URL, node.Weight)
+	}
+	if node.Metadata["region"] != "eu" || node.Metadata["archive"] != "true" || node.Metadata["service_type"] != "rpc" {
+		t.Errorf("Unexpected metadata: %v", node.Metadata)
+	}
+	if upstream.Nodes[1].Name != "backup" || upstream.Nodes[1].Weight != 100 {
+		t.Errorf("Expected node2 named backup with the default weight, got %s with weight %d", upstream.Nodes[1].Name, upstream.Nodes[1].Weight)
+	}
+
+	for _, servers := range []string{"http://node1:26657;weight=heavy", "http://node1:26657;weight=0", "http://node1:26657;archive"} {
+		upstream := &BlockchainHealthUpstream{Environment: EnvironmentConfig{RPCServers: servers}}
+		if err := upstream.processEnvironmentConfiguration(); err == nil {
+			t.Errorf("Expected %q to be rejected", servers)
+		}
+	}
+}
+
+// TestServerListSeparators tests comma- and newline-separated environment server lists
+func TestServerListSeparators(t *testing.T) {
+	upstream := &BlockchainHealthUpstream{
+		Environment: EnvironmentConfig{
+			RPCServers: "http://node1:26657/,http://node2:26657, http://node3:26657\nhttp://node4:26657;region=eu\n",
+		},
+	}
+
+	if err := upstream.processEnvironmentConfiguration(); err != nil {
+		t.Fatalf("Failed to process environment configuration: %v", err)
+	}
+
+	expected := []string{"http://node1:26657", "http://node2:26657", "http://node3:26657", "http://node4:26657"}
+	if len(upstream.Nodes) != len(expected) {
+		t.Fatalf("Expected %d nodes, got %d", len(expected), len(upstream.Nodes))
+	}
+	for i, want := range expected {
+		if upstream.Nodes[i].URL != want {
+			t.Errorf("Expected node %d to have URL %s, got %s", i, want, upstream.Nodes[i].URL)
+		}
+	}
+
+	// Nodes on the same host may be listed by their socket
+	upstream = &BlockchainHealthUpstream{
+		Environment: EnvironmentConfig{
+			EVMServers:   "unix:///var/run/evm/rpc.sock;name=local http://node2:8545",
+			EVMWSServers: "ws://node2:8546",
+		},
+	}
+	if err := upstream.processEnvironmentConfiguration(); err != nil {
+		t.Fatalf("Failed to process unix server entries: %v", err)
+	}
+	if len(upstream.Nodes) != 3 || upstream.Nodes[0].URL != "unix:///var/run/evm/rpc.sock" || upstream.Nodes[0].Name != "local" {
+		t.Fatalf("Expected the unix node first, got %+v", upstream.Nodes)
+	}
+	if upstream.Nodes[0].WebSocketURL != "" {
+		t.Errorf("Expected no WebSocket URL for a unix node, got %s", upstream.Nodes[0].WebSocketURL)
+	}
+	if httpURL := upstream.Nodes[2].Metadata["http_url"]; httpURL != "http://node2:8545" {
+		t.Errorf("Expected the WebSocket node to correlate with node2, got %q", httpURL)
+	}
+
+	for _, servers := range []string{"node1:26657", "http://", "ftp://node1", "unix://", "unix://host/rpc.sock"} {
+		upstream := &BlockchainHealthUpstream{Environment: EnvironmentConfig{RPCServers: servers}}
+		if err := upstream.processEnvironmentConfiguration(); err == nil {
+			t.Errorf("Expected malformed server %q to be rejected", servers)
+		}
+	}
+
+	// The HTTP list the WebSocket servers are correlated with is checked too
+	upstream = &BlockchainHealthUpstream{Environment: EnvironmentConfig{EVMServers: "http://node1:8545;archive", EVMWSServers: "ws://node1:8546"}}
+	if err := upstream.parseEVMWebSocketServers(upstream.Environment.EVMWSServers, upstream.Environment.EVMServers); err == nil {
+		t.Error("Expected a malformed HTTP server to be rejected when correlating WebSocket servers")
+	}
+}
@@ -0,0 +1,163 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+)
+
+// costMetadataKey is the node metadata value holding its price in USD per
+// million requests
+const costMetadataKey = "cost_per_million"
+
+// nodeCost returns the node's price per million requests; nodes without one
+// are free
+func nodeCost(node NodeConfig) (float64, error) {
+	value, ok := node.Metadata[costMetadataKey]
+	if !ok || value == "" {
+		return 0, nil
+	}
+	cost, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", costMetadataKey, err)
+	}
+	if cost < 0 {
+		return 0, fmt.Errorf("%s must not be negative", costMetadataKey)
+	}
+	return cost, nil
+}
+
+// costBudget is the month-to-date spend on paid nodes. Pools configured with
+// the same budget name share it, and it survives config reloads as long as a
+// pool uses it. The spend is kept in memory only, so a restart resets it.
+type costBudget struct {
+	name string
+
+	mutex sync.Mutex
+	month string // calendar month of spent, e.g. 2026-10
+	spent float64
+	now   func() time.Time
+}
+
+// Destruct implements caddy.Destructor
+func (c *costBudget) Destruct() error {
+	return nil
+}
+
+// rollover resets the spend when a new month starts; callers hold the mutex
+func (c *costBudget) rollover() {
+	month := c.now().UTC().Format("2006-01")
+	if month != c.month {
+		c.month = month
+		c.spent = 0
+	}
+}
+
+// spentThisMonth returns the month-to-date spend
+func (c *costBudget) spentThisMonth() float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.rollover()
+	return c.spent
+}
+
+// charge adds one request at the given price per million requests and
+// returns the month-to-date spend
+func (c *costBudget) charge(costPerMillion float64) float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.rollover()
+	c.spent += costPerMillion / 1e6
+	return c.spent
+}
+
+// costBudgets holds the live cost budgets by name
+var costBudgets = caddy.NewUsagePool()
+
+// registerCostBudget returns the live budget with the given name, creating it
+// if needed
+func registerCostBudget(name string) (*costBudget, error) {
+	value, _, err := costBudgets.LoadOrNew(name, func() (caddy.Destructor, error) {
+		return &costBudget{name: name, now: time.Now}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*costBudget), nil
+}
+
+// releaseCostBudget drops a pool's reference to its budget
+func releaseCostBudget(budget *costBudget) {
+	_, _ = costBudgets.Delete(budget.name)
+}
+
+// costBudgetName returns the configured budget name, defaulting to the chain
+func (b *BlockchainHealthUpstream) costBudgetName() string {
+	if b.Cost.Name != "" {
+		return b.Cost.Name
+	}
+	if chain := b.chainName(); chain != "" {
+		return chain
+	}
+	return "default"
+}
+
+// applyCostPolicy keeps the cheapest of the selected upstreams, so paid nodes
+// only receive traffic while no cheaper node is healthy. Paid nodes are
+// dropped entirely once the monthly budget is spent. Every kept upstream has
+// the same price, which is charged to the budget for the request. External
+// references have no node config and count as free.
+func (b *BlockchainHealthUpstream) applyCostPolicy(upstreams []*reverseproxy.Upstream, infos []selectionInfo) ([]*reverseproxy.Upstream, []selectionInfo, error) {
+	exhausted := b.config.Cost.MonthlyBudget > 0 && b.budget.spentThisMonth() >= b.config.Cost.MonthlyBudget
+
+	costs := make([]float64, len(upstreams))
+	cheapest := -1.0
+	for i := range upstreams {
+		if i < len(infos) {
+			if node := b.findNodeConfig(infos[i].name); node != nil {
+				costs[i], _ = nodeCost(*node)
+			}
+		}
+		if exhausted && costs[i] > 0 {
+			continue
+		}
+		if cheapest < 0 || costs[i] < cheapest {
+			cheapest = costs[i]
+		}
+	}
+	if cheapest < 0 {
+		return nil, nil, fmt.Errorf("%w: monthly budget of %s for paid nodes is spent", ErrNoHealthyUpstreams, b.budget.name)
+	}
+
+	var kept []*reverseproxy.Upstream
+	var keptInfos []selectionInfo
+	for i, upstream := range upstreams {
+		if costs[i] != cheapest {
+			if b.metrics != nil && i < len(infos) {
+				reason := "cost"
+				if exhausted && costs[i] > 0 {
+					reason = "cost_budget"
+				}
+				b.metrics.upstreamsExcluded.WithLabelValues(infos[i].name, infos[i].serviceType, reason).Inc()
+			}
+			continue
+		}
+		kept = append(kept, upstream)
+		if i < len(infos) {
+			keptInfos = append(keptInfos, infos[i])
+		}
+	}
+
+	if cheapest > 0 {
+		spent := b.budget.charge(cheapest)
+		if b.metrics != nil {
+			b.metrics.paidRequests.WithLabelValues(b.budget.name).Inc()
+			b.metrics.paidSpend.WithLabelValues(b.budget.name).Set(spent)
+		}
+	}
+	return kept, keptInfos, nil
+}
@@ -0,0 +1,115 @@
+package blockchain_health
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestCostBudget_ResetsEachMonth(t *testing.T) {
+	now := time.Date(2026, time.October, 31, 23, 0, 0, 0, time.UTC)
+	budget := &costBudget{name: "test", now: func() time.Time { return now }}
+
+	budget.charge(2_000_000)
+	if spent := budget.charge(1_000_000); spent != 3 {
+		t.Errorf("expected $3 spent, got %v", spent)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if spent := budget.spentThisMonth(); spent != 0 {
+		t.Errorf("expected the spend to reset in a new month, got %v", spent)
+	}
+}
+
+func TestSelectUpstreams_CostPolicy(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	selfHosted := createCosmosServer(t, 1000, false)
+	defer selfHosted.Close()
+	paid := createCosmosServer(t, 1000, false)
+	defer paid.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "self-hosted", URL: selfHosted.URL, Type: NodeTypeCosmos, Weight: 1},
+		{Name: "provider", URL: paid.URL, Type: NodeTypeCosmos, Weight: 1, Metadata: map[string]string{"cost_per_million": "500000"}},
+	}, logger)
+	upstream.config.Cost = CostConfig{Enabled: true, MonthlyBudget: 1}
+	budget, err := registerCostBudget("cost-test")
+	if err != nil {
+		t.Fatalf("registerCostBudget failed: %v", err)
+	}
+	defer releaseCostBudget(budget)
+	upstream.budget = budget
+
+	request := &http.Request{URL: &url.URL{Path: "/"}, Header: http.Header{}}
+	upstreams, err := upstream.GetUpstreams(request)
+	if err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+	if len(upstreams) != 1 || upstreams[0].Dial != getDynamicTestHostFromURL(selfHosted.URL) {
+		t.Fatalf("expected only the self-hosted node, got %v", upstreams)
+	}
+	if spent := budget.spentThisMonth(); spent != 0 {
+		t.Errorf("expected no spend on the free node, got %v", spent)
+	}
+
+	// The paid node takes over while the free one is down, until the budget is spent
+	selfHosted.Close()
+	upstream.cache.Clear()
+	upstream.healthChecker.cache.Clear()
+	for i := 0; i < 2; i++ {
+		upstreams, err = upstream.GetUpstreams(request)
+		if err != nil {
+			t.Fatalf("GetUpstreams failed: %v", err)
+		}
+		if len(upstreams) != 1 || upstreams[0].Dial != getDynamicTestHostFromURL(paid.URL) {
+			t.Fatalf("expected only the paid node, got %v", upstreams)
+		}
+	}
+	if spent := budget.spentThisMonth(); spent != 1 {
+		t.Errorf("expected $1 spent, got %v", spent)
+	}
+
+	if _, err := upstream.GetUpstreams(request); !errors.Is(err, ErrNoHealthyUpstreams) {
+		t.Errorf("expected the spent budget to stop paid traffic, got %v", err)
+	}
+}
+
+func TestCost_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:26657
+			type cosmos
+			metadata {
+				cost_per_million 12.5
+			}
+		}
+		cost {
+			name ethereum-paid
+			monthly_budget 250
+		}
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if !b.Cost.Enabled || b.Cost.Name != "ethereum-paid" || b.Cost.MonthlyBudget != 250 {
+		t.Errorf("unexpected cost config: %+v", b.Cost)
+	}
+	if cost, err := nodeCost(b.Nodes[0]); err != nil || cost != 12.5 {
+		t.Errorf("expected a cost of 12.5, got %v (%v)", cost, err)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+
+	b.Nodes[0].Metadata["cost_per_million"] = "cheap"
+	if err := b.validate(); err == nil {
+		t.Error("expected an invalid cost to be rejected")
+	}
+}
@@ -0,0 +1,148 @@
+package blockchain_health
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+)
+
+func init() {
+	caddy.RegisterModule(&Defaults{})
+	httpcaddyfile.RegisterGlobalOption("blockchain_health_defaults", parseDefaultsCaddyfile)
+}
+
+// Defaults holds settings shared by every blockchain_health upstream and
+// named pool, so site blocks don't repeat identical tuning. It takes the
+// upstream's options except nodes; settings an upstream sets itself win.
+type Defaults BlockchainHealthUpstream
+
+// CaddyModule returns the Caddy module information.
+func (*Defaults) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "blockchain_health_defaults",
+		New: func() caddy.Module { return new(Defaults) },
+	}
+}
+
+// Validate implements caddy.Validator. Nodes belong to each upstream.
+func (d *Defaults) Validate() error {
+	if d.Pool != "" || len(d.Nodes) > 0 || d.Environment != (EnvironmentConfig{}) {
+		return fmt.Errorf("blockchain_health_defaults cannot configure nodes or pools")
+	}
+	return (*BlockchainHealthUpstream)(d).validateChoices()
+}
+
+// Start implements caddy.App.
+func (*Defaults) Start() error { return nil }
+
+// Stop implements caddy.App.
+func (*Defaults) Stop() error { return nil }
+
+// parseDefaultsCaddyfile parses the blockchain_health_defaults global option,
+// which takes the same options as the upstream
+func parseDefaultsCaddyfile(d *caddyfile.Dispenser, existing any) (any, error) {
+	if existing != nil {
+		return nil, d.Err("blockchain_health_defaults defined more than once")
+	}
+	defaults := new(BlockchainHealthUpstream)
+	if err := defaults.parseCaddyfile(d); err != nil {
+		return nil, err
+	}
+	if err := (*Defaults)(defaults).Validate(); err != nil {
+		return nil, err
+	}
+
+	value, err := json.Marshal(defaults)
+	if err != nil {
+		return nil, err
+	}
+	return httpcaddyfile.App{Name: "blockchain_health_defaults", Value: value}, nil
+}
+
+// applyDefaults fills the settings the upstream leaves unset from the
+// blockchain_health_defaults app, if the config has one
+func (b *BlockchainHealthUpstream) applyDefaults(ctx caddy.Context) error {
+	module, err := ctx.AppIfConfigured("blockchain_health_defaults")
+	if errors.Is(err, caddy.ErrNotConfigured) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	mergeDefaults(reflect.ValueOf(b).Elem(), reflect.ValueOf(module.(*Defaults)).Elem())
+	return nil
+}
+
+// mergeDefaults sets the zero-valued exported fields of dst from defaults,
+// descending into the config sections so an upstream can override single
+// settings of a section. Booleans set in the defaults can't be turned off
+// per upstream, as false is their zero value. Maps, slices and pointers are
+// copied, so pools changing their settings don't change each other's.
+func mergeDefaults(dst, defaults reflect.Value) {
+	for i := 0; i < dst.NumField(); i++ {
+		field := dst.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+		if field.Kind() == reflect.Struct {
+			mergeDefaults(field, defaults.Field(i))
+			continue
+		}
+		if field.IsZero() {
+			field.Set(deepCopy(defaults.Field(i)))
+		}
+	}
+}
+
+// deepCopy returns a copy of v sharing no maps, slices or pointers with it
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			c.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < c.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// Interface guards
+var (
+	_ caddy.App       = (*Defaults)(nil)
+	_ caddy.Validator = (*Defaults)(nil)
+)
@@ -0,0 +1,159 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+)
+
+func TestMergeDefaults(t *testing.T) {
+	enforce := false
+	defaults := Defaults{
+		HealthCheck:     HealthCheckConfig{Interval: "10s", Timeout: "3s"},
+		BlockValidation: BlockValidationConfig{HeightThreshold: 5},
+		FailureHandling: FailureHandlingConfig{Enforce: &enforce},
+		Monitoring:      MonitoringConfig{ComponentLogLevels: map[string]string{"checker": "debug"}},
+	}
+	b := BlockchainHealthUpstream{
+		HealthCheck:     HealthCheckConfig{Timeout: "1s"},
+		BlockValidation: BlockValidationConfig{HeightThreshold: 2},
+	}
+	mergeDefaults(reflect.ValueOf(&b).Elem(), reflect.ValueOf(&defaults).Elem())
+
+	if b.HealthCheck.Interval != "10s" || b.HealthCheck.Timeout != "1s" {
+		t.Errorf("expected the default interval and the upstream's timeout, got %+v", b.HealthCheck)
+	}
+	if b.BlockValidation.HeightThreshold != 2 {
+		t.Errorf("expected the upstream's height threshold, got %d", b.BlockValidation.HeightThreshold)
+	}
+	if b.FailureHandling.enforceExclusions() {
+		t.Error("expected the default enforce setting")
+	}
+	if b.Monitoring.ComponentLogLevels["checker"] != "debug" {
+		t.Errorf("expected the default log levels, got %v", b.Monitoring.ComponentLogLevels)
+	}
+}
+
+func TestMergeDefaults_CopiesSharedSettings(t *testing.T) {
+	defaults := Defaults{
+		DNSFailover: DNSFailoverConfig{Headers: map[string]string{"Authorization": "Bearer token"}},
+		ExternalReferences: []ExternalReference{
+			{Name: "reference", URL: "https://rpc.example.com", Type: NodeTypeEVM, Enabled: true},
+		},
+	}
+	var first, second BlockchainHealthUpstream
+	mergeDefaults(reflect.ValueOf(&first).Elem(), reflect.ValueOf(&defaults).Elem())
+	mergeDefaults(reflect.ValueOf(&second).Elem(), reflect.ValueOf(&defaults).Elem())
+
+	// Each pool changes the settings it inherited
+	first.DNSFailover.Headers["Authorization"] = "Bearer first"
+	second.DNSFailover.Headers["X-Pool"] = "second"
+	first.ExternalReferences[0].Enabled = false
+
+	if got := second.DNSFailover.Headers["Authorization"]; got != "Bearer token" {
+		t.Errorf("expected the second pool to keep the inherited header, got %q", got)
+	}
+	if _, ok := first.DNSFailover.Headers["X-Pool"]; ok {
+		t.Error("expected the second pool's header not to reach the first pool")
+	}
+	if !second.ExternalReferences[0].Enabled {
+		t.Error("expected the second pool to keep its inherited reference enabled")
+	}
+	if len(defaults.DNSFailover.Headers) != 1 || defaults.DNSFailover.Headers["Authorization"] != "Bearer token" ||
+		!defaults.ExternalReferences[0].Enabled {
+		t.Errorf("expected the defaults to be unchanged, got %v and %+v", defaults.DNSFailover.Headers, defaults.ExternalReferences)
+	}
+}
+
+func TestDefaults_Adapt(t *testing.T) {
+	config := `{
+		blockchain_health_defaults {
+			timeout 5s
+			height_threshold 5
+		}
+	}
+
+	:8080 {
+		reverse_proxy {
+			dynamic blockchain_health {
+				node a {
+					url http://localhost:8545
+					type evm
+				}
+			}
+		}
+	}`
+	adapted, _, err := caddyconfig.GetAdapter("caddyfile").Adapt([]byte(config), nil)
+	if err != nil {
+		t.Fatalf("adapting Caddyfile: %v", err)
+	}
+	for _, want := range []string{`"blockchain_health_defaults":{`, `"timeout":"5s"`, `"height_threshold":5`} {
+		if !strings.Contains(string(adapted), want) {
+			t.Errorf("expected %s in %s", want, adapted)
+		}
+	}
+}
+
+func TestDefaults_RejectsNodes(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health_defaults {
+		node a {
+			url http://localhost:8545
+			type evm
+		}
+	}`)
+	if _, err := parseDefaultsCaddyfile(d, nil); err == nil {
+		t.Error("expected nodes in the defaults to be rejected")
+	}
+}
+
+func TestDefaults_AppliedToPoolsAndUpstreams(t *testing.T) {
+	node := createVersionedEVMServer(t, "Geth/v1.13.14")
+	defer node.Close()
+
+	config := fmt.Sprintf(`{
+		"admin": {"disabled": true},
+		"apps": {
+			"blockchain_health_defaults": {
+				"health_check": {"interval": "100ms", "timeout": "3s"},
+				"block_validation": {"height_threshold": 7}
+			},
+			"blockchain_health": {
+				"pools": {
+					"evm-main": {
+						"nodes": [{"name": "a", "url": %q, "type": "evm"}],
+						"block_validation": {"height_threshold": 2}
+					}
+				}
+			}
+		}
+	}`, node.URL)
+	if err := caddy.Load([]byte(config), true); err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	defer func() { _ = caddy.Stop() }()
+
+	ctx := caddy.ActiveContext()
+	appModule, err := ctx.App("blockchain_health")
+	if err != nil {
+		t.Fatalf("blockchain_health app not loaded: %v", err)
+	}
+	pool := appModule.(*App).Pools["evm-main"]
+	if pool.config.HealthCheck.Timeout != "3s" || pool.config.BlockValidation.HeightThreshold != 2 {
+		t.Errorf("expected the default timeout and the pool's threshold, got %+v %+v",
+			pool.config.HealthCheck, pool.config.BlockValidation)
+	}
+
+	site := &BlockchainHealthUpstream{Nodes: []NodeConfig{{Name: "a", URL: node.URL, Type: NodeTypeEVM, Weight: 1}}}
+	if err := site.Provision(ctx); err != nil {
+		t.Fatalf("provisioning site: %v", err)
+	}
+	defer func() { _ = site.Cleanup() }()
+	if site.HealthCheck.Timeout != "3s" || site.BlockValidation.HeightThreshold != 7 {
+		t.Errorf("expected the defaults on the site, got %+v %+v", site.HealthCheck, site.BlockValidation)
+	}
+}
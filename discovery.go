@@ -0,0 +1,122 @@
+package blockchain_health
+
+import (
+	"reflect"
+	"sort"
+
+	"go.uber.org/zap"
+)
+
+// setDiscoveredNodes replaces the nodes found by a discovery source and
+// updates the pool to the configured nodes plus every source's nodes. Nodes
+// already in the pool keep their health state, since it is kept by node key.
+func (b *BlockchainHealthUpstream) setDiscoveredNodes(source string, nodes []NodeConfig) error {
+	return b.replaceDiscoveredNodes(source, nodes, nil)
+}
+
+// replaceDiscoveredNodes sets the nodes of a source and retires the nodes of
+// the given keys in one update, so no request sees both the old and the new
+// node, or neither. Retired nodes are left out even when a source lists them
+// again.
+func (b *BlockchainHealthUpstream) replaceDiscoveredNodes(source string, nodes []NodeConfig, retire []string) error {
+	if err := migrateNodeTypes(nodes, b.logger); err != nil {
+		return err
+	}
+
+	b.discoveryMutex.Lock()
+	defer b.discoveryMutex.Unlock()
+
+	if b.discovered == nil {
+		b.discovered = make(map[string][]NodeConfig)
+	}
+	previous, existed := b.discovered[source]
+	b.discovered[source] = nodes
+	var newlyRetired []string
+	for _, key := range retire {
+		if !b.retired[key] {
+			newlyRetired = append(newlyRetired, key)
+		}
+	}
+	if b.retired == nil && len(newlyRetired) > 0 {
+		b.retired = make(map[string]bool)
+	}
+	for _, key := range newlyRetired {
+		b.retired[key] = true
+	}
+
+	sources := make([]string, 0, len(b.discovered))
+	for name := range b.discovered {
+		sources = append(sources, name)
+	}
+	sort.Strings(sources)
+
+	all := append([]NodeConfig(nil), b.staticNodes...)
+	for _, name := range sources {
+		all = append(all, b.discovered[name]...)
+	}
+	kept := all[:0]
+	for _, node := range all {
+		if !b.retired[node.key()] {
+			kept = append(kept, node)
+		}
+	}
+	merged, _, err := dedupeNodes(kept)
+	if err != nil {
+		if existed {
+			b.discovered[source] = previous
+		} else {
+			delete(b.discovered, source)
+		}
+		for _, key := range newlyRetired {
+			delete(b.retired, key)
+		}
+		return err
+	}
+
+	memoizeKeys(merged)
+	current := b.config.nodeList()
+	if reflect.DeepEqual(current, merged) {
+		return nil
+	}
+	b.config.setNodes(merged)
+	if b.healthChecker != nil {
+		b.healthChecker.syncSchedules()
+	}
+
+	if b.metrics != nil {
+		b.metrics.configuredNodes.Set(float64(len(merged)))
+		for _, node := range merged {
+			b.metrics.nodeInfo.WithLabelValues(node.Name, node.key(), string(node.Type), b.config.Chain.Network).Set(1)
+		}
+	}
+
+	added, removed := diffNodeNames(current, merged)
+	b.logger.Info("discovered nodes updated",
+		zap.String("source", source),
+		zap.Int("nodes", len(merged)),
+		zap.Strings("added", added),
+		zap.Strings("removed", removed))
+	return nil
+}
+
+// diffNodeNames returns the names of the nodes added to and removed from a
+// node list, by node key
+func diffNodeNames(before, after []NodeConfig) (added, removed []string) {
+	beforeKeys := make(map[string]bool, len(before))
+	for _, node := range before {
+		beforeKeys[node.key()] = true
+	}
+	afterKeys := make(map[string]bool, len(after))
+	for _, node := range after {
+		afterKeys[node.key()] = true
+		if !beforeKeys[node.key()] {
+			added = append(added, node.Name)
+		}
+	}
+	for _, node := range before {
+		if !afterKeys[node.key()] {
+			removed = append(removed, node.Name)
+		}
+	}
+	return added, removed
+}
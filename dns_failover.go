@@ -0,0 +1,196 @@
+package blockchain_health
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"go.uber.org/zap"
+)
+
+// DNS failover defaults
+const (
+	defaultDNSFailoverAfter   = time.Minute
+	defaultDNSFailoverTimeout = 10 * time.Second
+)
+
+// validate checks the dns_failover settings
+func (c *DNSFailoverConfig) validate() error {
+	if c.WithdrawOn != "" && (!isValidPoolState(string(c.WithdrawOn)) || c.WithdrawOn == PoolHealthy) {
+		return fmt.Errorf("invalid dns_failover withdraw_on %q (must be degraded, chain_halted, critical or down)", c.WithdrawOn)
+	}
+	if c.After != "" {
+		if after, err := time.ParseDuration(c.After); err != nil || after < 0 {
+			return fmt.Errorf("invalid dns_failover after: %s", c.After)
+		}
+	}
+	if c.Timeout != "" {
+		if timeout, err := time.ParseDuration(c.Timeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid dns_failover timeout: %s", c.Timeout)
+		}
+	}
+	if c.Withdraw.URL == "" || c.Restore.URL == "" {
+		return fmt.Errorf("dns_failover requires both a withdraw and a restore request")
+	}
+	return nil
+}
+
+// dnsFailover withdraws the gateway from rotation once the pool has been at
+// or below WithdrawOn for After, and restores it once the pool has been
+// healthy for After. States in between keep the gateway where it is.
+//
+// Whether the gateway is in rotation is unknown on start, so after a reload
+// the first sustained state sends its request even if an earlier instance
+// already did. Provider requests should therefore be idempotent.
+type dnsFailover struct {
+	config  *DNSFailoverConfig
+	after   time.Duration
+	client  *http.Client
+	logger  *zap.Logger
+	metrics *Metrics
+	now     func() time.Time
+
+	mutex     sync.Mutex
+	known     bool      // whether withdrawn reflects the provider
+	withdrawn bool      // whether the last successful request withdrew the gateway
+	since     time.Time // when the pool entered the state wanting a change
+	sending   bool      // whether a provider request is in flight
+}
+
+// newDNSFailover returns the DNS failover of a pool. The config must have
+// its defaults set.
+func newDNSFailover(config *DNSFailoverConfig, logger *zap.Logger, metrics *Metrics) *dnsFailover {
+	after, _ := time.ParseDuration(config.After)
+	timeout, _ := time.ParseDuration(config.Timeout)
+	return &dnsFailover{
+		config:  config,
+		after:   after,
+		client:  &http.Client{Timeout: timeout},
+		logger:  logger,
+		metrics: metrics,
+		now:     time.Now,
+	}
+}
+
+// observe records the state of the pool after a health check. Once the state
+// has lasted long enough it returns the withdraw or restore request to send,
+// else nil.
+func (f *dnsFailover) observe(pool string, state PoolState) func(context.Context) {
+	var withdraw bool
+	switch {
+	case state.severity() >= f.config.WithdrawOn.severity():
+		withdraw = true
+	case state == PoolHealthy:
+		withdraw = false
+	default:
+		f.mutex.Lock()
+		f.since = time.Time{}
+		f.mutex.Unlock()
+		return nil
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.known && f.withdrawn == withdraw {
+		f.since = time.Time{}
+		return nil
+	}
+	now := f.now()
+	if f.since.IsZero() {
+		f.since = now
+	}
+	if now.Sub(f.since) < f.after || f.sending {
+		return nil
+	}
+
+	// Sent outside the health check; a failed request is retried after the
+	// next check
+	f.sending = true
+	return func(ctx context.Context) { f.send(ctx, pool, state, withdraw) }
+}
+
+// send calls the provider and records the outcome
+func (f *dnsFailover) send(ctx context.Context, pool string, state PoolState, withdraw bool) {
+	request, action := f.config.Restore, "restore"
+	if withdraw {
+		request, action = f.config.Withdraw, "withdraw"
+	}
+	err := f.do(ctx, request, pool, state)
+
+	f.mutex.Lock()
+	f.sending = false
+	if err == nil {
+		f.known, f.withdrawn = true, withdraw
+		f.since = time.Time{}
+	}
+	f.mutex.Unlock()
+
+	if err != nil {
+		f.logger.Error("DNS failover request failed",
+			zap.String("pool", pool),
+			zap.String("action", action),
+			zap.Error(err))
+		return
+	}
+	f.logger.Warn("DNS failover updated gateway rotation",
+		zap.String("pool", pool),
+		zap.String("action", action),
+		zap.String("pool_state", string(state)))
+	if f.metrics != nil {
+		value := 0.0
+		if withdraw {
+			value = 1
+		}
+		f.metrics.dnsWithdrawn.WithLabelValues(pool).Set(value)
+	}
+}
+
+// do sends a templated provider request
+func (f *dnsFailover) do(ctx context.Context, request DNSFailoverRequest, pool string, state PoolState) error {
+	repl := caddy.NewReplacer()
+	repl.Set("pool.name", pool)
+	repl.Set("pool.state", string(state))
+
+	// Unknown placeholders are left alone so JSON bodies survive
+	var body io.Reader
+	if request.Body != "" {
+		body = strings.NewReader(repl.ReplaceKnown(request.Body, ""))
+	}
+	req, err := http.NewRequestWithContext(ctx, request.Method, repl.ReplaceKnown(request.URL, ""), body)
+	if err != nil {
+		return err
+	}
+	if request.Body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range f.config.Headers {
+		req.Header.Set(name, repl.ReplaceKnown(value, ""))
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("provider returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// applyDNSFailover passes the pool state to the DNS failover, if configured.
+// Its requests run in the background, which Stop cancels and waits for.
+func (h *HealthChecker) applyDNSFailover(pool string, state PoolState) {
+	if h.dnsFailover == nil {
+		return
+	}
+	if send := h.dnsFailover.observe(pool, state); send != nil {
+		h.goBackground(func() { send(h.ctx) })
+	}
+}
@@ -0,0 +1,239 @@
+package blockchain_health
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"go.uber.org/zap/zaptest"
+)
+
+// providerRequest is a request received by the fake DNS provider
+type providerRequest struct {
+	method, path, body, auth string
+}
+
+// fakeProvider records provider requests and answers with the given statuses
+// in turn, then 200
+type fakeProvider struct {
+	mutex    sync.Mutex
+	requests []providerRequest
+	statuses []int
+}
+
+func (p *fakeProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.requests = append(p.requests, providerRequest{r.Method, r.URL.Path, string(body), r.Header.Get("Authorization")})
+	status := http.StatusOK
+	if len(p.statuses) > 0 {
+		status, p.statuses = p.statuses[0], p.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (p *fakeProvider) received() []providerRequest {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]providerRequest(nil), p.requests...)
+}
+
+// newTestDNSFailover returns a DNS failover against the provider with a
+// controllable clock
+func newTestDNSFailover(t *testing.T, provider *httptest.Server, clock *time.Time) *dnsFailover {
+	t.Helper()
+	config := &DNSFailoverConfig{
+		Enabled:    true,
+		WithdrawOn: PoolCritical,
+		After:      "1m",
+		Timeout:    "5s",
+		Headers:    map[string]string{"Authorization": "Bearer {env.DNS_FAILOVER_TEST_TOKEN}"},
+		Withdraw:   DNSFailoverRequest{Method: http.MethodPatch, URL: provider.URL + "/pools/{pool.name}", Body: `{"enabled": false, "state": "{pool.state}"}`},
+		Restore:    DNSFailoverRequest{Method: http.MethodPatch, URL: provider.URL + "/pools/{pool.name}", Body: `{"enabled": true}`},
+	}
+	failover := newDNSFailover(config, zaptest.NewLogger(t), NewMetrics())
+	failover.now = func() time.Time { return *clock }
+	return failover
+}
+
+// observeDNSFailover passes the pool state to the DNS failover and sends
+// its request, if any, before returning
+func observeDNSFailover(f *dnsFailover, pool string, state PoolState) {
+	if send := f.observe(pool, state); send != nil {
+		send(context.Background())
+	}
+}
+
+func TestDNSFailover_WithdrawAndRestore(t *testing.T) {
+	t.Setenv("DNS_FAILOVER_TEST_TOKEN", "secret")
+	provider := &fakeProvider{}
+	server := httptest.NewServer(provider)
+	defer server.Close()
+
+	clock := time.Now()
+	failover := newTestDNSFailover(t, server, &clock)
+	step := func(state PoolState, elapsed time.Duration) {
+		clock = clock.Add(elapsed)
+		observeDNSFailover(failover, "osmosis", state)
+	}
+
+	// Degraded is above withdraw_on, and critical must last a minute
+	step(PoolDegraded, 0)
+	step(PoolCritical, 0)
+	step(PoolCritical, 30*time.Second)
+	if got := provider.received(); len(got) != 0 {
+		t.Fatalf("expected no requests before the state lasted, got %v", got)
+	}
+	step(PoolDown, 30*time.Second)
+
+	got := provider.received()
+	want := providerRequest{http.MethodPatch, "/pools/osmosis", `{"enabled": false, "state": "down"}`, "Bearer secret"}
+	if len(got) != 1 || got[0] != want {
+		t.Fatalf("expected withdraw request %v, got %v", want, got)
+	}
+
+	// A short recovery and degraded states keep the gateway withdrawn
+	step(PoolHealthy, time.Minute)
+	step(PoolDegraded, 30*time.Second)
+	step(PoolHealthy, time.Minute)
+	step(PoolHealthy, 30*time.Second)
+	if got := provider.received(); len(got) != 1 {
+		t.Fatalf("expected the gateway to stay withdrawn, got %v", got)
+	}
+	step(PoolHealthy, 30*time.Second)
+
+	got = provider.received()
+	if len(got) != 2 || got[1].body != `{"enabled": true}` {
+		t.Fatalf("expected a restore request, got %v", got)
+	}
+	step(PoolHealthy, time.Hour)
+	if got := provider.received(); len(got) != 2 {
+		t.Fatalf("expected a single restore, got %v", got)
+	}
+}
+
+func TestDNSFailover_RetriesFailedRequests(t *testing.T) {
+	provider := &fakeProvider{statuses: []int{http.StatusInternalServerError}}
+	server := httptest.NewServer(provider)
+	defer server.Close()
+
+	clock := time.Now()
+	failover := newTestDNSFailover(t, server, &clock)
+	observeDNSFailover(failover, "osmosis", PoolDown)
+	clock = clock.Add(time.Minute)
+	observeDNSFailover(failover, "osmosis", PoolDown)
+	if failover.known {
+		t.Fatal("expected a failed withdraw not to count")
+	}
+
+	observeDNSFailover(failover, "osmosis", PoolDown)
+	if !failover.known || !failover.withdrawn {
+		t.Fatal("expected the retried withdraw to succeed")
+	}
+	if got := provider.received(); len(got) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(got))
+	}
+}
+
+func TestDNSFailover_StopWaitsForRequests(t *testing.T) {
+	// The provider holds the request until it is canceled
+	received := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		close(received)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "node", URL: "http://localhost:1", Type: NodeTypeEVM, Weight: 1},
+	}, zaptest.NewLogger(t))
+	h := upstream.healthChecker
+	clock := time.Now()
+	failover := newTestDNSFailover(t, server, &clock)
+	h.dnsFailover = failover
+
+	h.applyDNSFailover("osmosis", PoolDown)
+	clock = clock.Add(time.Minute)
+	h.applyDNSFailover("osmosis", PoolDown)
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the withdraw request to be sent")
+	}
+
+	// Stop cancels the request and returns once it finished
+	h.Stop()
+	failover.mutex.Lock()
+	defer failover.mutex.Unlock()
+	if failover.sending || failover.known {
+		t.Errorf("expected the canceled request to have finished without effect, sending %v known %v", failover.sending, failover.known)
+	}
+}
+
+func TestDNSFailover_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:8545
+			type evm
+		}
+		dns_failover {
+			withdraw_on down
+			after 2m
+			header Authorization "Bearer {env.CF_API_TOKEN}"
+			withdraw patch https://api.example.com/pools/{pool.name} "{\"enabled\": false}"
+			restore PATCH https://api.example.com/pools/{pool.name} "{\"enabled\": true}"
+		}
+	}`)
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	df := b.DNSFailover
+	if !df.Enabled || df.WithdrawOn != PoolDown || df.After != "2m" {
+		t.Fatalf("unexpected dns_failover settings: %+v", df)
+	}
+	if df.Headers["Authorization"] != "Bearer {env.CF_API_TOKEN}" {
+		t.Errorf("unexpected headers: %v", df.Headers)
+	}
+	if df.Withdraw.Method != http.MethodPatch || df.Withdraw.Body != `{"enabled": false}` {
+		t.Errorf("unexpected withdraw request: %+v", df.Withdraw)
+	}
+	if df.Restore.URL != "https://api.example.com/pools/{pool.name}" {
+		t.Errorf("unexpected restore request: %+v", df.Restore)
+	}
+}
+
+func TestDNSFailoverConfig_Validate(t *testing.T) {
+	valid := DNSFailoverConfig{
+		Enabled:  true,
+		Withdraw: DNSFailoverRequest{URL: "https://api.example.com/withdraw"},
+		Restore:  DNSFailoverRequest{URL: "https://api.example.com/restore"},
+	}
+	if err := valid.validate(); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+
+	for name, mutate := range map[string]func(*DNSFailoverConfig){
+		"healthy withdraw_on": func(c *DNSFailoverConfig) { c.WithdrawOn = PoolHealthy },
+		"unknown withdraw_on": func(c *DNSFailoverConfig) { c.WithdrawOn = "bad" },
+		"negative after":      func(c *DNSFailoverConfig) { c.After = "-1s" },
+		"zero timeout":        func(c *DNSFailoverConfig) { c.Timeout = "0s" },
+		"missing restore":     func(c *DNSFailoverConfig) { c.Restore.URL = "" },
+	} {
+		config := valid
+		mutate(&config)
+		if err := config.validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package blockchain_health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Docker labels read by docker_discovery
+const (
+	dockerLabelChain   = "blockchain_health.chain"   // required; the chain the container serves
+	dockerLabelService = "blockchain_health.service" // rpc (default), api, websocket, generic
+	dockerLabelPort    = "blockchain_health.port"    // container port to use when several are published
+	dockerLabelType    = "blockchain_health.type"    // cosmos, evm or beacon; defaults from the chain
+	dockerLabelScheme  = "blockchain_health.scheme"  // http (default), https, ws or wss
+	dockerLabelWeight  = "blockchain_health.weight"
+	dockerLabelName    = "blockchain_health.name" // defaults to the container or service name
+)
+
+const (
+	defaultDockerEndpoint = "unix:///var/run/docker.sock"
+	defaultDockerRefresh  = 30 * time.Second
+	dockerRequestTimeout  = 10 * time.Second
+)
+
+// dockerDiscovery lists the labelled containers or services through the
+// Docker Engine API
+type dockerDiscovery struct {
+	config  DockerDiscoveryConfig
+	client  *http.Client
+	baseURL string
+	mapType func(chain string) string
+}
+
+// newDockerDiscovery creates a client for the configured Docker endpoint
+func newDockerDiscovery(config DockerDiscoveryConfig, mapType func(string) string) (*dockerDiscovery, error) {
+	client, baseURL, err := dockerClient(config.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &dockerDiscovery{
+		config:  config,
+		client:  client,
+		baseURL: baseURL,
+		mapType: mapType,
+	}, nil
+}
+
+// dockerClient returns an HTTP client and base URL for a Docker endpoint:
+// unix:// sockets, tcp:// addresses, or plain http(s):// URLs
+func dockerClient(endpoint string) (*http.Client, string, error) {
+	if endpoint == "" {
+		endpoint = defaultDockerEndpoint
+	}
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid docker endpoint: %w", err)
+	}
+
+	client := &http.Client{Timeout: dockerRequestTimeout}
+	switch parsed.Scheme {
+	case "unix":
+		socket := parsed.Path
+		if socket == "" {
+			return nil, "", fmt.Errorf("invalid docker endpoint %s: missing socket path", endpoint)
+		}
+		client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		}
+		return client, "http://docker", nil
+	case "tcp":
+		return client, "http://" + parsed.Host, nil
+	case "http", "https":
+		return client, strings.TrimSuffix(endpoint, "/"), nil
+	default:
+		return nil, "", fmt.Errorf("invalid docker endpoint %s: scheme must be unix, tcp, http or https", endpoint)
+	}
+}
+
+// dockerContainer is the part of a /containers/json entry we use
+type dockerContainer struct {
+	Names  []string          `json:"Names"`
+	Labels map[string]string `json:"Labels"`
+	Ports  []struct {
+		IP          string `json:"IP"`
+		PrivatePort int    `json:"PrivatePort"`
+		PublicPort  int    `json:"PublicPort"`
+		Type        string `json:"Type"`
+	} `json:"Ports"`
+}
+
+// dockerService is the part of a /services entry we use
+type dockerService struct {
+	Spec struct {
+		Name   string            `json:"Name"`
+		Labels map[string]string `json:"Labels"`
+	} `json:"Spec"`
+	Endpoint struct {
+		Ports []struct {
+			Protocol      string `json:"Protocol"`
+			TargetPort    int    `json:"TargetPort"`
+			PublishedPort int    `json:"PublishedPort"`
+		} `json:"Ports"`
+	} `json:"Endpoint"`
+}
+
+// dockerPort is a published port of a container or service
+type dockerPort struct {
+	host    string
+	private int
+	public  int
+}
+
+// discover returns a node for each labelled container or service with a
+// published port, sorted by name. Entries that cannot become a node are
+// skipped and reported through skip.
+func (d *dockerDiscovery) discover(ctx context.Context, skip func(name string, err error)) ([]NodeConfig, error) {
+	label := dockerLabelChain
+	if d.config.Chain != "" {
+		label += "=" + d.config.Chain
+	}
+	filters, err := json.Marshal(map[string][]string{"label": {label}})
+	if err != nil {
+		return nil, err
+	}
+	query := "?filters=" + url.QueryEscape(string(filters))
+
+	var nodes []NodeConfig
+	if d.config.Swarm {
+		var services []dockerService
+		if err := d.get(ctx, "/services"+query, &services); err != nil {
+			return nil, err
+		}
+		for _, service := range services {
+			ports := make([]dockerPort, 0, len(service.Endpoint.Ports))
+			for _, port := range service.Endpoint.Ports {
+				if port.Protocol != "" && port.Protocol != "tcp" {
+					continue
+				}
+				ports = append(ports, dockerPort{private: port.TargetPort, public: port.PublishedPort})
+			}
+			node, err := d.node(service.Spec.Name, service.Spec.Labels, ports)
+			if err != nil {
+				skip(service.Spec.Name, err)
+				continue
+			}
+			nodes = append(nodes, node)
+		}
+	} else {
+		var containers []dockerContainer
+		if err := d.get(ctx, "/containers/json"+query, &containers); err != nil {
+			return nil, err
+		}
+		for _, container := range containers {
+			name := ""
+			if len(container.Names) > 0 {
+				name = strings.TrimPrefix(container.Names[0], "/")
+			}
+			ports := make([]dockerPort, 0, len(container.Ports))
+			for _, port := range container.Ports {
+				if port.Type != "" && port.Type != "tcp" {
+					continue
+				}
+				ports = append(ports, dockerPort{host: port.IP, private: port.PrivatePort, public: port.PublicPort})
+			}
+			node, err := d.node(name, container.Labels, ports)
+			if err != nil {
+				skip(name, err)
+				continue
+			}
+			nodes = append(nodes, node)
+		}
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes, nil
+}
+
+// get decodes a Docker API response
+func (d *dockerDiscovery) get(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("docker API request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("docker API returned status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid docker API response: %w", err)
+	}
+	return nil
+}
+
+// node builds the node for a container or service from its labels
+func (d *dockerDiscovery) node(name string, labels map[string]string, ports []dockerPort) (NodeConfig, error) {
+	chain := labels[dockerLabelChain]
+	if chain == "" {
+		return NodeConfig{}, fmt.Errorf("missing %s label", dockerLabelChain)
+	}
+	if labels[dockerLabelName] != "" {
+		name = labels[dockerLabelName]
+	}
+	if name == "" {
+		return NodeConfig{}, fmt.Errorf("container has no name")
+	}
+
+	nodeType := labels[dockerLabelType]
+	if nodeType == "" {
+		nodeType = d.mapType(chain)
+	}
+	if _, _, ok := parseNodeType(nodeType); !ok {
+		return NodeConfig{}, fmt.Errorf("invalid node type %q for chain %s", nodeType, chain)
+	}
+
+	port, err := publishedPort(labels[dockerLabelPort], ports)
+	if err != nil {
+		return NodeConfig{}, err
+	}
+
+	host := d.config.Host
+	if host == "" {
+		host = port.host
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+
+	scheme := labels[dockerLabelScheme]
+	if scheme == "" {
+		scheme = "http"
+	}
+	switch scheme {
+	case "http", "https", "ws", "wss":
+	default:
+		return NodeConfig{}, fmt.Errorf("invalid scheme %q", scheme)
+	}
+
+	weight := 100
+	if value := labels[dockerLabelWeight]; value != "" {
+		if weight, err = strconv.Atoi(value); err != nil || weight <= 0 {
+			return NodeConfig{}, fmt.Errorf("invalid weight %q", value)
+		}
+	}
+
+	serviceType := labels[dockerLabelService]
+	if serviceType == "" {
+		serviceType = string(ServiceTypeRPC)
+	}
+	if _, _, ok := parseServiceType(serviceType); !ok {
+		return NodeConfig{}, fmt.Errorf("invalid %s label %q", dockerLabelService, serviceType)
+	}
+
+	return NodeConfig{
+		Name:   name,
+		URL:    fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, strconv.Itoa(port.public))),
+		Type:   NodeType(nodeType),
+		Weight: weight,
+		Metadata: map[string]string{
+			"chain_type":   chain,
+			"service_type": serviceType,
+			"source":       "docker",
+		},
+	}, nil
+}
+
+// publishedPort picks the published port for the container port named by
+// the port label, or the first published port
+func publishedPort(label string, ports []dockerPort) (dockerPort, error) {
+	want := 0
+	if label != "" {
+		var err error
+		if want, err = strconv.Atoi(label); err != nil {
+			return dockerPort{}, fmt.Errorf("invalid %s label %q", dockerLabelPort, label)
+		}
+	}
+	for _, port := range ports {
+		if port.public == 0 {
+			continue
+		}
+		if want == 0 || port.private == want {
+			return port, nil
+		}
+	}
+	if want != 0 {
+		return dockerPort{}, fmt.Errorf("container port %d is not published", want)
+	}
+	return dockerPort{}, fmt.Errorf("no published tcp port")
+}
+
+// startDockerDiscovery loads the discovered nodes once, then refreshes them
+// in the background until the health checker stops
+func (b *BlockchainHealthUpstream) startDockerDiscovery() error {
+	config := b.config.DockerDiscovery
+	discovery, err := newDockerDiscovery(config, b.mapChainTypeToProtocol)
+	if err != nil {
+		return err
+	}
+	refresh := defaultDockerRefresh
+	if config.Refresh != "" {
+		if refresh, err = time.ParseDuration(config.Refresh); err != nil {
+			return fmt.Errorf("invalid docker_discovery refresh: %w", err)
+		}
+	}
+
+	refreshNodes := func(ctx context.Context) {
+		nodes, err := discovery.discover(ctx, func(name string, err error) {
+			b.logger.Debug("docker entry skipped", zap.String("name", name), zap.Error(err))
+		})
+		if err != nil {
+			b.logger.Warn("docker discovery failed, keeping the current nodes", zap.Error(err))
+			return
+		}
+		if err := b.setDiscoveredNodes("docker", nodes); err != nil {
+			b.logger.Warn("discovered docker nodes rejected", zap.Error(err))
+		}
+	}
+
+	ctx := b.healthChecker.ctx
+	refreshNodes(ctx)
+
+	go func() {
+		ticker := time.NewTicker(refresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				refreshNodes(ctx)
+			}
+		}
+	}()
+	return nil
+}
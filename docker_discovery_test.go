@@ -0,0 +1,174 @@
+package blockchain_health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"go.uber.org/zap/zaptest"
+)
+
+// newFakeDocker serves the given containers and services like the Docker API
+func newFakeDocker(t *testing.T, containers, services *string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var filters map[string][]string
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters); err != nil || len(filters["label"]) != 1 {
+			t.Errorf("unexpected filters: %q", r.URL.Query().Get("filters"))
+		}
+		switch r.URL.Path {
+		case "/containers/json":
+			_, _ = w.Write([]byte(*containers))
+		case "/services":
+			_, _ = w.Write([]byte(*services))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDockerDiscovery_Containers(t *testing.T) {
+	containers := `[
+		{"Names": ["/osmosis-rpc"], "Labels": {"blockchain_health.chain": "osmosis"},
+		 "Ports": [{"IP": "0.0.0.0", "PrivatePort": 26657, "PublicPort": 32768, "Type": "tcp"}]},
+		{"Names": ["/geth"], "Labels": {"blockchain_health.chain": "ethereum", "blockchain_health.port": "8545", "blockchain_health.weight": "50"},
+		 "Ports": [{"IP": "10.0.0.5", "PrivatePort": 8546, "PublicPort": 8546, "Type": "tcp"},
+		           {"IP": "10.0.0.5", "PrivatePort": 8545, "PublicPort": 18545, "Type": "tcp"}]},
+		{"Names": ["/unpublished"], "Labels": {"blockchain_health.chain": "osmosis"}, "Ports": [{"PrivatePort": 26657, "Type": "tcp"}]}
+	]`
+	services := `[]`
+	server := newFakeDocker(t, &containers, &services)
+
+	b := &BlockchainHealthUpstream{}
+	discovery, err := newDockerDiscovery(DockerDiscoveryConfig{Endpoint: server.URL}, b.mapChainTypeToProtocol)
+	if err != nil {
+		t.Fatalf("Failed to create discovery: %v", err)
+	}
+
+	var skipped []string
+	nodes, err := discovery.discover(context.Background(), func(name string, err error) {
+		skipped = append(skipped, name)
+	})
+	if err != nil {
+		t.Fatalf("Discovery failed: %v", err)
+	}
+
+	if len(nodes) != 2 {
+		t.Fatalf("Expected 2 nodes, got %+v", nodes)
+	}
+	if nodes[0].Name != "geth" || nodes[0].URL != "http://10.0.0.5:18545" || nodes[0].Type != NodeTypeEVM || nodes[0].Weight != 50 {
+		t.Errorf("unexpected geth node: %+v", nodes[0])
+	}
+	if nodes[1].Name != "osmosis-rpc" || nodes[1].URL != "http://127.0.0.1:32768" || nodes[1].Type != NodeTypeCosmos {
+		t.Errorf("unexpected osmosis node: %+v", nodes[1])
+	}
+	if nodes[1].Metadata["service_type"] != "rpc" || nodes[1].Metadata["chain_type"] != "osmosis" || nodes[1].Metadata["source"] != "docker" {
+		t.Errorf("unexpected metadata: %v", nodes[1].Metadata)
+	}
+	if len(skipped) != 1 || skipped[0] != "unpublished" {
+		t.Errorf("expected the unpublished container to be skipped, got %v", skipped)
+	}
+}
+
+func TestDockerDiscovery_SwarmServices(t *testing.T) {
+	containers := `[]`
+	services := `[
+		{"Spec": {"Name": "cosmos_api", "Labels": {"blockchain_health.chain": "cosmos-hub", "blockchain_health.service": "api"}},
+		 "Endpoint": {"Ports": [{"Protocol": "tcp", "TargetPort": 1317, "PublishedPort": 1317}]}}
+	]`
+	server := newFakeDocker(t, &containers, &services)
+
+	b := &BlockchainHealthUpstream{}
+	config := DockerDiscoveryConfig{Endpoint: server.URL, Swarm: true, Host: "swarm.internal"}
+	discovery, err := newDockerDiscovery(config, b.mapChainTypeToProtocol)
+	if err != nil {
+		t.Fatalf("Failed to create discovery: %v", err)
+	}
+
+	nodes, err := discovery.discover(context.Background(), func(string, error) {})
+	if err != nil {
+		t.Fatalf("Discovery failed: %v", err)
+	}
+	if len(nodes) != 1 || nodes[0].URL != "http://swarm.internal:1317" || nodes[0].Metadata["service_type"] != "api" {
+		t.Errorf("unexpected nodes: %+v", nodes)
+	}
+}
+
+func TestSetDiscoveredNodes(t *testing.T) {
+	static := NodeConfig{Name: "static", URL: "http://static:26657", Type: NodeTypeCosmos, Weight: 100}
+	upstream := createTestUpstream([]NodeConfig{static}, zaptest.NewLogger(t))
+	upstream.staticNodes = upstream.config.nodeList()
+	upstream.healthChecker.scheduleNodes(time.Hour)
+	defer upstream.healthChecker.Stop()
+
+	discovered := NodeConfig{Name: "docker", URL: "http://127.0.0.1:32768", Type: NodeTypeCosmos, Weight: 100}
+	if err := upstream.setDiscoveredNodes("docker", []NodeConfig{discovered}); err != nil {
+		t.Fatalf("Failed to set nodes: %v", err)
+	}
+	if nodes := upstream.config.nodeList(); len(nodes) != 2 || nodes[1].Name != "docker" {
+		t.Fatalf("expected the discovered node to be added, got %+v", nodes)
+	}
+	if upstream.findNodeConfig("docker") == nil {
+		t.Error("expected the discovered node to be found by name")
+	}
+
+	// A discovered node sharing a URL with a configured one merges into it
+	duplicate := NodeConfig{Name: "copy", URL: "http://static:26657/", Type: NodeTypeCosmos, Weight: 100}
+	if err := upstream.setDiscoveredNodes("docker", []NodeConfig{duplicate}); err != nil {
+		t.Fatalf("Failed to set nodes: %v", err)
+	}
+	if nodes := upstream.config.nodeList(); len(nodes) != 1 || nodes[0].Name != "static" {
+		t.Errorf("expected only the configured node to remain, got %+v", nodes)
+	}
+
+	// Conflicting types are rejected and the pool is left as it was
+	conflict := NodeConfig{Name: "evm", URL: "http://static:26657", Type: NodeTypeEVM, Weight: 100}
+	if err := upstream.setDiscoveredNodes("docker", []NodeConfig{conflict}); err == nil {
+		t.Error("expected a node with a conflicting type to be rejected")
+	}
+	if nodes := upstream.config.nodeList(); len(nodes) != 1 {
+		t.Errorf("expected the pool to be unchanged, got %+v", nodes)
+	}
+}
+
+func TestDockerDiscovery_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		docker_discovery {
+			endpoint tcp://docker.internal:2375
+			chain osmosis
+			host 10.0.0.5
+			refresh 1m
+			swarm
+		}
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	want := DockerDiscoveryConfig{
+		Enabled:  true,
+		Endpoint: "tcp://docker.internal:2375",
+		Chain:    "osmosis",
+		Host:     "10.0.0.5",
+		Swarm:    true,
+		Refresh:  "1m",
+	}
+	if b.DockerDiscovery != want {
+		t.Errorf("unexpected docker discovery config: %+v", b.DockerDiscovery)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected docker discovery to stand in for static nodes, got %v", err)
+	}
+
+	b.DockerDiscovery.Endpoint = "ftp://docker"
+	if err := b.validate(); err == nil {
+		t.Error("expected an unsupported endpoint to be rejected")
+	}
+}
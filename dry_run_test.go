@@ -0,0 +1,50 @@
+package blockchain_health
+
+import (
+	"net/http"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// TestDryRunKeepsUnhealthyNodes verifies that enforce=false returns all nodes
+// while enforce=true (the default) excludes unhealthy ones
+func TestDryRunKeepsUnhealthyNodes(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	healthyServer := createCosmosServer(t, 1000, false)
+	defer healthyServer.Close()
+	syncingServer := createCosmosServer(t, 990, true)
+	defer syncingServer.Close()
+
+	nodes := []NodeConfig{
+		{Name: "healthy", URL: healthyServer.URL, Type: NodeTypeCosmos, Weight: 1},
+		{Name: "syncing", URL: syncingServer.URL, Type: NodeTypeCosmos, Weight: 1},
+	}
+
+	t.Run("Enforced", func(t *testing.T) {
+		upstream := createTestUpstream(nodes, logger)
+
+		upstreams, err := upstream.GetUpstreams(&http.Request{})
+		if err != nil {
+			t.Fatalf("GetUpstreams failed: %v", err)
+		}
+		if len(upstreams) != 1 {
+			t.Errorf("Expected 1 upstream with enforcement, got %d", len(upstreams))
+		}
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		upstream := createTestUpstream(nodes, logger)
+		enforce := false
+		upstream.config.FailureHandling.Enforce = &enforce
+
+		upstreams, err := upstream.GetUpstreams(&http.Request{})
+		if err != nil {
+			t.Fatalf("GetUpstreams failed: %v", err)
+		}
+		if len(upstreams) != 2 {
+			t.Errorf("Expected 2 upstreams in dry-run mode, got %d", len(upstreams))
+		}
+	})
+}
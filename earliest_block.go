@@ -0,0 +1,148 @@
+package blockchain_health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Earliest block probing settings
+const (
+	earliestBlockRefresh      = time.Hour
+	earliestBlockRetry        = 5 * time.Minute
+	earliestBlockProbeTimeout = 30 * time.Second
+)
+
+// earliestBlockState holds the last probed earliest block for a node
+type earliestBlockState struct {
+	height    uint64
+	known     bool
+	nextProbe time.Time
+	probing   bool
+}
+
+// GetEarliestBlock implements EarliestBlockProber for EVM nodes. It binary
+// searches eth_getBlockByNumber between block 1 and latest for the first block
+// the node still returns. Genesis alone does not prove full history, since
+// clients that expire old history keep it. Null results and JSON-RPC errors
+// count as pruned; rate limits and transport errors abort the search.
+func (e *EVMHandler) GetEarliestBlock(ctx context.Context, url string, latest uint64) (uint64, error) {
+	available := func(number uint64) (bool, error) {
+		rpcResp, err := e.callJSONRPC(ctx, url, "eth_getBlockByNumber", []interface{}{fmt.Sprintf("0x%x", number), false})
+		if err != nil {
+			// Rate limits say nothing about history; abort the search
+			if errors.Is(err, errThrottled) {
+				return false, err
+			}
+			var rpcErr *jsonRPCError
+			if errors.As(err, &rpcErr) {
+				return false, nil
+			}
+			return false, err
+		}
+		return rpcResp.Result != nil, nil
+	}
+
+	// Nodes that still serve genesis and block 1 have full history
+	ok, err := available(0)
+	if err != nil {
+		return 0, fmt.Errorf("probing genesis block: %w", err)
+	}
+	if ok {
+		ok, err = available(1)
+		if err != nil {
+			return 0, fmt.Errorf("probing block 1: %w", err)
+		}
+		if ok {
+			return 0, nil
+		}
+	}
+
+	low, high := uint64(1), latest
+	for low < high {
+		mid := low + (high-low)/2
+		ok, err := available(mid)
+		if err != nil {
+			return 0, fmt.Errorf("probing block %d: %w", mid, err)
+		}
+		if ok {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+
+	return low, nil
+}
+
+// trackEarliestBlock applies the last known earliest block to an EVM node's
+// health and starts a background probe when the value is missing or stale
+func (h *HealthChecker) trackEarliestBlock(node NodeConfig, health *NodeHealth) {
+	if !h.config.BlockValidation.TrackEarliestBlock || node.Type != NodeTypeEVM || !health.Healthy || health.BlockHeight == 0 {
+		return
+	}
+
+	probeURL := node.rpcURL()
+	if probeURL == "" {
+		return
+	}
+
+	h.mutex.Lock()
+	state, exists := h.earliestBlocks[node.key()]
+	if !exists {
+		state = &earliestBlockState{}
+		h.earliestBlocks[node.key()] = state
+	}
+	if state.known {
+		health.EarliestBlockHeight = state.height
+		health.Pruned = state.height > 0
+	}
+	stale := !state.probing && !time.Now().Before(state.nextProbe)
+	if stale {
+		state.probing = true
+	}
+	h.mutex.Unlock()
+
+	if stale {
+		height := health.BlockHeight
+		h.goBackground(func() { h.probeEarliestBlock(node, probeURL, height) })
+	}
+}
+
+// probeEarliestBlock finds and stores the earliest block for a node. Failed
+// probes are retried after earliestBlockRetry rather than on every check.
+func (h *HealthChecker) probeEarliestBlock(node NodeConfig, url string, latest uint64) {
+	defer h.recoverCheckPanic(node, nil)
+	prober, ok := h.evmHandler.(EarliestBlockProber)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(h.ctx, earliestBlockProbeTimeout)
+	defer cancel()
+
+	earliest, err := prober.GetEarliestBlock(ctx, url, latest)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	state := h.earliestBlocks[node.key()]
+	state.probing = false
+	if err != nil {
+		state.nextProbe = time.Now().Add(earliestBlockRetry)
+		h.logger.Debug("earliest block probe failed",
+			zap.String("node", node.Name),
+			zap.Error(err))
+		return
+	}
+
+	state.height = earliest
+	state.known = true
+	state.nextProbe = time.Now().Add(earliestBlockRefresh)
+	h.logger.Debug("earliest block probed",
+		zap.String("node", node.Name),
+		zap.Uint64("earliest_block", earliest))
+}
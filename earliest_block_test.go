@@ -0,0 +1,156 @@
+package blockchain_health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// createPrunedEVMServer serves blocks from earliest to latest plus genesis when
+// keepGenesis is set; older blocks return null, or a JSON-RPC error when
+// rpcErrors is set
+func createPrunedEVMServer(t *testing.T, earliest, latest uint64, rpcErrors, keepGenesis bool) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req EVMJSONRPCRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+
+		switch req.Method {
+		case "eth_blockNumber":
+			_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":"0x%x"}`, latest)
+		case "eth_getBlockByNumber":
+			number, _ := strconv.ParseUint(strings.TrimPrefix(req.Params[0].(string), "0x"), 16, 64)
+			switch {
+			case number >= earliest && number <= latest, number == 0 && keepGenesis:
+				_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":{"number":"0x%x"}}`, number)
+			case rpcErrors:
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"history pruned"}}`))
+			default:
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":null}`))
+			}
+		}
+	}))
+}
+
+func TestEVMHandler_GetEarliestBlock(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	tests := []struct {
+		name        string
+		earliest    uint64
+		rpcErrors   bool
+		keepGenesis bool
+	}{
+		{name: "full history", earliest: 0},
+		{name: "pruned with null blocks", earliest: 700},
+		{name: "pruned with rpc errors", earliest: 123, rpcErrors: true},
+		{name: "expired history keeps genesis", earliest: 500, keepGenesis: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := createPrunedEVMServer(t, tt.earliest, 1000, tt.rpcErrors, tt.keepGenesis)
+			defer server.Close()
+
+			handler := NewEVMHandler(5*time.Second, logger)
+			earliest, err := handler.GetEarliestBlock(context.Background(), server.URL, 1000)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if earliest != tt.earliest {
+				t.Errorf("Expected earliest block %d, got %d", tt.earliest, earliest)
+			}
+		})
+	}
+}
+
+func TestHealthChecker_TracksEarliestBlock(t *testing.T) {
+	server := createPrunedEVMServer(t, 400, 1000, false, false)
+	defer server.Close()
+
+	config := &Config{
+		BlockValidation: BlockValidationConfig{TrackEarliestBlock: true},
+	}
+	checker := NewHealthChecker(config, NewHealthCache(time.Second), nil, zaptest.NewLogger(t))
+	node := NodeConfig{Name: "evm-pruned", URL: server.URL, Type: NodeTypeEVM}
+
+	// The first check starts the probe in the background
+	checker.trackEarliestBlock(node, &NodeHealth{Name: node.Name, Healthy: true, BlockHeight: 1000})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		checker.mutex.RLock()
+		state := checker.earliestBlocks[node.key()]
+		done := state != nil && !state.probing
+		checker.mutex.RUnlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for earliest block probe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Later checks report the probed horizon
+	health := &NodeHealth{Name: node.Name, Healthy: true, BlockHeight: 1000}
+	checker.trackEarliestBlock(node, health)
+	if health.EarliestBlockHeight != 400 || !health.Pruned {
+		t.Errorf("Expected pruned node with earliest block 400, got %d (pruned=%v)", health.EarliestBlockHeight, health.Pruned)
+	}
+}
+
+func TestHealthChecker_EarliestBlockProbeBackoff(t *testing.T) {
+	var probes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		BlockValidation: BlockValidationConfig{TrackEarliestBlock: true},
+	}
+	checker := NewHealthChecker(config, NewHealthCache(time.Second), nil, zaptest.NewLogger(t))
+	defer checker.Stop()
+	node := NodeConfig{Name: "evm-down", URL: server.URL, Type: NodeTypeEVM}
+
+	checker.trackEarliestBlock(node, &NodeHealth{Name: node.Name, Healthy: true, BlockHeight: 1000})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		checker.mutex.RLock()
+		done := !checker.earliestBlocks[node.key()].probing
+		checker.mutex.RUnlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for earliest block probe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A failed probe is not retried on the next check
+	before := probes.Load()
+	checker.trackEarliestBlock(node, &NodeHealth{Name: node.Name, Healthy: true, BlockHeight: 1000})
+	checker.mutex.RLock()
+	probing := checker.earliestBlocks[node.key()].probing
+	checker.mutex.RUnlock()
+	if probing || probes.Load() != before {
+		t.Error("Expected failed earliest block probe to back off")
+	}
+}
@@ -0,0 +1,179 @@
+package blockchain_health
+
+import (
+	"sync"
+	"time"
+)
+
+// Error rate window defaults
+const (
+	defaultErrorRateWindow     = 5 * time.Minute
+	defaultErrorRateMinSamples = 5
+
+	// errorRateBuckets is the number of buckets the window is split into
+	errorRateBuckets = 10
+)
+
+// errorRateBucket counts outcomes for one slice of the window
+type errorRateBucket struct {
+	start    time.Time
+	total    int
+	failures int
+}
+
+// errorRateWindow tracks success/failure outcomes over a sliding time window.
+// Outcomes are aggregated into fixed buckets so memory stays bounded no matter
+// how many health check outcomes are recorded.
+type errorRateWindow struct {
+	width   time.Duration
+	buckets [errorRateBuckets]errorRateBucket
+	mutex   sync.Mutex
+}
+
+// newErrorRateWindow creates a window covering the given duration
+func newErrorRateWindow(window time.Duration) *errorRateWindow {
+	width := window / errorRateBuckets
+	if width <= 0 {
+		width = time.Second
+	}
+	return &errorRateWindow{width: width}
+}
+
+// record adds an outcome at the current time
+func (w *errorRateWindow) record(failed bool) {
+	w.recordAt(time.Now(), failed)
+}
+
+// recordAt adds an outcome at the given time
+func (w *errorRateWindow) recordAt(now time.Time, failed bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	start := now.Truncate(w.width)
+	bucket := &w.buckets[(start.UnixNano()/int64(w.width))%errorRateBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = errorRateBucket{start: start}
+	}
+	bucket.total++
+	if failed {
+		bucket.failures++
+	}
+}
+
+// rate returns the failure ratio and number of outcomes in the window
+func (w *errorRateWindow) rate() (float64, int) {
+	return w.rateAt(time.Now())
+}
+
+// rateAt returns the failure ratio and number of outcomes in the window ending at now
+func (w *errorRateWindow) rateAt(now time.Time) (float64, int) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	oldest := now.Truncate(w.width).Add(-w.width * (errorRateBuckets - 1))
+	var total, failures int
+	for _, bucket := range w.buckets {
+		if bucket.total == 0 || bucket.start.Before(oldest) {
+			continue
+		}
+		total += bucket.total
+		failures += bucket.failures
+	}
+
+	if total == 0 {
+		return 0, 0
+	}
+	return float64(failures) / float64(total), total
+}
+
+// reset clears all recorded outcomes
+func (w *errorRateWindow) reset() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.buckets = [errorRateBuckets]errorRateBucket{}
+}
+
+// errorRateWindowDuration returns the configured error rate window
+func (h *HealthChecker) errorRateWindowDuration() time.Duration {
+	if d, err := time.ParseDuration(h.config.FailureHandling.ErrorRateWindow); err == nil && d > 0 {
+		return d
+	}
+	return defaultErrorRateWindow
+}
+
+// errorRateMinSamples returns the number of outcomes required before the
+// error rate may open the circuit breaker
+func (h *HealthChecker) errorRateMinSamples() int {
+	if h.config.FailureHandling.ErrorRateMinSamples > 0 {
+		return h.config.FailureHandling.ErrorRateMinSamples
+	}
+	return defaultErrorRateMinSamples
+}
+
+// getErrorWindow gets or creates the error rate window for a node
+func (h *HealthChecker) getErrorWindow(nodeName string) *errorRateWindow {
+	key := h.nodeKey(nodeName)
+	h.mutex.RLock()
+	window, exists := h.errorWindows[key]
+	h.mutex.RUnlock()
+
+	if !exists {
+		h.mutex.Lock()
+		// Double-check after acquiring write lock
+		if window, exists = h.errorWindows[key]; !exists {
+			window = newErrorRateWindow(h.errorRateWindowDuration())
+			h.errorWindows[key] = window
+		}
+		h.mutex.Unlock()
+	}
+
+	return window
+}
+
+// recordCheckOutcome records the result of a completed (non-cached) probe and
+// feeds the windowed error rate into the node's circuit breaker
+func (h *HealthChecker) recordCheckOutcome(nodeName string, healthy bool) {
+	breaker := h.getCircuitBreaker(nodeName)
+	window := h.getErrorWindow(nodeName)
+
+	if healthy {
+		// A node recovering from an open breaker starts with a clean window so
+		// old failures do not immediately trip it again
+		if breaker.GetState() == CircuitHalfOpen {
+			window.reset()
+		}
+		breaker.RecordSuccess()
+	} else {
+		breaker.RecordFailure()
+	}
+
+	window.record(!healthy)
+	rate, samples := window.rate()
+	breaker.RecordErrorRate(rate, samples)
+	h.updateErrorRateMetric(nodeName, rate)
+
+	// Open breakers skip checks, so an open breaker here just tripped
+	if breaker.GetState() == CircuitOpen {
+		h.reportBreakerTrip(nodeName)
+	}
+}
+
+// errorRate returns the windowed failure ratio for a node (0 when unknown)
+func (h *HealthChecker) errorRate(nodeName string) float64 {
+	h.mutex.RLock()
+	window, exists := h.errorWindows[h.nodeKey(nodeName)]
+	h.mutex.RUnlock()
+
+	if !exists {
+		return 0
+	}
+	rate, _ := window.rate()
+	return rate
+}
+
+// updateErrorRateMetric exports the windowed error rate for a node
+func (h *HealthChecker) updateErrorRateMetric(nodeName string, rate float64) {
+	if h.metrics != nil {
+		h.metrics.nodeErrorRate.WithLabelValues(nodeName).Set(rate)
+	}
+}
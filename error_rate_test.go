@@ -0,0 +1,91 @@
+package blockchain_health
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestErrorRateWindow_SlidesOutOldOutcomes(t *testing.T) {
+	w := newErrorRateWindow(10 * time.Second)
+	now := time.Unix(1000, 0)
+
+	w.recordAt(now, true)
+	w.recordAt(now, true)
+	w.recordAt(now.Add(5*time.Second), false)
+
+	rate, samples := w.rateAt(now.Add(5 * time.Second))
+	if samples != 3 {
+		t.Fatalf("Expected 3 samples, got %d", samples)
+	}
+	if rate < 0.66 || rate > 0.67 {
+		t.Errorf("Expected error rate ~0.67, got %f", rate)
+	}
+
+	// The two failures fall out of the window after it has fully elapsed
+	rate, samples = w.rateAt(now.Add(12 * time.Second))
+	if samples != 1 || rate != 0 {
+		t.Errorf("Expected 1 successful sample, got %d samples with rate %f", samples, rate)
+	}
+
+	rate, samples = w.rateAt(now.Add(time.Minute))
+	if samples != 0 || rate != 0 {
+		t.Errorf("Expected empty window, got %d samples with rate %f", samples, rate)
+	}
+}
+
+func TestErrorRateCircuitBreaker_OpensOnRatio(t *testing.T) {
+	cb := NewErrorRateCircuitBreaker(0.5, 4)
+
+	// Consecutive failures alone do not open an error rate breaker
+	cb.RecordFailure()
+	cb.RecordFailure()
+	cb.RecordFailure()
+	if cb.GetState() != CircuitClosed {
+		t.Fatalf("Expected CircuitClosed, got %v", cb.GetState())
+	}
+
+	// Not enough samples yet
+	cb.RecordErrorRate(1, 3)
+	if cb.GetState() != CircuitClosed {
+		t.Fatalf("Expected CircuitClosed below min samples, got %v", cb.GetState())
+	}
+
+	// Below the failure ratio
+	cb.RecordErrorRate(0.4, 10)
+	if cb.GetState() != CircuitClosed {
+		t.Fatalf("Expected CircuitClosed below ratio, got %v", cb.GetState())
+	}
+
+	cb.RecordErrorRate(0.5, 4)
+	if cb.GetState() != CircuitOpen {
+		t.Errorf("Expected CircuitOpen, got %v", cb.GetState())
+	}
+	if cb.CanExecute() {
+		t.Error("Expected CanExecute=false when circuit is open")
+	}
+}
+
+func TestHealthChecker_IntermittentFailuresOpenBreaker(t *testing.T) {
+	config := &Config{
+		FailureHandling: FailureHandlingConfig{
+			CircuitBreakerThreshold: 0.5,
+			ErrorRateMinSamples:     4,
+		},
+	}
+	checker := NewHealthChecker(config, NewHealthCache(time.Second), nil, zaptest.NewLogger(t))
+
+	// Alternating outcomes never produce consecutive failures but do reach a
+	// 50% error rate
+	for _, healthy := range []bool{false, true, false, true} {
+		checker.recordCheckOutcome("flaky", healthy)
+	}
+
+	if state := checker.getCircuitBreaker("flaky").GetState(); state != CircuitOpen {
+		t.Errorf("Expected CircuitOpen for flaky node, got %v", state)
+	}
+	if rate := checker.errorRate("flaky"); rate != 0.5 {
+		t.Errorf("Expected error rate 0.5, got %f", rate)
+	}
+}
@@ -0,0 +1,83 @@
+package blockchain_health
+
+import (
+	"sync"
+	"time"
+)
+
+// maxErrorSamples is the number of distinct errors kept per node
+const maxErrorSamples = 5
+
+// ErrorSample is a distinct error a node's health checks failed with
+type ErrorSample struct {
+	Error     string    `json:"error"`
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// errorSamples keeps the last maxErrorSamples distinct errors of a node, most
+// recent first. A single last error hides failures that alternate, e.g. a
+// node timing out between lagging checks.
+type errorSamples struct {
+	samples []ErrorSample
+	mutex   sync.Mutex
+}
+
+// record notes an error seen at now. A repeated error moves to the front
+// instead of taking another slot.
+func (s *errorSamples) record(err string, now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sample := ErrorSample{Error: err, FirstSeen: now}
+	for i, existing := range s.samples {
+		if existing.Error == err {
+			sample = existing
+			s.samples = append(s.samples[:i], s.samples[i+1:]...)
+			break
+		}
+	}
+	sample.Count++
+	sample.LastSeen = now
+
+	s.samples = append([]ErrorSample{sample}, s.samples...)
+	if len(s.samples) > maxErrorSamples {
+		s.samples = s.samples[:maxErrorSamples]
+	}
+}
+
+// list returns a copy of the samples, most recent first
+func (s *errorSamples) list() []ErrorSample {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.samples) == 0 {
+		return nil
+	}
+	return append([]ErrorSample(nil), s.samples...)
+}
+
+// recordErrorSample keeps the error of a node's failed probe
+func (h *HealthChecker) recordErrorSample(nodeName, err string) {
+	key := h.nodeKey(nodeName)
+	h.mutex.Lock()
+	samples, exists := h.errorSamples[key]
+	if !exists {
+		samples = &errorSamples{}
+		h.errorSamples[key] = samples
+	}
+	h.mutex.Unlock()
+
+	samples.record(err, time.Now())
+}
+
+// recentErrors returns the last distinct errors of a node, most recent first
+func (h *HealthChecker) recentErrors(nodeName string) []ErrorSample {
+	h.mutex.RLock()
+	samples, exists := h.errorSamples[h.nodeKey(nodeName)]
+	h.mutex.RUnlock()
+	if !exists {
+		return nil
+	}
+	return samples.list()
+}
@@ -0,0 +1,43 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestErrorSamples_DistinctAndBounded(t *testing.T) {
+	var s errorSamples
+	now := time.Unix(1000, 0)
+
+	s.record("timeout", now)
+	s.record("lagging", now.Add(time.Second))
+	s.record("timeout", now.Add(2*time.Second))
+
+	// A repeated error moves to the front instead of taking another slot
+	samples := s.list()
+	if len(samples) != 2 {
+		t.Fatalf("Expected 2 distinct errors, got %+v", samples)
+	}
+	if samples[0].Error != "timeout" || samples[0].Count != 2 ||
+		!samples[0].FirstSeen.Equal(now) || !samples[0].LastSeen.Equal(now.Add(2*time.Second)) {
+		t.Errorf("Expected the repeated timeout first, got %+v", samples[0])
+	}
+	if samples[1].Error != "lagging" || samples[1].Count != 1 {
+		t.Errorf("Expected the lagging error second, got %+v", samples[1])
+	}
+
+	// The oldest errors are dropped past maxErrorSamples
+	for i := 0; i < maxErrorSamples; i++ {
+		s.record(fmt.Sprintf("error %d", i), now.Add(time.Duration(3+i)*time.Second))
+	}
+	samples = s.list()
+	if len(samples) != maxErrorSamples || samples[0].Error != fmt.Sprintf("error %d", maxErrorSamples-1) {
+		t.Errorf("Expected the last %d errors, got %+v", maxErrorSamples, samples)
+	}
+	for _, sample := range samples {
+		if sample.Error == "timeout" || sample.Error == "lagging" {
+			t.Errorf("Expected %q to be dropped", sample.Error)
+		}
+	}
+}
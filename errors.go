@@ -0,0 +1,95 @@
+package blockchain_health
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Errors returned by GetUpstreams. Use errors.Is and errors.As to tell them
+// apart; the reason a request got no upstream is also kept in the
+// blockchain_health.no_upstreams request variable.
+var (
+	// ErrNoHealthyUpstreams means no node could serve the request
+	ErrNoHealthyUpstreams = errors.New("no healthy upstreams available")
+	// ErrNotProvisioned means the module was used before it was provisioned
+	ErrNotProvisioned = errors.New("blockchain_health upstream not provisioned")
+)
+
+// ErrChainDegraded means no node is healthy and the fallback strategy refused
+// to route to degraded nodes
+type ErrChainDegraded struct {
+	Chain string
+}
+
+// Error implements error
+func (e *ErrChainDegraded) Error() string {
+	if e.Chain == "" {
+		return "chain degraded: no healthy upstreams"
+	}
+	return fmt.Sprintf("chain %s degraded: no healthy upstreams", e.Chain)
+}
+
+// Upstream error causes, used as metric labels and in the cause variable
+const (
+	causeNotProvisioned     = "not_provisioned"
+	causeNoHealthyUpstreams = "no_healthy_upstreams"
+	causeChainDegraded      = "chain_degraded"
+	causeHealthCheckFailed  = "health_check_failed"
+)
+
+// upstreamErrorCause returns the cause label for an error from GetUpstreams
+func upstreamErrorCause(err error) string {
+	var degraded *ErrChainDegraded
+	switch {
+	case errors.Is(err, ErrNotProvisioned):
+		return causeNotProvisioned
+	case errors.As(err, &degraded):
+		return causeChainDegraded
+	case errors.Is(err, ErrNoHealthyUpstreams):
+		return causeNoHealthyUpstreams
+	default:
+		return causeHealthCheckFailed
+	}
+}
+
+// upstreamErrorStatus returns the HTTP status answered for an error from
+// GetUpstreams
+func upstreamErrorStatus(err error) int {
+	switch upstreamErrorCause(err) {
+	case causeNotProvisioned:
+		return http.StatusInternalServerError
+	case causeChainDegraded:
+		return http.StatusBadGateway
+	default:
+		return http.StatusServiceUnavailable
+	}
+}
+
+// chainName returns the chain the pool serves, for error messages. The
+// network is appended, as in osmosis-testnet, so pools of several networks of
+// one chain get their own default names.
+func (b *BlockchainHealthUpstream) chainName() string {
+	if b == nil || b.config == nil {
+		return ""
+	}
+	chain := b.config.Chain.ChainType
+	if chain == "" {
+		chain = b.config.Chain.ChainPreset
+	}
+	if chain == "" {
+		for _, node := range b.config.nodeList() {
+			if node.ChainType != "" {
+				chain = node.ChainType
+				break
+			}
+		}
+	}
+	if network := b.config.Chain.Network; network != "" {
+		if chain == "" {
+			return network
+		}
+		return chain + "-" + network
+	}
+	return chain
+}
@@ -0,0 +1,96 @@
+package blockchain_health
+
+import (
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyevents"
+	"go.uber.org/zap"
+)
+
+// Events emitted through Caddy's events app, for other modules to subscribe
+// to. Their origin is the blockchain_health app.
+const (
+	// eventNodeHealthy is emitted when an unhealthy node recovers
+	eventNodeHealthy = "node_healthy"
+
+	// eventNodeUnhealthy is emitted when a healthy node fails its check
+	eventNodeUnhealthy = "node_unhealthy"
+
+	// eventPoolStateChanged is emitted when a pool changes state
+	eventPoolStateChanged = "pool_state_changed"
+
+	// eventChainHalted is emitted when a chain stops producing blocks
+	eventChainHalted = "chain_halted"
+
+	// eventChainResumed is emitted when a halted chain produces a block
+	eventChainResumed = "chain_resumed"
+
+	// eventCheckerStalled is emitted when the background health checker of a
+	// pool stops completing checks
+	eventCheckerStalled = "checker_stalled"
+
+	// eventCheckerResumed is emitted when a stalled health checker completes
+	// a check again
+	eventCheckerResumed = "checker_resumed"
+
+	// eventNodeQuarantined is emitted when a node is quarantined for
+	// flapping or through the admin API
+	eventNodeQuarantined = "node_quarantined"
+
+	// eventNodeReleased is emitted when a node leaves quarantine
+	eventNodeReleased = "node_released"
+
+	// eventNodeReplaced is emitted when a replacement takes over a node
+	eventNodeReplaced = "node_replaced"
+
+	// eventReplacementFailed is emitted when a replacement fails its soak
+	eventReplacementFailed = "node_replacement_failed"
+)
+
+// eventEmitter emits events in the context of the module that provisioned
+// the pool
+type eventEmitter struct {
+	app *caddyevents.App
+	ctx caddy.Context
+}
+
+// newEventEmitter returns an emitter for the config's events app, or nil
+// when the context has no config
+func newEventEmitter(ctx caddy.Context) *eventEmitter {
+	module, ok := loadCaddyApp(ctx, "events")
+	if !ok {
+		return nil
+	}
+	app, ok := module.(*caddyevents.App)
+	if !ok {
+		return nil
+	}
+	return &eventEmitter{app: app, ctx: ctx}
+}
+
+// emit dispatches an event to its subscribers. Subscribers run
+// synchronously, so a slow one delays the pool's next check.
+func (h *HealthChecker) emit(name string, data map[string]any) {
+	if h.events == nil {
+		return
+	}
+	h.logger.Debug("emitting event", zap.String("event", name))
+	h.events.app.Emit(h.events.ctx, name, data)
+}
+
+// emitHealthChanges emits an event for each node whose health changed.
+// Candidates are reported too, so subscribers can follow their evaluation.
+func (h *HealthChecker) emitHealthChanges(changed []*NodeHealth) {
+	for _, health := range changed {
+		name := eventNodeUnhealthy
+		if health.Healthy {
+			name = eventNodeHealthy
+		}
+		h.emit(name, map[string]any{
+			"pool":         h.poolName(),
+			"node":         health.Name,
+			"candidate":    h.isCandidate(health.Name),
+			"block_height": health.BlockHeight,
+			"error":        health.LastError,
+		})
+	}
+}
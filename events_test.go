@@ -0,0 +1,100 @@
+package blockchain_health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyevents"
+	"go.uber.org/zap/zaptest"
+)
+
+// eventRecorder is an events handler recording the events it receives
+type eventRecorder struct {
+	mutex  sync.Mutex
+	events []caddy.Event
+}
+
+func (r *eventRecorder) Handle(_ context.Context, e caddy.Event) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, e)
+	return nil
+}
+
+func TestHealthChecker_EmitsEvents(t *testing.T) {
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	defer cancel()
+	events := &caddyevents.App{}
+	if err := events.Provision(ctx); err != nil {
+		t.Fatalf("provisioning events: %v", err)
+	}
+	recorder := &eventRecorder{}
+	for _, name := range []string{eventNodeHealthy, eventNodeUnhealthy, eventPoolStateChanged} {
+		if err := events.On(name, recorder); err != nil {
+			t.Fatalf("subscribing to %s: %v", name, err)
+		}
+	}
+
+	upstream := createTestUpstream(nil, zaptest.NewLogger(t))
+	checker := upstream.healthChecker
+	checker.events = &eventEmitter{app: events, ctx: ctx}
+	state, err := registerChainState("events-test", checker)
+	if err != nil {
+		t.Fatalf("registering pool state: %v", err)
+	}
+	defer releaseChainState(state, checker)
+	checker.state = state
+
+	// A first check is not a transition; later changes are
+	checker.processResults([]*NodeHealth{{Name: "a", Healthy: true, BlockHeight: 10}})
+	checker.processResults([]*NodeHealth{{Name: "a", LastError: "connection refused"}})
+	checker.processResults([]*NodeHealth{{Name: "a", Healthy: true, BlockHeight: 12}})
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	var got []string
+	for _, e := range recorder.events {
+		switch e.Name() {
+		case eventNodeHealthy, eventNodeUnhealthy:
+			if e.Data["node"] != "a" || e.Data["pool"] != "events-test" {
+				t.Errorf("unexpected event data: %v", e.Data)
+			}
+			if e.Name() == eventNodeUnhealthy && e.Data["error"] != "connection refused" {
+				t.Errorf("expected the check error, got %v", e.Data["error"])
+			}
+			got = append(got, e.Name())
+		case eventPoolStateChanged:
+			got = append(got, fmt.Sprintf("%v:%v->%v", e.Data["pool"], e.Data["from"], e.Data["to"]))
+		}
+	}
+	want := []string{eventNodeUnhealthy, "events-test:healthy->down", eventNodeHealthy, "events-test:down->healthy"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected events %v, got %v", want, got)
+	}
+}
+
+func TestNewEventEmitter(t *testing.T) {
+	if err := caddy.Load([]byte(`{"admin": {"disabled": true}, "apps": {"events": {}}}`), true); err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	defer func() { _ = caddy.Stop() }()
+
+	ctx := caddy.ActiveContext()
+	events, err := ctx.App("events")
+	if err != nil {
+		t.Fatalf("events app not loaded: %v", err)
+	}
+	if emitter := newEventEmitter(ctx); emitter == nil || emitter.app != events {
+		t.Error("expected an emitter for the config's events app")
+	}
+
+	// Outside a config there is nothing to emit to
+	standalone, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	defer cancel()
+	if newEventEmitter(standalone) != nil {
+		t.Error("expected no emitter outside a config")
+	}
+}
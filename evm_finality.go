@@ -0,0 +1,162 @@
+package blockchain_health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// defaultFinalizedThreshold is how many blocks an EVM node's finalized block
+// may trail the pool's before requests for finalized data avoid it. Two
+// Ethereum epochs, as finality advances an epoch at a time.
+const defaultFinalizedThreshold = 64
+
+// finalityHeader tags a request as reading finalized data. It takes the
+// block tags "finalized" or "safe".
+const finalityHeader = "X-Finality"
+
+// GetFinalizedHeight implements FinalityProber for EVM nodes using
+// eth_getBlockByNumber("finalized"). Chains without finality answer null or
+// an error.
+func (e *EVMHandler) GetFinalizedHeight(ctx context.Context, url string) (uint64, error) {
+	rpcResp, err := e.callJSONRPC(ctx, url, "eth_getBlockByNumber", []interface{}{"finalized", false})
+	if err != nil {
+		return 0, err
+	}
+	block, ok := rpcResp.Result.(map[string]interface{})
+	if !ok {
+		return 0, fmt.Errorf("no finalized block")
+	}
+	height, err := parseHexQuantity(block["number"])
+	if err != nil {
+		return 0, fmt.Errorf("parsing finalized block number: %w", err)
+	}
+	return height, nil
+}
+
+// collectFinalizedHeight records the finalized block of a healthy EVM node
+// when finality is tracked. Failures leave it unset.
+func (h *HealthChecker) collectFinalizedHeight(ctx context.Context, node NodeConfig, health *NodeHealth) {
+	if !h.config.BlockValidation.TrackFinality || node.Type != NodeTypeEVM || !health.Healthy {
+		return
+	}
+
+	probeURL := node.rpcURL()
+	prober, ok := h.evmHandler.(FinalityProber)
+	if !ok || probeURL == "" {
+		return
+	}
+
+	height, err := prober.GetFinalizedHeight(ctx, probeURL)
+	if err != nil {
+		h.logger.Debug("finalized block unavailable",
+			zap.String("node", node.Name),
+			zap.Error(err))
+		return
+	}
+	health.FinalizedHeight = height
+}
+
+// finalizedThreshold returns the allowed finalized block lag
+func (h *HealthChecker) finalizedThreshold() uint64 {
+	if h.config.BlockValidation.FinalizedThreshold > 0 {
+		return uint64(h.config.BlockValidation.FinalizedThreshold)
+	}
+	return defaultFinalizedThreshold
+}
+
+// validateFinalizedHeights marks EVM nodes whose finalized block trails the
+// best in the pool by more than the threshold, or is unknown while others
+// report one. Nodes ejected for trailing the head are compared too, since
+// they may still serve finalized data. If no node reports finality, none is
+// marked.
+func (h *HealthChecker) validateFinalizedHeights(nodes []*NodeHealth) {
+	var finalized uint64
+	for _, node := range nodes {
+		if !h.isCandidate(node.Name) && node.FinalizedHeight > finalized {
+			finalized = node.FinalizedHeight
+		}
+	}
+	if finalized == 0 {
+		return
+	}
+
+	threshold := h.finalizedThreshold()
+	for _, node := range nodes {
+		lagging := node.FinalizedHeight+threshold < finalized
+		if lagging && !node.FinalizedLagging {
+			h.logger.Debug("EVM node finality is lagging",
+				zap.String("node", node.Name),
+				zap.Uint64("finalized_height", node.FinalizedHeight),
+				zap.Uint64("pool_finalized_height", finalized))
+		}
+		node.FinalizedLagging = lagging
+	}
+}
+
+// servesFinalized reports whether a node keeps up with the pool's finalized
+// block
+func (n *NodeHealth) servesFinalized() bool {
+	return !n.FinalizedLagging
+}
+
+// headLagOnly reports whether a node was ejected only for trailing the pool
+// head, while its finalized block is known: it can still serve finalized data
+func (n *NodeHealth) headLagOnly() bool {
+	return !n.Healthy && !n.HeightValid && n.LastError == "" && !n.Throttled &&
+		n.BlockHeight > 0 && n.FinalizedHeight > 0 && (n.CatchingUp == nil || !*n.CatchingUp)
+}
+
+// isFinalizedRequest reports whether a request reads finalized data: it
+// carries the X-Finality header, or is a JSON-RPC call or batch naming the
+// "finalized" or "safe" block tag, directly or as the range of a log filter
+func isFinalizedRequest(r *http.Request) bool {
+	if r == nil {
+		return false
+	}
+	if tag := r.Header.Get(finalityHeader); tag != "" {
+		return isFinalityTag(tag)
+	}
+	if r.Method != http.MethodPost {
+		return false
+	}
+	body, _, err := readRPCBody(r, 0)
+	if err != nil {
+		return false
+	}
+	calls, _, err := parseRPCCalls(body)
+	if err != nil {
+		return false
+	}
+
+	for _, call := range calls {
+		var params []json.RawMessage
+		if json.Unmarshal(call.Params, &params) != nil {
+			continue
+		}
+		for _, param := range params {
+			var tag string
+			if json.Unmarshal(param, &tag) == nil && isFinalityTag(tag) {
+				return true
+			}
+			var filter struct {
+				FromBlock string `json:"fromBlock"`
+				ToBlock   string `json:"toBlock"`
+			}
+			if json.Unmarshal(param, &filter) == nil && (isFinalityTag(filter.FromBlock) || isFinalityTag(filter.ToBlock)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isFinalityTag reports whether a block tag names finalized data
+func isFinalityTag(tag string) bool {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	return tag == "finalized" || tag == "safe"
+}
@@ -0,0 +1,179 @@
+package blockchain_health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"go.uber.org/zap/zaptest"
+)
+
+// createFinalityEVMServer serves a synced EVM node at head with the given
+// finalized block. A zero finalized block answers null, as chains without
+// finality do.
+func createFinalityEVMServer(t *testing.T, head, finalized uint64) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req EVMJSONRPCRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case req.Method == "eth_blockNumber":
+			_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":"0x%x"}`, head)
+		case req.Method == "eth_syncing":
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":false}`))
+		case req.Method == "eth_getBlockByNumber" && len(req.Params) > 0 && req.Params[0] == "finalized" && finalized > 0:
+			_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":{"number":"0x%x"}}`, finalized)
+		default:
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":null}`))
+		}
+	}))
+}
+
+func TestEVMHandler_GetFinalizedHeight(t *testing.T) {
+	handler := NewEVMHandler(5*time.Second, zaptest.NewLogger(t))
+
+	finalizing := createFinalityEVMServer(t, 1000, 936)
+	defer finalizing.Close()
+	height, err := handler.GetFinalizedHeight(context.Background(), finalizing.URL)
+	if err != nil || height != 936 {
+		t.Errorf("expected finalized block 936, got %d (%v)", height, err)
+	}
+
+	// Chains without finality answer null
+	unsupported := createFinalityEVMServer(t, 1000, 0)
+	defer unsupported.Close()
+	if _, err := handler.GetFinalizedHeight(context.Background(), unsupported.URL); err == nil {
+		t.Error("expected an error for a chain without finality")
+	}
+}
+
+func TestValidateFinalizedHeights(t *testing.T) {
+	checker := createTestUpstream(nil, zaptest.NewLogger(t)).healthChecker
+
+	nodes := []*NodeHealth{
+		{Name: "leader", Healthy: true, FinalizedHeight: 1000},
+		{Name: "within", Healthy: true, FinalizedHeight: 936},
+		{Name: "behind", Healthy: true, FinalizedHeight: 900},
+		{Name: "unknown", Healthy: true},
+	}
+	checker.validateFinalizedHeights(nodes)
+	for _, node := range nodes {
+		if lagging := node.Name == "behind" || node.Name == "unknown"; node.FinalizedLagging != lagging {
+			t.Errorf("%s: expected lagging=%v", node.Name, lagging)
+		}
+	}
+
+	checker.config.BlockValidation.FinalizedThreshold = 100
+	checker.validateFinalizedHeights(nodes)
+	if nodes[2].FinalizedLagging {
+		t.Error("expected the configured threshold to be used")
+	}
+
+	// Pools where no node reports finality are left alone
+	none := []*NodeHealth{{Name: "a", Healthy: true}, {Name: "b", Healthy: true}}
+	checker.validateFinalizedHeights(none)
+	if none[0].FinalizedLagging || none[1].FinalizedLagging {
+		t.Error("expected no node to lag when none reports finality")
+	}
+}
+
+func TestIsFinalizedRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		body   string
+		want   bool
+	}{
+		{name: "header", header: "finalized", want: true},
+		{name: "header safe", header: "Safe", want: true},
+		{name: "header latest", header: "latest", body: `{"jsonrpc":"2.0","id":1,"method":"eth_getBalance","params":["0xabc","finalized"]}`},
+		{name: "block tag", body: `{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["finalized",false]}`, want: true},
+		{name: "call at safe", body: `{"jsonrpc":"2.0","id":1,"method":"eth_call","params":[{"to":"0xabc"},"safe"]}`, want: true},
+		{name: "log range", body: `{"jsonrpc":"2.0","id":1,"method":"eth_getLogs","params":[{"fromBlock":"0x1","toBlock":"finalized"}]}`, want: true},
+		{name: "batch", body: `[{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]},{"jsonrpc":"2.0","id":2,"method":"eth_getBlockByNumber","params":["finalized",false]}]`, want: true},
+		{name: "latest", body: `{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["latest",false]}`},
+		{name: "not JSON-RPC", body: `finalized`},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+		if tt.header != "" {
+			r.Header.Set(finalityHeader, tt.header)
+		}
+		if got := isFinalizedRequest(r); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestFinalizedRequestsRouteByFinalizedLag(t *testing.T) {
+	leader := createFinalityEVMServer(t, 1000, 936)
+	defer leader.Close()
+	headLag := createFinalityEVMServer(t, 980, 936)
+	defer headLag.Close()
+	finalityLag := createFinalityEVMServer(t, 1000, 800)
+	defer finalityLag.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "leader", URL: leader.URL, Type: NodeTypeEVM, ChainType: "test-evm", Weight: 1},
+		{Name: "head-lag", URL: headLag.URL, Type: NodeTypeEVM, ChainType: "test-evm", Weight: 1},
+		{Name: "finality-lag", URL: finalityLag.URL, Type: NodeTypeEVM, ChainType: "test-evm", Weight: 1},
+	}, zaptest.NewLogger(t))
+	upstream.config.BlockValidation.TrackFinality = true
+
+	dials := func(r *http.Request) []string {
+		t.Helper()
+		upstreams, err := upstream.GetUpstreams(r)
+		if err != nil {
+			t.Fatalf("GetUpstreams failed: %v", err)
+		}
+		var dials []string
+		for _, up := range upstreams {
+			dials = append(dials, up.Dial)
+		}
+		return dials
+	}
+	host := getDynamicTestHostFromURL
+
+	// Head reads skip the node trailing the head
+	latest := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`))
+	if got := dials(latest); len(got) != 2 || got[0] != host(leader.URL) || got[1] != host(finalityLag.URL) {
+		t.Errorf("expected the leader and the finality-lagging node for head reads, got %v", got)
+	}
+
+	// Finalized reads skip the node trailing finality instead
+	finalized := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["finalized",false]}`))
+	if got := dials(finalized); len(got) != 2 || got[0] != host(leader.URL) || got[1] != host(headLag.URL) {
+		t.Errorf("expected the leader and the head-lagging node for finalized reads, got %v", got)
+	}
+}
+
+func TestTrackFinality_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:8545
+			type evm
+		}
+		track_finality
+		finalized_threshold 128
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if !b.BlockValidation.TrackFinality || b.BlockValidation.FinalizedThreshold != 128 {
+		t.Errorf("unexpected block validation config: %+v", b.BlockValidation)
+	}
+
+	b.BlockValidation.FinalizedThreshold = -1
+	if err := b.validate(); err == nil {
+		t.Error("expected a negative threshold to be rejected")
+	}
+}
@@ -0,0 +1,328 @@
+package blockchain_health
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// errInvalidTransaction is returned for raw transactions that cannot be decoded
+var errInvalidTransaction = errors.New("invalid raw transaction")
+
+// transactionSender recovers the sender address of a signed raw transaction,
+// as sent to eth_sendRawTransaction: legacy (with or without EIP-155 replay
+// protection) or typed (EIP-2718). The address is lowercase hex with 0x.
+func transactionSender(raw []byte) (string, error) {
+	if len(raw) == 0 {
+		return "", errInvalidTransaction
+	}
+
+	var payload []byte
+	var sig [][]byte
+	var recid uint64
+
+	if raw[0] >= 0xc0 {
+		// Legacy: [nonce, gasPrice, gas, to, value, data, v, r, s]
+		items, err := rlpListItems(raw)
+		if err != nil || len(items) != 9 {
+			return "", errInvalidTransaction
+		}
+		v := new(big.Int).SetBytes(items[6].content)
+		var unsigned []byte
+		for _, item := range items[:6] {
+			unsigned = append(unsigned, item.raw...)
+		}
+		switch {
+		case v.Cmp(big.NewInt(27)) == 0 || v.Cmp(big.NewInt(28)) == 0:
+			recid = v.Uint64() - 27
+		case v.Cmp(big.NewInt(35)) >= 0:
+			// EIP-155: v = chainId*2 + 35 + recid
+			chainID := new(big.Int).Sub(v, big.NewInt(35))
+			recid = uint64(chainID.Bit(0))
+			chainID.Rsh(chainID, 1)
+			unsigned = append(unsigned, rlpEncodeBytes(chainID.Bytes())...)
+			unsigned = append(unsigned, 0x80, 0x80)
+		default:
+			return "", errInvalidTransaction
+		}
+		payload = append(rlpListHeader(len(unsigned)), unsigned...)
+		sig = [][]byte{items[7].content, items[8].content}
+	} else {
+		// Typed: type || rlp([..., yParity, r, s])
+		txType := raw[0]
+		if txType > 0x7f {
+			return "", errInvalidTransaction
+		}
+		items, err := rlpListItems(raw[1:])
+		if err != nil {
+			return "", errInvalidTransaction
+		}
+		// Blob transactions are broadcast wrapped with their blobs
+		if txType == 0x03 && len(items) > 0 && items[0].list {
+			if items, err = rlpListItems(items[0].raw); err != nil {
+				return "", errInvalidTransaction
+			}
+		}
+		if len(items) < 4 {
+			return "", errInvalidTransaction
+		}
+		signed := len(items) - 3
+		parity := new(big.Int).SetBytes(items[signed].content)
+		if parity.Cmp(big.NewInt(1)) > 0 {
+			return "", errInvalidTransaction
+		}
+		recid = parity.Uint64()
+		var unsigned []byte
+		for _, item := range items[:signed] {
+			unsigned = append(unsigned, item.raw...)
+		}
+		payload = append([]byte{txType}, append(rlpListHeader(len(unsigned)), unsigned...)...)
+		sig = [][]byte{items[signed+1].content, items[signed+2].content}
+	}
+
+	pub, err := recoverPublicKey(keccak256(payload), new(big.Int).SetBytes(sig[0]), new(big.Int).SetBytes(sig[1]), recid)
+	if err != nil {
+		return "", err
+	}
+	return "0x" + hex.EncodeToString(keccak256(pub)[12:]), nil
+}
+
+// keccak256 returns the Keccak-256 hash used by Ethereum
+func keccak256(data []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// rlpItem is one decoded RLP item
+type rlpItem struct {
+	raw     []byte // the full encoding
+	content []byte // the payload without the prefix
+	list    bool
+}
+
+// rlpSplit decodes the first RLP item of b and returns it with the rest
+func rlpSplit(b []byte) (rlpItem, []byte, error) {
+	if len(b) == 0 {
+		return rlpItem{}, nil, errInvalidTransaction
+	}
+	prefix := b[0]
+	var offset, length int
+	list := false
+	switch {
+	case prefix < 0x80:
+		return rlpItem{raw: b[:1], content: b[:1]}, b[1:], nil
+	case prefix <= 0xb7:
+		offset, length = 1, int(prefix-0x80)
+	case prefix <= 0xbf:
+		offset, length = rlpLongLength(b, int(prefix-0xb7))
+	case prefix <= 0xf7:
+		offset, length, list = 1, int(prefix-0xc0), true
+	default:
+		offset, length = rlpLongLength(b, int(prefix-0xf7))
+		list = true
+	}
+	if offset == 0 || length < 0 || offset+length > len(b) {
+		return rlpItem{}, nil, errInvalidTransaction
+	}
+	end := offset + length
+	return rlpItem{raw: b[:end], content: b[offset:end], list: list}, b[end:], nil
+}
+
+// rlpLongLength reads a big-endian length of size bytes following the prefix.
+// It returns a zero offset if the length is malformed.
+func rlpLongLength(b []byte, size int) (int, int) {
+	if size > 4 || 1+size > len(b) {
+		return 0, 0
+	}
+	length := 0
+	for _, c := range b[1 : 1+size] {
+		length = length<<8 | int(c)
+	}
+	return 1 + size, length
+}
+
+// rlpListItems decodes an RLP list into its items
+func rlpListItems(b []byte) ([]rlpItem, error) {
+	list, rest, err := rlpSplit(b)
+	if err != nil || !list.list || len(rest) != 0 {
+		return nil, errInvalidTransaction
+	}
+	var items []rlpItem
+	for content := list.content; len(content) > 0; {
+		var item rlpItem
+		if item, content, err = rlpSplit(content); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// rlpListHeader returns the prefix of a list with a payload of n bytes
+func rlpListHeader(n int) []byte {
+	if n < 56 {
+		return []byte{0xc0 + byte(n)}
+	}
+	size := big.NewInt(int64(n)).Bytes()
+	return append([]byte{0xf7 + byte(len(size))}, size...)
+}
+
+// rlpEncodeBytes encodes a short byte string such as an integer
+func rlpEncodeBytes(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return b
+	}
+	return append([]byte{0x80 + byte(len(b))}, b...)
+}
+
+// secp256k1 curve parameters (y² = x³ + 7 over the field of size p)
+var (
+	secpP, _  = new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F", 16)
+	secpN, _  = new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141", 16)
+	secpGx, _ = new(big.Int).SetString("79BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798", 16)
+	secpGy, _ = new(big.Int).SetString("483ADA7726A3C4655DA4FBFC0E1108A8FD17B448A68554199C47D08FFB10D4B8", 16)
+)
+
+// recoverPublicKey returns the uncompressed public key (X || Y, 64 bytes)
+// that produced the signature (r, s) with recovery id recid over hash
+func recoverPublicKey(hash []byte, r, s *big.Int, recid uint64) ([]byte, error) {
+	if r.Sign() <= 0 || r.Cmp(secpN) >= 0 || s.Sign() <= 0 || s.Cmp(secpN) >= 0 || recid > 1 {
+		return nil, fmt.Errorf("%w: signature out of range", errInvalidTransaction)
+	}
+
+	// R is the curve point with x = r and the parity of y given by recid
+	ySquared := new(big.Int).Exp(r, big.NewInt(3), secpP)
+	ySquared.Add(ySquared, big.NewInt(7)).Mod(ySquared, secpP)
+	exponent := new(big.Int).Add(secpP, big.NewInt(1))
+	exponent.Rsh(exponent, 2)
+	y := new(big.Int).Exp(ySquared, exponent, secpP)
+	if new(big.Int).Exp(y, big.NewInt(2), secpP).Cmp(ySquared) != 0 {
+		return nil, fmt.Errorf("%w: signature not on the curve", errInvalidTransaction)
+	}
+	if y.Bit(0) != uint(recid) {
+		y.Sub(secpP, y)
+	}
+
+	// Q = r⁻¹(sR − eG)
+	rInv := new(big.Int).ModInverse(r, secpN)
+	e := new(big.Int).SetBytes(hash)
+	u1 := new(big.Int).Mul(e, rInv)
+	u1.Neg(u1).Mod(u1, secpN)
+	u2 := new(big.Int).Mul(s, rInv)
+	u2.Mod(u2, secpN)
+
+	q := secpAdd(secpMul(secpPoint(secpGx, secpGy), u1), secpMul(secpPoint(r, y), u2))
+	x, qy, ok := q.affine()
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid signature", errInvalidTransaction)
+	}
+	pub := make([]byte, 64)
+	x.FillBytes(pub[:32])
+	qy.FillBytes(pub[32:])
+	return pub, nil
+}
+
+// jacobianPoint is a secp256k1 point in Jacobian coordinates; Z = 0 is the
+// point at infinity
+type jacobianPoint struct {
+	x, y, z *big.Int
+}
+
+// secpPoint returns the affine point (x, y)
+func secpPoint(x, y *big.Int) jacobianPoint {
+	return jacobianPoint{new(big.Int).Set(x), new(big.Int).Set(y), big.NewInt(1)}
+}
+
+// affine converts the point back to affine coordinates
+func (p jacobianPoint) affine() (*big.Int, *big.Int, bool) {
+	if p.z.Sign() == 0 {
+		return nil, nil, false
+	}
+	zInv := new(big.Int).ModInverse(p.z, secpP)
+	zInv2 := new(big.Int).Mul(zInv, zInv)
+	x := new(big.Int).Mul(p.x, zInv2)
+	x.Mod(x, secpP)
+	y := new(big.Int).Mul(p.y, zInv2.Mul(zInv2, zInv))
+	y.Mod(y, secpP)
+	return x, y, true
+}
+
+// Field arithmetic modulo p, returning new values
+func fieldMul(a, b *big.Int) *big.Int { return new(big.Int).Mod(new(big.Int).Mul(a, b), secpP) }
+func fieldAdd(a, b *big.Int) *big.Int { return new(big.Int).Mod(new(big.Int).Add(a, b), secpP) }
+func fieldSub(a, b *big.Int) *big.Int { return new(big.Int).Mod(new(big.Int).Sub(a, b), secpP) }
+func fieldScale(a *big.Int, k int64) *big.Int {
+	return new(big.Int).Mod(new(big.Int).Mul(a, big.NewInt(k)), secpP)
+}
+
+// secpInfinity returns the point at infinity
+func secpInfinity() jacobianPoint {
+	return jacobianPoint{big.NewInt(0), big.NewInt(0), big.NewInt(0)}
+}
+
+// secpDouble returns 2P (dbl-2009-l, for curves with a = 0)
+func secpDouble(p jacobianPoint) jacobianPoint {
+	if p.z.Sign() == 0 || p.y.Sign() == 0 {
+		return secpInfinity()
+	}
+	a := fieldMul(p.x, p.x)
+	b := fieldMul(p.y, p.y)
+	c := fieldMul(b, b)
+	xb := fieldAdd(p.x, b)
+	d := fieldScale(fieldSub(fieldSub(fieldMul(xb, xb), a), c), 2)
+	e := fieldScale(a, 3)
+	f := fieldMul(e, e)
+	x := fieldSub(f, fieldScale(d, 2))
+	y := fieldSub(fieldMul(e, fieldSub(d, x)), fieldScale(c, 8))
+	z := fieldScale(fieldMul(p.y, p.z), 2)
+	return jacobianPoint{x, y, z}
+}
+
+// secpAdd returns P + Q (add-2007-bl)
+func secpAdd(p, q jacobianPoint) jacobianPoint {
+	if p.z.Sign() == 0 {
+		return q
+	}
+	if q.z.Sign() == 0 {
+		return p
+	}
+	z1z1 := fieldMul(p.z, p.z)
+	z2z2 := fieldMul(q.z, q.z)
+	u1 := fieldMul(p.x, z2z2)
+	u2 := fieldMul(q.x, z1z1)
+	s1 := fieldMul(fieldMul(p.y, q.z), z2z2)
+	s2 := fieldMul(fieldMul(q.y, p.z), z1z1)
+	if u1.Cmp(u2) == 0 {
+		if s1.Cmp(s2) != 0 {
+			return secpInfinity()
+		}
+		return secpDouble(p)
+	}
+	h := fieldSub(u2, u1)
+	h2 := fieldScale(h, 2)
+	i := fieldMul(h2, h2)
+	j := fieldMul(h, i)
+	r := fieldScale(fieldSub(s2, s1), 2)
+	v := fieldMul(u1, i)
+	x := fieldSub(fieldSub(fieldMul(r, r), j), fieldScale(v, 2))
+	y := fieldSub(fieldMul(r, fieldSub(v, x)), fieldScale(fieldMul(s1, j), 2))
+	zz := fieldAdd(p.z, q.z)
+	z := fieldMul(fieldSub(fieldSub(fieldMul(zz, zz), z1z1), z2z2), h)
+	return jacobianPoint{x, y, z}
+}
+
+// secpMul returns kP by double-and-add
+func secpMul(p jacobianPoint, k *big.Int) jacobianPoint {
+	result := secpInfinity()
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		result = secpDouble(result)
+		if k.Bit(i) == 1 {
+			result = secpAdd(result, p)
+		}
+	}
+	return result
+}
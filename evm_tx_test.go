@@ -0,0 +1,90 @@
+package blockchain_health
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// testKey is the private key of the EIP-155 example, 0x4646...46
+var testKey, _ = new(big.Int).SetString("4646464646464646464646464646464646464646464646464646464646464646", 16)
+
+// testSender is the address of testKey
+const testSender = "0x9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f"
+
+// signTestPayload signs the hash of payload with key, using a nonce derived
+// from both so the test is deterministic
+func signTestPayload(key *big.Int, payload []byte) (*big.Int, *big.Int, uint64) {
+	k := new(big.Int).SetBytes(keccak256(append(key.Bytes(), payload...)))
+	k.Mod(k, secpN)
+	x, y, _ := secpMul(secpPoint(secpGx, secpGy), k).affine()
+	r := new(big.Int).Mod(x, secpN)
+	s := new(big.Int).Mul(r, key)
+	s.Add(s, new(big.Int).SetBytes(keccak256(payload)))
+	s.Mul(s, new(big.Int).ModInverse(k, secpN)).Mod(s, secpN)
+	return r, s, uint64(y.Bit(0))
+}
+
+// rlpTestList encodes items, each already RLP encoded, as a list
+func rlpTestList(items ...[]byte) []byte {
+	var payload []byte
+	for _, item := range items {
+		payload = append(payload, item...)
+	}
+	return append(rlpListHeader(len(payload)), payload...)
+}
+
+func TestTransactionSender_EIP155(t *testing.T) {
+	// Example from EIP-155, signed with testKey
+	raw, _ := hex.DecodeString("f86c098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a76400008025a028ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276a067cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83")
+	sender, err := transactionSender(raw)
+	if err != nil {
+		t.Fatalf("Failed to recover sender: %v", err)
+	}
+	if sender != testSender {
+		t.Errorf("unexpected sender %s", sender)
+	}
+}
+
+func TestTransactionSender_DynamicFee(t *testing.T) {
+	to, _ := hex.DecodeString("3535353535353535353535353535353535353535")
+	fields := [][]byte{
+		rlpEncodeBytes([]byte{0x01}),             // chain id
+		rlpEncodeBytes([]byte{0x09}),             // nonce
+		rlpEncodeBytes([]byte{0x3b, 0x9a, 0xca}), // max priority fee
+		rlpEncodeBytes([]byte{0x04, 0xa8, 0x17}), // max fee
+		rlpEncodeBytes([]byte{0x52, 0x08}),       // gas
+		rlpEncodeBytes(to),
+		rlpEncodeBytes([]byte{0x01}), // value
+		rlpEncodeBytes(nil),          // data
+		rlpTestList(),                // access list
+	}
+	payload := append([]byte{0x02}, rlpTestList(fields...)...)
+	r, s, recid := signTestPayload(testKey, payload)
+
+	signed := append(fields, rlpEncodeBytes(big.NewInt(int64(recid)).Bytes()), rlpEncodeBytes(r.Bytes()), rlpEncodeBytes(s.Bytes()))
+	raw := append([]byte{0x02}, rlpTestList(signed...)...)
+
+	sender, err := transactionSender(raw)
+	if err != nil {
+		t.Fatalf("Failed to recover sender: %v", err)
+	}
+	if sender != testSender {
+		t.Errorf("unexpected sender %s", sender)
+	}
+}
+
+func TestTransactionSender_Invalid(t *testing.T) {
+	for name, raw := range map[string]string{
+		"empty":          "",
+		"truncated list": "f86c0985",
+		"short list":     "c3010203",
+		"bad type":       "02c3010203",
+		"zero signature": "02cc0101010101010101c0808080",
+	} {
+		b, _ := hex.DecodeString(raw)
+		if _, err := transactionSender(b); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package blockchain_health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Block explorer APIs an external reference can read its height from
+const (
+	ExplorerEtherscan  = "etherscan"
+	ExplorerBlockscout = "blockscout"
+	ExplorerMintscan   = "mintscan"
+)
+
+// explorerTypes are the node types whose heights each explorer reports
+var explorerTypes = map[string]NodeType{
+	ExplorerEtherscan:  NodeTypeEVM,
+	ExplorerBlockscout: NodeTypeEVM,
+	ExplorerMintscan:   NodeTypeCosmos,
+}
+
+// explorerClient reads chain heights from block explorer APIs, for chains
+// without a reliable public RPC to use as a reference. Explorers are third
+// parties, so requests don't go through the signing and tagging transports
+// of the node probes.
+type explorerClient struct {
+	client    *http.Client
+	userAgent string
+}
+
+// newExplorerClient returns an explorer client sending userAgent, or the
+// module's name and version if it is empty
+func newExplorerClient(timeout time.Duration, userAgent string) *explorerClient {
+	if userAgent == "" {
+		userAgent = probeTag()
+	}
+	return &explorerClient{client: &http.Client{Timeout: timeout}, userAgent: userAgent}
+}
+
+// height returns the latest height the explorer of the reference reports
+func (e *explorerClient) height(ctx context.Context, ref ExternalReference) (uint64, error) {
+	req, err := explorerRequest(ctx, ref)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", e.userAgent)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		// The URL carries the API key of Etherscan-style explorers, so only
+		// the cause is reported
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return 0, fmt.Errorf("explorer request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("explorer returned status %d", resp.StatusCode)
+	}
+	if ref.Explorer == ExplorerMintscan {
+		return parseMintscanHeight(resp)
+	}
+	return parseEtherscanHeight(resp)
+}
+
+// explorerRequest builds the latest height request of the reference's
+// explorer. Etherscan and Blockscout take the API key as a query parameter,
+// Mintscan as a bearer token. Query parameters of the URL, such as the
+// chainid of Etherscan's multichain API, are kept.
+func explorerRequest(ctx context.Context, ref ExternalReference) (*http.Request, error) {
+	u, err := url.Parse(ref.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid explorer URL: %w", err)
+	}
+
+	query := u.Query()
+	switch ref.Explorer {
+	case ExplorerEtherscan:
+		query.Set("module", "proxy")
+		query.Set("action", "eth_blockNumber")
+	case ExplorerBlockscout:
+		query.Set("module", "block")
+		query.Set("action", "eth_block_number")
+	case ExplorerMintscan:
+		// The URL is the explorer's latest block endpoint
+	default:
+		return nil, fmt.Errorf("unknown explorer: %s", ref.Explorer)
+	}
+	if ref.APIKey != "" && ref.Explorer != ExplorerMintscan {
+		query.Set("apikey", ref.APIKey)
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if ref.APIKey != "" && ref.Explorer == ExplorerMintscan {
+		req.Header.Set("Authorization", "Bearer "+ref.APIKey)
+	}
+	return req, nil
+}
+
+// parseEtherscanHeight reads the height from an Etherscan-style response.
+// Heights come back as a JSON-RPC result; errors, such as an invalid API key
+// or a rate limit, as status "0" with the reason in the result.
+func parseEtherscanHeight(resp *http.Response) (uint64, error) {
+	var body struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+		Result  any    `json:"result"`
+		Error   *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := decodeJSONNumbers(resp, &body); err != nil {
+		return 0, fmt.Errorf("decoding explorer response: %w", err)
+	}
+	if body.Error != nil {
+		return 0, fmt.Errorf("explorer error: %s", body.Error.Message)
+	}
+	if body.Status == "0" {
+		return 0, fmt.Errorf("explorer error: %s: %v", body.Message, body.Result)
+	}
+
+	height, err := parseHexQuantity(body.Result)
+	if err != nil {
+		return 0, fmt.Errorf("parsing explorer height: %w", err)
+	}
+	return height, nil
+}
+
+// mintscanHeightPaths are where Mintscan-style responses put the height of
+// the latest block
+var mintscanHeightPaths = [][]string{
+	{"height"},
+	{"block", "height"},
+	{"block", "header", "height"},
+	{"header", "height"},
+}
+
+// parseMintscanHeight reads the height from a Mintscan-style latest block
+// response, which is the block or a list starting with it
+func parseMintscanHeight(resp *http.Response) (uint64, error) {
+	var body any
+	if err := decodeJSONNumbers(resp, &body); err != nil {
+		return 0, fmt.Errorf("decoding explorer response: %w", err)
+	}
+	if list, ok := body.([]any); ok {
+		if len(list) == 0 {
+			return 0, fmt.Errorf("explorer returned no blocks")
+		}
+		body = list[0]
+	}
+
+	for _, path := range mintscanHeightPaths {
+		value, ok := lookupJSONPath(body, path)
+		if !ok {
+			continue
+		}
+		height, err := parseHeight(fmt.Sprint(value))
+		if err != nil {
+			return 0, fmt.Errorf("parsing explorer height: %w", err)
+		}
+		return height, nil
+	}
+	return 0, fmt.Errorf("explorer response has no block height")
+}
+
+// lookupJSONPath returns the value at the path of nested JSON objects
+func lookupJSONPath(value any, path []string) (any, bool) {
+	for _, key := range path {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok || value == nil {
+			return nil, false
+		}
+	}
+	return value, true
+}
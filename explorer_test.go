@@ -0,0 +1,157 @@
+package blockchain_health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"go.uber.org/zap"
+)
+
+func TestExplorerHeight(t *testing.T) {
+	tests := []struct {
+		name     string
+		explorer string
+		apiKey   string
+		path     string
+		check    func(r *http.Request) bool
+		response string
+		want     uint64
+		wantErr  string
+	}{
+		{
+			name:     "etherscan",
+			explorer: ExplorerEtherscan,
+			apiKey:   "KEY",
+			path:     "/v2/api?chainid=1",
+			check: func(r *http.Request) bool {
+				q := r.URL.Query()
+				return q.Get("chainid") == "1" && q.Get("module") == "proxy" && q.Get("action") == "eth_blockNumber" && q.Get("apikey") == "KEY"
+			},
+			response: `{"jsonrpc":"2.0","id":83,"result":"0x1312d00"}`,
+			want:     20000000,
+		},
+		{
+			name:     "etherscan error",
+			explorer: ExplorerEtherscan,
+			response: `{"status":"0","message":"NOTOK","result":"Invalid API Key"}`,
+			wantErr:  "Invalid API Key",
+		},
+		{
+			name:     "blockscout",
+			explorer: ExplorerBlockscout,
+			path:     "/api",
+			check: func(r *http.Request) bool {
+				q := r.URL.Query()
+				return q.Get("module") == "block" && q.Get("action") == "eth_block_number" && !q.Has("apikey")
+			},
+			response: `{"jsonrpc":"2.0","id":1,"result":"0xb33bf1"}`,
+			want:     11746289,
+		},
+		{
+			name:     "mintscan block",
+			explorer: ExplorerMintscan,
+			apiKey:   "KEY",
+			check: func(r *http.Request) bool {
+				return r.Header.Get("Authorization") == "Bearer KEY" && r.URL.RawQuery == ""
+			},
+			response: `{"block":{"header":{"height":"18234567"}}}`,
+			want:     18234567,
+		},
+		{
+			name:     "mintscan list",
+			explorer: ExplorerMintscan,
+			response: `[{"height":18234568,"hash":"ABC"}]`,
+			want:     18234568,
+		},
+		{
+			name:     "mintscan without a height",
+			explorer: ExplorerMintscan,
+			response: `{"block":{}}`,
+			wantErr:  "no block height",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.check != nil && !tt.check(r) {
+					t.Errorf("unexpected explorer request: %s %v", r.URL, r.Header)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			ref := ExternalReference{Name: tt.name, URL: server.URL + tt.path, Explorer: tt.explorer, APIKey: tt.apiKey}
+			height, err := newExplorerClient(time.Second, "").height(context.Background(), ref)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("height failed: %v", err)
+			}
+			if height != tt.want {
+				t.Errorf("expected height %d, got %d", tt.want, height)
+			}
+		})
+	}
+}
+
+func TestExplorerHeight_HidesAPIKey(t *testing.T) {
+	ref := ExternalReference{Name: "etherscan", URL: "http://127.0.0.1:1/api", Explorer: ExplorerEtherscan, APIKey: "SECRET"}
+	_, err := newExplorerClient(time.Second, "").height(context.Background(), ref)
+	if err == nil || strings.Contains(err.Error(), "SECRET") {
+		t.Errorf("expected an error without the API key, got %v", err)
+	}
+}
+
+func TestExplorerReference_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:8545
+			type evm
+		}
+		external_reference evm {
+			name etherscan
+			url https://api.etherscan.io/v2/api?chainid=1
+			explorer etherscan
+			api_key KEY
+		}
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	ref := b.ExternalReferences[0]
+	if ref.Explorer != ExplorerEtherscan || ref.APIKey != "KEY" {
+		t.Errorf("Unexpected explorer reference: %+v", ref)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("Expected a valid config, got %v", err)
+	}
+
+	b.ExternalReferences[0].Explorer = ExplorerMintscan
+	if err := b.validate(); err == nil {
+		t.Error("Expected a Cosmos explorer to be rejected for an EVM reference")
+	}
+	b.ExternalReferences[0].Explorer = "etherscan2"
+	if err := b.validate(); err == nil {
+		t.Error("Expected an unknown explorer to be rejected")
+	}
+
+	b.ExternalReferences[0].Explorer = ExplorerEtherscan
+	b.config = &Config{ExternalReferences: b.ExternalReferences}
+	b.logger = zap.NewNop()
+	if _, _, err := b.externalProviderUpstreams(); err == nil {
+		t.Error("Expected explorers not to be used as fallback providers")
+	}
+}
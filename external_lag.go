@@ -0,0 +1,21 @@
+package blockchain_health
+
+// Actions taken on nodes trailing an external reference
+const (
+	// ExternalLagWarn only flags the nodes in logs, metrics and the health
+	// endpoint
+	ExternalLagWarn = "warn"
+	// ExternalLagDownweight keeps routing to the nodes with less weight
+	ExternalLagDownweight = "downweight"
+	// ExternalLagExclude stops routing to the nodes
+	ExternalLagExclude = "exclude"
+)
+
+// isValidExternalLagAction reports whether s is a known external lag action
+func isValidExternalLagAction(s string) bool {
+	switch s {
+	case ExternalLagWarn, ExternalLagDownweight, ExternalLagExclude:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,234 @@
+package blockchain_health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap/zaptest"
+)
+
+// fetchReference makes ref the only reference of the health checker and
+// fetches its height
+func fetchReference(h *HealthChecker, ref ExternalReference) {
+	h.config.ExternalReferences = []ExternalReference{ref}
+	h.references = make(map[string]*referenceFetch)
+	h.refreshReferences()
+}
+
+func TestValidateAgainstExternal_PlausibilityWindow(t *testing.T) {
+	upstream := createTestUpstream(nil, zaptest.NewLogger(t))
+	h := upstream.healthChecker
+	defer h.Stop()
+	h.config.BlockValidation.ReferenceMaxAhead = 100
+
+	tests := []struct {
+		name      string
+		reference uint64
+		discarded string
+		valid     bool
+	}{
+		{name: "within the threshold", reference: 1005, valid: true},
+		{name: "slightly behind", reference: 995, valid: true},
+		{name: "ahead within the window", reference: 1050, valid: false},
+		{name: "stale", reference: 900, discarded: referenceBehindPool},
+		{name: "another chain", reference: 20000000, discarded: referenceTooFarAhead},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := createEVMServer(t, tt.reference, false)
+			defer server.Close()
+			ref := ExternalReference{Name: "ref", URL: server.URL, Type: NodeTypeEVM, Enabled: true}
+			nodes := []*NodeHealth{{Name: "node", BlockHeight: 1000, ExternalReferenceValid: true}}
+
+			fetchReference(h, ref)
+			before := testutil.ToFloat64(h.metrics.referencesDiscarded.WithLabelValues("ref", tt.discarded))
+			height, err := h.validateAgainstExternal(nodes, ref, 1000)
+			if tt.discarded != "" {
+				if !errors.Is(err, errImplausibleReference) {
+					t.Fatalf("expected the reference to be discarded, got %d (%v)", height, err)
+				}
+				if !nodes[0].ExternalReferenceValid || nodes[0].BlocksBehindExternal != 0 {
+					t.Error("expected a discarded reference to leave the node alone")
+				}
+				if after := testutil.ToFloat64(h.metrics.referencesDiscarded.WithLabelValues("ref", tt.discarded)); after != before+1 {
+					t.Errorf("expected the discard to be counted, got %v", after-before)
+				}
+				if count := testutil.CollectAndCount(h.metrics.blocksBehindExternal); count != 0 {
+					t.Errorf("expected the lag behind a discarded reference to be dropped, got %d series", count)
+				}
+				return
+			}
+			if err != nil || height != tt.reference {
+				t.Fatalf("expected reference height %d, got %d (%v)", tt.reference, height, err)
+			}
+			if nodes[0].ExternalReferenceValid != tt.valid {
+				t.Errorf("expected the node's reference validity to be %v", tt.valid)
+			}
+		})
+	}
+
+	// Without a pool height there is nothing to bound the reference by
+	server := createEVMServer(t, 20000000, false)
+	defer server.Close()
+	ref := ExternalReference{Name: "ref", URL: server.URL, Type: NodeTypeEVM, Enabled: true}
+	fetchReference(h, ref)
+	if _, err := h.validateAgainstExternal([]*NodeHealth{{Name: "node"}}, ref, 0); err != nil {
+		t.Errorf("expected the reference to be used without a pool height, got %v", err)
+	}
+}
+
+func TestExternalReference_FetchedOncePerInterval(t *testing.T) {
+	var fetches atomic.Int32
+	reference := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x3e8"}`))
+	}))
+	defer reference.Close()
+
+	nodes := []NodeConfig{
+		{Name: "a", URL: "http://127.0.0.1:1", Type: NodeTypeEVM},
+		{Name: "b", URL: "http://127.0.0.1:2", Type: NodeTypeEVM},
+		{Name: "c", URL: "http://127.0.0.1:3", Type: NodeTypeEVM},
+	}
+	upstream := createTestUpstream(nodes, zaptest.NewLogger(t))
+	h := upstream.healthChecker
+	defer h.Stop()
+	h.config.HealthCheck.Interval = "1m"
+	h.config.ExternalReferences = []ExternalReference{{Name: "ref", URL: reference.URL, Type: NodeTypeEVM, Enabled: true}}
+
+	now := time.Now()
+	results := func(checked ...time.Time) []*NodeHealth {
+		var out []*NodeHealth
+		for i, node := range nodes {
+			out = append(out, &NodeHealth{Name: node.Name, URL: node.URL, Healthy: true, BlockHeight: 1000, LastCheck: checked[i]})
+		}
+		return out
+	}
+
+	// Results are processed once per node check, each time with the latest
+	// results of the other nodes
+	h.processResults(results(now, now, now))
+	h.processResults(results(now.Add(time.Second), now, now))
+	h.processResults(results(now.Add(time.Second), now.Add(2*time.Second), now))
+
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("expected the reference to be fetched once per interval, got %d fetches", got)
+	}
+	if got := testutil.ToFloat64(h.metrics.totalChecks); got != 5 {
+		t.Errorf("expected 5 node checks to be counted, got %v", got)
+	}
+	if height, err := h.cachedReferenceHeight(h.config.ExternalReferences[0]); err != nil || height != 1000 {
+		t.Errorf("expected the cached reference height 1000, got %d (%v)", height, err)
+	}
+}
+
+func TestExternalLagAction(t *testing.T) {
+	current := createEVMServer(t, 1000, false)
+	defer current.Close()
+	lagging := createEVMServer(t, 990, false)
+	defer lagging.Close()
+	reference := createEVMServer(t, 1005, false)
+	defer reference.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "current", URL: current.URL, Type: NodeTypeEVM, Weight: 100},
+		{Name: "lagging", URL: lagging.URL, Type: NodeTypeEVM, Weight: 100},
+	}, zaptest.NewLogger(t))
+	upstream.config.BlockValidation.HeightThreshold = 20
+	upstream.config.ExternalReferences = []ExternalReference{{Name: "ref", URL: reference.URL, Type: NodeTypeEVM, Enabled: true}}
+	h := upstream.healthChecker
+	defer h.Stop()
+
+	results, err := h.CheckAllNodes(context.Background())
+	if err != nil {
+		t.Fatalf("CheckAllNodes failed: %v", err)
+	}
+	for _, health := range results {
+		if flagged := health.Name == "lagging"; health.ExternalLagging != flagged || !health.Healthy {
+			t.Errorf("%s: expected a healthy node with external_lagging=%v, got %+v", health.Name, flagged, health)
+		}
+	}
+
+	// The lag is exported per node and per reference
+	if value := testutil.ToFloat64(h.metrics.blocksBehindPool.WithLabelValues("lagging")); value != 10 {
+		t.Errorf("expected the lagging node 10 blocks behind the pool, got %v", value)
+	}
+	if value := testutil.ToFloat64(h.metrics.blocksBehindExternal.WithLabelValues("lagging", "ref")); value != 15 {
+		t.Errorf("expected the lagging node 15 blocks behind the reference, got %v", value)
+	}
+	if value := testutil.ToFloat64(h.metrics.heightValid.WithLabelValues("lagging")); value != 1 {
+		t.Errorf("expected the lagging node within the pool threshold, got %v", value)
+	}
+
+	weights := func() map[string]int {
+		t.Helper()
+		upstreams, err := upstream.GetUpstreams(&http.Request{})
+		if err != nil {
+			t.Fatalf("GetUpstreams failed: %v", err)
+		}
+		weights := make(map[string]int)
+		for _, up := range upstreams {
+			weights[up.Dial] = up.MaxRequests
+		}
+		return weights
+	}
+	currentDial, laggingDial := getDynamicTestHostFromURL(current.URL), getDynamicTestHostFromURL(lagging.URL)
+
+	// By default external lag is only flagged
+	if got := weights(); len(got) != 2 || got[laggingDial] != 100 {
+		t.Errorf("expected both nodes at full weight, got %v", got)
+	}
+
+	upstream.config.BlockValidation.ExternalLagAction = ExternalLagDownweight
+	upstream.config.BlockValidation.ExternalLagWeightFactor = 0.2
+	if got := weights(); got[currentDial] != 100 || got[laggingDial] != 20 {
+		t.Errorf("expected the lagging node down-weighted, got %v", got)
+	}
+
+	upstream.config.BlockValidation.ExternalLagAction = ExternalLagExclude
+	if got := weights(); len(got) != 1 || got[currentDial] != 100 {
+		t.Errorf("expected only the current node, got %v", got)
+	}
+	if value := testutil.ToFloat64(upstream.metrics.upstreamsExcluded.WithLabelValues("lagging", "", "external_lag")); value == 0 {
+		t.Error("expected the exclusion to be counted")
+	}
+}
+
+func TestExternalLagAction_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:8545
+			type evm
+		}
+		external_lag_action downweight 0.3
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if b.BlockValidation.ExternalLagAction != ExternalLagDownweight || b.BlockValidation.ExternalLagWeightFactor != 0.3 {
+		t.Errorf("Unexpected external lag action: %+v", b.BlockValidation)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("Expected a valid config, got %v", err)
+	}
+
+	b.BlockValidation.ExternalLagAction = "eject"
+	if err := b.validate(); err == nil {
+		t.Error("Expected an unknown action to be rejected")
+	}
+	b.BlockValidation.ExternalLagAction = ExternalLagDownweight
+	b.BlockValidation.ExternalLagWeightFactor = 2
+	if err := b.validate(); err == nil {
+		t.Error("Expected a weight factor above 1 to be rejected")
+	}
+}
@@ -0,0 +1,197 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// Fallback strategies applied when no pool node is healthy
+const (
+	// FallbackAll routes to every pool node, healthy or not
+	FallbackAll = "all"
+	// FallbackBestEffortHighest routes only to the least-behind nodes
+	FallbackBestEffortHighest = "best_effort_highest"
+	// FallbackExternalProviders routes to the enabled external references
+	FallbackExternalProviders = "external_providers"
+	// FallbackError fails the request instead of routing to unhealthy nodes
+	FallbackError = "error"
+)
+
+// isValidFallbackStrategy reports whether s is a known fallback strategy
+func isValidFallbackStrategy(s string) bool {
+	switch s {
+	case FallbackAll, FallbackBestEffortHighest, FallbackExternalProviders, FallbackError:
+		return true
+	}
+	return false
+}
+
+// selectionInfo describes why an upstream was selected, for metrics
+type selectionInfo struct {
+	name        string
+	serviceType string
+	reason      string
+	included    prometheus.Counter // counts the inclusion, if looked up already
+}
+
+// fallbackStrategy returns the configured fallback strategy
+func (f FailureHandlingConfig) fallbackStrategy() string {
+	if f.FallbackStrategy == "" {
+		return FallbackAll
+	}
+	return f.FallbackStrategy
+}
+
+// fallbackStrategy returns the fallback strategy in effect. Strict leader
+// mode never routes to nodes behind the network head, so only the external
+// providers may stand in for the pool and other strategies fail the request.
+func (c *Config) fallbackStrategy() string {
+	strategy := c.FailureHandling.fallbackStrategy()
+	if c.BlockValidation.StrictLeaderOnly && strategy != FallbackExternalProviders {
+		return FallbackError
+	}
+	return strategy
+}
+
+// fallbackUpstreams builds the upstream list used when no pool node is healthy
+func (b *BlockchainHealthUpstream) fallbackUpstreams(healthResults []*NodeHealth) ([]*reverseproxy.Upstream, []selectionInfo, error) {
+	strategy := b.config.fallbackStrategy()
+
+	switch strategy {
+	case FallbackError:
+		return nil, nil, &ErrChainDegraded{Chain: b.chainName()}
+
+	case FallbackExternalProviders:
+		return b.externalProviderUpstreams()
+	}
+
+	// Only the nodes at the highest observed height are used for best effort
+	var bestHeight uint64
+	if strategy == FallbackBestEffortHighest {
+		for _, health := range healthResults {
+			if !b.healthChecker.isCandidate(health.Name) && health.BlockHeight > bestHeight {
+				bestHeight = health.BlockHeight
+			}
+		}
+	}
+
+	reason := "fallback_" + strategy
+	var upstreams []*reverseproxy.Upstream
+	var infos []selectionInfo
+	for _, health := range healthResults {
+		// Find the corresponding node config for weight
+		weight := 1
+		serviceType := ""
+		if node := b.findNodeConfig(health.Name); node != nil {
+			if node.isCandidate() {
+				continue
+			}
+			weight = node.Weight
+			serviceType = node.Metadata["service_type"]
+		}
+
+		if health.BlockHeight < bestHeight {
+			continue
+		}
+
+		// Parse URL for upstream
+		parsedURL, err := url.Parse(health.URL)
+		if err != nil {
+			b.logger.Warn("invalid node URL", zap.String("node", health.Name), zap.String("url", health.URL))
+			if b.metrics != nil {
+				b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "invalid_url").Inc()
+			}
+			continue
+		}
+		dial := upstreamDial(parsedURL)
+		if dial == "" {
+			b.logger.Warn("parsed URL has empty host; skipping fallback upstream", zap.String("node", health.Name), zap.String("url", health.URL))
+			if b.metrics != nil {
+				b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "empty_host").Inc()
+			}
+			continue
+		}
+
+		upstream := &reverseproxy.Upstream{
+			Dial: dial,
+		}
+
+		// Add weight if specified
+		if weight > 1 {
+			upstream.MaxRequests = weight
+		}
+
+		upstreams = append(upstreams, upstream)
+		infos = append(infos, selectionInfo{
+			name:        health.Name,
+			serviceType: serviceType,
+			reason:      reason,
+		})
+	}
+
+	return upstreams, infos, nil
+}
+
+// externalProviderUpstreams routes to the enabled external references. The
+// reverse_proxy transport must match their scheme (e.g. TLS for https). When
+// any reference has a request budget, the providers take turns and each
+// request goes to the next one with budget left.
+func (b *BlockchainHealthUpstream) externalProviderUpstreams() ([]*reverseproxy.Upstream, []selectionInfo, error) {
+	var upstreams []*reverseproxy.Upstream
+	var infos []selectionInfo
+	for _, ref := range b.config.ExternalReferences {
+		// Explorers answer their own API, not the chain's
+		if !ref.Enabled || ref.Explorer != "" {
+			continue
+		}
+		parsedURL, err := url.Parse(ref.URL)
+		if err != nil || parsedURL.Host == "" {
+			b.logger.Warn("invalid external reference URL", zap.String("reference", ref.Name), zap.String("url", ref.URL))
+			continue
+		}
+
+		upstreams = append(upstreams, &reverseproxy.Upstream{Dial: dialAddress(parsedURL)})
+		infos = append(infos, selectionInfo{
+			name:   ref.Name,
+			reason: "fallback_" + FallbackExternalProviders,
+		})
+	}
+
+	if len(upstreams) == 0 {
+		return nil, nil, fmt.Errorf("%w: no enabled external references to fall back to", ErrNoHealthyUpstreams)
+	}
+	if b.providers == nil {
+		return upstreams, infos, nil
+	}
+
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.name
+	}
+	i, ok := b.providers.next(names, func(name string) {
+		if b.metrics != nil {
+			b.metrics.upstreamsExcluded.WithLabelValues(name, "", "provider_budget").Inc()
+		}
+	})
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: all external providers are over their request budget", ErrNoHealthyUpstreams)
+	}
+	return upstreams[i : i+1], infos[i : i+1], nil
+}
+
+// dialAddress returns host:port for a URL, using the scheme's default port
+func dialAddress(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "80"
+	if u.Scheme == "https" || u.Scheme == "wss" {
+		port = "443"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
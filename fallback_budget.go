@@ -0,0 +1,76 @@
+package blockchain_health
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// providerRotation takes turns among the external providers used as a
+// fallback and enforces their request budgets with a token bucket each, so an
+// outage of the pool can't burn through a provider's quota in minutes
+type providerRotation struct {
+	mutex   sync.Mutex
+	turn    int
+	buckets map[string]*providerBucket // provider name -> bucket; absent means unlimited
+	now     func() time.Time
+}
+
+// providerBucket holds the requests a provider has left
+type providerBucket struct {
+	rate    float64 // tokens refilled per second
+	burst   float64
+	tokens  float64
+	updated time.Time
+}
+
+// newProviderRotation returns the rotation for the references, or nil when
+// none of them has a request budget
+func newProviderRotation(refs []ExternalReference) *providerRotation {
+	p := &providerRotation{buckets: make(map[string]*providerBucket), now: time.Now}
+	now := p.now()
+	for _, ref := range refs {
+		if ref.RequestsPerMinute <= 0 {
+			continue
+		}
+		burst := ref.Burst
+		if burst == 0 {
+			burst = ref.RequestsPerMinute
+		}
+		p.buckets[ref.Name] = &providerBucket{
+			rate:    float64(ref.RequestsPerMinute) / 60,
+			burst:   float64(burst),
+			tokens:  float64(burst),
+			updated: now,
+		}
+	}
+	if len(p.buckets) == 0 {
+		return nil
+	}
+	return p
+}
+
+// next returns the index of the first provider, starting from the one whose
+// turn it is, that has budget left and charges one request to it. Providers
+// skipped for lack of budget are passed to skipped.
+func (p *providerRotation) next(names []string, skipped func(name string)) (int, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := p.now()
+	for k := 0; k < len(names); k++ {
+		i := (p.turn + k) % len(names)
+		if bucket, ok := p.buckets[names[i]]; ok {
+			bucket.tokens = math.Min(bucket.burst, bucket.tokens+now.Sub(bucket.updated).Seconds()*bucket.rate)
+			bucket.updated = now
+			if bucket.tokens < 1 {
+				skipped(names[i])
+				continue
+			}
+			bucket.tokens--
+		}
+		p.turn = i + 1
+		return i, true
+	}
+	return 0, false
+}
@@ -0,0 +1,160 @@
+package blockchain_health
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestFallbackStrategies(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	// Both nodes are catching up, so neither is healthy
+	ahead := createCosmosServer(t, 1000, true)
+	defer ahead.Close()
+	behind := createCosmosServer(t, 900, true)
+	defer behind.Close()
+
+	nodes := []NodeConfig{
+		{Name: "ahead", URL: ahead.URL, Type: NodeTypeCosmos, Weight: 1},
+		{Name: "behind", URL: behind.URL, Type: NodeTypeCosmos, Weight: 1},
+	}
+
+	tests := []struct {
+		strategy      string
+		expectErr     bool
+		expectedDials []string
+	}{
+		{strategy: "", expectedDials: []string{getDynamicTestHostFromURL(ahead.URL), getDynamicTestHostFromURL(behind.URL)}},
+		{strategy: FallbackAll, expectedDials: []string{getDynamicTestHostFromURL(ahead.URL), getDynamicTestHostFromURL(behind.URL)}},
+		{strategy: FallbackBestEffortHighest, expectedDials: []string{getDynamicTestHostFromURL(ahead.URL)}},
+		{strategy: FallbackExternalProviders, expectedDials: []string{"rpc.example.com:443"}},
+		{strategy: FallbackError, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run("strategy_"+tt.strategy, func(t *testing.T) {
+			upstream := createTestUpstream(nodes, logger)
+			upstream.config.FailureHandling.FallbackStrategy = tt.strategy
+			upstream.config.ExternalReferences = []ExternalReference{
+				{Name: "public", URL: "https://rpc.example.com", Type: NodeTypeCosmos, Enabled: true},
+				{Name: "disabled", URL: "https://other.example.com", Type: NodeTypeCosmos},
+			}
+
+			upstreams, err := upstream.GetUpstreams(&http.Request{URL: &url.URL{Path: "/"}, Header: http.Header{}})
+			if tt.expectErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got %v", upstreams)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetUpstreams failed: %v", err)
+			}
+
+			if len(upstreams) != len(tt.expectedDials) {
+				t.Fatalf("Expected %d upstreams, got %d", len(tt.expectedDials), len(upstreams))
+			}
+			for i, dial := range tt.expectedDials {
+				if upstreams[i].Dial != dial {
+					t.Errorf("Expected upstream %d to dial %s, got %s", i, dial, upstreams[i].Dial)
+				}
+			}
+		})
+	}
+}
+
+func TestFallbackStrategyValidation(t *testing.T) {
+	upstream := &BlockchainHealthUpstream{
+		Nodes: []NodeConfig{{Name: "node", URL: "http://localhost:26657", Type: NodeTypeCosmos, Weight: 100}},
+		FailureHandling: FailureHandlingConfig{
+			FallbackStrategy: "random",
+		},
+	}
+	err := upstream.validate()
+	if err == nil || !strings.Contains(err.Error(), "fallback strategy") {
+		t.Errorf("Expected an invalid fallback strategy to be rejected, got %v", err)
+	}
+}
+
+func TestFallbackProviderBudgets(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	upstream := createTestUpstream(nil, logger)
+	upstream.config.ExternalReferences = []ExternalReference{
+		{Name: "infura", URL: "https://infura.example.com", Type: NodeTypeEVM, Enabled: true, RequestsPerMinute: 60, Burst: 2},
+		{Name: "alchemy", URL: "https://alchemy.example.com", Type: NodeTypeEVM, Enabled: true, RequestsPerMinute: 60, Burst: 1},
+	}
+	upstream.providers = newProviderRotation(upstream.config.ExternalReferences)
+	now := time.Now()
+	upstream.providers.now = func() time.Time { return now }
+
+	// The providers take turns until alchemy runs out, then infura takes over
+	var dials []string
+	for i := 0; i < 3; i++ {
+		upstreams, _, err := upstream.externalProviderUpstreams()
+		if err != nil {
+			t.Fatalf("externalProviderUpstreams failed: %v", err)
+		}
+		if len(upstreams) != 1 {
+			t.Fatalf("Expected one provider per request, got %v", upstreams)
+		}
+		dials = append(dials, upstreams[0].Dial)
+	}
+	expected := []string{"infura.example.com:443", "alchemy.example.com:443", "infura.example.com:443"}
+	for i := range expected {
+		if dials[i] != expected[i] {
+			t.Errorf("Expected request %d to go to %s, got %s", i, expected[i], dials[i])
+		}
+	}
+
+	if _, _, err := upstream.externalProviderUpstreams(); !errors.Is(err, ErrNoHealthyUpstreams) {
+		t.Errorf("Expected spent budgets to fail the request, got %v", err)
+	}
+
+	// One request per second refills
+	now = now.Add(time.Second)
+	if upstreams, _, err := upstream.externalProviderUpstreams(); err != nil || len(upstreams) != 1 {
+		t.Errorf("Expected a refilled provider, got %v (%v)", upstreams, err)
+	}
+}
+
+func TestFallbackProviderBudgets_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:8545
+			type evm
+		}
+		external_reference evm {
+			name infura
+			url https://mainnet.infura.io/v3/KEY
+			requests_per_minute 600
+			burst 50
+		}
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	ref := b.ExternalReferences[0]
+	if ref.RequestsPerMinute != 600 || ref.Burst != 50 {
+		t.Errorf("Unexpected request budget: %d/min, burst %d", ref.RequestsPerMinute, ref.Burst)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("Expected a valid config, got %v", err)
+	}
+
+	b.ExternalReferences[0].RequestsPerMinute = 0
+	if err := b.validate(); err == nil {
+		t.Error("Expected burst without requests_per_minute to be rejected")
+	}
+	if newProviderRotation(b.ExternalReferences) != nil {
+		t.Error("Expected no rotation without request budgets")
+	}
+}
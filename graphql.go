@@ -0,0 +1,125 @@
+package blockchain_health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// graphqlPath is the path Geth and Erigon serve GraphQL on
+const graphqlPath = "/graphql"
+
+// graphqlBlockQuery asks for the number of the latest block
+const graphqlBlockQuery = `{"query":"{block{number}}"}`
+
+// graphqlResponse is the answer to graphqlBlockQuery
+type graphqlResponse struct {
+	Data *struct {
+		Block *struct {
+			Number interface{} `json:"number"`
+		} `json:"block"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// graphqlURL returns the GraphQL endpoint of a node: its URL, with /graphql
+// appended unless the URL already points at it
+func graphqlURL(url string) string {
+	url = strings.TrimRight(url, "/")
+	if strings.HasSuffix(url, graphqlPath) {
+		return url
+	}
+	return url + graphqlPath
+}
+
+// isGraphQLRequest reports whether a request is for a GraphQL endpoint
+func isGraphQLRequest(r *http.Request) bool {
+	return r != nil && r.URL != nil && strings.HasSuffix(strings.TrimRight(r.URL.Path, "/"), graphqlPath)
+}
+
+// rpcURL returns the URL JSON-RPC probes of a node are sent to. WebSocket and
+// GraphQL nodes are probed through their http_url metadata, and are skipped
+// when they have none.
+func (n NodeConfig) rpcURL() string {
+	switch n.serviceType() {
+	case ServiceTypeWebSocket, ServiceTypeGraphQL:
+		return n.Metadata["http_url"]
+	}
+	return n.URL
+}
+
+// GetGraphQLBlockHeight returns the latest block served by a node's GraphQL
+// endpoint. Geth answers the block number as a hex string, older versions
+// as a JSON number.
+func (e *EVMHandler) GetGraphQLBlockHeight(ctx context.Context, url string) (uint64, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", graphqlURL(url), strings.NewReader(graphqlBlockQuery))
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("GraphQL request failed: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			e.logger.Debug("Failed to close response body", zap.Error(err))
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, statusError("GraphQL", resp.StatusCode)
+	}
+
+	var gqlResp graphqlResponse
+	if err := decodeJSONNumbers(resp, &gqlResp); err != nil {
+		return 0, fmt.Errorf("decoding GraphQL response: %w", err)
+	}
+	if len(gqlResp.Errors) > 0 {
+		return 0, fmt.Errorf("GraphQL error: %s", gqlResp.Errors[0].Message)
+	}
+	if gqlResp.Data == nil || gqlResp.Data.Block == nil {
+		return 0, fmt.Errorf("GraphQL response has no block")
+	}
+
+	height, err := parseHexQuantity(gqlResp.Data.Block.Number)
+	if err != nil {
+		return 0, fmt.Errorf("parsing GraphQL block number: %w", err)
+	}
+	return height, nil
+}
+
+// checkGraphQLHealth checks a GraphQL node by querying the latest block from
+// its GraphQL endpoint, so nodes without GraphQL enabled are unhealthy. Nodes
+// with an http_url are also checked with eth_syncing; either way a node
+// trailing the pool is ejected by the height check.
+func (e *EVMHandler) checkGraphQLHealth(ctx context.Context, node NodeConfig, health *NodeHealth, start time.Time) *NodeHealth {
+	blockHeight, err := e.GetGraphQLBlockHeight(ctx, node.URL)
+	if err != nil {
+		health.LastError = err.Error()
+		health.Throttled = errors.Is(err, errThrottled)
+		health.ResponseTime = time.Since(start)
+		e.logger.Debug("GraphQL node health check failed",
+			zap.String("node", node.Name),
+			zap.String("url", graphqlURL(node.URL)),
+			zap.Error(err))
+		return health
+	}
+
+	health.BlockHeight = blockHeight
+	health.Healthy = true
+	if httpURL := node.Metadata["http_url"]; httpURL != "" {
+		e.applySyncing(ctx, health, httpURL)
+		health.Client = string(e.clientFor(ctx, node, httpURL))
+	}
+	health.ResponseTime = time.Since(start)
+	return health
+}
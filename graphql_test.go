@@ -0,0 +1,95 @@
+package blockchain_health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// createGraphQLServer serves {block{number}} on /graphql with the given
+// number, written as is into the response
+func createGraphQLServer(t *testing.T, number string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != graphqlPath {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":{"block":{"number":` + number + `}}}`))
+	}))
+}
+
+func TestEVMHandler_GetGraphQLBlockHeight(t *testing.T) {
+	handler := NewEVMHandler(5*time.Second, zaptest.NewLogger(t))
+
+	tests := []struct {
+		name    string
+		number  string
+		path    string
+		want    uint64
+		wantErr bool
+	}{
+		{name: "hex number", number: `"0x3e8"`, want: 1000},
+		{name: "JSON number", number: `1000`, want: 1000},
+		{name: "URL with path", number: `"0x10"`, path: "/graphql", want: 16},
+		{name: "no block", number: `null`, wantErr: true},
+	}
+	for _, tt := range tests {
+		server := createGraphQLServer(t, tt.number)
+		height, err := handler.GetGraphQLBlockHeight(context.Background(), server.URL+tt.path)
+		server.Close()
+		if (err != nil) != tt.wantErr || height != tt.want {
+			t.Errorf("%s: expected %d (error %v), got %d (%v)", tt.name, tt.want, tt.wantErr, height, err)
+		}
+	}
+
+	// Nodes without GraphQL enabled answer 404
+	rpc := createEVMServer(t, 1000, false)
+	defer rpc.Close()
+	if _, err := handler.GetGraphQLBlockHeight(context.Background(), rpc.URL); err == nil {
+		t.Error("expected an error from a node without GraphQL")
+	}
+}
+
+func TestGraphQL_Routing(t *testing.T) {
+	rpc := createEVMServer(t, 1000, false)
+	defer rpc.Close()
+	graphql := createGraphQLServer(t, `"0x3e8"`)
+	defer graphql.Close()
+	disabled := createEVMServer(t, 1000, false)
+	defer disabled.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "rpc", URL: rpc.URL, Type: NodeTypeEVM, ChainType: "test-evm", Weight: 1},
+		{Name: "graphql", URL: graphql.URL, Type: NodeTypeEVM, ChainType: "test-evm", Weight: 1, Metadata: map[string]string{"service_type": "graphql"}},
+		{Name: "disabled", URL: disabled.URL, Type: NodeTypeEVM, ChainType: "test-evm", Weight: 1, Metadata: map[string]string{"service_type": "graphql"}},
+	}, zaptest.NewLogger(t))
+
+	dials := func(target string) []string {
+		t.Helper()
+		upstreams, err := upstream.GetUpstreams(httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{}`)))
+		if err != nil {
+			t.Fatalf("GetUpstreams failed: %v", err)
+		}
+		var dials []string
+		for _, up := range upstreams {
+			dials = append(dials, up.Dial)
+		}
+		return dials
+	}
+
+	// GraphQL requests only reach the node with the endpoint enabled
+	if got := dials("/graphql"); len(got) != 1 || got[0] != getDynamicTestHostFromURL(graphql.URL) {
+		t.Errorf("expected GraphQL requests to reach only the graphql node, got %v", got)
+	}
+
+	// JSON-RPC requests skip GraphQL nodes
+	if got := dials("/"); len(got) != 1 || got[0] != getDynamicTestHostFromURL(rpc.URL) {
+		t.Errorf("expected JSON-RPC requests to reach only the rpc node, got %v", got)
+	}
+}
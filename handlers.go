@@ -3,31 +3,63 @@ package blockchain_health
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
 )
 
+// Cosmos RPC probe modes
+const (
+	CosmosProbeStatus   = "status"    // /status: height and catching_up
+	CosmosProbeHealth   = "health"    // /health liveness plus /abci_info height
+	CosmosProbeABCIInfo = "abci_info" // /abci_info: application height only
+)
+
+// isValidCosmosProbeMode reports whether mode is a supported Cosmos probe mode
+func isValidCosmosProbeMode(mode string) bool {
+	switch mode {
+	case CosmosProbeStatus, CosmosProbeHealth, CosmosProbeABCIInfo:
+		return true
+	}
+	return false
+}
+
+// cosmosRESTBasePaths are the base paths of the REST syncing and latest
+// block endpoints, tried in order until one answers: the gRPC gateway of
+// Cosmos SDK v0.40 and later, and the legacy LCD routes of older chains
+var cosmosRESTBasePaths = []string{"/cosmos/base/tendermint/v1beta1", "/"}
+
+// errRESTPathMissing marks REST probes answered with 404 or 501, the sign
+// that the node serves its status endpoints under another base path
+var errRESTPathMissing = errors.New("endpoint not served")
+
 // CosmosHandler handles health checks for Cosmos-based blockchain nodes
 type CosmosHandler struct {
-	client *http.Client
-	logger *zap.Logger
+	client    *http.Client
+	logger    *zap.Logger
+	probeMode string
+
+	// REST base path detected for each REST URL
+	restPaths      map[string]string
+	restPathsMutex sync.Mutex
 }
 
 // NewCosmosHandler creates a new Cosmos protocol handler
 func NewCosmosHandler(timeout time.Duration, logger *zap.Logger) *CosmosHandler {
 	return &CosmosHandler{
-		client: &http.Client{
-			Timeout: timeout,
-		},
-		logger: logger,
+		client:    newProbeClient(timeout),
+		logger:    logger,
+		restPaths: make(map[string]string),
 	}
 }
 
@@ -35,12 +67,22 @@ func NewCosmosHandler(timeout time.Duration, logger *zap.Logger) *CosmosHandler
 type CosmosStatus struct {
 	Result struct {
 		SyncInfo struct {
-			LatestBlockHeight string `json:"latest_block_height"`
-			CatchingUp        bool   `json:"catching_up"`
+			LatestBlockHeight   heightText `json:"latest_block_height"`
+			EarliestBlockHeight heightText `json:"earliest_block_height"`
+			CatchingUp          bool       `json:"catching_up"`
 		} `json:"sync_info"`
 	} `json:"result"`
 }
 
+// CosmosABCIInfo represents the response from Cosmos /abci_info endpoint
+type CosmosABCIInfo struct {
+	Result struct {
+		Response struct {
+			LastBlockHeight heightText `json:"last_block_height"`
+		} `json:"response"`
+	} `json:"result"`
+}
+
 // CosmosRESTSyncing represents the response from Cosmos REST /cosmos/base/tendermint/v1beta1/syncing
 type CosmosRESTSyncing struct {
 	Syncing bool `json:"syncing"`
@@ -70,23 +112,26 @@ func (c *CosmosHandler) CheckHealth(ctx context.Context, node NodeConfig) (*Node
 		zap.String("url", node.URL),
 		zap.String("type", string(node.Type)))
 
-	var blockHeight uint64
+	var blockHeight, earliestHeight uint64
 	var catchingUp bool
 	var err error
 
 	// Check if this is a REST API node or RPC node
-	if node.Metadata["service_type"] == "api" {
+	if node.serviceType() == ServiceTypeAPI {
 		// This is a REST API node - use REST directly
 		c.logger.Debug("using REST API for API node",
 			zap.String("node", node.Name),
 			zap.String("url", node.URL))
-		blockHeight, catchingUp, err = c.checkRESTStatus(ctx, node.URL)
+		blockHeight, catchingUp, health.RESTBasePath, err = c.probeRESTStatus(ctx, node.URL, node.Metadata["rest_base_path"])
+		if err == nil {
+			earliestHeight = c.checkRESTEarliestHeight(ctx, node.URL)
+		}
 	} else {
 		// This is an RPC node - try RPC first, fallback to REST if available
 		c.logger.Debug("using RPC for RPC node",
 			zap.String("node", node.Name),
 			zap.String("url", node.URL))
-		blockHeight, catchingUp, err = c.checkRPCStatus(ctx, node.URL)
+		blockHeight, earliestHeight, catchingUp, err = c.checkRPC(ctx, node.URL, c.probeModeFor(node))
 		if err != nil {
 			c.logger.Debug("RPC check failed, trying REST API fallback",
 				zap.String("node", node.Name),
@@ -95,7 +140,7 @@ func (c *CosmosHandler) CheckHealth(ctx context.Context, node NodeConfig) (*Node
 
 			// If RPC fails and we have an API URL, try REST
 			if node.APIURL != "" {
-				blockHeight, catchingUp, err = c.checkRESTStatus(ctx, node.APIURL)
+				blockHeight, catchingUp, health.RESTBasePath, err = c.probeRESTStatus(ctx, node.APIURL, node.Metadata["rest_base_path"])
 			}
 		}
 	}
@@ -106,6 +151,7 @@ func (c *CosmosHandler) CheckHealth(ctx context.Context, node NodeConfig) (*Node
 			zap.String("url", node.URL),
 			zap.Error(err))
 		health.LastError = err.Error()
+		health.Throttled = errors.Is(err, errThrottled)
 		health.ResponseTime = time.Since(start)
 		return health, nil // Don't return error, just mark as unhealthy
 	}
@@ -129,6 +175,8 @@ func (c *CosmosHandler) CheckHealth(ctx context.Context, node NodeConfig) (*Node
 
 	health.BlockHeight = blockHeight
 	health.CatchingUp = &catchingUp
+	health.EarliestBlockHeight = earliestHeight
+	health.Pruned = earliestHeight > 1
 	health.ResponseTime = time.Since(start)
 
 	// Node is healthy if we got a response and it's not catching up
@@ -145,19 +193,153 @@ func (c *CosmosHandler) CheckHealth(ctx context.Context, node NodeConfig) (*Node
 // GetBlockHeight implements ProtocolHandler for Cosmos nodes
 func (c *CosmosHandler) GetBlockHeight(ctx context.Context, url string) (uint64, error) {
 	// Try RPC first
-	height, _, err := c.checkRPCStatus(ctx, url)
+	height, _, _, err := c.checkRPCStatus(ctx, url)
 	if err != nil {
 		// If this looks like a REST URL, try REST instead
 		// Note: This fallback should rarely be used - prefer explicit service type configuration
 		if strings.Contains(url, "/cosmos/") {
-			height, _, err = c.checkRESTStatus(ctx, url)
+			height, _, _, err = c.probeRESTStatus(ctx, url, "")
 		}
 	}
 	return height, err
 }
 
-// checkRPCStatus checks Cosmos node status via RPC endpoint
-func (c *CosmosHandler) checkRPCStatus(ctx context.Context, url string) (uint64, bool, error) {
+// cosmosNetInfo represents the response from Cosmos /net_info endpoint
+type cosmosNetInfo struct {
+	Result struct {
+		NPeers string `json:"n_peers"`
+	} `json:"result"`
+}
+
+// GetPeerCount implements PeerCounter for Cosmos RPC nodes using /net_info
+func (c *CosmosHandler) GetPeerCount(ctx context.Context, url string) (uint64, error) {
+	netInfoURL := fmt.Sprintf("%s/net_info", strings.TrimSuffix(url, "/"))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, netInfoURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("creating net_info request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("net_info request failed: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			c.logger.Debug("Failed to close response body", zap.Error(err))
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, statusError("net_info", resp.StatusCode)
+	}
+
+	var netInfo cosmosNetInfo
+	if err := decodeJSON(resp, &netInfo); err != nil {
+		return 0, fmt.Errorf("decoding net_info response: %w", err)
+	}
+
+	peers, err := strconv.ParseUint(netInfo.Result.NPeers, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing peer count: %w", err)
+	}
+	return peers, nil
+}
+
+// probeModeFor returns the RPC probe mode for a node, honoring a per-node
+// "probe_mode" metadata override
+func (c *CosmosHandler) probeModeFor(node NodeConfig) string {
+	if mode := node.Metadata["probe_mode"]; mode != "" {
+		return mode
+	}
+	if c.probeMode != "" {
+		return c.probeMode
+	}
+	return CosmosProbeStatus
+}
+
+// checkRPC checks a Cosmos RPC node using the given probe mode and returns the
+// latest height, earliest available height and catching_up. The lighter probes
+// report neither sync status nor pruning, so those are zero values for them.
+func (c *CosmosHandler) checkRPC(ctx context.Context, url, mode string) (uint64, uint64, bool, error) {
+	switch mode {
+	case CosmosProbeHealth:
+		if err := c.checkRPCHealth(ctx, url); err != nil {
+			return 0, 0, false, err
+		}
+		height, err := c.checkABCIInfo(ctx, url)
+		return height, 0, false, err
+	case CosmosProbeABCIInfo:
+		height, err := c.checkABCIInfo(ctx, url)
+		return height, 0, false, err
+	default:
+		return c.checkRPCStatus(ctx, url)
+	}
+}
+
+// checkRPCHealth checks Cosmos node liveness via the RPC /health endpoint
+func (c *CosmosHandler) checkRPCHealth(ctx context.Context, url string) error {
+	healthURL := fmt.Sprintf("%s/health", strings.TrimSuffix(url, "/"))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthURL, nil)
+	if err != nil {
+		return fmt.Errorf("creating health request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("health request failed: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			c.logger.Debug("Failed to close response body", zap.Error(err))
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return statusError("health", resp.StatusCode)
+	}
+	return nil
+}
+
+// checkABCIInfo returns the application's last committed block height via /abci_info
+func (c *CosmosHandler) checkABCIInfo(ctx context.Context, url string) (uint64, error) {
+	abciURL := fmt.Sprintf("%s/abci_info", strings.TrimSuffix(url, "/"))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, abciURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("creating abci_info request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("abci_info request failed: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			c.logger.Debug("Failed to close response body", zap.Error(err))
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, statusError("abci_info", resp.StatusCode)
+	}
+
+	var info CosmosABCIInfo
+	if err := decodeJSON(resp, &info); err != nil {
+		return 0, fmt.Errorf("decoding abci_info response: %w", err)
+	}
+
+	height, err := parseHeight(string(info.Result.Response.LastBlockHeight))
+	if err != nil {
+		return 0, fmt.Errorf("parsing app block height: %w", err)
+	}
+	return height, nil
+}
+
+// checkRPCStatus checks Cosmos node status via RPC endpoint and returns the
+// latest height, earliest available height (0 if unreported) and catching_up
+func (c *CosmosHandler) checkRPCStatus(ctx context.Context, url string) (uint64, uint64, bool, error) {
 	statusURL := fmt.Sprintf("%s/status", strings.TrimSuffix(url, "/"))
 
 	c.logger.Debug("checking RPC status",
@@ -165,7 +347,7 @@ func (c *CosmosHandler) checkRPCStatus(ctx context.Context, url string) (uint64,
 
 	req, err := http.NewRequestWithContext(ctx, "GET", statusURL, nil)
 	if err != nil {
-		return 0, false, fmt.Errorf("creating request: %w", err)
+		return 0, 0, false, fmt.Errorf("creating request: %w", err)
 	}
 
 	resp, err := c.client.Do(req)
@@ -173,7 +355,7 @@ func (c *CosmosHandler) checkRPCStatus(ctx context.Context, url string) (uint64,
 		c.logger.Debug("RPC request failed",
 			zap.String("url", statusURL),
 			zap.Error(err))
-		return 0, false, fmt.Errorf("RPC request failed: %w", err)
+		return 0, 0, false, fmt.Errorf("RPC request failed: %w", err)
 	}
 	defer func(body io.ReadCloser) {
 		if err := body.Close(); err != nil {
@@ -186,40 +368,131 @@ func (c *CosmosHandler) checkRPCStatus(ctx context.Context, url string) (uint64,
 		zap.Int("status_code", resp.StatusCode))
 
 	if resp.StatusCode != http.StatusOK {
-		return 0, false, fmt.Errorf("RPC status %d", resp.StatusCode)
+		return 0, 0, false, statusError("RPC", resp.StatusCode)
 	}
 
 	var status CosmosStatus
-	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+	if err := decodeJSON(resp, &status); err != nil {
 		c.logger.Debug("failed to decode RPC response",
 			zap.String("url", statusURL),
 			zap.Error(err))
-		return 0, false, fmt.Errorf("decoding RPC response: %w", err)
+		return 0, 0, false, fmt.Errorf("decoding RPC response: %w", err)
 	}
 
 	c.logger.Debug("RPC response decoded",
 		zap.String("url", statusURL),
-		zap.String("block_height", status.Result.SyncInfo.LatestBlockHeight),
+		zap.String("block_height", string(status.Result.SyncInfo.LatestBlockHeight)),
 		zap.Bool("catching_up", status.Result.SyncInfo.CatchingUp))
 
-	height, err := strconv.ParseUint(status.Result.SyncInfo.LatestBlockHeight, 10, 64)
+	height, err := parseHeight(string(status.Result.SyncInfo.LatestBlockHeight))
 	if err != nil {
 		c.logger.Debug("failed to parse block height",
 			zap.String("url", statusURL),
-			zap.String("height_string", status.Result.SyncInfo.LatestBlockHeight),
+			zap.String("height_string", string(status.Result.SyncInfo.LatestBlockHeight)),
+			zap.Error(err))
+		return 0, 0, false, fmt.Errorf("parsing block height: %w", err)
+	}
+
+	// Earliest height is absent on older nodes; treat it as unknown
+	earliest, _ := parseHeight(string(status.Result.SyncInfo.EarliestBlockHeight))
+
+	return height, earliest, status.Result.SyncInfo.CatchingUp, nil
+}
+
+// CosmosRESTNodeStatus represents the response from Cosmos REST /cosmos/base/node/v1beta1/status
+type CosmosRESTNodeStatus struct {
+	EarliestStoreHeight heightText `json:"earliest_store_height"`
+}
+
+// checkRESTEarliestHeight returns the earliest stored height of a REST API
+// node, or 0 if the node does not expose it (SDKs before v0.47)
+func (c *CosmosHandler) checkRESTEarliestHeight(ctx context.Context, baseURL string) uint64 {
+	statusURL := fmt.Sprintf("%s/cosmos/base/node/v1beta1/status", strings.TrimSuffix(baseURL, "/"))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, statusURL, nil)
+	if err != nil {
+		return 0
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.logger.Debug("REST node status request failed",
+			zap.String("url", statusURL),
 			zap.Error(err))
-		return 0, false, fmt.Errorf("parsing block height: %w", err)
+		return 0
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			c.logger.Debug("Failed to close response body", zap.Error(err))
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0
+	}
+
+	var status CosmosRESTNodeStatus
+	if err := decodeJSON(resp, &status); err != nil {
+		return 0
 	}
 
-	return height, status.Result.SyncInfo.CatchingUp, nil
+	earliest, _ := parseHeight(string(status.EarliestStoreHeight))
+	return earliest
 }
 
-// checkRESTStatus checks Cosmos node status via REST API
-func (c *CosmosHandler) checkRESTStatus(ctx context.Context, baseURL string) (uint64, bool, error) {
-	baseURL = strings.TrimSuffix(baseURL, "/")
+// probeRESTStatus checks Cosmos node status via REST API and returns the
+// base path that answered. A configured base path is the only one tried.
+// Otherwise the path detected for the URL is tried first, and the other
+// known paths only when it is not served, so a node that is down is not
+// probed once per path.
+func (c *CosmosHandler) probeRESTStatus(ctx context.Context, baseURL, basePath string) (uint64, bool, string, error) {
+	if basePath != "" {
+		height, syncing, err := c.checkRESTStatus(ctx, baseURL, basePath)
+		return height, syncing, basePath, err
+	}
+
+	c.restPathsMutex.Lock()
+	detected := c.restPaths[baseURL]
+	c.restPathsMutex.Unlock()
+
+	candidates := cosmosRESTBasePaths
+	if detected != "" {
+		candidates = append([]string{detected}, slices.DeleteFunc(slices.Clone(candidates), func(path string) bool {
+			return path == detected
+		})...)
+	}
+
+	var firstErr error
+	for _, path := range candidates {
+		height, syncing, err := c.checkRESTStatus(ctx, baseURL, path)
+		if err == nil {
+			if path != detected {
+				c.restPathsMutex.Lock()
+				c.restPaths[baseURL] = path
+				c.restPathsMutex.Unlock()
+				c.logger.Info("detected Cosmos REST base path",
+					zap.String("url", baseURL),
+					zap.String("base_path", path))
+			}
+			return height, syncing, path, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if !errors.Is(err, errRESTPathMissing) {
+			break
+		}
+	}
+	return 0, false, "", firstErr
+}
+
+// checkRESTStatus checks Cosmos node status via the REST endpoints under a
+// base path
+func (c *CosmosHandler) checkRESTStatus(ctx context.Context, baseURL, basePath string) (uint64, bool, error) {
+	baseURL = strings.TrimSuffix(baseURL, "/") + strings.TrimSuffix(basePath, "/")
 
 	// Check syncing status
-	syncingURL := fmt.Sprintf("%s/cosmos/base/tendermint/v1beta1/syncing", baseURL)
+	syncingURL := fmt.Sprintf("%s/syncing", baseURL)
 
 	c.logger.Debug("checking REST syncing status",
 		zap.String("syncing_url", syncingURL))
@@ -250,12 +523,15 @@ func (c *CosmosHandler) checkRESTStatus(ctx context.Context, baseURL string) (ui
 		zap.String("url", syncingURL),
 		zap.Int("status_code", resp.StatusCode))
 
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusNotImplemented {
+		return 0, false, fmt.Errorf("REST syncing status %d: %w", resp.StatusCode, errRESTPathMissing)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return 0, false, fmt.Errorf("REST syncing status %d", resp.StatusCode)
+		return 0, false, statusError("REST syncing", resp.StatusCode)
 	}
 
 	var syncStatus CosmosRESTSyncing
-	if err := json.NewDecoder(resp.Body).Decode(&syncStatus); err != nil {
+	if err := decodeJSON(resp, &syncStatus); err != nil {
 		c.logger.Debug("failed to decode REST syncing response",
 			zap.String("url", syncingURL),
 			zap.Error(err))
@@ -267,7 +543,7 @@ func (c *CosmosHandler) checkRESTStatus(ctx context.Context, baseURL string) (ui
 		zap.Bool("syncing", syncStatus.Syncing))
 
 	// Get latest block height
-	blockURL := fmt.Sprintf("%s/cosmos/base/tendermint/v1beta1/blocks/latest", baseURL)
+	blockURL := fmt.Sprintf("%s/blocks/latest", baseURL)
 
 	c.logger.Debug("checking REST latest block",
 		zap.String("block_url", blockURL))
@@ -299,11 +575,11 @@ func (c *CosmosHandler) checkRESTStatus(ctx context.Context, baseURL string) (ui
 		zap.Int("status_code", resp.StatusCode))
 
 	if resp.StatusCode != http.StatusOK {
-		return 0, false, fmt.Errorf("REST block status %d", resp.StatusCode)
+		return 0, false, statusError("REST block", resp.StatusCode)
 	}
 
 	var blockResp CosmosRESTLatestBlock
-	if err := json.NewDecoder(resp.Body).Decode(&blockResp); err != nil {
+	if err := decodeJSON(resp, &blockResp); err != nil {
 		c.logger.Debug("failed to decode REST block response",
 			zap.String("url", blockURL),
 			zap.Error(err))
@@ -312,13 +588,13 @@ func (c *CosmosHandler) checkRESTStatus(ctx context.Context, baseURL string) (ui
 
 	c.logger.Debug("REST block response decoded",
 		zap.String("url", blockURL),
-		zap.String("height", blockResp.Block.Header.Height))
+		zap.String("height", string(blockResp.Block.Header.Height)))
 
-	height, err := strconv.ParseUint(blockResp.Block.Header.Height, 10, 64)
+	height, err := parseHeight(string(blockResp.Block.Header.Height))
 	if err != nil {
 		c.logger.Debug("failed to parse REST block height",
 			zap.String("url", blockURL),
-			zap.String("height_string", blockResp.Block.Header.Height),
+			zap.String("height_string", string(blockResp.Block.Header.Height)),
 			zap.Error(err))
 		return 0, false, fmt.Errorf("parsing REST block height: %w", err)
 	}
@@ -403,15 +679,18 @@ func (c *CosmosHandler) checkWebSocketHealth(ctx context.Context, wsURL string)
 type EVMHandler struct {
 	client *http.Client
 	logger *zap.Logger
+
+	// Detected execution client per node URL
+	clients      map[string]clientEntry
+	clientsMutex sync.Mutex
 }
 
 // NewEVMHandler creates a new EVM protocol handler
 func NewEVMHandler(timeout time.Duration, logger *zap.Logger) *EVMHandler {
 	return &EVMHandler{
-		client: &http.Client{
-			Timeout: timeout,
-		},
-		logger: logger,
+		client:  newProbeClient(timeout),
+		logger:  logger,
+		clients: make(map[string]clientEntry),
 	}
 }
 
@@ -451,7 +730,7 @@ func (e *EVMHandler) CheckHealth(ctx context.Context, node NodeConfig) (*NodeHea
 		zap.String("service_type", node.Metadata["service_type"]))
 
 	// Check if this is a WebSocket-only node
-	if node.Metadata["service_type"] == "websocket" {
+	if node.serviceType() == ServiceTypeWebSocket {
 		// For WebSocket nodes, look for the corresponding HTTP URL in metadata
 		// This should be set during configuration processing
 		httpURL := node.Metadata["http_url"]
@@ -473,6 +752,7 @@ func (e *EVMHandler) CheckHealth(ctx context.Context, node NodeConfig) (*NodeHea
 		blockHeight, err := e.GetBlockHeight(ctx, httpURL)
 		if err != nil {
 			health.LastError = err.Error()
+			health.Throttled = errors.Is(err, errThrottled)
 			health.ResponseTime = time.Since(start)
 			e.logger.Debug("WebSocket node health check failed via HTTP",
 				zap.String("node", node.Name),
@@ -485,6 +765,8 @@ func (e *EVMHandler) CheckHealth(ctx context.Context, node NodeConfig) (*NodeHea
 		// Health check successful via HTTP, but we'll proxy to WebSocket
 		health.BlockHeight = blockHeight
 		health.Healthy = true
+		e.applySyncing(ctx, health, httpURL)
+		health.Client = string(e.clientFor(ctx, node, httpURL))
 		health.ResponseTime = time.Since(start)
 		e.logger.Debug("WebSocket node health check successful via HTTP",
 			zap.String("node", node.Name),
@@ -501,19 +783,26 @@ func (e *EVMHandler) CheckHealth(ctx context.Context, node NodeConfig) (*NodeHea
 		return health, nil
 	}
 
+	// GraphQL nodes are checked through their GraphQL endpoint
+	if node.serviceType() == ServiceTypeGraphQL {
+		return e.checkGraphQLHealth(ctx, node, health, start), nil
+	}
+
 	// For HTTP/RPC nodes, try to get block height
 	blockHeight, err := e.GetBlockHeight(ctx, node.URL)
 	if err != nil {
 		health.LastError = err.Error()
+		health.Throttled = errors.Is(err, errThrottled)
 		health.ResponseTime = time.Since(start)
 		return health, nil // Don't return error, just mark as unhealthy
 	}
 
 	health.BlockHeight = blockHeight
-	health.ResponseTime = time.Since(start)
 	health.Healthy = true
-	// EVM nodes don't have a "catching up" concept like Cosmos
-	// If we can get a block height, we consider the node healthy
+	// Like Cosmos nodes catching up, syncing nodes are unhealthy
+	e.applySyncing(ctx, health, node.URL)
+	health.Client = string(e.clientFor(ctx, node, node.URL))
+	health.ResponseTime = time.Since(start)
 
 	// Skip WebSocket connectivity testing for regular nodes too
 	// WebSocket health is determined by HTTP JSON-RPC health checks only
@@ -530,28 +819,98 @@ func (e *EVMHandler) CheckHealth(ctx context.Context, node NodeConfig) (*NodeHea
 
 // GetBlockHeight implements ProtocolHandler for EVM nodes
 func (e *EVMHandler) GetBlockHeight(ctx context.Context, url string) (uint64, error) {
+	rpcResp, err := e.callJSONRPC(ctx, url, "eth_blockNumber", []interface{}{})
+	if err != nil {
+		return 0, err
+	}
+
+	height, err := parseHexQuantity(rpcResp.Result)
+	if err != nil {
+		if err == errInvalidQuantityType {
+			return 0, fmt.Errorf("invalid block height response type")
+		}
+		return 0, fmt.Errorf("parsing block height: %w", err)
+	}
+
+	return height, nil
+}
+
+// applySyncing sets CatchingUp from eth_syncing and marks syncing nodes
+// unhealthy. Nodes that fail the call or answer it in an unknown form keep
+// their health, with CatchingUp unset.
+func (e *EVMHandler) applySyncing(ctx context.Context, health *NodeHealth, url string) {
+	syncing, ok := e.checkSyncing(ctx, url)
+	if !ok {
+		return
+	}
+	health.CatchingUp = &syncing
+	health.Healthy = !syncing
+}
+
+// checkSyncing calls eth_syncing, which answers false once the node is in
+// sync and an object with its progress while it syncs. A progress object
+// whose current block reached the highest block counts as in sync, as some
+// clients keep answering one for a while after catching up.
+func (e *EVMHandler) checkSyncing(ctx context.Context, url string) (syncing bool, ok bool) {
+	rpcResp, err := e.callJSONRPC(ctx, url, "eth_syncing", []interface{}{})
+	if err != nil {
+		e.logger.Debug("eth_syncing check failed", zap.String("url", url), zap.Error(err))
+		return false, false
+	}
+
+	switch result := rpcResp.Result.(type) {
+	case bool:
+		return result, true
+	case map[string]interface{}:
+		current, currentErr := parseHexQuantity(result["currentBlock"])
+		highest, highestErr := parseHexQuantity(result["highestBlock"])
+		if currentErr == nil && highestErr == nil && highest > 0 && current >= highest {
+			return false, true
+		}
+		return true, true
+	}
+	return false, false
+}
+
+// GetPeerCount implements PeerCounter for EVM nodes using net_peerCount
+func (e *EVMHandler) GetPeerCount(ctx context.Context, url string) (uint64, error) {
+	rpcResp, err := e.callJSONRPC(ctx, url, "net_peerCount", []interface{}{})
+	if err != nil {
+		return 0, err
+	}
+
+	peers, err := parseHexQuantity(rpcResp.Result)
+	if err != nil {
+		return 0, fmt.Errorf("parsing peer count: %w", err)
+	}
+	return peers, nil
+}
+
+// callJSONRPC performs a single JSON-RPC call and returns the decoded response.
+// JSON-RPC level errors are returned as errors.
+func (e *EVMHandler) callJSONRPC(ctx context.Context, url, method string, params []interface{}) (*EVMJSONRPCResponse, error) {
 	reqBody := EVMJSONRPCRequest{
 		JSONRPC: "2.0",
-		Method:  "eth_blockNumber",
-		Params:  []interface{}{},
+		Method:  method,
+		Params:  params,
 		ID:      1,
 	}
 
 	reqBytes, err := json.Marshal(reqBody)
 	if err != nil {
-		return 0, fmt.Errorf("marshaling request: %w", err)
+		return nil, fmt.Errorf("marshaling request: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(reqBytes)))
 	if err != nil {
-		return 0, fmt.Errorf("creating request: %w", err)
+		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := e.client.Do(req)
 	if err != nil {
-		return 0, fmt.Errorf("JSON-RPC request failed: %w", err)
+		return nil, fmt.Errorf("JSON-RPC request failed: %w", err)
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
@@ -560,53 +919,82 @@ func (e *EVMHandler) GetBlockHeight(ctx context.Context, url string) (uint64, er
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("JSON-RPC status %d", resp.StatusCode)
+		return nil, statusError("JSON-RPC", resp.StatusCode)
 	}
 
+	// Numbers are kept as text so large quantities are not rounded
 	var rpcResp EVMJSONRPCResponse
-	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
-		return 0, fmt.Errorf("decoding JSON-RPC response: %w", err)
+	if err := decodeJSONNumbers(resp, &rpcResp); err != nil {
+		return nil, fmt.Errorf("decoding JSON-RPC response: %w", err)
 	}
 
 	if rpcResp.Error != nil {
-		return 0, fmt.Errorf("JSON-RPC error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
+		return nil, &jsonRPCError{code: rpcResp.Error.Code, message: rpcResp.Error.Message}
 	}
 
-	heightStr, ok := rpcResp.Result.(string)
-	if !ok {
-		return 0, fmt.Errorf("invalid block height response type")
-	}
+	return &rpcResp, nil
+}
 
-	// Remove 0x prefix if present
-	heightStr = strings.TrimPrefix(heightStr, "0x")
+// jsonRPCError is a JSON-RPC level error returned by a node that was reachable
+type jsonRPCError struct {
+	code    int
+	message string
+}
 
-	height, err := strconv.ParseUint(heightStr, 16, 64)
-	if err != nil {
-		return 0, fmt.Errorf("parsing block height: %w", err)
-	}
+func (e *jsonRPCError) Error() string {
+	return fmt.Sprintf("JSON-RPC error %d: %s", e.code, e.message)
+}
 
-	return height, nil
+// Is reports rate-limit errors as errThrottled
+func (e *jsonRPCError) Is(target error) bool {
+	return target == errThrottled && isRateLimitRPCError(e.code, e.message)
+}
+
+// errInvalidQuantityType is returned when a JSON-RPC quantity is neither a
+// string nor a number
+var errInvalidQuantityType = errors.New("invalid quantity response type")
+
+// parseHexQuantity parses a JSON-RPC quantity result: a hex string, with or
+// without the 0x prefix, or a JSON number from nodes that don't follow the
+// spec. Results must be decoded with UseNumber.
+func parseHexQuantity(result interface{}) (uint64, error) {
+	switch quantity := result.(type) {
+	case string:
+		quantity = strings.TrimPrefix(strings.TrimPrefix(quantity, "0x"), "0X")
+		return parseHeight("0x" + quantity)
+	case json.Number:
+		return parseHeight(quantity.String())
+	}
+	return 0, errInvalidQuantityType
 }
 
 // BeaconHandler handles health checks for Ethereum Beacon (consensus) nodes
 type BeaconHandler struct {
 	client *http.Client
 	logger *zap.Logger
+
+	// Detected consensus client per node URL
+	clients      map[string]clientEntry
+	clientsMutex sync.Mutex
 }
 
 // NewBeaconHandler creates a new Beacon protocol handler
 func NewBeaconHandler(timeout time.Duration, logger *zap.Logger) *BeaconHandler {
 	return &BeaconHandler{
-		client: &http.Client{Timeout: timeout},
-		logger: logger,
+		client:  newProbeClient(timeout),
+		logger:  logger,
+		clients: make(map[string]clientEntry),
 	}
 }
 
 // beaconSyncingResponse represents /eth/v1/node/syncing response
 type beaconSyncingResponse struct {
 	Data struct {
-		IsSyncing bool   `json:"is_syncing"`
-		HeadSlot  string `json:"head_slot"`
+		IsSyncing    bool           `json:"is_syncing"`
+		IsOptimistic bool           `json:"is_optimistic"`
+		ELOffline    bool           `json:"el_offline"`
+		HeadSlot     beaconQuantity `json:"head_slot"`
+		SyncDistance beaconQuantity `json:"sync_distance"`
 	} `json:"data"`
 }
 
@@ -615,7 +1003,7 @@ type beaconHeaderResponse struct {
 	Data struct {
 		Header struct {
 			Message struct {
-				Slot string `json:"slot"`
+				Slot heightText `json:"slot"`
 			} `json:"message"`
 		} `json:"header"`
 	} `json:"data"`
@@ -659,48 +1047,108 @@ func (b *BeaconHandler) CheckHealth(ctx context.Context, node NodeConfig) (*Node
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		health.LastError = fmt.Errorf("syncing status %d", resp.StatusCode).Error()
+		health.LastError = statusError("syncing", resp.StatusCode).Error()
+		health.Throttled = resp.StatusCode == http.StatusTooManyRequests
 		health.ResponseTime = time.Since(start)
 		return health, nil
 	}
 
 	var syncResp beaconSyncingResponse
-	if err := json.NewDecoder(resp.Body).Decode(&syncResp); err != nil {
+	if err := decodeJSON(resp, &syncResp); err != nil {
 		b.logger.Debug("failed to decode Beacon syncing response", zap.String("url", syncingURL), zap.Error(err))
 		health.LastError = fmt.Errorf("decoding syncing response: %w", err).Error()
 		health.ResponseTime = time.Since(start)
 		return health, nil
 	}
 
+	// Adjust the check to the node's consensus client
+	client := b.clientFor(ctx, node)
+	quirks := beaconClientQuirks[client]
+	health.Client = string(client)
+
 	// Determine head slot. Some clients provide it here; otherwise fetch header
-	var headSlot uint64
-	if syncResp.Data.HeadSlot != "" {
-		if slotParsed, err := strconv.ParseUint(syncResp.Data.HeadSlot, 10, 64); err == nil {
-			headSlot = slotParsed
-		}
-	}
+	headSlot := uint64(syncResp.Data.HeadSlot)
 
 	if headSlot == 0 {
 		// Fallback: fetch head header for slot number
 		slot, err := b.getHeadSlot(ctx, node.URL)
 		if err != nil {
 			health.LastError = err.Error()
+			health.Throttled = errors.Is(err, errThrottled)
 			health.ResponseTime = time.Since(start)
 			return health, nil
 		}
 		headSlot = slot
 	}
 
-	// Healthy if not syncing and we have a valid head slot
-	catchingUp := syncResp.Data.IsSyncing
+	// Healthy if not syncing and we have a valid head slot. Clients that
+	// report is_syncing close to the head count as synced within their
+	// tolerance.
+	catchingUp := syncResp.Data.IsSyncing && uint64(syncResp.Data.SyncDistance) > quirks.syncTolerance
 	health.BlockHeight = headSlot
 	health.CatchingUp = &catchingUp
 	health.Healthy = !catchingUp && headSlot > 0
+	if health.Healthy && quirks.readinessPath != "" {
+		if err := b.checkReadiness(ctx, node.URL, quirks.readinessPath); err != nil {
+			health.Healthy = false
+			health.LastError = err.Error()
+			health.Throttled = errors.Is(err, errThrottled)
+		}
+	}
+	health.Optimistic = syncResp.Data.IsOptimistic
+	health.ELOffline = syncResp.Data.ELOffline
+	if health.Healthy {
+		b.checkFinality(ctx, node, health)
+		if node.servesEventStream() {
+			b.checkEventStream(ctx, node, health)
+		}
+	}
 	health.ResponseTime = time.Since(start)
 
 	return health, nil
 }
 
+// beaconPeerCountResponse represents /eth/v1/node/peer_count response
+type beaconPeerCountResponse struct {
+	Data struct {
+		Connected string `json:"connected"`
+	} `json:"data"`
+}
+
+// GetPeerCount implements PeerCounter for Beacon nodes (connected peers)
+func (b *BeaconHandler) GetPeerCount(ctx context.Context, baseURL string) (uint64, error) {
+	peerURL := fmt.Sprintf("%s/eth/v1/node/peer_count", strings.TrimSuffix(baseURL, "/"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, peerURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("creating peer_count request: %w", err)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("peer_count request failed: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			b.logger.Debug("Failed to close response body", zap.Error(err))
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, statusError("peer_count", resp.StatusCode)
+	}
+
+	var peerResp beaconPeerCountResponse
+	if err := decodeJSON(resp, &peerResp); err != nil {
+		return 0, fmt.Errorf("decoding peer_count response: %w", err)
+	}
+
+	peers, err := strconv.ParseUint(peerResp.Data.Connected, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing peer count: %w", err)
+	}
+	return peers, nil
+}
+
 // GetBlockHeight implements ProtocolHandler for Beacon nodes (returns head slot)
 func (b *BeaconHandler) GetBlockHeight(ctx context.Context, baseURL string) (uint64, error) {
 	return b.getHeadSlot(ctx, baseURL)
@@ -724,19 +1172,19 @@ func (b *BeaconHandler) getHeadSlot(ctx context.Context, baseURL string) (uint64
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("headers status %d", resp.StatusCode)
+		return 0, statusError("headers", resp.StatusCode)
 	}
 
 	var hdr beaconHeaderResponse
-	if err := json.NewDecoder(resp.Body).Decode(&hdr); err != nil {
+	if err := decodeJSON(resp, &hdr); err != nil {
 		return 0, fmt.Errorf("decoding headers response: %w", err)
 	}
 
-	slotStr := hdr.Data.Header.Message.Slot
+	slotStr := string(hdr.Data.Header.Message.Slot)
 	if slotStr == "" {
 		return 0, fmt.Errorf("empty head slot in headers response")
 	}
-	slot, err := strconv.ParseUint(slotStr, 10, 64)
+	slot, err := parseHeight(slotStr)
 	if err != nil {
 		return 0, fmt.Errorf("parsing head slot: %w", err)
 	}
@@ -2,8 +2,11 @@ package blockchain_health
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -162,6 +165,65 @@ func TestEVMHandler_CheckHealth(t *testing.T) {
 	}
 }
 
+func TestEVMHandler_Syncing(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	tests := []struct {
+		name               string
+		syncing            string
+		expectedHealthy    bool
+		expectedCatchingUp *bool
+	}{
+		{name: "in sync", syncing: `"result": false`, expectedHealthy: true, expectedCatchingUp: boolPtr(false)},
+		{
+			name:               "syncing from genesis",
+			syncing:            `"result": {"startingBlock": "0x0", "currentBlock": "0x1f4", "highestBlock": "0x12d687"}`,
+			expectedHealthy:    false,
+			expectedCatchingUp: boolPtr(true),
+		},
+		{
+			name:               "progress at the head",
+			syncing:            `"result": {"currentBlock": "0x12d687", "highestBlock": "0x12d687"}`,
+			expectedHealthy:    true,
+			expectedCatchingUp: boolPtr(false),
+		},
+		{name: "method not supported", syncing: `"error": {"code": -32601, "message": "method not found"}`, expectedHealthy: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req EVMJSONRPCRequest
+				_ = json.NewDecoder(r.Body).Decode(&req)
+				w.Header().Set("Content-Type", "application/json")
+				switch req.Method {
+				case "eth_syncing":
+					_, _ = w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, ` + tt.syncing + `}`))
+				default:
+					_, _ = w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": "0x12d687"}`))
+				}
+			}))
+			defer server.Close()
+
+			handler := NewEVMHandler(5*time.Second, logger)
+			health, err := handler.CheckHealth(context.Background(), NodeConfig{Name: "test-node", URL: server.URL, Type: NodeTypeEVM})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if health.Healthy != tt.expectedHealthy || health.BlockHeight != 1234567 {
+				t.Errorf("Expected healthy=%v at height 1234567, got %+v", tt.expectedHealthy, health)
+			}
+			switch {
+			case tt.expectedCatchingUp == nil && health.CatchingUp != nil:
+				t.Errorf("Expected catching_up to be unset, got %v", *health.CatchingUp)
+			case tt.expectedCatchingUp != nil && (health.CatchingUp == nil || *health.CatchingUp != *tt.expectedCatchingUp):
+				t.Errorf("Expected catching_up=%v, got %v", *tt.expectedCatchingUp, health.CatchingUp)
+			}
+		})
+	}
+}
+
 func TestCosmosHandler_GetBlockHeight(t *testing.T) {
 	logger := zaptest.NewLogger(t)
 
@@ -195,6 +257,54 @@ func TestCosmosHandler_GetBlockHeight(t *testing.T) {
 	}
 }
 
+func TestCosmosHandler_RESTBasePathDetection(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	// A chain still serving the legacy LCD routes
+	var gatewayProbes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/syncing":
+			_, _ = w.Write([]byte(`{"syncing": false}`))
+		case "/blocks/latest":
+			_, _ = w.Write([]byte(`{"block": {"header": {"height": "4200"}}}`))
+		default:
+			if strings.HasPrefix(r.URL.Path, "/cosmos/base/tendermint/") {
+				gatewayProbes.Add(1)
+			}
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	handler := NewCosmosHandler(5*time.Second, logger)
+	node := NodeConfig{Name: "lcd", URL: server.URL, Type: NodeTypeCosmos, Metadata: map[string]string{"service_type": "api"}}
+
+	for i := range 2 {
+		health, err := handler.CheckHealth(context.Background(), node)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !health.Healthy || health.BlockHeight != 4200 || health.RESTBasePath != "/" {
+			t.Errorf("check %d: expected the legacy routes to be detected, got %+v", i, health)
+		}
+	}
+	// Once detected, the gateway path is not probed again
+	if got := gatewayProbes.Load(); got != 1 {
+		t.Errorf("expected 1 probe of the gateway path, got %d", got)
+	}
+
+	// A configured base path is the only one tried
+	node.Metadata["rest_base_path"] = "/cosmos/base/tendermint/v1beta1"
+	health, err := handler.CheckHealth(context.Background(), node)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if health.Healthy || !strings.Contains(health.LastError, "404") {
+		t.Errorf("expected the configured path to fail, got %+v", health)
+	}
+}
+
 func TestEVMHandler_GetBlockHeight(t *testing.T) {
 	logger := zaptest.NewLogger(t)
 
@@ -2,21 +2,43 @@ package blockchain_health
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
-	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
 )
 
+// defaultHealthCacheTTL is how long a health endpoint response is reused
+const defaultHealthCacheTTL = time.Second
+
+// healthSchemaVersion is the version of the health endpoint response. It is
+// raised when fields are renamed, removed or change meaning; new fields can
+// be added within a version.
+const healthSchemaVersion = 1
+
 // HealthEndpointResponse represents the response structure for the health endpoint
 type HealthEndpointResponse struct {
+	SchemaVersion      int                          `json:"schema_version"`
 	Status             string                       `json:"status"`
+	Reason             string                       `json:"reason,omitempty"`
+	State              PoolState                    `json:"state,omitempty"`
 	Timestamp          time.Time                    `json:"timestamp"`
 	Nodes              NodesStatus                  `json:"nodes"`
 	ExternalReferences map[string]ExternalRefStatus `json:"external_references"`
+	Chains             map[string]ChainHeight       `json:"chains,omitempty"`
+	Scores             map[string]float64           `json:"scores,omitempty"`
+	BlockRanges        map[string]BlockRange        `json:"block_ranges,omitempty"`
+	RESTBasePaths      map[string]string            `json:"rest_base_paths,omitempty"`
+	MempoolDivergence  map[string]float64           `json:"mempool_divergence,omitempty"`
+	MempoolDivergent   []string                     `json:"mempool_divergent,omitempty"`
 	Cache              map[string]interface{}       `json:"cache,omitempty"`
+	NodeDetails        []NodeDetail                 `json:"node_details,omitempty"`
 	LastCheck          time.Time                    `json:"last_check"`
 }
 
@@ -25,6 +47,37 @@ type NodesStatus struct {
 	Total     int `json:"total"`
 	Healthy   int `json:"healthy"`
 	Unhealthy int `json:"unhealthy"`
+
+	// Throttled nodes still in the pool; they are not counted as healthy
+	Throttled int `json:"throttled,omitempty"`
+
+	// Candidates are shadow nodes; they are not counted as healthy or unhealthy
+	Candidates        int `json:"candidates,omitempty"`
+	HealthyCandidates int `json:"healthy_candidates,omitempty"`
+}
+
+// NodeDetail is the health of one node, listed with ?verbose=1
+type NodeDetail struct {
+	Name             string        `json:"name"`
+	URL              string        `json:"url"`
+	Healthy          bool          `json:"healthy"`
+	Candidate        bool          `json:"candidate,omitempty"`
+	Throttled        bool          `json:"throttled,omitempty"`
+	Quarantined      bool          `json:"quarantined,omitempty"`
+	ExternalLagging  bool          `json:"external_lagging,omitempty"`
+	BlockHeight      uint64        `json:"block_height"`
+	BlocksBehindPool int64         `json:"blocks_behind_pool"`
+	LastError        string        `json:"last_error,omitempty"`
+	CircuitBreaker   string        `json:"circuit_breaker"`
+	ResponseTimeMs   float64       `json:"response_time_ms"`
+	LastCheck        time.Time     `json:"last_check"`
+	RecentErrors     []ErrorSample `json:"recent_errors,omitempty"`
+}
+
+// BlockRange is the range of blocks a pruned node can still serve
+type BlockRange struct {
+	Earliest uint64 `json:"earliest"`
+	Latest   uint64 `json:"latest"`
 }
 
 // ExternalRefStatus represents the status of an external reference
@@ -42,13 +95,28 @@ func (b *BlockchainHealthUpstream) ServeHealthEndpoint() http.HandlerFunc {
 			return
 		}
 
+		// Tell load balancers to drain this gateway before it stops
+		if b != nil && b.shuttingDown(r) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_ = json.NewEncoder(w).Encode(&HealthEndpointResponse{
+				SchemaVersion: healthSchemaVersion,
+				Status:        "unhealthy",
+				Reason:        reasonShuttingDown,
+				Timestamp:     time.Now(),
+				LastCheck:     time.Now(),
+			})
+			return
+		}
+
 		// Defensive: if not provisioned yet, report unhealthy instead of risking a panic
 		if b == nil || b.healthChecker == nil || b.config == nil {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusServiceUnavailable)
 			_ = json.NewEncoder(w).Encode(&HealthEndpointResponse{
-				Status:    "unhealthy",
-				Timestamp: time.Now(),
+				SchemaVersion: healthSchemaVersion,
+				Status:        "unhealthy",
+				Timestamp:     time.Now(),
 				Nodes: NodesStatus{
 					Total:     0,
 					Healthy:   0,
@@ -62,48 +130,222 @@ func (b *BlockchainHealthUpstream) ServeHealthEndpoint() http.HandlerFunc {
 		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 		defer cancel()
 
-		response := b.buildHealthResponse(ctx)
+		verbose, _ := strconv.ParseBool(r.URL.Query().Get("verbose"))
+		cached, err := b.cachedHealthResponse(ctx, verbose)
+		if err != nil {
+			b.logger.Error("failed to encode health response", zap.Error(err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("ETag", cached.etag)
 
-		w.Header().Set("Content-Type", "application/json")
+		// Monitors holding the current response only get a 304; unhealthy
+		// responses are always sent in full so the 503 is seen
+		if cached.status == http.StatusOK && etagMatches(r.Header.Get("If-None-Match"), cached.etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
 
 		// Set HTTP status based on overall health
-		if response.Status == "healthy" {
-			w.WriteHeader(http.StatusOK)
-		} else {
-			w.WriteHeader(http.StatusServiceUnavailable)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(cached.status)
+		_, _ = w.Write(cached.body)
+	}
+}
+
+// healthResponseCache holds the last encoded health endpoint response, so
+// monitors polling the endpoint every second do not each rebuild it
+type healthResponseCache struct {
+	mu sync.Mutex
+
+	// The plain and the verbose response, and when each expires
+	responses [2]encodedHealthResponse
+	expires   [2]time.Time
+}
+
+// encodedHealthResponse is a health endpoint response ready to be written
+type encodedHealthResponse struct {
+	body   []byte
+	status int
+	etag   string
+}
+
+// healthCacheTTL returns how long a health endpoint response is reused
+func (b *BlockchainHealthUpstream) healthCacheTTL() time.Duration {
+	if b.config.Monitoring.HealthCacheTTL == "" {
+		return 0
+	}
+	ttl, err := time.ParseDuration(b.config.Monitoring.HealthCacheTTL)
+	if err != nil || ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+// cachedHealthResponse returns the last health endpoint response while it is
+// within health_cache_ttl, and builds a new one otherwise. Requests arriving
+// while a response is built wait for it rather than building their own.
+func (b *BlockchainHealthUpstream) cachedHealthResponse(ctx context.Context, verbose bool) (encodedHealthResponse, error) {
+	ttl := b.healthCacheTTL()
+	if ttl <= 0 {
+		return b.encodeHealthResponse(ctx, verbose)
+	}
+
+	slot := 0
+	if verbose {
+		slot = 1
+	}
+	cache := &b.healthResponse
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.responses[slot].body != nil && time.Now().Before(cache.expires[slot]) {
+		return cache.responses[slot], nil
+	}
+
+	encoded, err := b.encodeHealthResponse(ctx, verbose)
+	if err != nil {
+		return encoded, err
+	}
+	cache.responses[slot] = encoded
+	cache.expires[slot] = time.Now().Add(ttl)
+	return encoded, nil
+}
+
+// encodeHealthResponse builds and encodes the health endpoint response
+func (b *BlockchainHealthUpstream) encodeHealthResponse(ctx context.Context, verbose bool) (encodedHealthResponse, error) {
+	response := b.buildHealthResponse(ctx, verbose)
+	body, err := json.Marshal(response)
+	if err != nil {
+		return encodedHealthResponse{}, err
+	}
+	etag, err := healthETag(response)
+	if err != nil {
+		return encodedHealthResponse{}, err
+	}
+
+	status := http.StatusOK
+	if response.Status != "healthy" {
+		status = http.StatusServiceUnavailable
+	}
+	return encodedHealthResponse{
+		body:   append(body, '\n'),
+		status: status,
+		etag:   etag,
+	}, nil
+}
+
+// healthTag is what the ETag of a health response covers: the health and
+// state of the pool and its nodes. Heights, scores, timings and errors are
+// left out, as they change with every block or check, so the ETag only
+// changes with the pool's health. As responses with the same tag may differ,
+// the ETag is weak.
+type healthTag struct {
+	Status     string          `json:"status"`
+	Reason     string          `json:"reason,omitempty"`
+	State      PoolState       `json:"state,omitempty"`
+	Nodes      NodesStatus     `json:"nodes"`
+	References map[string]bool `json:"references,omitempty"`
+	Divergent  []string        `json:"divergent,omitempty"`
+	Details    []nodeTag       `json:"details,omitempty"`
+}
+
+// nodeTag is the health of one node in a healthTag
+type nodeTag struct {
+	Name            string `json:"name"`
+	URL             string `json:"url"`
+	Healthy         bool   `json:"healthy"`
+	Candidate       bool   `json:"candidate,omitempty"`
+	Throttled       bool   `json:"throttled,omitempty"`
+	Quarantined     bool   `json:"quarantined,omitempty"`
+	ExternalLagging bool   `json:"external_lagging,omitempty"`
+	CircuitBreaker  string `json:"circuit_breaker"`
+}
+
+// healthETag returns the weak ETag of a health response
+func healthETag(response *HealthEndpointResponse) (string, error) {
+	tag := healthTag{
+		Status:    response.Status,
+		Reason:    response.Reason,
+		State:     response.State,
+		Nodes:     response.Nodes,
+		Divergent: response.MempoolDivergent,
+	}
+	for name, ref := range response.ExternalReferences {
+		if tag.References == nil {
+			tag.References = make(map[string]bool, len(response.ExternalReferences))
 		}
+		tag.References[name] = ref.Reachable
+	}
+	for _, detail := range response.NodeDetails {
+		tag.Details = append(tag.Details, nodeTag{
+			Name:            detail.Name,
+			URL:             detail.URL,
+			Healthy:         detail.Healthy,
+			Candidate:       detail.Candidate,
+			Throttled:       detail.Throttled,
+			Quarantined:     detail.Quarantined,
+			ExternalLagging: detail.ExternalLagging,
+			CircuitBreaker:  detail.CircuitBreaker,
+		})
+	}
+	encoded, err := json.Marshal(&tag)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`, nil
+}
 
-		if err := json.NewEncoder(w).Encode(response); err != nil {
-			b.logger.Error("failed to encode health response", zap.Error(err))
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+// etagMatches reports whether an If-None-Match header names the ETag, with
+// weak comparison as RFC 9110 requires for If-None-Match
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
 		}
 	}
+	return false
 }
 
-// buildHealthResponse builds the health endpoint response
-func (b *BlockchainHealthUpstream) buildHealthResponse(ctx context.Context) *HealthEndpointResponse {
+// buildHealthResponse builds the health endpoint response, with the details
+// of every node when verbose
+func (b *BlockchainHealthUpstream) buildHealthResponse(ctx context.Context, verbose bool) *HealthEndpointResponse {
 	// Get current health status
 	healthResults, err := b.healthChecker.CheckAllNodes(ctx)
 	if err != nil {
 		b.logger.Error("health check failed for endpoint", zap.Error(err))
 		return &HealthEndpointResponse{
-			Status:    "unhealthy",
-			Timestamp: time.Now(),
+			SchemaVersion: healthSchemaVersion,
+			Status:        "unhealthy",
+			State:         PoolDown,
+			Timestamp:     time.Now(),
 			Nodes: NodesStatus{
-				Total:     len(b.config.Nodes),
+				Total:     len(b.config.nodeList()),
 				Healthy:   0,
-				Unhealthy: len(b.config.Nodes),
+				Unhealthy: len(b.config.nodeList()),
 			},
 			LastCheck: time.Now(),
 		}
 	}
 
-	// Count healthy and unhealthy nodes
-	var healthyCount, unhealthyCount int
+	// Count healthy and unhealthy nodes, keeping candidates separate
+	var healthyCount, unhealthyCount, throttledCount, candidateCount, healthyCandidateCount int
 	for _, health := range healthResults {
-		if health.Healthy {
+		if b.healthChecker.isCandidate(health.Name) {
+			candidateCount++
+			if health.Healthy {
+				healthyCandidateCount++
+			}
+			continue
+		}
+		switch {
+		case health.Healthy && health.Throttled:
+			throttledCount++
+		case health.Healthy:
 			healthyCount++
-		} else {
+		default:
 			unhealthyCount++
 		}
 	}
@@ -126,42 +368,106 @@ func (b *BlockchainHealthUpstream) buildHealthResponse(ctx context.Context) *Hea
 	}
 
 	response := &HealthEndpointResponse{
-		Status:    status,
-		Timestamp: time.Now(),
+		SchemaVersion: healthSchemaVersion,
+		Status:        status,
+		State:         b.healthChecker.classifyPool(healthResults),
+		Timestamp:     time.Now(),
 		Nodes: NodesStatus{
-			Total:     len(b.config.Nodes),
-			Healthy:   healthyCount,
-			Unhealthy: unhealthyCount,
+			Total:             len(b.config.nodeList()),
+			Healthy:           healthyCount,
+			Unhealthy:         unhealthyCount,
+			Throttled:         throttledCount,
+			Candidates:        candidateCount,
+			HealthyCandidates: healthyCandidateCount,
 		},
 		ExternalReferences: externalRefs,
+		Chains:             b.healthChecker.chainHeightsSnapshot(),
 		LastCheck:          time.Now(),
 	}
 
+	// Add per-node scores when the scoring model is enabled
+	if b.config.Scoring.Enabled {
+		response.Scores = make(map[string]float64, len(healthResults))
+		for _, health := range healthResults {
+			response.Scores[health.Name] = health.Score
+		}
+	}
+
+	// Add per-node mempool divergence and the flagged nodes
+	if b.config.MempoolDivergence.Enabled {
+		response.MempoolDivergence = make(map[string]float64, len(healthResults))
+		for _, health := range healthResults {
+			response.MempoolDivergence[health.Name] = health.MempoolDivergence
+			if health.MempoolDivergent {
+				response.MempoolDivergent = append(response.MempoolDivergent, health.Name)
+			}
+		}
+	}
+
+	// Add usable block ranges for nodes with a known pruning horizon
+	for _, health := range healthResults {
+		if health.EarliestBlockHeight == 0 {
+			continue
+		}
+		if response.BlockRanges == nil {
+			response.BlockRanges = make(map[string]BlockRange)
+		}
+		response.BlockRanges[health.Name] = BlockRange{
+			Earliest: health.EarliestBlockHeight,
+			Latest:   health.BlockHeight,
+		}
+	}
+
+	// Add the REST base paths of Cosmos API nodes
+	for _, health := range healthResults {
+		if health.RESTBasePath == "" {
+			continue
+		}
+		if response.RESTBasePaths == nil {
+			response.RESTBasePaths = make(map[string]string)
+		}
+		response.RESTBasePaths[health.Name] = health.RESTBasePath
+	}
+
 	// Add cache stats if available
 	if b.cache != nil {
 		response.Cache = b.cache.GetStats()
 	}
 
+	if verbose {
+		response.NodeDetails = b.nodeDetails(healthResults)
+	}
+
 	return response
 }
 
-// checkExternalReference checks the status of an external reference
-func (b *BlockchainHealthUpstream) checkExternalReference(ctx context.Context, ref ExternalReference) ExternalRefStatus {
-	var height uint64
-	var err error
-
-	switch ref.Type {
-	case NodeTypeCosmos:
-		height, err = b.healthChecker.cosmosHandler.GetBlockHeight(ctx, ref.URL)
-	case NodeTypeEVM:
-		height, err = b.healthChecker.evmHandler.GetBlockHeight(ctx, ref.URL)
-	default:
-		return ExternalRefStatus{
-			Reachable: false,
-			Error:     fmt.Sprintf("unsupported type: %s", ref.Type),
-		}
+// nodeDetails lists the health of every node, with its circuit breaker state
+func (b *BlockchainHealthUpstream) nodeDetails(healthResults []*NodeHealth) []NodeDetail {
+	details := make([]NodeDetail, 0, len(healthResults))
+	for _, health := range healthResults {
+		details = append(details, NodeDetail{
+			Name:             health.Name,
+			URL:              health.URL,
+			Healthy:          health.Healthy,
+			Candidate:        b.healthChecker.isCandidate(health.Name),
+			Throttled:        health.Throttled,
+			Quarantined:      b.healthChecker.isQuarantined(health.Name),
+			ExternalLagging:  health.ExternalLagging,
+			BlockHeight:      health.BlockHeight,
+			BlocksBehindPool: health.BlocksBehindPool,
+			LastError:        health.LastError,
+			CircuitBreaker:   b.healthChecker.getCircuitBreaker(health.Name).GetState().String(),
+			ResponseTimeMs:   float64(health.ResponseTime) / float64(time.Millisecond),
+			LastCheck:        health.LastCheck,
+			RecentErrors:     b.healthChecker.recentErrors(health.Name),
+		})
 	}
+	return details
+}
 
+// checkExternalReference checks the status of an external reference
+func (b *BlockchainHealthUpstream) checkExternalReference(ctx context.Context, ref ExternalReference) ExternalRefStatus {
+	height, err := b.healthChecker.referenceHeight(ctx, ref)
 	if err != nil {
 		return ExternalRefStatus{
 			Reachable: false,
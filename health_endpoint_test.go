@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
 	"go.uber.org/zap/zaptest"
 )
 
@@ -222,6 +224,153 @@ func TestHealthEndpointResponseStructure(t *testing.T) {
 	}
 }
 
+// TestHealthEndpointCache tests that responses are reused within
+// health_cache_ttl and that If-None-Match is answered with 304
+func TestHealthEndpointCache(t *testing.T) {
+	server := createCosmosServer(t, 12345, false)
+	defer server.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "test-node", URL: server.URL, Type: NodeTypeCosmos, Weight: 1},
+	}, zaptest.NewLogger(t))
+	upstream.config.Monitoring.HealthCacheTTL = "1m"
+	handler := upstream.ServeHealthEndpoint()
+
+	get := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/health", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	first := get("")
+	if first.Code != http.StatusOK || first.Header().Get("ETag") == "" {
+		t.Fatalf("Expected 200 with an ETag, got %d (%q)", first.Code, first.Header().Get("ETag"))
+	}
+
+	// The second response is the cached one, timestamps included
+	time.Sleep(5 * time.Millisecond)
+	if second := get(""); second.Body.String() != first.Body.String() {
+		t.Error("Expected the cached response within the TTL")
+	}
+
+	etag := first.Header().Get("ETag")
+	if w := get(etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("Expected 304 for a matching ETag, got %d", w.Code)
+	}
+	if w := get(`"other", ` + strings.TrimPrefix(etag, "W/")); w.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for a weak match in a list, got %d", w.Code)
+	}
+	if w := get(`"other"`); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 for a stale ETag, got %d", w.Code)
+	}
+
+	// Without a TTL every request builds a new response, with the same ETag
+	// while the pool's health is unchanged
+	upstream.config.Monitoring.HealthCacheTTL = "0"
+	w := get("")
+	if w.Body.String() == first.Body.String() || w.Header().Get("ETag") != etag {
+		t.Errorf("Expected a fresh response with ETag %s, got %s", etag, w.Header().Get("ETag"))
+	}
+}
+
+// TestHealthETag tests that the ETag follows the pool's health, not its
+// heights
+func TestHealthETag(t *testing.T) {
+	response := func(height uint64, healthy bool) *HealthEndpointResponse {
+		return &HealthEndpointResponse{
+			Status:             "healthy",
+			State:              PoolHealthy,
+			Timestamp:          time.Now(),
+			Nodes:              NodesStatus{Total: 1, Healthy: 1},
+			ExternalReferences: map[string]ExternalRefStatus{"reference": {Reachable: true, BlockHeight: height + 1}},
+			Chains:             map[string]ChainHeight{"cosmoshub": {LeaderHeight: height, NetworkHeight: height + 1}},
+			Scores:             map[string]float64{"node": float64(height%7) / 7},
+			BlockRanges:        map[string]BlockRange{"node": {Earliest: 1, Latest: height}},
+			NodeDetails: []NodeDetail{{
+				Name: "node", Healthy: healthy, BlockHeight: height, CircuitBreaker: "closed",
+				ResponseTimeMs: float64(height % 100), LastCheck: time.Now(),
+			}},
+			LastCheck: time.Now(),
+		}
+	}
+
+	first, err := healthETag(response(1000, true))
+	if err != nil {
+		t.Fatalf("healthETag failed: %v", err)
+	}
+	// Responses with the same tag may differ in heights, so the tag is weak
+	if !strings.HasPrefix(first, `W/"`) {
+		t.Errorf("expected a weak ETag, got %s", first)
+	}
+	if next, _ := healthETag(response(1001, true)); next != first {
+		t.Errorf("expected the ETag to stay %s across blocks, got %s", first, next)
+	}
+	if unhealthy, _ := healthETag(response(1001, false)); unhealthy == first {
+		t.Error("expected the ETag to change with a node's health")
+	}
+}
+
+// TestHealthEndpointVerbose tests the schema version and the node details
+// of verbose responses
+func TestHealthEndpointVerbose(t *testing.T) {
+	server := createCosmosServer(t, 12345, false)
+	defer server.Close()
+	down := createCosmosServer(t, 12345, false)
+	down.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "up", URL: server.URL, Type: NodeTypeCosmos, Weight: 1},
+		{Name: "down", URL: down.URL, Type: NodeTypeCosmos, Weight: 1},
+	}, zaptest.NewLogger(t))
+	upstream.config.Monitoring.HealthCacheTTL = "1m"
+	handler := upstream.ServeHealthEndpoint()
+
+	get := func(target string) HealthEndpointResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", target, nil))
+		var response HealthEndpointResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		return response
+	}
+
+	plain := get("/health")
+	if plain.SchemaVersion != healthSchemaVersion || plain.NodeDetails != nil {
+		t.Errorf("Expected schema version %d without node details, got %d with %d details",
+			healthSchemaVersion, plain.SchemaVersion, len(plain.NodeDetails))
+	}
+
+	// Verbose responses are cached apart from plain ones
+	verbose := get("/health?verbose=1")
+	if verbose.SchemaVersion != healthSchemaVersion || len(verbose.NodeDetails) != 2 {
+		t.Fatalf("Expected details of 2 nodes, got %+v", verbose.NodeDetails)
+	}
+	for _, detail := range verbose.NodeDetails {
+		switch detail.Name {
+		case "up":
+			if !detail.Healthy || detail.BlockHeight != 12345 || detail.CircuitBreaker != "closed" {
+				t.Errorf("Unexpected details of the healthy node: %+v", detail)
+			}
+		case "down":
+			if detail.Healthy || detail.LastError == "" {
+				t.Errorf("Expected the down node's last error, got %+v", detail)
+			}
+			if len(detail.RecentErrors) != 1 || detail.RecentErrors[0].Error != detail.LastError || detail.RecentErrors[0].Count != 1 {
+				t.Errorf("Expected the down node's last error in its recent errors, got %+v", detail.RecentErrors)
+			}
+		}
+	}
+	if plain := get("/health"); plain.NodeDetails != nil {
+		t.Error("Expected the cached plain response without node details")
+	}
+}
+
 // TestExternalReferenceCheck tests external reference checking
 func TestExternalReferenceCheck(t *testing.T) {
 	logger := zaptest.NewLogger(t)
@@ -300,3 +449,29 @@ func TestExternalReferenceCheck(t *testing.T) {
 		t.Errorf("Expected block height 12350, got %d", status.BlockHeight)
 	}
 }
+
+func TestHealthEndpointCache_Config(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:8545
+			type evm
+		}
+		health_cache_ttl 500ms
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if b.Monitoring.HealthCacheTTL != "500ms" {
+		t.Errorf("expected health_cache_ttl 500ms, got %q", b.Monitoring.HealthCacheTTL)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+
+	b.Monitoring.HealthCacheTTL = "soon"
+	if err := b.validate(); err == nil {
+		t.Error("expected an invalid health_cache_ttl to be rejected")
+	}
+}
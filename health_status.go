@@ -0,0 +1,94 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// HealthStatus is a handler answering with the bare health of a chain, for
+// legacy hardware load balancers that can't parse the JSON health endpoint:
+// 200 with the body "up", or 503 with the body "down".
+//
+//	GET <path>/<pool>
+//
+// The chain is down while the worst state of the pools sharing its pool
+// state name is at or below DownOn, before they have been checked, and
+// while the gateway drains before shutting down.
+type HealthStatus struct {
+	// Pool state name; defaults to the last element of the request path
+	Pool string `json:"pool,omitempty"`
+
+	// Pool state at or below which the chain is down; defaults to critical
+	DownOn PoolState `json:"down_on,omitempty"`
+}
+
+func init() {
+	caddy.RegisterModule(&HealthStatus{})
+}
+
+// CaddyModule returns the Caddy module information.
+func (*HealthStatus) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.blockchain_health_status",
+		New: func() caddy.Module { return new(HealthStatus) },
+	}
+}
+
+// Provision applies defaults
+func (h *HealthStatus) Provision(ctx caddy.Context) error {
+	if h.DownOn == "" {
+		h.DownOn = PoolCritical
+	}
+	return nil
+}
+
+// Validate checks configuration correctness
+func (h *HealthStatus) Validate() error {
+	if h.DownOn != "" && (!isValidPoolState(string(h.DownOn)) || h.DownOn == PoolHealthy) {
+		return fmt.Errorf("invalid down_on %q (must be degraded, chain_halted, critical or down)", h.DownOn)
+	}
+	return nil
+}
+
+// ServeHTTP answers with the health of the chain. It answers every request
+// itself.
+func (h *HealthStatus) ServeHTTP(w http.ResponseWriter, r *http.Request, _ caddyhttp.Handler) error {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		return caddyhttp.Error(http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	}
+
+	name := h.Pool
+	if name == "" {
+		path := strings.Trim(r.URL.Path, "/")
+		name = path[strings.LastIndex(path, "/")+1:]
+	}
+	live, ok := lookupChainState(name)
+	if !ok {
+		return caddyhttp.Error(http.StatusNotFound, fmt.Errorf("unknown pool %q", name))
+	}
+
+	// A draining gateway is down, so load balancers move off it before it
+	// stops
+	status, body := http.StatusOK, "up"
+	if state, checked := live.state(); !checked || state.severity() >= h.DownOn.severity() ||
+		live.draining.Load() || serverShuttingDown(r) {
+		status, body = http.StatusServiceUnavailable, "down"
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(body + "\n"))
+	return nil
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*HealthStatus)(nil)
+	_ caddy.Validator             = (*HealthStatus)(nil)
+	_ caddyhttp.MiddlewareHandler = (*HealthStatus)(nil)
+)
@@ -0,0 +1,59 @@
+package blockchain_health
+
+import (
+	"fmt"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	httpcaddyfile "github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+func init() {
+	// Register Caddyfile directive for this handler
+	httpcaddyfile.RegisterHandlerDirective("blockchain_health_status", parseHealthStatusCaddyfile)
+	httpcaddyfile.RegisterDirectiveOrder("blockchain_health_status", httpcaddyfile.Before, "reverse_proxy")
+}
+
+func parseHealthStatusCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	hs := new(HealthStatus)
+	if err := hs.UnmarshalCaddyfile(h.Dispenser); err != nil {
+		return nil, err
+	}
+	return hs, nil
+}
+
+// UnmarshalCaddyfile implements caddyfile.Unmarshaler for blockchain_health_status.
+//
+//	blockchain_health_status [<pool>] {
+//		down_on <state>
+//	}
+func (h *HealthStatus) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	for d.Next() {
+		if d.NextArg() {
+			h.Pool = d.Val()
+		}
+		if d.NextArg() {
+			return d.ArgErr()
+		}
+		for d.NextBlock(0) {
+			switch d.Val() {
+			case "down_on":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				h.DownOn = PoolState(d.Val())
+
+			default:
+				return d.Errf("unknown directive: %s", d.Val())
+			}
+		}
+	}
+
+	if err := h.Validate(); err != nil {
+		return fmt.Errorf("blockchain_health_status validation: %w", err)
+	}
+	return nil
+}
+
+// Interface guard
+var _ caddyfile.Unmarshaler = (*HealthStatus)(nil)
@@ -0,0 +1,120 @@
+package blockchain_health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestHealthStatus(t *testing.T) {
+	upstream := createTestUpstream(nil, zaptest.NewLogger(t))
+	upstream.config.PoolState = PoolStateConfig{DegradedBelow: 0.75, CriticalBelow: 0.5}
+	state, err := registerChainState("health-status-test", upstream.healthChecker)
+	if err != nil {
+		t.Fatalf("Failed to register pool state: %v", err)
+	}
+	upstream.healthChecker.state = state
+	defer releaseChainState(state, upstream.healthChecker)
+
+	handler := &HealthStatus{}
+	if err := handler.Provision(caddy.Context{}); err != nil {
+		t.Fatalf("Provision failed: %v", err)
+	}
+	serve := func(method, path string) (*httptest.ResponseRecorder, error) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		return w, handler.ServeHTTP(w, httptest.NewRequest(method, path, nil), nil)
+	}
+	expect := func(status int, body string) {
+		t.Helper()
+		w, err := serve(http.MethodGet, "/lb/health-status-test")
+		if err != nil {
+			t.Fatalf("ServeHTTP failed: %v", err)
+		}
+		if w.Code != status || w.Body.String() != body+"\n" {
+			t.Errorf("expected %d %q, got %d %q", status, body, w.Code, w.Body.String())
+		}
+	}
+
+	// The chain is down until its pools have been checked
+	expect(http.StatusServiceUnavailable, "down")
+
+	upstream.healthChecker.updatePoolState(poolResults(4, 4))
+	expect(http.StatusOK, "up")
+
+	// A degraded pool still takes traffic by default
+	upstream.healthChecker.updatePoolState(poolResults(2, 3))
+	expect(http.StatusOK, "up")
+
+	upstream.healthChecker.updatePoolState(poolResults(1, 3))
+	expect(http.StatusServiceUnavailable, "down")
+
+	handler.DownOn = PoolDegraded
+	upstream.healthChecker.updatePoolState(poolResults(2, 3))
+	expect(http.StatusServiceUnavailable, "down")
+
+	// While the server waits out its shutdown_delay, and once the pool
+	// drains, the chain is down however healthy its pools are
+	handler.DownOn = PoolCritical
+	upstream.healthChecker.updatePoolState(poolResults(4, 4))
+	expect(http.StatusOK, "up")
+
+	repl := caddy.NewReplacer()
+	repl.Set("http.shutting_down", true)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/lb/health-status-test", nil)
+	r = r.WithContext(context.WithValue(r.Context(), caddy.ReplacerCtxKey, repl))
+	if err := handler.ServeHTTP(w, r, nil); err != nil || w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 during the shutdown delay, got %d (%v)", w.Code, err)
+	}
+
+	original := exiting
+	defer func() { exiting = original }()
+	exiting = func() bool { return true }
+	upstream.config.Monitoring.ShutdownDrain = "1s"
+	if drain := upstream.startDrain(); drain != time.Second {
+		t.Fatalf("expected the pool to drain for 1s, got %v", drain)
+	}
+	expect(http.StatusServiceUnavailable, "down")
+
+	if _, err := serve(http.MethodGet, "/lb/missing"); !isHandlerError(err, http.StatusNotFound) {
+		t.Errorf("expected 404 for an unknown pool, got %v", err)
+	}
+	if _, err := serve(http.MethodPost, "/lb/health-status-test"); !isHandlerError(err, http.StatusMethodNotAllowed) {
+		t.Errorf("expected 405 for POST, got %v", err)
+	}
+}
+
+// isHandlerError reports whether err is a handler error with the status
+func isHandlerError(err error, status int) bool {
+	var handlerErr caddyhttp.HandlerError
+	return errors.As(err, &handlerErr) && handlerErr.StatusCode == status
+}
+
+func TestHealthStatus_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health_status osmosis {
+		down_on down
+	}`)
+	var h HealthStatus
+	if err := h.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if h.Pool != "osmosis" || h.DownOn != PoolDown {
+		t.Errorf("unexpected settings: %+v", h)
+	}
+
+	d = caddyfile.NewTestDispenser(`blockchain_health_status {
+		down_on healthy
+	}`)
+	if err := new(HealthStatus).UnmarshalCaddyfile(d); err == nil {
+		t.Error("expected down_on healthy to be rejected")
+	}
+}
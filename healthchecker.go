@@ -2,15 +2,19 @@ package blockchain_health
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 )
 
 // NewHealthChecker creates a new health checker instance
 func NewHealthChecker(config *Config, cache *HealthCache, metrics *Metrics, logger *zap.Logger) *HealthChecker {
+	handlerLogger := newComponentLogger(logger, config.Monitoring, LogComponentHandlers)
+	logger = newComponentLogger(logger, config.Monitoring, LogComponentChecker)
+
 	timeout, err := time.ParseDuration(config.HealthCheck.Timeout)
 	if err != nil || timeout == 0 {
 		// Default to 10 seconds if no timeout specified or invalid
@@ -20,22 +24,77 @@ func NewHealthChecker(config *Config, cache *HealthCache, metrics *Metrics, logg
 		logger.Debug("using configured timeout", zap.Duration("timeout", timeout))
 	}
 
-	return &HealthChecker{
+	cosmosHandler := NewCosmosHandler(timeout, handlerLogger)
+	cosmosHandler.probeMode = config.HealthCheck.ProbeMode
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	h := &HealthChecker{
 		config:          config,
-		cosmosHandler:   NewCosmosHandler(timeout, logger),
-		evmHandler:      NewEVMHandler(timeout, logger),
-		beaconHandler:   NewBeaconHandler(timeout, logger),
+		cosmosHandler:   cosmosHandler,
+		evmHandler:      NewEVMHandler(timeout, handlerLogger),
+		beaconHandler:   NewBeaconHandler(timeout, handlerLogger),
+		explorers:       newExplorerClient(timeout, config.HealthCheck.UserAgent),
 		cache:           cache,
 		metrics:         metrics,
 		logger:          logger,
 		circuitBreakers: make(map[string]*CircuitBreaker),
+		errorWindows:    make(map[string]*errorRateWindow),
+		errorSamples:    make(map[string]*errorSamples),
+		quarantines:     make(map[string]*quarantineState),
+		earliestBlocks:  make(map[string]*earliestBlockState),
+		logsRanges:      make(map[string]*logsRangeState),
+		throttleStates:  make(map[string]*throttleState),
+		mempoolStates:   make(map[string]*mempoolState),
+		nodeSLOs:        make(map[string]*sloState),
+		chainSLOs:       make(map[string]*sloState),
+		lastHealthy:     make(map[string]bool),
+		lastCounted:     make(map[string]time.Time),
+		references:      make(map[string]*referenceFetch),
+		blockTimes:      make(map[string]*blockTimeEstimate),
+		chainHeights:    make(map[string]ChainHeight),
+		inflight:        make(map[string]*nodeCheck),
+		ctx:             ctx,
+		cancel:          cancel,
+	}
+
+	// Tag health checks so node operators can tell them from client traffic
+	h.tagProbes(config.HealthCheck.UserAgent)
+	return h
+}
+
+// Stop aborts background probes started by the health checker and waits for
+// them, and for results being processed, to return, so the pool's shared
+// state can be released after it
+func (h *HealthChecker) Stop() {
+	h.backgroundMutex.Lock()
+	h.cancel()
+	h.backgroundMutex.Unlock()
+	h.background.Wait()
+
+	h.resultsMutex.Lock()
+	defer h.resultsMutex.Unlock()
+}
+
+// goBackground runs fn in a goroutine that Stop waits for. Nothing is started
+// once the health checker is stopped.
+func (h *HealthChecker) goBackground(fn func()) {
+	h.backgroundMutex.Lock()
+	defer h.backgroundMutex.Unlock()
+	if h.ctx.Err() != nil {
+		return
 	}
+	h.background.Add(1)
+	go func() {
+		defer h.background.Done()
+		fn()
+	}()
 }
 
 // CheckAllNodes performs health checks on all configured nodes
 func (h *HealthChecker) CheckAllNodes(ctx context.Context) ([]*NodeHealth, error) {
 	start := time.Now()
-	nodes := h.config.Nodes
+	nodes := h.config.nodeList()
 	if len(nodes) == 0 {
 		return nil, fmt.Errorf("no nodes configured")
 	}
@@ -43,65 +102,123 @@ func (h *HealthChecker) CheckAllNodes(ctx context.Context) ([]*NodeHealth, error
 	h.logger.Debug("starting health checks for all nodes",
 		zap.Int("total_nodes", len(nodes)))
 
-	// Use semaphore pattern to limit concurrent checks
-	sem := make(chan struct{}, h.config.Performance.MaxConcurrentChecks)
-	var wg sync.WaitGroup
-	results := make([]*NodeHealth, len(nodes))
-
-	// Check each node concurrently with rate limiting
+	// Queue the checks on the worker pool, joining checks already in flight
+	checks := make([]*nodeCheck, len(nodes))
 	for i, node := range nodes {
-		wg.Add(1)
-		go func(idx int, n NodeConfig) {
-			defer wg.Done()
-
-			// Acquire semaphore with context cancellation
-			select {
-			case sem <- struct{}{}:
-				defer func() { <-sem }()
-			case <-ctx.Done():
-				// Context cancelled, return early
-				results[idx] = &NodeHealth{
-					Name:      n.Name,
-					URL:       n.URL,
-					Healthy:   false,
-					LastError: ctx.Err().Error(),
-				}
-				return
-			}
-
-			h.logger.Debug("checking node health",
-				zap.String("node", n.Name),
-				zap.String("url", n.URL),
-				zap.String("type", string(n.Type)))
-
-			health := h.checkSingleNode(ctx, n)
-			results[idx] = health
-
-			h.logger.Debug("node health check completed",
-				zap.String("node", n.Name),
-				zap.Bool("healthy", health.Healthy),
-				zap.String("error", health.LastError))
-		}(i, node)
+		checks[i] = h.startCheck(node, false)
 	}
 
-	wg.Wait()
+	results := make([]*NodeHealth, len(nodes))
+	for i, check := range checks {
+		results[i] = check.wait(ctx)
+	}
 
 	h.logger.Debug("all health checks completed",
 		zap.Int("total_nodes", len(nodes)),
 		zap.Int("healthy_nodes", countHealthyNodes(results)))
 
+	h.processResults(results)
+	if h.metrics != nil {
+		h.metrics.RecordCheckDuration(time.Since(start).Seconds())
+		h.metrics.sweepDuration.WithLabelValues(h.poolName()).Observe(time.Since(start).Seconds())
+	}
+
+	return results, nil
+}
+
+// processResults compares the node results with each other and updates the
+// metrics. Results are published in the cache and read by requests, so they
+// are replaced with processed copies, both in results and in the cache. Runs
+// are serialized because they replace the shared results.
+func (h *HealthChecker) processResults(results []*NodeHealth) {
+	h.refreshReferences()
+
+	h.resultsMutex.Lock()
+	defer h.resultsMutex.Unlock()
+	// Results of a stopped pool are left alone, as its state is released
+	if h.ctx.Err() != nil {
+		return
+	}
+
+	updates := make([]cacheUpdate, 0, len(results))
+	for i, health := range results {
+		if health == nil {
+			continue
+		}
+		processed := *health
+		results[i] = &processed
+		updates = append(updates, cacheUpdate{key: h.nodeKey(health.Name), old: health, replaced: &processed})
+	}
+	defer h.cache.replace(updates)
+
 	// Post-process: validate block heights and update metrics
 	if err := h.validateBlockHeights(results); err != nil {
 		h.logger.Warn("block height validation failed", zap.Error(err))
 	}
 
+	// Replace the binary height decision with weighted scores when enabled
+	if h.scoringEnabled() {
+		h.applyScores(results)
+	}
+
+	// Flag nodes whose mempool diverges from the pool
+	h.applyMempoolDivergence(results)
+
+	// Track the SLOs of the chains and flag nodes burning their error budget
+	h.recordChainSLOs(results)
+	h.applySLOs(results)
+
+	// Add the checks to the periodic health report
+	h.recordReport(results)
+
+	// Move WebSocket clients off nodes that just turned unhealthy
+	changed := h.healthChanges(results)
+	h.drainUnhealthySessions(changed)
+
+	// Quarantine nodes that flap, and release those whose cool-down elapsed
+	now := time.Now()
+	h.trackFlapping(changed, now)
+	names := make([]string, 0, len(results))
+	for _, health := range results {
+		if health != nil {
+			names = append(names, health.Name)
+		}
+	}
+	h.applyQuarantine(names, now)
+
+	// Tell event subscribers about nodes that changed health
+	h.emitHealthChanges(changed)
+
+	// Track the degradation tier of the pool
+	h.updatePoolState(results)
+
+	// Track the client mix of the pool
+	h.updateClientMix(results)
+
 	// Update metrics
 	if h.metrics != nil {
 		h.updateMetrics(results)
-		h.metrics.RecordCheckDuration(time.Since(start).Seconds())
 	}
+}
 
-	return results, nil
+// healthChanges returns the nodes whose health changed since their previous
+// check. A node's first check is not a change.
+func (h *HealthChecker) healthChanges(results []*NodeHealth) []*NodeHealth {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var changed []*NodeHealth
+	for _, health := range results {
+		if health == nil {
+			continue
+		}
+		key := h.nodeKey(health.Name)
+		if wasHealthy, seen := h.lastHealthy[key]; seen && wasHealthy != health.Healthy {
+			changed = append(changed, health)
+		}
+		h.lastHealthy[key] = health.Healthy
+	}
+	return changed
 }
 
 // countHealthyNodes counts the number of healthy nodes
@@ -121,11 +238,16 @@ func countHealthyNodes(results []*NodeHealth) int {
 // checkSingleNode performs health check on a single node with caching and circuit breaker
 func (h *HealthChecker) checkSingleNode(ctx context.Context, node NodeConfig) *NodeHealth {
 	// Check cache first
-	if cached := h.cache.Get(node.Name); cached != nil {
+	if cached := h.cache.Get(node.key()); cached != nil {
 		h.logger.Debug("using cached health result", zap.String("node", node.Name))
 		return cached
 	}
 
+	return h.probeNode(ctx, node)
+}
+
+// probeNode checks a node, bypassing the cache
+func (h *HealthChecker) probeNode(ctx context.Context, node NodeConfig) *NodeHealth {
 	// Check circuit breaker
 	breaker := h.getCircuitBreaker(node.Name)
 	if !breaker.CanExecute() {
@@ -139,18 +261,35 @@ func (h *HealthChecker) checkSingleNode(ctx context.Context, node NodeConfig) *N
 		}
 	}
 
-	// Perform health check with retry
-	health := h.checkWithRetry(ctx, node)
+	// Perform health check with retry on each of the node's URLs
+	health := h.checkEndpoints(ctx, node)
+	h.injectChaos(ctx, node, health)
+	if health.LastError != "" {
+		h.recordErrorSample(node.Name, health.LastError)
+	}
 
-	// Update circuit breaker
-	if health.Healthy {
-		breaker.RecordSuccess()
-	} else {
-		breaker.RecordFailure()
+	if health.Throttled {
+		h.handleThrottled(node, health)
+		return health
+	}
+	h.recordUnthrottled(node, health)
+
+	// Update the error rate window and circuit breaker
+	h.recordCheckOutcome(node.Name, health.Healthy)
+	h.recordNodeSLO(node, health)
+
+	// Collect additional scoring signals, from the endpoint routed to
+	endpoint := node
+	if len(node.AlternateURLs) > 0 {
+		endpoint.URL = health.URL
 	}
+	h.collectPeerCount(ctx, endpoint, health)
+	h.collectFinalizedHeight(ctx, endpoint, health)
+	h.trackEarliestBlock(node, health)
+	h.trackLogsRange(node, health)
 
 	// Cache the result
-	h.cache.Set(node.Name, health)
+	h.cache.Set(node.key(), health)
 
 	return health
 }
@@ -193,8 +332,8 @@ func (h *HealthChecker) checkWithRetry(ctx context.Context, node NodeConfig) *No
 				zap.Error(err))
 		} else {
 			lastHealth = health
-			if health.Healthy {
-				// Success, no need to retry
+			if health.Healthy || health.Throttled {
+				// Success, or the node asked us to slow down; no need to retry
 				break
 			}
 		}
@@ -243,13 +382,10 @@ func (h *HealthChecker) validateBlockHeights(healthResults []*NodeHealth) error
 		}
 
 		// Find the node config to get the chain type
-		for _, node := range h.config.Nodes {
+		for _, node := range h.config.nodeList() {
 			if node.Name == health.Name {
-				chainType := node.ChainType
-				if chainType == "" {
-					// Fallback to generic grouping if no chain type specified
-					chainType = string(node.Type)
-				}
+				// Fallback to generic grouping if no chain type specified
+				chainType := nodeChain(node)
 
 				// Group nodes by their specific chain type
 				if chainGroups[chainType] == nil {
@@ -266,7 +402,8 @@ func (h *HealthChecker) validateBlockHeights(healthResults []*NodeHealth) error
 	for chainType, nodes := range chainGroups {
 		if len(nodes) > 0 {
 			nodeType := chainNodeTypes[chainType]
-			if err := h.validateNodeGroup(nodes, nodeType); err != nil {
+			h.observeHeight(chainType, h.leaderHeight(nodes), lastChecked(nodes))
+			if err := h.validateNodeGroup(nodes, chainType, nodeType); err != nil {
 				h.logger.Warn("chain node validation failed",
 					zap.String("chain_type", chainType),
 					zap.String("node_type", string(nodeType)),
@@ -282,29 +419,61 @@ func (h *HealthChecker) validateBlockHeights(healthResults []*NodeHealth) error
 	return nil
 }
 
-// validateNodeGroup validates block heights within a group of nodes of the same type
-func (h *HealthChecker) validateNodeGroup(nodes []*NodeHealth, nodeType NodeType) error {
-	if len(nodes) <= 1 {
-		return nil // Nothing to validate
-	}
-
-	// Find the highest block height in the group
-	var maxHeight uint64
+// leaderHeight returns the highest block height in a group of nodes of the
+// same chain. Candidate nodes are compared against the pool but never set the
+// leader height unless they are all we have.
+func (h *HealthChecker) leaderHeight(nodes []*NodeHealth) uint64 {
+	var maxHeight, candidateMaxHeight uint64
 	for _, node := range nodes {
+		if node.Throttled {
+			continue
+		}
+		if h.isCandidate(node.Name) {
+			if node.BlockHeight > candidateMaxHeight {
+				candidateMaxHeight = node.BlockHeight
+			}
+			continue
+		}
 		if node.BlockHeight > maxHeight {
 			maxHeight = node.BlockHeight
 		}
 	}
+	if maxHeight == 0 {
+		return candidateMaxHeight
+	}
+	return maxHeight
+}
+
+// validateNodeGroup validates block heights within a group of nodes of the same chain
+func (h *HealthChecker) validateNodeGroup(nodes []*NodeHealth, chain string, nodeType NodeType) error {
+	if len(nodes) <= 1 {
+		// A lone node is its own leader
+		for _, node := range nodes {
+			node.HeightValid = true
+		}
+		h.recordChainHeight(chain, h.leaderHeight(nodes), 0)
+		return nil
+	}
 
-	// Check each node against the pool leader
-	threshold := uint64(h.config.BlockValidation.HeightThreshold)
+	maxHeight := h.leaderHeight(nodes)
+
+	// Check each node against the pool leader. While the chain is halted
+	// every node is stuck, so none is ejected for lagging.
+	threshold := uint64(h.heightThreshold(chain))
+	halted := h.chainHalted(chain)
 	for _, node := range nodes {
-		blocksBehind := int64(maxHeight - node.BlockHeight)
+		// Throttled nodes have no fresh height to compare
+		if node.Throttled {
+			node.HeightValid = true
+			continue
+		}
+
+		blocksBehind := int64(maxHeight) - int64(node.BlockHeight)
 		node.BlocksBehindPool = blocksBehind
 
-		if blocksBehind > int64(threshold) {
+		if blocksBehind > int64(threshold) && !halted {
 			node.HeightValid = false
-			node.Healthy = false // Mark as unhealthy if too far behind
+			node.Healthy = false // Mark as unhealthy if too far behind; scoring only ranks nodes within the threshold
 			h.logger.Warn("node too far behind pool",
 				zap.String("node", node.Name),
 				zap.Uint64("node_height", node.BlockHeight),
@@ -316,41 +485,112 @@ func (h *HealthChecker) validateNodeGroup(nodes []*NodeHealth, nodeType NodeType
 	}
 
 	// Validate against external references if configured
+	networkHead := maxHeight
+	var referenceHeight uint64
+	for _, node := range nodes {
+		node.ExternalLagging = false
+	}
 	for _, ref := range h.config.ExternalReferences {
 		if ref.Type == nodeType && ref.Enabled {
-			if err := h.validateAgainstExternal(nodes, ref); err != nil {
-				h.logger.Warn("external reference validation failed",
+			externalHeight, err := h.validateAgainstExternal(nodes, ref, maxHeight)
+			if errors.Is(err, errReferencePending) {
+				continue
+			}
+			if err != nil {
+				message := "external reference validation failed"
+				if errors.Is(err, errImplausibleReference) {
+					message = "external reference discarded"
+				}
+				h.logger.Warn(message,
 					zap.String("reference", ref.Name),
 					zap.Error(err))
+				continue
+			}
+			if externalHeight > referenceHeight {
+				referenceHeight = externalHeight
+			}
+			if externalHeight > networkHead {
+				networkHead = externalHeight
 			}
 		}
 	}
+	h.recordChainHeight(chain, maxHeight, referenceHeight)
+
+	if h.config.BlockValidation.StrictLeaderOnly && !halted {
+		h.applyStrictLeader(nodes, networkHead)
+	}
+
+	if nodeType == NodeTypeBeacon {
+		h.validateFinality(nodes)
+	}
+	if nodeType == NodeTypeEVM && h.config.BlockValidation.TrackFinality {
+		h.validateFinalizedHeights(nodes)
+	}
 
 	return nil
 }
 
-// validateAgainstExternal validates nodes against an external reference
-func (h *HealthChecker) validateAgainstExternal(nodes []*NodeHealth, ref ExternalReference) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// defaultReferenceMaxAhead is how many blocks an external reference may be
+// ahead of the pool leader before it is discarded as implausible
+const defaultReferenceMaxAhead = 1000
+
+// Reasons an external reference is discarded, used as metric labels
+const (
+	referenceBehindPool  = "behind_pool"
+	referenceTooFarAhead = "too_far_ahead"
+)
 
-	var externalHeight uint64
-	var err error
+// errImplausibleReference is returned for a reference height too far from
+// the pool leader's to be trusted
+var errImplausibleReference = errors.New("implausible external reference height")
 
-	// Get external reference height
-	switch ref.Type {
-	case NodeTypeCosmos:
-		externalHeight, err = h.cosmosHandler.GetBlockHeight(ctx, ref.URL)
-	case NodeTypeEVM:
-		externalHeight, err = h.evmHandler.GetBlockHeight(ctx, ref.URL)
-	case NodeTypeBeacon:
-		externalHeight, err = h.beaconHandler.GetBlockHeight(ctx, ref.URL)
-	default:
-		return fmt.Errorf("unsupported external reference type: %s", ref.Type)
+// referenceMaxAhead returns how many blocks a reference may be ahead of the
+// pool leader
+func (h *HealthChecker) referenceMaxAhead() uint64 {
+	if h.config.BlockValidation.ReferenceMaxAhead > 0 {
+		return uint64(h.config.BlockValidation.ReferenceMaxAhead)
+	}
+	return defaultReferenceMaxAhead
+}
+
+// implausibleReference returns why a reference height can't be trusted
+// against the pool leader's, or "" if it can. A reference behind the pool
+// is stale, and one far ahead is more likely another chain, e.g. an
+// explorer of the wrong network, than the whole pool stuck; either would
+// flag every node. Without a leader height there is nothing to compare.
+func (h *HealthChecker) implausibleReference(externalHeight, poolHeight uint64) string {
+	if poolHeight == 0 {
+		return ""
+	}
+	if externalHeight+uint64(h.config.BlockValidation.ExternalReferenceThreshold) < poolHeight {
+		return referenceBehindPool
 	}
+	if externalHeight > poolHeight+h.referenceMaxAhead() {
+		return referenceTooFarAhead
+	}
+	return ""
+}
 
+// validateAgainstExternal validates nodes against the last fetched height of
+// an external reference and returns it. A reference implausibly far from the
+// pool leader at poolHeight is discarded without touching the nodes.
+func (h *HealthChecker) validateAgainstExternal(nodes []*NodeHealth, ref ExternalReference, poolHeight uint64) (uint64, error) {
+	externalHeight, err := h.cachedReferenceHeight(ref)
 	if err != nil {
-		return fmt.Errorf("failed to get external reference height: %w", err)
+		return 0, fmt.Errorf("failed to get external reference height: %w", err)
+	}
+	if reason := h.implausibleReference(externalHeight, poolHeight); reason != "" {
+		if h.metrics != nil {
+			h.metrics.referencesDiscarded.WithLabelValues(ref.Name, reason).Inc()
+			// Lag against a discarded reference is meaningless
+			h.metrics.blocksBehindExternal.DeletePartialMatch(prometheus.Labels{"reference": ref.Name})
+			h.metrics.referenceHeight.DeleteLabelValues(ref.Name)
+		}
+		return 0, fmt.Errorf("%w: %s at %d, pool leader at %d", errImplausibleReference, reason, externalHeight, poolHeight)
+	}
+
+	if h.metrics != nil {
+		h.metrics.referenceHeight.WithLabelValues(ref.Name).Set(float64(externalHeight))
 	}
 
 	// Check each node against external reference
@@ -358,9 +598,13 @@ func (h *HealthChecker) validateAgainstExternal(nodes []*NodeHealth, ref Externa
 	for _, node := range nodes {
 		blocksBehind := int64(externalHeight - node.BlockHeight)
 		node.BlocksBehindExternal = blocksBehind
+		if h.metrics != nil {
+			h.metrics.blocksBehindExternal.WithLabelValues(node.Name, ref.Name).Set(float64(blocksBehind))
+		}
 
 		if blocksBehind > int64(threshold) {
 			node.ExternalReferenceValid = false
+			node.ExternalLagging = !node.Throttled
 			h.logger.Warn("node too far behind external reference",
 				zap.String("node", node.Name),
 				zap.String("reference", ref.Name),
@@ -372,21 +616,60 @@ func (h *HealthChecker) validateAgainstExternal(nodes []*NodeHealth, ref Externa
 		}
 	}
 
-	return nil
+	return externalHeight, nil
+}
+
+// referenceHeight returns the latest height of an external reference, from
+// its explorer's API or as a node of its type
+func (h *HealthChecker) referenceHeight(ctx context.Context, ref ExternalReference) (uint64, error) {
+	if ref.Explorer != "" {
+		return h.explorers.height(ctx, ref)
+	}
+	switch ref.Type {
+	case NodeTypeCosmos:
+		return h.cosmosHandler.GetBlockHeight(ctx, ref.URL)
+	case NodeTypeEVM:
+		return h.evmHandler.GetBlockHeight(ctx, ref.URL)
+	case NodeTypeBeacon:
+		return h.beaconHandler.GetBlockHeight(ctx, ref.URL)
+	}
+	return 0, fmt.Errorf("unsupported external reference type: %s", ref.Type)
+}
+
+// isCandidate reports whether the named node is configured as a shadow candidate
+func (h *HealthChecker) isCandidate(nodeName string) bool {
+	for _, node := range h.config.nodeList() {
+		if node.Name == nodeName {
+			return node.isCandidate()
+		}
+	}
+	return false
+}
+
+// nodeKey returns the key of the named node, or the name itself if no node
+// has that name
+func (h *HealthChecker) nodeKey(nodeName string) string {
+	for _, node := range h.config.nodeList() {
+		if node.Name == nodeName {
+			return node.key()
+		}
+	}
+	return nodeName
 }
 
 // getCircuitBreaker gets or creates a circuit breaker for a node
 func (h *HealthChecker) getCircuitBreaker(nodeName string) *CircuitBreaker {
+	key := h.nodeKey(nodeName)
 	h.mutex.RLock()
-	breaker, exists := h.circuitBreakers[nodeName]
+	breaker, exists := h.circuitBreakers[key]
 	h.mutex.RUnlock()
 
 	if !exists {
 		h.mutex.Lock()
 		// Double-check after acquiring write lock
-		if breaker, exists = h.circuitBreakers[nodeName]; !exists {
-			breaker = NewCircuitBreaker(int(h.config.FailureHandling.CircuitBreakerThreshold * 10))
-			h.circuitBreakers[nodeName] = breaker
+		if breaker, exists = h.circuitBreakers[key]; !exists {
+			breaker = NewErrorRateCircuitBreaker(h.config.FailureHandling.CircuitBreakerThreshold, h.errorRateMinSamples())
+			h.circuitBreakers[key] = breaker
 		}
 		h.mutex.Unlock()
 	}
@@ -396,18 +679,39 @@ func (h *HealthChecker) getCircuitBreaker(nodeName string) *CircuitBreaker {
 
 // updateMetrics updates prometheus metrics based on health check results
 func (h *HealthChecker) updateMetrics(results []*NodeHealth) {
-	var healthyCount, unhealthyCount int
+	var healthyCount, unhealthyCount, healthyCandidates, unhealthyCandidates, checks int
 
 	for _, health := range results {
-		if health.Healthy {
+		// Candidates are reported separately so they don't skew the pool totals
+		switch {
+		case h.isCandidate(health.Name) && health.Healthy:
+			healthyCandidates++
+		case h.isCandidate(health.Name):
+			unhealthyCandidates++
+		case health.Healthy:
 			healthyCount++
-		} else {
+		default:
 			unhealthyCount++
 		}
 
 		// Update individual node metrics
 		h.metrics.blockHeightGauge.WithLabelValues(health.Name).Set(float64(health.BlockHeight))
+		h.metrics.blocksBehindPool.WithLabelValues(health.Name).Set(float64(health.BlocksBehindPool))
+		heightValid := 0.0
+		if health.HeightValid {
+			heightValid = 1
+		}
+		h.metrics.heightValid.WithLabelValues(health.Name).Set(heightValid)
+		if dial := nodeDial(health.URL); dial != "" {
+			h.metrics.wsConnections.WithLabelValues(health.Name).Set(float64(wsSessions.count(dial)))
+		}
 
+		// Results are processed again with the checks of other nodes, so only
+		// new checks are counted
+		if !h.countCheck(health) {
+			continue
+		}
+		checks++
 		if health.LastError != "" {
 			h.metrics.errorCount.WithLabelValues(health.Name, "health_check").Inc()
 		}
@@ -415,5 +719,21 @@ func (h *HealthChecker) updateMetrics(results []*NodeHealth) {
 
 	h.metrics.healthyNodes.Set(float64(healthyCount))
 	h.metrics.unhealthyNodes.Set(float64(unhealthyCount))
-	h.metrics.totalChecks.Inc()
+	h.metrics.healthyCandidates.Set(float64(healthyCandidates))
+	h.metrics.unhealthyCandidates.Set(float64(unhealthyCandidates))
+	h.metrics.totalChecks.Add(float64(checks))
+}
+
+// countCheck reports whether a result comes from a check not counted yet.
+// Callers must hold resultsMutex.
+func (h *HealthChecker) countCheck(health *NodeHealth) bool {
+	if health.LastCheck.IsZero() {
+		return true
+	}
+	key := h.nodeKey(health.Name)
+	if !health.LastCheck.After(h.lastCounted[key]) {
+		return false
+	}
+	h.lastCounted[key] = health.LastCheck
+	return true
 }
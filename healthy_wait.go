@@ -0,0 +1,83 @@
+package blockchain_health
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// healthWaitPollInterval is how often a held request re-reads node health
+const healthWaitPollInterval = 100 * time.Millisecond
+
+// maxWait returns how long requests may be held while no node is healthy
+func (b *BlockchainHealthUpstream) maxWait() time.Duration {
+	if b.config.FailureHandling.MaxWait == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(b.config.FailureHandling.MaxWait)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// hasHealthyPoolNode reports whether any non-candidate node is healthy
+func (b *BlockchainHealthUpstream) hasHealthyPoolNode(results []*NodeHealth) bool {
+	for _, health := range results {
+		if health.Healthy && !b.healthChecker.isCandidate(health.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// waitForHealthyNodes holds a request for up to max_wait while no node is
+// healthy, polling health until one recovers. It returns the latest results,
+// which still have no healthy node if the wait ran out.
+func (b *BlockchainHealthUpstream) waitForHealthyNodes(ctx context.Context, results []*NodeHealth) []*NodeHealth {
+	wait := b.maxWait()
+	if wait <= 0 || b.hasHealthyPoolNode(results) {
+		return results
+	}
+
+	start := time.Now()
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	ticker := time.NewTicker(healthWaitPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			b.recordHealthWait("cancelled", start)
+			return results
+		case <-timer.C:
+			b.recordHealthWait("timeout", start)
+			return results
+		case <-ticker.C:
+			fresh := b.getCachedHealthResults()
+			if len(fresh) == 0 {
+				var err error
+				if fresh, err = b.healthChecker.CheckAllNodes(ctx); err != nil {
+					continue
+				}
+			}
+			results = b.routeResults(ctx, fresh)
+			if b.hasHealthyPoolNode(results) {
+				b.recordHealthWait("recovered", start)
+				return results
+			}
+		}
+	}
+}
+
+// recordHealthWait logs and counts the outcome of a held request
+func (b *BlockchainHealthUpstream) recordHealthWait(outcome string, start time.Time) {
+	b.logger.Debug("finished waiting for a healthy node",
+		zap.String("outcome", outcome),
+		zap.Duration("waited", time.Since(start)))
+	if b.metrics != nil {
+		b.metrics.healthWaits.WithLabelValues(outcome).Inc()
+	}
+}
@@ -0,0 +1,66 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestGetUpstreams_WaitsForHealthyNode(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	var healthy atomic.Bool
+	recovering := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `{"result":{"sync_info":{"latest_block_height":"1000","catching_up":false}}}`)
+	}))
+	defer recovering.Close()
+
+	nodes := []NodeConfig{
+		{Name: "recovering", URL: recovering.URL, Type: NodeTypeCosmos, Weight: 1},
+		{Name: "down", URL: "http://127.0.0.1:1", Type: NodeTypeCosmos, Weight: 1},
+	}
+
+	t.Run("RecoversWithinMaxWait", func(t *testing.T) {
+		healthy.Store(false)
+		upstream := createTestUpstream(nodes, logger)
+		upstream.config.FailureHandling.MaxWait = "5s"
+
+		time.AfterFunc(200*time.Millisecond, func() { healthy.Store(true) })
+
+		upstreams, err := upstream.GetUpstreams(&http.Request{})
+		if err != nil {
+			t.Fatalf("GetUpstreams failed: %v", err)
+		}
+		if len(upstreams) != 1 || upstreams[0].Dial != getDynamicTestHostFromURL(recovering.URL) {
+			t.Errorf("Expected only the recovered node, got %v", upstreams)
+		}
+	})
+
+	t.Run("FallsBackAfterMaxWait", func(t *testing.T) {
+		healthy.Store(false)
+		upstream := createTestUpstream(nodes, logger)
+		upstream.config.FailureHandling.MaxWait = "200ms"
+
+		start := time.Now()
+		upstreams, err := upstream.GetUpstreams(&http.Request{})
+		if err != nil {
+			t.Fatalf("GetUpstreams failed: %v", err)
+		}
+		if waited := time.Since(start); waited < 200*time.Millisecond {
+			t.Errorf("Expected the request to be held for max_wait, returned after %v", waited)
+		}
+		if len(upstreams) != 2 {
+			t.Errorf("Expected fallback to all nodes after max_wait, got %d upstreams", len(upstreams))
+		}
+	})
+}
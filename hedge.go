@@ -0,0 +1,528 @@
+package blockchain_health
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Hedging defaults
+const (
+	defaultHedgePercentile  = 99
+	defaultHedgeMinDelay    = 5 * time.Millisecond
+	defaultHedgeMaxDelay    = 2 * time.Second
+	defaultHedgeBudget      = 0.05
+	defaultHedgeMaxBodySize = 1 << 20
+
+	// hedgeWindow is the number of recent latencies the delay is derived from
+	hedgeWindow = 1024
+	// hedgeMinSamples latencies are needed before requests are hedged
+	hedgeMinSamples = 100
+	// hedgeRecompute is how many latencies are recorded between updates of the delay
+	hedgeRecompute = 32
+	// hedgeBudgetBurst caps the hedges saved up while traffic is quiet
+	hedgeBudgetBurst = 10
+)
+
+// hedgeExcludeVar holds the dial address of the node a hedged request was
+// first sent to, so the second attempt is sent to another node
+const hedgeExcludeVar = "blockchain_hedge_exclude"
+
+// defaultHedgeMethods are the JSON-RPC methods hedged unless methods is set.
+// They are reads whose answer does not depend on which node serves them, so
+// sending one twice is harmless. Filter and subscription methods are left
+// out since their state lives on a single node.
+var defaultHedgeMethods = []string{
+	"eth_blockNumber",
+	"eth_call",
+	"eth_chainId",
+	"eth_estimateGas",
+	"eth_feeHistory",
+	"eth_gasPrice",
+	"eth_getBalance",
+	"eth_getBlockByHash",
+	"eth_getBlockByNumber",
+	"eth_getBlockReceipts",
+	"eth_getCode",
+	"eth_getLogs",
+	"eth_getStorageAt",
+	"eth_getTransactionByHash",
+	"eth_getTransactionCount",
+	"eth_getTransactionReceipt",
+	"eth_maxPriorityFeePerGas",
+	"net_version",
+	"abci_query",
+	"block",
+	"block_results",
+	"status",
+	"tx",
+	"validators",
+}
+
+// hedgePlaceholders are the reverse_proxy placeholders taken from the
+// attempt that answered
+var hedgePlaceholders = []string{
+	"http.reverse_proxy.upstream.address",
+	"http.reverse_proxy.upstream.hostport",
+	"http.reverse_proxy.upstream.host",
+	"http.reverse_proxy.upstream.port",
+	"http.reverse_proxy.upstream.requests",
+	"http.reverse_proxy.upstream.max_requests",
+	"http.reverse_proxy.upstream.fails",
+	"http.reverse_proxy.upstream.latency",
+	"http.reverse_proxy.upstream.latency_ms",
+	"http.reverse_proxy.upstream.duration",
+	"http.reverse_proxy.upstream.duration_ms",
+	"http.reverse_proxy.status_code",
+	"http.reverse_proxy.duration",
+	"http.reverse_proxy.duration_ms",
+	"http.reverse_proxy.retries",
+}
+
+// Hedge is a middleware placed in front of reverse_proxy that cuts tail
+// latency for idempotent reads. When the node a request was sent to has not
+// answered within the Percentile latency of recent requests, the request is
+// sent again to another node of the pool; the first good answer is used and
+// the other attempt is cancelled. Budget caps the share of requests hedged,
+// so a slow pool does not see its load doubled.
+//
+// Only GET and HEAD requests without a body and JSON-RPC calls to Methods
+// are hedged. Responses are buffered, so hedging does not suit streaming.
+type Hedge struct {
+	Methods     []string       `json:"methods,omitempty"`
+	Percentile  float64        `json:"percentile,omitempty"`
+	MinDelay    caddy.Duration `json:"min_delay,omitempty"`
+	MaxDelay    caddy.Duration `json:"max_delay,omitempty"`
+	Budget      float64        `json:"budget,omitempty"` // share of requests (0-1) that may be hedged
+	MaxBodySize int64          `json:"max_body_size,omitempty"`
+
+	latencies *hedgeLatencies
+	budget    *hedgeBudget
+}
+
+func init() {
+	caddy.RegisterModule(&Hedge{})
+}
+
+var hdMetrics *HedgeMetrics
+
+// CaddyModule returns the Caddy module information.
+func (*Hedge) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.blockchain_hedge",
+		New: func() caddy.Module { return new(Hedge) },
+	}
+}
+
+// Provision applies defaults and registers metrics
+func (h *Hedge) Provision(ctx caddy.Context) error {
+	if len(h.Methods) == 0 {
+		h.Methods = defaultHedgeMethods
+	}
+	if h.Percentile == 0 {
+		h.Percentile = defaultHedgePercentile
+	}
+	if h.MinDelay == 0 {
+		h.MinDelay = caddy.Duration(defaultHedgeMinDelay)
+	}
+	if h.MaxDelay == 0 {
+		h.MaxDelay = caddy.Duration(defaultHedgeMaxDelay)
+	}
+	if h.Budget == 0 {
+		h.Budget = defaultHedgeBudget
+	}
+	if h.MaxBodySize == 0 {
+		h.MaxBodySize = defaultHedgeMaxBodySize
+	}
+	h.latencies = newHedgeLatencies(h.Percentile, time.Duration(h.MinDelay), time.Duration(h.MaxDelay))
+	h.budget = &hedgeBudget{ratio: h.Budget}
+
+	var registerer prometheus.Registerer
+	if reg := ctx.GetMetricsRegistry(); reg != nil {
+		registerer = reg
+	} else {
+		registerer = prometheus.DefaultRegisterer
+	}
+	metrics, err := acquireHedgeMetrics(registerer)
+	if err != nil {
+		return err
+	}
+	hdMetrics = metrics
+	return nil
+}
+
+// Validate checks configuration correctness
+func (h *Hedge) Validate() error {
+	if h.Percentile < 0 || h.Percentile >= 100 {
+		return fmt.Errorf("percentile must be between 0 and 100")
+	}
+	if h.MinDelay < 0 || h.MaxDelay < 0 {
+		return fmt.Errorf("min_delay and max_delay must not be negative")
+	}
+	if h.MaxDelay > 0 && h.MinDelay > h.MaxDelay {
+		return fmt.Errorf("min_delay must not exceed max_delay")
+	}
+	if h.Budget < 0 || h.Budget > 1 {
+		return fmt.Errorf("budget must be between 0 and 1")
+	}
+	if h.MaxBodySize < 0 {
+		return fmt.Errorf("max_body_size must not be negative")
+	}
+	return validateMethodPatterns(h.Methods)
+}
+
+// ServeHTTP proxies hedgeable requests, sending them to a second node when
+// the first is slow to answer
+func (h *Hedge) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	body, ok := h.hedgeable(r)
+	if !ok {
+		return next.ServeHTTP(w, r)
+	}
+	h.budget.earn()
+
+	delay, ok := h.latencies.delay()
+	if !ok {
+		// Not enough latencies yet to tell a slow answer from a normal one
+		start := time.Now()
+		err := next.ServeHTTP(w, r)
+		if err == nil {
+			h.latencies.record(time.Since(start))
+		}
+		return err
+	}
+	return h.serveHedged(w, r, body, delay, next)
+}
+
+// hedgeable returns the body of a request that may be sent twice
+func (h *Hedge) hedgeable(r *http.Request) ([]byte, bool) {
+	if websocket.IsWebSocketUpgrade(r) {
+		return nil, false
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		return nil, r.Body == nil || r.Body == http.NoBody
+
+	case http.MethodPost:
+		// Bodies of unknown length are left alone rather than read past the limit
+		if r.ContentLength < 0 || r.ContentLength > h.MaxBodySize {
+			return nil, false
+		}
+		body, _, err := readRPCBody(r, 0)
+		if err != nil || body == nil {
+			return nil, false
+		}
+		calls, _, err := parseRPCCalls(body)
+		if err != nil || len(calls) == 0 {
+			return nil, false
+		}
+		for _, call := range calls {
+			if _, ok := matchRPCMethod(h.Methods, call.Method); !ok {
+				return nil, false
+			}
+		}
+		return body, true
+	}
+	return nil, false
+}
+
+// hedgeAttempt is one of the two times a hedged request is proxied
+type hedgeAttempt struct {
+	rec    *hedgeRecorder
+	cancel context.CancelFunc
+	start  time.Time
+	err    error
+}
+
+// ok reports whether the attempt got an answer worth using
+func (a *hedgeAttempt) ok() bool {
+	return a.err == nil && a.rec.status < http.StatusInternalServerError
+}
+
+// serveHedged proxies the request and, if no answer came within delay and
+// the budget allows it, proxies it again to another node
+func (h *Hedge) serveHedged(w http.ResponseWriter, r *http.Request, body []byte, delay time.Duration, next caddyhttp.Handler) error {
+	repl, _ := r.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer)
+	vars, _ := r.Context().Value(caddyhttp.VarsCtxKey).(map[string]any)
+	if repl == nil || vars == nil {
+		return next.ServeHTTP(w, r)
+	}
+
+	// The attempts run concurrently, so the second one gets its own vars
+	// and placeholders, copied before the first can change them
+	hedgeVars := maps.Clone(vars)
+
+	results := make(chan *hedgeAttempt, 2)
+	run := func(ctx context.Context, a *hedgeAttempt) {
+		ctx, a.cancel = context.WithCancel(ctx)
+		req := r.WithContext(ctx)
+		if body != nil {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		a.start = time.Now()
+		go func() {
+			defer func() {
+				// reverse_proxy panics to abort a response it could not
+				// finish, which would take down the server off its goroutine
+				if v := recover(); v != nil {
+					a.err = caddyhttp.Error(http.StatusBadGateway, fmt.Errorf("proxying aborted: %v", v))
+				}
+				results <- a
+			}()
+			a.err = next.ServeHTTP(a.rec, req)
+		}()
+	}
+
+	primary := &hedgeAttempt{rec: newHedgeRecorder()}
+	run(r.Context(), primary)
+	defer primary.cancel()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case a := <-results:
+		h.latencies.record(time.Since(a.start))
+		return a.rec.writeTo(w, a.err)
+	case <-timer.C:
+	}
+
+	if !h.budget.spend() {
+		if hdMetrics != nil {
+			hdMetrics.requestsTotal.WithLabelValues("budget_exhausted").Inc()
+		}
+		a := <-results
+		h.latencies.record(time.Since(a.start))
+		return a.rec.writeTo(w, a.err)
+	}
+
+	// Steer the second attempt away from the node the first was sent to
+	if dial, ok := repl.GetString("http.reverse_proxy.upstream.hostport"); ok {
+		hedgeVars[hedgeExcludeVar] = dial
+	}
+	hedgeRepl := caddy.NewReplacer()
+	hedgeRepl.Map(repl.Get)
+	ctx := context.WithValue(r.Context(), caddy.ReplacerCtxKey, hedgeRepl)
+	ctx = context.WithValue(ctx, caddyhttp.VarsCtxKey, hedgeVars)
+	hedged := &hedgeAttempt{rec: newHedgeRecorder()}
+	run(ctx, hedged)
+	defer hedged.cancel()
+
+	// Use the first good answer; if both fail, the first attempt's
+	var winner *hedgeAttempt
+	finished := 0
+	for finished < 2 && winner == nil {
+		a := <-results
+		finished++
+		if a == primary {
+			h.latencies.record(time.Since(a.start))
+		}
+		if a.ok() {
+			winner = a
+		}
+	}
+	if winner == nil {
+		winner = primary
+	}
+
+	// Cancel the other attempt and wait until it is done with the request
+	if finished < 2 {
+		loser := hedged
+		if winner == hedged {
+			loser = primary
+			// The first attempt's latency is at least this long
+			h.latencies.record(time.Since(primary.start))
+		}
+		loser.cancel()
+		<-results
+	}
+
+	if winner == hedged {
+		for _, key := range hedgePlaceholders {
+			if value, ok := hedgeRepl.Get(key); ok {
+				repl.Set(key, value)
+			}
+		}
+		delete(hedgeVars, hedgeExcludeVar)
+		maps.Copy(vars, hedgeVars)
+	}
+	if hdMetrics != nil {
+		outcome := "primary_won"
+		if winner == hedged {
+			outcome = "hedge_won"
+		}
+		hdMetrics.requestsTotal.WithLabelValues(outcome).Inc()
+	}
+	return winner.rec.writeTo(w, winner.err)
+}
+
+// excludeHedgedNode drops the node a hedged request was first sent to from
+// the upstreams of its second attempt. The list may end up empty, failing the
+// second attempt rather than sending the request to the same node twice. The
+// list may be shared with other requests, so it is copied rather than
+// modified.
+func excludeHedgedNode(r *http.Request, upstreams []*reverseproxy.Upstream) []*reverseproxy.Upstream {
+	exclude, _ := caddyhttp.GetVar(r.Context(), hedgeExcludeVar).(string)
+	if exclude == "" {
+		return upstreams
+	}
+	excluded := func(u *reverseproxy.Upstream) bool {
+		return u.Dial == exclude
+	}
+	if !slices.ContainsFunc(upstreams, excluded) {
+		return upstreams
+	}
+	return slices.DeleteFunc(slices.Clone(upstreams), excluded)
+}
+
+// hedgeRecorder buffers the response of an attempt
+type hedgeRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newHedgeRecorder() *hedgeRecorder {
+	return &hedgeRecorder{header: make(http.Header)}
+}
+
+// Header implements http.ResponseWriter
+func (rec *hedgeRecorder) Header() http.Header {
+	return rec.header
+}
+
+// WriteHeader implements http.ResponseWriter
+func (rec *hedgeRecorder) WriteHeader(status int) {
+	if rec.status == 0 && status >= http.StatusOK {
+		rec.status = status
+	}
+}
+
+// Write implements http.ResponseWriter
+func (rec *hedgeRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.body.Write(p)
+}
+
+// writeTo sends the buffered response, or returns the attempt's error
+func (rec *hedgeRecorder) writeTo(w http.ResponseWriter, err error) error {
+	if err != nil {
+		return err
+	}
+	maps.Copy(w.Header(), rec.header)
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	_, err = w.Write(rec.body.Bytes())
+	return err
+}
+
+// hedgeLatencies keeps the latencies of recent requests and the hedging
+// delay derived from them
+type hedgeLatencies struct {
+	mutex      sync.Mutex
+	samples    []time.Duration
+	next       int
+	recorded   int
+	percentile float64
+	min, max   time.Duration
+	current    atomic.Int64 // delay in nanoseconds; 0 until enough samples
+}
+
+func newHedgeLatencies(percentile float64, min, max time.Duration) *hedgeLatencies {
+	return &hedgeLatencies{
+		samples:    make([]time.Duration, 0, hedgeWindow),
+		percentile: percentile,
+		min:        min,
+		max:        max,
+	}
+}
+
+// record adds the latency of a request
+func (l *hedgeLatencies) record(latency time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if len(l.samples) < hedgeWindow {
+		l.samples = append(l.samples, latency)
+	} else {
+		l.samples[l.next] = latency
+	}
+	l.next = (l.next + 1) % hedgeWindow
+	l.recorded++
+	if len(l.samples) < hedgeMinSamples || l.recorded%hedgeRecompute != 0 && l.current.Load() != 0 {
+		return
+	}
+
+	sorted := slices.Clone(l.samples)
+	slices.Sort(sorted)
+	index := int(math.Ceil(l.percentile/100*float64(len(sorted)))) - 1
+	delay := sorted[max(index, 0)]
+	if delay < l.min {
+		delay = l.min
+	}
+	if l.max > 0 && delay > l.max {
+		delay = l.max
+	}
+	l.current.Store(int64(delay))
+	if hdMetrics != nil {
+		hdMetrics.delaySeconds.Set(delay.Seconds())
+	}
+}
+
+// delay returns how long to wait for an answer before hedging, and false
+// until enough latencies were recorded
+func (l *hedgeLatencies) delay() (time.Duration, bool) {
+	delay := time.Duration(l.current.Load())
+	return delay, delay > 0
+}
+
+// hedgeBudget lets a share of requests be hedged. Every hedgeable request
+// earns ratio of a hedge, and a hedge spends a whole one.
+type hedgeBudget struct {
+	mutex  sync.Mutex
+	ratio  float64
+	tokens float64
+}
+
+// earn credits the budget for a hedgeable request
+func (b *hedgeBudget) earn() {
+	b.mutex.Lock()
+	b.tokens = math.Min(hedgeBudgetBurst, b.tokens+b.ratio)
+	b.mutex.Unlock()
+}
+
+// spend takes a hedge from the budget if there is one left
+func (b *hedgeBudget) spend() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*Hedge)(nil)
+	_ caddy.Validator             = (*Hedge)(nil)
+	_ caddyhttp.MiddlewareHandler = (*Hedge)(nil)
+)
@@ -0,0 +1,98 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	httpcaddyfile "github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+func init() {
+	// Register Caddyfile directive for this handler
+	httpcaddyfile.RegisterHandlerDirective("blockchain_hedge", parseHedgeCaddyfile)
+	httpcaddyfile.RegisterDirectiveOrder("blockchain_hedge", httpcaddyfile.Before, "reverse_proxy")
+}
+
+func parseHedgeCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	hg := new(Hedge)
+	if err := hg.UnmarshalCaddyfile(h.Dispenser); err != nil {
+		return nil, err
+	}
+	return hg, nil
+}
+
+// UnmarshalCaddyfile implements caddyfile.Unmarshaler for blockchain_hedge
+func (h *Hedge) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	for d.Next() {
+		for d.NextBlock(0) {
+			switch d.Val() {
+			case "methods":
+				args := d.RemainingArgs()
+				if len(args) == 0 {
+					return d.ArgErr()
+				}
+				h.Methods = append(h.Methods, args...)
+
+			case "percentile":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				p, err := strconv.ParseFloat(d.Val(), 64)
+				if err != nil {
+					return d.Errf("invalid percentile: %v", err)
+				}
+				h.Percentile = p
+
+			case "min_delay", "max_delay":
+				name := d.Val()
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				dur, err := time.ParseDuration(d.Val())
+				if err != nil {
+					return d.Errf("invalid %s: %v", name, err)
+				}
+				if name == "min_delay" {
+					h.MinDelay = caddy.Duration(dur)
+				} else {
+					h.MaxDelay = caddy.Duration(dur)
+				}
+
+			case "budget":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				budget, err := parseShare(d.Val())
+				if err != nil {
+					return d.Errf("invalid budget: %v", err)
+				}
+				h.Budget = budget
+
+			case "max_body_size":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				size, err := parseByteSize(d.Val())
+				if err != nil {
+					return d.Errf("invalid max_body_size: %v", err)
+				}
+				h.MaxBodySize = size
+
+			default:
+				return d.Errf("unknown directive: %s", d.Val())
+			}
+		}
+	}
+
+	if err := h.Validate(); err != nil {
+		return fmt.Errorf("blockchain_hedge validation: %w", err)
+	}
+	return nil
+}
+
+// Interface guard
+var _ caddyfile.Unmarshaler = (*Hedge)(nil)
@@ -0,0 +1,223 @@
+package blockchain_health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// hedgeTestProxy stands in for reverse_proxy: requests go to a slow node
+// unless it is excluded, in which case they go to a fast one
+type hedgeTestProxy struct {
+	slowCancelled atomic.Bool
+	calls         atomic.Int32
+}
+
+func (p *hedgeTestProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) error {
+	p.calls.Add(1)
+	repl := r.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer)
+	upstreams := excludeHedgedNode(r, []*reverseproxy.Upstream{{Dial: "slow:8545"}, {Dial: "fast:8545"}})
+	repl.Set("http.reverse_proxy.upstream.hostport", upstreams[0].Dial)
+
+	if upstreams[0].Dial == "slow:8545" {
+		select {
+		case <-r.Context().Done():
+			p.slowCancelled.Store(true)
+			return caddyhttp.Error(http.StatusBadGateway, r.Context().Err())
+		case <-time.After(time.Second):
+		}
+	}
+	w.Header().Set("X-Node", upstreams[0].Dial)
+	_, err := w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	return err
+}
+
+func newTestHedge(t *testing.T, budget float64) *Hedge {
+	t.Helper()
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	t.Cleanup(cancel)
+
+	h := &Hedge{MinDelay: caddy.Duration(time.Millisecond), Budget: budget}
+	if err := h.Provision(ctx); err != nil {
+		t.Fatalf("Provision failed: %v", err)
+	}
+	for range hedgeMinSamples {
+		h.latencies.record(time.Millisecond)
+	}
+	return h
+}
+
+func newHedgeRequest(body string) (*http.Request, *caddy.Replacer) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	repl := caddy.NewReplacer()
+	ctx := context.WithValue(r.Context(), caddy.ReplacerCtxKey, repl)
+	ctx = context.WithValue(ctx, caddyhttp.VarsCtxKey, map[string]any{})
+	return r.WithContext(ctx), repl
+}
+
+func hedgeOutcomes(t *testing.T, outcome string) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := hdMetrics.requestsTotal.WithLabelValues(outcome).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestHedge_SecondNodeAnswers(t *testing.T) {
+	h := newTestHedge(t, 1)
+	h.budget.tokens = 1
+	proxy := new(hedgeTestProxy)
+	before := hedgeOutcomes(t, "hedge_won")
+
+	r, repl := newHedgeRequest(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`)
+	w := httptest.NewRecorder()
+	start := time.Now()
+	if err := h.ServeHTTP(w, r, caddyhttp.HandlerFunc(proxy.ServeHTTP)); err != nil {
+		t.Fatalf("ServeHTTP failed: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected the hedge to answer quickly, took %v", elapsed)
+	}
+	if got := w.Header().Get("X-Node"); got != "fast:8545" {
+		t.Errorf("expected the answer of the second node, got %q", got)
+	}
+	if !proxy.slowCancelled.Load() {
+		t.Error("expected the slow attempt to be cancelled")
+	}
+	if got, _ := repl.GetString("http.reverse_proxy.upstream.hostport"); got != "fast:8545" {
+		t.Errorf("expected the placeholders of the answering attempt, got %q", got)
+	}
+	if got := hedgeOutcomes(t, "hedge_won") - before; got != 1 {
+		t.Errorf("expected one hedge won, got %v", got)
+	}
+}
+
+func TestHedge_Budget(t *testing.T) {
+	h := newTestHedge(t, 0.5)
+	proxy := new(hedgeTestProxy)
+	before := hedgeOutcomes(t, "budget_exhausted")
+
+	// The first request only earns half a hedge, so it waits for the slow node
+	r, _ := newHedgeRequest(`{"jsonrpc":"2.0","id":1,"method":"eth_call","params":[]}`)
+	w := httptest.NewRecorder()
+	if err := h.ServeHTTP(w, r, caddyhttp.HandlerFunc(proxy.ServeHTTP)); err != nil {
+		t.Fatalf("ServeHTTP failed: %v", err)
+	}
+	if got := w.Header().Get("X-Node"); got != "slow:8545" || proxy.calls.Load() != 1 {
+		t.Errorf("expected no hedge without budget, got node %q after %d calls", got, proxy.calls.Load())
+	}
+	if got := hedgeOutcomes(t, "budget_exhausted") - before; got != 1 {
+		t.Errorf("expected one request over budget, got %v", got)
+	}
+
+	// The second completes a hedge
+	r, _ = newHedgeRequest(`{"jsonrpc":"2.0","id":2,"method":"eth_call","params":[]}`)
+	w = httptest.NewRecorder()
+	if err := h.ServeHTTP(w, r, caddyhttp.HandlerFunc(proxy.ServeHTTP)); err != nil {
+		t.Fatalf("ServeHTTP failed: %v", err)
+	}
+	if got := w.Header().Get("X-Node"); got != "fast:8545" {
+		t.Errorf("expected the request to be hedged, got node %q", got)
+	}
+}
+
+func TestHedge_Hedgeable(t *testing.T) {
+	h := &Hedge{Methods: defaultHedgeMethods, MaxBodySize: defaultHedgeMaxBodySize}
+
+	tests := map[string]struct {
+		request *http.Request
+		want    bool
+	}{
+		"read call": {
+			request: httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_getBalance","params":[]}`)),
+			want:    true,
+		},
+		"batch with a write": {
+			request: httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`[{"jsonrpc":"2.0","id":1,"method":"eth_call"},{"jsonrpc":"2.0","id":2,"method":"eth_sendRawTransaction"}]`)),
+		},
+		"filter": {
+			request: httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_getFilterChanges","params":["0x1"]}`)),
+		},
+		"rest read": {
+			request: httptest.NewRequest(http.MethodGet, "/cosmos/base/tendermint/v1beta1/blocks/latest", nil),
+			want:    true,
+		},
+		"rest write": {
+			request: httptest.NewRequest(http.MethodPut, "/", nil),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, got := h.hedgeable(tt.request); got != tt.want {
+				t.Errorf("expected hedgeable %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	// A body of unknown length is not read
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_call"}`))
+	r.ContentLength = -1
+	if _, ok := h.hedgeable(r); ok {
+		t.Error("expected a body of unknown length not to be hedged")
+	}
+}
+
+func TestHedge_Delay(t *testing.T) {
+	l := newHedgeLatencies(99, time.Millisecond, 300*time.Millisecond)
+	for i := range hedgeMinSamples - 1 {
+		l.record(time.Duration(i+1) * time.Millisecond)
+	}
+	if _, ok := l.delay(); ok {
+		t.Fatal("expected no delay before enough latencies were recorded")
+	}
+	l.record(100 * time.Millisecond)
+	if delay, ok := l.delay(); !ok || delay != 99*time.Millisecond {
+		t.Errorf("expected the P99 of 1-100ms, got %v", delay)
+	}
+
+	for range hedgeRecompute {
+		l.record(time.Second)
+	}
+	if delay, _ := l.delay(); delay != 300*time.Millisecond {
+		t.Errorf("expected the delay to be capped at max_delay, got %v", delay)
+	}
+}
+
+func TestHedge_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_hedge {
+		methods eth_call eth_getLogs
+		percentile 95
+		min_delay 20ms
+		max_delay 1s
+		budget 10%
+		max_body_size 64KB
+	}`)
+
+	var h Hedge
+	if err := h.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if len(h.Methods) != 2 || h.Percentile != 95 || h.Budget != 0.1 ||
+		time.Duration(h.MinDelay) != 20*time.Millisecond || time.Duration(h.MaxDelay) != time.Second {
+		t.Errorf("unexpected config %+v", h)
+	}
+
+	d = caddyfile.NewTestDispenser(`blockchain_hedge {
+		budget 150%
+	}`)
+	if err := new(Hedge).UnmarshalCaddyfile(d); err == nil {
+		t.Error("expected a budget above 100% to be rejected")
+	}
+}
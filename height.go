@@ -0,0 +1,74 @@
+package blockchain_health
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// errHeightOverflow is returned for heights that do not fit a uint64
+var errHeightOverflow = errors.New("height overflows uint64")
+
+// parseHeight parses a block height, slot or epoch as nodes report it: a
+// decimal string, a 0x-prefixed hex quantity (leading zeros allowed) or a
+// number in scientific notation such as 1.2345e6, which some JSON encoders
+// produce for large integers. Fractions and negative values are rejected, and
+// values beyond uint64 return errHeightOverflow instead of wrapping.
+func parseHeight(value string) (uint64, error) {
+	value = strings.TrimSpace(value)
+	if height, err := strconv.ParseUint(value, 10, 64); err == nil {
+		return height, nil
+	}
+	if value == "" {
+		return 0, errors.New("empty height")
+	}
+
+	var n *big.Int
+	switch {
+	case strings.HasPrefix(value, "0x") || strings.HasPrefix(value, "0X"):
+		n, _ = new(big.Int).SetString(value[2:], 16)
+	case strings.ContainsAny(value, ".eE"):
+		f, _, err := big.ParseFloat(value, 10, 256, big.ToNearestEven)
+		if err == nil && f.IsInt() {
+			n, _ = f.Int(nil)
+		}
+	default:
+		n, _ = new(big.Int).SetString(value, 10)
+	}
+
+	switch {
+	case n == nil:
+		return 0, fmt.Errorf("invalid height %q", value)
+	case n.Sign() < 0:
+		return 0, fmt.Errorf("negative height %q", value)
+	case !n.IsUint64():
+		return 0, fmt.Errorf("%w: %s", errHeightOverflow, value)
+	}
+	return n.Uint64(), nil
+}
+
+// heightText is a height as the node sent it, for parseHeight to read.
+// Nodes send heights as JSON strings or, on some Cosmos SDK versions, as
+// JSON numbers; both decode into the text of the value.
+type heightText string
+
+// UnmarshalJSON accepts "123", 123 and null
+func (h *heightText) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		*h = heightText(value)
+		return nil
+	}
+	if string(data) == "null" {
+		*h = ""
+		return nil
+	}
+	*h = heightText(data)
+	return nil
+}
@@ -0,0 +1,130 @@
+package blockchain_health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestParseHeight(t *testing.T) {
+	tests := map[string]uint64{
+		"12345":                12345,
+		" 12345 ":              12345,
+		"0":                    0,
+		"0x1b4":                436,
+		"0X1B4":                436,
+		"0x00000000001b4":      436,
+		"1.2345e6":             1234500,
+		"1E3":                  1000,
+		"18446744073709551615": 18446744073709551615,
+	}
+	for input, want := range tests {
+		got, err := parseHeight(input)
+		if err != nil {
+			t.Errorf("%q: %v", input, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%q: expected %d, got %d", input, want, got)
+		}
+	}
+
+	for _, input := range []string{"", "0x", "abc", "-1", "1.5", "1.5e0", "0xzz"} {
+		if _, err := parseHeight(input); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+
+	for _, input := range []string{"18446744073709551616", "0x10000000000000000", "1e20"} {
+		if _, err := parseHeight(input); !errors.Is(err, errHeightOverflow) {
+			t.Errorf("%q: expected errHeightOverflow, got %v", input, err)
+		}
+	}
+}
+
+func TestHeightText_UnmarshalJSON(t *testing.T) {
+	tests := map[string]heightText{
+		`"12345"`:  "12345",
+		`12345`:    "12345",
+		`1.2345e6`: "1.2345e6",
+		`"0x1b4"`:  "0x1b4",
+		`null`:     "",
+		`""`:       "",
+	}
+	for input, want := range tests {
+		var h heightText
+		if err := json.Unmarshal([]byte(input), &h); err != nil {
+			t.Errorf("%s: %v", input, err)
+			continue
+		}
+		if h != want {
+			t.Errorf("%s: expected %q, got %q", input, want, h)
+		}
+	}
+}
+
+func TestCosmosHandler_NumericHeights(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/status" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"result":{"sync_info":{"latest_block_height":12345,"earliest_block_height":1e3,"catching_up":false}}}`))
+	}))
+	defer server.Close()
+
+	handler := NewCosmosHandler(5*time.Second, zaptest.NewLogger(t))
+	health, err := handler.CheckHealth(context.Background(), NodeConfig{Name: "node", URL: server.URL, Type: NodeTypeCosmos})
+	if err != nil {
+		t.Fatalf("CheckHealth failed: %v", err)
+	}
+	if !health.Healthy || health.BlockHeight != 12345 {
+		t.Errorf("expected healthy node at 12345, got healthy=%v height=%d", health.Healthy, health.BlockHeight)
+	}
+}
+
+func TestCosmosHandler_HeightOverflow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"result":{"sync_info":{"latest_block_height":"99999999999999999999","catching_up":false}}}`))
+	}))
+	defer server.Close()
+
+	handler := NewCosmosHandler(5*time.Second, zaptest.NewLogger(t))
+	health, _ := handler.CheckHealth(context.Background(), NodeConfig{Name: "node", URL: server.URL, Type: NodeTypeCosmos})
+	if health != nil && health.Healthy {
+		t.Error("expected a node reporting an overflowing height to be unhealthy")
+	}
+}
+
+func TestEVMHandler_QuantityFormats(t *testing.T) {
+	tests := map[string]uint64{
+		`"0x1b4"`:              436,
+		`"0x00000000000001b4"`: 436,
+		`436`:                  436,
+	}
+	for result, want := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + result + `}`))
+		}))
+
+		handler := NewEVMHandler(5*time.Second, zaptest.NewLogger(t))
+		height, err := handler.GetBlockHeight(context.Background(), server.URL)
+		server.Close()
+		if err != nil {
+			t.Errorf("%s: %v", result, err)
+			continue
+		}
+		if height != want {
+			t.Errorf("%s: expected %d, got %d", result, want, height)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/caddyserver/caddy/v2"
+)
+
+// HostPool binds a requested host to a pool of the blockchain_health app.
+// The host is a name such as osmosis-rpc.example.com, a wildcard such as
+// *.osmosis.example.com matching one label, or * for every other host.
+type HostPool struct {
+	Host string `json:"host"`
+	Pool string `json:"pool"`
+}
+
+// hostPool is a host pattern with the pool it resolved to
+type hostPool struct {
+	host string
+	pool *BlockchainHealthUpstream
+}
+
+// provisionHosts resolves the pools an upstream selects by host. Exact hosts
+// are matched before wildcards, and * last.
+func (b *BlockchainHealthUpstream) provisionHosts(ctx caddy.Context) error {
+	appModule, err := ctx.AppIfConfigured("blockchain_health")
+	if err != nil {
+		return fmt.Errorf("host routing: no blockchain_health pools are configured: %w", err)
+	}
+	app := appModule.(*App)
+
+	var exact, wildcard, fallback []hostPool
+	for _, route := range b.Hosts {
+		pool, ok := app.namedPool(route.Pool)
+		if !ok {
+			return fmt.Errorf("host %s: pool %s is not defined in the blockchain_health app", route.Host, route.Pool)
+		}
+		entry := hostPool{host: normalizeHost(route.Host), pool: pool}
+		switch {
+		case entry.host == "*":
+			fallback = append(fallback, entry)
+		case strings.HasPrefix(entry.host, "*."):
+			wildcard = append(wildcard, entry)
+		default:
+			exact = append(exact, entry)
+		}
+	}
+	b.hostPools = append(append(exact, wildcard...), fallback...)
+	return nil
+}
+
+// validateHosts checks the hosts of an upstream selecting pools by host
+func (b *BlockchainHealthUpstream) validateHosts() error {
+	if b.Pool != "" {
+		return fmt.Errorf("pool=%s and host routing cannot be combined", b.Pool)
+	}
+	if len(b.Nodes) > 0 || len(b.ExternalReferences) > 0 {
+		return fmt.Errorf("host routing selects pools of the blockchain_health app; nodes belong in those pools")
+	}
+	seen := make(map[string]bool, len(b.Hosts))
+	for _, route := range b.Hosts {
+		host := normalizeHost(route.Host)
+		if host == "" {
+			return fmt.Errorf("host is required")
+		}
+		if strings.Contains(strings.TrimPrefix(host, "*"), "*") {
+			return fmt.Errorf("invalid host %s (wildcards must be a leading *. label, or * alone)", route.Host)
+		}
+		if seen[host] {
+			return fmt.Errorf("host %s listed more than once", host)
+		}
+		seen[host] = true
+		if route.Pool == "" {
+			return fmt.Errorf("host %s: pool is required", host)
+		}
+	}
+	return nil
+}
+
+// normalizeHost lowercases a host and drops a trailing dot
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+// poolForHost returns the pool serving the host of a request
+func (b *BlockchainHealthUpstream) poolForHost(r *http.Request) (*BlockchainHealthUpstream, error) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = normalizeHost(host)
+	for _, route := range b.hostPools {
+		if matchHostPattern(route.host, host) {
+			return route.pool, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no pool serves host %s", ErrNoHealthyUpstreams, host)
+}
+
+// matchHostPattern matches a host against a name, a *. wildcard covering one
+// label, or *
+func matchHostPattern(pattern, host string) bool {
+	if pattern == "*" || pattern == host {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(pattern, "*")
+	if !ok {
+		return false
+	}
+	label, ok := strings.CutSuffix(host, suffix)
+	return ok && label != "" && !strings.Contains(label, ".")
+}
@@ -0,0 +1,133 @@
+package blockchain_health
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+)
+
+func TestMatchHostPattern(t *testing.T) {
+	tests := []struct {
+		pattern, host string
+		want          bool
+	}{
+		{"rpc.example.com", "rpc.example.com", true},
+		{"rpc.example.com", "api.example.com", false},
+		{"*.example.com", "rpc.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "a.rpc.example.com", false},
+		{"*.example.com", "rpcexample.com", false},
+		{"*", "anything.example.org", true},
+	}
+	for _, tt := range tests {
+		if got := matchHostPattern(tt.pattern, tt.host); got != tt.want {
+			t.Errorf("matchHostPattern(%q, %q) = %v, want %v", tt.pattern, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestHostPools_SelectByHost(t *testing.T) {
+	osmosis := createVersionedEVMServer(t, "Geth/v1.13.14")
+	defer osmosis.Close()
+	eth := createVersionedEVMServer(t, "Geth/v1.13.14")
+	defer eth.Close()
+
+	config := fmt.Sprintf(`{
+		"admin": {"disabled": true},
+		"apps": {
+			"blockchain_health": {
+				"pools": {
+					"osmosis-main": {"nodes": [{"name": "osmosis", "url": %q, "type": "evm", "weight": 1}]},
+					"eth-main": {"nodes": [{"name": "eth", "url": %q, "type": "evm", "weight": 1}]}
+				}
+			}
+		}
+	}`, osmosis.URL, eth.URL)
+	if err := caddy.Load([]byte(config), true); err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	defer func() { _ = caddy.Stop() }()
+
+	site := &BlockchainHealthUpstream{Hosts: []HostPool{
+		{Host: "*.eth.example.com", Pool: "eth-main"},
+		{Host: "Osmosis-RPC.example.com", Pool: "osmosis-main"},
+		{Host: "eth-rpc.example.com", Pool: "eth-main"},
+	}}
+	if err := site.Validate(); err != nil {
+		t.Fatalf("expected a valid config, got %v", err)
+	}
+	if err := site.Provision(caddy.ActiveContext()); err != nil {
+		t.Fatalf("provisioning site: %v", err)
+	}
+	defer func() { _ = site.Cleanup() }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for host, node := range map[string]string{
+		"osmosis-rpc.example.com":       osmosis.URL,
+		"eth-rpc.example.com:443":       eth.URL,
+		"archive.eth.example.com":       eth.URL,
+		"OSMOSIS-RPC.EXAMPLE.COM.:8443": osmosis.URL,
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.Host = host
+		for {
+			upstreams, err := site.GetUpstreams(r)
+			if err == nil && len(upstreams) == 1 && upstreams[0].Dial == getDynamicTestHostFromURL(node) {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: expected the node %s, got %v (%v)", host, node, upstreams, err)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Host = "cosmos-rpc.example.com"
+	if _, err := site.GetUpstreams(r); !errors.Is(err, ErrNoHealthyUpstreams) {
+		t.Errorf("expected a host without a pool to fail, got %v", err)
+	}
+
+	missing := &BlockchainHealthUpstream{Hosts: []HostPool{{Host: "a.example.com", Pool: "cosmos-main"}}}
+	if err := missing.Provision(caddy.ActiveContext()); err == nil {
+		t.Error("expected an undefined pool to be rejected")
+	}
+}
+
+func TestHostPools_UnmarshalCaddyfile(t *testing.T) {
+	var b BlockchainHealthUpstream
+	err := b.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`blockchain_health {
+		host osmosis-rpc.example.com osmosis-main
+		host * eth-main
+	}`))
+	if err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if len(b.Hosts) != 2 || b.Hosts[1] != (HostPool{Host: "*", Pool: "eth-main"}) {
+		t.Errorf("unexpected hosts: %+v", b.Hosts)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+
+	for name, input := range map[string]string{
+		"missing pool":   "blockchain_health {\nhost a.example.com\n}",
+		"duplicate host": "blockchain_health {\nhost a.example.com x\nhost A.example.com y\n}",
+		"inner wildcard": "blockchain_health {\nhost rpc.*.example.com x\n}",
+		"with nodes":     "blockchain_health {\nhost a.example.com x\nnode a {\nurl http://localhost:8545\ntype evm\n}\n}",
+	} {
+		var b BlockchainHealthUpstream
+		if err := b.UnmarshalCaddyfile(caddyfile.NewTestDispenser(input)); err != nil {
+			continue
+		}
+		if err := b.validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
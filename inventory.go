@@ -0,0 +1,301 @@
+package blockchain_health
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// InventoryWebhook is a handler that lets an inventory system such as NetBox
+// push the nodes of the pools configured with an inventory block:
+//
+//	GET   <path>/<pool>   list the pushed nodes
+//	PUT   <path>/<pool>   replace them
+//	PATCH <path>/<pool>   add, update or remove nodes by name
+//
+// Requests must carry the pool's token as a bearer token. Pushed nodes are
+// added to the configured ones and survive config reloads, but not restarts.
+type InventoryWebhook struct {
+	// Pool name; defaults to the last element of the request path
+	Pool string `json:"pool,omitempty"`
+}
+
+func init() {
+	caddy.RegisterModule(&InventoryWebhook{})
+}
+
+// CaddyModule returns the Caddy module information.
+func (*InventoryWebhook) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.blockchain_inventory",
+		New: func() caddy.Module { return new(InventoryWebhook) },
+	}
+}
+
+// inventoryPatch is the body of a PATCH request
+type inventoryPatch struct {
+	Nodes  []NodeConfig `json:"nodes,omitempty"`  // added, or replacing the node with the same name
+	Remove []string     `json:"remove,omitempty"` // names of nodes to remove
+}
+
+// ServeHTTP applies an inventory push. It answers every request itself.
+func (h *InventoryWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request, _ caddyhttp.Handler) error {
+	name := h.Pool
+	if name == "" {
+		path := strings.Trim(r.URL.Path, "/")
+		name = path[strings.LastIndex(path, "/")+1:]
+	}
+
+	inv, ok := lookupInventory(name)
+	if !ok {
+		return caddyhttp.Error(http.StatusNotFound, fmt.Errorf("unknown inventory pool %q", name))
+	}
+	if !inv.authorized(r.Header.Get("Authorization")) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		return caddyhttp.Error(http.StatusUnauthorized, fmt.Errorf("invalid inventory token"))
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		// Show the nodes as they are
+
+	case http.MethodPut, http.MethodPost:
+		var body struct {
+			Nodes []NodeConfig `json:"nodes"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return caddyhttp.Error(http.StatusBadRequest, fmt.Errorf("decoding request: %w", err))
+		}
+		if err := inv.replace(body.Nodes); err != nil {
+			return caddyhttp.Error(http.StatusBadRequest, err)
+		}
+
+	case http.MethodPatch:
+		var body inventoryPatch
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return caddyhttp.Error(http.StatusBadRequest, fmt.Errorf("decoding request: %w", err))
+		}
+		if err := inv.patch(body); err != nil {
+			return caddyhttp.Error(http.StatusBadRequest, err)
+		}
+
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST, PATCH")
+		return caddyhttp.Error(http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	}
+
+	return writeAdminJSON(w, map[string][]NodeConfig{"nodes": inv.nodeList()})
+}
+
+// inventory holds the nodes pushed for a pool name. Pools configured with the
+// same name share it, and it outlives config reloads while a pool uses it.
+type inventory struct {
+	name string
+
+	mutex sync.Mutex
+	token string
+	nodes []NodeConfig
+	pools []*BlockchainHealthUpstream
+}
+
+// Destruct implements caddy.Destructor
+func (inv *inventory) Destruct() error {
+	return nil
+}
+
+// authorized reports whether an Authorization header carries the token
+func (inv *inventory) authorized(header string) bool {
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return false
+	}
+	inv.mutex.Lock()
+	defer inv.mutex.Unlock()
+	return subtle.ConstantTimeCompare([]byte(token), []byte(inv.token)) == 1
+}
+
+// nodeList returns the pushed nodes
+func (inv *inventory) nodeList() []NodeConfig {
+	inv.mutex.Lock()
+	defer inv.mutex.Unlock()
+	if inv.nodes == nil {
+		return []NodeConfig{}
+	}
+	return inv.nodes
+}
+
+// replace sets the pushed nodes of every pool using the inventory. If a pool
+// rejects them, the pools already updated are reverted.
+func (inv *inventory) replace(nodes []NodeConfig) error {
+	nodes, err := normalizePushedNodes(nodes, "inventory")
+	if err != nil {
+		return err
+	}
+
+	inv.mutex.Lock()
+	defer inv.mutex.Unlock()
+	return inv.apply(nodes)
+}
+
+// patch adds or updates nodes by name and removes the named ones
+func (inv *inventory) patch(p inventoryPatch) error {
+	inv.mutex.Lock()
+	defer inv.mutex.Unlock()
+
+	remove := make(map[string]bool, len(p.Remove)+len(p.Nodes))
+	for _, name := range p.Remove {
+		remove[name] = true
+	}
+	for _, node := range p.Nodes {
+		remove[node.Name] = true
+	}
+	var nodes []NodeConfig
+	for _, node := range inv.nodes {
+		if !remove[node.Name] {
+			nodes = append(nodes, node)
+		}
+	}
+	nodes, err := normalizePushedNodes(append(nodes, p.Nodes...), "inventory")
+	if err != nil {
+		return err
+	}
+	return inv.apply(nodes)
+}
+
+// apply hands the nodes to every pool; callers hold the mutex
+func (inv *inventory) apply(nodes []NodeConfig) error {
+	for i, pool := range inv.pools {
+		if err := pool.setDiscoveredNodes("inventory", nodes); err != nil {
+			for _, applied := range inv.pools[:i] {
+				_ = applied.setDiscoveredNodes("inventory", inv.nodes)
+			}
+			return err
+		}
+	}
+	inv.nodes = nodes
+	return nil
+}
+
+// normalizePushedNodes validates nodes pushed through the admin API, fills in
+// defaults and marks them with their source
+func normalizePushedNodes(nodes []NodeConfig, source string) ([]NodeConfig, error) {
+	seen := make(map[string]bool, len(nodes))
+	normalized := make([]NodeConfig, 0, len(nodes))
+	for i, node := range nodes {
+		if node.Name == "" {
+			return nil, fmt.Errorf("node %d: name is required", i)
+		}
+		if seen[node.Name] {
+			return nil, fmt.Errorf("node %s: listed more than once", node.Name)
+		}
+		seen[node.Name] = true
+
+		parsed, err := url.Parse(node.URL)
+		if err != nil || parsed.Host == "" {
+			return nil, fmt.Errorf("node %s: invalid URL %q", node.Name, node.URL)
+		}
+		switch parsed.Scheme {
+		case "http", "https", "ws", "wss":
+		default:
+			return nil, fmt.Errorf("node %s: URL scheme must be http, https, ws or wss", node.Name)
+		}
+		if err := validateNodeTypes(node); err != nil {
+			return nil, err
+		}
+		if node.Weight < 0 {
+			return nil, fmt.Errorf("node %s: weight must be positive", node.Name)
+		}
+		if node.Weight == 0 {
+			node.Weight = defaultNodeWeight
+		}
+		if _, err := nodeCost(node); err != nil {
+			return nil, fmt.Errorf("node %s: %w", node.Name, err)
+		}
+
+		metadata := make(map[string]string, len(node.Metadata)+1)
+		for key, value := range node.Metadata {
+			metadata[key] = value
+		}
+		metadata["source"] = source
+		node.Metadata = metadata
+		normalized = append(normalized, node)
+	}
+	return normalized, nil
+}
+
+// inventories holds the inventories by pool name
+var inventories = caddy.NewUsagePool()
+
+// registerInventory adds a pool to the inventory for its name, creating it
+// if needed, and hands the pool any nodes pushed before a reload
+func registerInventory(b *BlockchainHealthUpstream) (*inventory, error) {
+	name := b.inventoryName()
+	value, _, err := inventories.LoadOrNew(name, func() (caddy.Destructor, error) {
+		return &inventory{name: name}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	inv := value.(*inventory)
+
+	inv.mutex.Lock()
+	defer inv.mutex.Unlock()
+	if len(inv.nodes) > 0 {
+		if err := b.setDiscoveredNodes("inventory", inv.nodes); err != nil {
+			_, _ = inventories.Delete(name)
+			return nil, err
+		}
+	}
+	inv.token = b.config.Inventory.Token
+	inv.pools = append(inv.pools, b)
+	return inv, nil
+}
+
+// releaseInventory removes a pool from its inventory
+func releaseInventory(inv *inventory, b *BlockchainHealthUpstream) {
+	inv.mutex.Lock()
+	for i, pool := range inv.pools {
+		if pool == b {
+			inv.pools = append(inv.pools[:i:i], inv.pools[i+1:]...)
+			break
+		}
+	}
+	inv.mutex.Unlock()
+	_, _ = inventories.Delete(inv.name)
+}
+
+// lookupInventory returns the inventory with the given pool name
+func lookupInventory(name string) (*inventory, bool) {
+	var found *inventory
+	inventories.Range(func(key, value any) bool {
+		if key == name {
+			found = value.(*inventory)
+			return false
+		}
+		return true
+	})
+	return found, found != nil
+}
+
+// inventoryName returns the configured inventory name, defaulting to the chain
+func (b *BlockchainHealthUpstream) inventoryName() string {
+	if b.Inventory.Name != "" {
+		return b.Inventory.Name
+	}
+	if chain := b.chainName(); chain != "" {
+		return chain
+	}
+	return "default"
+}
+
+// Interface guards
+var (
+	_ caddyhttp.MiddlewareHandler = (*InventoryWebhook)(nil)
+)
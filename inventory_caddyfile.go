@@ -0,0 +1,42 @@
+package blockchain_health
+
+import (
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	httpcaddyfile "github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+func init() {
+	// Register Caddyfile directive for this handler
+	httpcaddyfile.RegisterHandlerDirective("blockchain_inventory", parseInventoryWebhookCaddyfile)
+	httpcaddyfile.RegisterDirectiveOrder("blockchain_inventory", httpcaddyfile.Before, "reverse_proxy")
+}
+
+func parseInventoryWebhookCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	iw := new(InventoryWebhook)
+	if err := iw.UnmarshalCaddyfile(h.Dispenser); err != nil {
+		return nil, err
+	}
+	return iw, nil
+}
+
+// UnmarshalCaddyfile implements caddyfile.Unmarshaler for blockchain_inventory.
+//
+//	blockchain_inventory [<pool>]
+func (h *InventoryWebhook) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	for d.Next() {
+		if d.NextArg() {
+			h.Pool = d.Val()
+		}
+		if d.NextArg() {
+			return d.ArgErr()
+		}
+		if d.NextBlock(0) {
+			return d.Errf("blockchain_inventory takes no block")
+		}
+	}
+	return nil
+}
+
+// Interface guard
+var _ caddyfile.Unmarshaler = (*InventoryWebhook)(nil)
@@ -0,0 +1,197 @@
+package blockchain_health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+// newInventoryPool returns a pool with one configured node that accepts
+// inventory pushes under the given name
+func newInventoryPool(t *testing.T, name string) *BlockchainHealthUpstream {
+	t.Helper()
+	static := NodeConfig{Name: "static", URL: "http://static:26657", Type: NodeTypeCosmos, Weight: 100}
+	upstream := createTestUpstream([]NodeConfig{static}, zaptest.NewLogger(t))
+	upstream.staticNodes = upstream.config.nodeList()
+	upstream.Inventory = InventoryConfig{Enabled: true, Name: name, Token: "secret"}
+	upstream.config.Inventory = upstream.Inventory
+	upstream.healthChecker.scheduleNodes(time.Hour)
+
+	inv, err := registerInventory(upstream)
+	if err != nil {
+		t.Fatalf("Failed to register inventory: %v", err)
+	}
+	upstream.inventory = inv
+	t.Cleanup(func() {
+		releaseInventory(inv, upstream)
+		upstream.healthChecker.Stop()
+	})
+	return upstream
+}
+
+// pushInventory sends a request to the webhook and returns the status
+func pushInventory(t *testing.T, method, path, token, body string) (int, *httptest.ResponseRecorder) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	err := (&InventoryWebhook{}).ServeHTTP(rec, req, nil)
+	var handlerErr caddyhttp.HandlerError
+	if errors.As(err, &handlerErr) {
+		return handlerErr.StatusCode, rec
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return http.StatusOK, rec
+}
+
+func TestInventoryWebhook_ReplaceAndPatch(t *testing.T) {
+	upstream := newInventoryPool(t, "inventory-replace")
+
+	status, _ := pushInventory(t, http.MethodPut, "/inventory/inventory-replace", "secret", `{"nodes": [
+		{"name": "a", "url": "http://10.0.0.1:26657", "type": "cosmos"},
+		{"name": "b", "url": "http://10.0.0.2:26657", "type": "cosmos", "weight": 50}
+	]}`)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	nodes := upstream.config.nodeList()
+	if len(nodes) != 3 || nodes[1].Name != "a" || nodes[1].Weight != 100 || nodes[2].Weight != 50 {
+		t.Fatalf("expected the pushed nodes after the configured one, got %+v", nodes)
+	}
+	if nodes[1].Metadata["source"] != "inventory" {
+		t.Errorf("expected inventory source metadata, got %v", nodes[1].Metadata)
+	}
+
+	status, rec := pushInventory(t, http.MethodPatch, "/inventory/inventory-replace", "secret", `{
+		"nodes": [{"name": "c", "url": "http://10.0.0.3:26657", "type": "cosmos"}],
+		"remove": ["a"]
+	}`)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	var body struct {
+		Nodes []NodeConfig `json:"nodes"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if len(body.Nodes) != 2 || body.Nodes[0].Name != "b" || body.Nodes[1].Name != "c" {
+		t.Errorf("expected b and c to be pushed, got %+v", body.Nodes)
+	}
+	if upstream.findNodeConfig("a") != nil || upstream.findNodeConfig("c") == nil {
+		t.Errorf("expected a to be removed and c added, got %+v", upstream.config.nodeList())
+	}
+
+	// A full replace with no nodes leaves only the configured node
+	if status, _ := pushInventory(t, http.MethodPut, "/inventory/inventory-replace", "secret", `{"nodes": []}`); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	if nodes := upstream.config.nodeList(); len(nodes) != 1 || nodes[0].Name != "static" {
+		t.Errorf("expected only the configured node, got %+v", nodes)
+	}
+}
+
+func TestInventoryWebhook_Rejects(t *testing.T) {
+	upstream := newInventoryPool(t, "inventory-rejects")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+	}{
+		{"missing token", http.MethodPut, "/inventory/inventory-rejects", "", `{"nodes": []}`, http.StatusUnauthorized},
+		{"wrong token", http.MethodPut, "/inventory/inventory-rejects", "guess", `{"nodes": []}`, http.StatusUnauthorized},
+		{"unknown pool", http.MethodPut, "/inventory/missing", "secret", `{"nodes": []}`, http.StatusNotFound},
+		{"bad method", http.MethodDelete, "/inventory/inventory-rejects", "secret", ``, http.StatusMethodNotAllowed},
+		{"bad json", http.MethodPut, "/inventory/inventory-rejects", "secret", `{`, http.StatusBadRequest},
+		{"bad type", http.MethodPut, "/inventory/inventory-rejects", "secret",
+			`{"nodes": [{"name": "a", "url": "http://10.0.0.1:26657", "type": "solana"}]}`, http.StatusBadRequest},
+		{"bad url", http.MethodPut, "/inventory/inventory-rejects", "secret",
+			`{"nodes": [{"name": "a", "url": "10.0.0.1:26657", "type": "cosmos"}]}`, http.StatusBadRequest},
+		{"duplicate name", http.MethodPut, "/inventory/inventory-rejects", "secret",
+			`{"nodes": [{"name": "a", "url": "http://10.0.0.1:26657", "type": "cosmos"}, {"name": "a", "url": "http://10.0.0.2:26657", "type": "cosmos"}]}`, http.StatusBadRequest},
+		{"conflicting type", http.MethodPut, "/inventory/inventory-rejects", "secret",
+			`{"nodes": [{"name": "a", "url": "http://static:26657", "type": "evm"}]}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, _ := pushInventory(t, tt.method, tt.path, tt.token, tt.body); status != tt.status {
+				t.Errorf("expected %d, got %d", tt.status, status)
+			}
+			if nodes := upstream.config.nodeList(); len(nodes) != 1 {
+				t.Errorf("expected the pool to be unchanged, got %+v", nodes)
+			}
+		})
+	}
+}
+
+func TestInventory_SurvivesReload(t *testing.T) {
+	first := newInventoryPool(t, "inventory-reload")
+	if status, _ := pushInventory(t, http.MethodPut, "/inventory/inventory-reload", "secret",
+		`{"nodes": [{"name": "a", "url": "http://10.0.0.1:26657", "type": "cosmos"}]}`); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+
+	// The pool of the new config gets the pushed nodes on registration
+	second := newInventoryPool(t, "inventory-reload")
+	if second.findNodeConfig("a") == nil {
+		t.Errorf("expected the pushed node after a reload, got %+v", second.config.nodeList())
+	}
+
+	// Pushes reach every pool with the name
+	if status, _ := pushInventory(t, http.MethodPatch, "/inventory/inventory-reload", "secret", `{"remove": ["a"]}`); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	if first.findNodeConfig("a") != nil || second.findNodeConfig("a") != nil {
+		t.Error("expected the node to be removed from both pools")
+	}
+}
+
+func TestInventory_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		inventory {
+			name osmosis
+			token s3cret
+		}
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if b.Inventory != (InventoryConfig{Enabled: true, Name: "osmosis", Token: "s3cret"}) {
+		t.Errorf("unexpected inventory config: %+v", b.Inventory)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected inventory to stand in for static nodes, got %v", err)
+	}
+
+	b.Inventory.Token = ""
+	if err := b.validate(); err == nil {
+		t.Error("expected an inventory without a token to be rejected")
+	}
+
+	var h InventoryWebhook
+	if err := h.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`blockchain_inventory osmosis`)); err != nil {
+		t.Fatalf("Failed to parse handler: %v", err)
+	}
+	if h.Pool != "osmosis" {
+		t.Errorf("expected pool osmosis, got %q", h.Pool)
+	}
+}
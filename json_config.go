@@ -0,0 +1,105 @@
+package blockchain_health
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/caddyserver/caddy/v2"
+)
+
+// defaultNodeWeight is the load balancing weight of nodes that don't set one
+const defaultNodeWeight = 100
+
+// Legacy fallback behaviors when the environment configuration fails
+const (
+	FallbackDisableHealthChecks = "disable_health_checks"
+	FallbackFailStartup         = "fail_startup"
+)
+
+// isValidChainPreset reports whether preset names a chain preset
+func isValidChainPreset(preset string) bool {
+	switch preset {
+	case "cosmos", "cosmos-hub", "ethereum", "althea":
+		return true
+	}
+	return false
+}
+
+// expand replaces placeholders such as {env.COSMOS_RPC_SERVERS} in the
+// server lists, the native JSON equivalent of {$COSMOS_RPC_SERVERS} in a
+// Caddyfile, which is substituted when the Caddyfile is adapted
+func (e EnvironmentConfig) expand() EnvironmentConfig {
+	repl := caddy.NewReplacer()
+	for _, servers := range []*string{&e.Servers, &e.RPCServers, &e.APIServers, &e.WebSocketServers, &e.EVMServers, &e.EVMWSServers} {
+		*servers = repl.ReplaceKnown(*servers, "")
+	}
+	return e
+}
+
+// UnmarshalJSON decodes the native JSON configuration, rejecting unknown
+// fields and invalid choices as soon as the config is loaded, and gives nodes
+// without a weight the same default weight as the Caddyfile
+func (b *BlockchainHealthUpstream) UnmarshalJSON(data []byte) error {
+	// The decoder Caddy loads modules with doesn't pass its strictness on to
+	// custom unmarshalers, so unknown fields are rejected here
+	type upstream BlockchainHealthUpstream
+	if err := caddy.StrictUnmarshalJSON(data, (*upstream)(b)); err != nil {
+		return err
+	}
+
+	var weights struct {
+		Nodes []struct {
+			Weight *int `json:"weight"`
+		} `json:"nodes"`
+	}
+	if err := json.Unmarshal(data, &weights); err != nil {
+		return err
+	}
+	for i, node := range weights.Nodes {
+		if node.Weight == nil && i < len(b.Nodes) {
+			b.Nodes[i].Weight = defaultNodeWeight
+		}
+	}
+
+	return b.validateChoices()
+}
+
+// validateChoices checks the settings limited to a fixed set of values, which
+// both the Caddyfile and JSON configurations are checked against
+func (b *BlockchainHealthUpstream) validateChoices() error {
+	if _, _, ok := parseNodeType(b.Chain.NodeType); b.Chain.NodeType != "" && !ok {
+		return fmt.Errorf("invalid node_type: %s (must be 'cosmos', 'evm', or 'beacon')", b.Chain.NodeType)
+	}
+	if _, _, ok := parseChainType(b.Chain.ChainType); b.Chain.ChainType != "" && !ok {
+		return fmt.Errorf("invalid chain_type: %q (must be an identifier such as 'ethereum' or 'cosmos-hub')", b.Chain.ChainType)
+	}
+	if b.Chain.ChainPreset != "" && !isValidChainPreset(b.Chain.ChainPreset) {
+		return fmt.Errorf("invalid chain_preset: %s (must be 'cosmos-hub', 'ethereum', or 'althea')", b.Chain.ChainPreset)
+	}
+	if t, _, ok := parseServiceType(string(b.Chain.ServiceType)); b.Chain.ServiceType != "" && (!ok || t == ServiceTypeGeneric) {
+		return fmt.Errorf("invalid service_type: %s (must be 'rpc', 'api', or 'websocket')", b.Chain.ServiceType)
+	}
+	switch b.Legacy.FallbackBehavior {
+	case "", FallbackDisableHealthChecks, FallbackFailStartup:
+	default:
+		return fmt.Errorf("invalid fallback_behavior: %s (must be '%s' or '%s')", b.Legacy.FallbackBehavior,
+			FallbackDisableHealthChecks, FallbackFailStartup)
+	}
+	if b.HealthCheck.ProbeMode != "" && !isValidCosmosProbeMode(b.HealthCheck.ProbeMode) {
+		return fmt.Errorf("invalid probe mode: %s", b.HealthCheck.ProbeMode)
+	}
+	if b.FailureHandling.FallbackStrategy != "" && !isValidFallbackStrategy(b.FailureHandling.FallbackStrategy) {
+		return fmt.Errorf("invalid fallback strategy %q: must be %s, %s, %s or %s", b.FailureHandling.FallbackStrategy,
+			FallbackAll, FallbackBestEffortHighest, FallbackExternalProviders, FallbackError)
+	}
+	if b.BlockValidation.ExternalLagAction != "" && !isValidExternalLagAction(b.BlockValidation.ExternalLagAction) {
+		return fmt.Errorf("invalid external_lag_action: %s (must be '%s', '%s' or '%s')", b.BlockValidation.ExternalLagAction,
+			ExternalLagWarn, ExternalLagDownweight, ExternalLagExclude)
+	}
+	for component := range b.Monitoring.ComponentLogLevels {
+		if !isValidLogComponent(component) {
+			return fmt.Errorf("invalid log level component: %s", component)
+		}
+	}
+	return nil
+}
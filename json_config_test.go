@@ -0,0 +1,155 @@
+package blockchain_health
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	_ "github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+)
+
+// roundTripBlock sets the environment, chain and legacy settings along with
+// a sample of the other sections
+const roundTripBlock = `blockchain_health {
+	node a {
+		url http://10.0.0.1:26657
+		alternate_url https://rpc.example.com
+		api_url http://10.0.0.1:1317
+		type cosmos
+		weight 50
+		header X-Org-Token {env.ORG_TOKEN}
+		metadata {
+			region eu
+		}
+	}
+	check_interval 10s
+	timeout 3s
+	retry_attempts 2
+	probe_mode abci_info
+	user_agent acme-prober/1.0
+	block_height_threshold 4
+	strict_leader_only 3
+	enforce false
+	scoring {
+		cutoff 0
+		max_latency 2s
+	}
+	request_signing hmac {
+		secret {env.NODE_SECRET}
+	}
+	account_affinity 30s
+	log_level checker debug
+	client_diversity_warning
+	rpc_servers http://node1:26657 http://node2:26657
+	evm_servers http://node1:8545
+	evm_ws_servers ws://node1:8546
+	chain_type akash
+	node_type cosmos
+	chain_preset cosmos-hub
+	auto_discover_from_env AKASH
+	service_type rpc
+	legacy_mode true
+	fallback_behavior fail_startup
+	required_env_vars AKASH_RPC_SERVERS
+	optional_env_vars AKASH_API_SERVERS
+}`
+
+func TestJSONConfig_CaddyfileRoundTrip(t *testing.T) {
+	var parsed BlockchainHealthUpstream
+	if err := parsed.UnmarshalCaddyfile(caddyfile.NewTestDispenser(roundTripBlock)); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+
+	// caddy adapt writes the upstream source into the reverse_proxy handler
+	config := ":8080 {\n\treverse_proxy {\n\t\tdynamic " + roundTripBlock + "\n\t}\n}"
+	adapted, _, err := caddyconfig.GetAdapter("caddyfile").Adapt([]byte(config), nil)
+	if err != nil {
+		t.Fatalf("adapting Caddyfile: %v", err)
+	}
+	var tree struct {
+		Apps struct {
+			HTTP struct {
+				Servers map[string]struct {
+					Routes []struct {
+						Handle []struct {
+							DynamicUpstreams map[string]json.RawMessage `json:"dynamic_upstreams"`
+						} `json:"handle"`
+					} `json:"routes"`
+				} `json:"servers"`
+			} `json:"http"`
+		} `json:"apps"`
+	}
+	if err := json.Unmarshal(adapted, &tree); err != nil {
+		t.Fatalf("decoding adapted config: %v", err)
+	}
+	source := tree.Apps.HTTP.Servers["srv0"].Routes[0].Handle[0].DynamicUpstreams
+	delete(source, "source")
+	raw, _ := json.Marshal(source)
+
+	// Caddy loads the module with a strict decoder
+	var loaded BlockchainHealthUpstream
+	if err := caddy.StrictUnmarshalJSON(raw, &loaded); err != nil {
+		t.Fatalf("loading adapted config: %v", err)
+	}
+	if !reflect.DeepEqual(&parsed, &loaded) {
+		t.Errorf("adapted config differs from the Caddyfile:\nparsed: %+v\nloaded: %+v", &parsed, &loaded)
+	}
+}
+
+func TestJSONConfig_NodeWeightDefault(t *testing.T) {
+	var b BlockchainHealthUpstream
+	err := caddy.StrictUnmarshalJSON([]byte(`{"nodes": [
+		{"name": "a", "url": "http://localhost:26657", "type": "cosmos"},
+		{"name": "b", "url": "http://localhost:26658", "type": "cosmos", "weight": 7}
+	]}`), &b)
+	if err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	if b.Nodes[0].Weight != defaultNodeWeight || b.Nodes[1].Weight != 7 {
+		t.Errorf("expected weights %d and 7, got %d and %d", defaultNodeWeight, b.Nodes[0].Weight, b.Nodes[1].Weight)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+}
+
+func TestJSONConfig_RejectsInvalidSettings(t *testing.T) {
+	tests := map[string]string{
+		"unknown field":          `{"health_check": {"intervall": "10s"}}`,
+		"unknown node field":     `{"nodes": [{"name": "a", "url": "http://localhost:26657", "type": "cosmos", "wieght": 1}]}`,
+		"invalid node_type":      `{"chain": {"node_type": "solana"}}`,
+		"invalid chain_preset":   `{"chain": {"chain_preset": "polkadot"}}`,
+		"invalid service_type":   `{"chain": {"service_type": "grpc"}}`,
+		"invalid fallback":       `{"legacy": {"fallback_behavior": "ignore"}}`,
+		"invalid probe_mode":     `{"health_check": {"probe_mode": "block"}}`,
+		"invalid log component":  `{"monitoring": {"component_log_levels": {"proxy": "debug"}}}`,
+		"invalid fallback route": `{"failure_handling": {"fallback_strategy": "random"}}`,
+	}
+	for name, config := range tests {
+		var b BlockchainHealthUpstream
+		if err := caddy.StrictUnmarshalJSON([]byte(config), &b); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestJSONConfig_EnvironmentPlaceholders(t *testing.T) {
+	t.Setenv("JSON_CONFIG_TEST_SERVERS", "http://node1:26657 http://node2:26657")
+
+	var b BlockchainHealthUpstream
+	if err := caddy.StrictUnmarshalJSON([]byte(`{"environment": {"rpc_servers": "{env.JSON_CONFIG_TEST_SERVERS}"}}`), &b); err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	if err := b.processServerLists(); err != nil {
+		t.Fatalf("processing server lists: %v", err)
+	}
+	if len(b.Nodes) != 2 || b.Nodes[0].URL != "http://node1:26657" {
+		t.Errorf("expected nodes from the environment variable, got %+v", b.Nodes)
+	}
+	if b.Environment.RPCServers != "{env.JSON_CONFIG_TEST_SERVERS}" {
+		t.Errorf("expected the configured placeholder to be kept, got %q", b.Environment.RPCServers)
+	}
+}
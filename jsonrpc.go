@@ -0,0 +1,132 @@
+package blockchain_health
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+)
+
+// JSON-RPC 2.0 error codes used when a request is refused before proxying
+const (
+	rpcCodeParseError     = -32700
+	rpcCodeInvalidRequest = -32600
+	rpcCodeMethodNotFound = -32601
+	rpcCodeInternalError  = -32603
+)
+
+// rpcError is a JSON-RPC error object
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// rpcErrorResponse is a JSON-RPC response carrying an error
+type rpcErrorResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   rpcError        `json:"error"`
+}
+
+// readRPCBody reads the request body, allowing at most limit bytes when limit
+// is positive, and restores it so the request can still be proxied. It
+// reports whether the body exceeded the limit.
+func readRPCBody(r *http.Request, limit int64) ([]byte, bool, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, false, nil
+	}
+
+	reader := io.Reader(r.Body)
+	if limit > 0 {
+		reader = io.LimitReader(r.Body, limit+1)
+	}
+	body, err := io.ReadAll(reader)
+	_ = r.Body.Close()
+	if err != nil {
+		return nil, false, err
+	}
+	if limit > 0 && int64(len(body)) > limit {
+		return nil, true, nil
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	return body, false, nil
+}
+
+// errInvalidRPC marks bodies that look like JSON-RPC but can't be decoded.
+// Nodes still run the valid calls of such a batch, so these bodies must not
+// be proxied unchecked.
+var errInvalidRPC = errors.New("invalid JSON-RPC request")
+
+// parseRPCCalls decodes a single JSON-RPC call or a batch. It reports whether
+// the body was a batch; bodies that are not JSON-RPC return an error, which
+// wraps errInvalidRPC if the body is a batch or carries a jsonrpc member.
+// Batch elements are decoded one by one, as nodes do, so a single malformed
+// element fails the whole body.
+func parseRPCCalls(body []byte) ([]rpcMessage, bool, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return nil, false, fmt.Errorf("empty body")
+	}
+
+	if trimmed[0] == '[' {
+		var elements []json.RawMessage
+		if err := json.Unmarshal(trimmed, &elements); err != nil {
+			return nil, true, fmt.Errorf("%w: %v", errInvalidRPC, err)
+		}
+		calls := make([]rpcMessage, len(elements))
+		for i, element := range elements {
+			element = bytes.TrimSpace(element)
+			if len(element) == 0 || element[0] != '{' {
+				return nil, true, fmt.Errorf("%w: batch element %d is not an object", errInvalidRPC, i)
+			}
+			if err := json.Unmarshal(element, &calls[i]); err != nil {
+				return nil, true, fmt.Errorf("%w: batch element %d: %v", errInvalidRPC, i, err)
+			}
+		}
+		return calls, true, nil
+	}
+
+	var call rpcMessage
+	if err := json.Unmarshal(trimmed, &call); err != nil {
+		if trimmed[0] == '{' && bytes.Contains(trimmed, []byte(`"jsonrpc"`)) {
+			return nil, false, fmt.Errorf("%w: %v", errInvalidRPC, err)
+		}
+		return nil, false, err
+	}
+	return []rpcMessage{call}, false, nil
+}
+
+// writeInvalidRPC refuses a body that looks like JSON-RPC but can't be decoded
+func writeInvalidRPC(w http.ResponseWriter) error {
+	return writeRPCError(w, http.StatusBadRequest, nil, rpcCodeInvalidRequest, errInvalidRPC.Error())
+}
+
+// matchRPCMethod reports whether method matches any of the patterns, which
+// may use shell wildcards such as admin_*
+func matchRPCMethod(patterns []string, method string) (string, bool) {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, method); err == nil && matched {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// writeRPCError answers with a JSON-RPC error. A nil id is sent as null.
+func writeRPCError(w http.ResponseWriter, status int, id json.RawMessage, code int, message string) error {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(rpcErrorResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   rpcError{Code: code, Message: message},
+	})
+}
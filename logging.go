@@ -0,0 +1,135 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Logging components that support per-component level overrides
+const (
+	LogComponentUpstream = "upstream"
+	LogComponentChecker  = "checker"
+	LogComponentHandlers = "handlers"
+)
+
+// levelOverrideCore wraps a zapcore.Core and applies its own minimum level.
+// Entries still go through the wrapped core's Check, so Caddy's per-log
+// include/exclude filters and sampling keep applying; only the level gate of
+// the wrapped core is relaxed, which lets the module log at debug even when
+// the Caddy logger is at info.
+type levelOverrideCore struct {
+	zapcore.Core
+	level zapcore.Level
+}
+
+// Enabled reports whether the override level allows the given level
+func (c *levelOverrideCore) Enabled(lvl zapcore.Level) bool {
+	return c.level.Enabled(lvl)
+}
+
+// With keeps the override level on child cores
+func (c *levelOverrideCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelOverrideCore{Core: c.Core.With(fields), level: c.level}
+}
+
+// Check asks the wrapped core which sinks accept the entry. Entries below the
+// wrapped core's level are checked at the lowest level it enables, and the
+// accepted sinks are then written with the original entry.
+func (c *levelOverrideCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(ent.Level) {
+		return ce
+	}
+
+	probe := ent
+	for !c.Core.Enabled(probe.Level) {
+		if probe.Level >= zapcore.FatalLevel {
+			return ce
+		}
+		probe.Level++
+	}
+
+	inner := c.Core.Check(probe, nil)
+	if inner == nil {
+		return ce
+	}
+	return ce.AddCore(ent, &checkedRelayCore{checked: inner})
+}
+
+// checkedRelayCore writes an entry to the sinks collected by an inner Check
+type checkedRelayCore struct {
+	checked *zapcore.CheckedEntry
+}
+
+func (r *checkedRelayCore) Enabled(zapcore.Level) bool { return true }
+
+func (r *checkedRelayCore) With([]zapcore.Field) zapcore.Core { return r }
+
+func (r *checkedRelayCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, r)
+}
+
+// Write restores the original entry (level included) before writing
+func (r *checkedRelayCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	r.checked.Entry = ent
+	r.checked.Write(fields...)
+	return nil
+}
+
+func (r *checkedRelayCore) Sync() error { return nil }
+
+// parseLogLevel parses a configured log level name
+func parseLogLevel(level string) (zapcore.Level, error) {
+	lvl, err := zapcore.ParseLevel(strings.ToLower(strings.TrimSpace(level)))
+	if err != nil {
+		return lvl, fmt.Errorf("invalid log level %q (must be debug, info, warn, or error)", level)
+	}
+	return lvl, nil
+}
+
+// isValidLogComponent reports whether name is a known logging component
+func isValidLogComponent(name string) bool {
+	switch name {
+	case LogComponentUpstream, LogComponentChecker, LogComponentHandlers:
+		return true
+	default:
+		return false
+	}
+}
+
+// componentLogLevel returns the effective level name for a component.
+// A component override wins over the module-wide log_level; an empty
+// result means the Caddy logger level is used unchanged.
+func componentLogLevel(cfg MonitoringConfig, component string) string {
+	if level := cfg.ComponentLogLevels[component]; level != "" {
+		return level
+	}
+	return cfg.LogLevel
+}
+
+// newComponentLogger returns a logger for the given component that honours
+// the module's log level overrides
+func newComponentLogger(base *zap.Logger, cfg MonitoringConfig, component string) *zap.Logger {
+	if base == nil {
+		return zap.NewNop()
+	}
+
+	level := componentLogLevel(cfg, component)
+	if level == "" {
+		return base
+	}
+
+	lvl, err := parseLogLevel(level)
+	if err != nil {
+		base.Warn("ignoring invalid log level override",
+			zap.String("component", component),
+			zap.String("log_level", level))
+		return base
+	}
+
+	return base.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &levelOverrideCore{Core: core, level: lvl}
+	}))
+}
@@ -0,0 +1,136 @@
+package blockchain_health
+
+import (
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestComponentLogger_DebugOverridesInfoBase verifies log_level debug works
+// even when the underlying Caddy logger only enables info
+func TestComponentLogger_DebugOverridesInfoBase(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	base := zap.New(core)
+
+	logger := newComponentLogger(base, MonitoringConfig{LogLevel: "debug"}, LogComponentChecker)
+	logger.Debug("debug message")
+
+	if logs.Len() != 1 {
+		t.Fatalf("Expected debug entry to be written, got %d entries", logs.Len())
+	}
+}
+
+// TestComponentLogger_ComponentOverride verifies per-component levels win
+// over the module-wide level
+func TestComponentLogger_ComponentOverride(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	base := zap.New(core)
+
+	cfg := MonitoringConfig{
+		LogLevel: "debug",
+		ComponentLogLevels: map[string]string{
+			LogComponentHandlers: "warn",
+		},
+	}
+
+	handlers := newComponentLogger(base, cfg, LogComponentHandlers)
+	handlers.Info("suppressed")
+	handlers.Warn("kept")
+
+	upstream := newComponentLogger(base, cfg, LogComponentUpstream)
+	upstream.Debug("kept")
+
+	if logs.Len() != 2 {
+		t.Fatalf("Expected 2 entries, got %d", logs.Len())
+	}
+	if logs.FilterMessage("suppressed").Len() != 0 {
+		t.Error("Expected info entry to be suppressed for handlers component")
+	}
+}
+
+// namedFilterCore mimics Caddy's per-log filtering core: entries from loggers
+// named in exclude are dropped in Check
+type namedFilterCore struct {
+	zapcore.Core
+	exclude string
+}
+
+func (c *namedFilterCore) With(fields []zapcore.Field) zapcore.Core {
+	return &namedFilterCore{Core: c.Core.With(fields), exclude: c.exclude}
+}
+
+func (c *namedFilterCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.LoggerName == c.exclude {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// TestComponentLogger_RespectsFilteredSinks verifies overridden entries still
+// honour per-sink filters when the base logger tees several Caddy logs
+func TestComponentLogger_RespectsFilteredSinks(t *testing.T) {
+	includedCore, included := observer.New(zapcore.InfoLevel)
+	excludedCore, excluded := observer.New(zapcore.InfoLevel)
+
+	base := zap.New(zapcore.NewTee(
+		&namedFilterCore{Core: includedCore},
+		&namedFilterCore{Core: excludedCore, exclude: "blockchain_health"},
+	)).Named("blockchain_health")
+
+	logger := newComponentLogger(base, MonitoringConfig{LogLevel: "debug"}, LogComponentChecker)
+	logger.Debug("debug message")
+	logger.Info("info message")
+
+	if included.Len() != 2 {
+		t.Fatalf("Expected 2 entries in the including sink, got %d", included.Len())
+	}
+	if entry := included.FilterMessage("debug message").All(); len(entry) != 1 || entry[0].Level != zapcore.DebugLevel {
+		t.Errorf("Expected debug entry to keep its level, got %+v", entry)
+	}
+	if excluded.Len() != 0 {
+		t.Errorf("Expected excluding sink to receive nothing, got %d entries", excluded.Len())
+	}
+}
+
+// TestComponentLogger_NoOverride verifies an empty level keeps the base logger
+func TestComponentLogger_NoOverride(t *testing.T) {
+	base := zap.NewNop()
+	if logger := newComponentLogger(base, MonitoringConfig{}, LogComponentUpstream); logger != base {
+		t.Error("Expected base logger to be returned unchanged")
+	}
+}
+
+// TestLogLevelCaddyfile tests parsing of module-wide and per-component log levels
+func TestLogLevelCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		log_level info
+		log_level checker debug
+		log_level handlers error
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+
+	if b.Monitoring.LogLevel != "info" {
+		t.Errorf("Expected log level info, got %s", b.Monitoring.LogLevel)
+	}
+	if b.Monitoring.ComponentLogLevels[LogComponentChecker] != "debug" {
+		t.Errorf("Expected checker level debug, got %s", b.Monitoring.ComponentLogLevels[LogComponentChecker])
+	}
+	if b.Monitoring.ComponentLogLevels[LogComponentHandlers] != "error" {
+		t.Errorf("Expected handlers level error, got %s", b.Monitoring.ComponentLogLevels[LogComponentHandlers])
+	}
+
+	d = caddyfile.NewTestDispenser(`blockchain_health {
+		log_level proxy debug
+	}`)
+	var bad BlockchainHealthUpstream
+	if err := bad.UnmarshalCaddyfile(d); err == nil {
+		t.Error("Expected error for unknown log_level component")
+	}
+}
@@ -0,0 +1,280 @@
+package blockchain_health
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// eth_getLogs range probing settings
+const (
+	logsRangeRefresh      = time.Hour
+	logsRangeRetry        = 5 * time.Minute
+	logsRangeProbeTimeout = 30 * time.Second
+)
+
+// logsRangeProbes are the block ranges tried, widest first, when probing a
+// node's eth_getLogs limit. They cover the limits common providers enforce;
+// a node accepting the widest is taken to have no limit.
+var logsRangeProbes = []uint64{100000, 10000, 5000, 3000, 2000, 1000, 500, 100}
+
+// logsRangeProbeAddress is filtered on by range probes so they match no logs
+const logsRangeProbeAddress = "0x0000000000000000000000000000000000000000"
+
+// logsRangeVar is the request variable set when no node's eth_getLogs limit
+// covers the requested range, for blockchain_logs_split to split it
+const logsRangeVar = "blockchain_health.logs_range"
+
+// logsRangeRefusal records why an eth_getLogs request could not be routed:
+// the widest range a healthy node accepts and the head used for block tags
+type logsRangeRefusal struct {
+	limit uint64
+	head  uint64
+}
+
+// logsRangeState holds the last probed eth_getLogs limit for a node
+type logsRangeState struct {
+	limit     uint64
+	known     bool
+	nextProbe time.Time
+	probing   bool
+}
+
+// GetLogsRange implements LogsRangeProber for EVM nodes. It queries
+// eth_getLogs over the ranges of logsRangeProbes ending at latest, widest
+// first, and returns the first one the node accepts, or 0 when it accepts
+// the widest. JSON-RPC errors count as a refused range; rate limits and
+// transport errors abort the probe.
+func (e *EVMHandler) GetLogsRange(ctx context.Context, url string, latest uint64) (uint64, error) {
+	widest := true
+	var tried uint64
+	for _, span := range logsRangeProbes {
+		// Short chains are probed over their whole length first
+		span = min(span, latest+1)
+		if span == tried {
+			continue
+		}
+		tried = span
+		filter := map[string]interface{}{
+			"fromBlock": fmt.Sprintf("0x%x", latest-span+1),
+			"toBlock":   fmt.Sprintf("0x%x", latest),
+			"address":   logsRangeProbeAddress,
+		}
+		_, err := e.callJSONRPC(ctx, url, "eth_getLogs", []interface{}{filter})
+		if err == nil {
+			if widest {
+				return 0, nil
+			}
+			return span, nil
+		}
+		var rpcErr *jsonRPCError
+		if !errors.As(err, &rpcErr) {
+			return 0, fmt.Errorf("probing a range of %d blocks: %w", span, err)
+		}
+		widest = false
+	}
+	return 0, fmt.Errorf("node refused every eth_getLogs range down to %d blocks", logsRangeProbes[len(logsRangeProbes)-1])
+}
+
+// nodeLogsRange returns the eth_getLogs limit configured in a node's
+// max_logs_range metadata
+func nodeLogsRange(node NodeConfig) (uint64, bool) {
+	value, ok := node.Metadata["max_logs_range"]
+	if !ok {
+		return 0, false
+	}
+	limit, err := strconv.ParseUint(value, 10, 64)
+	return limit, err == nil && limit > 0
+}
+
+// trackLogsRange applies an EVM node's eth_getLogs limit to its health: the
+// max_logs_range metadata when set, else the last probed limit, starting a
+// background probe when that is missing or stale
+func (h *HealthChecker) trackLogsRange(node NodeConfig, health *NodeHealth) {
+	if node.Type != NodeTypeEVM {
+		return
+	}
+	if limit, ok := nodeLogsRange(node); ok {
+		health.MaxLogsRange = limit
+		return
+	}
+	if !h.config.BlockValidation.TrackLogsRange || !health.Healthy || health.BlockHeight == 0 {
+		return
+	}
+
+	probeURL := node.rpcURL()
+	if probeURL == "" {
+		return
+	}
+
+	h.mutex.Lock()
+	state, exists := h.logsRanges[node.key()]
+	if !exists {
+		state = &logsRangeState{}
+		h.logsRanges[node.key()] = state
+	}
+	if state.known {
+		health.MaxLogsRange = state.limit
+	}
+	stale := !state.probing && !time.Now().Before(state.nextProbe)
+	if stale {
+		state.probing = true
+	}
+	h.mutex.Unlock()
+
+	if stale {
+		height := health.BlockHeight
+		h.goBackground(func() { h.probeLogsRange(node, probeURL, height) })
+	}
+}
+
+// probeLogsRange finds and stores the eth_getLogs limit of a node. Failed
+// probes are retried after logsRangeRetry rather than on every check.
+func (h *HealthChecker) probeLogsRange(node NodeConfig, url string, latest uint64) {
+	defer h.recoverCheckPanic(node, nil)
+	prober, ok := h.evmHandler.(LogsRangeProber)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(h.ctx, logsRangeProbeTimeout)
+	defer cancel()
+
+	limit, err := prober.GetLogsRange(ctx, url, latest)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	state := h.logsRanges[node.key()]
+	state.probing = false
+	if err != nil {
+		state.nextProbe = time.Now().Add(logsRangeRetry)
+		h.logger.Debug("eth_getLogs range probe failed",
+			zap.String("node", node.Name),
+			zap.Error(err))
+		return
+	}
+
+	state.limit = limit
+	state.known = true
+	state.nextProbe = time.Now().Add(logsRangeRefresh)
+	h.logger.Debug("eth_getLogs range probed",
+		zap.String("node", node.Name),
+		zap.Uint64("max_logs_range", limit))
+}
+
+// servesLogsRange reports whether a node accepts an eth_getLogs range of
+// span blocks. Nodes without a known limit are assumed to.
+func (n *NodeHealth) servesLogsRange(span uint64) bool {
+	return span == 0 || n.MaxLogsRange == 0 || span <= n.MaxLogsRange
+}
+
+// logsFilter is the filter object of an eth_getLogs call
+type logsFilter struct {
+	BlockHash json.RawMessage `json:"blockHash"`
+	FromBlock json.RawMessage `json:"fromBlock"`
+	ToBlock   json.RawMessage `json:"toBlock"`
+}
+
+// blockRange resolves the filter's block range, with block tags other than
+// "earliest" taken as head. Block hash filters cover one block and have no
+// range.
+func (f logsFilter) blockRange(head uint64) (from, to uint64, ok bool) {
+	if len(f.BlockHash) > 0 && !bytes.Equal(f.BlockHash, []byte("null")) {
+		return 0, 0, false
+	}
+	from, ok = resolveLogsBlock(f.FromBlock, head)
+	if !ok {
+		return 0, 0, false
+	}
+	to, ok = resolveLogsBlock(f.ToBlock, head)
+	if !ok || from > to {
+		return 0, 0, false
+	}
+	return from, to, true
+}
+
+// resolveLogsBlock resolves a block number or tag of a log filter; a missing
+// one defaults to latest
+func resolveLogsBlock(raw json.RawMessage, head uint64) (uint64, bool) {
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return head, true
+	}
+	if number, ok := parseBlockNumber(raw); ok {
+		return number, true
+	}
+	var tag string
+	if json.Unmarshal(raw, &tag) != nil {
+		return 0, false
+	}
+	switch strings.ToLower(tag) {
+	case "earliest":
+		return 0, true
+	case "latest", "pending", "safe", "finalized":
+		return head, true
+	}
+	return 0, false
+}
+
+// logsCallRange returns the filter and block range of an eth_getLogs call
+func logsCallRange(call rpcMessage, head uint64) (map[string]json.RawMessage, uint64, uint64, bool) {
+	if call.Method != "eth_getLogs" {
+		return nil, 0, 0, false
+	}
+	var params []json.RawMessage
+	if json.Unmarshal(call.Params, &params) != nil || len(params) != 1 {
+		return nil, 0, 0, false
+	}
+	var filter logsFilter
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(params[0], &filter) != nil || json.Unmarshal(params[0], &fields) != nil {
+		return nil, 0, 0, false
+	}
+	from, to, ok := filter.blockRange(head)
+	if !ok {
+		return nil, 0, 0, false
+	}
+	return fields, from, to, true
+}
+
+// requestedLogsRange returns the widest block range of the eth_getLogs calls
+// in a request, with block tags resolved to the pool's highest block, and
+// that head. It only reads the request while some node has a known limit.
+func requestedLogsRange(r *http.Request, results []*NodeHealth) (uint64, uint64) {
+	if r == nil || r.Method != http.MethodPost {
+		return 0, 0
+	}
+	limited := false
+	var head uint64
+	for _, health := range results {
+		limited = limited || health.MaxLogsRange > 0
+		head = max(head, health.BlockHeight)
+	}
+	if !limited {
+		return 0, 0
+	}
+
+	body, _, err := readRPCBody(r, 0)
+	if err != nil {
+		return 0, 0
+	}
+	calls, _, err := parseRPCCalls(body)
+	if err != nil {
+		return 0, 0
+	}
+	var widest uint64
+	for _, call := range calls {
+		if _, from, to, ok := logsCallRange(call, head); ok {
+			widest = max(widest, to-from+1)
+		}
+	}
+	return widest, head
+}
@@ -0,0 +1,185 @@
+package blockchain_health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+// createLogsRangeEVMServer serves a synced EVM node at head that refuses
+// eth_getLogs ranges wider than limit blocks, or none when limit is 0
+func createLogsRangeEVMServer(t *testing.T, head, limit uint64) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string       `json:"method"`
+			Params []logsFilter `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		switch req.Method {
+		case "eth_blockNumber":
+			_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":"0x%x"}`, head)
+		case "eth_getLogs":
+			from, to, ok := req.Params[0].blockRange(head)
+			if !ok || (limit > 0 && to-from+1 > limit) {
+				_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"query exceeds max block range %d"}}`, limit)
+				return
+			}
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":[]}`))
+		default:
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":false}`))
+		}
+	}))
+}
+
+func TestEVMHandler_GetLogsRange(t *testing.T) {
+	handler := NewEVMHandler(5*time.Second, zaptest.NewLogger(t))
+
+	tests := []struct {
+		name    string
+		head    uint64
+		limit   uint64
+		want    uint64
+		wantErr bool
+	}{
+		{name: "provider limit", head: 1000000, limit: 2000, want: 2000},
+		{name: "between probes", head: 1000000, limit: 4000, want: 3000},
+		{name: "unlimited", head: 1000000, want: 0},
+		{name: "short chain", head: 7000, limit: 5000, want: 5000},
+		{name: "refuses every range", head: 1000000, limit: 50, wantErr: true},
+	}
+	for _, tt := range tests {
+		server := createLogsRangeEVMServer(t, tt.head, tt.limit)
+		limit, err := handler.GetLogsRange(context.Background(), server.URL, tt.head)
+		server.Close()
+		if (err != nil) != tt.wantErr || limit != tt.want {
+			t.Errorf("%s: expected %d (error %v), got %d (%v)", tt.name, tt.want, tt.wantErr, limit, err)
+		}
+	}
+}
+
+func TestTrackLogsRange(t *testing.T) {
+	probed := createLogsRangeEVMServer(t, 1000000, 2000)
+	defer probed.Close()
+	configured := createLogsRangeEVMServer(t, 1000000, 0)
+	defer configured.Close()
+
+	nodes := []NodeConfig{
+		{Name: "probed", URL: probed.URL, Type: NodeTypeEVM, Weight: 1},
+		{Name: "configured", URL: configured.URL, Type: NodeTypeEVM, Weight: 1, Metadata: map[string]string{"max_logs_range": "500"}},
+	}
+	checker := createTestUpstream(nodes, zaptest.NewLogger(t)).healthChecker
+	checker.config.BlockValidation.TrackLogsRange = true
+
+	if health := checker.probeNode(context.Background(), nodes[1]); health.MaxLogsRange != 500 {
+		t.Errorf("expected the max_logs_range metadata to apply, got %d", health.MaxLogsRange)
+	}
+
+	// The first check starts the probe; later checks apply its result
+	checker.probeNode(context.Background(), nodes[0])
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		checker.mutex.RLock()
+		known := checker.logsRanges[nodes[0].key()].known
+		checker.mutex.RUnlock()
+		if known || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if health := checker.probeNode(context.Background(), nodes[0]); health.MaxLogsRange != 2000 {
+		t.Errorf("expected the probed limit of 2000 blocks, got %d", health.MaxLogsRange)
+	}
+}
+
+func TestLogsRangeRouting(t *testing.T) {
+	narrow := createLogsRangeEVMServer(t, 100000, 0)
+	defer narrow.Close()
+	wide := createLogsRangeEVMServer(t, 100000, 0)
+	defer wide.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "narrow", URL: narrow.URL, Type: NodeTypeEVM, Weight: 1, Metadata: map[string]string{"max_logs_range": "1000"}},
+		{Name: "wide", URL: wide.URL, Type: NodeTypeEVM, Weight: 1, Metadata: map[string]string{"max_logs_range": "10000"}},
+	}, zaptest.NewLogger(t))
+
+	logsRequest := func(from, to string) *http.Request {
+		body := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"eth_getLogs","params":[{"fromBlock":%q,"toBlock":%q}]}`, from, to)
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		return r.WithContext(context.WithValue(r.Context(), caddyhttp.VarsCtxKey, map[string]any{}))
+	}
+
+	tests := []struct {
+		name     string
+		from, to string
+		want     []string
+	}{
+		{name: "within every limit", from: "0x1", to: "0x100", want: []string{narrow.URL, wide.URL}},
+		{name: "past the narrow limit", from: "0x1", to: "0x1388", want: []string{wide.URL}},
+		{name: "tags resolve to the head", from: "0x182b8", to: "latest", want: []string{wide.URL}},
+	}
+	for _, tt := range tests {
+		upstreams, err := upstream.GetUpstreams(logsRequest(tt.from, tt.to))
+		if err != nil {
+			t.Fatalf("%s: GetUpstreams failed: %v", tt.name, err)
+		}
+		if len(upstreams) != len(tt.want) {
+			t.Fatalf("%s: expected %d upstreams, got %d", tt.name, len(tt.want), len(upstreams))
+		}
+		for i, up := range upstreams {
+			if up.Dial != getDynamicTestHostFromURL(tt.want[i]) {
+				t.Errorf("%s: expected %s, got %s", tt.name, tt.want[i], up.Dial)
+			}
+		}
+	}
+
+	// A range no node accepts is refused with the widest limit
+	r := logsRequest("earliest", "latest")
+	_, err := upstream.GetUpstreams(r)
+	if !errors.Is(err, ErrNoHealthyUpstreams) || !strings.Contains(err.Error(), "at most 10000 blocks") {
+		t.Errorf("expected the range to be refused naming the widest limit, got %v", err)
+	}
+	if refusal, ok := caddyhttp.GetVar(r.Context(), logsRangeVar).(logsRangeRefusal); !ok || refusal.limit != 10000 || refusal.head != 100000 {
+		t.Errorf("expected the refusal to be recorded for splitting, got %+v", refusal)
+	}
+}
+
+func TestTrackLogsRange_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:8545
+			type evm
+			metadata {
+				max_logs_range 2000
+			}
+		}
+		track_logs_range
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if !b.BlockValidation.TrackLogsRange {
+		t.Errorf("unexpected block validation config: %+v", b.BlockValidation)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+
+	b.Nodes[0].Metadata["max_logs_range"] = "0"
+	if err := b.validate(); err == nil {
+		t.Error("expected a zero max_logs_range to be rejected")
+	}
+}
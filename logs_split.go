@@ -0,0 +1,159 @@
+package blockchain_health
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// defaultLogsSplitMaxChunks is how many ranges a request is split into at most
+const defaultLogsSplitMaxChunks = 10
+
+// LogsSplit is a middleware placed in front of reverse_proxy that splits an
+// eth_getLogs request whose block range no node accepts into consecutive
+// ranges within the widest limit of the pool's nodes. The ranges are proxied
+// one after the other and their logs merged into a single response. Requests
+// needing more than MaxChunks ranges, batches and failed ranges get the
+// error the pool answered with.
+type LogsSplit struct {
+	MaxChunks int `json:"max_chunks,omitempty"`
+}
+
+func init() {
+	caddy.RegisterModule(&LogsSplit{})
+}
+
+// CaddyModule returns the Caddy module information.
+func (*LogsSplit) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.blockchain_logs_split",
+		New: func() caddy.Module { return new(LogsSplit) },
+	}
+}
+
+// Provision applies defaults
+func (h *LogsSplit) Provision(ctx caddy.Context) error {
+	if h.MaxChunks == 0 {
+		h.MaxChunks = defaultLogsSplitMaxChunks
+	}
+	return nil
+}
+
+// Validate checks configuration correctness
+func (h *LogsSplit) Validate() error {
+	if h.MaxChunks < 0 {
+		return fmt.Errorf("max_chunks must not be negative")
+	}
+	return nil
+}
+
+// ServeHTTP proxies eth_getLogs requests, splitting those refused for their
+// block range
+func (h *LogsSplit) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	call, ok := logsSplitCall(r)
+	if !ok {
+		return next.ServeHTTP(w, r)
+	}
+
+	// The answer is held back until it is known whether the range was refused
+	caddyhttp.SetVar(r.Context(), logsRangeVar, nil)
+	rec := newHedgeRecorder()
+	err := next.ServeHTTP(rec, r)
+	refusal, refused := caddyhttp.GetVar(r.Context(), logsRangeVar).(logsRangeRefusal)
+	if !refused || refusal.limit == 0 {
+		return rec.writeTo(w, err)
+	}
+
+	fields, from, to, ok := logsCallRange(call, refusal.head)
+	if !ok || (to-from)/refusal.limit+1 > uint64(h.MaxChunks) {
+		return rec.writeTo(w, err)
+	}
+	return h.serveChunks(w, r, call, fields, from, to, refusal.limit, next)
+}
+
+// logsSplitCall returns the eth_getLogs call of a request that is a single
+// JSON-RPC call
+func logsSplitCall(r *http.Request) (rpcMessage, bool) {
+	if r.Method != http.MethodPost {
+		return rpcMessage{}, false
+	}
+	body, _, err := readRPCBody(r, 0)
+	if err != nil || body == nil {
+		return rpcMessage{}, false
+	}
+	calls, batch, err := parseRPCCalls(body)
+	if err != nil || batch || len(calls) != 1 || calls[0].Method != "eth_getLogs" {
+		return rpcMessage{}, false
+	}
+	return calls[0], true
+}
+
+// serveChunks proxies the range from-to in chunks of at most limit blocks and
+// answers with their logs in order. The first chunk that fails is answered
+// as it is.
+func (h *LogsSplit) serveChunks(w http.ResponseWriter, r *http.Request, call rpcMessage, fields map[string]json.RawMessage, from, to, limit uint64, next caddyhttp.Handler) error {
+	id := call.ID
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+
+	logs := []json.RawMessage{}
+	for start := from; start <= to; start += limit {
+		end := min(start+limit-1, to)
+		fields["fromBlock"] = json.RawMessage(fmt.Sprintf(`"0x%x"`, start))
+		fields["toBlock"] = json.RawMessage(fmt.Sprintf(`"0x%x"`, end))
+		body, err := json.Marshal(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      id,
+			"method":  call.Method,
+			"params":  []any{fields},
+		})
+		if err != nil {
+			return caddyhttp.Error(http.StatusInternalServerError, err)
+		}
+
+		req := r.Clone(r.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		caddyhttp.SetVar(r.Context(), logsRangeVar, nil)
+
+		rec := newHedgeRecorder()
+		err = next.ServeHTTP(rec, req)
+		if err != nil || (rec.status != 0 && rec.status != http.StatusOK) {
+			return rec.writeTo(w, err)
+		}
+		var resp struct {
+			Result []json.RawMessage `json:"result"`
+			Error  json.RawMessage   `json:"error"`
+		}
+		if json.Unmarshal(rec.body.Bytes(), &resp) != nil || len(resp.Error) > 0 || resp.Result == nil {
+			return rec.writeTo(w, nil)
+		}
+		logs = append(logs, resp.Result...)
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"result":  logs,
+	})
+	if err != nil {
+		return caddyhttp.Error(http.StatusInternalServerError, err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(body)
+	return err
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*LogsSplit)(nil)
+	_ caddy.Validator             = (*LogsSplit)(nil)
+	_ caddyhttp.MiddlewareHandler = (*LogsSplit)(nil)
+)
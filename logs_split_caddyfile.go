@@ -0,0 +1,54 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	httpcaddyfile "github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+func init() {
+	// Register Caddyfile directive for this handler
+	httpcaddyfile.RegisterHandlerDirective("blockchain_logs_split", parseLogsSplitCaddyfile)
+	httpcaddyfile.RegisterDirectiveOrder("blockchain_logs_split", httpcaddyfile.Before, "reverse_proxy")
+}
+
+func parseLogsSplitCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	ls := new(LogsSplit)
+	if err := ls.UnmarshalCaddyfile(h.Dispenser); err != nil {
+		return nil, err
+	}
+	return ls, nil
+}
+
+// UnmarshalCaddyfile implements caddyfile.Unmarshaler for blockchain_logs_split
+func (h *LogsSplit) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	for d.Next() {
+		for d.NextBlock(0) {
+			switch d.Val() {
+			case "max_chunks":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				chunks, err := strconv.Atoi(d.Val())
+				if err != nil {
+					return d.Errf("invalid max_chunks: %v", err)
+				}
+				h.MaxChunks = chunks
+
+			default:
+				return d.Errf("unknown directive: %s", d.Val())
+			}
+		}
+	}
+
+	if err := h.Validate(); err != nil {
+		return fmt.Errorf("blockchain_logs_split validation: %w", err)
+	}
+	return nil
+}
+
+// Interface guard
+var _ caddyfile.Unmarshaler = (*LogsSplit)(nil)
@@ -0,0 +1,106 @@
+package blockchain_health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// logsPool stands in for reverse_proxy in front of a pool whose nodes accept
+// eth_getLogs ranges of up to limit blocks, answering each range with one
+// log naming its first block
+func logsPool(limit, head uint64, calls *int) caddyhttp.Handler {
+	return caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		*calls++
+		body, _, _ := readRPCBody(r, 0)
+		parsed, _, _ := parseRPCCalls(body)
+		_, from, to, ok := logsCallRange(parsed[0], head)
+		if !ok || to-from+1 > limit {
+			caddyhttp.SetVar(r.Context(), logsRangeVar, logsRangeRefusal{limit: limit, head: head})
+			return caddyhttp.Error(http.StatusServiceUnavailable, ErrNoHealthyUpstreams)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, err := fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":[{"blockNumber":"0x%x"}]}`, parsed[0].ID, from)
+		return err
+	})
+}
+
+func TestLogsSplit_ServeHTTP(t *testing.T) {
+	h := &LogsSplit{MaxChunks: 3}
+	serve := func(body string, calls *int) (*httptest.ResponseRecorder, error) {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r = r.WithContext(context.WithValue(r.Context(), caddyhttp.VarsCtxKey, map[string]any{}))
+		w := httptest.NewRecorder()
+		return w, h.ServeHTTP(w, r, logsPool(1000, 5000, calls))
+	}
+
+	// A range within the limit is proxied once
+	calls := 0
+	w, err := serve(`{"jsonrpc":"2.0","id":7,"method":"eth_getLogs","params":[{"fromBlock":"0x1","toBlock":"0x10"}]}`, &calls)
+	if err != nil || calls != 1 || !strings.Contains(w.Body.String(), `"blockNumber":"0x1"`) {
+		t.Errorf("expected a single proxied range, got %d calls: %s (%v)", calls, w.Body.String(), err)
+	}
+
+	// A wider range is split and its logs merged in order
+	calls = 0
+	w, err = serve(`{"jsonrpc":"2.0","id":7,"method":"eth_getLogs","params":[{"address":"0xabc","fromBlock":"0x1","toBlock":"0x9c4"}]}`, &calls)
+	if err != nil {
+		t.Fatalf("expected the range to be split, got %v", err)
+	}
+	var resp struct {
+		ID     int `json:"id"`
+		Result []struct {
+			BlockNumber string `json:"blockNumber"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response %s: %v", w.Body.String(), err)
+	}
+	if calls != 4 || resp.ID != 7 || len(resp.Result) != 3 {
+		t.Fatalf("expected one refused and 3 split ranges, got %d calls: %s", calls, w.Body.String())
+	}
+	for i, want := range []string{"0x1", "0x3e9", "0x7d1"} {
+		if resp.Result[i].BlockNumber != want {
+			t.Errorf("range %d: expected logs from %s, got %s", i, want, resp.Result[i].BlockNumber)
+		}
+	}
+
+	// Block tags are resolved with the pool's head
+	calls = 0
+	if _, err := serve(`{"jsonrpc":"2.0","id":7,"method":"eth_getLogs","params":[{"fromBlock":"0xbb8","toBlock":"latest"}]}`, &calls); err != nil || calls != 4 {
+		t.Errorf("expected the range up to the head to be split in 3, got %d calls (%v)", calls, err)
+	}
+
+	// Ranges needing more than max_chunks get the pool's error
+	calls = 0
+	if _, err := serve(`{"jsonrpc":"2.0","id":7,"method":"eth_getLogs","params":[{"fromBlock":"earliest","toBlock":"latest"}]}`, &calls); err == nil || calls != 1 {
+		t.Errorf("expected the refusal to pass through, got %d calls (%v)", calls, err)
+	}
+}
+
+func TestLogsSplit_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_logs_split {
+		max_chunks 20
+	}`)
+	var h LogsSplit
+	if err := h.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if h.MaxChunks != 20 {
+		t.Errorf("expected max_chunks 20, got %d", h.MaxChunks)
+	}
+
+	d = caddyfile.NewTestDispenser(`blockchain_logs_split {
+		max_chunks -1
+	}`)
+	if err := new(LogsSplit).UnmarshalCaddyfile(d); err == nil {
+		t.Error("expected a negative max_chunks to be rejected")
+	}
+}
@@ -0,0 +1,333 @@
+package blockchain_health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Mempool divergence defaults
+const (
+	defaultMempoolInterval     = 30 * time.Second
+	defaultMempoolThreshold    = 0.5
+	defaultMempoolFlagAfter    = 3
+	defaultMempoolWeightFactor = 0.5
+
+	// minMempoolSamples is how many nodes must be sampled to tell which of
+	// them diverges
+	minMempoolSamples = 3
+)
+
+// mempoolSample is the pending transaction view of a node. Either part may
+// be missing when the node does not support the call.
+type mempoolSample struct {
+	pending    uint64
+	hasPending bool
+	hashes     map[string]struct{}
+}
+
+// MempoolSampler is implemented by protocol handlers that can report the
+// pending transactions of a node
+type MempoolSampler interface {
+	GetMempool(ctx context.Context, url string) (*mempoolSample, error)
+}
+
+// GetMempool implements MempoolSampler for EVM nodes: the pending count from
+// txpool_status and the transaction hashes of the pending block
+func (e *EVMHandler) GetMempool(ctx context.Context, url string) (*mempoolSample, error) {
+	sample := &mempoolSample{}
+
+	statusErr := func() error {
+		rpcResp, err := e.callJSONRPC(ctx, url, "txpool_status", []interface{}{})
+		if err != nil {
+			return err
+		}
+		status, ok := rpcResp.Result.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("unexpected txpool_status result")
+		}
+		pending, err := parseHexQuantity(status["pending"])
+		if err != nil {
+			return fmt.Errorf("parsing pending count: %w", err)
+		}
+		sample.pending, sample.hasPending = pending, true
+		return nil
+	}()
+
+	blockErr := func() error {
+		rpcResp, err := e.callJSONRPC(ctx, url, "eth_getBlockByNumber", []interface{}{"pending", false})
+		if err != nil {
+			return err
+		}
+		block, ok := rpcResp.Result.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("unexpected pending block result")
+		}
+		txs, _ := block["transactions"].([]interface{})
+		sample.hashes = make(map[string]struct{}, len(txs))
+		for _, tx := range txs {
+			if hash, ok := tx.(string); ok {
+				sample.hashes[strings.ToLower(hash)] = struct{}{}
+			}
+		}
+		return nil
+	}()
+
+	if statusErr != nil && blockErr != nil {
+		return nil, fmt.Errorf("txpool_status: %v; pending block: %w", statusErr, blockErr)
+	}
+	return sample, nil
+}
+
+// mempoolDivergence scores how far each sampled node's mempool is from the
+// rest of the pool, from 0 (in line) to 1. The count signal is the distance
+// of the node's pending count from the median; the hash signal is the share
+// of pending transactions seen by most nodes that the node is missing. A
+// node's score is its worst signal. Fewer than minMempoolSamples nodes are
+// not scored, since with two nodes neither can be told apart.
+func mempoolDivergence(samples map[string]*mempoolSample) map[string]float64 {
+	if len(samples) < minMempoolSamples {
+		return nil
+	}
+
+	var counts []uint64
+	seen := make(map[string]int)
+	withHashes := 0
+	for _, sample := range samples {
+		if sample.hasPending {
+			counts = append(counts, sample.pending)
+		}
+		if sample.hashes != nil {
+			withHashes++
+			for hash := range sample.hashes {
+				seen[hash]++
+			}
+		}
+	}
+
+	var median float64
+	if len(counts) >= minMempoolSamples {
+		sort.Slice(counts, func(i, j int) bool { return counts[i] < counts[j] })
+		mid := len(counts) / 2
+		median = float64(counts[mid])
+		if len(counts)%2 == 0 {
+			median = (float64(counts[mid-1]) + median) / 2
+		}
+	}
+
+	// Transactions most nodes see are the pool's view of the mempool
+	consensus := make([]string, 0, len(seen))
+	if withHashes >= minMempoolSamples {
+		for hash, count := range seen {
+			if count*2 > withHashes {
+				consensus = append(consensus, hash)
+			}
+		}
+	}
+
+	scores := make(map[string]float64, len(samples))
+	for name, sample := range samples {
+		score := 0.0
+		if median > 0 && sample.hasPending && len(counts) >= minMempoolSamples {
+			pending := float64(sample.pending)
+			score = clampUnit(absFloat(pending-median) / maxFloat(pending, median))
+		}
+		if len(consensus) > 0 && sample.hashes != nil {
+			missing := 0
+			for _, hash := range consensus {
+				if _, ok := sample.hashes[hash]; !ok {
+					missing++
+				}
+			}
+			if share := float64(missing) / float64(len(consensus)); share > score {
+				score = share
+			}
+		}
+		scores[name] = score
+	}
+	return scores
+}
+
+// absFloat returns the absolute value of v
+func absFloat(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// maxFloat returns the larger of a and b
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// mempoolState is a node's last divergence score and its streak of
+// divergent samples
+type mempoolState struct {
+	score    float64
+	streak   int
+	flagged  bool
+	lastSeen time.Time
+}
+
+// startMempoolSampling samples the mempools of the pool's EVM nodes every
+// interval until the health checker stops
+func (h *HealthChecker) startMempoolSampling() {
+	interval := defaultMempoolInterval
+	if d, err := time.ParseDuration(h.config.MempoolDivergence.Interval); err == nil && d > 0 {
+		interval = d
+	}
+
+	h.goBackground(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.ctx.Done():
+				return
+			case <-ticker.C:
+				h.sampleMempools(h.ctx)
+			}
+		}
+	})
+}
+
+// sampleMempools samples the healthy EVM nodes and updates their divergence.
+// Unhealthy nodes are skipped, since a lagging node's mempool differs for
+// reasons the health checks already cover.
+func (h *HealthChecker) sampleMempools(ctx context.Context) {
+	sampler, ok := h.evmHandler.(MempoolSampler)
+	if !ok {
+		return
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mutex   sync.Mutex
+		samples = make(map[string]*mempoolSample)
+		names   = make(map[string]string)
+	)
+	for _, node := range h.config.nodeList() {
+		if node.Type != NodeTypeEVM {
+			continue
+		}
+		if health := h.cache.Get(node.key()); health == nil || !health.Healthy {
+			continue
+		}
+		sampleURL := node.rpcURL()
+		if sampleURL == "" {
+			continue
+		}
+
+		wg.Add(1)
+		go func(node NodeConfig, sampleURL string) {
+			defer wg.Done()
+			defer h.recoverCheckPanic(node, nil)
+			sample, err := sampler.GetMempool(ctx, sampleURL)
+			if err != nil {
+				h.logger.Debug("mempool sample failed",
+					zap.String("node", node.Name),
+					zap.Error(err))
+				return
+			}
+			mutex.Lock()
+			samples[node.key()] = sample
+			names[node.key()] = node.Name
+			mutex.Unlock()
+		}(node, sampleURL)
+	}
+	wg.Wait()
+
+	h.recordMempoolDivergence(mempoolDivergence(samples), names)
+}
+
+// recordMempoolDivergence updates the divergence state of the scored nodes.
+// A node is flagged after flag_after divergent samples in a row and cleared
+// by its first sample back under the threshold.
+func (h *HealthChecker) recordMempoolDivergence(scores map[string]float64, names map[string]string) {
+	cfg := h.config.MempoolDivergence
+	now := time.Now()
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for key, score := range scores {
+		name := names[key]
+		state, exists := h.mempoolStates[key]
+		if !exists {
+			state = &mempoolState{}
+			h.mempoolStates[key] = state
+		}
+		state.score = score
+		state.lastSeen = now
+
+		if score > cfg.Threshold {
+			state.streak++
+		} else {
+			state.streak = 0
+		}
+		flagged := state.streak >= cfg.FlagAfter
+		if flagged && !state.flagged {
+			h.logger.Warn("node mempool diverges from the pool",
+				zap.String("node", name),
+				zap.Float64("divergence", score),
+				zap.Int("samples", state.streak))
+		} else if !flagged && state.flagged {
+			h.logger.Info("node mempool back in line with the pool",
+				zap.String("node", name),
+				zap.Float64("divergence", score))
+		}
+		state.flagged = flagged
+
+		if h.metrics != nil {
+			h.metrics.mempoolDivergence.WithLabelValues(name).Set(score)
+			value := 0.0
+			if flagged {
+				value = 1
+			}
+			h.metrics.mempoolDivergent.WithLabelValues(name).Set(value)
+		}
+	}
+
+	// Forget nodes that have not been scored for a while, such as removed ones
+	stale := 10 * defaultMempoolInterval
+	if d, err := time.ParseDuration(cfg.Interval); err == nil && d > 0 {
+		stale = 10 * d
+	}
+	for key, state := range h.mempoolStates {
+		if now.Sub(state.lastSeen) > stale {
+			delete(h.mempoolStates, key)
+		}
+	}
+}
+
+// applyMempoolDivergence copies the divergence state onto the health results
+func (h *HealthChecker) applyMempoolDivergence(results []*NodeHealth) {
+	if !h.config.MempoolDivergence.Enabled {
+		return
+	}
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	for _, health := range results {
+		if health == nil {
+			continue
+		}
+		if state, ok := h.mempoolStates[h.nodeKey(health.Name)]; ok {
+			health.MempoolDivergence = state.score
+			health.MempoolDivergent = state.flagged
+		} else {
+			health.MempoolDivergence = 0
+			health.MempoolDivergent = false
+		}
+	}
+}
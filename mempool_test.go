@@ -0,0 +1,217 @@
+package blockchain_health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap/zaptest"
+)
+
+// createMempoolEVMServer serves a healthy EVM node with the given pending
+// count and pending block transactions
+func createMempoolEVMServer(t *testing.T, pending uint64, hashes ...string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req EVMJSONRPCRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+
+		switch req.Method {
+		case "txpool_status":
+			_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":{"pending":"0x%x","queued":"0x0"}}`, pending)
+		case "eth_getBlockByNumber":
+			txs, _ := json.Marshal(hashes)
+			_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":{"number":"0x3e9","transactions":%s}}`, txs)
+		default:
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x3e8"}`))
+		}
+	}))
+}
+
+func TestEVMHandler_GetMempool(t *testing.T) {
+	server := createMempoolEVMServer(t, 42, "0xAA", "0xbb")
+	defer server.Close()
+
+	handler := NewEVMHandler(5*time.Second, zaptest.NewLogger(t))
+	sample, err := handler.GetMempool(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("GetMempool failed: %v", err)
+	}
+	if !sample.hasPending || sample.pending != 42 {
+		t.Errorf("expected 42 pending transactions, got %+v", sample)
+	}
+	if _, ok := sample.hashes["0xaa"]; !ok || len(sample.hashes) != 2 {
+		t.Errorf("expected the pending block hashes, got %v", sample.hashes)
+	}
+
+	// Nodes without txpool_status still report their pending block
+	partial := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make([]byte, r.ContentLength)
+		_, _ = r.Body.Read(body)
+		if strings.Contains(string(body), "txpool_status") {
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"transactions":["0x01"]}}`))
+	}))
+	defer partial.Close()
+	sample, err = handler.GetMempool(context.Background(), partial.URL)
+	if err != nil {
+		t.Fatalf("GetMempool failed: %v", err)
+	}
+	if sample.hasPending || len(sample.hashes) != 1 {
+		t.Errorf("expected only the pending block, got %+v", sample)
+	}
+}
+
+func TestMempoolDivergence(t *testing.T) {
+	hashes := func(values ...string) map[string]struct{} {
+		set := make(map[string]struct{})
+		for _, v := range values {
+			set[v] = struct{}{}
+		}
+		return set
+	}
+
+	scores := mempoolDivergence(map[string]*mempoolSample{
+		"a": {pending: 100, hasPending: true, hashes: hashes("1", "2", "3", "4")},
+		"b": {pending: 104, hasPending: true, hashes: hashes("1", "2", "3", "4")},
+		"e": {pending: 101, hasPending: true, hashes: hashes("1", "2", "3", "4")},
+		"c": {pending: 98, hasPending: true, hashes: hashes("1", "2", "3")},
+		"d": {pending: 10, hasPending: true, hashes: hashes("9")},
+	})
+	for _, name := range []string{"a", "b", "e"} {
+		if scores[name] > 0.1 {
+			t.Errorf("expected %s to be in line, got %.2f", name, scores[name])
+		}
+	}
+	if scores["c"] != 0.25 {
+		t.Errorf("expected c to miss a quarter of the pending transactions, got %.2f", scores["c"])
+	}
+	if scores["d"] != 1 {
+		t.Errorf("expected d to diverge completely, got %.2f", scores["d"])
+	}
+
+	// Two nodes cannot be told apart
+	if scores := mempoolDivergence(map[string]*mempoolSample{
+		"a": {pending: 100, hasPending: true},
+		"b": {pending: 1, hasPending: true},
+	}); scores != nil {
+		t.Errorf("expected no scores for two nodes, got %v", scores)
+	}
+}
+
+func TestMempoolDivergentNodeDownWeighted(t *testing.T) {
+	servers := []*httptest.Server{
+		createMempoolEVMServer(t, 100, "0x01", "0x02"),
+		createMempoolEVMServer(t, 102, "0x01", "0x02"),
+		createMempoolEVMServer(t, 3),
+	}
+	var nodes []NodeConfig
+	for i, server := range servers {
+		defer server.Close()
+		nodes = append(nodes, NodeConfig{Name: fmt.Sprintf("node-%d", i), URL: server.URL, Type: NodeTypeEVM, ChainType: "test-evm", Weight: 100})
+	}
+
+	upstream := createTestUpstream(nodes, zaptest.NewLogger(t))
+	upstream.config.MempoolDivergence = MempoolDivergenceConfig{Enabled: true, Threshold: 0.5, FlagAfter: 2, WeightFactor: 0.25}
+	checker := upstream.healthChecker
+
+	if _, err := checker.CheckAllNodes(context.Background()); err != nil {
+		t.Fatalf("CheckAllNodes failed: %v", err)
+	}
+
+	// A single divergent sample is not enough to flag the node
+	checker.sampleMempools(context.Background())
+	results, _ := checker.CheckAllNodes(context.Background())
+	for _, health := range results {
+		if health.MempoolDivergent {
+			t.Fatalf("expected no node to be flagged after one sample, got %s", health.Name)
+		}
+	}
+
+	checker.sampleMempools(context.Background())
+	results, _ = checker.CheckAllNodes(context.Background())
+	for _, health := range results {
+		if flagged := health.Name == "node-2"; health.MempoolDivergent != flagged {
+			t.Errorf("%s: expected flagged=%v, got %+v", health.Name, flagged, health)
+		}
+	}
+
+	var m dto.Metric
+	if err := checker.metrics.mempoolDivergent.WithLabelValues("node-2").Write(&m); err != nil {
+		t.Fatalf("reading mempool_divergent: %v", err)
+	}
+	if m.GetGauge().GetValue() != 1 {
+		t.Errorf("expected node-2 to be flagged in the metrics, got %v", m.GetGauge().GetValue())
+	}
+
+	upstreams, err := upstream.GetUpstreams(&http.Request{})
+	if err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+	if len(upstreams) != 3 {
+		t.Fatalf("expected the divergent node to stay in the pool, got %d upstreams", len(upstreams))
+	}
+	for _, up := range upstreams {
+		want := 100
+		if up.Dial == getDynamicTestHostFromURL(servers[2].URL) {
+			want = 25
+		}
+		if up.MaxRequests != want {
+			t.Errorf("%s: expected weight %d, got %d", up.Dial, want, up.MaxRequests)
+		}
+	}
+
+	// A zero weight factor only flags the node
+	upstream.config.MempoolDivergence.WeightFactor = 0
+	upstreams, _ = upstream.GetUpstreams(&http.Request{})
+	for _, up := range upstreams {
+		if up.MaxRequests != 100 {
+			t.Errorf("%s: expected the configured weight, got %d", up.Dial, up.MaxRequests)
+		}
+	}
+}
+
+func TestMempoolDivergence_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:8545
+			type evm
+		}
+		mempool_divergence {
+			interval 1m
+			threshold 0.4
+			flag_after 5
+			weight_factor 0.2
+		}
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	want := MempoolDivergenceConfig{Enabled: true, Interval: "1m", Threshold: 0.4, FlagAfter: 5, WeightFactor: 0.2}
+	if b.MempoolDivergence != want {
+		t.Errorf("unexpected mempool divergence config: %+v", b.MempoolDivergence)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+
+	b.MempoolDivergence.WeightFactor = 2
+	if err := b.validate(); err == nil {
+		t.Error("expected a weight factor above 1 to be rejected")
+	}
+}
@@ -14,7 +14,7 @@ func NewMetrics() *Metrics {
 			Namespace: "caddy",
 			Subsystem: "blockchain_health",
 			Name:      "checks_total",
-			Help:      "Total number of health checks performed",
+			Help:      "Total number of node health checks performed",
 		}),
 		healthyNodes: prometheus.NewGauge(prometheus.GaugeOpts{
 			Namespace: "caddy",
@@ -28,6 +28,18 @@ func NewMetrics() *Metrics {
 			Name:      "unhealthy_nodes",
 			Help:      "Number of currently unhealthy nodes",
 		}),
+		healthyCandidates: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "healthy_candidate_nodes",
+			Help:      "Number of currently healthy candidate nodes",
+		}),
+		unhealthyCandidates: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "unhealthy_candidate_nodes",
+			Help:      "Number of currently unhealthy candidate nodes",
+		}),
 		configuredNodes: prometheus.NewGauge(prometheus.GaugeOpts{
 			Namespace: "caddy",
 			Subsystem: "blockchain_health",
@@ -65,6 +77,266 @@ func NewMetrics() *Metrics {
 			Name:      "upstreams_excluded_total",
 			Help:      "Total number of times a node was excluded from upstreams and why",
 		}, []string{"node_name", "service_type", "reason"}),
+		dryRunExclusions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "dry_run_exclusions_total",
+			Help:      "Total number of times a node would have been excluded if enforcement were enabled",
+		}, []string{"node_name", "service_type", "reason"}),
+		nodeScore: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "node_score",
+			Help:      "Weighted health score of each node (0-1) when scoring is enabled",
+		}, []string{"node_name"}),
+		nodeErrorRate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "node_error_rate",
+			Help:      "Share of failed checks per node over the error rate window",
+		}, []string{"node_name"}),
+		throttledChecks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "throttled_checks_total",
+			Help:      "Total number of health checks rate limited by the node",
+		}, []string{"node_name"}),
+		nodeThrottled: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "node_throttled",
+			Help:      "Whether each node is currently rate limiting health checks (1) or not (0)",
+		}, []string{"node_name"}),
+		healthWaits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "zero_healthy_waits_total",
+			Help:      "Requests held while no node was healthy, by outcome",
+		}, []string{"outcome"}),
+		wsSessionsDrained: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "websocket_sessions_drained_total",
+			Help:      "WebSocket sessions closed because their node became unhealthy",
+		}, []string{"node_name"}),
+		wsConnections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "ws_connections",
+			Help:      "Active proxied WebSocket connections per node",
+		}, []string{"node_name"}),
+		upstreamErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "upstream_errors_total",
+			Help:      "Total number of requests for which no upstream was selected, by cause",
+		}, []string{"cause"}),
+		trafficSplitRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "traffic_split_requests_total",
+			Help:      "Total number of requests routed to each node group of a traffic split",
+		}, []string{"split", "group"}),
+		paidRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "paid_requests_total",
+			Help:      "Requests routed to paid nodes by cost budget",
+		}, []string{"budget"}),
+		paidSpend: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "paid_spend_dollars",
+			Help:      "Month-to-date spend on paid nodes by cost budget",
+		}, []string{"budget"}),
+		checksSkipped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "checks_skipped_total",
+			Help:      "Background node probes skipped because the previous probe of the node was still in progress",
+		}),
+		nodeInfo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "node_info",
+			Help:      "Configured nodes with their stable key, always 1",
+		}, []string{"node", "key", "type", "network"}),
+		poolState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "pool_state",
+			Help:      "State of each pool: 1 for the current state (healthy, degraded, chain_halted, critical or down), 0 otherwise",
+		}, []string{"pool", "state"}),
+		mempoolDivergence: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "mempool_divergence",
+			Help:      "Mempool divergence of each EVM node from the rest of the pool (0-1)",
+		}, []string{"node"}),
+		mempoolDivergent: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "mempool_divergent",
+			Help:      "Whether each EVM node is flagged for a persistently divergent mempool (1 = flagged)",
+		}, []string{"node"}),
+		poolClients: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "pool_clients",
+			Help:      "Nodes of each pool per detected execution or consensus client",
+		}, []string{"pool", "client"}),
+		poolClientDominance: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "pool_client_dominance",
+			Help:      "Share of each pool's nodes running its most common client (1 = a single client)",
+		}, []string{"pool"}),
+		dnsWithdrawn: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "dns_withdrawn",
+			Help:      "Whether DNS failover has withdrawn this gateway from rotation for the pool (1) or not (0)",
+		}, []string{"pool"}),
+		statusPageUpdates: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "status_page_updates_total",
+			Help:      "Status page component updates sent for the pool, by result (success or failure)",
+		}, []string{"pool", "result"}),
+		blockTime: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "block_time_seconds",
+			Help:      "Block time of each chain measured from the pool leader's height",
+		}, []string{"chain", "network"}),
+		prewarmPings: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "prewarm_pings_total",
+			Help:      "Keep-alive pings sent to warm connections to each node, by whether they reused a warm connection, opened a new one or failed",
+		}, []string{"node_name", "connection"}),
+		prewarmHandshake: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "prewarm_handshake_seconds",
+			Help:      "Time prewarm pings spent opening new connections to each node, which requests would otherwise pay",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"node_name"}),
+		nodeSLOCompliance: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "node_slo_compliance",
+			Help:      "Share of each node's health checks over the SLO window meeting the objective (availability or latency)",
+		}, []string{"node_name", "objective"}),
+		nodeSLOBurnRate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "node_slo_burn_rate",
+			Help:      "Rate at which each node burns the error budget of the objective; 1 exhausts it exactly over the window",
+		}, []string{"node_name", "objective"}),
+		chainSLOCompliance: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "chain_slo_compliance",
+			Help:      "Share of each chain's health check rounds over the SLO window meeting the objective (availability or latency)",
+		}, []string{"chain", "network", "objective"}),
+		chainSLOBurnRate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "chain_slo_burn_rate",
+			Help:      "Rate at which each chain burns the error budget of the objective; 1 exhausts it exactly over the window",
+		}, []string{"chain", "network", "objective"}),
+		sweepDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "sweep_duration_seconds",
+			Help:      "Duration of checks of every node of a pool at once, in seconds",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"pool"}),
+		cacheHitRatio: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "cache_hit_ratio",
+			Help:      "Share of lookups of each pool's health cache that found a fresh result",
+		}, []string{"pool"}),
+		goroutines: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "goroutines",
+			Help:      "Number of goroutines in the Caddy process",
+		}),
+		probeQueueDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "probe_queue_depth",
+			Help:      "Node checks of each pool queued for a free worker",
+		}, []string{"pool"}),
+		oldestResultAge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "oldest_result_age_seconds",
+			Help:      "Age of the oldest cached node result of each pool, in seconds",
+		}, []string{"pool"}),
+		checkerStalled: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "checker_stalled",
+			Help:      "Whether the background health checker of each pool stopped completing checks (1) or not (0)",
+		}, []string{"pool"}),
+		panicsRecovered: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "panics_recovered_total",
+			Help:      "Total number of panics recovered in node checks and upstream selection, by source",
+		}, []string{"source"}),
+		nodeQuarantined: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "node_quarantined",
+			Help:      "Whether each node is quarantined (1) or not (0)",
+		}, []string{"node"}),
+		referencesDiscarded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "references_discarded_total",
+			Help:      "Total number of external reference heights discarded as implausible, by reason",
+		}, []string{"reference", "reason"}),
+		blocksBehindPool: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "blocks_behind_pool",
+			Help:      "Blocks each node trails the pool leader of its chain by",
+		}, []string{"node"}),
+		blocksBehindExternal: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "blocks_behind_external",
+			Help:      "Blocks each node trails each external reference by",
+		}, []string{"node", "reference"}),
+		heightValid: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "height_valid",
+			Help:      "Whether each node is within the height threshold of the pool leader (1) or not (0)",
+		}, []string{"node"}),
+		leaderHeight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "leader_height",
+			Help:      "Block height of the pool leader of each chain",
+		}, []string{"chain"}),
+		networkHeight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "network_height",
+			Help:      "Highest of the pool leader and accepted external reference heights of each chain",
+		}, []string{"chain"}),
+		referenceHeight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_health",
+			Name:      "reference_height",
+			Help:      "Block height of each accepted external reference",
+		}, []string{"reference"}),
 	}
 }
 
@@ -120,12 +392,57 @@ func (m *Metrics) Register() error {
 		m.totalChecks,
 		m.healthyNodes,
 		m.unhealthyNodes,
+		m.healthyCandidates,
+		m.unhealthyCandidates,
 		m.configuredNodes,
 		m.checkDuration,
 		m.blockHeightGauge,
 		m.errorCount,
 		m.upstreamsIncluded,
 		m.upstreamsExcluded,
+		m.dryRunExclusions,
+		m.nodeScore,
+		m.nodeErrorRate,
+		m.throttledChecks,
+		m.nodeThrottled,
+		m.healthWaits,
+		m.wsSessionsDrained,
+		m.wsConnections,
+		m.upstreamErrors,
+		m.trafficSplitRequests,
+		m.paidRequests,
+		m.paidSpend,
+		m.checksSkipped,
+		m.nodeInfo,
+		m.poolState,
+		m.mempoolDivergence,
+		m.mempoolDivergent,
+		m.poolClients,
+		m.poolClientDominance,
+		m.dnsWithdrawn,
+		m.statusPageUpdates,
+		m.blockTime,
+		m.prewarmPings,
+		m.prewarmHandshake,
+		m.nodeSLOCompliance,
+		m.nodeSLOBurnRate,
+		m.chainSLOCompliance,
+		m.chainSLOBurnRate,
+		m.sweepDuration,
+		m.cacheHitRatio,
+		m.goroutines,
+		m.probeQueueDepth,
+		m.oldestResultAge,
+		m.checkerStalled,
+		m.panicsRecovered,
+		m.nodeQuarantined,
+		m.referencesDiscarded,
+		m.blocksBehindPool,
+		m.blocksBehindExternal,
+		m.heightValid,
+		m.leaderHeight,
+		m.networkHeight,
+		m.referenceHeight,
 	}
 
 	for _, collector := range collectors {
@@ -155,6 +472,12 @@ func (m *Metrics) registerWith(reg prometheus.Registerer) error {
 	if m.unhealthyNodes, err = registerGauge(reg, m.unhealthyNodes); err != nil {
 		return err
 	}
+	if m.healthyCandidates, err = registerGauge(reg, m.healthyCandidates); err != nil {
+		return err
+	}
+	if m.unhealthyCandidates, err = registerGauge(reg, m.unhealthyCandidates); err != nil {
+		return err
+	}
 	if m.configuredNodes, err = registerGauge(reg, m.configuredNodes); err != nil {
 		return err
 	}
@@ -173,6 +496,135 @@ func (m *Metrics) registerWith(reg prometheus.Registerer) error {
 	if m.upstreamsExcluded, err = registerCounterVec(reg, m.upstreamsExcluded); err != nil {
 		return err
 	}
+	if m.dryRunExclusions, err = registerCounterVec(reg, m.dryRunExclusions); err != nil {
+		return err
+	}
+	if m.nodeScore, err = registerGaugeVec(reg, m.nodeScore); err != nil {
+		return err
+	}
+	if m.nodeErrorRate, err = registerGaugeVec(reg, m.nodeErrorRate); err != nil {
+		return err
+	}
+	if m.throttledChecks, err = registerCounterVec(reg, m.throttledChecks); err != nil {
+		return err
+	}
+	if m.nodeThrottled, err = registerGaugeVec(reg, m.nodeThrottled); err != nil {
+		return err
+	}
+	if m.healthWaits, err = registerCounterVec(reg, m.healthWaits); err != nil {
+		return err
+	}
+	if m.wsSessionsDrained, err = registerCounterVec(reg, m.wsSessionsDrained); err != nil {
+		return err
+	}
+	if m.wsConnections, err = registerGaugeVec(reg, m.wsConnections); err != nil {
+		return err
+	}
+	if m.upstreamErrors, err = registerCounterVec(reg, m.upstreamErrors); err != nil {
+		return err
+	}
+	if m.trafficSplitRequests, err = registerCounterVec(reg, m.trafficSplitRequests); err != nil {
+		return err
+	}
+	if m.paidRequests, err = registerCounterVec(reg, m.paidRequests); err != nil {
+		return err
+	}
+	if m.paidSpend, err = registerGaugeVec(reg, m.paidSpend); err != nil {
+		return err
+	}
+	if m.checksSkipped, err = registerCounter(reg, m.checksSkipped); err != nil {
+		return err
+	}
+	if m.nodeInfo, err = registerGaugeVec(reg, m.nodeInfo); err != nil {
+		return err
+	}
+	if m.poolState, err = registerGaugeVec(reg, m.poolState); err != nil {
+		return err
+	}
+	if m.mempoolDivergence, err = registerGaugeVec(reg, m.mempoolDivergence); err != nil {
+		return err
+	}
+	if m.mempoolDivergent, err = registerGaugeVec(reg, m.mempoolDivergent); err != nil {
+		return err
+	}
+	if m.poolClients, err = registerGaugeVec(reg, m.poolClients); err != nil {
+		return err
+	}
+	if m.poolClientDominance, err = registerGaugeVec(reg, m.poolClientDominance); err != nil {
+		return err
+	}
+	if m.dnsWithdrawn, err = registerGaugeVec(reg, m.dnsWithdrawn); err != nil {
+		return err
+	}
+	if m.statusPageUpdates, err = registerCounterVec(reg, m.statusPageUpdates); err != nil {
+		return err
+	}
+	if m.blockTime, err = registerGaugeVec(reg, m.blockTime); err != nil {
+		return err
+	}
+	if m.prewarmPings, err = registerCounterVec(reg, m.prewarmPings); err != nil {
+		return err
+	}
+	if m.prewarmHandshake, err = registerHistogramVec(reg, m.prewarmHandshake); err != nil {
+		return err
+	}
+	if m.nodeSLOCompliance, err = registerGaugeVec(reg, m.nodeSLOCompliance); err != nil {
+		return err
+	}
+	if m.nodeSLOBurnRate, err = registerGaugeVec(reg, m.nodeSLOBurnRate); err != nil {
+		return err
+	}
+	if m.chainSLOCompliance, err = registerGaugeVec(reg, m.chainSLOCompliance); err != nil {
+		return err
+	}
+	if m.chainSLOBurnRate, err = registerGaugeVec(reg, m.chainSLOBurnRate); err != nil {
+		return err
+	}
+	if m.sweepDuration, err = registerHistogramVec(reg, m.sweepDuration); err != nil {
+		return err
+	}
+	if m.cacheHitRatio, err = registerGaugeVec(reg, m.cacheHitRatio); err != nil {
+		return err
+	}
+	if m.goroutines, err = registerGauge(reg, m.goroutines); err != nil {
+		return err
+	}
+	if m.probeQueueDepth, err = registerGaugeVec(reg, m.probeQueueDepth); err != nil {
+		return err
+	}
+	if m.oldestResultAge, err = registerGaugeVec(reg, m.oldestResultAge); err != nil {
+		return err
+	}
+	if m.checkerStalled, err = registerGaugeVec(reg, m.checkerStalled); err != nil {
+		return err
+	}
+	if m.panicsRecovered, err = registerCounterVec(reg, m.panicsRecovered); err != nil {
+		return err
+	}
+	if m.nodeQuarantined, err = registerGaugeVec(reg, m.nodeQuarantined); err != nil {
+		return err
+	}
+	if m.referencesDiscarded, err = registerCounterVec(reg, m.referencesDiscarded); err != nil {
+		return err
+	}
+	if m.blocksBehindPool, err = registerGaugeVec(reg, m.blocksBehindPool); err != nil {
+		return err
+	}
+	if m.blocksBehindExternal, err = registerGaugeVec(reg, m.blocksBehindExternal); err != nil {
+		return err
+	}
+	if m.heightValid, err = registerGaugeVec(reg, m.heightValid); err != nil {
+		return err
+	}
+	if m.leaderHeight, err = registerGaugeVec(reg, m.leaderHeight); err != nil {
+		return err
+	}
+	if m.networkHeight, err = registerGaugeVec(reg, m.networkHeight); err != nil {
+		return err
+	}
+	if m.referenceHeight, err = registerGaugeVec(reg, m.referenceHeight); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -183,12 +635,57 @@ func (m *Metrics) Unregister() {
 		m.totalChecks,
 		m.healthyNodes,
 		m.unhealthyNodes,
+		m.healthyCandidates,
+		m.unhealthyCandidates,
 		m.configuredNodes,
 		m.checkDuration,
 		m.blockHeightGauge,
 		m.errorCount,
 		m.upstreamsIncluded,
 		m.upstreamsExcluded,
+		m.dryRunExclusions,
+		m.nodeScore,
+		m.nodeErrorRate,
+		m.throttledChecks,
+		m.nodeThrottled,
+		m.healthWaits,
+		m.wsSessionsDrained,
+		m.wsConnections,
+		m.upstreamErrors,
+		m.trafficSplitRequests,
+		m.paidRequests,
+		m.paidSpend,
+		m.checksSkipped,
+		m.nodeInfo,
+		m.poolState,
+		m.mempoolDivergence,
+		m.mempoolDivergent,
+		m.poolClients,
+		m.poolClientDominance,
+		m.dnsWithdrawn,
+		m.statusPageUpdates,
+		m.blockTime,
+		m.prewarmPings,
+		m.prewarmHandshake,
+		m.nodeSLOCompliance,
+		m.nodeSLOBurnRate,
+		m.chainSLOCompliance,
+		m.chainSLOBurnRate,
+		m.sweepDuration,
+		m.cacheHitRatio,
+		m.goroutines,
+		m.probeQueueDepth,
+		m.oldestResultAge,
+		m.checkerStalled,
+		m.panicsRecovered,
+		m.nodeQuarantined,
+		m.referencesDiscarded,
+		m.blocksBehindPool,
+		m.blocksBehindExternal,
+		m.heightValid,
+		m.leaderHeight,
+		m.networkHeight,
+		m.referenceHeight,
 	}
 
 	for _, collector := range collectors {
@@ -334,6 +831,306 @@ func (m *RequestDeadlineMetrics) registerWith(reg prometheus.Registerer) error {
 	return nil
 }
 
+// BackpressureMetrics tracks the backpressure middleware
+type BackpressureMetrics struct {
+	rejectedTotal *prometheus.CounterVec
+	queuedSeconds *prometheus.HistogramVec
+}
+
+// NewBackpressureMetrics creates backpressure metrics
+func NewBackpressureMetrics() *BackpressureMetrics {
+	return &BackpressureMetrics{
+		rejectedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_backpressure",
+			Name:      "rejected_total",
+			Help:      "Total number of requests answered with 429 because the pool was saturated",
+		}, []string{"method"}),
+		queuedSeconds: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_backpressure",
+			Name:      "queued_seconds",
+			Help:      "Time requests waited for a saturated pool, by outcome",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"outcome"}),
+	}
+}
+
+var (
+	backpressureMetricsMu         sync.Mutex
+	backpressureMetricsRegisterer prometheus.Registerer
+)
+
+func acquireBackpressureMetrics(reg prometheus.Registerer) (*BackpressureMetrics, error) {
+	backpressureMetricsMu.Lock()
+	defer backpressureMetricsMu.Unlock()
+
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+
+	if bpMetrics == nil || backpressureMetricsRegisterer != reg {
+		metrics := NewBackpressureMetrics()
+		var err error
+		if metrics.rejectedTotal, err = registerCounterVec(reg, metrics.rejectedTotal); err != nil {
+			return nil, err
+		}
+		if metrics.queuedSeconds, err = registerHistogramVec(reg, metrics.queuedSeconds); err != nil {
+			return nil, err
+		}
+		bpMetrics = metrics
+		backpressureMetricsRegisterer = reg
+	}
+
+	return bpMetrics, nil
+}
+
+// RPCGuardMetrics tracks the JSON-RPC guard middleware
+type RPCGuardMetrics struct {
+	rejectedTotal *prometheus.CounterVec
+}
+
+// NewRPCGuardMetrics creates JSON-RPC guard metrics
+func NewRPCGuardMetrics() *RPCGuardMetrics {
+	return &RPCGuardMetrics{
+		rejectedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_rpc_guard",
+			Name:      "rejected_total",
+			Help:      "Total number of requests or responses refused by the JSON-RPC guard, by reason",
+		}, []string{"reason"}),
+	}
+}
+
+var (
+	rpcGuardMetricsMu         sync.Mutex
+	rpcGuardMetricsRegisterer prometheus.Registerer
+)
+
+func acquireRPCGuardMetrics(reg prometheus.Registerer) (*RPCGuardMetrics, error) {
+	rpcGuardMetricsMu.Lock()
+	defer rpcGuardMetricsMu.Unlock()
+
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+
+	if rgMetrics == nil || rpcGuardMetricsRegisterer != reg {
+		metrics := NewRPCGuardMetrics()
+		var err error
+		if metrics.rejectedTotal, err = registerCounterVec(reg, metrics.rejectedTotal); err != nil {
+			return nil, err
+		}
+		rgMetrics = metrics
+		rpcGuardMetricsRegisterer = reg
+	}
+
+	return rgMetrics, nil
+}
+
+// RateLimitMetrics tracks the per-method rate limiting middleware
+type RateLimitMetrics struct {
+	rejectedTotal *prometheus.CounterVec
+}
+
+// NewRateLimitMetrics creates rate limiting metrics
+func NewRateLimitMetrics() *RateLimitMetrics {
+	return &RateLimitMetrics{
+		rejectedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_rate_limit",
+			Name:      "rejected_total",
+			Help:      "Total number of requests rejected because a client exhausted a method class",
+		}, []string{"class"}),
+	}
+}
+
+var (
+	rateLimitMetricsMu         sync.Mutex
+	rateLimitMetricsRegisterer prometheus.Registerer
+)
+
+func acquireRateLimitMetrics(reg prometheus.Registerer) (*RateLimitMetrics, error) {
+	rateLimitMetricsMu.Lock()
+	defer rateLimitMetricsMu.Unlock()
+
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+
+	if rlMetrics == nil || rateLimitMetricsRegisterer != reg {
+		metrics := NewRateLimitMetrics()
+		var err error
+		if metrics.rejectedTotal, err = registerCounterVec(reg, metrics.rejectedTotal); err != nil {
+			return nil, err
+		}
+		rlMetrics = metrics
+		rateLimitMetricsRegisterer = reg
+	}
+
+	return rlMetrics, nil
+}
+
+// ResponseCacheMetrics tracks the response cache middleware
+type ResponseCacheMetrics struct {
+	requestsTotal *prometheus.CounterVec
+}
+
+// NewResponseCacheMetrics creates response cache metrics
+func NewResponseCacheMetrics() *ResponseCacheMetrics {
+	return &ResponseCacheMetrics{
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_cache",
+			Name:      "requests_total",
+			Help:      "Total number of cacheable requests, by method and result (hit or miss)",
+		}, []string{"method", "result"}),
+	}
+}
+
+var (
+	responseCacheMetricsMu         sync.Mutex
+	responseCacheMetricsRegisterer prometheus.Registerer
+)
+
+func acquireResponseCacheMetrics(reg prometheus.Registerer) (*ResponseCacheMetrics, error) {
+	responseCacheMetricsMu.Lock()
+	defer responseCacheMetricsMu.Unlock()
+
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+
+	if rcMetrics == nil || responseCacheMetricsRegisterer != reg {
+		metrics := NewResponseCacheMetrics()
+		var err error
+		if metrics.requestsTotal, err = registerCounterVec(reg, metrics.requestsTotal); err != nil {
+			return nil, err
+		}
+		rcMetrics = metrics
+		responseCacheMetricsRegisterer = reg
+	}
+
+	return rcMetrics, nil
+}
+
+// HedgeMetrics tracks the hedging middleware
+type HedgeMetrics struct {
+	requestsTotal *prometheus.CounterVec
+	delaySeconds  prometheus.Gauge
+}
+
+// NewHedgeMetrics creates hedging metrics
+func NewHedgeMetrics() *HedgeMetrics {
+	return &HedgeMetrics{
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_hedge",
+			Name:      "requests_total",
+			Help:      "Total number of requests slow enough to hedge, by outcome (primary_won, hedge_won or budget_exhausted)",
+		}, []string{"outcome"}),
+		delaySeconds: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_hedge",
+			Name:      "delay_seconds",
+			Help:      "Time a request is given before it is hedged, derived from recent latencies",
+		}),
+	}
+}
+
+var (
+	hedgeMetricsMu         sync.Mutex
+	hedgeMetricsRegisterer prometheus.Registerer
+)
+
+func acquireHedgeMetrics(reg prometheus.Registerer) (*HedgeMetrics, error) {
+	hedgeMetricsMu.Lock()
+	defer hedgeMetricsMu.Unlock()
+
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+
+	if hdMetrics == nil || hedgeMetricsRegisterer != reg {
+		metrics := NewHedgeMetrics()
+		var err error
+		if metrics.requestsTotal, err = registerCounterVec(reg, metrics.requestsTotal); err != nil {
+			return nil, err
+		}
+		if metrics.delaySeconds, err = registerGauge(reg, metrics.delaySeconds); err != nil {
+			return nil, err
+		}
+		hdMetrics = metrics
+		hedgeMetricsRegisterer = reg
+	}
+
+	return hdMetrics, nil
+}
+
+// BackfillMetrics tracks the backfill middleware
+type BackfillMetrics struct {
+	inFlight      prometheus.Gauge
+	rejectedTotal prometheus.Counter
+	queuedSeconds *prometheus.HistogramVec
+}
+
+// NewBackfillMetrics creates backfill metrics
+func NewBackfillMetrics() *BackfillMetrics {
+	return &BackfillMetrics{
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_backfill",
+			Name:      "in_flight",
+			Help:      "Number of backfill requests being proxied",
+		}),
+		rejectedTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_backfill",
+			Name:      "rejected_total",
+			Help:      "Total number of backfill requests answered with 429 because max_concurrent were in flight",
+		}),
+		queuedSeconds: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "caddy",
+			Subsystem: "blockchain_backfill",
+			Name:      "queued_seconds",
+			Help:      "Time backfill requests waited for a free slot, by outcome",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"outcome"}),
+	}
+}
+
+var (
+	backfillMetricsMu         sync.Mutex
+	backfillMetricsRegisterer prometheus.Registerer
+)
+
+func acquireBackfillMetrics(reg prometheus.Registerer) (*BackfillMetrics, error) {
+	backfillMetricsMu.Lock()
+	defer backfillMetricsMu.Unlock()
+
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+
+	if bfMetrics == nil || backfillMetricsRegisterer != reg {
+		metrics := NewBackfillMetrics()
+		var err error
+		if metrics.inFlight, err = registerGauge(reg, metrics.inFlight); err != nil {
+			return nil, err
+		}
+		if metrics.rejectedTotal, err = registerCounter(reg, metrics.rejectedTotal); err != nil {
+			return nil, err
+		}
+		if metrics.queuedSeconds, err = registerHistogramVec(reg, metrics.queuedSeconds); err != nil {
+			return nil, err
+		}
+		bfMetrics = metrics
+		backfillMetricsRegisterer = reg
+	}
+
+	return bfMetrics, nil
+}
+
 func registerCounter(reg prometheus.Registerer, counter prometheus.Counter) (prometheus.Counter, error) {
 	if err := reg.Register(counter); err != nil {
 		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
@@ -0,0 +1,77 @@
+package blockchain_health
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// endpoints returns the node once per URL it is reachable on, the primary URL
+// first. The copies share the node's name, so results and state stay keyed
+// by the primary URL.
+func (n NodeConfig) endpoints() []NodeConfig {
+	endpoints := make([]NodeConfig, 0, 1+len(n.AlternateURLs))
+	endpoints = append(endpoints, n)
+	for _, alternate := range n.AlternateURLs {
+		endpoint := n
+		endpoint.URL = alternate
+		endpoint.AlternateURLs = nil
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints
+}
+
+// checkEndpoints checks every endpoint of a node and returns the best result.
+// The node is healthy if any endpoint is, and its URL in the result is the
+// endpoint requests are routed to. Nodes without alternate URLs are checked
+// as before.
+func (h *HealthChecker) checkEndpoints(ctx context.Context, node NodeConfig) *NodeHealth {
+	if len(node.AlternateURLs) == 0 {
+		return h.checkWithRetry(ctx, node)
+	}
+
+	endpoints := node.endpoints()
+	results := make([]*NodeHealth, len(endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range endpoints {
+		wg.Add(1)
+		go func(i int, endpoint NodeConfig) {
+			defer wg.Done()
+			defer h.recoverCheckPanic(endpoint, &results[i])
+			results[i] = h.checkWithRetry(ctx, endpoint)
+		}(i, endpoint)
+	}
+	wg.Wait()
+
+	best := results[0]
+	for _, result := range results[1:] {
+		if betterEndpoint(result, best) {
+			best = result
+		}
+	}
+
+	if best.URL != node.URL {
+		h.logger.Debug("routing node through alternate endpoint",
+			zap.String("node", node.Name),
+			zap.String("endpoint", best.URL),
+			zap.Bool("healthy", best.Healthy))
+	}
+	return best
+}
+
+// betterEndpoint reports whether endpoint a should be routed to over b:
+// healthy endpoints first, then ones not rate limiting us, then the highest
+// block, then the fastest. Ties keep the earlier endpoint.
+func betterEndpoint(a, b *NodeHealth) bool {
+	if a.Healthy != b.Healthy {
+		return a.Healthy
+	}
+	if a.Throttled != b.Throttled {
+		return !a.Throttled
+	}
+	if a.BlockHeight != b.BlockHeight {
+		return a.BlockHeight > b.BlockHeight
+	}
+	return a.ResponseTime < b.ResponseTime
+}
@@ -0,0 +1,103 @@
+package blockchain_health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestBetterEndpoint(t *testing.T) {
+	healthy := &NodeHealth{Healthy: true, BlockHeight: 100}
+	tests := []struct {
+		name string
+		a, b *NodeHealth
+		want bool
+	}{
+		{"healthy beats unhealthy", healthy, &NodeHealth{BlockHeight: 200}, true},
+		{"unthrottled beats throttled", healthy, &NodeHealth{Healthy: true, Throttled: true, BlockHeight: 200}, true},
+		{"higher block wins", &NodeHealth{Healthy: true, BlockHeight: 101}, healthy, true},
+		{"faster wins at the same block", &NodeHealth{Healthy: true, BlockHeight: 100, ResponseTime: 1}, &NodeHealth{Healthy: true, BlockHeight: 100, ResponseTime: 2}, true},
+		{"ties keep the earlier endpoint", healthy, healthy, false},
+	}
+	for _, tt := range tests {
+		if got := betterEndpoint(tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestAlternateURLs_RouteThroughHealthyEndpoint(t *testing.T) {
+	down := createCosmosServer(t, 1000, true)
+	defer down.Close()
+	up := createCosmosServer(t, 1000, false)
+	defer up.Close()
+
+	nodes := []NodeConfig{
+		{Name: "migrating", URL: down.URL, AlternateURLs: []string{up.URL}, Type: NodeTypeCosmos, Weight: 1},
+	}
+	upstream := createTestUpstream(nodes, zaptest.NewLogger(t))
+
+	upstreams, err := upstream.GetUpstreams(httptest.NewRequest(http.MethodPost, "/", nil))
+	if err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+	upURL, _ := url.Parse(up.URL)
+	if len(upstreams) != 1 || upstreams[0].Dial != upURL.Host {
+		t.Errorf("expected the node to be routed through %s, got %v", upURL.Host, upstreams)
+	}
+
+	// The result is cached under the node's primary URL
+	if cached := upstream.healthChecker.cache.Get(nodes[0].key()); cached == nil || cached.URL != up.URL {
+		t.Errorf("expected the cached result to name the healthy endpoint, got %+v", cached)
+	}
+}
+
+func TestAlternateURLs_UnhealthyWhenAllEndpointsFail(t *testing.T) {
+	a := createCosmosServer(t, 1000, true)
+	defer a.Close()
+	b := createCosmosServer(t, 1000, true)
+	defer b.Close()
+
+	nodes := []NodeConfig{
+		{Name: "down", URL: a.URL, AlternateURLs: []string{b.URL}, Type: NodeTypeCosmos, Weight: 1},
+	}
+	upstream := createTestUpstream(nodes, zaptest.NewLogger(t))
+
+	results, err := upstream.healthChecker.CheckAllNodes(t.Context())
+	if err != nil {
+		t.Fatalf("CheckAllNodes failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Healthy {
+		t.Errorf("expected the node to be unhealthy, got %+v", results)
+	}
+}
+
+func TestAlternateURLs_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://10.0.0.1:26657
+			alternate_url https://rpc.example.com http://10.1.0.1:26657
+			type cosmos
+		}
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if len(b.Nodes) != 1 || len(b.Nodes[0].AlternateURLs) != 2 || b.Nodes[0].AlternateURLs[0] != "https://rpc.example.com" {
+		t.Fatalf("expected two alternate URLs, got %+v", b.Nodes)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+
+	b.Nodes[0].AlternateURLs = []string{"not a url"}
+	if err := b.validate(); err == nil {
+		t.Error("expected an alternate URL without a host to be rejected")
+	}
+}
@@ -0,0 +1,73 @@
+package blockchain_health
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+)
+
+func init() {
+	httpcaddyfile.RegisterGlobalOption("blockchain_health", parseAppCaddyfile)
+}
+
+// parseAppCaddyfile parses the blockchain_health global option. A pool name
+// may only be defined once, also across repeated blocks of the option.
+func parseAppCaddyfile(d *caddyfile.Dispenser, existing any) (any, error) {
+	app := &App{Pools: make(map[string]*BlockchainHealthUpstream)}
+	if existing != nil {
+		if err := json.Unmarshal(existing.(httpcaddyfile.App).Value, app); err != nil {
+			return nil, err
+		}
+	}
+
+	for d.Next() {
+		if d.NextArg() {
+			return nil, d.ArgErr()
+		}
+		for d.NextBlock(0) {
+			if d.Val() != "pool" {
+				return nil, d.Errf("unrecognized blockchain_health option: %s", d.Val())
+			}
+			if !d.NextArg() {
+				return nil, d.ArgErr()
+			}
+			name := d.Val()
+			if _, ok := app.Pools[name]; ok {
+				return nil, d.Errf("pool %s defined more than once", name)
+			}
+
+			// The pool block takes the same options as the upstream
+			pool := new(BlockchainHealthUpstream)
+			if err := pool.parseCaddyfile(d.NewFromNextSegment()); err != nil {
+				return nil, fmt.Errorf("pool %s: %w", name, err)
+			}
+			if pool.Pool != "" {
+				return nil, d.Errf("pool %s: a named pool cannot reference another pool", name)
+			}
+			app.Pools[name] = pool
+		}
+	}
+
+	value, err := json.Marshal(app)
+	if err != nil {
+		return nil, err
+	}
+	return httpcaddyfile.App{Name: "blockchain_health", Value: value}, nil
+}
+
+// provisionShared points an upstream at the named pool it references
+func (b *BlockchainHealthUpstream) provisionShared(ctx caddy.Context) error {
+	appModule, err := ctx.AppIfConfigured("blockchain_health")
+	if err != nil {
+		return fmt.Errorf("pool %s: no blockchain_health pools are configured: %w", b.Pool, err)
+	}
+	pool, ok := appModule.(*App).namedPool(b.Pool)
+	if !ok {
+		return fmt.Errorf("pool %s is not defined in the blockchain_health app", b.Pool)
+	}
+	b.shared = pool
+	return nil
+}
@@ -0,0 +1,233 @@
+package blockchain_health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+)
+
+func TestNamedPool_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		pool evm-main {
+			node a {
+				url http://localhost:8545
+				type evm
+			}
+			check_interval 5s
+		}
+		pool evm-archive {
+			node b {
+				url http://localhost:8546
+				type evm
+			}
+		}
+	}`)
+	value, err := parseAppCaddyfile(d, nil)
+	if err != nil {
+		t.Fatalf("Failed to parse global option: %v", err)
+	}
+	app := new(App)
+	if err := json.Unmarshal(value.(httpcaddyfile.App).Value, app); err != nil {
+		t.Fatalf("decoding app: %v", err)
+	}
+	if len(app.Pools) != 2 || app.Pools["evm-main"].HealthCheck.Interval != "5s" || app.Pools["evm-archive"].Nodes[0].Name != "b" {
+		t.Errorf("unexpected pools: %+v", app.Pools)
+	}
+
+	for name, input := range map[string]string{
+		"duplicate pool":  `blockchain_health { pool a { node a { url http://localhost:8545; type evm } } pool a { } }`,
+		"unknown option":  `blockchain_health { nodes 3 }`,
+		"nested pool ref": `blockchain_health { pool a pool=b }`,
+	} {
+		if _, err := parseAppCaddyfile(caddyfile.NewTestDispenser(input), nil); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestPoolReference_UnmarshalCaddyfile(t *testing.T) {
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`blockchain_health pool=evm-main`)); err != nil {
+		t.Fatalf("Failed to parse pool reference: %v", err)
+	}
+	if b.Pool != "evm-main" {
+		t.Errorf("expected pool evm-main, got %q", b.Pool)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid pool reference, got %v", err)
+	}
+
+	for name, input := range map[string]string{
+		"block":        "blockchain_health pool=evm-main {\n check_interval 5s\n}",
+		"bad argument": "blockchain_health evm-main",
+		"empty name":   "blockchain_health pool=",
+	} {
+		var b BlockchainHealthUpstream
+		if err := b.UnmarshalCaddyfile(caddyfile.NewTestDispenser(input)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestNamedPool_Adapt(t *testing.T) {
+	config := `{
+		blockchain_health {
+			pool evm-main {
+				node a {
+					url http://localhost:8545
+					type evm
+				}
+			}
+		}
+	}
+
+	:8080 {
+		reverse_proxy {
+			dynamic blockchain_health pool=evm-main
+		}
+	}`
+	adapted, _, err := caddyconfig.GetAdapter("caddyfile").Adapt([]byte(config), nil)
+	if err != nil {
+		t.Fatalf("adapting Caddyfile: %v", err)
+	}
+	for _, want := range []string{`"blockchain_health":{"pools":{"evm-main":`, `"pool":"evm-main","pool_state"`} {
+		if !strings.Contains(string(adapted), want) {
+			t.Errorf("expected %s in %s", want, adapted)
+		}
+	}
+}
+
+func TestNamedPool_SharedAcrossSites(t *testing.T) {
+	node := createVersionedEVMServer(t, "Geth/v1.13.14")
+	defer node.Close()
+
+	config := fmt.Sprintf(`{
+		"admin": {"disabled": true},
+		"apps": {
+			"blockchain_health": {
+				"pools": {
+					"evm-main": {
+						"nodes": [{"name": "a", "url": %q, "type": "evm", "weight": 1}],
+						"health_check": {"interval": "100ms"}
+					}
+				}
+			}
+		}
+	}`, node.URL)
+	if err := caddy.Load([]byte(config), true); err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	defer func() { _ = caddy.Stop() }()
+
+	ctx := caddy.ActiveContext()
+	appModule, err := ctx.App("blockchain_health")
+	if err != nil {
+		t.Fatalf("blockchain_health app not loaded: %v", err)
+	}
+	pool := appModule.(*App).Pools["evm-main"]
+
+	// Two sites referencing the pool share its health checks
+	var sites []*BlockchainHealthUpstream
+	for i := 0; i < 2; i++ {
+		site := &BlockchainHealthUpstream{Pool: "evm-main"}
+		if err := site.Provision(ctx); err != nil {
+			t.Fatalf("provisioning site %d: %v", i, err)
+		}
+		if site.shared != pool || site.healthChecker != nil {
+			t.Fatalf("site %d does not use the shared pool", i)
+		}
+		sites = append(sites, site)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	deadline := time.Now().Add(5 * time.Second)
+	for _, site := range sites {
+		for {
+			upstreams, err := site.GetUpstreams(r)
+			if err == nil && len(upstreams) == 1 && upstreams[0].Dial == getDynamicTestHostFromURL(node.URL) {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected the pool's node, got %v (%v)", upstreams, err)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		// Cleaning up a site leaves the pool checking
+		if err := site.Cleanup(); err != nil {
+			t.Errorf("cleanup failed: %v", err)
+		}
+	}
+	if pool.healthChecker == nil || pool.metrics == nil {
+		t.Error("expected the pool to outlive the sites referencing it")
+	}
+
+	missing := &BlockchainHealthUpstream{Pool: "cosmos-main"}
+	if err := missing.Provision(ctx); err == nil {
+		t.Error("expected an undefined pool to be rejected")
+	}
+}
+
+func TestNamedPool_Networks(t *testing.T) {
+	mainnet := createVersionedEVMServer(t, "Geth/v1.13.14")
+	defer mainnet.Close()
+	testnet := createVersionedEVMServer(t, "Geth/v1.13.14")
+	defer testnet.Close()
+
+	config := fmt.Sprintf(`{
+		"admin": {"disabled": true},
+		"apps": {
+			"blockchain_health": {
+				"pools": {
+					"eth-mainnet": {
+						"nodes": [{"name": "main", "url": %q, "type": "evm", "weight": 1}],
+						"chain": {"chain_type": "ethereum", "network": "mainnet"}
+					},
+					"eth-sepolia": {
+						"nodes": [{"name": "sepolia", "url": %q, "type": "evm", "weight": 1}],
+						"chain": {"chain_type": "ethereum", "network": "sepolia"},
+						"block_validation": {"height_threshold": 50}
+					}
+				}
+			}
+		}
+	}`, mainnet.URL, testnet.URL)
+	if err := caddy.Load([]byte(config), true); err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	defer func() { _ = caddy.Stop() }()
+
+	appModule, err := caddy.ActiveContext().App("blockchain_health")
+	if err != nil {
+		t.Fatalf("blockchain_health app not loaded: %v", err)
+	}
+	pools := appModule.(*App).Pools
+
+	// Networks of one chain keep their own pool state and thresholds
+	main, sepolia := pools["eth-mainnet"], pools["eth-sepolia"]
+	if main.chainName() != "ethereum-mainnet" || sepolia.chainName() != "ethereum-sepolia" {
+		t.Errorf("expected per-network chain names, got %q and %q", main.chainName(), sepolia.chainName())
+	}
+	if main.healthChecker.state == sepolia.healthChecker.state {
+		t.Error("expected the networks not to share a pool state")
+	}
+	if main.config.BlockValidation.HeightThreshold == sepolia.config.BlockValidation.HeightThreshold {
+		t.Error("expected each network to keep its own height threshold")
+	}
+
+	invalid := &BlockchainHealthUpstream{
+		Nodes: []NodeConfig{{Name: "a", URL: "http://localhost:8545", Type: NodeTypeEVM, Weight: 1}},
+		Chain: ChainConfig{Network: "Main Net"},
+	}
+	if err := invalid.validate(); err == nil {
+		t.Error("expected a network that is not an identifier to be rejected")
+	}
+}
@@ -0,0 +1,56 @@
+package blockchain_health
+
+import (
+	"time"
+)
+
+// ChainHeight is the height of a chain as the pool sees it at the last check.
+// The network height is the canonical height of the chain: the highest of
+// the pool leader and the accepted external references.
+type ChainHeight struct {
+	LeaderHeight    uint64    `json:"leader_height"`
+	ReferenceHeight uint64    `json:"reference_height,omitempty"`
+	NetworkHeight   uint64    `json:"network_height"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// recordChainHeight records the pool leader and highest accepted external
+// reference height of a chain, and exports them with the network height.
+// A chain without a leader height, such as one whose nodes are all
+// throttled, keeps its last heights.
+func (h *HealthChecker) recordChainHeight(chain string, leader, reference uint64) {
+	if leader == 0 {
+		return
+	}
+
+	height := ChainHeight{
+		LeaderHeight:    leader,
+		ReferenceHeight: reference,
+		NetworkHeight:   max(leader, reference),
+		UpdatedAt:       time.Now(),
+	}
+	h.chainHeightsMutex.Lock()
+	h.chainHeights[chain] = height
+	h.chainHeightsMutex.Unlock()
+
+	if h.metrics != nil {
+		h.metrics.leaderHeight.WithLabelValues(chain).Set(float64(height.LeaderHeight))
+		h.metrics.networkHeight.WithLabelValues(chain).Set(float64(height.NetworkHeight))
+	}
+}
+
+// chainHeightsSnapshot returns a copy of the heights of every chain, or nil
+// before any chain has a height
+func (h *HealthChecker) chainHeightsSnapshot() map[string]ChainHeight {
+	h.chainHeightsMutex.Lock()
+	defer h.chainHeightsMutex.Unlock()
+
+	if len(h.chainHeights) == 0 {
+		return nil
+	}
+	heights := make(map[string]ChainHeight, len(h.chainHeights))
+	for chain, height := range h.chainHeights {
+		heights[chain] = height
+	}
+	return heights
+}
@@ -0,0 +1,59 @@
+package blockchain_health
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestChainHeights(t *testing.T) {
+	leader := createEVMServer(t, 1000, false)
+	defer leader.Close()
+	follower := createEVMServer(t, 995, false)
+	defer follower.Close()
+	reference := createEVMServer(t, 1002, false)
+	defer reference.Close()
+	lone := createCosmosServer(t, 500, false)
+	defer lone.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "leader", URL: leader.URL, Type: NodeTypeEVM, ChainType: "ethereum", Weight: 100},
+		{Name: "follower", URL: follower.URL, Type: NodeTypeEVM, ChainType: "ethereum", Weight: 100},
+		{Name: "lone", URL: lone.URL, Type: NodeTypeCosmos, ChainType: "akash", Weight: 100},
+	}, zaptest.NewLogger(t))
+	upstream.config.ExternalReferences = []ExternalReference{{Name: "ref", URL: reference.URL, Type: NodeTypeEVM, Enabled: true}}
+	h := upstream.healthChecker
+	defer h.Stop()
+
+	if heights := h.chainHeightsSnapshot(); heights != nil {
+		t.Fatalf("expected no heights before the first check, got %v", heights)
+	}
+	if _, err := h.CheckAllNodes(context.Background()); err != nil {
+		t.Fatalf("CheckAllNodes failed: %v", err)
+	}
+
+	heights := h.chainHeightsSnapshot()
+	if got := heights["ethereum"]; got.LeaderHeight != 1000 || got.ReferenceHeight != 1002 || got.NetworkHeight != 1002 {
+		t.Errorf("expected the reference to set the network height, got %+v", got)
+	}
+	if got := heights["akash"]; got.LeaderHeight != 500 || got.ReferenceHeight != 0 || got.NetworkHeight != 500 {
+		t.Errorf("expected a lone node to set the network height, got %+v", got)
+	}
+
+	if value := testutil.ToFloat64(h.metrics.leaderHeight.WithLabelValues("ethereum")); value != 1000 {
+		t.Errorf("expected leader height 1000, got %v", value)
+	}
+	if value := testutil.ToFloat64(h.metrics.networkHeight.WithLabelValues("ethereum")); value != 1002 {
+		t.Errorf("expected network height 1002, got %v", value)
+	}
+	if value := testutil.ToFloat64(h.metrics.referenceHeight.WithLabelValues("ref")); value != 1002 {
+		t.Errorf("expected reference height 1002, got %v", value)
+	}
+
+	response := upstream.buildHealthResponse(context.Background(), false)
+	if got := response.Chains["ethereum"]; got.NetworkHeight != 1002 {
+		t.Errorf("expected the health endpoint to report the network height, got %+v", response.Chains)
+	}
+}
@@ -0,0 +1,87 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/caddyserver/caddy/v2"
+)
+
+// adminNodesPath is the admin API path of the effective node configuration
+const adminNodesPath = "/blockchain_health/nodes/"
+
+// poolNodes is the effective node configuration of a pool: its nodes after
+// environment lists, presets and discovery were resolved. Pools and nodes
+// are sorted by name, so configuration management can diff the output
+// against the intended topology.
+type poolNodes struct {
+	Pool               string              `json:"pool"`
+	Named              bool                `json:"named"`
+	Chain              ChainConfig         `json:"chain"`
+	Nodes              []NodeConfig        `json:"nodes"`
+	ExternalReferences []ExternalReference `json:"external_references"`
+}
+
+// resolvedNodes returns the effective node configuration of a pool
+func resolvedNodes(name string, named bool, pool *BlockchainHealthUpstream) poolNodes {
+	export := poolNodes{
+		Pool:               name,
+		Named:              named,
+		Nodes:              []NodeConfig{},
+		ExternalReferences: []ExternalReference{},
+	}
+	if pool.config == nil {
+		return export
+	}
+	export.Chain = pool.config.Chain
+	export.Nodes = append(export.Nodes, pool.config.nodeList()...)
+	slices.SortStableFunc(export.Nodes, func(a, b NodeConfig) int { return strings.Compare(a.Name, b.Name) })
+	export.ExternalReferences = append(export.ExternalReferences, pool.config.ExternalReferences...)
+	slices.SortStableFunc(export.ExternalReferences, func(a, b ExternalReference) int { return strings.Compare(a.Name, b.Name) })
+	return export
+}
+
+// nodeExports returns the effective node configuration of the pools of the
+// app. Inline pools are named after their pool state name.
+func (a *App) nodeExports() []poolNodes {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var pools []poolNodes
+	for _, name := range a.poolNames() {
+		pools = append(pools, resolvedNodes(name, true, a.Pools[name]))
+	}
+	for _, pool := range a.inline {
+		pools = append(pools, resolvedNodes(pool.poolStateName(), false, pool))
+	}
+	return pools
+}
+
+// handleNodes serves the effective node configuration of every pool, or of
+// the pool named in the path
+func (a AdminAPI) handleNodes(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return caddy.APIError{HTTPStatus: http.StatusMethodNotAllowed, Err: fmt.Errorf("method %s not allowed", r.Method)}
+	}
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, adminNodesPath), "/")
+
+	pools := []poolNodes{}
+	activeApps.Range(func(key, _ any) bool {
+		pools = append(pools, key.(*App).nodeExports()...)
+		return true
+	})
+	sort.SliceStable(pools, func(i, j int) bool { return pools[i].Pool < pools[j].Pool })
+
+	if name == "" {
+		return writeAdminJSON(w, pools)
+	}
+	for _, pool := range pools {
+		if pool.Pool == name {
+			return writeAdminJSON(w, pool)
+		}
+	}
+	return caddy.APIError{HTTPStatus: http.StatusNotFound, Err: fmt.Errorf("unknown pool %q", name)}
+}
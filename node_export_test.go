@@ -0,0 +1,88 @@
+package blockchain_health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+)
+
+func TestAdminAPI_Nodes(t *testing.T) {
+	node := createVersionedEVMServer(t, "Geth/v1.13.14")
+	defer node.Close()
+
+	t.Setenv("EXPORT_EVM_SERVERS", fmt.Sprintf("%s;weight=50 %s", node.URL, node.URL+"/other"))
+	config := fmt.Sprintf(`{
+		"admin": {"disabled": true},
+		"apps": {
+			"blockchain_health": {
+				"pools": {
+					"evm-env": {
+						"environment": {"evm_servers": "{env.EXPORT_EVM_SERVERS}"},
+						"chain": {"chain_type": "ethereum", "node_type": "evm"}
+					},
+					"evm-static": {
+						"nodes": [
+							{"name": "z", "url": %q, "type": "evm"},
+							{"name": "a", "url": %q, "type": "evm", "headers": {"X-Org-Token": "{env.ORG_TOKEN}"}}
+						]
+					}
+				}
+			}
+		}
+	}`, node.URL, node.URL+"/a")
+	if err := caddy.Load([]byte(config), true); err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	defer func() { _ = caddy.Stop() }()
+
+	w := httptest.NewRecorder()
+	if err := (AdminAPI{}).handleNodes(w, httptest.NewRequest(http.MethodGet, adminNodesPath, nil)); err != nil {
+		t.Fatalf("listing nodes: %v", err)
+	}
+	var pools []poolNodes
+	if err := json.NewDecoder(w.Body).Decode(&pools); err != nil {
+		t.Fatalf("decoding nodes: %v", err)
+	}
+	if len(pools) != 2 || pools[0].Pool != "evm-env" || pools[1].Pool != "evm-static" {
+		t.Fatalf("expected both pools sorted by name, got %+v", pools)
+	}
+
+	// Environment lists are resolved into nodes
+	env := pools[0]
+	if len(env.Nodes) != 2 || env.Chain.ChainType != "ethereum" {
+		t.Fatalf("expected two nodes from the environment list, got %+v", env)
+	}
+	weights := []int{env.Nodes[0].Weight, env.Nodes[1].Weight}
+	sort.Ints(weights)
+	if weights[0] != 50 || weights[1] != defaultNodeWeight {
+		t.Errorf("expected the annotated and the default weight, got %v", weights)
+	}
+
+	// Nodes are sorted by name and secrets in headers stay placeholders
+	static := pools[1]
+	if len(static.Nodes) != 2 || static.Nodes[0].Name != "a" || static.Nodes[1].Name != "z" {
+		t.Errorf("expected the nodes sorted by name, got %+v", static.Nodes)
+	}
+	if static.Nodes[0].Headers["X-Org-Token"] != "{env.ORG_TOKEN}" {
+		t.Errorf("expected the header placeholder, got %v", static.Nodes[0].Headers)
+	}
+
+	w = httptest.NewRecorder()
+	if err := (AdminAPI{}).handleNodes(w, httptest.NewRequest(http.MethodGet, adminNodesPath+"evm-static", nil)); err != nil {
+		t.Fatalf("showing a pool: %v", err)
+	}
+	var one poolNodes
+	if err := json.NewDecoder(w.Body).Decode(&one); err != nil || one.Pool != "evm-static" {
+		t.Errorf("expected the evm-static pool, got %+v (%v)", one, err)
+	}
+
+	err := (AdminAPI{}).handleNodes(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, adminNodesPath+"missing", nil))
+	if apiErr, ok := err.(caddy.APIError); !ok || apiErr.HTTPStatus != http.StatusNotFound {
+		t.Errorf("expected a 404 for an unknown pool, got %v", err)
+	}
+}
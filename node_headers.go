@@ -0,0 +1,95 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+)
+
+// nodeHeadersVar is the request variable holding the static headers of the
+// selected upstreams that have any, by dial address
+const nodeHeadersVar = "blockchain_health.node_headers"
+
+// nodeHeadersReplacerVar is set once the node header placeholders are
+// registered with the request's replacer, so retries don't register them again
+const nodeHeadersReplacerVar = "blockchain_health.node_headers_replacer"
+
+// nodeHeaderPlaceholderPrefix prefixes the placeholders resolving to a header
+// of the upstream reverse_proxy picked, as in
+// {blockchain_health.upstream.header.X-Org-Token}. reverse_proxy resolves
+// header_up after picking the upstream, so each node only gets its own.
+const nodeHeaderPlaceholderPrefix = "blockchain_health.upstream.header."
+
+// validateNodeHeaders checks that a node's header names are valid field names
+func validateNodeHeaders(node NodeConfig) error {
+	for name := range node.Headers {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return fmt.Errorf("node %s: invalid header name %q", node.Name, name)
+		}
+	}
+	return nil
+}
+
+// upstreamHeaders returns the static headers of the selected upstreams whose
+// nodes define any, by dial address, or nil if none do
+func upstreamHeaders(nodes []NodeConfig, upstreams []*reverseproxy.Upstream, infos []selectionInfo) map[string]map[string]string {
+	var headers map[string]map[string]string
+	for i, info := range infos {
+		if i >= len(upstreams) {
+			break
+		}
+		node := findNode(nodes, info.name)
+		if node == nil || len(node.Headers) == 0 {
+			continue
+		}
+		if headers == nil {
+			headers = make(map[string]map[string]string)
+		}
+		headers[upstreams[i].Dial] = node.Headers
+	}
+	return headers
+}
+
+// setUpstreamHeaders conveys the headers of the selected upstreams to
+// reverse_proxy, registering the node header placeholders on first use
+func setUpstreamHeaders(r *http.Request, headers map[string]map[string]string) {
+	if headers == nil {
+		caddyhttp.SetVar(r.Context(), nodeHeadersVar, nil)
+		return
+	}
+	caddyhttp.SetVar(r.Context(), nodeHeadersVar, headers)
+
+	if registered, _ := caddyhttp.GetVar(r.Context(), nodeHeadersReplacerVar).(bool); registered {
+		return
+	}
+	repl, ok := r.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer)
+	if !ok {
+		return
+	}
+	repl.Map(func(key string) (any, bool) {
+		name, ok := strings.CutPrefix(key, nodeHeaderPlaceholderPrefix)
+		if !ok {
+			return nil, false
+		}
+		return upstreamHeader(r, repl, name), true
+	})
+	caddyhttp.SetVar(r.Context(), nodeHeadersReplacerVar, true)
+}
+
+// upstreamHeader returns the named header of the upstream reverse_proxy
+// picked for the request, or "" if its node doesn't define it. Values may
+// use placeholders such as {env.ORG_TOKEN}.
+func upstreamHeader(r *http.Request, repl *caddy.Replacer, name string) string {
+	headers, _ := caddyhttp.GetVar(r.Context(), nodeHeadersVar).(map[string]map[string]string)
+	dial, _ := repl.GetString("http.reverse_proxy.upstream.hostport")
+	for field, value := range headers[dial] {
+		if strings.EqualFold(field, name) {
+			return repl.ReplaceKnown(value, "")
+		}
+	}
+	return ""
+}
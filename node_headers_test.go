@@ -0,0 +1,81 @@
+package blockchain_health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestNodeHeaders_OnlyForPickedUpstream(t *testing.T) {
+	t.Setenv("NODE_HEADERS_TEST_TOKEN", "org-123")
+	tokenServer := createCosmosServer(t, 1000, false)
+	defer tokenServer.Close()
+	plainServer := createCosmosServer(t, 1000, false)
+	defer plainServer.Close()
+
+	nodes := []NodeConfig{
+		{Name: "token", URL: tokenServer.URL, Type: NodeTypeCosmos, Weight: 1, Headers: map[string]string{"X-Org-Token": "{env.NODE_HEADERS_TEST_TOKEN}"}},
+		{Name: "plain", URL: plainServer.URL, Type: NodeTypeCosmos, Weight: 1},
+	}
+	upstream := createTestUpstream(nodes, zaptest.NewLogger(t))
+
+	repl := caddy.NewReplacer()
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	ctx := context.WithValue(req.Context(), caddy.ReplacerCtxKey, repl)
+	ctx = context.WithValue(ctx, caddyhttp.VarsCtxKey, map[string]any{})
+	req = req.WithContext(ctx)
+
+	// Retries select upstreams again
+	for i := 0; i < 2; i++ {
+		if _, err := upstream.GetUpstreams(req); err != nil {
+			t.Fatalf("GetUpstreams failed: %v", err)
+		}
+	}
+
+	tokenURL, _ := url.Parse(tokenServer.URL)
+	plainURL, _ := url.Parse(plainServer.URL)
+
+	repl.Set("http.reverse_proxy.upstream.hostport", tokenURL.Host)
+	if got := repl.ReplaceKnown("{blockchain_health.upstream.header.x-org-token}", ""); got != "org-123" {
+		t.Errorf("expected the token node's header, got %q", got)
+	}
+	repl.Set("http.reverse_proxy.upstream.hostport", plainURL.Host)
+	if got := repl.ReplaceKnown("{blockchain_health.upstream.header.X-Org-Token}", ""); got != "" {
+		t.Errorf("expected no header for the plain node, got %q", got)
+	}
+}
+
+func TestNodeHeaders_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:26657
+			type cosmos
+			header X-Org-Token {env.ORG_TOKEN}
+			header Host rpc.internal
+		}
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	headers := b.Nodes[0].Headers
+	if headers["X-Org-Token"] != "{env.ORG_TOKEN}" || headers["Host"] != "rpc.internal" {
+		t.Errorf("unexpected headers %v", headers)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+
+	b.Nodes[0].Headers = map[string]string{"Bad Name": "x"}
+	if err := b.validate(); err == nil {
+		t.Error("expected an invalid header name to be rejected")
+	}
+}
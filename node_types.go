@@ -0,0 +1,202 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// ServiceType is the endpoint a node serves, kept in its service_type
+// metadata. HTTP requests are routed to rpc, api and generic nodes, WebSocket
+// upgrades to websocket nodes and GraphQL requests to graphql nodes.
+type ServiceType string
+
+const (
+	ServiceTypeRPC       ServiceType = "rpc"
+	ServiceTypeAPI       ServiceType = "api"
+	ServiceTypeWebSocket ServiceType = "websocket"
+	ServiceTypeGeneric   ServiceType = "generic"
+	ServiceTypeGraphQL   ServiceType = "graphql"
+)
+
+// valid reports whether t is a known node type
+func (t NodeType) valid() bool {
+	switch t {
+	case NodeTypeCosmos, NodeTypeEVM, NodeTypeBeacon:
+		return true
+	}
+	return false
+}
+
+// valid reports whether t is a known service type
+func (t ServiceType) valid() bool {
+	switch t {
+	case ServiceTypeRPC, ServiceTypeAPI, ServiceTypeWebSocket, ServiceTypeGeneric, ServiceTypeGraphQL:
+		return true
+	}
+	return false
+}
+
+// serviceType returns the service type of the node, or "" if it has none
+func (n NodeConfig) serviceType() ServiceType {
+	return ServiceType(n.Metadata["service_type"])
+}
+
+// Deprecated spellings of node and service types, still accepted with a
+// warning so existing configs keep working after an upgrade
+var (
+	nodeTypeAliases = map[string]NodeType{
+		"tendermint": NodeTypeCosmos,
+		"cometbft":   NodeTypeCosmos,
+		"eth":        NodeTypeEVM,
+		"ethereum":   NodeTypeEVM,
+		"consensus":  NodeTypeBeacon,
+		"eth2":       NodeTypeBeacon,
+	}
+	serviceTypeAliases = map[string]ServiceType{
+		"jsonrpc":  ServiceTypeRPC,
+		"json-rpc": ServiceTypeRPC,
+		"rest":     ServiceTypeAPI,
+		"lcd":      ServiceTypeAPI,
+		"ws":       ServiceTypeWebSocket,
+		"wss":      ServiceTypeWebSocket,
+	}
+)
+
+// chainTypePattern matches chain type identifiers such as ethereum,
+// cosmos-hub or althea_evm
+var chainTypePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// parseNodeType returns the node type a value names, accepting other cases
+// and deprecated spellings, and whether the value is deprecated
+func parseNodeType(value string) (t NodeType, deprecated, ok bool) {
+	canonical := strings.ToLower(strings.TrimSpace(value))
+	if t = NodeType(canonical); t.valid() {
+		return t, canonical != value, true
+	}
+	if t, ok = nodeTypeAliases[canonical]; ok {
+		return t, true, true
+	}
+	return "", false, false
+}
+
+// parseServiceType returns the service type a value names, accepting other
+// cases and deprecated spellings, and whether the value is deprecated
+func parseServiceType(value string) (t ServiceType, deprecated, ok bool) {
+	canonical := strings.ToLower(strings.TrimSpace(value))
+	if t = ServiceType(canonical); t.valid() {
+		return t, canonical != value, true
+	}
+	if t, ok = serviceTypeAliases[canonical]; ok {
+		return t, true, true
+	}
+	return "", false, false
+}
+
+// parseChainType returns a chain type identifier in lowercase, and whether
+// the value had to be changed. Chains are open-ended, but a chain type must
+// be an identifier so nodes of one chain are grouped together.
+func parseChainType(value string) (chain string, deprecated, ok bool) {
+	chain = strings.ToLower(strings.TrimSpace(value))
+	if !chainTypePattern.MatchString(chain) {
+		return "", false, false
+	}
+	return chain, chain != value, true
+}
+
+// validateNodeTypes checks the type, chain type and service type of a node,
+// accepting deprecated spellings
+func validateNodeTypes(node NodeConfig) error {
+	if _, _, ok := parseNodeType(string(node.Type)); !ok {
+		return fmt.Errorf("node %s: invalid type %s (must be 'cosmos', 'evm', or 'beacon')", node.Name, node.Type)
+	}
+	for _, chain := range []string{node.ChainType, node.Metadata["chain_type"]} {
+		if _, _, ok := parseChainType(chain); chain != "" && !ok {
+			return fmt.Errorf("node %s: invalid chain_type %q (must be an identifier such as 'ethereum' or 'cosmos-hub')", node.Name, chain)
+		}
+	}
+	if serviceType := node.Metadata["service_type"]; serviceType != "" {
+		if _, _, ok := parseServiceType(serviceType); !ok {
+			return fmt.Errorf("node %s: invalid service_type %q (must be 'rpc', 'api', 'websocket', 'generic', or 'graphql')", node.Name, serviceType)
+		}
+	}
+	return nil
+}
+
+// migrateNodeTypes rewrites the types of the nodes to their canonical
+// values, warning about each deprecated spelling, so filters comparing them
+// match. Nodes with invalid types are an error.
+func migrateNodeTypes(nodes []NodeConfig, logger *zap.Logger) error {
+	for i := range nodes {
+		node := &nodes[i]
+		if err := validateNodeTypes(*node); err != nil {
+			return err
+		}
+
+		warn := func(field, from, to string) {
+			logger.Warn("deprecated node setting, use the canonical value",
+				zap.String("node", node.Name),
+				zap.String("setting", field),
+				zap.String("value", from),
+				zap.String("canonical", to))
+		}
+		if t, deprecated, _ := parseNodeType(string(node.Type)); deprecated {
+			warn("type", string(node.Type), string(t))
+			node.Type = t
+		}
+		if chain, deprecated, _ := parseChainType(node.ChainType); node.ChainType != "" && deprecated {
+			warn("chain_type", node.ChainType, chain)
+			node.ChainType = chain
+		}
+
+		// Metadata maps may be shared with the parsed config, so the
+		// canonical values go into a copy
+		copied := false
+		replace := func(key, value string) {
+			if !copied {
+				metadata := make(map[string]string, len(node.Metadata))
+				for k, v := range node.Metadata {
+					metadata[k] = v
+				}
+				node.Metadata = metadata
+				copied = true
+			}
+			node.Metadata[key] = value
+		}
+		if chain, deprecated, _ := parseChainType(node.Metadata["chain_type"]); node.Metadata["chain_type"] != "" && deprecated {
+			warn("metadata chain_type", node.Metadata["chain_type"], chain)
+			replace("chain_type", chain)
+		}
+		if t, deprecated, _ := parseServiceType(node.Metadata["service_type"]); node.Metadata["service_type"] != "" && deprecated {
+			warn("service_type", node.Metadata["service_type"], string(t))
+			replace("service_type", string(t))
+		}
+	}
+	return nil
+}
+
+// migrateChainTypes rewrites the pool's node type, chain type and service
+// type to their canonical values, warning about deprecated spellings. Nodes
+// generated from environment lists take their types from these.
+func (b *BlockchainHealthUpstream) migrateChainTypes() {
+	warn := func(field, from, to string) {
+		b.logger.Warn("deprecated chain setting, use the canonical value",
+			zap.String("setting", field),
+			zap.String("value", from),
+			zap.String("canonical", to))
+	}
+	if t, deprecated, ok := parseNodeType(b.Chain.NodeType); ok && deprecated {
+		warn("node_type", b.Chain.NodeType, string(t))
+		b.Chain.NodeType = string(t)
+	}
+	if chain, deprecated, ok := parseChainType(b.Chain.ChainType); ok && deprecated {
+		warn("chain_type", b.Chain.ChainType, chain)
+		b.Chain.ChainType = chain
+	}
+	if t, deprecated, ok := parseServiceType(string(b.Chain.ServiceType)); ok && deprecated {
+		warn("service_type", string(b.Chain.ServiceType), string(t))
+		b.Chain.ServiceType = t
+	}
+}
@@ -0,0 +1,107 @@
+package blockchain_health
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestParseNodeType(t *testing.T) {
+	tests := []struct {
+		value      string
+		want       NodeType
+		deprecated bool
+		ok         bool
+	}{
+		{"evm", NodeTypeEVM, false, true},
+		{"EVM", NodeTypeEVM, true, true},
+		{"tendermint", NodeTypeCosmos, true, true},
+		{"eth2", NodeTypeBeacon, true, true},
+		{"solana", "", false, false},
+		{"", "", false, false},
+	}
+	for _, tt := range tests {
+		got, deprecated, ok := parseNodeType(tt.value)
+		if got != tt.want || deprecated != tt.deprecated || ok != tt.ok {
+			t.Errorf("parseNodeType(%q) = %q, %v, %v, want %q, %v, %v", tt.value, got, deprecated, ok, tt.want, tt.deprecated, tt.ok)
+		}
+	}
+}
+
+func TestParseServiceType(t *testing.T) {
+	tests := []struct {
+		value      string
+		want       ServiceType
+		deprecated bool
+		ok         bool
+	}{
+		{"websocket", ServiceTypeWebSocket, false, true},
+		{"ws", ServiceTypeWebSocket, true, true},
+		{"REST", ServiceTypeAPI, true, true},
+		{"grpc", "", false, false},
+	}
+	for _, tt := range tests {
+		got, deprecated, ok := parseServiceType(tt.value)
+		if got != tt.want || deprecated != tt.deprecated || ok != tt.ok {
+			t.Errorf("parseServiceType(%q) = %q, %v, %v, want %q, %v, %v", tt.value, got, deprecated, ok, tt.want, tt.deprecated, tt.ok)
+		}
+	}
+}
+
+func TestMigrateNodeTypes(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	metadata := map[string]string{"service_type": "ws", "chain_type": "Ethereum"}
+	nodes := []NodeConfig{
+		{Name: "a", URL: "ws://localhost:8546", Type: "eth", ChainType: "Ethereum", Metadata: metadata},
+		{Name: "b", URL: "http://localhost:26657", Type: NodeTypeCosmos},
+	}
+
+	if err := migrateNodeTypes(nodes, zap.New(core)); err != nil {
+		t.Fatalf("migrateNodeTypes failed: %v", err)
+	}
+	a := nodes[0]
+	if a.Type != NodeTypeEVM || a.ChainType != "ethereum" || a.serviceType() != ServiceTypeWebSocket || a.Metadata["chain_type"] != "ethereum" {
+		t.Errorf("expected canonical types, got %+v", a)
+	}
+	if metadata["service_type"] != "ws" {
+		t.Error("expected the original metadata to be left unchanged")
+	}
+	if got := logs.FilterMessage("deprecated node setting, use the canonical value").Len(); got != 4 {
+		t.Errorf("expected 4 deprecation warnings, got %d", got)
+	}
+
+	// Invalid values are rejected rather than creating nodes no filter matches
+	for _, node := range []NodeConfig{
+		{Name: "c", URL: "http://localhost:8545", Type: "solana"},
+		{Name: "d", URL: "http://localhost:8545", Type: NodeTypeEVM, ChainType: "my chain"},
+		{Name: "e", URL: "http://localhost:8545", Type: NodeTypeEVM, Metadata: map[string]string{"service_type": "grpc"}},
+	} {
+		if err := migrateNodeTypes([]NodeConfig{node}, zap.NewNop()); err == nil {
+			t.Errorf("expected node %s to be rejected", node.Name)
+		}
+	}
+}
+
+func TestValidate_NodeTypes(t *testing.T) {
+	b := &BlockchainHealthUpstream{
+		Nodes: []NodeConfig{{Name: "a", URL: "http://localhost:8545", Type: "ethereum", Weight: 1}},
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a deprecated node type to be accepted, got %v", err)
+	}
+
+	b.Chain.ServiceType = "generic"
+	if err := b.validate(); err == nil {
+		t.Error("expected the generic service_type to be rejected for a chain")
+	}
+	b.Chain.ServiceType = "rest"
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a deprecated service_type to be accepted, got %v", err)
+	}
+
+	b.Chain.ChainType = "Cosmos Hub"
+	if err := b.validate(); err == nil {
+		t.Error("expected a chain_type with spaces to be rejected")
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !race
+
+package blockchain_health
+
+// raceEnabled is set when tests run with the race detector, which makes
+// sync.Pool drop items at random
+const raceEnabled = false
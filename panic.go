@@ -0,0 +1,75 @@
+package blockchain_health
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+	"go.uber.org/zap"
+)
+
+// Sources of recovered panics, used as metric labels
+const (
+	panicSourceNodeCheck    = "node_check"
+	panicSourceGetUpstreams = "get_upstreams"
+)
+
+// errSelectionPanicked is returned by GetUpstreams when selecting upstreams
+// panicked. It is answered like a failed health check.
+var errSelectionPanicked = errors.New("upstream selection panicked")
+
+// recoverCheckPanic recovers a panic in a check of the node, e.g. a handler
+// choking on a malformed response, so it doesn't crash Caddy. Deferred by
+// the goroutines running checks; when result is set, the node's state is
+// unknown and it is reported unhealthy with the panic as its error. The
+// result is neither cached nor counted by the circuit breaker, so the next
+// check probes the node again.
+func (h *HealthChecker) recoverCheckPanic(node NodeConfig, result **NodeHealth) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	h.logger.Error("recovered panic in node health check",
+		zap.String("node", node.Name),
+		zap.String("url", node.URL),
+		zap.Any("panic", r),
+		zap.Stack("stack"))
+	if h.metrics != nil {
+		h.metrics.panicsRecovered.WithLabelValues(panicSourceNodeCheck).Inc()
+		h.metrics.errorCount.WithLabelValues(node.Name, "panic").Inc()
+	}
+	if result != nil {
+		*result = &NodeHealth{
+			Name:      node.Name,
+			URL:       node.URL,
+			Healthy:   false,
+			LastCheck: time.Now(),
+			LastError: fmt.Sprintf("health check panicked: %v", r),
+		}
+		h.recordErrorSample(node.Name, (*result).LastError)
+	}
+}
+
+// recoveredSelect selects upstreams, turning a panic into an error so one
+// request fails instead of the whole Caddy process
+func (b *BlockchainHealthUpstream) recoveredSelect(r *http.Request, source *BlockchainHealthUpstream) (upstreams []*reverseproxy.Upstream, err error) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		if b.logger != nil {
+			b.logger.Error("recovered panic in upstream selection",
+				zap.String("uri", r.RequestURI),
+				zap.Any("panic", recovered),
+				zap.Stack("stack"))
+		}
+		if b.metrics != nil {
+			b.metrics.panicsRecovered.WithLabelValues(panicSourceGetUpstreams).Inc()
+		}
+		upstreams, err = nil, fmt.Errorf("%w: %v", errSelectionPanicked, recovered)
+	}()
+	return b.selectUpstreams(r, source)
+}
@@ -0,0 +1,84 @@
+package blockchain_health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap/zaptest"
+)
+
+// panickingHandler panics like a handler choking on a malformed response
+type panickingHandler struct{}
+
+func (panickingHandler) CheckHealth(ctx context.Context, node NodeConfig) (*NodeHealth, error) {
+	var result map[string]any
+	_ = result["result"].(string)
+	return nil, nil
+}
+
+func (panickingHandler) GetBlockHeight(ctx context.Context, url string) (uint64, error) {
+	panic("malformed response")
+}
+
+func TestPanicRecovery_NodeCheck(t *testing.T) {
+	server := createCosmosServer(t, 1000, false)
+	defer server.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "panicking", URL: server.URL, Type: NodeTypeCosmos, Weight: 1},
+	}, zaptest.NewLogger(t))
+	h := upstream.healthChecker
+	defer h.Stop()
+	h.cosmosHandler = panickingHandler{}
+	panics := h.metrics.panicsRecovered.WithLabelValues(panicSourceNodeCheck)
+	before := testutil.ToFloat64(panics)
+
+	results, err := h.CheckAllNodes(context.Background())
+	if err != nil {
+		t.Fatalf("CheckAllNodes failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Healthy || !strings.Contains(results[0].LastError, "health check panicked") {
+		t.Fatalf("expected the node to be unknown after a panic, got %+v", results[0])
+	}
+	if got := testutil.ToFloat64(panics) - before; got != 1 {
+		t.Errorf("expected 1 recovered panic, got %v", got)
+	}
+
+	// The result is not cached, so the next check probes the node again
+	if cached := h.cache.Get(upstream.config.Nodes[0].key()); cached != nil {
+		t.Errorf("expected no cached result, got %+v", cached)
+	}
+	if !h.getCircuitBreaker("panicking").CanExecute() {
+		t.Error("expected the circuit breaker to ignore the panic")
+	}
+}
+
+func TestPanicRecovery_GetUpstreams(t *testing.T) {
+	server := createCosmosServer(t, 1000, false)
+	defer server.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "node", URL: server.URL, Type: NodeTypeCosmos, Weight: 1},
+	}, zaptest.NewLogger(t))
+	defer upstream.healthChecker.Stop()
+
+	upstream.cache = nil
+	panics := upstream.metrics.panicsRecovered.WithLabelValues(panicSourceGetUpstreams)
+	before := testutil.ToFloat64(panics)
+
+	_, err := upstream.GetUpstreams(httptest.NewRequest(http.MethodGet, "/status", nil))
+	if !errors.Is(err, errSelectionPanicked) {
+		t.Fatalf("expected the panic to be returned as an error, got %v", err)
+	}
+	if status := upstreamErrorStatus(err); status != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", status)
+	}
+	if got := testutil.ToFloat64(panics) - before; got != 1 {
+		t.Errorf("expected 1 recovered panic, got %v", got)
+	}
+}
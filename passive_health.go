@@ -0,0 +1,229 @@
+package blockchain_health
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// Passive health defaults, matching reverse_proxy's passive health checks
+const (
+	defaultPassiveMaxFails     = 1
+	defaultPassiveFailDuration = 30 * time.Second
+)
+
+// PassiveHealth is a middleware placed in front of reverse_proxy that counts
+// failed proxied requests per upstream, like reverse_proxy's passive health
+// checks, and feeds them to the blockchain_health upstream module. A node with
+// MaxFails failures within FailDuration is excluded from selection as if the
+// active checks had found it unhealthy, so fallback strategies and
+// min_healthy_nodes see one consistent view of the pool.
+//
+// reverse_proxy's own passive checks cannot be used for this: its per-host
+// failure counts are dropped once no request to a dynamic upstream is in
+// flight, and its health state cannot be set from outside the package.
+type PassiveHealth struct {
+	MaxFails         int            `json:"max_fails,omitempty"`
+	FailDuration     caddy.Duration `json:"fail_duration,omitempty"`
+	UnhealthyStatus  []int          `json:"unhealthy_status,omitempty"`
+	UnhealthyLatency caddy.Duration `json:"unhealthy_latency,omitempty"`
+}
+
+func init() {
+	caddy.RegisterModule(&PassiveHealth{})
+}
+
+// CaddyModule returns the Caddy module information.
+func (*PassiveHealth) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.blockchain_passive_health",
+		New: func() caddy.Module { return new(PassiveHealth) },
+	}
+}
+
+// Provision applies defaults
+func (h *PassiveHealth) Provision(ctx caddy.Context) error {
+	if h.MaxFails == 0 {
+		h.MaxFails = defaultPassiveMaxFails
+	}
+	if h.FailDuration == 0 {
+		h.FailDuration = caddy.Duration(defaultPassiveFailDuration)
+	}
+	return nil
+}
+
+// Validate checks configuration correctness
+func (h *PassiveHealth) Validate() error {
+	if h.MaxFails < 0 {
+		return fmt.Errorf("max_fails must not be negative")
+	}
+	if h.FailDuration < 0 {
+		return fmt.Errorf("fail_duration must not be negative")
+	}
+	if h.UnhealthyLatency < 0 {
+		return fmt.Errorf("unhealthy_latency must not be negative")
+	}
+	for _, status := range h.UnhealthyStatus {
+		// Single digits are status classes, e.g. 5 for 5xx
+		if (status < 1 || status > 5) && (status < 100 || status > 599) {
+			return fmt.Errorf("invalid unhealthy_status %d", status)
+		}
+	}
+	return nil
+}
+
+// ServeHTTP proxies the request and counts a failure against the upstream
+// that served it when the proxy failed or the response looked unhealthy
+func (h *PassiveHealth) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	rec := &passiveStatusWriter{
+		ResponseWriterWrapper: &caddyhttp.ResponseWriterWrapper{ResponseWriter: w},
+		start:                 time.Now(),
+	}
+	err := next.ServeHTTP(rec, r)
+
+	upstream := ""
+	if repl, ok := r.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer); ok {
+		upstream = repl.ReplaceAll("{http.reverse_proxy.upstream.hostport}", "")
+	}
+	if upstream != "" && h.failed(rec, err) {
+		passiveFailures.record(upstream, h.MaxFails, time.Duration(h.FailDuration))
+	}
+	return err
+}
+
+// failed reports whether a proxied request counts as a failure
+func (h *PassiveHealth) failed(rec *passiveStatusWriter, err error) bool {
+	var handlerErr caddyhttp.HandlerError
+	if errors.As(err, &handlerErr) {
+		// reverse_proxy answers 502 when the node could not be reached and
+		// 504 when it timed out; refusals to select a node are 503
+		return handlerErr.StatusCode == http.StatusBadGateway || handlerErr.StatusCode == http.StatusGatewayTimeout
+	}
+	if rec.status == 0 {
+		return false
+	}
+	if h.UnhealthyLatency > 0 && rec.latency >= time.Duration(h.UnhealthyLatency) {
+		return true
+	}
+	for _, status := range h.UnhealthyStatus {
+		if caddyhttp.StatusCodeMatches(rec.status, status) {
+			return true
+		}
+	}
+	return false
+}
+
+// passiveStatusWriter records the status of the response and how long the
+// upstream took to send it
+type passiveStatusWriter struct {
+	*caddyhttp.ResponseWriterWrapper
+	start   time.Time
+	status  int
+	latency time.Duration
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *passiveStatusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.latency = time.Since(w.start)
+	}
+	w.ResponseWriterWrapper.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (w *passiveStatusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriterWrapper.Write(p)
+}
+
+// passiveFailureRegistry remembers recent passive failures per upstream
+// address. It is process-wide so the upstream module can consult failures
+// any PassiveHealth handler counted.
+type passiveFailureRegistry struct {
+	mutex     sync.Mutex
+	failures  map[string][]time.Time // expiry of each remembered failure, oldest first
+	downUntil map[string]time.Time
+	now       func() time.Time
+
+	// Whether downUntil has entries, so requests skip the lock while no
+	// upstream is down
+	anyDown atomic.Bool
+}
+
+var passiveFailures = &passiveFailureRegistry{
+	failures:  make(map[string][]time.Time),
+	downUntil: make(map[string]time.Time),
+	now:       time.Now,
+}
+
+// record counts a failure against the upstream. Once maxFails failures are
+// remembered, the upstream is down until enough of them expire.
+func (reg *passiveFailureRegistry) record(upstream string, maxFails int, failDuration time.Duration) {
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+
+	now := reg.now()
+	expiries := reg.failures[upstream]
+	kept := expiries[:0]
+	for _, expiry := range expiries {
+		if expiry.After(now) {
+			kept = append(kept, expiry)
+		}
+	}
+	kept = append(kept, now.Add(failDuration))
+	reg.failures[upstream] = kept
+
+	if maxFails > 0 && len(kept) >= maxFails {
+		// Down until fewer than maxFails failures are left
+		until := kept[len(kept)-maxFails]
+		if until.After(reg.downUntil[upstream]) {
+			reg.downUntil[upstream] = until
+			reg.anyDown.Store(true)
+		}
+	}
+}
+
+// isDown reports whether passive failures currently mark the upstream down
+func (reg *passiveFailureRegistry) isDown(upstream string) bool {
+	if !reg.anyDown.Load() {
+		return false
+	}
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+
+	until, ok := reg.downUntil[upstream]
+	if !ok {
+		return false
+	}
+	if !reg.now().Before(until) {
+		delete(reg.downUntil, upstream)
+		reg.anyDown.Store(len(reg.downUntil) > 0)
+		return false
+	}
+	return true
+}
+
+// passivelyDown reports whether passive failures mark the node down
+func passivelyDown(health *NodeHealth) bool {
+	dial := nodeDial(health.URL)
+	if dial == "" {
+		return false
+	}
+	return passiveFailures.isDown(dial)
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*PassiveHealth)(nil)
+	_ caddy.Validator             = (*PassiveHealth)(nil)
+	_ caddyhttp.MiddlewareHandler = (*PassiveHealth)(nil)
+)
@@ -0,0 +1,95 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	httpcaddyfile "github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+func init() {
+	// Register Caddyfile directive for this handler
+	httpcaddyfile.RegisterHandlerDirective("blockchain_passive_health", parsePassiveHealthCaddyfile)
+	httpcaddyfile.RegisterDirectiveOrder("blockchain_passive_health", httpcaddyfile.Before, "reverse_proxy")
+}
+
+func parsePassiveHealthCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	ph := new(PassiveHealth)
+	if err := ph.UnmarshalCaddyfile(h.Dispenser); err != nil {
+		return nil, err
+	}
+	return ph, nil
+}
+
+// UnmarshalCaddyfile implements caddyfile.Unmarshaler for blockchain_passive_health.
+// Options mirror reverse_proxy's passive health checks.
+func (h *PassiveHealth) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	for d.Next() {
+		for d.NextBlock(0) {
+			switch d.Val() {
+			case "max_fails":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				n, err := strconv.Atoi(d.Val())
+				if err != nil {
+					return d.Errf("invalid max_fails: %v", err)
+				}
+				h.MaxFails = n
+
+			case "fail_duration":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				dur, err := time.ParseDuration(d.Val())
+				if err != nil {
+					return d.Errf("invalid fail_duration: %v", err)
+				}
+				h.FailDuration = caddy.Duration(dur)
+
+			case "unhealthy_status":
+				args := d.RemainingArgs()
+				if len(args) == 0 {
+					return d.ArgErr()
+				}
+				for _, arg := range args {
+					// 5xx is the status class 5
+					if len(arg) == 3 && strings.HasSuffix(arg, "xx") {
+						arg = arg[:1]
+					}
+					status, err := strconv.Atoi(arg)
+					if err != nil {
+						return d.Errf("invalid unhealthy_status %q: %v", arg, err)
+					}
+					h.UnhealthyStatus = append(h.UnhealthyStatus, status)
+				}
+
+			case "unhealthy_latency":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				dur, err := time.ParseDuration(d.Val())
+				if err != nil {
+					return d.Errf("invalid unhealthy_latency: %v", err)
+				}
+				h.UnhealthyLatency = caddy.Duration(dur)
+
+			default:
+				return d.Errf("unknown directive: %s", d.Val())
+			}
+		}
+	}
+
+	if err := h.Validate(); err != nil {
+		return fmt.Errorf("blockchain_passive_health validation: %w", err)
+	}
+	return nil
+}
+
+// Interface guard
+var _ caddyfile.Unmarshaler = (*PassiveHealth)(nil)
@@ -0,0 +1,149 @@
+package blockchain_health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestPassiveFailureRegistry_MaxFailsAndExpiry(t *testing.T) {
+	now := time.Unix(1000, 0)
+	reg := &passiveFailureRegistry{
+		failures:  make(map[string][]time.Time),
+		downUntil: make(map[string]time.Time),
+		now:       func() time.Time { return now },
+	}
+
+	reg.record("node:26657", 2, 10*time.Second)
+	if reg.isDown("node:26657") {
+		t.Fatal("expected one failure below max_fails to keep the node up")
+	}
+
+	now = now.Add(2 * time.Second)
+	reg.record("node:26657", 2, 10*time.Second)
+	if !reg.isDown("node:26657") {
+		t.Fatal("expected max_fails failures to mark the node down")
+	}
+
+	// The first failure expires, leaving one
+	now = now.Add(9 * time.Second)
+	if reg.isDown("node:26657") {
+		t.Error("expected the node to recover once failures expire")
+	}
+	reg.record("node:26657", 2, 10*time.Second)
+	if !reg.isDown("node:26657") {
+		t.Error("expected the remaining failure to count toward max_fails")
+	}
+}
+
+func TestPassiveHealth_CountsFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		next      caddyhttp.HandlerFunc
+		expectHit bool
+	}{
+		{
+			name: "unreachable",
+			next: func(w http.ResponseWriter, r *http.Request) error {
+				return caddyhttp.Error(http.StatusBadGateway, errors.New("connection refused"))
+			},
+			expectHit: true,
+		},
+		{
+			name: "unhealthy_status",
+			next: func(w http.ResponseWriter, r *http.Request) error {
+				w.WriteHeader(http.StatusInternalServerError)
+				return nil
+			},
+			expectHit: true,
+		},
+		{
+			name: "ok",
+			next: func(w http.ResponseWriter, r *http.Request) error {
+				_, err := w.Write([]byte("{}"))
+				return err
+			},
+		},
+		{
+			name: "refused_selection",
+			next: func(w http.ResponseWriter, r *http.Request) error {
+				return caddyhttp.Error(http.StatusServiceUnavailable, errors.New(noUpstreamsMessage))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := "passive-" + tt.name + ":8545"
+			h := &PassiveHealth{UnhealthyStatus: []int{5}}
+			if err := h.Provision(caddy.Context{}); err != nil {
+				t.Fatalf("Provision failed: %v", err)
+			}
+
+			repl := caddy.NewReplacer()
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			r = r.WithContext(context.WithValue(r.Context(), caddy.ReplacerCtxKey, repl))
+			next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+				repl.Set("http.reverse_proxy.upstream.hostport", upstream)
+				return tt.next(w, r)
+			})
+
+			_ = h.ServeHTTP(httptest.NewRecorder(), r, next)
+			if down := passiveFailures.isDown(upstream); down != tt.expectHit {
+				t.Errorf("expected down=%v, got %v", tt.expectHit, down)
+			}
+		})
+	}
+}
+
+func TestSelectUpstreams_ExcludesPassivelyDownNodes(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	failing := createCosmosServer(t, 1000, false)
+	defer failing.Close()
+	good := createCosmosServer(t, 1000, false)
+	defer good.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "failing", URL: failing.URL, Type: NodeTypeCosmos, Weight: 1},
+		{Name: "good", URL: good.URL, Type: NodeTypeCosmos, Weight: 1},
+	}, logger)
+
+	passiveFailures.record(getDynamicTestHostFromURL(failing.URL), 1, time.Minute)
+
+	upstreams, err := upstream.GetUpstreams(&http.Request{URL: &url.URL{Path: "/"}, Header: http.Header{}})
+	if err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+	if len(upstreams) != 1 || upstreams[0].Dial != getDynamicTestHostFromURL(good.URL) {
+		t.Errorf("expected only the good node to be selected, got %v", upstreams)
+	}
+}
+
+func TestPassiveHealth_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_passive_health {
+		max_fails 3
+		fail_duration 1m
+		unhealthy_status 5xx 429
+		unhealthy_latency 2s
+	}`)
+
+	var h PassiveHealth
+	if err := h.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if h.MaxFails != 3 || time.Duration(h.FailDuration) != time.Minute || time.Duration(h.UnhealthyLatency) != 2*time.Second {
+		t.Errorf("unexpected config: max_fails=%d fail_duration=%v unhealthy_latency=%v", h.MaxFails, h.FailDuration, h.UnhealthyLatency)
+	}
+	if len(h.UnhealthyStatus) != 2 || h.UnhealthyStatus[0] != 5 || h.UnhealthyStatus[1] != 429 {
+		t.Errorf("unexpected unhealthy_status: %v", h.UnhealthyStatus)
+	}
+}
@@ -25,7 +25,11 @@ func (b *BlockchainHealthUpstream) UnmarshalCaddyfile(d *caddyfile.Dispenser) er
 
 // Provision implements caddy.Provisioner.
 func (b *BlockchainHealthUpstream) Provision(ctx caddy.Context) error {
-	return b.provision(ctx)
+	if err := b.provision(ctx); err != nil {
+		return err
+	}
+	b.prewarm = b.newPrewarmer(ctx)
+	return nil
 }
 
 // Validate implements caddy.Validator.
@@ -0,0 +1,191 @@
+package blockchain_health
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+
+	"github.com/caddyserver/caddy/v2"
+)
+
+// Pool is a named pool of the blockchain_health app, for other Caddy modules
+// to pick healthy nodes for their own outbound calls, such as a faucet or an
+// indexer plugin, without running health checks of their own:
+//
+//	func (f *Faucet) Provision(ctx caddy.Context) error {
+//		pool, err := blockchain_health.LoadPool(ctx, "evm-main")
+//		if err != nil {
+//			return err
+//		}
+//		f.pool = pool
+//		return nil
+//	}
+//
+//	node, err := f.pool.SelectUpstream(ctx, blockchain_health.SelectionCriteria{})
+//	if err != nil {
+//		return err
+//	}
+//	resp, err := http.Post(node.URL, "application/json", body)
+//
+// The app keeps checking the pool's nodes; a Pool is valid for as long as
+// the config that loaded it.
+type Pool struct {
+	name     string
+	upstream *BlockchainHealthUpstream
+}
+
+// SelectionCriteria narrows the nodes SelectUpstream picks from. The zero
+// value picks any healthy node serving HTTP.
+type SelectionCriteria struct {
+	// ServiceType is the service_type metadata the node must have (rpc, api
+	// or websocket). Empty matches every node but WebSocket ones, like
+	// proxied HTTP requests.
+	ServiceType ServiceType
+
+	// Height is a block height the node must still serve, for historical
+	// queries against pruned nodes
+	Height uint64
+
+	// ValidatorSafe picks only Beacon nodes fully synced and finalizing, as
+	// for validator duties
+	ValidatorSafe bool
+
+	// Finalized picks EVM nodes by how far their finalized block trails the
+	// pool's instead of their head, as for requests for finalized data. It
+	// needs track_finality.
+	Finalized bool
+
+	// Exclude lists nodes not to pick by name, such as one that just failed
+	Exclude []string
+}
+
+// SelectedUpstream is a node picked by SelectUpstream
+type SelectedUpstream struct {
+	Name        string
+	URL         string
+	Type        NodeType
+	BlockHeight uint64
+
+	// Headers are the node's static headers, which requests to it must
+	// carry, with {env.*} placeholders resolved
+	Headers map[string]string
+}
+
+// LoadPool returns the named pool of the blockchain_health app. Call it while
+// provisioning a module, so the app is loaded with the module's config.
+func LoadPool(ctx caddy.Context, name string) (*Pool, error) {
+	module, err := ctx.App("blockchain_health")
+	if err != nil {
+		return nil, fmt.Errorf("loading blockchain_health app: %w", err)
+	}
+	return module.(*App).Pool(name)
+}
+
+// Pool returns the named pool of the app
+func (a *App) Pool(name string) (*Pool, error) {
+	upstream, ok := a.Pools[name]
+	if !ok {
+		return nil, fmt.Errorf("pool %s is not defined in the blockchain_health app", name)
+	}
+	return &Pool{name: name, upstream: upstream}, nil
+}
+
+// Name returns the name of the pool
+func (p *Pool) Name() string {
+	return p.name
+}
+
+// SelectUpstream picks a node of the pool matching the criteria at random by
+// weight, from the latest health results. Like proxied requests, it holds
+// for up to max_wait while no node is healthy, skips candidates and nodes
+// failing passive health checks, and gives throttled nodes less weight. It
+// never falls back to unhealthy nodes and returns an error wrapping
+// ErrNoHealthyUpstreams if no node matches.
+func (p *Pool) SelectUpstream(ctx context.Context, criteria SelectionCriteria) (*SelectedUpstream, error) {
+	b := p.upstream
+	if b == nil || b.config == nil || b.healthChecker == nil {
+		return nil, ErrNotProvisioned
+	}
+
+	results := b.getCachedHealthResults()
+	if len(results) == 0 {
+		var err error
+		results, err = b.healthChecker.CheckAllNodes(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("health check failed: %w", err)
+		}
+	}
+
+	enforce := b.config.FailureHandling.enforceExclusions()
+	if enforce {
+		results = b.waitForHealthyNodes(ctx, results)
+	}
+
+	nodes := b.config.nodeList()
+	var candidates []*NodeHealth
+	var weights []int
+	total := 0
+	for _, health := range results {
+		node := findNode(nodes, health.Name)
+		if node == nil || node.isCandidate() || slices.Contains(criteria.Exclude, health.Name) {
+			continue
+		}
+		healthy := health.Healthy || criteria.Finalized && health.headLagOnly() && health.servesFinalized()
+		if enforce && (!healthy || passivelyDown(health)) {
+			continue
+		}
+
+		serviceType := node.serviceType()
+		if criteria.ServiceType == "" && (serviceType == ServiceTypeWebSocket || serviceType == ServiceTypeGraphQL) ||
+			criteria.ServiceType != "" && serviceType != criteria.ServiceType {
+			continue
+		}
+		if !health.servesHeight(criteria.Height) {
+			continue
+		}
+		if criteria.ValidatorSafe && !health.validatorSafe() {
+			continue
+		}
+		if criteria.Finalized && !health.servesFinalized() {
+			continue
+		}
+
+		weight := max(node.Weight, 1)
+		if health.Throttled {
+			weight = throttledWeight(weight, b.config.FailureHandling.ThrottleWeightFactor)
+		}
+		candidates = append(candidates, health)
+		weights = append(weights, weight)
+		total += weight
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w: no node of pool %s matches the selection criteria", ErrNoHealthyUpstreams, p.name)
+	}
+
+	picked := candidates[len(candidates)-1]
+	n := rand.Intn(total)
+	for i, weight := range weights {
+		n -= weight
+		if n < 0 {
+			picked = candidates[i]
+			break
+		}
+	}
+
+	node := findNode(nodes, picked.Name)
+	selected := &SelectedUpstream{
+		Name:        picked.Name,
+		URL:         picked.URL,
+		Type:        node.Type,
+		BlockHeight: picked.BlockHeight,
+	}
+	if len(node.Headers) > 0 {
+		repl := caddy.NewReplacer()
+		selected.Headers = make(map[string]string, len(node.Headers))
+		for name, value := range node.Headers {
+			selected.Headers[name] = repl.ReplaceKnown(value, "")
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,89 @@
+package blockchain_health
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestPool_SelectUpstream(t *testing.T) {
+	leader := createEVMServer(t, 1000, false)
+	defer leader.Close()
+	lagging := createEVMServer(t, 900, false)
+	defer lagging.Close()
+	candidate := createEVMServer(t, 1000, false)
+	defer candidate.Close()
+
+	t.Setenv("ORG_TOKEN", "secret")
+	nodes := []NodeConfig{
+		{Name: "leader", URL: leader.URL, Type: NodeTypeEVM, Weight: 1, Headers: map[string]string{"X-Org-Token": "{env.ORG_TOKEN}"}},
+		{Name: "lagging", URL: lagging.URL, Type: NodeTypeEVM, Weight: 1},
+		{Name: "candidate", URL: candidate.URL, Type: NodeTypeEVM, Weight: 1, Metadata: map[string]string{"candidate": "true"}},
+	}
+	pool := &Pool{name: "evm-main", upstream: createTestUpstream(nodes, zaptest.NewLogger(t))}
+
+	for range 10 {
+		node, err := pool.SelectUpstream(t.Context(), SelectionCriteria{})
+		if err != nil {
+			t.Fatalf("SelectUpstream failed: %v", err)
+		}
+		if node.Name != "leader" || node.URL != leader.URL || node.BlockHeight != 1000 {
+			t.Fatalf("expected the healthy non-candidate node, got %+v", node)
+		}
+		if node.Headers["X-Org-Token"] != "secret" {
+			t.Errorf("expected the node's headers with placeholders resolved, got %v", node.Headers)
+		}
+	}
+
+	_, err := pool.SelectUpstream(t.Context(), SelectionCriteria{Exclude: []string{"leader"}})
+	if !errors.Is(err, ErrNoHealthyUpstreams) {
+		t.Errorf("expected ErrNoHealthyUpstreams without the leader, got %v", err)
+	}
+	_, err = pool.SelectUpstream(t.Context(), SelectionCriteria{ServiceType: "websocket"})
+	if !errors.Is(err, ErrNoHealthyUpstreams) {
+		t.Errorf("expected ErrNoHealthyUpstreams for WebSocket nodes, got %v", err)
+	}
+
+	if _, err := (&Pool{name: "unprovisioned", upstream: &BlockchainHealthUpstream{}}).SelectUpstream(t.Context(), SelectionCriteria{}); !errors.Is(err, ErrNotProvisioned) {
+		t.Errorf("expected ErrNotProvisioned, got %v", err)
+	}
+}
+
+func TestLoadPool(t *testing.T) {
+	node := createEVMServer(t, 1000, false)
+	defer node.Close()
+
+	config := fmt.Sprintf(`{
+		"admin": {"disabled": true},
+		"apps": {
+			"blockchain_health": {
+				"pools": {
+					"evm-main": {"nodes": [{"name": "a", "url": %q, "type": "evm"}]}
+				}
+			}
+		}
+	}`, node.URL)
+	if err := caddy.Load([]byte(config), true); err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	defer func() { _ = caddy.Stop() }()
+
+	pool, err := LoadPool(caddy.ActiveContext(), "evm-main")
+	if err != nil {
+		t.Fatalf("LoadPool failed: %v", err)
+	}
+	selected, err := pool.SelectUpstream(t.Context(), SelectionCriteria{})
+	if err != nil {
+		t.Fatalf("SelectUpstream failed: %v", err)
+	}
+	if selected.Name != "a" || selected.Type != NodeTypeEVM {
+		t.Errorf("unexpected node %+v", selected)
+	}
+
+	if _, err := LoadPool(caddy.ActiveContext(), "missing"); err == nil {
+		t.Error("expected an error for an undefined pool")
+	}
+}
@@ -0,0 +1,305 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap"
+)
+
+// PoolState is the degradation tier of a pool
+type PoolState string
+
+// Pool states, from best to worst
+const (
+	PoolHealthy     PoolState = "healthy"
+	PoolDegraded    PoolState = "degraded"
+	PoolChainHalted PoolState = "chain_halted"
+	PoolCritical    PoolState = "critical"
+	PoolDown        PoolState = "down"
+)
+
+// poolStates lists the pool states from best to worst. A halted chain is
+// stuck for every gateway alike, so it ranks below critical and doesn't
+// withdraw the gateway by default.
+var poolStates = []PoolState{PoolHealthy, PoolDegraded, PoolChainHalted, PoolCritical, PoolDown}
+
+// Default healthy shares below which a pool is degraded or critical
+const (
+	defaultDegradedBelow = 0.75
+	defaultCriticalBelow = 0.5
+)
+
+// severity orders pool states from best to worst
+func (s PoolState) severity() int {
+	for i, state := range poolStates {
+		if state == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// isValidPoolState reports whether s names a pool state
+func isValidPoolState(s string) bool {
+	return PoolState(s).severity() >= 0
+}
+
+// classifyPool returns the state of the pool for a set of health results.
+// Candidates are ignored and throttled nodes do not count as healthy, like in
+// the health endpoint. A pool with fewer healthy nodes than min_healthy_nodes
+// is at least critical. While a chain of the pool is halted, the pool is
+// chain_halted unless no node is healthy, as its nodes aren't at fault.
+func (h *HealthChecker) classifyPool(results []*NodeHealth) PoolState {
+	total, healthy := 0, 0
+	for _, health := range results {
+		if health == nil || h.isCandidate(health.Name) {
+			continue
+		}
+		total++
+		if health.Healthy && !health.Throttled {
+			healthy++
+		}
+	}
+	if healthy == 0 {
+		return PoolDown
+	}
+	if h.anyChainHalted() {
+		return PoolChainHalted
+	}
+
+	share := float64(healthy) / float64(total)
+	switch {
+	case share < h.config.PoolState.CriticalBelow || healthy < h.config.FailureHandling.MinHealthyNodes:
+		return PoolCritical
+	case share < h.config.PoolState.DegradedBelow:
+		return PoolDegraded
+	default:
+		return PoolHealthy
+	}
+}
+
+// updatePoolState records the state of the pool after a health check
+func (h *HealthChecker) updatePoolState(results []*NodeHealth) {
+	live := h.state
+	if live == nil {
+		return
+	}
+	state := h.classifyPool(results)
+	previous := live.set(h, state)
+	h.applyDNSFailover(live.name, state)
+	h.applyStatusPage(live.name, state)
+	h.writeAnycastSignal(live)
+	if previous == state {
+		return
+	}
+
+	if h.metrics != nil {
+		for _, s := range poolStates {
+			value := 0.0
+			if s == state {
+				value = 1
+			}
+			h.metrics.poolState.WithLabelValues(live.name, string(s)).Set(value)
+		}
+	}
+	if previous != "" {
+		h.logger.Info("pool state changed",
+			zap.String("pool", live.name),
+			zap.String("from", string(previous)),
+			zap.String("to", string(state)))
+		h.emit(eventPoolStateChanged, map[string]any{
+			"pool": live.name,
+			"from": string(previous),
+			"to":   string(state),
+		})
+	}
+}
+
+// chainState is the live state of the pools sharing a pool state name. The
+// chain is as bad as its worst pool, so RPC and REST pools of one chain can
+// share it.
+type chainState struct {
+	name string
+
+	mutex sync.RWMutex
+	pools map[*HealthChecker]PoolState
+
+	// Set once one of the pools drains before Caddy exits
+	draining atomic.Bool
+}
+
+// Destruct implements caddy.Destructor
+func (c *chainState) Destruct() error {
+	return nil
+}
+
+// set records the state of a pool and returns its previous state
+func (c *chainState) set(pool *HealthChecker, state PoolState) PoolState {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	previous := c.pools[pool]
+	c.pools[pool] = state
+	return previous
+}
+
+// state returns the worst state of the pools, or false while none of them
+// has been checked
+func (c *chainState) state() (PoolState, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	worst, found := PoolHealthy, false
+	for _, state := range c.pools {
+		if state == "" {
+			continue
+		}
+		found = true
+		if state.severity() > worst.severity() {
+			worst = state
+		}
+	}
+	return worst, found
+}
+
+// chainStates holds the live pool states by name
+var chainStates = caddy.NewUsagePool()
+
+// registerChainState adds a pool to the state for the given name, creating
+// it if needed
+func registerChainState(name string, pool *HealthChecker) (*chainState, error) {
+	value, _, err := chainStates.LoadOrNew(name, func() (caddy.Destructor, error) {
+		return &chainState{name: name, pools: make(map[*HealthChecker]PoolState)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	state := value.(*chainState)
+	state.mutex.Lock()
+	if _, ok := state.pools[pool]; !ok {
+		state.pools[pool] = ""
+	}
+	state.mutex.Unlock()
+	return state, nil
+}
+
+// releaseChainState removes a pool from its state
+func releaseChainState(state *chainState, pool *HealthChecker) {
+	state.mutex.Lock()
+	delete(state.pools, pool)
+	state.mutex.Unlock()
+	_, _ = chainStates.Delete(state.name)
+}
+
+// lookupChainState returns the live state with the given name
+func lookupChainState(name string) (*chainState, bool) {
+	var found *chainState
+	chainStates.Range(func(key, value any) bool {
+		if key == name {
+			found = value.(*chainState)
+			return false
+		}
+		return true
+	})
+	return found, found != nil
+}
+
+// poolStateName returns the configured pool state name, defaulting to the chain
+func (b *BlockchainHealthUpstream) poolStateName() string {
+	if b.PoolState.Name != "" {
+		return b.PoolState.Name
+	}
+	if chain := b.chainName(); chain != "" {
+		return chain
+	}
+	return "default"
+}
+
+// MatchPoolState matches requests while the pools with the given pool state
+// name are in one of the listed states, so routes can respond differently to
+// a degraded chain:
+//
+//	@degraded blockchain_pool_state osmosis degraded critical down
+//
+// It never matches before the pools have been checked.
+type MatchPoolState struct {
+	Pool   string      `json:"pool"`
+	States []PoolState `json:"states"`
+}
+
+func init() {
+	caddy.RegisterModule(&MatchPoolState{})
+}
+
+// CaddyModule returns the Caddy module information.
+func (*MatchPoolState) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.matchers.blockchain_pool_state",
+		New: func() caddy.Module { return new(MatchPoolState) },
+	}
+}
+
+// Validate checks configuration correctness
+func (m *MatchPoolState) Validate() error {
+	if m.Pool == "" {
+		return fmt.Errorf("blockchain_pool_state requires a pool name")
+	}
+	if len(m.States) == 0 {
+		return fmt.Errorf("blockchain_pool_state requires at least one state")
+	}
+	for _, state := range m.States {
+		if !isValidPoolState(string(state)) {
+			return fmt.Errorf("invalid pool state %q (must be healthy, degraded, chain_halted, critical or down)", state)
+		}
+	}
+	return nil
+}
+
+// Match implements caddyhttp.RequestMatcher
+func (m *MatchPoolState) Match(r *http.Request) bool {
+	live, ok := lookupChainState(m.Pool)
+	if !ok {
+		return false
+	}
+	current, ok := live.state()
+	if !ok {
+		return false
+	}
+	for _, state := range m.States {
+		if state == current {
+			return true
+		}
+	}
+	return false
+}
+
+// UnmarshalCaddyfile implements caddyfile.Unmarshaler.
+//
+//	blockchain_pool_state <pool> <states...>
+func (m *MatchPoolState) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	for d.Next() {
+		args := d.RemainingArgs()
+		if len(args) < 2 {
+			return d.ArgErr()
+		}
+		m.Pool = args[0]
+		for _, state := range args[1:] {
+			m.States = append(m.States, PoolState(state))
+		}
+	}
+	if err := m.Validate(); err != nil {
+		return d.Err(err.Error())
+	}
+	return nil
+}
+
+// Interface guards
+var (
+	_ caddy.Validator          = (*MatchPoolState)(nil)
+	_ caddyhttp.RequestMatcher = (*MatchPoolState)(nil)
+	_ caddyfile.Unmarshaler    = (*MatchPoolState)(nil)
+)
@@ -0,0 +1,150 @@
+package blockchain_health
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap/zaptest"
+)
+
+// poolResults returns health results with the given number of healthy nodes
+func poolResults(healthy, total int) []*NodeHealth {
+	results := make([]*NodeHealth, total)
+	for i := range results {
+		results[i] = &NodeHealth{Name: string(rune('a' + i)), Healthy: i < healthy}
+	}
+	return results
+}
+
+// poolStateGauge reads the pool state gauge of a pool and state
+func poolStateGauge(t *testing.T, metrics *Metrics, pool string, state PoolState) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := metrics.poolState.WithLabelValues(pool, string(state)).Write(&m); err != nil {
+		t.Fatalf("reading pool_state: %v", err)
+	}
+	return m.GetGauge().GetValue()
+}
+
+func TestClassifyPool(t *testing.T) {
+	upstream := createTestUpstream(nil, zaptest.NewLogger(t))
+	upstream.config.PoolState = PoolStateConfig{DegradedBelow: 0.75, CriticalBelow: 0.5}
+	upstream.config.FailureHandling.MinHealthyNodes = 2
+
+	tests := []struct {
+		healthy, total int
+		want           PoolState
+	}{
+		{4, 4, PoolHealthy},
+		{3, 4, PoolHealthy},
+		{5, 8, PoolDegraded},
+		{3, 8, PoolCritical},
+		{1, 2, PoolCritical}, // below min_healthy_nodes
+		{0, 4, PoolDown},
+		{0, 0, PoolDown},
+	}
+	for _, tt := range tests {
+		if got := upstream.healthChecker.classifyPool(poolResults(tt.healthy, tt.total)); got != tt.want {
+			t.Errorf("%d of %d healthy: expected %s, got %s", tt.healthy, tt.total, tt.want, got)
+		}
+	}
+
+	// Throttled nodes do not count as healthy
+	results := poolResults(4, 4)
+	results[0].Throttled = true
+	results[1].Throttled = true
+	if got := upstream.healthChecker.classifyPool(results); got != PoolDegraded {
+		t.Errorf("expected throttled nodes to degrade the pool, got %s", got)
+	}
+}
+
+func TestPoolStateMatcher(t *testing.T) {
+	rpc := createTestUpstream(nil, zaptest.NewLogger(t))
+	rest := createTestUpstream(nil, zaptest.NewLogger(t))
+	for _, upstream := range []*BlockchainHealthUpstream{rpc, rest} {
+		upstream.config.PoolState = PoolStateConfig{DegradedBelow: 0.75, CriticalBelow: 0.5}
+		state, err := registerChainState("pool-state-test", upstream.healthChecker)
+		if err != nil {
+			t.Fatalf("Failed to register pool state: %v", err)
+		}
+		upstream.healthChecker.state = state
+		defer releaseChainState(state, upstream.healthChecker)
+	}
+
+	degraded := &MatchPoolState{Pool: "pool-state-test", States: []PoolState{PoolDegraded, PoolCritical}}
+	req := httptest.NewRequest("POST", "/", nil)
+	if degraded.Match(req) {
+		t.Error("expected no match before the pools were checked")
+	}
+
+	rpc.healthChecker.updatePoolState(poolResults(4, 4))
+	rest.healthChecker.updatePoolState(poolResults(4, 4))
+	if degraded.Match(req) {
+		t.Error("expected no match while both pools are healthy")
+	}
+	if got := poolStateGauge(t, rpc.healthChecker.metrics, "pool-state-test", PoolHealthy); got != 1 {
+		t.Errorf("expected the healthy state gauge to be 1, got %v", got)
+	}
+
+	// The chain is as bad as its worst pool
+	rest.healthChecker.updatePoolState(poolResults(2, 3))
+	if !degraded.Match(req) {
+		t.Error("expected a match once one pool is degraded")
+	}
+	if got := poolStateGauge(t, rest.healthChecker.metrics, "pool-state-test", PoolHealthy); got != 0 {
+		t.Errorf("expected the healthy state gauge to be 0, got %v", got)
+	}
+
+	rest.healthChecker.updatePoolState(poolResults(0, 3))
+	if degraded.Match(req) {
+		t.Error("expected no match once a pool is down")
+	}
+
+	unknown := &MatchPoolState{Pool: "missing", States: []PoolState{PoolDown}}
+	if unknown.Match(req) {
+		t.Error("expected no match for an unknown pool")
+	}
+}
+
+func TestPoolState_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:26657
+			type cosmos
+		}
+		pool_state {
+			name cosmoshub
+			degraded_below 0.9
+			critical_below 0.6
+		}
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if b.PoolState != (PoolStateConfig{Name: "cosmoshub", DegradedBelow: 0.9, CriticalBelow: 0.6}) {
+		t.Errorf("unexpected pool state config: %+v", b.PoolState)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+
+	b.PoolState.CriticalBelow = 0.95
+	if err := b.validate(); err == nil {
+		t.Error("expected critical_below above degraded_below to be rejected")
+	}
+
+	var m MatchPoolState
+	if err := m.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`blockchain_pool_state cosmoshub degraded down`)); err != nil {
+		t.Fatalf("Failed to parse matcher: %v", err)
+	}
+	if m.Pool != "cosmoshub" || len(m.States) != 2 || m.States[1] != PoolDown {
+		t.Errorf("unexpected matcher: %+v", m)
+	}
+	if err := (&MatchPoolState{}).UnmarshalCaddyfile(caddyfile.NewTestDispenser(`blockchain_pool_state cosmoshub sluggish`)); err == nil {
+		t.Error("expected an unknown state to be rejected")
+	}
+}
@@ -0,0 +1,228 @@
+package blockchain_health
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPrewarmConnections = 2
+	defaultPrewarmInterval    = 30 * time.Second
+
+	// prewarmTimeout bounds each keep-alive ping
+	prewarmTimeout = 5 * time.Second
+)
+
+// prewarmer pings every healthy node of a pool through the transport of the
+// reverse proxy the pool feeds, keeping connections to it open. The pings go
+// through the proxy's own transport since its connection pool is the one
+// requests use.
+type prewarmer struct {
+	upstream    *BlockchainHealthUpstream
+	handler     *reverseproxy.Handler
+	connections int
+	interval    time.Duration
+	logger      *zap.Logger
+
+	once   sync.Once
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// prewarmTarget is a node to keep connections open to
+type prewarmTarget struct {
+	name string
+	dial string
+}
+
+// newPrewarmer returns the prewarmer of an upstream, or nil if prewarm is off
+// or the upstream does not feed a reverse proxy
+func (b *BlockchainHealthUpstream) newPrewarmer(ctx caddy.Context) *prewarmer {
+	if !b.Prewarm.Enabled {
+		return nil
+	}
+
+	// The reverse proxy provisions its upstream source after its transport,
+	// so the handler is the module loading this one
+	var handler *reverseproxy.Handler
+	for _, module := range ctx.Modules() {
+		if h, ok := module.(*reverseproxy.Handler); ok {
+			handler = h
+		}
+	}
+	if handler == nil {
+		b.logger.Warn("prewarm only applies to upstreams of a reverse_proxy, disabling it")
+		return nil
+	}
+
+	p := &prewarmer{
+		upstream:    b,
+		handler:     handler,
+		connections: b.Prewarm.Connections,
+		interval:    defaultPrewarmInterval,
+		logger:      b.logger,
+		done:        make(chan struct{}),
+	}
+	if p.connections <= 0 {
+		p.connections = defaultPrewarmConnections
+	}
+	if interval, err := time.ParseDuration(b.Prewarm.Interval); err == nil && interval > 0 {
+		p.interval = interval
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	return p
+}
+
+// start begins warming connections. It runs on the first request, since the
+// reverse proxy only sets up its default transport after provisioning this
+// module.
+func (p *prewarmer) start() {
+	p.once.Do(func() {
+		transport, ok := p.handler.Transport.(*reverseproxy.HTTPTransport)
+		if !ok || transport.ProxyProtocol != "" {
+			p.logger.Warn("prewarm needs the reverse_proxy http transport without proxy_protocol, disabling it")
+			close(p.done)
+			return
+		}
+		go p.run(transport)
+	})
+}
+
+// stop ends warming connections and waits for the pings in flight
+func (p *prewarmer) stop() {
+	p.cancel()
+	p.once.Do(func() { close(p.done) })
+	<-p.done
+}
+
+// run warms connections every interval until stopped
+func (p *prewarmer) run(transport http.RoundTripper) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.warm(p.ctx, transport, p.targets())
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// targets returns the healthy nodes requests can be proxied to over pooled
+// connections. WebSocket and Unix socket nodes are left out: upgraded
+// connections are never reused, and sockets need no handshake.
+func (p *prewarmer) targets() []prewarmTarget {
+	pool := p.upstream
+	if pool.shared != nil {
+		pool = pool.shared
+	}
+	nodes := pool.config.nodeList()
+
+	var targets []prewarmTarget
+	seen := make(map[string]bool)
+	for _, health := range pool.getCachedHealthResults() {
+		node := findNode(nodes, health.Name)
+		if node == nil || node.isCandidate() || node.serviceType() == ServiceTypeWebSocket {
+			continue
+		}
+		if !health.Healthy || passivelyDown(health) {
+			continue
+		}
+		dial := nodeDial(health.URL)
+		if dial == "" || strings.HasPrefix(dial, "unix/") || seen[dial] {
+			continue
+		}
+		seen[dial] = true
+		targets = append(targets, prewarmTarget{name: health.Name, dial: dial})
+	}
+	return targets
+}
+
+// warm sends as many concurrent keep-alive pings to each target as it should
+// have warm connections. Idle connections are taken by one ping each, and
+// missing ones are opened.
+func (p *prewarmer) warm(ctx context.Context, transport http.RoundTripper, targets []prewarmTarget) {
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		for range p.connections {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p.ping(ctx, transport, target)
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// ping sends a HEAD request to a target the way the reverse proxy addresses
+// it, leaving the scheme to the transport, and records whether it reused a
+// warm connection
+func (p *prewarmer) ping(ctx context.Context, transport http.RoundTripper, target prewarmTarget) {
+	ctx, cancel := context.WithTimeout(ctx, prewarmTimeout)
+	defer cancel()
+
+	var reused bool
+	var handshake time.Duration
+	var getConn time.Time
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(string) { getConn = time.Now() },
+		GotConn: func(info httptrace.GotConnInfo) {
+			reused = info.Reused
+			if !reused {
+				handshake = time.Since(getConn)
+			}
+		},
+	})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, "http://"+target.dial+"/", nil)
+	if err != nil {
+		return
+	}
+	req.URL.Scheme = ""
+
+	metrics := p.metrics()
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		if p.ctx.Err() == nil {
+			p.logger.Debug("prewarm ping failed", zap.String("node", target.name), zap.Error(err))
+			if metrics != nil {
+				metrics.prewarmPings.WithLabelValues(target.name, "failed").Inc()
+			}
+		}
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	if metrics == nil {
+		return
+	}
+	if reused {
+		metrics.prewarmPings.WithLabelValues(target.name, "reused").Inc()
+		return
+	}
+	metrics.prewarmPings.WithLabelValues(target.name, "new").Inc()
+	metrics.prewarmHandshake.WithLabelValues(target.name).Observe(handshake.Seconds())
+}
+
+// metrics returns the metrics of the pool
+func (p *prewarmer) metrics() *Metrics {
+	if p.upstream.shared != nil {
+		return p.upstream.shared.metrics
+	}
+	return p.upstream.metrics
+}
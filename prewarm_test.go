@@ -0,0 +1,128 @@
+package blockchain_health
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestPrewarm_KeepsConnectionsWarm(t *testing.T) {
+	evm := createEVMServer(t, 1000, false)
+	evm.Close()
+	var opened atomic.Int32
+	server := httptest.NewUnstartedServer(evm.Config.Handler)
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			opened.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	nodes := []NodeConfig{
+		{Name: "rpc", URL: server.URL, Type: NodeTypeEVM, Weight: 1},
+		{Name: "ws", URL: server.URL + "/ws", Type: NodeTypeEVM, Weight: 1, Metadata: map[string]string{"service_type": "websocket"}},
+	}
+	upstream := createTestUpstream(nodes, zaptest.NewLogger(t))
+	upstream.cache = upstream.healthChecker.cache
+	if _, err := upstream.healthChecker.CheckAllNodes(t.Context()); err != nil {
+		t.Fatalf("CheckAllNodes failed: %v", err)
+	}
+
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	defer cancel()
+	transport := new(reverseproxy.HTTPTransport)
+	if err := transport.Provision(ctx); err != nil {
+		t.Fatalf("provisioning transport: %v", err)
+	}
+
+	p := &prewarmer{upstream: upstream, connections: 2, logger: upstream.logger}
+	p.ctx, p.cancel = context.WithCancel(t.Context())
+	defer p.cancel()
+
+	targets := p.targets()
+	if len(targets) != 1 || targets[0].name != "rpc" {
+		t.Fatalf("expected only the HTTP node to be warmed, got %+v", targets)
+	}
+
+	before := opened.Load()
+	p.warm(t.Context(), transport, targets)
+	if got := opened.Load() - before; got != 2 {
+		t.Errorf("expected the first round to open 2 connections, opened %d", got)
+	}
+	p.warm(t.Context(), transport, targets)
+	if got := opened.Load() - before; got != 2 {
+		t.Errorf("expected the second round to reuse the warm connections, %d were opened in total", got)
+	}
+
+	var reused, fresh dto.Metric
+	if err := upstream.metrics.prewarmPings.WithLabelValues("rpc", "reused").Write(&reused); err != nil {
+		t.Fatal(err)
+	}
+	if err := upstream.metrics.prewarmPings.WithLabelValues("rpc", "new").Write(&fresh); err != nil {
+		t.Fatal(err)
+	}
+	if reused.GetCounter().GetValue() != 2 || fresh.GetCounter().GetValue() != 2 {
+		t.Errorf("expected 2 new and 2 reused pings, got %v new and %v reused",
+			fresh.GetCounter().GetValue(), reused.GetCounter().GetValue())
+	}
+}
+
+func TestPrewarm_RequiresReverseProxy(t *testing.T) {
+	upstream := createTestUpstream(nil, zaptest.NewLogger(t))
+	upstream.Prewarm.Enabled = true
+
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	defer cancel()
+	if p := upstream.newPrewarmer(ctx); p != nil {
+		t.Error("expected no prewarmer outside of a reverse_proxy")
+	}
+
+	// Other transports are left alone, and stopping does not block
+	p := &prewarmer{
+		upstream: upstream,
+		handler:  &reverseproxy.Handler{Transport: http.DefaultTransport},
+		logger:   upstream.logger,
+		done:     make(chan struct{}),
+	}
+	p.ctx, p.cancel = context.WithCancel(t.Context())
+	p.start()
+	p.stop()
+}
+
+func TestPrewarm_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:8545
+			type evm
+		}
+		prewarm 4 {
+			interval 45s
+		}
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if !b.Prewarm.Enabled || b.Prewarm.Connections != 4 || b.Prewarm.Interval != "45s" {
+		t.Errorf("unexpected prewarm config %+v", b.Prewarm)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+
+	b.Prewarm.Interval = "soon"
+	if err := b.validate(); err == nil {
+		t.Error("expected an invalid prewarm interval to be rejected")
+	}
+}
@@ -0,0 +1,282 @@
+package blockchain_health
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	caddycmd "github.com/caddyserver/caddy/v2/cmd"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	caddycmd.RegisterCommand(caddycmd.Command{
+		Name:  "blockchain-health",
+		Usage: "probe [--config <path>] [--adapter <name>] [--watch <interval>] [--json]",
+		Short: "Health checks the blockchain_health pools of a config",
+		Long: `
+Runs the health checks of every blockchain_health pool in a config without
+starting the server, and prints the results of each node. The pools are the
+named pools of the blockchain_health app and the pools configured inline in
+dynamic blockchain_health blocks, with blockchain_health_defaults applied.
+
+By default the nodes are checked once, and the command exits with status 1
+if any node is unhealthy, for use in CI. With --watch, the nodes are checked
+at the given interval until interrupted.
+`,
+		CobraFunc: func(cmd *cobra.Command) {
+			probe := &cobra.Command{
+				Use:   "probe",
+				Short: "Health checks the blockchain_health pools of a config",
+				RunE:  caddycmd.WrapCommandFuncForCobra(cmdProbe),
+			}
+			probe.Flags().StringP("config", "c", "", "Configuration file (default: Caddyfile in the current directory)")
+			probe.Flags().StringP("adapter", "a", "", "Name of config adapter to apply")
+			probe.Flags().DurationP("watch", "w", 0, "Check continuously at this interval")
+			probe.Flags().Bool("json", false, "Print the results as JSON, one object per check")
+			cmd.AddCommand(probe)
+		},
+	})
+}
+
+// cmdProbe health checks the pools of a config once or continuously
+func cmdProbe(fl caddycmd.Flags) (int, error) {
+	config, _, err := caddycmd.LoadConfig(fl.String("config"), fl.String("adapter"))
+	if err != nil {
+		return caddy.ExitCodeFailedStartup, err
+	}
+	pools, err := probePools(config)
+	if err != nil {
+		return caddy.ExitCodeFailedStartup, err
+	}
+	if len(pools) == 0 {
+		return caddy.ExitCodeFailedStartup, fmt.Errorf("no blockchain_health pools in the config")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	caddyCtx, cancel := caddy.NewContext(caddy.Context{Context: ctx})
+	defer cancel()
+
+	for _, pool := range pools {
+		if err := pool.upstream.Provision(caddyCtx); err != nil {
+			return caddy.ExitCodeFailedStartup, fmt.Errorf("pool %s: %w", pool.name, err)
+		}
+		defer func() { _ = pool.upstream.Cleanup() }()
+		if err := pool.upstream.Validate(); err != nil {
+			return caddy.ExitCodeFailedStartup, fmt.Errorf("pool %s: %w", pool.name, err)
+		}
+	}
+
+	asJSON := fl.Bool("json")
+	watch := fl.Duration("watch")
+	if watch <= 0 {
+		if unhealthy := runProbe(ctx, pools, os.Stdout, asJSON); unhealthy > 0 {
+			return 1, fmt.Errorf("%d nodes are unhealthy", unhealthy)
+		}
+		return caddy.ExitCodeSuccess, nil
+	}
+
+	ticker := time.NewTicker(watch)
+	defer ticker.Stop()
+	for {
+		runProbe(ctx, pools, os.Stdout, asJSON)
+		select {
+		case <-ctx.Done():
+			return caddy.ExitCodeSuccess, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// probePool is a pool of the config being probed
+type probePool struct {
+	name     string
+	upstream *BlockchainHealthUpstream
+}
+
+// probePools returns the pools of a JSON config: the named pools of the
+// blockchain_health app and the pools of dynamic blockchain_health upstreams,
+// one per distinct configuration like in the app, with the defaults applied
+func probePools(config []byte) ([]probePool, error) {
+	var cfg struct {
+		Apps map[string]json.RawMessage `json:"apps"`
+	}
+	if err := json.Unmarshal(config, &cfg); err != nil {
+		return nil, fmt.Errorf("decoding config: %w", err)
+	}
+
+	var defaults *Defaults
+	if raw, ok := cfg.Apps["blockchain_health_defaults"]; ok {
+		defaults = new(Defaults)
+		if err := caddy.StrictUnmarshalJSON(raw, defaults); err != nil {
+			return nil, fmt.Errorf("decoding blockchain_health_defaults: %w", err)
+		}
+	}
+
+	var pools []probePool
+	if raw, ok := cfg.Apps["blockchain_health"]; ok {
+		var app App
+		if err := json.Unmarshal(raw, &app); err != nil {
+			return nil, fmt.Errorf("decoding blockchain_health app: %w", err)
+		}
+		for _, name := range app.poolNames() {
+			pools = append(pools, probePool{name: name, upstream: app.Pools[name]})
+		}
+	}
+
+	var inline []map[string]any
+	if raw, ok := cfg.Apps["http"]; ok {
+		var httpApp any
+		if err := json.Unmarshal(raw, &httpApp); err != nil {
+			return nil, fmt.Errorf("decoding http app: %w", err)
+		}
+		inline = findUpstreamSources(httpApp, inline)
+	}
+	seen := make(map[string]bool)
+	names := make(map[string]int)
+	for _, source := range inline {
+		delete(source, "source")
+		if _, ok := source["pool"]; ok {
+			continue // references a named pool
+		}
+		raw, err := json.Marshal(source)
+		if err != nil {
+			return nil, err
+		}
+		if seen[string(raw)] {
+			continue
+		}
+		seen[string(raw)] = true
+
+		upstream := new(BlockchainHealthUpstream)
+		if err := upstream.UnmarshalJSON(raw); err != nil {
+			return nil, fmt.Errorf("decoding blockchain_health upstream: %w", err)
+		}
+		name := upstream.PoolState.Name
+		for _, fallback := range []string{upstream.Chain.ChainType, upstream.Chain.ChainPreset, "inline"} {
+			if name == "" {
+				name = fallback
+			}
+		}
+		if names[name]++; names[name] > 1 {
+			name += "-" + strconv.Itoa(names[name])
+		}
+		pools = append(pools, probePool{name: name, upstream: upstream})
+	}
+
+	if defaults != nil {
+		for _, pool := range pools {
+			mergeDefaults(reflect.ValueOf(pool.upstream).Elem(), reflect.ValueOf(defaults).Elem())
+		}
+	}
+	return pools, nil
+}
+
+// findUpstreamSources appends the dynamic blockchain_health upstream sources
+// found anywhere in a decoded JSON value
+func findUpstreamSources(value any, sources []map[string]any) []map[string]any {
+	switch v := value.(type) {
+	case map[string]any:
+		if v["source"] == "blockchain_health" {
+			return append(sources, v)
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			sources = findUpstreamSources(v[key], sources)
+		}
+	case []any:
+		for _, item := range v {
+			sources = findUpstreamSources(item, sources)
+		}
+	}
+	return sources
+}
+
+// probeReport is the JSON output of a probe run
+type probeReport struct {
+	Time  time.Time         `json:"time"`
+	Pools []probePoolReport `json:"pools"`
+}
+
+// probePoolReport is the state and node results of a pool in a probe run
+type probePoolReport struct {
+	Name  string        `json:"name"`
+	State PoolState     `json:"state"`
+	Error string        `json:"error,omitempty"`
+	Nodes []*NodeHealth `json:"nodes"`
+}
+
+// runProbe checks the nodes of every pool and writes the results as a table
+// or JSON. It returns how many nodes are unhealthy, not counting candidates.
+func runProbe(ctx context.Context, pools []probePool, w io.Writer, asJSON bool) int {
+	report := probeReport{Time: time.Now()}
+	unhealthy := 0
+	for _, pool := range pools {
+		checker := pool.upstream.healthChecker
+		results, err := checker.CheckAllNodes(ctx)
+		poolReport := probePoolReport{Name: pool.name, Nodes: results}
+		if err != nil {
+			poolReport.State = PoolDown
+			poolReport.Error = err.Error()
+			unhealthy += len(pool.upstream.config.nodeList())
+		} else {
+			poolReport.State = checker.classifyPool(results)
+			for _, health := range results {
+				if !health.Healthy && !checker.isCandidate(health.Name) {
+					unhealthy++
+				}
+			}
+		}
+		report.Pools = append(report.Pools, poolReport)
+	}
+
+	if asJSON {
+		_ = json.NewEncoder(w).Encode(report)
+		return unhealthy
+	}
+
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "POOL\tSTATE\tNODE\tSTATUS\tHEIGHT\tBEHIND\tLATENCY\tERROR")
+	for i, poolReport := range report.Pools {
+		if poolReport.Error != "" {
+			fmt.Fprintf(tw, "%s\t%s\t-\t-\t-\t-\t-\t%s\n", poolReport.Name, poolReport.State, poolReport.Error)
+			continue
+		}
+		checker := pools[i].upstream.healthChecker
+		for _, health := range poolReport.Nodes {
+			status := "healthy"
+			switch {
+			case !health.Healthy:
+				status = "unhealthy"
+			case health.Throttled:
+				status = "throttled"
+			}
+			if checker.isCandidate(health.Name) {
+				status += " (candidate)"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\n",
+				poolReport.Name, poolReport.State, health.Name, status, health.BlockHeight,
+				health.BlocksBehindPool, health.ResponseTime.Round(time.Millisecond), health.LastError)
+		}
+	}
+	_ = tw.Flush()
+	fmt.Fprintf(&buf, "\n%s: unhealthy nodes: %d\n", report.Time.Format(time.RFC3339), unhealthy)
+	_, _ = w.Write(buf.Bytes())
+	return unhealthy
+}
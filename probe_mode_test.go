@@ -0,0 +1,95 @@
+package blockchain_health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"go.uber.org/zap/zaptest"
+)
+
+// createCometBFTServer serves /health and /abci_info and fails /status
+func createCometBFTServer(t *testing.T, appHeight string, healthStatus int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.WriteHeader(healthStatus)
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":-1,"result":{}}`))
+		case "/abci_info":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":-1,"result":{"response":{"data":"app","last_block_height":"` + appHeight + `"}}}`))
+		default:
+			http.Error(w, "unexpected probe", http.StatusInternalServerError)
+		}
+	}))
+}
+
+func TestCosmosHandler_ProbeModes(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	tests := []struct {
+		name            string
+		mode            string
+		metadataMode    string
+		healthStatus    int
+		expectedHealthy bool
+		expectedHeight  uint64
+	}{
+		{name: "abci_info", mode: CosmosProbeABCIInfo, healthStatus: http.StatusOK, expectedHealthy: true, expectedHeight: 4200},
+		{name: "health", mode: CosmosProbeHealth, healthStatus: http.StatusOK, expectedHealthy: true, expectedHeight: 4200},
+		{name: "health failing", mode: CosmosProbeHealth, healthStatus: http.StatusInternalServerError, expectedHealthy: false},
+		{name: "metadata override", mode: CosmosProbeStatus, metadataMode: CosmosProbeABCIInfo, healthStatus: http.StatusOK, expectedHealthy: true, expectedHeight: 4200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := createCometBFTServer(t, "4200", tt.healthStatus)
+			defer server.Close()
+
+			handler := NewCosmosHandler(5*time.Second, logger)
+			handler.probeMode = tt.mode
+
+			node := NodeConfig{Name: "comet", URL: server.URL, Type: NodeTypeCosmos}
+			if tt.metadataMode != "" {
+				node.Metadata = map[string]string{"probe_mode": tt.metadataMode}
+			}
+
+			health, err := handler.CheckHealth(context.Background(), node)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if health.Healthy != tt.expectedHealthy {
+				t.Errorf("Expected healthy=%v, got %v (%s)", tt.expectedHealthy, health.Healthy, health.LastError)
+			}
+			if health.BlockHeight != tt.expectedHeight {
+				t.Errorf("Expected height=%d, got %d", tt.expectedHeight, health.BlockHeight)
+			}
+		})
+	}
+}
+
+func TestProbeModeCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		probe_mode abci_info
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if b.HealthCheck.ProbeMode != CosmosProbeABCIInfo {
+		t.Errorf("Expected probe mode abci_info, got %s", b.HealthCheck.ProbeMode)
+	}
+
+	d = caddyfile.NewTestDispenser(`blockchain_health {
+		probe_mode net_info
+	}`)
+	var bad BlockchainHealthUpstream
+	if err := bad.UnmarshalCaddyfile(d); err == nil {
+		t.Error("Expected error for unknown probe_mode")
+	}
+}
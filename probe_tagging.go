@@ -0,0 +1,76 @@
+package blockchain_health
+
+import (
+	"net/http"
+	"runtime/debug"
+	"sync"
+)
+
+// modulePath is this module's import path, used to find its version in the
+// build info
+const modulePath = "github.com/chalabi2/caddy-blockchain-health"
+
+// probeTagHeader marks health check requests so node operators can tell them
+// apart from client traffic
+const probeTagHeader = "X-Health-Check"
+
+// moduleVersion returns the version the module was built at, or "dev" for
+// local builds
+var moduleVersion = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+	version := info.Main.Version
+	if info.Main.Path != modulePath {
+		version = ""
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				version = dep.Version
+				if dep.Replace != nil && dep.Replace.Version != "" {
+					version = dep.Replace.Version
+				}
+				break
+			}
+		}
+	}
+	if version == "" || version == "(devel)" {
+		return "dev"
+	}
+	return version
+})
+
+// probeTag returns the value of the X-Health-Check header, which is also the
+// default User-Agent of health checks
+func probeTag() string {
+	return "caddy-blockchain-health/" + moduleVersion()
+}
+
+// taggingTransport adds the User-Agent and X-Health-Check headers to every
+// request sent through it
+type taggingTransport struct {
+	userAgent string
+	tag       string
+	next      http.RoundTripper
+}
+
+// RoundTrip tags a copy of the request, as round trippers must not modify
+// the request they are given
+func (t *taggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tagged := req.Clone(req.Context())
+	tagged.Header.Set("User-Agent", t.userAgent)
+	tagged.Header.Set(probeTagHeader, t.tag)
+	return t.next.RoundTrip(tagged)
+}
+
+// tagProbes tags the health check requests of every protocol handler, using
+// userAgent or the module's name and version if it is empty
+func (h *HealthChecker) tagProbes(userAgent string) {
+	tag := probeTag()
+	if userAgent == "" {
+		userAgent = tag
+	}
+	h.wrapProbeTransports(func(next http.RoundTripper) http.RoundTripper {
+		return &taggingTransport{userAgent: userAgent, tag: tag, next: next}
+	})
+}
@@ -0,0 +1,92 @@
+package blockchain_health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestProbeTagging_HealthChecksOnly(t *testing.T) {
+	var untagged atomic.Int32
+	var userAgent atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(probeTagHeader) != probeTag() {
+			untagged.Add(1)
+		}
+		userAgent.Store(r.Header.Get("User-Agent"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"result":{"sync_info":{"latest_block_height":"1000","catching_up":false}}}`))
+	}))
+	defer server.Close()
+
+	nodes := []NodeConfig{{Name: "tagged", URL: server.URL, Type: NodeTypeCosmos, Weight: 1}}
+	upstream := createTestUpstream(nodes, zaptest.NewLogger(t))
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	if _, err := upstream.GetUpstreams(req); err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+	if untagged.Load() != 0 {
+		t.Errorf("expected every health check to be tagged, %d were not", untagged.Load())
+	}
+	if got := userAgent.Load(); got != probeTag() {
+		t.Errorf("expected the default user agent %q, got %q", probeTag(), got)
+	}
+	if req.Header.Get(probeTagHeader) != "" {
+		t.Error("expected the proxied request not to be tagged")
+	}
+}
+
+func TestProbeTagging_CustomUserAgent(t *testing.T) {
+	var userAgent atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent.Store(r.Header.Get("User-Agent"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"result":{"sync_info":{"latest_block_height":"1000","catching_up":false}}}`))
+	}))
+	defer server.Close()
+
+	nodes := []NodeConfig{{Name: "tagged", URL: server.URL, Type: NodeTypeCosmos, Weight: 1}}
+	upstream := createTestUpstream(nodes, zaptest.NewLogger(t))
+	upstream.config.HealthCheck.UserAgent = "acme-prober/1.0"
+	checker := NewHealthChecker(upstream.config, NewHealthCache(time.Second), NewMetrics(), zaptest.NewLogger(t))
+	defer checker.Stop()
+
+	if _, err := checker.CheckAllNodes(t.Context()); err != nil {
+		t.Fatalf("CheckAllNodes failed: %v", err)
+	}
+	if got := userAgent.Load(); got != "acme-prober/1.0" {
+		t.Errorf("expected the configured user agent, got %q", got)
+	}
+}
+
+func TestProbeTagging_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:26657
+			type cosmos
+		}
+		user_agent "acme-prober/1.0 (+https://acme.example)"
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if b.HealthCheck.UserAgent != "acme-prober/1.0 (+https://acme.example)" {
+		t.Errorf("unexpected user agent %q", b.HealthCheck.UserAgent)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+
+	b.HealthCheck.UserAgent = "bad\r\nX-Injected: 1"
+	if err := b.validate(); err == nil {
+		t.Error("expected a user agent with a line break to be rejected")
+	}
+}
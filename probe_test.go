@@ -0,0 +1,105 @@
+package blockchain_health
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig"
+)
+
+func TestProbePools(t *testing.T) {
+	leader := createEVMServer(t, 1000, false)
+	defer leader.Close()
+	lagging := createEVMServer(t, 900, false)
+	defer lagging.Close()
+
+	site := fmt.Sprintf(`dynamic blockchain_health {
+				node leader {
+					url %s
+					type evm
+					chain_type ethereum
+				}
+				node lagging {
+					url %s
+					type evm
+					chain_type ethereum
+				}
+				chain_type ethereum
+			}`, leader.URL, lagging.URL)
+	config := fmt.Sprintf(`{
+		blockchain_health_defaults {
+			timeout 3s
+		}
+		blockchain_health {
+			pool evm-main {
+				node a {
+					url %s
+					type evm
+				}
+			}
+		}
+	}
+
+	:8080 {
+		reverse_proxy /a/* {
+			%s
+		}
+		reverse_proxy /b/* {
+			%s
+		}
+		reverse_proxy /c/* {
+			dynamic blockchain_health pool=evm-main
+		}
+	}`, leader.URL, site, site)
+	adapted, _, err := caddyconfig.GetAdapter("caddyfile").Adapt([]byte(config), nil)
+	if err != nil {
+		t.Fatalf("adapting Caddyfile: %v", err)
+	}
+
+	pools, err := probePools(adapted)
+	if err != nil {
+		t.Fatalf("probePools failed: %v", err)
+	}
+	if len(pools) != 2 || pools[0].name != "evm-main" || pools[1].name != "ethereum" {
+		t.Fatalf("expected the named pool and one inline pool, got %+v", pools)
+	}
+	for _, pool := range pools {
+		if pool.upstream.HealthCheck.Timeout != "3s" {
+			t.Errorf("%s: expected the default timeout, got %q", pool.name, pool.upstream.HealthCheck.Timeout)
+		}
+	}
+
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	defer cancel()
+	for _, pool := range pools {
+		if err := pool.upstream.Provision(ctx); err != nil {
+			t.Fatalf("provisioning %s: %v", pool.name, err)
+		}
+		defer func() { _ = pool.upstream.Cleanup() }()
+	}
+
+	var table bytes.Buffer
+	if unhealthy := runProbe(t.Context(), pools, &table, false); unhealthy != 1 {
+		t.Errorf("expected the lagging node to be unhealthy, got %d unhealthy nodes", unhealthy)
+	}
+	for _, want := range []string{"POOL", "evm-main", "leader", "lagging", "unhealthy", "unhealthy nodes: 1"} {
+		if !strings.Contains(table.String(), want) {
+			t.Errorf("expected %q in the table:\n%s", want, table.String())
+		}
+	}
+
+	var out bytes.Buffer
+	runProbe(t.Context(), pools, &out, true)
+	var report probeReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("decoding JSON output: %v", err)
+	}
+	if len(report.Pools) != 2 || report.Pools[1].State != PoolDegraded || len(report.Pools[1].Nodes) != 2 {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
@@ -0,0 +1,84 @@
+package blockchain_health
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// cosmosBlockHeightHeader is the gRPC-gateway header Cosmos REST uses to
+// query state at a specific height
+const cosmosBlockHeightHeader = "X-Cosmos-Block-Height"
+
+// cosmosBlockPathPattern matches Cosmos REST block queries such as
+// /blocks/{height} and /cosmos/base/tendermint/v1beta1/blocks/{height}
+var cosmosBlockPathPattern = regexp.MustCompile(`/blocks/(\d+)/?$`)
+
+// requestedBlockHeight returns the historical height a Cosmos REST request
+// targets, or 0 if the request is not tied to a specific height
+func requestedBlockHeight(r *http.Request) uint64 {
+	if r == nil {
+		return 0
+	}
+
+	if header := headerValue(r.Header, cosmosBlockHeightHeader); header != "" {
+		if height, err := strconv.ParseUint(header, 10, 64); err == nil {
+			return height
+		}
+	}
+
+	if r.URL == nil || !strings.Contains(r.URL.Path, "/blocks/") {
+		return 0
+	}
+	if match := cosmosBlockPathPattern.FindStringSubmatch(r.URL.Path); match != nil {
+		if height, err := strconv.ParseUint(match[1], 10, 64); err == nil {
+			return height
+		}
+	}
+	return 0
+}
+
+// requestedHistoricalHeight returns the oldest block a request reads, so it
+// is only routed to nodes that still have it: a Cosmos REST height, or the
+// block parameters of JSON-RPC calls such as eth_getBlockByNumber, eth_call
+// and eth_getBalance. Block tags tie a call to no particular height. The body
+// is only read while some node has a known pruning horizon.
+func requestedHistoricalHeight(r *http.Request, results []*NodeHealth) uint64 {
+	if height := requestedBlockHeight(r); height > 0 {
+		return height
+	}
+	if r == nil || r.Method != http.MethodPost {
+		return 0
+	}
+	pruned := false
+	for _, health := range results {
+		pruned = pruned || health.EarliestBlockHeight > 0
+	}
+	if !pruned {
+		return 0
+	}
+
+	body, _, err := readRPCBody(r, 0)
+	if err != nil {
+		return 0
+	}
+	calls, _, err := parseRPCCalls(body)
+	if err != nil {
+		return 0
+	}
+	var oldest uint64
+	for _, call := range calls {
+		// Tags resolve against a head of 0, as they read no particular block
+		if height, ok := callHeight(call, 0); ok && height > 0 && (oldest == 0 || height < oldest) {
+			oldest = height
+		}
+	}
+	return oldest
+}
+
+// servesHeight reports whether a node still has the given height, based on
+// its pruning horizon. Nodes with an unknown horizon are assumed to serve it.
+func (n *NodeHealth) servesHeight(height uint64) bool {
+	return height == 0 || n.EarliestBlockHeight == 0 || height >= n.EarliestBlockHeight
+}
@@ -0,0 +1,161 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// createPrunedCosmosServer serves /status with an earliest_block_height
+func createPrunedCosmosServer(t *testing.T, latest, earliest uint64) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/status" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"result":{"sync_info":{"latest_block_height":"%d","earliest_block_height":"%d","catching_up":false}}}`, latest, earliest)
+	}))
+}
+
+func TestRequestedBlockHeight(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		header   string
+		expected uint64
+	}{
+		{name: "legacy blocks path", path: "/blocks/1200", expected: 1200},
+		{name: "tendermint service path", path: "/cosmos/base/tendermint/v1beta1/blocks/77", expected: 77},
+		{name: "latest block", path: "/cosmos/base/tendermint/v1beta1/blocks/latest", expected: 0},
+		{name: "height header", path: "/cosmos/bank/v1beta1/balances/addr", header: "500", expected: 500},
+		{name: "unrelated path", path: "/status", expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &http.Request{URL: &url.URL{Path: tt.path}, Header: http.Header{}}
+			if tt.header != "" {
+				r.Header.Set(cosmosBlockHeightHeader, tt.header)
+			}
+			if got := requestedBlockHeight(r); got != tt.expected {
+				t.Errorf("Expected height %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestPrunedNodesSkippedForHistoricalQueries(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	archive := createPrunedCosmosServer(t, 10000, 1)
+	pruned := createPrunedCosmosServer(t, 10000, 9000)
+	defer archive.Close()
+	defer pruned.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "archive", URL: archive.URL, Type: NodeTypeCosmos, ChainType: "test-cosmos", Weight: 100},
+		{Name: "pruned", URL: pruned.URL, Type: NodeTypeCosmos, ChainType: "test-cosmos", Weight: 100},
+	}, logger)
+
+	// Historical block below the pruned node's horizon only goes to the archive node
+	historical := &http.Request{URL: &url.URL{Path: "/blocks/5000"}, Header: http.Header{}}
+	upstreams, err := upstream.GetUpstreams(historical)
+	if err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+	if len(upstreams) != 1 || upstreams[0].Dial != getDynamicTestHostFromURL(archive.URL) {
+		t.Errorf("Expected only the archive node for a historical query, got %v", upstreams)
+	}
+
+	// Recent blocks can be served by both nodes
+	recent := &http.Request{URL: &url.URL{Path: "/blocks/9500"}, Header: http.Header{}}
+	upstreams, err = upstream.GetUpstreams(recent)
+	if err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+	if len(upstreams) != 2 {
+		t.Errorf("Expected 2 upstreams for a recent query, got %d", len(upstreams))
+	}
+
+	health := upstream.healthChecker.cache.Get(upstream.healthChecker.nodeKey("pruned"))
+	if health == nil || !health.Pruned || health.EarliestBlockHeight != 9000 {
+		t.Errorf("Expected pruned node with horizon 9000, got %+v", health)
+	}
+}
+
+func TestAllHealthyNodesPrunedFailsHistoricalQuery(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	pruned := createPrunedCosmosServer(t, 10000, 9000)
+	defer pruned.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "pruned", URL: pruned.URL, Type: NodeTypeCosmos, ChainType: "test-cosmos", Weight: 100},
+		{Name: "down", URL: "http://127.0.0.1:1", Type: NodeTypeCosmos, ChainType: "test-cosmos", Weight: 100},
+	}, logger)
+
+	// The unreachable node must not be used as a fallback for the historical query
+	historical := &http.Request{URL: &url.URL{Path: "/blocks/5000"}, Header: http.Header{}}
+	upstreams, err := upstream.GetUpstreams(historical)
+	if err == nil {
+		t.Fatalf("Expected an error when no healthy node serves the height, got %v", upstreams)
+	}
+}
+
+func TestPrunedEVMNodesSkippedForHistoricalCalls(t *testing.T) {
+	archive := createEVMServer(t, 1000, false)
+	defer archive.Close()
+	pruned := createEVMServer(t, 1000, false)
+	defer pruned.Close()
+
+	nodes := []NodeConfig{
+		{Name: "archive", URL: archive.URL, Type: NodeTypeEVM, Weight: 100},
+		{Name: "pruned", URL: pruned.URL, Type: NodeTypeEVM, Weight: 100},
+	}
+	upstream := createTestUpstream(nodes, zaptest.NewLogger(t))
+	upstream.config.BlockValidation.TrackEarliestBlock = true
+
+	// The pruned node's horizon is already probed
+	checker := upstream.healthChecker
+	checker.mutex.Lock()
+	checker.earliestBlocks[nodes[0].key()] = &earliestBlockState{known: true, nextProbe: time.Now().Add(time.Hour)}
+	checker.earliestBlocks[nodes[1].key()] = &earliestBlockState{height: 900, known: true, nextProbe: time.Now().Add(time.Hour)}
+	checker.mutex.Unlock()
+
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{"block below the horizon", `{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["0x64",false]}`, 1},
+		{"state below the horizon", `{"jsonrpc":"2.0","id":1,"method":"eth_getBalance","params":["0xabc","0x64"]}`, 1},
+		{"call below the horizon", `{"jsonrpc":"2.0","id":1,"method":"eth_call","params":[{"to":"0xabc"},"0x64"]}`, 1},
+		{"batch with one old call", `[{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]},{"jsonrpc":"2.0","id":2,"method":"eth_getCode","params":["0xabc","0x1"]}]`, 1},
+		{"block within the horizon", `{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["0x3e0",false]}`, 2},
+		{"latest block", `{"jsonrpc":"2.0","id":1,"method":"eth_call","params":[{"to":"0xabc"},"latest"]}`, 2},
+		{"no block parameter", `{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]}`, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			upstreams, err := upstream.GetUpstreams(r)
+			if err != nil {
+				t.Fatalf("GetUpstreams failed: %v", err)
+			}
+			if len(upstreams) != tt.expected {
+				t.Fatalf("Expected %d upstreams, got %d", tt.expected, len(upstreams))
+			}
+			if tt.expected == 1 && upstreams[0].Dial != getDynamicTestHostFromURL(archive.URL) {
+				t.Errorf("Expected only the archive node, got %s", upstreams[0].Dial)
+			}
+		})
+	}
+}
@@ -0,0 +1,365 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"go.uber.org/zap"
+)
+
+// Flap quarantine defaults
+const (
+	defaultFlapMaxTransitions = 5
+	defaultFlapWindow         = 10 * time.Minute
+	defaultFlapCooldown       = 30 * time.Minute
+)
+
+// quarantineState tracks a node's recent health transitions and its
+// quarantine
+type quarantineState struct {
+	transitions []time.Time // within the window, oldest first
+	until       time.Time   // zero while the node is not quarantined
+	reason      string
+}
+
+// quarantinedNode is the admin API representation of a quarantined node
+type quarantinedNode struct {
+	Node   string    `json:"node"`
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason"`
+}
+
+// validateFlapQuarantine checks the flap_quarantine section
+func (b *BlockchainHealthUpstream) validateFlapQuarantine() error {
+	if b.FlapQuarantine.MaxTransitions < 0 {
+		return fmt.Errorf("flap_quarantine max_transitions must not be negative")
+	}
+	for name, value := range map[string]string{"window": b.FlapQuarantine.Window, "cooldown": b.FlapQuarantine.Cooldown} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			return fmt.Errorf("invalid flap_quarantine %s: %s", name, value)
+		}
+	}
+	return nil
+}
+
+// flapWindow returns the window transitions are counted over
+func (h *HealthChecker) flapWindow() time.Duration {
+	if d, err := time.ParseDuration(h.config.FlapQuarantine.Window); err == nil && d > 0 {
+		return d
+	}
+	return defaultFlapWindow
+}
+
+// flapCooldown returns how long a flapping node is quarantined
+func (h *HealthChecker) flapCooldown() time.Duration {
+	if d, err := time.ParseDuration(h.config.FlapQuarantine.Cooldown); err == nil && d > 0 {
+		return d
+	}
+	return defaultFlapCooldown
+}
+
+// getQuarantineState gets or creates the quarantine state of a node. The
+// caller holds h.mutex.
+func (h *HealthChecker) getQuarantineState(nodeName string) *quarantineState {
+	key := h.nodeKey(nodeName)
+	state, exists := h.quarantines[key]
+	if !exists {
+		state = &quarantineState{}
+		h.quarantines[key] = state
+	}
+	return state
+}
+
+// trackFlapping records the health transitions of the changed nodes and
+// quarantines those with more than max_transitions within the window.
+// Transitions of quarantined nodes are not counted.
+func (h *HealthChecker) trackFlapping(changed []*NodeHealth, now time.Time) {
+	if !h.config.FlapQuarantine.Enabled || len(changed) == 0 {
+		return
+	}
+	maxTransitions := h.config.FlapQuarantine.MaxTransitions
+	if maxTransitions <= 0 {
+		maxTransitions = defaultFlapMaxTransitions
+	}
+	oldest := now.Add(-h.flapWindow())
+	until := now.Add(h.flapCooldown())
+
+	type flapping struct {
+		node        string
+		transitions int
+	}
+	var quarantined []flapping
+
+	h.mutex.Lock()
+	for _, health := range changed {
+		state := h.getQuarantineState(health.Name)
+		if !state.until.IsZero() {
+			continue
+		}
+
+		kept := state.transitions[:0]
+		for _, at := range state.transitions {
+			if at.After(oldest) {
+				kept = append(kept, at)
+			}
+		}
+		state.transitions = append(kept, now)
+
+		if len(state.transitions) > maxTransitions {
+			quarantined = append(quarantined, flapping{node: health.Name, transitions: len(state.transitions)})
+			state.transitions = nil
+			state.until = until
+			state.reason = "flapping"
+			h.quarantineVersion.Add(1)
+		}
+	}
+	h.mutex.Unlock()
+
+	for _, q := range quarantined {
+		h.logger.Warn("node quarantined for flapping",
+			zap.String("node", q.node),
+			zap.Int("transitions", q.transitions),
+			zap.Duration("window", h.flapWindow()),
+			zap.Time("until", until))
+		h.emit(eventNodeQuarantined, map[string]any{
+			"pool":        h.poolName(),
+			"node":        q.node,
+			"reason":      "flapping",
+			"transitions": q.transitions,
+			"until":       until,
+		})
+	}
+}
+
+// applyQuarantine releases the nodes whose cool-down elapsed and exports the
+// quarantine of the named nodes. Results published in the cache are shared
+// with requests, so the quarantine is kept in h.quarantines rather than
+// marked on them.
+func (h *HealthChecker) applyQuarantine(names []string, now time.Time) {
+	var released []string
+
+	h.mutex.Lock()
+	for _, name := range names {
+		state, exists := h.quarantines[h.nodeKey(name)]
+		quarantined := exists && !state.until.IsZero()
+		if quarantined && !now.Before(state.until) {
+			state.until, state.reason = time.Time{}, ""
+			released = append(released, name)
+			h.quarantineVersion.Add(1)
+			quarantined = false
+		}
+		if h.metrics != nil {
+			value := 0.0
+			if quarantined {
+				value = 1
+			}
+			h.metrics.nodeQuarantined.WithLabelValues(name).Set(value)
+		}
+	}
+	h.mutex.Unlock()
+
+	for _, node := range released {
+		h.logger.Info("node released from quarantine", zap.String("node", node))
+		h.emit(eventNodeReleased, map[string]any{"pool": h.poolName(), "node": node, "reason": "cooldown"})
+	}
+}
+
+// isQuarantined reports whether a node is quarantined, so requests skip it
+func (h *HealthChecker) isQuarantined(name string) bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	state, exists := h.quarantines[h.nodeKey(name)]
+	return exists && !state.until.IsZero()
+}
+
+// hasNode reports whether the pool has a node of that name
+func (h *HealthChecker) hasNode(name string) bool {
+	return findNode(h.config.nodeList(), name) != nil
+}
+
+// quarantineNode quarantines a node for the cool-down, overriding the flap
+// detection
+func (h *HealthChecker) quarantineNode(name string, now time.Time) error {
+	if !h.hasNode(name) {
+		return fmt.Errorf("unknown node %q", name)
+	}
+	until := now.Add(h.flapCooldown())
+	h.mutex.Lock()
+	state := h.getQuarantineState(name)
+	state.transitions = nil
+	state.until, state.reason = until, "admin"
+	h.quarantineVersion.Add(1)
+	h.mutex.Unlock()
+
+	h.logger.Warn("node quarantined through the admin API", zap.String("node", name), zap.Time("until", until))
+	h.emit(eventNodeQuarantined, map[string]any{"pool": h.poolName(), "node": name, "reason": "admin", "until": until})
+	h.refreshQuarantine(now)
+	return nil
+}
+
+// releaseNode releases a node from quarantine and clears its transitions
+func (h *HealthChecker) releaseNode(name string, now time.Time) error {
+	if !h.hasNode(name) {
+		return fmt.Errorf("unknown node %q", name)
+	}
+	h.mutex.Lock()
+	state := h.getQuarantineState(name)
+	wasQuarantined := !state.until.IsZero()
+	state.transitions = nil
+	state.until, state.reason = time.Time{}, ""
+	h.quarantineVersion.Add(1)
+	h.mutex.Unlock()
+
+	if wasQuarantined {
+		h.logger.Info("node released from quarantine through the admin API", zap.String("node", name))
+		h.emit(eventNodeReleased, map[string]any{"pool": h.poolName(), "node": name, "reason": "admin"})
+	}
+	h.refreshQuarantine(now)
+	return nil
+}
+
+// refreshQuarantine releases the nodes whose cool-down elapsed and exports
+// the quarantine of every node, so admin changes show before the next check
+func (h *HealthChecker) refreshQuarantine(now time.Time) {
+	nodes := h.config.nodeList()
+	names := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		names = append(names, node.Name)
+	}
+	h.applyQuarantine(names, now)
+}
+
+// quarantinedNodes returns the quarantined nodes, sorted by name
+func (h *HealthChecker) quarantinedNodes() []quarantinedNode {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	nodes := []quarantinedNode{}
+	for _, node := range h.config.nodeList() {
+		if state, exists := h.quarantines[h.nodeKey(node.Name)]; exists && !state.until.IsZero() {
+			nodes = append(nodes, quarantinedNode{Node: node.Name, Until: state.until, Reason: state.reason})
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Node < nodes[j].Node })
+	return nodes
+}
+
+// adminQuarantinePath is the admin API path of the quarantined nodes
+const adminQuarantinePath = "/blockchain_health/quarantine/"
+
+// poolQuarantine is the admin API representation of a pool's quarantined
+// nodes
+type poolQuarantine struct {
+	Pool  string            `json:"pool"`
+	Nodes []quarantinedNode `json:"nodes"`
+}
+
+// upstreams returns the pools of the app by pool name. Inline pools are named
+// after their pool state name.
+func (a *App) upstreams() map[string][]*BlockchainHealthUpstream {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	upstreams := make(map[string][]*BlockchainHealthUpstream)
+	for _, name := range a.poolNames() {
+		upstreams[name] = append(upstreams[name], a.Pools[name])
+	}
+	for _, pool := range a.inline {
+		upstreams[pool.poolStateName()] = append(upstreams[pool.poolStateName()], pool)
+	}
+	return upstreams
+}
+
+// healthCheckers returns the health checkers of the pools of the app by pool
+// name
+func (a *App) healthCheckers() map[string][]*HealthChecker {
+	checkers := make(map[string][]*HealthChecker)
+	for name, pools := range a.upstreams() {
+		for _, pool := range pools {
+			if pool.healthChecker != nil {
+				checkers[name] = append(checkers[name], pool.healthChecker)
+			}
+		}
+	}
+	return checkers
+}
+
+// handleQuarantine serves the quarantine routes. Nodes quarantined through
+// the API sit out the cool-down; released nodes start counting transitions
+// afresh.
+func (a AdminAPI) handleQuarantine(w http.ResponseWriter, r *http.Request) error {
+	checkers := make(map[string][]*HealthChecker)
+	activeApps.Range(func(key, _ any) bool {
+		for pool, found := range key.(*App).healthCheckers() {
+			checkers[pool] = append(checkers[pool], found...)
+		}
+		return true
+	})
+	return a.serveQuarantine(w, r, checkers)
+}
+
+// serveQuarantine serves a quarantine route for the health checkers of the
+// pools by name
+func (a AdminAPI) serveQuarantine(w http.ResponseWriter, r *http.Request, checkers map[string][]*HealthChecker) error {
+	name, node, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, adminQuarantinePath), "/"), "/")
+
+	if name == "" {
+		if r.Method != http.MethodGet {
+			return caddy.APIError{HTTPStatus: http.StatusMethodNotAllowed, Err: fmt.Errorf("method %s not allowed", r.Method)}
+		}
+		pools := []poolQuarantine{}
+		for pool, found := range checkers {
+			pools = append(pools, poolQuarantineState(pool, found))
+		}
+		sort.Slice(pools, func(i, j int) bool { return pools[i].Pool < pools[j].Pool })
+		return writeAdminJSON(w, pools)
+	}
+
+	found, ok := checkers[name]
+	if !ok {
+		return caddy.APIError{HTTPStatus: http.StatusNotFound, Err: fmt.Errorf("unknown pool %q", name)}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		// Show the quarantine as it is
+
+	case http.MethodPut, http.MethodPost, http.MethodDelete:
+		if node == "" {
+			return caddy.APIError{HTTPStatus: http.StatusBadRequest, Err: fmt.Errorf("a node is quarantined or released by name")}
+		}
+		now := time.Now()
+		for _, checker := range found {
+			var err error
+			if r.Method == http.MethodDelete {
+				err = checker.releaseNode(node, now)
+			} else {
+				err = checker.quarantineNode(node, now)
+			}
+			if err != nil {
+				return caddy.APIError{HTTPStatus: http.StatusNotFound, Err: err}
+			}
+		}
+
+	default:
+		return caddy.APIError{HTTPStatus: http.StatusMethodNotAllowed, Err: fmt.Errorf("method %s not allowed", r.Method)}
+	}
+
+	return writeAdminJSON(w, poolQuarantineState(name, found))
+}
+
+// poolQuarantineState lists the quarantined nodes of the pools of one name
+func poolQuarantineState(pool string, checkers []*HealthChecker) poolQuarantine {
+	state := poolQuarantine{Pool: pool, Nodes: []quarantinedNode{}}
+	for _, checker := range checkers {
+		state.Nodes = append(state.Nodes, checker.quarantinedNodes()...)
+	}
+	return state
+}
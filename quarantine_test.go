@@ -0,0 +1,165 @@
+package blockchain_health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestFlapQuarantine(t *testing.T) {
+	flapping := createCosmosServer(t, 1000, false)
+	defer flapping.Close()
+	stable := createCosmosServer(t, 1000, false)
+	defer stable.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "flapping", URL: flapping.URL, Type: NodeTypeCosmos, Weight: 1},
+		{Name: "stable", URL: stable.URL, Type: NodeTypeCosmos, Weight: 1},
+	}, zaptest.NewLogger(t))
+	upstream.config.FlapQuarantine = FlapQuarantineConfig{Enabled: true, MaxTransitions: 2, Window: "10m", Cooldown: "30m"}
+	h := upstream.healthChecker
+	defer h.Stop()
+	if _, err := h.CheckAllNodes(context.Background()); err != nil {
+		t.Fatalf("CheckAllNodes failed: %v", err)
+	}
+
+	dials := func() []string {
+		t.Helper()
+		upstreams, err := upstream.GetUpstreams(httptest.NewRequest(http.MethodGet, "/status", nil))
+		if err != nil {
+			t.Fatalf("GetUpstreams failed: %v", err)
+		}
+		var dials []string
+		for _, up := range upstreams {
+			dials = append(dials, up.Dial)
+		}
+		return dials
+	}
+
+	// Transitions that fell out of the window don't count
+	start := time.Now()
+	changed := []*NodeHealth{{Name: "flapping"}}
+	h.trackFlapping(changed, start.Add(-time.Hour))
+	h.trackFlapping(changed, start)
+	h.trackFlapping(changed, start.Add(time.Minute))
+	if nodes := h.quarantinedNodes(); len(nodes) != 0 {
+		t.Fatalf("expected no quarantine within max_transitions, got %+v", nodes)
+	}
+
+	h.trackFlapping(changed, start.Add(2*time.Minute))
+	nodes := h.quarantinedNodes()
+	if len(nodes) != 1 || nodes[0].Node != "flapping" || nodes[0].Reason != "flapping" ||
+		!nodes[0].Until.Equal(start.Add(32*time.Minute)) {
+		t.Fatalf("expected the flapping node to be quarantined for the cool-down, got %+v", nodes)
+	}
+
+	// A quarantined node is not routed to, though healthy
+	h.refreshQuarantine(start.Add(2 * time.Minute))
+	if got := dials(); len(got) != 1 || got[0] != getDynamicTestHostFromURL(stable.URL) {
+		t.Errorf("expected only the stable node, got %v", got)
+	}
+	if value := testutil.ToFloat64(h.metrics.nodeQuarantined.WithLabelValues("flapping")); value != 1 {
+		t.Errorf("expected the quarantine gauge to be 1, got %v", value)
+	}
+
+	// The node is released once the cool-down elapsed
+	h.refreshQuarantine(start.Add(33 * time.Minute))
+	if got := dials(); len(got) != 2 {
+		t.Errorf("expected both nodes after the cool-down, got %v", got)
+	}
+	if nodes := h.quarantinedNodes(); len(nodes) != 0 {
+		t.Errorf("expected no quarantined nodes, got %+v", nodes)
+	}
+}
+
+func TestQuarantine_AdminOverride(t *testing.T) {
+	server := createCosmosServer(t, 1000, false)
+	defer server.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "node", URL: server.URL, Type: NodeTypeCosmos, Weight: 1},
+	}, zaptest.NewLogger(t))
+	h := upstream.healthChecker
+	defer h.Stop()
+	if _, err := h.CheckAllNodes(context.Background()); err != nil {
+		t.Fatalf("CheckAllNodes failed: %v", err)
+	}
+
+	checkers := []*HealthChecker{h}
+	handle := func(method, target string) (int, poolQuarantine) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		err := AdminAPI{}.serveQuarantine(w, httptest.NewRequest(method, target, nil), map[string][]*HealthChecker{"default": checkers})
+		var apiErr caddy.APIError
+		if errors.As(err, &apiErr) {
+			return apiErr.HTTPStatus, poolQuarantine{}
+		}
+		var state poolQuarantine
+		if err := json.Unmarshal(w.Body.Bytes(), &state); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return http.StatusOK, state
+	}
+
+	// Quarantining applies right away, without a check
+	if _, state := handle(http.MethodPut, adminQuarantinePath+"default/node"); len(state.Nodes) != 1 || state.Nodes[0].Reason != "admin" {
+		t.Fatalf("expected the node to be quarantined, got %+v", state)
+	}
+	if !h.isQuarantined("node") {
+		t.Error("expected the node to be quarantined")
+	}
+
+	if _, state := handle(http.MethodDelete, adminQuarantinePath+"default/node"); len(state.Nodes) != 0 {
+		t.Errorf("expected the node to be released, got %+v", state)
+	}
+	if h.isQuarantined("node") {
+		t.Error("expected the node to be released")
+	}
+
+	if status, _ := handle(http.MethodPut, adminQuarantinePath+"default/missing"); status != http.StatusNotFound {
+		t.Errorf("expected an unknown node to be refused, got %d", status)
+	}
+	if status, _ := handle(http.MethodGet, adminQuarantinePath+"other"); status != http.StatusNotFound {
+		t.Errorf("expected an unknown pool to be refused, got %d", status)
+	}
+}
+
+func TestFlapQuarantine_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:26657
+			type cosmos
+		}
+		flap_quarantine {
+			max_transitions 8
+			window 15m
+			cooldown 1h
+		}
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	want := FlapQuarantineConfig{Enabled: true, MaxTransitions: 8, Window: "15m", Cooldown: "1h"}
+	if b.FlapQuarantine != want {
+		t.Errorf("expected %+v, got %+v", want, b.FlapQuarantine)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+
+	b.FlapQuarantine.Cooldown = "soon"
+	if err := b.validate(); err == nil {
+		t.Error("expected an invalid cooldown to be rejected")
+	}
+}
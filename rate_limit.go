@@ -0,0 +1,268 @@
+package blockchain_health
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// rpcCodeLimitExceeded is the JSON-RPC error code for rate limited calls (EIP-1474)
+const rpcCodeLimitExceeded = -32005
+
+// defaultRateLimitClass applies to methods no other class lists
+const defaultRateLimitClass = "default"
+
+// rateLimitSweepInterval is how often idle client buckets are dropped
+const rateLimitSweepInterval = time.Minute
+
+// RateLimitClass is a group of methods sharing a token bucket per client
+type RateLimitClass struct {
+	Name    string   `json:"name"`
+	Methods []string `json:"methods,omitempty"`
+	Rate    float64  `json:"rate"`  // tokens refilled per second
+	Burst   int      `json:"burst"` // bucket size
+}
+
+// RateLimit is a middleware placed in front of reverse_proxy that rate limits
+// JSON-RPC calls per client and method class, so expensive methods such as
+// eth_getLogs can be throttled independently of cheap ones. Clients are
+// identified by KeyFrom (e.g. an API key header) or else by their IP.
+type RateLimit struct {
+	KeyFrom []Source         `json:"key_from,omitempty"`
+	Classes []RateLimitClass `json:"classes,omitempty"`
+
+	mutex     sync.Mutex
+	buckets   map[string]*tokenBucket // class + client key -> bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// tokenBucket holds the tokens left for one client and class
+type tokenBucket struct {
+	class   *RateLimitClass
+	tokens  float64
+	updated time.Time
+}
+
+func init() {
+	caddy.RegisterModule(&RateLimit{})
+}
+
+var rlMetrics *RateLimitMetrics
+
+// CaddyModule returns the Caddy module information.
+func (*RateLimit) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.blockchain_rate_limit",
+		New: func() caddy.Module { return new(RateLimit) },
+	}
+}
+
+// Provision registers metrics
+func (h *RateLimit) Provision(ctx caddy.Context) error {
+	var registerer prometheus.Registerer
+	if reg := ctx.GetMetricsRegistry(); reg != nil {
+		registerer = reg
+	} else {
+		registerer = prometheus.DefaultRegisterer
+	}
+	metrics, err := acquireRateLimitMetrics(registerer)
+	if err != nil {
+		return err
+	}
+	rlMetrics = metrics
+	return nil
+}
+
+// Validate checks configuration correctness
+func (h *RateLimit) Validate() error {
+	if len(h.Classes) == 0 {
+		return fmt.Errorf("at least one class is required")
+	}
+	seen := make(map[string]bool)
+	for _, class := range h.Classes {
+		if class.Name == "" {
+			return fmt.Errorf("class name is required")
+		}
+		if seen[class.Name] {
+			return fmt.Errorf("duplicate class %q", class.Name)
+		}
+		seen[class.Name] = true
+		if class.Rate <= 0 {
+			return fmt.Errorf("class %q: rate must be positive", class.Name)
+		}
+		if class.Burst < 1 {
+			return fmt.Errorf("class %q: burst must be at least 1", class.Name)
+		}
+		if class.Name != defaultRateLimitClass && len(class.Methods) == 0 {
+			return fmt.Errorf("class %q: methods are required", class.Name)
+		}
+		if err := validateMethodPatterns(class.Methods); err != nil {
+			return fmt.Errorf("class %q: %w", class.Name, err)
+		}
+	}
+	for i, s := range h.KeyFrom {
+		switch s.Type {
+		case "placeholder", "header", "query":
+		default:
+			return fmt.Errorf("key_from[%d]: invalid type %q, must be placeholder, header, or query", i, s.Type)
+		}
+	}
+	return nil
+}
+
+// ServeHTTP charges the request's calls to the client's buckets and answers
+// 429 with a JSON-RPC error when a class is exhausted
+func (h *RateLimit) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	if websocket.IsWebSocketUpgrade(r) {
+		return next.ServeHTTP(w, r)
+	}
+
+	// Requests that are not JSON-RPC count as one call of the default class
+	methods := []string{""}
+	var id []byte
+	if r.Method == http.MethodPost {
+		body, _, err := readRPCBody(r, 0)
+		if err != nil {
+			return caddyhttp.Error(http.StatusBadRequest, err)
+		}
+		calls, batch, err := parseRPCCalls(body)
+		if errors.Is(err, errInvalidRPC) {
+			return writeInvalidRPC(w)
+		}
+		if err == nil && len(calls) > 0 {
+			methods = methods[:0]
+			for _, call := range calls {
+				methods = append(methods, call.Method)
+			}
+			if !batch {
+				id = calls[0].ID
+			}
+		}
+	}
+
+	costs := make(map[*RateLimitClass]int)
+	for _, method := range methods {
+		if class := h.classFor(method); class != nil {
+			costs[class]++
+		}
+	}
+	if len(costs) == 0 {
+		return next.ServeHTTP(w, r)
+	}
+
+	class, wait := h.take(h.clientKey(r), costs)
+	if class == nil {
+		return next.ServeHTTP(w, r)
+	}
+
+	if rlMetrics != nil {
+		rlMetrics.rejectedTotal.WithLabelValues(class.Name).Inc()
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	return writeRPCError(w, http.StatusTooManyRequests, id, rpcCodeLimitExceeded,
+		fmt.Sprintf("rate limit exceeded for %s methods", class.Name))
+}
+
+// classFor returns the class a method belongs to, or nil if it is unlimited
+func (h *RateLimit) classFor(method string) *RateLimitClass {
+	var fallback *RateLimitClass
+	for i := range h.Classes {
+		class := &h.Classes[i]
+		if class.Name == defaultRateLimitClass && len(class.Methods) == 0 {
+			fallback = class
+			continue
+		}
+		if _, ok := matchRPCMethod(class.Methods, method); ok {
+			return class
+		}
+	}
+	return fallback
+}
+
+// clientKey identifies the client by its configured key, or its IP
+func (h *RateLimit) clientKey(r *http.Request) string {
+	if key := resolveSource(r, h.KeyFrom); key != "" {
+		return "key:" + key
+	}
+	if ip, ok := caddyhttp.GetVar(r.Context(), caddyhttp.ClientIPVarKey).(string); ok && ip != "" {
+		return "ip:" + ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// take charges every class its cost only if all of them have enough tokens.
+// Otherwise it returns the exhausted class and how long until it refills.
+func (h *RateLimit) take(client string, costs map[*RateLimitClass]int) (*RateLimitClass, time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	now := time.Now()
+	if h.now != nil {
+		now = h.now()
+	}
+	if h.buckets == nil {
+		h.buckets = make(map[string]*tokenBucket)
+	}
+	h.sweep(now)
+
+	buckets := make(map[*RateLimitClass]*tokenBucket, len(costs))
+	for class, cost := range costs {
+		key := class.Name + "|" + client
+		bucket, ok := h.buckets[key]
+		if !ok {
+			bucket = &tokenBucket{class: class, tokens: float64(class.Burst), updated: now}
+			h.buckets[key] = bucket
+		}
+		bucket.tokens = math.Min(float64(class.Burst), bucket.tokens+now.Sub(bucket.updated).Seconds()*class.Rate)
+		bucket.updated = now
+
+		if bucket.tokens < float64(cost) {
+			deficit := float64(cost) - bucket.tokens
+			return class, time.Duration(deficit / class.Rate * float64(time.Second))
+		}
+		buckets[class] = bucket
+	}
+
+	for class, bucket := range buckets {
+		bucket.tokens -= float64(costs[class])
+	}
+	return nil, 0
+}
+
+// sweep drops buckets that have been idle long enough to be full again.
+// Callers must hold h.mutex.
+func (h *RateLimit) sweep(now time.Time) {
+	if now.Sub(h.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	h.lastSweep = now
+
+	for key, bucket := range h.buckets {
+		refilled := bucket.tokens + now.Sub(bucket.updated).Seconds()*bucket.class.Rate
+		if refilled >= float64(bucket.class.Burst) {
+			delete(h.buckets, key)
+		}
+	}
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*RateLimit)(nil)
+	_ caddy.Validator             = (*RateLimit)(nil)
+	_ caddyhttp.MiddlewareHandler = (*RateLimit)(nil)
+)
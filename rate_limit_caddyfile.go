@@ -0,0 +1,89 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	httpcaddyfile "github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+func init() {
+	// Register Caddyfile directive for this handler
+	httpcaddyfile.RegisterHandlerDirective("blockchain_rate_limit", parseRateLimitCaddyfile)
+	httpcaddyfile.RegisterDirectiveOrder("blockchain_rate_limit", httpcaddyfile.Before, "reverse_proxy")
+}
+
+func parseRateLimitCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	rl := new(RateLimit)
+	if err := rl.UnmarshalCaddyfile(h.Dispenser); err != nil {
+		return nil, err
+	}
+	return rl, nil
+}
+
+// UnmarshalCaddyfile implements caddyfile.Unmarshaler for blockchain_rate_limit
+func (h *RateLimit) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	for d.Next() {
+		for d.NextBlock(0) {
+			switch d.Val() {
+			case "key_from":
+				s, err := parseSource(d)
+				if err != nil {
+					return err
+				}
+				h.KeyFrom = append(h.KeyFrom, s)
+
+			case "class":
+				// Syntax: class <name> { methods ... rate <n> burst <n> }
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				class := RateLimitClass{Name: d.Val()}
+				for d.NextBlock(1) {
+					switch d.Val() {
+					case "methods":
+						args := d.RemainingArgs()
+						if len(args) == 0 {
+							return d.ArgErr()
+						}
+						class.Methods = append(class.Methods, args...)
+					case "rate":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						rate, err := strconv.ParseFloat(d.Val(), 64)
+						if err != nil {
+							return d.Errf("invalid rate: %v", err)
+						}
+						class.Rate = rate
+					case "burst":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						burst, err := strconv.Atoi(d.Val())
+						if err != nil {
+							return d.Errf("invalid burst: %v", err)
+						}
+						class.Burst = burst
+					default:
+						return d.Errf("unknown class directive: %s", d.Val())
+					}
+				}
+				h.Classes = append(h.Classes, class)
+
+			default:
+				return d.Errf("unknown directive: %s", d.Val())
+			}
+		}
+	}
+
+	if err := h.Validate(); err != nil {
+		return fmt.Errorf("blockchain_rate_limit validation: %w", err)
+	}
+	return nil
+}
+
+// Interface guard
+var _ caddyfile.Unmarshaler = (*RateLimit)(nil)
@@ -0,0 +1,143 @@
+package blockchain_health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+)
+
+// rateLimitRequest sends one JSON-RPC body through the limiter
+func rateLimitRequest(t *testing.T, h *RateLimit, body, apiKey string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://127.0.0.1/", strings.NewReader(body))
+	r.RemoteAddr = "203.0.113.7:51234"
+	if apiKey != "" {
+		r.Header.Set("X-API-Key", apiKey)
+	}
+	if err := h.ServeHTTP(rec, r, echoHandler); err != nil {
+		t.Fatalf("ServeHTTP returned error: %v", err)
+	}
+	return rec
+}
+
+func TestRateLimit_MethodClasses(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	h := &RateLimit{
+		KeyFrom: []Source{{Type: "header", Name: "X-API-Key"}},
+		Classes: []RateLimitClass{
+			{Name: "logs", Methods: []string{"eth_getLogs"}, Rate: 1, Burst: 2},
+			{Name: "default", Rate: 100, Burst: 100},
+		},
+		now: func() time.Time { return now },
+	}
+
+	getLogs := `{"jsonrpc":"2.0","id":1,"method":"eth_getLogs","params":[]}`
+	blockNumber := `{"jsonrpc":"2.0","id":2,"method":"eth_blockNumber","params":[]}`
+
+	for i := 0; i < 2; i++ {
+		if rec := rateLimitRequest(t, h, getLogs, ""); rec.Code != http.StatusOK {
+			t.Fatalf("request %d within burst was rejected: %d", i+1, rec.Code)
+		}
+	}
+
+	rec := rateLimitRequest(t, h, getLogs, "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once the logs class is exhausted, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `"code":-32005`) || !strings.Contains(rec.Body.String(), `"id":1`) {
+		t.Errorf("expected a JSON-RPC limit error, got %s", rec.Body.String())
+	}
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("expected Retry-After 1, got %q", got)
+	}
+
+	// Cheap methods and other clients are unaffected
+	if rec := rateLimitRequest(t, h, blockNumber, ""); rec.Code != http.StatusOK {
+		t.Errorf("expected the default class to be unaffected, got %d", rec.Code)
+	}
+	if rec := rateLimitRequest(t, h, getLogs, "other-client"); rec.Code != http.StatusOK {
+		t.Errorf("expected another API key to have its own bucket, got %d", rec.Code)
+	}
+
+	// Tokens refill at the configured rate
+	now = now.Add(time.Second)
+	if rec := rateLimitRequest(t, h, getLogs, ""); rec.Code != http.StatusOK {
+		t.Errorf("expected a refilled token after one second, got %d", rec.Code)
+	}
+}
+
+func TestRateLimit_BatchIsChargedPerCall(t *testing.T) {
+	h := &RateLimit{
+		Classes: []RateLimitClass{
+			{Name: "logs", Methods: []string{"eth_getLogs"}, Rate: 1, Burst: 2},
+		},
+	}
+
+	batch := `[{"jsonrpc":"2.0","id":1,"method":"eth_getLogs"},{"jsonrpc":"2.0","id":2,"method":"eth_getLogs"},{"jsonrpc":"2.0","id":3,"method":"eth_getLogs"}]`
+	rec := rateLimitRequest(t, h, batch, "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected a batch over the burst to be rejected, got %d", rec.Code)
+	}
+
+	// The rejected batch consumed nothing, and unlisted methods are unlimited
+	if rec := rateLimitRequest(t, h, `{"jsonrpc":"2.0","id":1,"method":"eth_getLogs"}`, ""); rec.Code != http.StatusOK {
+		t.Errorf("expected tokens to be left after a rejected batch, got %d", rec.Code)
+	}
+	if rec := rateLimitRequest(t, h, `{"jsonrpc":"2.0","id":1,"method":"eth_chainId"}`, ""); rec.Code != http.StatusOK {
+		t.Errorf("expected unlisted methods to be unlimited, got %d", rec.Code)
+	}
+}
+
+func TestRateLimit_MalformedBatchIsRefused(t *testing.T) {
+	h := &RateLimit{
+		Classes: []RateLimitClass{
+			{Name: "logs", Methods: []string{"eth_getLogs"}, Rate: 1, Burst: 1},
+		},
+	}
+
+	// Decoding the batch as a whole would fail and charge one default call,
+	// while the node still runs the valid ones
+	batch := `[{"jsonrpc":"2.0","id":1,"method":"eth_getLogs"},{"jsonrpc":"2.0","id":2,"method":"eth_getLogs"},null]`
+	rec := rateLimitRequest(t, h, batch, "")
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"code":-32600`) {
+		t.Errorf("expected a malformed batch to be refused, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRateLimit_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_rate_limit {
+		key_from header X-API-Key
+		class expensive {
+			methods eth_getLogs debug_* trace_*
+			rate 2.5
+			burst 5
+		}
+		class default {
+			rate 50
+			burst 100
+		}
+	}`)
+
+	var h RateLimit
+	if err := h.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if len(h.Classes) != 2 || h.Classes[0].Rate != 2.5 || h.Classes[0].Burst != 5 || len(h.Classes[0].Methods) != 3 {
+		t.Errorf("unexpected classes: %+v", h.Classes)
+	}
+
+	d = caddyfile.NewTestDispenser(`blockchain_rate_limit {
+		class expensive {
+			rate 1
+			burst 1
+		}
+	}`)
+	if err := new(RateLimit).UnmarshalCaddyfile(d); err == nil {
+		t.Error("expected a named class without methods to be rejected")
+	}
+}
@@ -0,0 +1,80 @@
+package blockchain_health
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// referenceFetchTimeout bounds one fetch of an external reference height
+const referenceFetchTimeout = 10 * time.Second
+
+// errReferencePending is returned while the first fetch of a reference is
+// in flight
+var errReferencePending = errors.New("external reference height not fetched yet")
+
+// referenceFetch is the last fetched height of an external reference
+type referenceFetch struct {
+	height  uint64
+	err     error
+	started time.Time // when the fetch started
+	pending bool      // whether a fetch is in flight
+}
+
+// referenceTTL returns how long a fetched reference height is reused. Results
+// are processed once per node check, so without it a pool of N nodes would
+// fetch every reference N times per interval. It is slightly shorter than the
+// interval so every sweep refreshes it.
+func (h *HealthChecker) referenceTTL() time.Duration {
+	interval, err := time.ParseDuration(h.config.HealthCheck.Interval)
+	if err != nil || interval <= 0 {
+		return 0
+	}
+	return interval * 9 / 10
+}
+
+// refreshReferences fetches the heights of the enabled external references
+// whose last fetch is older than the TTL. It runs before results are
+// processed, outside resultsMutex, so a slow reference doesn't stall the
+// results of other nodes. A reference already being fetched is skipped.
+func (h *HealthChecker) refreshReferences() {
+	ttl := h.referenceTTL()
+	for _, ref := range h.config.ExternalReferences {
+		if !ref.Enabled {
+			continue
+		}
+
+		now := time.Now()
+		h.referencesMutex.Lock()
+		fetch, ok := h.references[ref.Name]
+		if !ok {
+			fetch = &referenceFetch{}
+			h.references[ref.Name] = fetch
+		}
+		if fetch.pending || (!fetch.started.IsZero() && now.Sub(fetch.started) < ttl) {
+			h.referencesMutex.Unlock()
+			continue
+		}
+		fetch.pending = true
+		h.referencesMutex.Unlock()
+
+		ctx, cancel := context.WithTimeout(h.ctx, referenceFetchTimeout)
+		height, err := h.referenceHeight(ctx, ref)
+		cancel()
+
+		h.referencesMutex.Lock()
+		fetch.height, fetch.err, fetch.started, fetch.pending = height, err, now, false
+		h.referencesMutex.Unlock()
+	}
+}
+
+// cachedReferenceHeight returns the last fetched height of a reference
+func (h *HealthChecker) cachedReferenceHeight(ref ExternalReference) (uint64, error) {
+	h.referencesMutex.Lock()
+	defer h.referencesMutex.Unlock()
+	fetch, ok := h.references[ref.Name]
+	if !ok || fetch.started.IsZero() {
+		return 0, errReferencePending
+	}
+	return fetch.height, fetch.err
+}
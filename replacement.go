@@ -0,0 +1,441 @@
+package blockchain_health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"go.uber.org/zap"
+)
+
+// defaultReplacementSoak is how long a replacement is compared with the node
+// it replaces when the request doesn't say
+const defaultReplacementSoak = 10 * time.Minute
+
+// replacementSampleInterval is how often the cached results of a replacement
+// and the node it replaces are sampled. Only results of new checks count.
+const replacementSampleInterval = time.Second
+
+// replacementSource is the discovery source replacements are added as
+const replacementSource = "replacement"
+
+// Replacement statuses
+const (
+	replacementSoaking = "soaking"
+	replacementSwapped = "swapped"
+	replacementFailed  = "failed"
+	replacementAborted = "aborted"
+)
+
+var (
+	// errUnknownNode is returned for a replacement of a node not in the pool
+	errUnknownNode = errors.New("unknown node")
+	// errReplacementInProgress is returned when the node is already being
+	// replaced, or no replacement of it is soaking
+	errReplacementInProgress = errors.New("replacement in progress")
+)
+
+// replacementRequest is the admin API request replacing a node
+type replacementRequest struct {
+	Node NodeConfig `json:"node"`
+	Soak string     `json:"soak,omitempty"`
+}
+
+// nodeReplacement is a blue/green replacement of a node. The replacement is
+// checked as a candidate next to the old node for the soak period; if it
+// held up at least as well, it takes over the old node's weight and labels
+// in one update, and the old node is retired and its WebSocket sessions
+// drained.
+type nodeReplacement struct {
+	Old        string     `json:"old"`
+	New        string     `json:"new"`
+	Status     string     `json:"status"`
+	Reason     string     `json:"reason,omitempty"`
+	Started    time.Time  `json:"started"`
+	SoakUntil  time.Time  `json:"soak_until"`
+	Finished   *time.Time `json:"finished,omitempty"`
+	OldChecks  int        `json:"old_checks"`
+	OldHealthy int        `json:"old_healthy"`
+	NewChecks  int        `json:"new_checks"`
+	NewHealthy int        `json:"new_healthy"`
+
+	old     NodeConfig
+	node    NodeConfig // the replacement as a candidate, or promoted once swapped
+	oldSeen time.Time  // LastCheck of the last old node result counted
+	newSeen time.Time  // LastCheck of the last replacement result counted
+	lastNew bool       // whether the replacement passed its last check
+	cancel  context.CancelFunc
+}
+
+// startReplacement adds the node as a candidate replacing the named node and
+// starts comparing the two for the soak period
+func (b *BlockchainHealthUpstream) startReplacement(oldName string, req replacementRequest, now time.Time) (*nodeReplacement, error) {
+	if b.healthChecker == nil {
+		return nil, fmt.Errorf("pool has no health checker")
+	}
+	soak := defaultReplacementSoak
+	if req.Soak != "" {
+		d, err := time.ParseDuration(req.Soak)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid soak: %s", req.Soak)
+		}
+		soak = d
+	}
+
+	nodes := b.config.nodeList()
+	old := findNode(nodes, oldName)
+	if old == nil {
+		return nil, fmt.Errorf("%w %q", errUnknownNode, oldName)
+	}
+	if old.isCandidate() {
+		return nil, fmt.Errorf("node %s is a candidate and has no traffic to hand over", oldName)
+	}
+	normalized, err := normalizePushedNodes([]NodeConfig{req.Node}, replacementSource)
+	if err != nil {
+		return nil, err
+	}
+	node := normalized[0]
+	for _, existing := range nodes {
+		if existing.Name == node.Name {
+			return nil, fmt.Errorf("node %s is already in the pool", node.Name)
+		}
+		if normalizeNodeURL(existing.URL) == normalizeNodeURL(node.URL) {
+			return nil, fmt.Errorf("node %s has the URL of node %s", node.Name, existing.Name)
+		}
+	}
+	node.Metadata["candidate"] = "true"
+
+	b.replacementMutex.Lock()
+	defer b.replacementMutex.Unlock()
+
+	if existing, exists := b.replacements[oldName]; exists && existing.Status == replacementSoaking {
+		return nil, fmt.Errorf("%w: node %s is being replaced by %s", errReplacementInProgress, oldName, existing.New)
+	}
+	if b.replacements == nil {
+		b.replacements = make(map[string]*nodeReplacement)
+	}
+	previous := b.replacements[oldName]
+	r := &nodeReplacement{
+		Old:       oldName,
+		New:       node.Name,
+		Status:    replacementSoaking,
+		Started:   now,
+		SoakUntil: now.Add(soak),
+		old:       *old,
+		node:      node,
+	}
+	b.replacements[oldName] = r
+	if err := b.setDiscoveredNodes(replacementSource, b.replacementNodes()); err != nil {
+		if previous != nil {
+			b.replacements[oldName] = previous
+		} else {
+			delete(b.replacements, oldName)
+		}
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(b.healthChecker.ctx)
+	r.cancel = cancel
+	go b.soakReplacement(ctx, r)
+
+	b.logger.Info("node replacement started",
+		zap.String("old", oldName),
+		zap.String("new", node.Name),
+		zap.Duration("soak", soak))
+	return r, nil
+}
+
+// replacementNodes returns the nodes the replacements add to the pool: the
+// soaking candidates and the promoted nodes. The caller holds
+// b.replacementMutex.
+func (b *BlockchainHealthUpstream) replacementNodes() []NodeConfig {
+	names := make([]string, 0, len(b.replacements))
+	for name := range b.replacements {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var nodes []NodeConfig
+	for _, name := range names {
+		r := b.replacements[name]
+		if r.Status == replacementSoaking || r.Status == replacementSwapped {
+			nodes = append(nodes, r.node)
+		}
+	}
+	return nodes
+}
+
+// soakReplacement samples the checks of a replacement and the node it
+// replaces until the soak period ends, then swaps them or fails the
+// replacement
+func (b *BlockchainHealthUpstream) soakReplacement(ctx context.Context, r *nodeReplacement) {
+	ticker := time.NewTicker(replacementSampleInterval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(time.Until(r.SoakUntil))
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.sampleReplacement(r)
+		case <-deadline.C:
+			b.sampleReplacement(r)
+			b.finishReplacement(r, time.Now())
+			return
+		}
+	}
+}
+
+// sampleReplacement counts the checks of both nodes since the last sample
+func (b *BlockchainHealthUpstream) sampleReplacement(r *nodeReplacement) {
+	entries := b.healthChecker.cache.snapshot()
+
+	b.replacementMutex.Lock()
+	defer b.replacementMutex.Unlock()
+	if r.Status != replacementSoaking {
+		return
+	}
+
+	if entry, exists := entries[r.old.key()]; exists && entry.Health.LastCheck.After(r.oldSeen) {
+		r.oldSeen = entry.Health.LastCheck
+		r.OldChecks++
+		if entry.Health.Healthy {
+			r.OldHealthy++
+		}
+	}
+	if entry, exists := entries[r.node.key()]; exists && entry.Health.LastCheck.After(r.newSeen) {
+		r.newSeen = entry.Health.LastCheck
+		r.lastNew = entry.Health.Healthy
+		r.NewChecks++
+		if entry.Health.Healthy {
+			r.NewHealthy++
+		}
+	}
+}
+
+// verdict returns why the replacement should not take over, or "" if it
+// held up at least as well as the old node and passed its last check
+func (r *nodeReplacement) verdict() string {
+	switch {
+	case r.NewChecks == 0:
+		return "the replacement was not checked during the soak"
+	case !r.lastNew:
+		return "the replacement failed its last check"
+	case r.OldChecks > 0 && r.NewHealthy*r.OldChecks < r.OldHealthy*r.NewChecks:
+		return fmt.Sprintf("the replacement passed %d of %d checks, the old node %d of %d",
+			r.NewHealthy, r.NewChecks, r.OldHealthy, r.OldChecks)
+	}
+	return ""
+}
+
+// promoted returns the replacement as it takes over the old node: with its
+// weight, and its metadata under the replacement's own
+func (r *nodeReplacement) promoted() NodeConfig {
+	node := r.node
+	node.Weight = r.old.Weight
+	metadata := make(map[string]string, len(r.old.Metadata)+len(r.node.Metadata))
+	for key, value := range r.old.Metadata {
+		metadata[key] = value
+	}
+	for key, value := range r.node.Metadata {
+		metadata[key] = value
+	}
+	delete(metadata, "candidate")
+	node.Metadata = metadata
+	return node
+}
+
+// finishReplacement ends the soak: the replacement is promoted and the old
+// node retired in one update, or the replacement is removed
+func (b *BlockchainHealthUpstream) finishReplacement(r *nodeReplacement, now time.Time) {
+	b.replacementMutex.Lock()
+	if r.Status != replacementSoaking {
+		b.replacementMutex.Unlock()
+		return
+	}
+	r.Finished = &now
+
+	reason := r.verdict()
+	if reason == "" {
+		candidate := r.node
+		r.node, r.Status = r.promoted(), replacementSwapped
+		if err := b.replaceDiscoveredNodes(replacementSource, b.replacementNodes(), []string{r.old.key()}); err != nil {
+			r.node = candidate
+			reason = fmt.Sprintf("swapping the nodes: %v", err)
+		}
+	}
+	if reason != "" {
+		r.Status, r.Reason = replacementFailed, reason
+		if err := b.setDiscoveredNodes(replacementSource, b.replacementNodes()); err != nil {
+			b.logger.Error("failed to remove the replacement", zap.String("node", r.New), zap.Error(err))
+		}
+	}
+	b.replacementMutex.Unlock()
+
+	h := b.healthChecker
+	if reason != "" {
+		b.logger.Warn("node replacement failed",
+			zap.String("old", r.Old),
+			zap.String("new", r.New),
+			zap.String("reason", reason))
+		h.emit(eventReplacementFailed, map[string]any{"pool": h.poolName(), "old": r.Old, "new": r.New, "reason": reason})
+		return
+	}
+
+	drained := 0
+	if dial := nodeDial(r.old.URL); dial != "" {
+		drained = wsSessions.drain(dial)
+		if drained > 0 && b.metrics != nil {
+			b.metrics.wsSessionsDrained.WithLabelValues(r.Old).Add(float64(drained))
+		}
+	}
+	b.logger.Info("node replaced",
+		zap.String("old", r.Old),
+		zap.String("new", r.New),
+		zap.Int("weight", r.old.Weight),
+		zap.Int("sessions_drained", drained))
+	h.emit(eventNodeReplaced, map[string]any{"pool": h.poolName(), "old": r.Old, "new": r.New})
+}
+
+// abortReplacement stops the soak of a node's replacement and removes the
+// replacement
+func (b *BlockchainHealthUpstream) abortReplacement(oldName string, now time.Time) error {
+	b.replacementMutex.Lock()
+	defer b.replacementMutex.Unlock()
+
+	r, exists := b.replacements[oldName]
+	if !exists || r.Status != replacementSoaking {
+		return fmt.Errorf("%w: no replacement of node %s is soaking", errReplacementInProgress, oldName)
+	}
+	r.cancel()
+	r.Status, r.Reason, r.Finished = replacementAborted, "admin", &now
+	if err := b.setDiscoveredNodes(replacementSource, b.replacementNodes()); err != nil {
+		return err
+	}
+	b.logger.Info("node replacement aborted", zap.String("old", oldName), zap.String("new", r.New))
+	return nil
+}
+
+// replacementStates returns copies of the replacements, by old node name
+func (b *BlockchainHealthUpstream) replacementStates() []nodeReplacement {
+	b.replacementMutex.Lock()
+	defer b.replacementMutex.Unlock()
+
+	states := make([]nodeReplacement, 0, len(b.replacements))
+	for _, r := range b.replacements {
+		states = append(states, *r)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Old < states[j].Old })
+	return states
+}
+
+// adminReplacementsPath is the admin API path of the node replacements
+const adminReplacementsPath = "/blockchain_health/replacements/"
+
+// poolReplacements is the admin API representation of a pool's replacements
+type poolReplacements struct {
+	Pool         string            `json:"pool"`
+	Replacements []nodeReplacement `json:"replacements"`
+}
+
+// handleReplacements serves the replacement routes
+func (a AdminAPI) handleReplacements(w http.ResponseWriter, r *http.Request) error {
+	pools := make(map[string][]*BlockchainHealthUpstream)
+	activeApps.Range(func(key, _ any) bool {
+		for name, found := range key.(*App).upstreams() {
+			pools[name] = append(pools[name], found...)
+		}
+		return true
+	})
+	return a.serveReplacements(w, r, pools)
+}
+
+// serveReplacements serves a replacement route for the pools by name
+func (a AdminAPI) serveReplacements(w http.ResponseWriter, r *http.Request, pools map[string][]*BlockchainHealthUpstream) error {
+	name, node, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, adminReplacementsPath), "/"), "/")
+
+	if name == "" {
+		if r.Method != http.MethodGet {
+			return caddy.APIError{HTTPStatus: http.StatusMethodNotAllowed, Err: fmt.Errorf("method %s not allowed", r.Method)}
+		}
+		states := []poolReplacements{}
+		for pool, found := range pools {
+			states = append(states, poolReplacementsState(pool, found))
+		}
+		sort.Slice(states, func(i, j int) bool { return states[i].Pool < states[j].Pool })
+		return writeAdminJSON(w, states)
+	}
+
+	found, ok := pools[name]
+	if !ok {
+		return caddy.APIError{HTTPStatus: http.StatusNotFound, Err: fmt.Errorf("unknown pool %q", name)}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		// Show the replacements as they are
+
+	case http.MethodPut, http.MethodPost:
+		if node == "" {
+			return caddy.APIError{HTTPStatus: http.StatusBadRequest, Err: fmt.Errorf("a replacement is started for a node by name")}
+		}
+		var body replacementRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return caddy.APIError{HTTPStatus: http.StatusBadRequest, Err: fmt.Errorf("decoding request: %w", err)}
+		}
+		now := time.Now()
+		for i, pool := range found {
+			if _, err := pool.startReplacement(node, body, now); err != nil {
+				for _, started := range found[:i] {
+					_ = started.abortReplacement(node, now)
+				}
+				return replacementAPIError(err)
+			}
+		}
+
+	case http.MethodDelete:
+		if node == "" {
+			return caddy.APIError{HTTPStatus: http.StatusBadRequest, Err: fmt.Errorf("a replacement is aborted for a node by name")}
+		}
+		now := time.Now()
+		for _, pool := range found {
+			if err := pool.abortReplacement(node, now); err != nil {
+				return replacementAPIError(err)
+			}
+		}
+
+	default:
+		return caddy.APIError{HTTPStatus: http.StatusMethodNotAllowed, Err: fmt.Errorf("method %s not allowed", r.Method)}
+	}
+
+	return writeAdminJSON(w, poolReplacementsState(name, found))
+}
+
+// replacementAPIError maps a replacement error to its admin API status
+func replacementAPIError(err error) error {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, errUnknownNode):
+		status = http.StatusNotFound
+	case errors.Is(err, errReplacementInProgress):
+		status = http.StatusConflict
+	}
+	return caddy.APIError{HTTPStatus: status, Err: err}
+}
+
+// poolReplacementsState lists the replacements of the pools of one name
+func poolReplacementsState(pool string, upstreams []*BlockchainHealthUpstream) poolReplacements {
+	state := poolReplacements{Pool: pool, Replacements: []nodeReplacement{}}
+	for _, upstream := range upstreams {
+		state.Replacements = append(state.Replacements, upstream.replacementStates()...)
+	}
+	return state
+}
@@ -0,0 +1,185 @@
+package blockchain_health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestNodeReplacement_Swap(t *testing.T) {
+	old := createCosmosServer(t, 1000, false)
+	defer old.Close()
+	other := createCosmosServer(t, 1000, false)
+	defer other.Close()
+	replacement := createCosmosServer(t, 1000, false)
+	defer replacement.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "old", URL: old.URL, Type: NodeTypeCosmos, Weight: 3, Metadata: map[string]string{"region": "eu", "tier": "primary"}},
+		{Name: "other", URL: other.URL, Type: NodeTypeCosmos, Weight: 1},
+	}, zaptest.NewLogger(t))
+	upstream.staticNodes = upstream.config.nodeList()
+	h := upstream.healthChecker
+	defer h.Stop()
+
+	dials := func() []string {
+		t.Helper()
+		upstreams, err := upstream.GetUpstreams(httptest.NewRequest(http.MethodGet, "/status", nil))
+		if err != nil {
+			t.Fatalf("GetUpstreams failed: %v", err)
+		}
+		var dials []string
+		for _, up := range upstreams {
+			dials = append(dials, up.Dial)
+		}
+		return dials
+	}
+
+	now := time.Now()
+	r, err := upstream.startReplacement("old", replacementRequest{
+		Node: NodeConfig{Name: "new", URL: replacement.URL, Type: NodeTypeCosmos, Metadata: map[string]string{"tier": "canary"}},
+		Soak: "1h",
+	}, now)
+	if err != nil {
+		t.Fatalf("startReplacement failed: %v", err)
+	}
+
+	// The replacement is checked, but takes no traffic while soaking
+	node := findNode(upstream.config.nodeList(), "new")
+	if node == nil || !node.isCandidate() {
+		t.Fatalf("expected the replacement to be added as a candidate, got %+v", node)
+	}
+	if _, err := h.CheckAllNodes(context.Background()); err != nil {
+		t.Fatalf("CheckAllNodes failed: %v", err)
+	}
+	for _, dial := range dials() {
+		if dial == getDynamicTestHostFromURL(replacement.URL) {
+			t.Error("expected the soaking replacement to take no traffic")
+		}
+	}
+
+	upstream.sampleReplacement(r)
+	upstream.finishReplacement(r, now.Add(time.Hour))
+
+	states := upstream.replacementStates()
+	if len(states) != 1 || states[0].Status != replacementSwapped || states[0].NewChecks != 1 || states[0].OldChecks != 1 {
+		t.Fatalf("expected the replacement to be swapped after a passing check, got %+v", states)
+	}
+	if findNode(upstream.config.nodeList(), "old") != nil {
+		t.Error("expected the old node to be retired")
+	}
+	node = findNode(upstream.config.nodeList(), "new")
+	if node == nil || node.isCandidate() || node.Weight != 3 ||
+		node.Metadata["region"] != "eu" || node.Metadata["tier"] != "canary" {
+		t.Fatalf("expected the replacement to take over the weight and labels, got %+v", node)
+	}
+
+	// A discovery source listing the old node again doesn't bring it back
+	if err := upstream.setDiscoveredNodes("inventory", []NodeConfig{{Name: "old", URL: old.URL, Type: NodeTypeCosmos, Weight: 1}}); err != nil {
+		t.Fatalf("setDiscoveredNodes failed: %v", err)
+	}
+	if findNode(upstream.config.nodeList(), "old") != nil {
+		t.Error("expected the retired node to stay out of the pool")
+	}
+
+	if _, err := h.CheckAllNodes(context.Background()); err != nil {
+		t.Fatalf("CheckAllNodes failed: %v", err)
+	}
+	got := strings.Join(dials(), ",")
+	if !strings.Contains(got, getDynamicTestHostFromURL(replacement.URL)) || strings.Contains(got, getDynamicTestHostFromURL(old.URL)) {
+		t.Errorf("expected traffic to the replacement instead of the old node, got %s", got)
+	}
+}
+
+func TestNodeReplacement_Fails(t *testing.T) {
+	old := createCosmosServer(t, 1000, false)
+	defer old.Close()
+	replacement := createCosmosServer(t, 1000, true)
+	defer replacement.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "old", URL: old.URL, Type: NodeTypeCosmos, Weight: 1},
+	}, zaptest.NewLogger(t))
+	upstream.staticNodes = upstream.config.nodeList()
+	h := upstream.healthChecker
+	defer h.Stop()
+
+	now := time.Now()
+	r, err := upstream.startReplacement("old", replacementRequest{
+		Node: NodeConfig{Name: "new", URL: replacement.URL, Type: NodeTypeCosmos},
+		Soak: "1h",
+	}, now)
+	if err != nil {
+		t.Fatalf("startReplacement failed: %v", err)
+	}
+	if _, err := h.CheckAllNodes(context.Background()); err != nil {
+		t.Fatalf("CheckAllNodes failed: %v", err)
+	}
+	upstream.sampleReplacement(r)
+	upstream.finishReplacement(r, now.Add(time.Hour))
+
+	states := upstream.replacementStates()
+	if len(states) != 1 || states[0].Status != replacementFailed || states[0].Reason == "" {
+		t.Fatalf("expected the catching up replacement to fail, got %+v", states)
+	}
+	if findNode(upstream.config.nodeList(), "new") != nil || findNode(upstream.config.nodeList(), "old") == nil {
+		t.Errorf("expected the old node to stay and the replacement to be removed, got %+v", upstream.config.nodeList())
+	}
+}
+
+func TestNodeReplacement_Admin(t *testing.T) {
+	old := createCosmosServer(t, 1000, false)
+	defer old.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "old", URL: old.URL, Type: NodeTypeCosmos, Weight: 1},
+	}, zaptest.NewLogger(t))
+	upstream.staticNodes = upstream.config.nodeList()
+	defer upstream.healthChecker.Stop()
+	pools := map[string][]*BlockchainHealthUpstream{"cosmos": {upstream}}
+
+	serve := func(method, path, body string) error {
+		t.Helper()
+		return AdminAPI{}.serveReplacements(httptest.NewRecorder(), httptest.NewRequest(method, path, strings.NewReader(body)), pools)
+	}
+	status := func(err error) int {
+		if apiErr, ok := err.(caddy.APIError); ok {
+			return apiErr.HTTPStatus
+		}
+		return 0
+	}
+
+	body := `{"node": {"name": "new", "url": "http://new.example:26657", "type": "cosmos"}, "soak": "1h"}`
+	if err := serve(http.MethodPut, adminReplacementsPath+"cosmos/missing", body); status(err) != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown node, got %v", err)
+	}
+	if err := serve(http.MethodPut, adminReplacementsPath+"cosmos/old", `{"node": {"name": "old", "url": "http://new.example:26657", "type": "cosmos"}}`); status(err) != http.StatusBadRequest {
+		t.Errorf("expected 400 for a replacement named like a node in the pool, got %v", err)
+	}
+	if err := serve(http.MethodPut, adminReplacementsPath+"cosmos/old", body); err != nil {
+		t.Fatalf("starting the replacement failed: %v", err)
+	}
+	if err := serve(http.MethodPut, adminReplacementsPath+"cosmos/old", strings.Replace(body, "new", "newer", 2)); status(err) != http.StatusConflict {
+		t.Errorf("expected 409 while a replacement is soaking, got %v", err)
+	}
+
+	if err := serve(http.MethodDelete, adminReplacementsPath+"cosmos/old", ""); err != nil {
+		t.Fatalf("aborting the replacement failed: %v", err)
+	}
+	states := upstream.replacementStates()
+	if len(states) != 1 || states[0].Status != replacementAborted {
+		t.Fatalf("expected the replacement to be aborted, got %+v", states)
+	}
+	if findNode(upstream.config.nodeList(), "new") != nil {
+		t.Error("expected the aborted replacement to be removed")
+	}
+	if err := serve(http.MethodDelete, adminReplacementsPath+"cosmos/old", ""); status(err) != http.StatusConflict {
+		t.Errorf("expected 409 with no replacement soaking, got %v", err)
+	}
+}
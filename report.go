@@ -0,0 +1,471 @@
+package blockchain_health
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"go.uber.org/zap"
+)
+
+// Health report periods and formats
+const (
+	reportDaily    = "daily"
+	reportWeekly   = "weekly"
+	reportJSON     = "json"
+	reportMarkdown = "markdown"
+
+	defaultReportTimeout = 10 * time.Second
+
+	// reportMaxIncidents bounds the incidents listed per node and period;
+	// later ones are still counted
+	reportMaxIncidents = 100
+)
+
+// validate checks the report settings
+func (c *ReportConfig) validate() error {
+	switch c.Period {
+	case "", reportDaily, reportWeekly:
+	default:
+		return fmt.Errorf("invalid report period %q (must be daily or weekly)", c.Period)
+	}
+	switch c.Format {
+	case "", reportJSON, reportMarkdown:
+	default:
+		return fmt.Errorf("invalid report format %q (must be json or markdown)", c.Format)
+	}
+	if c.Directory == "" && c.Webhook == "" {
+		return fmt.Errorf("report requires a directory or a webhook")
+	}
+	if c.Directory != "" {
+		if info, err := os.Stat(c.Directory); err != nil || !info.IsDir() {
+			return fmt.Errorf("report directory %s does not exist", c.Directory)
+		}
+	}
+	if c.Webhook != "" {
+		if u, err := url.Parse(c.Webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid report webhook: %s", c.Webhook)
+		}
+	}
+	if c.Timeout != "" {
+		if timeout, err := time.ParseDuration(c.Timeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid report timeout: %s", c.Timeout)
+		}
+	}
+	return nil
+}
+
+// poolReport is a published health report
+type poolReport struct {
+	Pool   string       `json:"pool"`
+	Period string       `json:"period"`
+	From   time.Time    `json:"from"` // start of the period, or of tracking when it began later
+	To     time.Time    `json:"to"`
+	Nodes  []nodeReport `json:"nodes"`
+}
+
+// nodeReport is the scorecard of one node over a period
+type nodeReport struct {
+	Name            string           `json:"name"`
+	Checks          int              `json:"checks"`
+	Uptime          float64          `json:"uptime"`            // share of healthy checks (0-1)
+	AvgBlocksBehind float64          `json:"avg_blocks_behind"` // behind the pool leader, over healthy checks
+	MaxBlocksBehind int64            `json:"max_blocks_behind"`
+	Incidents       int              `json:"incidents"`
+	Downtime        string           `json:"downtime"`
+	BreakerTrips    int              `json:"breaker_trips"`
+	IncidentLog     []reportIncident `json:"incident_log,omitempty"`
+}
+
+// reportIncident is a stretch of time a node was unhealthy
+type reportIncident struct {
+	Start    time.Time  `json:"start"`
+	End      *time.Time `json:"end,omitempty"` // unset while ongoing
+	Duration string     `json:"duration"`
+	Error    string     `json:"error,omitempty"` // error of the check that started it
+}
+
+// nodeReportStats accumulates a node's checks over the current period
+type nodeReportStats struct {
+	checks     int
+	healthy    int
+	lagSum     int64
+	lagSamples int
+	maxLag     int64
+	trips      int
+
+	incidents     []reportIncident // ended during the period
+	incidentCount int
+	open          *reportIncident // ongoing, carried into the next period
+	downtime      time.Duration   // of ended incidents, within the period
+
+	lastCheck time.Time // of the last recorded check, so each is counted once
+}
+
+// healthReport collects the checks of a pool's nodes over the current day or
+// week and publishes a report when it ends. Pools with the same report name
+// share it, and it survives config reloads but not restarts.
+type healthReport struct {
+	name string
+
+	mutex  sync.Mutex
+	config ReportConfig
+	client *http.Client
+	logger *zap.Logger
+	start  time.Time // start of the current period
+	from   time.Time // when tracking of the current period began
+	nodes  map[string]*nodeReportStats
+	now    func() time.Time
+}
+
+// Destruct implements caddy.Destructor
+func (r *healthReport) Destruct() error {
+	return nil
+}
+
+// periodStart returns the start of the UTC day or ISO week containing t
+func (r *healthReport) periodStart(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if r.config.Period == reportWeekly {
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	}
+	return day
+}
+
+// periodEnd returns the end of the period starting at start
+func (r *healthReport) periodEnd(start time.Time) time.Time {
+	if r.config.Period == reportWeekly {
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// stats returns a node's stats for the current period; callers hold the mutex
+func (r *healthReport) stats(name string) *nodeReportStats {
+	stats, ok := r.nodes[name]
+	if !ok {
+		stats = &nodeReportStats{}
+		r.nodes[name] = stats
+	}
+	return stats
+}
+
+// rollover starts a new period when t is past the current one, returning
+// the report of the period that ended; callers hold the mutex
+func (r *healthReport) rollover(t time.Time) *poolReport {
+	start := r.periodStart(t)
+	if r.start.IsZero() {
+		r.start, r.from = start, t.UTC()
+		return nil
+	}
+	if !start.After(r.start) {
+		return nil
+	}
+
+	ended := r.build(r.periodEnd(r.start))
+	r.start, r.from = start, start
+
+	// Incidents still going on are carried over, and appear in both reports
+	nodes := make(map[string]*nodeReportStats, len(r.nodes))
+	for name, stats := range r.nodes {
+		next := &nodeReportStats{lastCheck: stats.lastCheck}
+		if stats.open != nil {
+			next.open = stats.open
+			next.incidentCount = 1
+		}
+		nodes[name] = next
+	}
+	r.nodes = nodes
+	return ended
+}
+
+// record adds a check of a node and returns the report of a period it ended,
+// if any. Checks already recorded are skipped, since pools process the
+// cached results of every node whenever one of them is checked.
+func (r *healthReport) record(health *NodeHealth) *poolReport {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ended := r.rollover(health.LastCheck)
+	stats := r.stats(health.Name)
+	if !health.LastCheck.After(stats.lastCheck) {
+		return ended
+	}
+	stats.lastCheck = health.LastCheck
+	stats.checks++
+
+	if health.Healthy {
+		stats.healthy++
+		stats.lagSum += health.BlocksBehindPool
+		stats.lagSamples++
+		stats.maxLag = max(stats.maxLag, health.BlocksBehindPool)
+
+		if stats.open != nil {
+			end := health.LastCheck
+			stats.open.End = &end
+			stats.open.Duration = end.Sub(stats.open.Start).Round(time.Second).String()
+			if len(stats.incidents) < reportMaxIncidents {
+				stats.incidents = append(stats.incidents, *stats.open)
+			}
+			stats.downtime += end.Sub(later(stats.open.Start, r.from))
+			stats.open = nil
+		}
+	} else if stats.open == nil {
+		stats.open = &reportIncident{Start: health.LastCheck, Error: health.LastError}
+		stats.incidentCount++
+	}
+	return ended
+}
+
+// breakerTripped counts a circuit breaker opening on a node and returns the
+// report of a period it ended, if any
+func (r *healthReport) breakerTripped(name string) *poolReport {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	ended := r.rollover(r.now())
+	r.stats(name).trips++
+	return ended
+}
+
+// build returns the report of the current period up to to; callers hold the
+// mutex
+func (r *healthReport) build(to time.Time) *poolReport {
+	period := r.config.Period
+	if period == "" {
+		period = reportDaily
+	}
+	report := &poolReport{
+		Pool:   r.name,
+		Period: period,
+		From:   r.from,
+		To:     to.UTC(),
+		Nodes:  make([]nodeReport, 0, len(r.nodes)),
+	}
+
+	names := make([]string, 0, len(r.nodes))
+	for name := range r.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		stats := r.nodes[name]
+		node := nodeReport{
+			Name:            name,
+			Checks:          stats.checks,
+			MaxBlocksBehind: stats.maxLag,
+			Incidents:       stats.incidentCount,
+			BreakerTrips:    stats.trips,
+			IncidentLog:     append([]reportIncident(nil), stats.incidents...),
+		}
+		if stats.checks > 0 {
+			node.Uptime = float64(stats.healthy) / float64(stats.checks)
+		}
+		if stats.lagSamples > 0 {
+			node.AvgBlocksBehind = float64(stats.lagSum) / float64(stats.lagSamples)
+		}
+
+		downtime := stats.downtime
+		if stats.open != nil {
+			downtime += to.Sub(later(stats.open.Start, r.from))
+			ongoing := *stats.open
+			ongoing.Duration = to.Sub(ongoing.Start).Round(time.Second).String()
+			node.IncidentLog = append(node.IncidentLog, ongoing)
+		}
+		node.Downtime = downtime.Round(time.Second).String()
+		report.Nodes = append(report.Nodes, node)
+	}
+	return report
+}
+
+// later returns the later of two times
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// render formats a report in the configured format, returning its content
+// type and file extension
+func (r *healthReport) render(report *poolReport) (body []byte, contentType, ext string, err error) {
+	if r.config.Format == reportMarkdown {
+		return renderMarkdownReport(report), "text/markdown; charset=utf-8", ".md", nil
+	}
+	body, err = json.MarshalIndent(report, "", "  ")
+	return append(body, '\n'), "application/json", ".json", err
+}
+
+// renderMarkdownReport formats a report as a Markdown document
+func renderMarkdownReport(report *poolReport) []byte {
+	const stamp = "2006-01-02 15:04 UTC"
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# Health report: %s\n\n", report.Pool)
+	fmt.Fprintf(&b, "%s%s report from %s to %s.\n\n", strings.ToUpper(report.Period[:1]), report.Period[1:],
+		report.From.Format(stamp), report.To.Format(stamp))
+
+	b.WriteString("| Node | Uptime | Checks | Avg blocks behind | Max blocks behind | Incidents | Downtime | Breaker trips |\n")
+	b.WriteString("|------|--------|--------|-------------------|-------------------|-----------|----------|---------------|\n")
+	for _, node := range report.Nodes {
+		fmt.Fprintf(&b, "| %s | %.3f%% | %d | %.1f | %d | %d | %s | %d |\n",
+			node.Name, node.Uptime*100, node.Checks, node.AvgBlocksBehind, node.MaxBlocksBehind,
+			node.Incidents, node.Downtime, node.BreakerTrips)
+	}
+
+	var incidents []string
+	for _, node := range report.Nodes {
+		for _, incident := range node.IncidentLog {
+			end := "ongoing"
+			if incident.End != nil {
+				end = incident.End.UTC().Format(stamp)
+			}
+			line := fmt.Sprintf("- **%s**: %s to %s (%s)", node.Name, incident.Start.UTC().Format(stamp), end, incident.Duration)
+			if incident.Error != "" {
+				line += ": " + incident.Error
+			}
+			incidents = append(incidents, line)
+		}
+	}
+	if len(incidents) > 0 {
+		b.WriteString("\n## Incidents\n\n")
+		b.WriteString(strings.Join(incidents, "\n"))
+		b.WriteString("\n")
+	}
+	return b.Bytes()
+}
+
+// publish writes a report to the directory and posts it to the webhook
+func (r *healthReport) publish(report *poolReport) {
+	r.mutex.Lock()
+	config, client := r.config, r.client
+	r.mutex.Unlock()
+
+	body, contentType, ext, err := r.render(report)
+	if err != nil {
+		r.logger.Error("failed to render health report", zap.String("report", r.name), zap.Error(err))
+		return
+	}
+
+	if config.Directory != "" {
+		name := strings.NewReplacer("/", "_", `\`, "_").Replace(r.name)
+		file := filepath.Join(config.Directory, name+"-"+report.From.Format("2006-01-02")+ext)
+		if err := writeFileAtomic(file, body); err != nil {
+			r.logger.Error("failed to write health report", zap.String("file", file), zap.Error(err))
+		} else {
+			r.logger.Info("health report written", zap.String("report", r.name), zap.String("file", file))
+		}
+	}
+
+	if config.Webhook != "" {
+		if err := postReport(client, config, body, contentType); err != nil {
+			r.logger.Error("failed to post health report",
+				zap.String("report", r.name),
+				zap.String("webhook", config.Webhook),
+				zap.Error(err))
+		} else {
+			r.logger.Info("health report posted", zap.String("report", r.name))
+		}
+	}
+}
+
+// postReport sends a report to the webhook. Header values may use Caddy's
+// global placeholders such as {env.REPORT_TOKEN}.
+func postReport(client *http.Client, config ReportConfig, body []byte, contentType string) error {
+	req, err := http.NewRequest(http.MethodPost, config.Webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	repl := caddy.NewReplacer()
+	for name, value := range config.Headers {
+		req.Header.Set(name, repl.ReplaceKnown(value, ""))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// healthReports holds the live health reports by name
+var healthReports = caddy.NewUsagePool()
+
+// registerHealthReport returns the live report with the given name, creating
+// it if needed. The config must have its defaults set; the latest pool to
+// register sets where the report goes.
+func registerHealthReport(name string, config ReportConfig, logger *zap.Logger) (*healthReport, error) {
+	value, _, err := healthReports.LoadOrNew(name, func() (caddy.Destructor, error) {
+		return &healthReport{name: name, nodes: make(map[string]*nodeReportStats), now: time.Now}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	report := value.(*healthReport)
+
+	timeout, _ := time.ParseDuration(config.Timeout)
+	report.mutex.Lock()
+	report.config = config
+	report.client = &http.Client{Timeout: timeout}
+	report.logger = logger
+	report.mutex.Unlock()
+	return report, nil
+}
+
+// releaseHealthReport drops a pool's reference to its report
+func releaseHealthReport(report *healthReport) {
+	_, _ = healthReports.Delete(report.name)
+}
+
+// reportName returns the configured report name, defaulting to the chain
+func (b *BlockchainHealthUpstream) reportName() string {
+	if b.Report.Name != "" {
+		return b.Report.Name
+	}
+	if chain := b.chainName(); chain != "" {
+		return chain
+	}
+	return "default"
+}
+
+// recordReport adds fresh check results to the pool's health report, and
+// publishes the report of a period they ended. Candidates are left out.
+func (h *HealthChecker) recordReport(results []*NodeHealth) {
+	if h.report == nil {
+		return
+	}
+	for _, health := range results {
+		if health == nil || h.isCandidate(health.Name) {
+			continue
+		}
+		if ended := h.report.record(health); ended != nil {
+			go h.report.publish(ended)
+		}
+	}
+}
+
+// reportBreakerTrip counts a circuit breaker opening in the pool's health
+// report
+func (h *HealthChecker) reportBreakerTrip(nodeName string) {
+	if h.report == nil || h.isCandidate(nodeName) {
+		return
+	}
+	if ended := h.report.breakerTripped(nodeName); ended != nil {
+		go h.report.publish(ended)
+	}
+}
@@ -0,0 +1,173 @@
+package blockchain_health
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestHealthReport_Rollover(t *testing.T) {
+	day := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	r := &healthReport{name: "ethereum", config: ReportConfig{Period: reportDaily}, nodes: make(map[string]*nodeReportStats)}
+	r.now = func() time.Time { return day.Add(12 * time.Hour) }
+
+	check := func(name string, at time.Duration, healthy bool, behind int64) *poolReport {
+		health := &NodeHealth{Name: name, Healthy: healthy, BlocksBehindPool: behind, LastCheck: day.Add(at)}
+		if !healthy {
+			health.LastError = "connection refused"
+		}
+		return r.record(health)
+	}
+	check("a", time.Hour, true, 2)
+	check("b", time.Hour, true, 0)
+	// A cached result processed again is not counted twice
+	r.record(&NodeHealth{Name: "a", Healthy: true, BlocksBehindPool: 2, LastCheck: day.Add(time.Hour)})
+	check("a", 2*time.Hour, false, 0)
+	check("a", 2*time.Hour+10*time.Minute, true, 4)
+	check("b", 23*time.Hour, false, 0)
+	r.breakerTripped("b")
+
+	ended := check("a", 25*time.Hour, true, 0)
+	if ended == nil {
+		t.Fatal("expected the first check of the next day to end the report")
+	}
+	if !ended.From.Equal(day.Add(time.Hour)) || !ended.To.Equal(day.Add(24*time.Hour)) {
+		t.Errorf("expected the report to run from the first check to midnight, got %v to %v", ended.From, ended.To)
+	}
+	if len(ended.Nodes) != 2 {
+		t.Fatalf("expected 2 nodes, got %+v", ended.Nodes)
+	}
+
+	a := ended.Nodes[0]
+	if a.Checks != 3 || a.Incidents != 1 || a.Downtime != "10m0s" || a.MaxBlocksBehind != 4 || a.AvgBlocksBehind != 3 {
+		t.Errorf("unexpected report for a: %+v", a)
+	}
+	if len(a.IncidentLog) != 1 || a.IncidentLog[0].End == nil || a.IncidentLog[0].Error != "connection refused" {
+		t.Errorf("expected a's ended incident, got %+v", a.IncidentLog)
+	}
+
+	b := ended.Nodes[1]
+	if b.Uptime != 0.5 || b.Incidents != 1 || b.BreakerTrips != 1 || b.Downtime != "1h0m0s" {
+		t.Errorf("unexpected report for b: %+v", b)
+	}
+	if len(b.IncidentLog) != 1 || b.IncidentLog[0].End != nil {
+		t.Errorf("expected b's incident to be ongoing, got %+v", b.IncidentLog)
+	}
+
+	// b's incident carries over into the next day
+	next := r.build(day.Add(26 * time.Hour))
+	if b := next.Nodes[1]; b.Checks != 0 || b.Incidents != 1 || b.Downtime != "2h0m0s" || b.BreakerTrips != 0 {
+		t.Errorf("expected b's ongoing incident in the next report, got %+v", b)
+	}
+}
+
+func TestHealthReport_PeriodStart(t *testing.T) {
+	r := &healthReport{config: ReportConfig{Period: reportWeekly}}
+	// 2026-10-15 is a Thursday
+	if got := r.periodStart(time.Date(2026, 10, 15, 13, 0, 0, 0, time.UTC)); !got.Equal(time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected weeks to start on Monday, got %v", got)
+	}
+	if got := r.periodStart(time.Date(2026, 10, 18, 23, 0, 0, 0, time.UTC)); !got.Equal(time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected Sunday to end the week, got %v", got)
+	}
+}
+
+func TestHealthReport_Publish(t *testing.T) {
+	var body []byte
+	var header http.Header
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+	}))
+	defer webhook.Close()
+
+	dir := t.TempDir()
+	t.Setenv("REPORT_TOKEN", "secret")
+	config := ReportConfig{Period: reportDaily, Format: reportMarkdown, Directory: dir, Webhook: webhook.URL,
+		Headers: map[string]string{"Authorization": "Bearer {env.REPORT_TOKEN}"}}
+	r := &healthReport{name: "ethereum", config: config, client: webhook.Client(), logger: zaptest.NewLogger(t)}
+
+	day := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	end := day.Add(3 * time.Hour)
+	r.publish(&poolReport{Pool: "ethereum", Period: reportDaily, From: day, To: day.Add(24 * time.Hour), Nodes: []nodeReport{{
+		Name: "a", Checks: 10, Uptime: 0.9, Incidents: 1, Downtime: "1h0m0s",
+		IncidentLog: []reportIncident{{Start: day.Add(2 * time.Hour), End: &end, Duration: "1h0m0s", Error: "timeout"}},
+	}}})
+
+	written, err := os.ReadFile(filepath.Join(dir, "ethereum-2026-10-15.md"))
+	if err != nil {
+		t.Fatalf("expected the report file: %v", err)
+	}
+	for _, want := range []string{"# Health report: ethereum", "| a | 90.000% | 10 |", "- **a**: 2026-10-15 02:00 UTC to 2026-10-15 03:00 UTC (1h0m0s): timeout"} {
+		if !strings.Contains(string(written), want) {
+			t.Errorf("expected the report to contain %q, got:\n%s", want, written)
+		}
+	}
+	if string(body) != string(written) {
+		t.Errorf("expected the webhook to receive the written report, got:\n%s", body)
+	}
+	if got := header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("expected the header placeholder to be replaced, got %q", got)
+	}
+	if got := header.Get("Content-Type"); !strings.HasPrefix(got, "text/markdown") {
+		t.Errorf("expected a Markdown content type, got %q", got)
+	}
+
+	// JSON reports decode back
+	r.config.Format = reportJSON
+	r.config.Directory = ""
+	r.publish(&poolReport{Pool: "ethereum", Period: reportDaily, From: day, To: day.Add(24 * time.Hour), Nodes: []nodeReport{{Name: "a"}}})
+	var decoded poolReport
+	if err := json.Unmarshal(body, &decoded); err != nil || decoded.Pool != "ethereum" || len(decoded.Nodes) != 1 {
+		t.Errorf("expected a JSON report, got %s (%v)", body, err)
+	}
+}
+
+func TestHealthReport_UnmarshalCaddyfile(t *testing.T) {
+	dir := t.TempDir()
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:8545
+			type evm
+		}
+		report weekly {
+			format markdown
+			directory ` + dir + `
+			webhook https://example.com/reports
+			header Authorization "Bearer {env.REPORT_TOKEN}"
+		}
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if !b.Report.Enabled || b.Report.Period != reportWeekly || b.Report.Format != reportMarkdown ||
+		b.Report.Directory != dir || b.Report.Headers["Authorization"] != "Bearer {env.REPORT_TOKEN}" {
+		t.Errorf("unexpected report config: %+v", b.Report)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+
+	b.Report.Period = "monthly"
+	if err := b.validate(); err == nil {
+		t.Error("expected an unknown period to be rejected")
+	}
+
+	d = caddyfile.NewTestDispenser(`blockchain_health {
+		report
+	}`)
+	if err := new(BlockchainHealthUpstream).UnmarshalCaddyfile(d); err == nil {
+		t.Error("expected a report without a directory or webhook to be rejected")
+	}
+}
@@ -203,11 +203,17 @@ func (h *RequestDeadline) shouldSkip(r *http.Request) bool {
 }
 
 func (h *RequestDeadline) resolveTier(r *http.Request) string {
+	return resolveSource(r, h.Sources)
+}
+
+// resolveSource returns the first non-empty value found in the sources.
+// Placeholders are tried before headers and query parameters.
+func resolveSource(r *http.Request, sources []Source) string {
 	// Attempt placeholder via Caddy Replacer if available
-	if len(h.Sources) > 0 {
+	if len(sources) > 0 {
 		if replVal := r.Context().Value(caddy.ReplacerCtxKey); replVal != nil {
 			if repl, ok := replVal.(*caddy.Replacer); ok {
-				for _, s := range h.Sources {
+				for _, s := range sources {
 					if s.Type == "placeholder" && s.Value != "" {
 						if v := strings.TrimSpace(repl.ReplaceAll(s.Value, "")); v != "" {
 							return v
@@ -218,7 +224,7 @@ func (h *RequestDeadline) resolveTier(r *http.Request) string {
 		}
 	}
 	// Fallbacks: header and query
-	for _, s := range h.Sources {
+	for _, s := range sources {
 		switch s.Type {
 		case "header":
 			if v := strings.TrimSpace(r.Header.Get(s.Name)); v != "" {
@@ -33,23 +33,9 @@ func (h *RequestDeadline) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
 		for d.NextBlock(0) {
 			switch d.Val() {
 			case "from":
-				// Syntax: from <placeholder|header|query> <value>
-				if !d.NextArg() {
-					return d.ArgErr()
-				}
-				typ := d.Val()
-				if !d.NextArg() {
-					return d.ArgErr()
-				}
-				val := d.Val()
-				s := Source{Type: typ}
-				switch typ {
-				case "placeholder":
-					s.Value = val
-				case "header", "query":
-					s.Name = val
-				default:
-					return d.Errf("unknown from type: %s", typ)
+				s, err := parseSource(d)
+				if err != nil {
+					return err
 				}
 				h.Sources = append(h.Sources, s)
 
@@ -153,5 +139,28 @@ func (h *RequestDeadline) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
 	return nil
 }
 
+// parseSource parses the arguments of a source option.
+// Syntax: <placeholder|header|query> <value>
+func parseSource(d *caddyfile.Dispenser) (Source, error) {
+	if !d.NextArg() {
+		return Source{}, d.ArgErr()
+	}
+	typ := d.Val()
+	if !d.NextArg() {
+		return Source{}, d.ArgErr()
+	}
+	val := d.Val()
+	s := Source{Type: typ}
+	switch typ {
+	case "placeholder":
+		s.Value = val
+	case "header", "query":
+		s.Name = val
+	default:
+		return Source{}, d.Errf("unknown from type: %s", typ)
+	}
+	return s, nil
+}
+
 // Interface guard
 var _ caddyfile.Unmarshaler = (*RequestDeadline)(nil)
@@ -0,0 +1,166 @@
+package blockchain_health
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+)
+
+// Request signing methods
+const (
+	SigningHMAC = "hmac"
+	SigningJWT  = "jwt"
+)
+
+// Request signing defaults
+const (
+	defaultSignatureHeader = "X-Signature"
+	defaultJWTHeader       = "Authorization"
+	defaultJWTTTL          = time.Minute
+)
+
+// signatureTimestampHeader carries the Unix time an HMAC signature was made
+// at, so gateways can reject replayed requests
+const signatureTimestampHeader = "X-Signature-Timestamp"
+
+// validate checks the request_signing settings
+func (c *RequestSigningConfig) validate() error {
+	if c.Method != SigningHMAC && c.Method != SigningJWT {
+		return fmt.Errorf("invalid request_signing method %q (must be hmac or jwt)", c.Method)
+	}
+	if c.Secret == "" {
+		return fmt.Errorf("request_signing requires a secret")
+	}
+	if c.TTL != "" {
+		if c.Method != SigningJWT {
+			return fmt.Errorf("request_signing ttl only applies to jwt")
+		}
+		if ttl, err := time.ParseDuration(c.TTL); err != nil || ttl <= 0 {
+			return fmt.Errorf("invalid request_signing ttl: %s", c.TTL)
+		}
+	}
+	return nil
+}
+
+// requestSigner signs the requests sent to the pool's nodes
+type requestSigner struct {
+	method   string
+	header   string
+	secret   []byte
+	issuer   string
+	audience string
+	ttl      time.Duration
+}
+
+// newRequestSigner returns the signer for the settings, replacing
+// placeholders in the secret, or nil when signing is off
+func newRequestSigner(c RequestSigningConfig) *requestSigner {
+	if c.Method == "" {
+		return nil
+	}
+	s := &requestSigner{
+		method:   c.Method,
+		header:   c.Header,
+		secret:   []byte(caddy.NewReplacer().ReplaceKnown(c.Secret, "")),
+		issuer:   c.Issuer,
+		audience: c.Audience,
+		ttl:      defaultJWTTTL,
+	}
+	if ttl, err := time.ParseDuration(c.TTL); err == nil && ttl > 0 {
+		s.ttl = ttl
+	}
+	if s.header == "" {
+		s.header = defaultSignatureHeader
+		if s.method == SigningJWT {
+			s.header = defaultJWTHeader
+		}
+	}
+	return s
+}
+
+// sign adds the signature headers to a request. HMAC signatures cover the
+// timestamp, method and request URI but not the body, which proxied requests
+// stream; JWTs are bearer tokens valid for the signer's TTL.
+func (s *requestSigner) sign(r *http.Request, now time.Time) {
+	switch s.method {
+	case SigningHMAC:
+		timestamp := strconv.FormatInt(now.Unix(), 10)
+		mac := hmac.New(sha256.New, s.secret)
+		mac.Write([]byte(timestamp + "\n" + r.Method + "\n" + r.URL.RequestURI()))
+		r.Header.Set(signatureTimestampHeader, timestamp)
+		r.Header.Set(s.header, hex.EncodeToString(mac.Sum(nil)))
+	case SigningJWT:
+		token := s.token(now)
+		if s.header == defaultJWTHeader {
+			token = "Bearer " + token
+		}
+		r.Header.Set(s.header, token)
+	}
+}
+
+// jwtHeader is the encoded header of every token: HS256, the only algorithm
+// a shared secret allows
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// token returns an HS256 JWT issued at now
+func (s *requestSigner) token(now time.Time) string {
+	claims := struct {
+		Issuer   string `json:"iss,omitempty"`
+		Audience string `json:"aud,omitempty"`
+		IssuedAt int64  `json:"iat"`
+		Expires  int64  `json:"exp"`
+	}{s.issuer, s.audience, now.Unix(), now.Add(s.ttl).Unix()}
+	payload, _ := json.Marshal(claims)
+
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signingTransport signs every request sent through it
+type signingTransport struct {
+	signer *requestSigner
+	next   http.RoundTripper
+}
+
+// RoundTrip signs a copy of the request, as round trippers must not modify
+// the request they are given
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	signed := req.Clone(req.Context())
+	t.signer.sign(signed, time.Now())
+	return t.next.RoundTrip(signed)
+}
+
+// signProbes signs the health check requests of every protocol handler
+func (h *HealthChecker) signProbes(signer *requestSigner) {
+	h.wrapProbeTransports(func(next http.RoundTripper) http.RoundTripper {
+		return &signingTransport{signer: signer, next: next}
+	})
+}
+
+// wrapProbeTransports wraps the transport of every protocol handler's client
+func (h *HealthChecker) wrapProbeTransports(wrap func(http.RoundTripper) http.RoundTripper) {
+	for _, handler := range []ProtocolHandler{h.cosmosHandler, h.evmHandler, h.beaconHandler} {
+		var client *http.Client
+		switch handler := handler.(type) {
+		case *CosmosHandler:
+			client = handler.client
+		case *EVMHandler:
+			client = handler.client
+		case *BeaconHandler:
+			client = handler.client
+		default:
+			continue
+		}
+		client.Transport = wrap(client.Transport)
+	}
+}
@@ -0,0 +1,147 @@
+package blockchain_health
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestRequestSigner_HMAC(t *testing.T) {
+	signer := newRequestSigner(RequestSigningConfig{Method: SigningHMAC, Secret: "s3cret"})
+	req := httptest.NewRequest(http.MethodPost, "/status?height=5", nil)
+	now := time.Unix(1700000000, 0)
+	signer.sign(req, now)
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte("1700000000\nPOST\n/status?height=5"))
+	if got, want := req.Header.Get("X-Signature"), hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("expected signature %s, got %s", want, got)
+	}
+	if req.Header.Get(signatureTimestampHeader) != "1700000000" {
+		t.Errorf("expected the timestamp header, got %q", req.Header.Get(signatureTimestampHeader))
+	}
+}
+
+func TestRequestSigner_JWT(t *testing.T) {
+	t.Setenv("SIGNING_TEST_SECRET", "from-env")
+	signer := newRequestSigner(RequestSigningConfig{
+		Method:   SigningJWT,
+		Secret:   "{env.SIGNING_TEST_SECRET}",
+		Issuer:   "caddy",
+		Audience: "gateway",
+		TTL:      "30s",
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	now := time.Unix(1700000000, 0)
+	signer.sign(req, now)
+
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		t.Fatalf("expected a bearer token, got %q", req.Header.Get("Authorization"))
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("expected three token parts, got %d", len(parts))
+	}
+
+	mac := hmac.New(sha256.New, []byte("from-env"))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if parts[2] != base64.RawURLEncoding.EncodeToString(mac.Sum(nil)) {
+		t.Error("expected the token to be signed with the secret from the environment")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("decoding payload: %v", err)
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatalf("decoding claims: %v", err)
+	}
+	if claims["iss"] != "caddy" || claims["aud"] != "gateway" || claims["exp"] != float64(1700000030) {
+		t.Errorf("unexpected claims %v", claims)
+	}
+}
+
+func TestRequestSigning_ProbesAndProxiedRequests(t *testing.T) {
+	var unsigned atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Node-Signature") == "" {
+			unsigned.Add(1)
+			http.Error(w, "unsigned", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"result":{"sync_info":{"latest_block_height":"1000","catching_up":false}}}`))
+	}))
+	defer server.Close()
+
+	nodes := []NodeConfig{{Name: "signed", URL: server.URL, Type: NodeTypeCosmos, Weight: 1}}
+	upstream := createTestUpstream(nodes, zaptest.NewLogger(t))
+	upstream.signer = newRequestSigner(RequestSigningConfig{Method: SigningHMAC, Secret: "s3cret", Header: "X-Node-Signature"})
+	upstream.healthChecker.signProbes(upstream.signer)
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	if _, err := upstream.GetUpstreams(req); err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+	if unsigned.Load() != 0 {
+		t.Errorf("expected every health check to be signed, %d were not", unsigned.Load())
+	}
+	if req.Header.Get("X-Node-Signature") == "" {
+		t.Error("expected the proxied request to be signed")
+	}
+}
+
+func TestRequestSigning_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:26657
+			type cosmos
+		}
+		request_signing jwt {
+			secret {env.NODE_GATEWAY_SECRET}
+			issuer caddy
+			ttl 30s
+		}
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	want := RequestSigningConfig{Method: SigningJWT, Secret: "{env.NODE_GATEWAY_SECRET}", Issuer: "caddy", TTL: "30s"}
+	if b.RequestSigning != want {
+		t.Errorf("expected %+v, got %+v", want, b.RequestSigning)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+
+	b.RequestSigning.Method = "rsa"
+	if err := b.validate(); err == nil {
+		t.Error("expected an unknown signing method to be rejected")
+	}
+	b.RequestSigning = RequestSigningConfig{Method: SigningHMAC, Secret: "x", TTL: "30s"}
+	if err := b.validate(); err == nil {
+		t.Error("expected a ttl on hmac signing to be rejected")
+	}
+
+	d = caddyfile.NewTestDispenser(`blockchain_health {
+		request_signing hmac
+	}`)
+	if err := (&BlockchainHealthUpstream{}).UnmarshalCaddyfile(d); err == nil {
+		t.Error("expected request_signing without a secret to be rejected")
+	}
+}
@@ -0,0 +1,481 @@
+package blockchain_health
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// Response cache defaults
+const (
+	defaultCacheTTL           = time.Hour
+	defaultCacheMaxEntries    = 10000
+	defaultCacheFinalityDepth = 64
+)
+
+// rpcCacheRule describes when a JSON-RPC method's response is immutable
+type rpcCacheRule int
+
+const (
+	// cacheStatic responses never change for a chain (eth_chainId)
+	cacheStatic rpcCacheRule = iota
+	// cacheByHash responses are immutable once the object exists
+	cacheByHash
+	// cacheByBlockParam responses are immutable once the block in params[0] is final
+	cacheByBlockParam
+	// cacheByResultBlock responses are immutable once the block in the result is final
+	cacheByResultBlock
+	// cacheLogs applies to eth_getLogs by block hash or a final block range
+	cacheLogs
+	// learnHead responses are never cached but reveal the chain head
+	learnHead
+)
+
+// rpcCacheRules lists the JSON-RPC methods the cache handles
+var rpcCacheRules = map[string]rpcCacheRule{
+	"eth_chainId":                             cacheStatic,
+	"net_version":                             cacheStatic,
+	"eth_getBlockByHash":                      cacheByHash,
+	"eth_getBlockTransactionCountByHash":      cacheByHash,
+	"eth_getTransactionByBlockHashAndIndex":   cacheByHash,
+	"eth_getUncleByBlockHashAndIndex":         cacheByHash,
+	"eth_getUncleCountByBlockHash":            cacheByHash,
+	"eth_getBlockByNumber":                    cacheByBlockParam,
+	"eth_getBlockTransactionCountByNumber":    cacheByBlockParam,
+	"eth_getTransactionByBlockNumberAndIndex": cacheByBlockParam,
+	"eth_getUncleByBlockNumberAndIndex":       cacheByBlockParam,
+	"eth_getBlockReceipts":                    cacheByBlockParam,
+	"eth_getTransactionByHash":                cacheByResultBlock,
+	"eth_getTransactionReceipt":               cacheByResultBlock,
+	"eth_getLogs":                             cacheLogs,
+	"eth_blockNumber":                         learnHead,
+}
+
+// ResponseCache is a middleware placed in front of reverse_proxy that caches
+// responses for immutable blockchain data: blocks and transactions by hash,
+// eth_chainId, queries for blocks at least FinalityDepth below the head, and
+// Cosmos block queries by height. Entries are keyed on the full request and
+// stored in memory or in Redis.
+//
+// The chain head is learned from eth_blockNumber responses passing through the
+// handler, so each handler should front a single chain.
+type ResponseCache struct {
+	TTL           caddy.Duration    `json:"ttl,omitempty"`
+	MaxEntries    int               `json:"max_entries,omitempty"`
+	FinalityDepth *uint64           `json:"finality_depth,omitempty"`
+	Redis         *RedisCacheConfig `json:"redis,omitempty"`
+
+	// HeadTTL enables short-lived caching of chain head queries such as
+	// eth_blockNumber; HeadStream invalidates them as soon as a block lands
+	HeadTTL    caddy.Duration `json:"head_ttl,omitempty"`
+	HeadStream string         `json:"head_stream,omitempty"`
+
+	store     responseStore
+	headStore atomic.Pointer[memoryStore]
+	head      atomic.Uint64
+	cancel    context.CancelFunc
+	logger    *zap.Logger
+}
+
+// RedisCacheConfig selects a shared Redis store instead of memory
+type RedisCacheConfig struct {
+	Address  string `json:"address"`
+	Password string `json:"password,omitempty"`
+	DB       int    `json:"db,omitempty"`
+}
+
+// cachedResponse is the stored form of a REST response
+type cachedResponse struct {
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body"`
+}
+
+// cacheLookup describes a request the cache can serve
+type cacheLookup struct {
+	key    string
+	label  string          // JSON-RPC method, or "rest"
+	id     json.RawMessage // JSON-RPC id to answer with; nil for REST
+	rule   rpcCacheRule
+	params json.RawMessage
+	head   bool // served from the short-lived head cache
+}
+
+func init() {
+	caddy.RegisterModule(&ResponseCache{})
+}
+
+var rcMetrics *ResponseCacheMetrics
+
+// CaddyModule returns the Caddy module information.
+func (*ResponseCache) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.blockchain_cache",
+		New: func() caddy.Module { return new(ResponseCache) },
+	}
+}
+
+// Provision applies defaults, opens the store and registers metrics
+func (h *ResponseCache) Provision(ctx caddy.Context) error {
+	if h.TTL == 0 {
+		h.TTL = caddy.Duration(defaultCacheTTL)
+	}
+	if h.MaxEntries == 0 {
+		h.MaxEntries = defaultCacheMaxEntries
+	}
+	h.logger = ctx.Logger()
+	h.setupStore()
+	if h.HeadStream != "" {
+		streamCtx, cancel := context.WithCancel(ctx)
+		h.cancel = cancel
+		go h.followHeadStream(streamCtx)
+	}
+
+	var registerer prometheus.Registerer
+	if reg := ctx.GetMetricsRegistry(); reg != nil {
+		registerer = reg
+	} else {
+		registerer = prometheus.DefaultRegisterer
+	}
+	metrics, err := acquireResponseCacheMetrics(registerer)
+	if err != nil {
+		return err
+	}
+	rcMetrics = metrics
+	return nil
+}
+
+// setupStore picks the configured store
+func (h *ResponseCache) setupStore() {
+	if h.Redis != nil {
+		h.store = newRedisStore(h.Redis.Address, h.Redis.Password, h.Redis.DB)
+		return
+	}
+	h.store = newMemoryStore(h.MaxEntries)
+	h.headStore.Store(newMemoryStore(headStoreEntries))
+}
+
+// Cleanup stops the head stream
+func (h *ResponseCache) Cleanup() error {
+	if h.cancel != nil {
+		h.cancel()
+	}
+	return nil
+}
+
+// Validate checks configuration correctness
+func (h *ResponseCache) Validate() error {
+	if h.TTL < 0 {
+		return fmt.Errorf("ttl must not be negative")
+	}
+	if h.MaxEntries < 0 {
+		return fmt.Errorf("max_entries must not be negative")
+	}
+	if h.Redis != nil && h.Redis.Address == "" {
+		return fmt.Errorf("redis address is required")
+	}
+	if h.HeadTTL < 0 {
+		return fmt.Errorf("head_ttl must not be negative")
+	}
+	if h.HeadStream != "" {
+		if h.HeadTTL == 0 {
+			return fmt.Errorf("head_stream requires head_ttl")
+		}
+		if u, err := url.Parse(h.HeadStream); err != nil || (u.Scheme != "ws" && u.Scheme != "wss") {
+			return fmt.Errorf("head_stream must be a ws:// or wss:// URL")
+		}
+	}
+	return nil
+}
+
+// finalityDepth returns the configured finality depth
+func (h *ResponseCache) finalityDepth() uint64 {
+	if h.FinalityDepth == nil {
+		return defaultCacheFinalityDepth
+	}
+	return *h.FinalityDepth
+}
+
+// isFinal reports whether a block is at least finality_depth below the head
+func (h *ResponseCache) isFinal(height uint64) bool {
+	head := h.head.Load()
+	return head > 0 && height+h.finalityDepth() <= head
+}
+
+// ServeHTTP answers cacheable requests from the store and stores the
+// responses of misses that turn out to be immutable
+func (h *ResponseCache) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	if h.store == nil || websocket.IsWebSocketUpgrade(r) {
+		return next.ServeHTTP(w, r)
+	}
+
+	lookup, err := h.lookupFor(r)
+	if errors.Is(err, errInvalidRPC) {
+		return writeInvalidRPC(w)
+	}
+	if err != nil {
+		return caddyhttp.Error(http.StatusBadRequest, err)
+	}
+	if lookup == nil {
+		return next.ServeHTTP(w, r)
+	}
+
+	var store responseStore = h.store
+	ttl := time.Duration(h.TTL)
+	if lookup.head {
+		store = h.headStore.Load()
+		ttl = time.Duration(h.HeadTTL)
+	}
+
+	if lookup.rule != learnHead || lookup.head {
+		if value, ok := store.Get(lookup.key); ok {
+			if rcMetrics != nil {
+				rcMetrics.requestsTotal.WithLabelValues(lookup.label, "hit").Inc()
+			}
+			return writeCachedResponse(w, lookup, value)
+		}
+		if rcMetrics != nil {
+			rcMetrics.requestsTotal.WithLabelValues(lookup.label, "miss").Inc()
+		}
+		w.Header().Set("X-Cache", "MISS")
+	}
+
+	buf := new(bytes.Buffer)
+	rec := caddyhttp.NewResponseRecorder(w, buf, func(status int, header http.Header) bool {
+		return status == http.StatusOK && header.Get("Content-Encoding") == ""
+	})
+	if err := next.ServeHTTP(rec, r); err != nil {
+		return err
+	}
+	if !rec.Buffered() {
+		return nil
+	}
+
+	if value, ok := h.cacheableValue(lookup, rec.Header(), buf.Bytes()); ok {
+		if lookup.head {
+			// A newer head may have replaced the head store meanwhile
+			store = h.headStore.Load()
+		}
+		store.Set(lookup.key, value, ttl)
+	}
+	return rec.WriteResponse()
+}
+
+// lookupFor returns how a request can be cached, or nil if it cannot
+func (h *ResponseCache) lookupFor(r *http.Request) (*cacheLookup, error) {
+	switch r.Method {
+	case http.MethodGet:
+		if !isCosmosBlockQuery(r) {
+			return nil, nil
+		}
+		return &cacheLookup{key: cacheKey(r, "", nil), label: "rest"}, nil
+
+	case http.MethodPost:
+		body, _, err := readRPCBody(r, 0)
+		if err != nil {
+			return nil, err
+		}
+		calls, batch, err := parseRPCCalls(body)
+		if errors.Is(err, errInvalidRPC) {
+			return nil, err
+		}
+		if err != nil || batch || len(calls) != 1 {
+			return nil, nil
+		}
+		call := calls[0]
+		rule, ok := rpcCacheRules[call.Method]
+		if !ok {
+			return nil, nil
+		}
+		return &cacheLookup{
+			key:    cacheKey(r, call.Method, call.Params),
+			label:  call.Method,
+			id:     call.ID,
+			rule:   rule,
+			params: call.Params,
+			head:   h.HeadTTL > 0 && isHeadQuery(rule, call.Params),
+		}, nil
+	}
+	return nil, nil
+}
+
+// isCosmosBlockQuery reports whether a GET targets a block at an explicit
+// height. Cosmos chains have instant finality, so these never change.
+func isCosmosBlockQuery(r *http.Request) bool {
+	if cosmosBlockPathPattern.MatchString(r.URL.Path) {
+		return true
+	}
+	switch strings.TrimSuffix(r.URL.Path, "/") {
+	case "/block", "/block_results":
+		_, err := strconv.ParseUint(r.URL.Query().Get("height"), 10, 64)
+		return err == nil
+	}
+	return false
+}
+
+// cacheKey hashes everything that determines the response: host, routed
+// chain, path, query, method and compacted params. JSON-RPC ids are not part
+// of the key.
+func cacheKey(r *http.Request, method string, params json.RawMessage) string {
+	var compact bytes.Buffer
+	if len(params) > 0 && json.Compact(&compact, params) != nil {
+		compact.Reset()
+		compact.Write(params)
+	}
+
+	sum := sha256.New()
+	for _, part := range []string{r.Host, routedChain(r.Context()), r.URL.Path, r.URL.RawQuery, method, compact.String()} {
+		sum.Write([]byte(part))
+		sum.Write([]byte{0})
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// cacheableValue returns the value to store for a successful response, or
+// false if the response is not known to be immutable
+func (h *ResponseCache) cacheableValue(lookup *cacheLookup, header http.Header, body []byte) ([]byte, bool) {
+	if lookup.label == "rest" {
+		// CometBFT RPC reports missing heights as a JSON-RPC error with status 200
+		var rpcResponse struct {
+			Error json.RawMessage `json:"error"`
+		}
+		if json.Unmarshal(body, &rpcResponse) == nil && len(rpcResponse.Error) > 0 {
+			return nil, false
+		}
+		value, err := json.Marshal(cachedResponse{ContentType: header.Get("Content-Type"), Body: body})
+		return value, err == nil
+	}
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil || len(response.Error) > 0 {
+		return nil, false
+	}
+	result := bytes.TrimSpace(response.Result)
+	if len(result) == 0 || bytes.Equal(result, []byte("null")) {
+		return nil, false
+	}
+
+	if lookup.head {
+		// Never cache a head older than one already seen
+		height, ok := h.observeHeadResult(lookup.rule, result)
+		return result, ok && height >= h.head.Load()
+	}
+
+	switch lookup.rule {
+	case cacheStatic, cacheByHash:
+		return result, true
+
+	case cacheByBlockParam:
+		var params []json.RawMessage
+		if err := json.Unmarshal(lookup.params, &params); err != nil || len(params) == 0 {
+			return nil, false
+		}
+		height, ok := parseBlockNumber(params[0])
+		return result, ok && h.isFinal(height)
+
+	case cacheByResultBlock:
+		var tx struct {
+			BlockNumber json.RawMessage `json:"blockNumber"`
+		}
+		if err := json.Unmarshal(result, &tx); err != nil {
+			return nil, false
+		}
+		height, ok := parseBlockNumber(tx.BlockNumber)
+		return result, ok && h.isFinal(height)
+
+	case cacheLogs:
+		return result, h.logsAreFinal(lookup.params)
+
+	case learnHead:
+		h.observeHeadResult(learnHead, result)
+	}
+	return nil, false
+}
+
+// logsAreFinal reports whether an eth_getLogs filter only covers final blocks
+func (h *ResponseCache) logsAreFinal(params json.RawMessage) bool {
+	var filters []struct {
+		BlockHash json.RawMessage `json:"blockHash"`
+		FromBlock json.RawMessage `json:"fromBlock"`
+		ToBlock   json.RawMessage `json:"toBlock"`
+	}
+	if err := json.Unmarshal(params, &filters); err != nil || len(filters) != 1 {
+		return false
+	}
+	filter := filters[0]
+	if len(filter.BlockHash) > 0 && !bytes.Equal(filter.BlockHash, []byte("null")) {
+		return true
+	}
+	if _, ok := parseBlockNumber(filter.FromBlock); !ok {
+		return false
+	}
+	to, ok := parseBlockNumber(filter.ToBlock)
+	return ok && h.isFinal(to)
+}
+
+// parseBlockNumber decodes a hex quantity such as "0x10"; block tags like
+// "latest" are not numbers
+func parseBlockNumber(raw json.RawMessage) (uint64, bool) {
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil || !strings.HasPrefix(value, "0x") {
+		return 0, false
+	}
+	height, err := strconv.ParseUint(value[2:], 16, 64)
+	return height, err == nil
+}
+
+// writeCachedResponse answers from the cache
+func writeCachedResponse(w http.ResponseWriter, lookup *cacheLookup, value []byte) error {
+	w.Header().Set("X-Cache", "HIT")
+
+	if lookup.label == "rest" {
+		var cached cachedResponse
+		if err := json.Unmarshal(value, &cached); err != nil {
+			return caddyhttp.Error(http.StatusInternalServerError, err)
+		}
+		if cached.ContentType != "" {
+			w.Header().Set("Content-Type", cached.ContentType)
+		}
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write(cached.Body)
+		return err
+	}
+
+	id := lookup.id
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Result  json.RawMessage `json:"result"`
+	}{"2.0", id, value})
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*ResponseCache)(nil)
+	_ caddy.CleanerUpper          = (*ResponseCache)(nil)
+	_ caddy.Validator             = (*ResponseCache)(nil)
+	_ caddyhttp.MiddlewareHandler = (*ResponseCache)(nil)
+)
@@ -0,0 +1,119 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	httpcaddyfile "github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+func init() {
+	// Register Caddyfile directive for this handler
+	httpcaddyfile.RegisterHandlerDirective("blockchain_cache", parseResponseCacheCaddyfile)
+	httpcaddyfile.RegisterDirectiveOrder("blockchain_cache", httpcaddyfile.Before, "reverse_proxy")
+}
+
+func parseResponseCacheCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	rc := new(ResponseCache)
+	if err := rc.UnmarshalCaddyfile(h.Dispenser); err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
+// UnmarshalCaddyfile implements caddyfile.Unmarshaler for blockchain_cache
+func (h *ResponseCache) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	for d.Next() {
+		for d.NextBlock(0) {
+			switch d.Val() {
+			case "ttl":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				dur, err := time.ParseDuration(d.Val())
+				if err != nil {
+					return d.Errf("invalid ttl: %v", err)
+				}
+				h.TTL = caddy.Duration(dur)
+
+			case "max_entries":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				n, err := strconv.Atoi(d.Val())
+				if err != nil {
+					return d.Errf("invalid max_entries: %v", err)
+				}
+				h.MaxEntries = n
+
+			case "finality_depth":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				depth, err := strconv.ParseUint(d.Val(), 10, 64)
+				if err != nil {
+					return d.Errf("invalid finality_depth: %v", err)
+				}
+				h.FinalityDepth = &depth
+
+			case "head_ttl":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				dur, err := time.ParseDuration(d.Val())
+				if err != nil {
+					return d.Errf("invalid head_ttl: %v", err)
+				}
+				h.HeadTTL = caddy.Duration(dur)
+
+			case "head_stream":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				h.HeadStream = d.Val()
+
+			case "redis":
+				// Syntax: redis <address> { password <password> db <n> }
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				h.Redis = &RedisCacheConfig{Address: d.Val()}
+				for d.NextBlock(1) {
+					switch d.Val() {
+					case "password":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						h.Redis.Password = d.Val()
+					case "db":
+						if !d.NextArg() {
+							return d.ArgErr()
+						}
+						db, err := strconv.Atoi(d.Val())
+						if err != nil {
+							return d.Errf("invalid redis db: %v", err)
+						}
+						h.Redis.DB = db
+					default:
+						return d.Errf("unknown redis directive: %s", d.Val())
+					}
+				}
+
+			default:
+				return d.Errf("unknown directive: %s", d.Val())
+			}
+		}
+	}
+
+	if err := h.Validate(); err != nil {
+		return fmt.Errorf("blockchain_cache validation: %w", err)
+	}
+	return nil
+}
+
+// Interface guard
+var _ caddyfile.Unmarshaler = (*ResponseCache)(nil)
@@ -0,0 +1,145 @@
+package blockchain_health
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// headStoreEntries bounds the short-lived head cache
+const headStoreEntries = 1000
+
+// Head stream reconnect backoff
+const (
+	headStreamMinBackoff = time.Second
+	headStreamMaxBackoff = 30 * time.Second
+)
+
+// isHeadQuery reports whether a call asks for the current chain head, such as
+// eth_blockNumber or eth_getBlockByNumber("latest")
+func isHeadQuery(rule rpcCacheRule, params json.RawMessage) bool {
+	switch rule {
+	case learnHead:
+		return true
+	case cacheByBlockParam:
+		var args []json.RawMessage
+		if err := json.Unmarshal(params, &args); err != nil || len(args) == 0 {
+			return false
+		}
+		var tag string
+		return json.Unmarshal(args[0], &tag) == nil && tag == "latest"
+	}
+	return false
+}
+
+// observeHeadResult learns the head from an eth_blockNumber result or the
+// number of a latest block and returns that height
+func (h *ResponseCache) observeHeadResult(rule rpcCacheRule, result json.RawMessage) (uint64, bool) {
+	number := result
+	if rule != learnHead {
+		var block struct {
+			Number json.RawMessage `json:"number"`
+		}
+		if json.Unmarshal(result, &block) != nil {
+			return 0, false
+		}
+		number = block.Number
+	}
+
+	height, ok := parseBlockNumber(number)
+	if ok {
+		h.observeHead(height)
+	}
+	return height, ok
+}
+
+// observeHead records a head height and invalidates the head cache when the
+// chain has moved past what it holds
+func (h *ResponseCache) observeHead(height uint64) {
+	for {
+		head := h.head.Load()
+		if height <= head {
+			return
+		}
+		if h.head.CompareAndSwap(head, height) {
+			h.invalidateHead()
+			return
+		}
+	}
+}
+
+// invalidateHead drops every cached head response
+func (h *ResponseCache) invalidateHead() {
+	h.headStore.Store(newMemoryStore(headStoreEntries))
+}
+
+// followHeadStream subscribes to newHeads on HeadStream and invalidates the
+// head cache on every new block, reconnecting with backoff until ctx ends
+func (h *ResponseCache) followHeadStream(ctx context.Context) {
+	backoff := headStreamMinBackoff
+	for {
+		err := h.readHeadStream(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if h.logger != nil {
+			h.logger.Warn("head stream disconnected",
+				zap.String("head_stream", h.HeadStream),
+				zap.Duration("retry_in", backoff),
+				zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, headStreamMaxBackoff)
+	}
+}
+
+// readHeadStream runs one newHeads subscription until it fails
+func (h *ResponseCache) readHeadStream(ctx context.Context) error {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, h.HeadStream, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Unblock ReadMessage when the handler is cleaned up
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	subscribe := `{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["newHeads"]}`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(subscribe)); err != nil {
+		return err
+	}
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		var notification struct {
+			Method string `json:"method"`
+			Params struct {
+				Result struct {
+					Number json.RawMessage `json:"number"`
+				} `json:"result"`
+			} `json:"params"`
+		}
+		if json.Unmarshal(data, &notification) != nil || notification.Method != "eth_subscription" {
+			continue
+		}
+
+		// Any new head invalidates, even a reorg to the same height
+		if height, ok := parseBlockNumber(notification.Params.Result.Number); ok {
+			h.observeHead(height)
+		}
+		h.invalidateHead()
+	}
+}
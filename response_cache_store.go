@@ -0,0 +1,338 @@
+package blockchain_health
+
+import (
+	"bufio"
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// responseStore stores cached responses by key
+type responseStore interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration)
+}
+
+// memoryStore is an in-process LRU response store
+type memoryStore struct {
+	mutex      sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List // front is most recently used
+}
+
+// memoryEntry is a value held by memoryStore
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// newMemoryStore creates an LRU store holding at most maxEntries responses
+func newMemoryStore(maxEntries int) *memoryStore {
+	return &memoryStore{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Get returns a live entry and marks it recently used
+func (s *memoryStore) Get(key string) ([]byte, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*memoryEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		s.order.Remove(element)
+		delete(s.entries, key)
+		return nil, false
+	}
+	s.order.MoveToFront(element)
+	return entry.value, true
+}
+
+// Set stores a value, evicting the least recently used entry when full
+func (s *memoryStore) Set(key string, value []byte, ttl time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+
+	if element, ok := s.entries[key]; ok {
+		entry := element.Value.(*memoryEntry)
+		entry.value = value
+		entry.expires = expires
+		s.order.MoveToFront(element)
+		return
+	}
+
+	s.entries[key] = s.order.PushFront(&memoryEntry{key: key, value: value, expires: expires})
+	for s.maxEntries > 0 && s.order.Len() > s.maxEntries {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryEntry).key)
+	}
+}
+
+// redisStore stores responses in Redis so they are shared between Caddy
+// instances. It speaks just enough RESP for AUTH, SELECT, GET and SET and
+// keeps a few idle connections for reuse. Store errors are treated as cache
+// misses so Redis outages never fail requests.
+//
+// When Redis can't be reached, the store is bypassed for a backoff that
+// doubles with each failure, so requests don't wait on the dial timeout
+// while Redis is down. Once it expires, one command probes Redis again.
+type redisStore struct {
+	address  string
+	password string
+	db       int
+	prefix   string
+	timeout  time.Duration
+	idle     chan *redisConn
+	now      func() time.Time
+
+	mutex    sync.Mutex
+	failures int       // consecutive connection failures
+	retryAt  time.Time // when Redis is tried again after a failure
+	probing  bool      // whether a command is probing Redis
+}
+
+// Redis store defaults
+const (
+	redisTimeout    = time.Second
+	redisMaxIdle    = 8
+	redisBackoffMin = time.Second
+	redisBackoffMax = 30 * time.Second
+)
+
+// redisKeyPrefix namespaces the keys written by this module
+const redisKeyPrefix = "blockchain_health:cache:"
+
+// errRedisUnavailable is returned while the store is bypassed after a failure
+var errRedisUnavailable = errors.New("redis unavailable")
+
+// redisError is an error reply of Redis. The connection stays usable.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// newRedisStore creates a Redis store; connections are opened lazily
+func newRedisStore(address, password string, db int) *redisStore {
+	return &redisStore{
+		address:  address,
+		password: password,
+		db:       db,
+		prefix:   redisKeyPrefix,
+		timeout:  redisTimeout,
+		idle:     make(chan *redisConn, redisMaxIdle),
+		now:      time.Now,
+	}
+}
+
+// Get returns the value stored in Redis, if any
+func (s *redisStore) Get(key string) ([]byte, bool) {
+	reply, err := s.do("GET", s.prefix+key)
+	if err != nil || reply == nil {
+		return nil, false
+	}
+	return reply, true
+}
+
+// Set stores the value in Redis with the ttl, if positive
+func (s *redisStore) Set(key string, value []byte, ttl time.Duration) {
+	args := []string{"SET", s.prefix + key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, _ = s.do(args...)
+}
+
+// do sends one command and returns a bulk or simple string reply
+func (s *redisStore) do(args ...string) ([]byte, error) {
+	probe, err := s.allow()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := s.exchange(args...)
+	s.record(probe, err)
+	return reply, err
+}
+
+// exchange sends a command on an idle connection, or a new one if none is
+// idle. Redis may have closed an idle connection, so a command failing on
+// one is retried once on a new connection.
+func (s *redisStore) exchange(args ...string) ([]byte, error) {
+	conn, err := s.get()
+	for attempt := 0; err == nil; attempt++ {
+		var reply []byte
+		reply, err = conn.roundTrip(s.timeout, args...)
+		var replyErr redisError
+		if err == nil || errors.As(err, &replyErr) {
+			s.put(conn)
+			return reply, err
+		}
+		_ = conn.conn.Close()
+		if !conn.reused || attempt > 0 {
+			break
+		}
+		conn, err = s.dial()
+	}
+	return nil, err
+}
+
+// get returns an idle connection or dials a new one
+func (s *redisStore) get() (*redisConn, error) {
+	select {
+	case conn := <-s.idle:
+		return conn, nil
+	default:
+		return s.dial()
+	}
+}
+
+// put keeps a connection for reuse, closing it if enough are idle
+func (s *redisStore) put(conn *redisConn) {
+	conn.reused = true
+	select {
+	case s.idle <- conn:
+	default:
+		_ = conn.conn.Close()
+	}
+}
+
+// allow reports whether Redis may be used, and whether the command probes
+// it after a failure
+func (s *redisStore) allow() (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.failures == 0 {
+		return false, nil
+	}
+	if s.probing || s.now().Before(s.retryAt) {
+		return false, errRedisUnavailable
+	}
+	s.probing = true
+	return true, nil
+}
+
+// record updates the backoff with the outcome of a command. Error replies
+// come from a reachable Redis, so they don't count as failures.
+func (s *redisStore) record(probe bool, err error) {
+	var replyErr redisError
+	failed := err != nil && !errors.As(err, &replyErr)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if probe {
+		s.probing = false
+	}
+	if !failed {
+		s.failures = 0
+		return
+	}
+	// Commands sent before the first failure don't extend the backoff
+	if s.failures > 0 && !probe {
+		return
+	}
+	s.failures++
+	backoff := redisBackoffMin
+	for i := 1; i < s.failures && backoff < redisBackoffMax; i++ {
+		backoff *= 2
+	}
+	s.retryAt = s.now().Add(min(backoff, redisBackoffMax))
+}
+
+// dial opens a connection to Redis and authenticates. Error replies to AUTH
+// and SELECT are returned as connection failures, as retrying won't help.
+func (s *redisStore) dial() (*redisConn, error) {
+	netConn, err := net.DialTimeout("tcp", s.address, s.timeout)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{conn: netConn, reader: bufio.NewReader(netConn)}
+
+	if s.password != "" {
+		if _, err := conn.roundTrip(s.timeout, "AUTH", s.password); err != nil {
+			_ = netConn.Close()
+			return nil, fmt.Errorf("redis auth: %v", err)
+		}
+	}
+	if s.db != 0 {
+		if _, err := conn.roundTrip(s.timeout, "SELECT", strconv.Itoa(s.db)); err != nil {
+			_ = netConn.Close()
+			return nil, fmt.Errorf("redis select: %v", err)
+		}
+	}
+	return conn, nil
+}
+
+// redisConn is one connection to Redis, used by one command at a time
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	reused bool // whether the connection was idle before
+}
+
+// roundTrip writes a command and reads its reply
+func (c *redisConn) roundTrip(timeout time.Duration, args ...string) ([]byte, error) {
+	if err := c.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	command := "*" + strconv.Itoa(len(args)) + "\r\n"
+	for _, arg := range args {
+		command += "$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n"
+	}
+	if _, err := io.WriteString(c.conn, command); err != nil {
+		return nil, err
+	}
+	return readRESPReply(c.reader)
+}
+
+// readRESPReply reads a simple string, error, integer or bulk string reply.
+// A null bulk string returns nil without error.
+func readRESPReply(reader *bufio.Reader) ([]byte, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed redis reply %q", line)
+	}
+	payload := line[1 : len(line)-2]
+
+	switch line[0] {
+	case '+', ':':
+		return []byte(payload), nil
+	case '-':
+		return nil, redisError(payload)
+	case '$':
+		size, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("malformed redis bulk length %q", payload)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		return data[:size], nil
+	default:
+		return nil, fmt.Errorf("unsupported redis reply type %q", line[0])
+	}
+}
@@ -0,0 +1,440 @@
+package blockchain_health
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/gorilla/websocket"
+)
+
+// fakeRPCNode answers JSON-RPC calls from a fixed table and counts requests
+type fakeRPCNode struct {
+	mutex   sync.Mutex
+	calls   int
+	results map[string]string
+}
+
+func (n *fakeRPCNode) ServeHTTP(w http.ResponseWriter, r *http.Request) error {
+	n.mutex.Lock()
+	n.calls++
+	n.mutex.Unlock()
+
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		_, err := w.Write([]byte(`{"block":{"header":{"height":"` + strings.TrimPrefix(r.URL.Path, "/cosmos/base/tendermint/v1beta1/blocks/") + `"}}}`))
+		return err
+	}
+
+	var call rpcMessage
+	if err := json.NewDecoder(r.Body).Decode(&call); err != nil {
+		return err
+	}
+	result, ok := n.results[call.Method]
+	if !ok {
+		result = "null"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err := w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(call.ID) + `,"result":` + result + `}`))
+	return err
+}
+
+func (n *fakeRPCNode) callCount() int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.calls
+}
+
+// cacheRequest sends one JSON-RPC call through the cache
+func cacheRequest(t *testing.T, h *ResponseCache, next caddyhttp.Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://rpc.example/", strings.NewReader(body))
+	if err := h.ServeHTTP(rec, r, next); err != nil {
+		t.Fatalf("ServeHTTP returned error: %v", err)
+	}
+	return rec
+}
+
+func TestResponseCache_StaticAndHashQueries(t *testing.T) {
+	node := &fakeRPCNode{results: map[string]string{
+		"eth_chainId":        `"0x1"`,
+		"eth_getBlockByHash": `{"number":"0x10","hash":"0xabc"}`,
+	}}
+	h := &ResponseCache{}
+	h.setupStore()
+
+	first := cacheRequest(t, h, node, `{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]}`)
+	second := cacheRequest(t, h, node, `{"jsonrpc":"2.0","id":"second","method":"eth_chainId","params":[]}`)
+	if node.callCount() != 1 {
+		t.Fatalf("expected the second call to be served from cache, node saw %d calls", node.callCount())
+	}
+	if first.Header().Get("X-Cache") != "MISS" || second.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("unexpected X-Cache headers: %q, %q", first.Header().Get("X-Cache"), second.Header().Get("X-Cache"))
+	}
+	if !strings.Contains(second.Body.String(), `"id":"second"`) || !strings.Contains(second.Body.String(), `"result":"0x1"`) {
+		t.Errorf("expected the cached result with the caller's id, got %s", second.Body.String())
+	}
+
+	// Params are part of the key, whitespace is not
+	cacheRequest(t, h, node, `{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByHash","params":["0xabc",false]}`)
+	cacheRequest(t, h, node, `{"jsonrpc":"2.0","id":2,"method":"eth_getBlockByHash","params":[ "0xabc", false ]}`)
+	cacheRequest(t, h, node, `{"jsonrpc":"2.0","id":3,"method":"eth_getBlockByHash","params":["0xabc",true]}`)
+	if node.callCount() != 3 {
+		t.Errorf("expected 3 node calls, got %d", node.callCount())
+	}
+
+	// Unknown objects are not cached
+	cacheRequest(t, h, node, `{"jsonrpc":"2.0","id":1,"method":"eth_getTransactionByHash","params":["0xdef"]}`)
+	cacheRequest(t, h, node, `{"jsonrpc":"2.0","id":1,"method":"eth_getTransactionByHash","params":["0xdef"]}`)
+	if node.callCount() != 5 {
+		t.Errorf("expected null results to be refetched, node saw %d calls", node.callCount())
+	}
+}
+
+func TestResponseCache_FinalityDepth(t *testing.T) {
+	node := &fakeRPCNode{results: map[string]string{
+		"eth_blockNumber":      `"0x64"`, // 100
+		"eth_getBlockByNumber": `{"number":"0x50"}`,
+	}}
+	depth := uint64(10)
+	h := &ResponseCache{FinalityDepth: &depth}
+	h.setupStore()
+
+	block80 := `{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["0x50",false]}`
+	block95 := `{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["0x5f",false]}`
+	latest := `{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["latest",false]}`
+
+	// Without a known head nothing numeric is final
+	cacheRequest(t, h, node, block80)
+	cacheRequest(t, h, node, block80)
+	if node.callCount() != 2 {
+		t.Fatalf("expected no caching before the head is known, got %d calls", node.callCount())
+	}
+
+	cacheRequest(t, h, node, `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`)
+	if h.head.Load() != 100 {
+		t.Fatalf("expected head 100 to be learned, got %d", h.head.Load())
+	}
+
+	for _, body := range []string{block80, block80, block95, block95, latest, latest} {
+		cacheRequest(t, h, node, body)
+	}
+	// block 80 is cached after one fetch; block 95 and latest are always fetched
+	if node.callCount() != 3+1+2+2 {
+		t.Errorf("expected only final blocks to be cached, node saw %d calls", node.callCount())
+	}
+}
+
+func TestResponseCache_CosmosBlocks(t *testing.T) {
+	node := &fakeRPCNode{}
+	h := &ResponseCache{}
+	h.setupStore()
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "http://api.example/cosmos/base/tendermint/v1beta1/blocks/42", nil)
+		if err := h.ServeHTTP(rec, r, node); err != nil {
+			t.Fatalf("ServeHTTP returned error: %v", err)
+		}
+		if !strings.Contains(rec.Body.String(), `"height":"42"`) || rec.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected response: %s", rec.Body.String())
+		}
+	}
+	if node.callCount() != 1 {
+		t.Errorf("expected the block to be cached, node saw %d calls", node.callCount())
+	}
+
+	// Latest block queries are not cached
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest(http.MethodGet, "http://api.example/cosmos/base/tendermint/v1beta1/blocks/latest", nil)
+		if err := h.ServeHTTP(httptest.NewRecorder(), r, node); err != nil {
+			t.Fatalf("ServeHTTP returned error: %v", err)
+		}
+	}
+	if node.callCount() != 3 {
+		t.Errorf("expected latest block queries to reach the node, got %d calls", node.callCount())
+	}
+}
+
+func TestMemoryStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	store := newMemoryStore(2)
+	store.Set("a", []byte("1"), 0)
+	store.Set("b", []byte("2"), 0)
+	store.Get("a")
+	store.Set("c", []byte("3"), 0)
+
+	if _, ok := store.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if _, ok := store.Get("a"); !ok {
+		t.Error("expected a to survive as recently used")
+	}
+
+	store.Set("d", []byte("4"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := store.Get("d"); ok {
+		t.Error("expected d to expire")
+	}
+}
+
+// startFakeRedis serves GET and SET from a map over RESP
+func startFakeRedis(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	var mutex sync.Mutex
+	data := make(map[string]string)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					header, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					count, _ := strconv.Atoi(strings.TrimSpace(header[1:]))
+					args := make([]string, count)
+					for i := range args {
+						sizeLine, _ := reader.ReadString('\n')
+						size, _ := strconv.Atoi(strings.TrimSpace(sizeLine[1:]))
+						buf := make([]byte, size+2)
+						if _, err := io.ReadFull(reader, buf); err != nil {
+							return
+						}
+						args[i] = string(buf[:size])
+					}
+
+					mutex.Lock()
+					switch strings.ToUpper(args[0]) {
+					case "SET":
+						data[args[1]] = args[2]
+						_, _ = conn.Write([]byte("+OK\r\n"))
+					case "GET":
+						if value, ok := data[args[1]]; ok {
+							_, _ = conn.Write([]byte("$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"))
+						} else {
+							_, _ = conn.Write([]byte("$-1\r\n"))
+						}
+					default:
+						_, _ = conn.Write([]byte("-ERR unknown command\r\n"))
+					}
+					mutex.Unlock()
+				}
+			}(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestRedisStore_GetSet(t *testing.T) {
+	store := newRedisStore(startFakeRedis(t), "", 0)
+
+	if _, ok := store.Get("missing"); ok {
+		t.Error("expected a miss for an unknown key")
+	}
+	store.Set("block", []byte("{\"number\":\"0x1\"}\r\nwith newline"), time.Minute)
+	value, ok := store.Get("block")
+	if !ok || string(value) != "{\"number\":\"0x1\"}\r\nwith newline" {
+		t.Errorf("unexpected value %q (ok=%v)", value, ok)
+	}
+
+	// An unreachable Redis is a miss, not an error
+	down := newRedisStore("127.0.0.1:1", "", 0)
+	if _, ok := down.Get("block"); ok {
+		t.Error("expected a miss when Redis is unreachable")
+	}
+}
+
+func TestRedisStore_Backoff(t *testing.T) {
+	now := time.Now()
+	store := newRedisStore("127.0.0.1:1", "", 0)
+	store.now = func() time.Time { return now }
+
+	if _, ok := store.Get("block"); ok {
+		t.Fatal("expected a miss when Redis is unreachable")
+	}
+	if store.failures != 1 || !store.retryAt.Equal(now.Add(redisBackoffMin)) {
+		t.Fatalf("expected a backoff of %v, got failures=%d retry_at=%v", redisBackoffMin, store.failures, store.retryAt.Sub(now))
+	}
+
+	// Redis is bypassed without dialing until the backoff expires
+	if _, err := store.do("GET", "block"); !errors.Is(err, errRedisUnavailable) {
+		t.Errorf("expected Redis to be bypassed, got %v", err)
+	}
+
+	// A failed probe doubles the backoff
+	now = now.Add(redisBackoffMin)
+	store.Get("block")
+	if store.failures != 2 || !store.retryAt.Equal(now.Add(2*redisBackoffMin)) {
+		t.Errorf("expected a backoff of %v, got failures=%d retry_at=%v", 2*redisBackoffMin, store.failures, store.retryAt.Sub(now))
+	}
+
+	// A successful probe closes the backoff and the connection is kept
+	store.address = startFakeRedis(t)
+	now = now.Add(2 * redisBackoffMin)
+	store.Set("block", []byte("0x1"), time.Minute)
+	if store.failures != 0 {
+		t.Errorf("expected the backoff to be cleared, got failures=%d", store.failures)
+	}
+	if value, ok := store.Get("block"); !ok || string(value) != "0x1" {
+		t.Errorf("unexpected value %q (ok=%v)", value, ok)
+	}
+	if len(store.idle) != 1 {
+		t.Errorf("expected one idle connection, got %d", len(store.idle))
+	}
+}
+
+func TestResponseCache_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_cache {
+		ttl 10m
+		max_entries 500
+		finality_depth 0
+		redis 127.0.0.1:6379 {
+			password secret
+			db 2
+		}
+	}`)
+
+	var h ResponseCache
+	if err := h.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if time.Duration(h.TTL) != 10*time.Minute || h.MaxEntries != 500 || h.FinalityDepth == nil || *h.FinalityDepth != 0 {
+		t.Errorf("unexpected config: ttl=%v max_entries=%d finality_depth=%v", h.TTL, h.MaxEntries, h.FinalityDepth)
+	}
+	if h.Redis == nil || h.Redis.Address != "127.0.0.1:6379" || h.Redis.Password != "secret" || h.Redis.DB != 2 {
+		t.Errorf("unexpected redis config: %+v", h.Redis)
+	}
+
+	d = caddyfile.NewTestDispenser(`blockchain_cache {
+		head_ttl 500ms
+		head_stream wss://eth.example/ws
+	}`)
+	h = ResponseCache{}
+	if err := h.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if time.Duration(h.HeadTTL) != 500*time.Millisecond || h.HeadStream != "wss://eth.example/ws" {
+		t.Errorf("unexpected head config: head_ttl=%v head_stream=%q", h.HeadTTL, h.HeadStream)
+	}
+
+	d = caddyfile.NewTestDispenser(`blockchain_cache {
+		head_stream wss://eth.example/ws
+	}`)
+	h = ResponseCache{}
+	if err := h.UnmarshalCaddyfile(d); err == nil {
+		t.Error("expected head_stream without head_ttl to be rejected")
+	}
+}
+
+func TestResponseCache_HeadQueries(t *testing.T) {
+	node := &fakeRPCNode{results: map[string]string{
+		"eth_blockNumber":      `"0x64"`,
+		"eth_getBlockByNumber": `{"number":"0x64"}`,
+	}}
+	h := &ResponseCache{HeadTTL: caddy.Duration(time.Minute)}
+	h.setupStore()
+
+	blockNumber := `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`
+	latest := `{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["latest",false]}`
+	for i := 0; i < 3; i++ {
+		cacheRequest(t, h, node, blockNumber)
+		cacheRequest(t, h, node, latest)
+	}
+	if node.callCount() != 2 {
+		t.Fatalf("expected head queries to be served from cache, node saw %d calls", node.callCount())
+	}
+
+	// A higher head drops the cached head answers
+	h.observeHead(0x65)
+	node.mutex.Lock()
+	node.results["eth_blockNumber"] = `"0x65"`
+	node.mutex.Unlock()
+	rec := cacheRequest(t, h, node, blockNumber)
+	if rec.Header().Get("X-Cache") != "MISS" || !strings.Contains(rec.Body.String(), `"0x65"`) {
+		t.Errorf("expected a fresh head after invalidation, got %s %s", rec.Header().Get("X-Cache"), rec.Body.String())
+	}
+	if rec := cacheRequest(t, h, node, blockNumber); rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("expected the new head to be cached, got %s", rec.Header().Get("X-Cache"))
+	}
+
+	// A lagging node's older head is passed through but not cached
+	cacheRequest(t, h, node, latest)
+	if rec := cacheRequest(t, h, node, latest); rec.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("expected a stale latest block not to be cached, got %s", rec.Header().Get("X-Cache"))
+	}
+}
+
+func TestResponseCache_HeadStreamInvalidates(t *testing.T) {
+	heads := make(chan string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var call rpcMessage
+		if err := conn.ReadJSON(&call); err != nil || call.Method != "eth_subscribe" {
+			return
+		}
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"result":"0xsub"}`))
+		for number := range heads {
+			notification := `{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"0xsub","result":{"number":"` + number + `"}}}`
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(notification)); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+	defer close(heads)
+
+	h := &ResponseCache{HeadTTL: caddy.Duration(time.Minute), HeadStream: "ws" + strings.TrimPrefix(server.URL, "http")}
+	h.setupStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.followHeadStream(ctx)
+
+	node := &fakeRPCNode{results: map[string]string{"eth_blockNumber": `"0x1"`}}
+	blockNumber := `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`
+	cacheRequest(t, h, node, blockNumber)
+	if rec := cacheRequest(t, h, node, blockNumber); rec.Header().Get("X-Cache") != "HIT" {
+		t.Fatalf("expected the head to be cached, got %s", rec.Header().Get("X-Cache"))
+	}
+
+	heads <- "0x2"
+	deadline := time.Now().Add(2 * time.Second)
+	for h.head.Load() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("head stream was not followed, head is %d", h.head.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if rec := cacheRequest(t, h, node, blockNumber); rec.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("expected a new head to invalidate the cache, got %s", rec.Header().Get("X-Cache"))
+	}
+}
@@ -0,0 +1,72 @@
+package blockchain_health
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// maxResponseBytes bounds how much of a node's response a health check
+// reads, so a misbehaving node can't exhaust gateway memory
+const maxResponseBytes = 10 << 20
+
+var (
+	// errHealthResponseTooLarge is returned for responses over maxResponseBytes
+	errHealthResponseTooLarge = errors.New("response too large")
+	// errNotJSON is returned for responses not declared as JSON, such as the
+	// HTML error page of a proxy in front of the node
+	errNotJSON = errors.New("response is not JSON")
+)
+
+// decodeJSON decodes a node's JSON response into v
+func decodeJSON(resp *http.Response, v any) error {
+	return decodeResponse(resp, v, false)
+}
+
+// decodeJSONNumbers decodes a node's JSON response into v, keeping numbers as
+// text so large quantities are not rounded
+func decodeJSONNumbers(resp *http.Response, v any) error {
+	return decodeResponse(resp, v, true)
+}
+
+// decodeResponse checks the content type before decoding, and reads at most
+// maxResponseBytes of the body
+func decodeResponse(resp *http.Response, v any, useNumber bool) error {
+	if err := checkJSONContentType(resp.Header.Get("Content-Type")); err != nil {
+		return err
+	}
+
+	body := &io.LimitedReader{R: resp.Body, N: maxResponseBytes + 1}
+	decoder := json.NewDecoder(body)
+	if useNumber {
+		decoder.UseNumber()
+	}
+	if err := decoder.Decode(v); err != nil {
+		if body.N <= 0 {
+			return fmt.Errorf("%w: over %d bytes", errHealthResponseTooLarge, maxResponseBytes)
+		}
+		return err
+	}
+	return nil
+}
+
+// checkJSONContentType accepts JSON media types. A missing content type is
+// accepted, as some nodes don't set one; text/plain is accepted as it is what
+// servers that don't set one end up sniffing JSON as.
+func checkJSONContentType(contentType string) error {
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("%w: invalid content type %q", errNotJSON, contentType)
+	}
+	if mediaType == "application/json" || mediaType == "text/plain" || strings.HasSuffix(mediaType, "+json") {
+		return nil
+	}
+	return fmt.Errorf("%w: content type %s", errNotJSON, mediaType)
+}
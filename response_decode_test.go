@@ -0,0 +1,82 @@
+package blockchain_health
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	response := func(contentType, body string) *http.Response {
+		header := http.Header{}
+		if contentType != "" {
+			header.Set("Content-Type", contentType)
+		}
+		return &http.Response{Header: header, Body: io.NopCloser(strings.NewReader(body))}
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantErr     error
+	}{
+		{name: "JSON", contentType: "application/json; charset=utf-8", body: `{"result":"ok"}`},
+		{name: "JSON suffix", contentType: "application/vnd.api+json", body: `{"result":"ok"}`},
+		{name: "sniffed JSON", contentType: "text/plain; charset=utf-8", body: `{"result":"ok"}`},
+		{name: "no content type", body: `{"result":"ok"}`},
+		{name: "HTML error page", contentType: "text/html", body: `<html>502 Bad Gateway</html>`, wantErr: errNotJSON},
+		{name: "invalid content type", contentType: "application/json; =", body: `{}`, wantErr: errNotJSON},
+	}
+	for _, tt := range tests {
+		var v struct {
+			Result string `json:"result"`
+		}
+		err := decodeJSON(response(tt.contentType, tt.body), &v)
+		if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && v.Result != "ok") {
+			t.Errorf("%s: expected %v, got %v (%q)", tt.name, tt.wantErr, err, v.Result)
+		}
+	}
+
+	// Decoding stops once the body exceeds the limit
+	body := io.MultiReader(strings.NewReader(`{"result":"`), io.LimitReader(neverEnding('x'), 2*maxResponseBytes))
+	var v map[string]string
+	err := decodeJSON(&http.Response{Header: http.Header{}, Body: io.NopCloser(body)}, &v)
+	if !errors.Is(err, errHealthResponseTooLarge) {
+		t.Errorf("expected errHealthResponseTooLarge, got %v", err)
+	}
+}
+
+// neverEnding is an endless reader of one byte
+type neverEnding byte
+
+func (b neverEnding) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(b)
+	}
+	return len(p), nil
+}
+
+func TestCosmosHandler_HTMLErrorPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html><body>Service temporarily unavailable</body></html>"))
+	}))
+	defer server.Close()
+
+	handler := NewCosmosHandler(5*time.Second, zaptest.NewLogger(t))
+	health, err := handler.CheckHealth(context.Background(), NodeConfig{Name: "cosmos", URL: server.URL, Type: NodeTypeCosmos})
+	if err != nil {
+		t.Fatalf("CheckHealth failed: %v", err)
+	}
+	if health.Healthy || !strings.Contains(health.LastError, errNotJSON.Error()) {
+		t.Errorf("expected an unhealthy node with a content type error, got healthy %v, error %q", health.Healthy, health.LastError)
+	}
+}
@@ -0,0 +1,318 @@
+package blockchain_health
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// errResponseTooLarge aborts a proxied response that exceeds max_response_size
+var errResponseTooLarge = errors.New("response exceeds max_response_size")
+
+// RPCGuard is a middleware placed in front of reverse_proxy that refuses
+// abusive JSON-RPC payloads before they reach the nodes: oversized bodies,
+// oversized batches, and calls to methods outside the allowlist or on the
+// denylist (e.g. admin_* or personal_*). Placing a guard in each pool's route
+// gives per-pool method lists; Tenants refine them per API key. WriteProtect
+// refuses transaction broadcasts while the pool is degraded. Refused requests
+// are answered with a JSON-RPC error. WebSocket upgrades are refused when
+// method rules or a batch limit are set, as frames are not inspected.
+type RPCGuard struct {
+	MaxBodySize      int64    `json:"max_body_size,omitempty"`
+	MaxResponseSize  int64    `json:"max_response_size,omitempty"`
+	MaxBatchSize     int      `json:"max_batch_size,omitempty"`
+	AllowedMethods   []string `json:"allowed_methods,omitempty"`
+	ForbiddenMethods []string `json:"forbidden_methods,omitempty"`
+
+	// TenantFrom resolves the API key identifying the tenant
+	TenantFrom []Source             `json:"tenant_from,omitempty"`
+	Tenants    map[string]MethodACL `json:"tenants,omitempty"`
+
+	WriteProtect *WriteProtect `json:"write_protect,omitempty"`
+}
+
+// MethodACL holds a tenant's method lists. A non-empty AllowedMethods
+// replaces the pool allowlist for the tenant; ForbiddenMethods are added to
+// the pool denylist, which always applies.
+type MethodACL struct {
+	AllowedMethods   []string `json:"allowed_methods,omitempty"`
+	ForbiddenMethods []string `json:"forbidden_methods,omitempty"`
+}
+
+func init() {
+	caddy.RegisterModule(&RPCGuard{})
+}
+
+var rgMetrics *RPCGuardMetrics
+
+// CaddyModule returns the Caddy module information.
+func (*RPCGuard) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.blockchain_rpc_guard",
+		New: func() caddy.Module { return new(RPCGuard) },
+	}
+}
+
+// Provision registers metrics
+func (h *RPCGuard) Provision(ctx caddy.Context) error {
+	var registerer prometheus.Registerer
+	if reg := ctx.GetMetricsRegistry(); reg != nil {
+		registerer = reg
+	} else {
+		registerer = prometheus.DefaultRegisterer
+	}
+	metrics, err := acquireRPCGuardMetrics(registerer)
+	if err != nil {
+		return err
+	}
+	rgMetrics = metrics
+	return nil
+}
+
+// Validate checks configuration correctness
+func (h *RPCGuard) Validate() error {
+	if h.MaxBodySize < 0 {
+		return fmt.Errorf("max_body_size must not be negative")
+	}
+	if h.MaxResponseSize < 0 {
+		return fmt.Errorf("max_response_size must not be negative")
+	}
+	if h.MaxBatchSize < 0 {
+		return fmt.Errorf("max_batch_size must not be negative")
+	}
+	if err := validateMethodPatterns(h.AllowedMethods, h.ForbiddenMethods); err != nil {
+		return err
+	}
+	for i, s := range h.TenantFrom {
+		switch s.Type {
+		case "placeholder", "header", "query":
+		default:
+			return fmt.Errorf("tenant_from[%d]: invalid type %q, must be placeholder, header, or query", i, s.Type)
+		}
+	}
+	if len(h.Tenants) > 0 && len(h.TenantFrom) == 0 {
+		return fmt.Errorf("tenants require tenant_from")
+	}
+	for key, acl := range h.Tenants {
+		if err := validateMethodPatterns(acl.AllowedMethods, acl.ForbiddenMethods); err != nil {
+			return fmt.Errorf("tenant %q: %w", key, err)
+		}
+	}
+	if h.WriteProtect != nil {
+		if err := h.WriteProtect.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateMethodPatterns checks that method patterns are well formed
+func validateMethodPatterns(lists ...[]string) error {
+	for _, list := range lists {
+		for _, pattern := range list {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid method pattern %q: %v", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ServeHTTP checks the request payload and forwards it if it is acceptable
+func (h *RPCGuard) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	// WebSocket frames are not inspected, so connections that would bypass
+	// the method rules and batch limits are refused
+	if websocket.IsWebSocketUpgrade(r) {
+		if h.hasMethodRules() || h.MaxBatchSize > 0 {
+			return h.reject(w, "websocket", http.StatusForbidden, nil, rpcCodeInvalidRequest,
+				"WebSocket connections are not available on this endpoint")
+		}
+		return next.ServeHTTP(w, r)
+	}
+
+	if h.MaxBodySize > 0 && r.ContentLength > h.MaxBodySize {
+		return h.reject(w, "body_size", http.StatusRequestEntityTooLarge, nil, rpcCodeInvalidRequest,
+			fmt.Sprintf("request body exceeds %d bytes", h.MaxBodySize))
+	}
+
+	inspect := h.MaxBatchSize > 0 || h.hasMethodRules() || h.WriteProtect != nil
+	if h.MaxBodySize > 0 || (inspect && r.Method == http.MethodPost) {
+		body, tooLarge, err := readRPCBody(r, h.MaxBodySize)
+		if err != nil {
+			return caddyhttp.Error(http.StatusBadRequest, err)
+		}
+		if tooLarge {
+			return h.reject(w, "body_size", http.StatusRequestEntityTooLarge, nil, rpcCodeInvalidRequest,
+				fmt.Sprintf("request body exceeds %d bytes", h.MaxBodySize))
+		}
+
+		// Bodies that are not JSON-RPC (e.g. Cosmos REST) are left to the node,
+		// but malformed JSON-RPC is refused, as nodes run the valid calls of a
+		// batch
+		if inspect && r.Method == http.MethodPost {
+			calls, batch, err := parseRPCCalls(body)
+			if errors.Is(err, errInvalidRPC) {
+				return h.reject(w, "invalid_request", http.StatusBadRequest, nil, rpcCodeInvalidRequest, errInvalidRPC.Error())
+			}
+			if err == nil {
+				if batch && h.MaxBatchSize > 0 && len(calls) > h.MaxBatchSize {
+					return h.reject(w, "batch_size", http.StatusBadRequest, nil, rpcCodeInvalidRequest,
+						fmt.Sprintf("batch of %d calls exceeds the limit of %d", len(calls), h.MaxBatchSize))
+				}
+				acl := h.tenantACL(r)
+				for _, call := range calls {
+					if reason := h.methodRefusal(call.Method, acl); reason != "" {
+						id := call.ID
+						if batch {
+							id = nil
+						}
+						return h.reject(w, reason, http.StatusOK, id, rpcCodeMethodNotFound,
+							fmt.Sprintf("method %s is not available", call.Method))
+					}
+				}
+				if h.WriteProtect != nil {
+					for _, call := range calls {
+						if !h.WriteProtect.isBroadcast(call.Method) {
+							continue
+						}
+						if state, ok := h.WriteProtect.activeState(); ok {
+							id := call.ID
+							if batch {
+								id = nil
+							}
+							return h.reject(w, "write_protected", http.StatusServiceUnavailable, id,
+								rpcCodeTransactionRejected, h.WriteProtect.refusal(state))
+						}
+						break
+					}
+				}
+			}
+		}
+	}
+
+	// Broadcasts that are not JSON-RPC are recognized by their path
+	if h.WriteProtect != nil && h.WriteProtect.isBroadcastRequest(r) {
+		if state, ok := h.WriteProtect.activeState(); ok {
+			return h.reject(w, "write_protected", http.StatusServiceUnavailable, nil,
+				rpcCodeTransactionRejected, h.WriteProtect.refusal(state))
+		}
+	}
+
+	if h.MaxResponseSize > 0 {
+		w = &limitedResponseWriter{
+			ResponseWriterWrapper: &caddyhttp.ResponseWriterWrapper{ResponseWriter: w},
+			limit:                 h.MaxResponseSize,
+		}
+	}
+	return next.ServeHTTP(w, r)
+}
+
+// hasMethodRules reports whether any method list is configured
+func (h *RPCGuard) hasMethodRules() bool {
+	return len(h.AllowedMethods) > 0 || len(h.ForbiddenMethods) > 0 || len(h.Tenants) > 0
+}
+
+// tenantACL returns the method lists of the request's tenant, if known
+func (h *RPCGuard) tenantACL(r *http.Request) *MethodACL {
+	if len(h.Tenants) == 0 {
+		return nil
+	}
+	key := resolveSource(r, h.TenantFrom)
+	if key == "" {
+		return nil
+	}
+	if acl, ok := h.Tenants[key]; ok {
+		return &acl
+	}
+	return nil
+}
+
+// methodRefusal returns why a method may not be proxied, or "" if it may
+func (h *RPCGuard) methodRefusal(method string, acl *MethodACL) string {
+	if _, denied := matchRPCMethod(h.ForbiddenMethods, method); denied {
+		return "forbidden_method"
+	}
+
+	allowed := h.AllowedMethods
+	if acl != nil {
+		if _, denied := matchRPCMethod(acl.ForbiddenMethods, method); denied {
+			return "forbidden_method"
+		}
+		if len(acl.AllowedMethods) > 0 {
+			allowed = acl.AllowedMethods
+		}
+	}
+	if len(allowed) > 0 {
+		if _, ok := matchRPCMethod(allowed, method); !ok {
+			return "method_not_allowed"
+		}
+	}
+	return ""
+}
+
+// reject answers with a JSON-RPC error and counts the rejection
+func (h *RPCGuard) reject(w http.ResponseWriter, reason string, status int, id []byte, code int, message string) error {
+	if rgMetrics != nil {
+		rgMetrics.rejectedTotal.WithLabelValues(reason).Inc()
+	}
+	return writeRPCError(w, status, id, code, message)
+}
+
+// limitedResponseWriter stops proxied responses larger than limit. Responses
+// that announce their size are replaced with a JSON-RPC error; streamed ones
+// are cut off once the limit is reached.
+type limitedResponseWriter struct {
+	*caddyhttp.ResponseWriterWrapper
+	limit   int64
+	written int64
+	blocked bool
+}
+
+// WriteHeader replaces responses whose Content-Length is over the limit
+func (w *limitedResponseWriter) WriteHeader(status int) {
+	if status >= http.StatusOK {
+		if length, err := strconv.ParseInt(w.Header().Get("Content-Length"), 10, 64); err == nil && length > w.limit {
+			w.blocked = true
+			if rgMetrics != nil {
+				rgMetrics.rejectedTotal.WithLabelValues("response_size").Inc()
+			}
+			w.Header().Del("Content-Length")
+			w.Header().Del("Content-Encoding")
+			_ = writeRPCError(w.ResponseWriterWrapper, http.StatusBadGateway, nil, rpcCodeInternalError,
+				fmt.Sprintf("response exceeds %d bytes", w.limit))
+			return
+		}
+	}
+	w.ResponseWriterWrapper.WriteHeader(status)
+}
+
+// Write forwards the body until the limit is reached
+func (w *limitedResponseWriter) Write(p []byte) (int, error) {
+	if w.blocked {
+		return 0, errResponseTooLarge
+	}
+	if w.written+int64(len(p)) > w.limit {
+		w.blocked = true
+		if rgMetrics != nil {
+			rgMetrics.rejectedTotal.WithLabelValues("response_size").Inc()
+		}
+		return 0, errResponseTooLarge
+	}
+	n, err := w.ResponseWriterWrapper.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*RPCGuard)(nil)
+	_ caddy.Validator             = (*RPCGuard)(nil)
+	_ caddyhttp.MiddlewareHandler = (*RPCGuard)(nil)
+)
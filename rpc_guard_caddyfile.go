@@ -0,0 +1,177 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	httpcaddyfile "github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+func init() {
+	// Register Caddyfile directive for this handler
+	httpcaddyfile.RegisterHandlerDirective("blockchain_rpc_guard", parseRPCGuardCaddyfile)
+	httpcaddyfile.RegisterDirectiveOrder("blockchain_rpc_guard", httpcaddyfile.Before, "reverse_proxy")
+}
+
+func parseRPCGuardCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	rg := new(RPCGuard)
+	if err := rg.UnmarshalCaddyfile(h.Dispenser); err != nil {
+		return nil, err
+	}
+	return rg, nil
+}
+
+// UnmarshalCaddyfile implements caddyfile.Unmarshaler for blockchain_rpc_guard
+func (h *RPCGuard) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	for d.Next() {
+		for d.NextBlock(0) {
+			switch d.Val() {
+			case "max_body_size", "max_response_size":
+				name := d.Val()
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				size, err := parseByteSize(d.Val())
+				if err != nil {
+					return d.Errf("invalid %s: %v", name, err)
+				}
+				if name == "max_body_size" {
+					h.MaxBodySize = size
+				} else {
+					h.MaxResponseSize = size
+				}
+
+			case "max_batch_size":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				n, err := strconv.Atoi(d.Val())
+				if err != nil {
+					return d.Errf("invalid max_batch_size: %v", err)
+				}
+				h.MaxBatchSize = n
+
+			case "allowed_methods":
+				args := d.RemainingArgs()
+				if len(args) == 0 {
+					return d.ArgErr()
+				}
+				h.AllowedMethods = append(h.AllowedMethods, args...)
+
+			case "forbidden_methods":
+				args := d.RemainingArgs()
+				if len(args) == 0 {
+					return d.ArgErr()
+				}
+				h.ForbiddenMethods = append(h.ForbiddenMethods, args...)
+
+			case "tenant_from":
+				s, err := parseSource(d)
+				if err != nil {
+					return err
+				}
+				h.TenantFrom = append(h.TenantFrom, s)
+
+			case "tenant":
+				// Syntax: tenant <api_key> { allowed_methods ... forbidden_methods ... }
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				key := d.Val()
+				var acl MethodACL
+				for d.NextBlock(1) {
+					option := d.Val()
+					args := d.RemainingArgs()
+					if len(args) == 0 {
+						return d.ArgErr()
+					}
+					switch option {
+					case "allowed_methods":
+						acl.AllowedMethods = append(acl.AllowedMethods, args...)
+					case "forbidden_methods":
+						acl.ForbiddenMethods = append(acl.ForbiddenMethods, args...)
+					default:
+						return d.Errf("unknown tenant directive: %s", option)
+					}
+				}
+				if h.Tenants == nil {
+					h.Tenants = make(map[string]MethodACL)
+				}
+				h.Tenants[key] = acl
+
+			case "write_protect":
+				// Syntax: write_protect <pool> { states ... methods ... }
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				protect := &WriteProtect{Pool: d.Val()}
+				for d.NextBlock(1) {
+					option := d.Val()
+					args := d.RemainingArgs()
+					if len(args) == 0 {
+						return d.ArgErr()
+					}
+					switch option {
+					case "states":
+						for _, state := range args {
+							protect.States = append(protect.States, PoolState(state))
+						}
+					case "methods":
+						protect.Methods = append(protect.Methods, args...)
+					default:
+						return d.Errf("unknown write_protect directive: %s", option)
+					}
+				}
+				h.WriteProtect = protect
+
+			default:
+				return d.Errf("unknown directive: %s", d.Val())
+			}
+		}
+	}
+
+	if err := h.Validate(); err != nil {
+		return fmt.Errorf("blockchain_rpc_guard validation: %w", err)
+	}
+	return nil
+}
+
+// byteUnits maps size suffixes to multipliers, following Caddy's convention
+// of decimal KB/MB/GB and binary KiB/MiB/GiB
+var byteUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"kib", 1 << 10},
+	{"mib", 1 << 20},
+	{"gib", 1 << 30},
+	{"kb", 1000},
+	{"mb", 1000 * 1000},
+	{"gb", 1000 * 1000 * 1000},
+	{"b", 1},
+}
+
+// parseByteSize parses sizes like 512, 64KB or 5MiB
+func parseByteSize(s string) (int64, error) {
+	value := strings.ToLower(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range byteUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * multiplier, nil
+}
+
+// Interface guard
+var _ caddyfile.Unmarshaler = (*RPCGuard)(nil)
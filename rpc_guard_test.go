@@ -0,0 +1,379 @@
+package blockchain_health
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+// echoHandler answers with the request body it received
+var echoHandler = caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+})
+
+func TestRPCGuard_Requests(t *testing.T) {
+	h := &RPCGuard{
+		MaxBodySize:      128,
+		MaxBatchSize:     2,
+		ForbiddenMethods: []string{"admin_*", "personal_*", "txpool_content"},
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "allowed call is proxied unchanged",
+			body:       `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"method":"eth_blockNumber"`,
+		},
+		{
+			name:       "forbidden method by wildcard",
+			body:       `{"jsonrpc":"2.0","id":7,"method":"admin_peers","params":[]}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"jsonrpc":"2.0","id":7,"error":{"code":-32601,"message":"method admin_peers is not available"}}`,
+		},
+		{
+			name:       "forbidden method by exact name",
+			body:       `{"jsonrpc":"2.0","id":"a","method":"txpool_content","params":[]}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"code":-32601`,
+		},
+		{
+			name:       "forbidden method inside a batch",
+			body:       `[{"jsonrpc":"2.0","id":1,"method":"eth_chainId"},{"jsonrpc":"2.0","id":2,"method":"personal_sign"}]`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"id":null`,
+		},
+		{
+			name:       "batch over the limit",
+			body:       `[{"id":1,"method":"eth_chainId"},{"id":2,"method":"eth_chainId"},{"id":3,"method":"eth_chainId"}]`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "batch of 3 calls exceeds the limit of 2",
+		},
+		{
+			name:       "body over the limit",
+			body:       `{"jsonrpc":"2.0","id":1,"method":"eth_call","params":["` + strings.Repeat("a", 200) + `"]}`,
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantBody:   "request body exceeds 128 bytes",
+		},
+		{
+			name:       "batch with a forbidden call and a non-object element",
+			body:       `[{"jsonrpc":"2.0","id":1,"method":"admin_peers"},1]`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"code":-32600`,
+		},
+		{
+			name:       "batch over the limit with malformed elements",
+			body:       `[{"id":1,"method":"eth_chainId"},{"id":2,"method":"eth_chainId"},null,5]`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"code":-32600`,
+		},
+		{
+			name:       "batch with a mistyped method",
+			body:       `[{"id":1,"method":"eth_chainId"},{"id":2,"method":["admin_peers"]}]`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"code":-32600`,
+		},
+		{
+			name:       "malformed JSON-RPC call",
+			body:       `{"jsonrpc":"2.0","id":1,"method":"admin_peers",}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `"code":-32600`,
+		},
+		{
+			name:       "non JSON-RPC body is passed through",
+			body:       `tx=0xdeadbeef`,
+			wantStatus: http.StatusOK,
+			wantBody:   `tx=0xdeadbeef`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "http://127.0.0.1/", strings.NewReader(tt.body))
+			r.ContentLength = -1 // exercise the streaming limit, not just Content-Length
+
+			if err := h.ServeHTTP(rec, r, echoHandler); err != nil {
+				t.Fatalf("ServeHTTP returned error: %v", err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("expected body to contain %s, got %s", tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRPCGuard_ResponseSize(t *testing.T) {
+	h := &RPCGuard{MaxResponseSize: 16}
+	large := strings.Repeat("x", 64)
+
+	// Responses announcing their size are replaced with a JSON-RPC error
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://127.0.0.1/", strings.NewReader(`{}`))
+	err := h.ServeHTTP(rec, r, caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Length", "64")
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(large))
+		if err != errResponseTooLarge {
+			t.Errorf("expected errResponseTooLarge, got %v", err)
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("ServeHTTP returned error: %v", err)
+	}
+	if rec.Code != http.StatusBadGateway || !strings.Contains(rec.Body.String(), "response exceeds 16 bytes") {
+		t.Errorf("expected a 502 JSON-RPC error, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Streamed responses are cut off at the limit
+	rec = httptest.NewRecorder()
+	err = h.ServeHTTP(rec, r, caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte(large))
+		return err
+	}))
+	if err != errResponseTooLarge {
+		t.Fatalf("expected errResponseTooLarge, got %v", err)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("expected nothing to be written, got %d bytes", rec.Body.Len())
+	}
+}
+
+func TestRPCGuard_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_rpc_guard {
+		max_body_size 1MiB
+		max_response_size 10MB
+		max_batch_size 50
+		forbidden_methods admin_* personal_*
+		forbidden_methods txpool_content
+	}`)
+
+	var h RPCGuard
+	if err := h.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if h.MaxBodySize != 1<<20 || h.MaxResponseSize != 10*1000*1000 || h.MaxBatchSize != 50 {
+		t.Errorf("unexpected limits: %+v", h)
+	}
+	if len(h.ForbiddenMethods) != 3 || h.ForbiddenMethods[2] != "txpool_content" {
+		t.Errorf("unexpected forbidden methods: %v", h.ForbiddenMethods)
+	}
+
+	d = caddyfile.NewTestDispenser(`blockchain_rpc_guard {
+		forbidden_methods admin_[
+	}`)
+	if err := new(RPCGuard).UnmarshalCaddyfile(d); err == nil {
+		t.Error("expected an invalid pattern to be rejected")
+	}
+}
+
+func TestRPCGuard_MethodACLs(t *testing.T) {
+	h := &RPCGuard{
+		AllowedMethods:   []string{"eth_*", "net_version"},
+		ForbiddenMethods: []string{"eth_sign*"},
+		TenantFrom:       []Source{{Type: "header", Name: "X-API-Key"}},
+		Tenants: map[string]MethodACL{
+			"archive-key": {AllowedMethods: []string{"eth_*", "debug_trace*"}},
+			"limited-key": {ForbiddenMethods: []string{"eth_getLogs"}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		apiKey  string
+		method  string
+		allowed bool
+	}{
+		{"pool allowlist by wildcard", "", "eth_blockNumber", true},
+		{"pool allowlist by name", "", "net_version", true},
+		{"outside the pool allowlist", "", "debug_traceTransaction", false},
+		{"pool denylist beats allowlist", "", "eth_signTransaction", false},
+		{"unknown tenant uses pool lists", "other-key", "debug_traceTransaction", false},
+		{"tenant allowlist replaces pool allowlist", "archive-key", "debug_traceTransaction", true},
+		{"tenant allowlist narrows too", "archive-key", "net_version", false},
+		{"pool denylist applies to tenants", "archive-key", "eth_sign", false},
+		{"tenant denylist", "limited-key", "eth_getLogs", false},
+		{"tenant without allowlist keeps pool allowlist", "limited-key", "eth_call", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			body := `{"jsonrpc":"2.0","id":1,"method":"` + tt.method + `","params":[]}`
+			r := httptest.NewRequest(http.MethodPost, "http://127.0.0.1/", strings.NewReader(body))
+			if tt.apiKey != "" {
+				r.Header.Set("X-API-Key", tt.apiKey)
+			}
+
+			if err := h.ServeHTTP(rec, r, echoHandler); err != nil {
+				t.Fatalf("ServeHTTP returned error: %v", err)
+			}
+			refused := strings.Contains(rec.Body.String(), `"code":-32601`)
+			if refused == tt.allowed {
+				t.Errorf("expected allowed=%v for %s, got %s", tt.allowed, tt.method, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRPCGuard_WebSocket(t *testing.T) {
+	upgrade := func() *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "http://127.0.0.1/", nil)
+		r.Header.Set("Connection", "Upgrade")
+		r.Header.Set("Upgrade", "websocket")
+		return r
+	}
+
+	// Frames can't be checked against method rules, so the upgrade is refused
+	rec := httptest.NewRecorder()
+	guarded := &RPCGuard{ForbiddenMethods: []string{"admin_*"}}
+	if err := guarded.ServeHTTP(rec, upgrade(), echoHandler); err != nil {
+		t.Fatalf("ServeHTTP returned error: %v", err)
+	}
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), `"code":-32600`) {
+		t.Errorf("expected the upgrade to be refused, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Size limits alone don't depend on the frames
+	rec = httptest.NewRecorder()
+	sized := &RPCGuard{MaxBodySize: 1024}
+	if err := sized.ServeHTTP(rec, upgrade(), echoHandler); err != nil {
+		t.Fatalf("ServeHTTP returned error: %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the upgrade to be passed on, got %d", rec.Code)
+	}
+}
+
+func TestRPCGuard_UnmarshalCaddyfileTenants(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_rpc_guard {
+		allowed_methods eth_* net_version
+		tenant_from header X-API-Key
+		tenant archive-key {
+			allowed_methods eth_* debug_*
+			forbidden_methods debug_setHead
+		}
+	}`)
+
+	var h RPCGuard
+	if err := h.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if len(h.AllowedMethods) != 2 || len(h.TenantFrom) != 1 || h.TenantFrom[0].Name != "X-API-Key" {
+		t.Errorf("unexpected config: %+v", h)
+	}
+	acl, ok := h.Tenants["archive-key"]
+	if !ok || len(acl.AllowedMethods) != 2 || len(acl.ForbiddenMethods) != 1 {
+		t.Errorf("unexpected tenant config: %+v", h.Tenants)
+	}
+
+	d = caddyfile.NewTestDispenser(`blockchain_rpc_guard {
+		tenant some-key {
+			allowed_methods eth_*
+		}
+	}`)
+	if err := new(RPCGuard).UnmarshalCaddyfile(d); err == nil {
+		t.Error("expected tenants without tenant_from to be rejected")
+	}
+}
+
+func TestRPCGuard_WriteProtect(t *testing.T) {
+	pool := createTestUpstream(nil, zaptest.NewLogger(t))
+	pool.config.PoolState = PoolStateConfig{DegradedBelow: 0.75, CriticalBelow: 0.5}
+	state, err := registerChainState("write-protect-test", pool.healthChecker)
+	if err != nil {
+		t.Fatalf("Failed to register pool state: %v", err)
+	}
+	pool.healthChecker.state = state
+	defer releaseChainState(state, pool.healthChecker)
+
+	h := &RPCGuard{WriteProtect: &WriteProtect{Pool: "write-protect-test"}}
+	if err := h.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if err := h.ServeHTTP(rec, r, echoHandler); err != nil {
+			t.Fatalf("ServeHTTP returned error: %v", err)
+		}
+		return rec
+	}
+	broadcast := `{"jsonrpc":"2.0","id":3,"method":"eth_sendRawTransaction","params":["0x01"]}`
+
+	pool.healthChecker.updatePoolState(poolResults(4, 4))
+	if rec := send(http.MethodPost, "http://127.0.0.1/", broadcast); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "eth_sendRawTransaction") {
+		t.Errorf("expected the broadcast to be proxied while healthy, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	pool.healthChecker.updatePoolState(poolResults(2, 4))
+	rec := send(http.MethodPost, "http://127.0.0.1/", broadcast)
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"id":3,"error":{"code":-32003,"message":"chain write-protect-test is degraded`) {
+		t.Errorf("expected the broadcast to be refused, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := send(http.MethodPost, "http://127.0.0.1/", `{"jsonrpc":"2.0","id":4,"method":"eth_getBalance","params":[]}`); rec.Code != http.StatusOK {
+		t.Errorf("expected reads to be served, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := send(http.MethodGet, "http://127.0.0.1/broadcast_tx_sync?tx=0x01", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected a CometBFT URI broadcast to be refused, got %d", rec.Code)
+	}
+	if rec := send(http.MethodPost, "http://127.0.0.1/cosmos/tx/v1beta1/txs", `{"tx_bytes":"AQ==","mode":"BROADCAST_MODE_SYNC"}`); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected a Cosmos REST broadcast to be refused, got %d", rec.Code)
+	}
+	if rec := send(http.MethodGet, "http://127.0.0.1/cosmos/tx/v1beta1/txs/ABCD", ""); rec.Code != http.StatusOK {
+		t.Errorf("expected a Cosmos REST tx lookup to be served, got %d", rec.Code)
+	}
+
+	// Once the pool is down the request is left to reverse_proxy's errors
+	pool.healthChecker.updatePoolState(poolResults(0, 4))
+	if rec := send(http.MethodPost, "http://127.0.0.1/", broadcast); rec.Code != http.StatusOK {
+		t.Errorf("expected a down pool not to be write protected by default, got %d", rec.Code)
+	}
+}
+
+func TestRPCGuard_UnmarshalCaddyfileWriteProtect(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_rpc_guard {
+		write_protect ethereum {
+			states degraded critical down
+			methods eth_sendRawTransaction eth_sendBundle
+		}
+	}`)
+	var h RPCGuard
+	if err := h.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if h.WriteProtect == nil || h.WriteProtect.Pool != "ethereum" || len(h.WriteProtect.States) != 3 || len(h.WriteProtect.Methods) != 2 {
+		t.Errorf("unexpected write protection: %+v", h.WriteProtect)
+	}
+
+	d = caddyfile.NewTestDispenser(`blockchain_rpc_guard {
+		write_protect ethereum {
+			states lagging
+		}
+	}`)
+	if err := (&RPCGuard{}).UnmarshalCaddyfile(d); err == nil {
+		t.Error("expected an unknown pool state to be rejected")
+	}
+}
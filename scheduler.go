@@ -0,0 +1,137 @@
+package blockchain_health
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// nodeSchedule is the background schedule of one node
+type nodeSchedule struct {
+	node   NodeConfig
+	cancel context.CancelFunc
+}
+
+// scheduleNodes checks every node on its own schedule until the health
+// checker is stopped, so a slow node doesn't hold back the results of the
+// fast ones. The first checks are spread over the interval rather than
+// probing every node at once.
+func (h *HealthChecker) scheduleNodes(interval time.Duration) {
+	h.scheduleMutex.Lock()
+	h.interval = interval
+	h.schedules = make(map[string]*nodeSchedule)
+	h.scheduleMutex.Unlock()
+
+	h.syncSchedules()
+}
+
+// syncSchedules starts schedules for nodes added since the last call, and
+// stops those of removed nodes. A node whose config changed is restarted.
+func (h *HealthChecker) syncSchedules() {
+	h.scheduleMutex.Lock()
+	defer h.scheduleMutex.Unlock()
+	if h.schedules == nil {
+		return
+	}
+
+	nodes := h.config.nodeList()
+	initial := len(h.schedules) == 0
+	current := make(map[string]bool, len(nodes))
+	for i, node := range nodes {
+		key := node.key()
+		current[key] = true
+		if schedule, ok := h.schedules[key]; ok {
+			if reflect.DeepEqual(schedule.node, node) {
+				continue
+			}
+			schedule.cancel()
+		}
+
+		var offset time.Duration
+		if initial {
+			offset = h.interval * time.Duration(i) / time.Duration(len(nodes))
+		}
+		ctx, cancel := context.WithCancel(h.ctx)
+		h.schedules[key] = &nodeSchedule{node: node, cancel: cancel}
+		interval := h.interval
+		h.goBackground(func() { h.scheduleNode(ctx, node, interval, offset) })
+	}
+
+	for key, schedule := range h.schedules {
+		if !current[key] {
+			schedule.cancel()
+			delete(h.schedules, key)
+		}
+	}
+}
+
+// scheduleNode probes one node every interval, starting after offset, and
+// refreshes its cached result until ctx ends. A tick
+// that arrives while the node's previous check is still in progress is
+// skipped, so a node slower than the interval isn't probed more and more.
+func (h *HealthChecker) scheduleNode(ctx context.Context, node NodeConfig, interval, offset time.Duration) {
+	start := time.NewTimer(offset)
+	defer start.Stop()
+	select {
+	case <-start.C:
+	case <-ctx.Done():
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var pending *nodeCheck
+	var done <-chan struct{}
+	var started time.Time
+	run := func() {
+		if pending != nil {
+			h.logger.Warn("skipping health check, previous check still in progress",
+				zap.String("node", node.Name),
+				zap.Duration("check_interval", interval))
+			if h.metrics != nil {
+				h.metrics.checksSkipped.Inc()
+			}
+			return
+		}
+		pending = h.startCheck(node, true)
+		done = pending.done
+		started = time.Now()
+	}
+
+	run()
+	for {
+		select {
+		case <-ticker.C:
+			run()
+
+		case <-done:
+			h.recordTick()
+			h.processLatestResults(pending.health)
+			if h.metrics != nil {
+				h.metrics.RecordCheckDuration(time.Since(started).Seconds())
+			}
+			pending, done = nil, nil
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// processLatestResults compares a node's fresh result with the latest cached
+// results of the other nodes
+func (h *HealthChecker) processLatestResults(fresh *NodeHealth) {
+	results := []*NodeHealth{fresh}
+	for _, node := range h.config.nodeList() {
+		if node.Name == fresh.Name {
+			continue
+		}
+		if health := h.cache.Get(node.key()); health != nil {
+			results = append(results, health)
+		}
+	}
+	h.processResults(results)
+}
@@ -0,0 +1,175 @@
+package blockchain_health
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Scoring defaults applied when scoring is enabled without explicit values
+const (
+	defaultScoreCutoff = 0.5
+	defaultMinPeers    = 10
+)
+
+// scoreSignals holds the normalized (0-1, higher is better) inputs for a node score
+type scoreSignals struct {
+	height    float64
+	latency   float64
+	errorRate float64
+	peers     float64
+	hasPeers  bool
+}
+
+// weightedScore combines the signals using the configured weights. Signals
+// that are unavailable (peer count not reported) are left out of the average.
+func weightedScore(cfg ScoringConfig, s scoreSignals) float64 {
+	total := cfg.HeightWeight*s.height + cfg.LatencyWeight*s.latency + cfg.ErrorRateWeight*s.errorRate
+	weights := cfg.HeightWeight + cfg.LatencyWeight + cfg.ErrorRateWeight
+	if s.hasPeers {
+		total += cfg.PeerWeight * s.peers
+		weights += cfg.PeerWeight
+	}
+	if weights <= 0 {
+		return 1
+	}
+	return total / weights
+}
+
+// clampUnit limits v to the range [0, 1]
+func clampUnit(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// scoringEnabled reports whether nodes within the height threshold are ranked by weighted score
+func (h *HealthChecker) scoringEnabled() bool {
+	return h.config.Scoring.Enabled
+}
+
+// scoreMaxLatency returns the latency at which the latency signal reaches zero
+func (h *HealthChecker) scoreMaxLatency() time.Duration {
+	if d, err := time.ParseDuration(h.config.Scoring.MaxLatency); err == nil && d > 0 {
+		return d
+	}
+	if d, err := time.ParseDuration(h.config.HealthCheck.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return 5 * time.Second
+}
+
+// signalsFor computes the normalized scoring signals for a node
+func (h *HealthChecker) signalsFor(health *NodeHealth) scoreSignals {
+	var s scoreSignals
+
+	// Height: 1 at the pool leader, 0 once the node is past the height threshold
+	threshold := h.config.BlockValidation.HeightThreshold
+	if node := findNode(h.config.nodeList(), health.Name); node != nil {
+		threshold = h.heightThreshold(nodeChain(*node))
+	}
+	if threshold < 1 {
+		threshold = 1
+	}
+	behind := health.BlocksBehindPool
+	if behind < 0 {
+		behind = 0
+	}
+	s.height = clampUnit(1 - float64(behind)/float64(threshold+1))
+
+	// Latency: linear from 0 (1.0) to the max latency (0.0)
+	s.latency = clampUnit(1 - float64(health.ResponseTime)/float64(h.scoreMaxLatency()))
+
+	// Error rate: share of successful checks
+	s.errorRate = clampUnit(1 - h.errorRate(health.Name))
+
+	// Peers: saturates at the configured minimum
+	if health.PeerCount != nil {
+		minPeers := h.config.Scoring.MinPeers
+		if minPeers <= 0 {
+			minPeers = defaultMinPeers
+		}
+		s.peers = clampUnit(float64(*health.PeerCount) / float64(minPeers))
+		s.hasPeers = true
+	}
+
+	return s
+}
+
+// applyScores scores every reachable node and marks nodes below the cutoff
+// unhealthy. Nodes that already failed their probe or the height threshold
+// score zero.
+func (h *HealthChecker) applyScores(results []*NodeHealth) {
+	cutoff := defaultScoreCutoff
+	if h.config.Scoring.Cutoff != nil {
+		cutoff = *h.config.Scoring.Cutoff
+	}
+
+	for _, health := range results {
+		if health == nil {
+			continue
+		}
+
+		if !health.Healthy {
+			health.Score = 0
+		} else {
+			health.Score = weightedScore(h.config.Scoring, h.signalsFor(health))
+			if health.Score < cutoff {
+				health.Healthy = false
+				health.LastError = fmt.Sprintf("health score %.2f below cutoff %.2f", health.Score, cutoff)
+				h.logger.Debug("node score below cutoff",
+					zap.String("node", health.Name),
+					zap.Float64("score", health.Score),
+					zap.Float64("cutoff", cutoff))
+			}
+		}
+
+		if h.metrics != nil {
+			h.metrics.nodeScore.WithLabelValues(health.Name).Set(health.Score)
+		}
+	}
+}
+
+// collectPeerCount fetches the peer count for a healthy node when the peer
+// signal is in use. Failures leave the peer count unset.
+func (h *HealthChecker) collectPeerCount(ctx context.Context, node NodeConfig, health *NodeHealth) {
+	if !h.scoringEnabled() || h.config.Scoring.PeerWeight <= 0 || !health.Healthy {
+		return
+	}
+
+	var handler ProtocolHandler
+	peerURL := node.URL
+	switch node.Type {
+	case NodeTypeCosmos:
+		// Peer info is only available from the RPC interface
+		if node.serviceType() == ServiceTypeAPI {
+			return
+		}
+		handler = h.cosmosHandler
+	case NodeTypeEVM:
+		peerURL = node.rpcURL()
+		handler = h.evmHandler
+	case NodeTypeBeacon:
+		handler = h.beaconHandler
+	}
+
+	counter, ok := handler.(PeerCounter)
+	if !ok || peerURL == "" {
+		return
+	}
+
+	peers, err := counter.GetPeerCount(ctx, peerURL)
+	if err != nil {
+		h.logger.Debug("peer count unavailable",
+			zap.String("node", node.Name),
+			zap.Error(err))
+		return
+	}
+	health.PeerCount = &peers
+}
@@ -0,0 +1,169 @@
+package blockchain_health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestWeightedScore tests the weighted combination of scoring signals
+func TestWeightedScore(t *testing.T) {
+	cfg := ScoringConfig{HeightWeight: 2, LatencyWeight: 1, ErrorRateWeight: 1, PeerWeight: 4}
+
+	// Without a peer signal the peer weight is left out
+	score := weightedScore(cfg, scoreSignals{height: 1, latency: 0, errorRate: 1})
+	if math.Abs(score-0.75) > 1e-9 {
+		t.Errorf("Expected score 0.75, got %f", score)
+	}
+
+	score = weightedScore(cfg, scoreSignals{height: 1, latency: 0, errorRate: 1, peers: 0, hasPeers: true})
+	if math.Abs(score-0.375) > 1e-9 {
+		t.Errorf("Expected score 0.375, got %f", score)
+	}
+}
+
+// TestScoringWithinHeightThreshold verifies nodes within the height threshold
+// are kept or dropped based on the score cutoff
+func TestScoringWithinHeightThreshold(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	leader := createCosmosServer(t, 1000, false)
+	defer leader.Close()
+	lagging := createCosmosServer(t, 997, false)
+	defer lagging.Close()
+
+	nodes := []NodeConfig{
+		{Name: "leader", URL: leader.URL, Type: NodeTypeCosmos, Weight: 1},
+		{Name: "lagging", URL: lagging.URL, Type: NodeTypeCosmos, Weight: 1},
+	}
+
+	tests := []struct {
+		name          string
+		cutoff        float64
+		expectHealthy bool
+	}{
+		{"LowCutoffKeepsLaggingNode", 0.3, true},
+		{"HighCutoffDropsLaggingNode", 0.9, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cutoff := tt.cutoff
+			upstream := createTestUpstream(nodes, logger)
+			upstream.config.Scoring = ScoringConfig{
+				Enabled:         true,
+				HeightWeight:    1,
+				LatencyWeight:   1,
+				ErrorRateWeight: 1,
+				Cutoff:          &cutoff,
+				MaxLatency:      "10s",
+			}
+
+			results, err := upstream.healthChecker.CheckAllNodes(context.Background())
+			if err != nil {
+				t.Fatalf("CheckAllNodes failed: %v", err)
+			}
+
+			for _, result := range results {
+				if result.Name != "lagging" {
+					continue
+				}
+				if !result.HeightValid {
+					t.Error("Expected lagging node to pass height validation")
+				}
+				if result.Score <= 0 || result.Score >= 1 {
+					t.Errorf("Expected partial score for lagging node, got %f", result.Score)
+				}
+				if result.Healthy != tt.expectHealthy {
+					t.Errorf("Expected healthy=%v at cutoff %.1f (score %.2f)", tt.expectHealthy, tt.cutoff, result.Score)
+				}
+			}
+		})
+	}
+}
+
+// TestScoringKeepsHeightThresholdGate verifies a fast, error-free node far
+// behind the pool is still excluded under the default scoring config
+func TestScoringKeepsHeightThresholdGate(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	leader := createCosmosServer(t, 100000, false)
+	defer leader.Close()
+	stale := createCosmosServer(t, 40000, false)
+	defer stale.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "leader", URL: leader.URL, Type: NodeTypeCosmos, Weight: 1},
+		{Name: "stale", URL: stale.URL, Type: NodeTypeCosmos, Weight: 1},
+	}, logger)
+	upstream.config.Scoring = ScoringConfig{Enabled: true}
+	if err := upstream.setDefaults(); err != nil {
+		t.Fatalf("setDefaults failed: %v", err)
+	}
+
+	results, err := upstream.healthChecker.CheckAllNodes(context.Background())
+	if err != nil {
+		t.Fatalf("CheckAllNodes failed: %v", err)
+	}
+
+	for _, result := range results {
+		if result.Name == "stale" && (result.Healthy || result.Score != 0) {
+			t.Errorf("Expected stale node to be excluded with score 0, got healthy=%v score=%.2f", result.Healthy, result.Score)
+		}
+	}
+}
+
+// TestScoringCutoffZero verifies an explicit cutoff of 0 survives defaults
+func TestScoringCutoffZero(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		scoring {
+			cutoff 0
+		}
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	b.config = &Config{Scoring: b.Scoring}
+	if err := b.setDefaults(); err != nil {
+		t.Fatalf("setDefaults failed: %v", err)
+	}
+	if b.config.Scoring.Cutoff == nil || *b.config.Scoring.Cutoff != 0 {
+		t.Errorf("Expected cutoff 0 to be kept, got %v", b.config.Scoring.Cutoff)
+	}
+}
+
+// TestEVMHandler_GetPeerCount tests net_peerCount parsing
+func TestEVMHandler_GetPeerCount(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req EVMJSONRPCRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		if req.Method != "net_peerCount" {
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}`))
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":"0x19"}`)
+	}))
+	defer server.Close()
+
+	handler := NewEVMHandler(5*time.Second, logger)
+	peers, err := handler.GetPeerCount(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("GetPeerCount failed: %v", err)
+	}
+	if peers != 25 {
+		t.Errorf("Expected 25 peers, got %d", peers)
+	}
+}
@@ -0,0 +1,199 @@
+package blockchain_health
+
+import (
+	"slices"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// maxSharedUpstreams bounds the upstream lists kept per snapshot; requests
+// selecting other nodes get their own list
+const maxSharedUpstreams = 8
+
+// selectionSnapshot is what requests precompute from one generation of the
+// health cache, the node list and the quarantines: each result's node
+// config, dial address and quarantine, whether it is a candidate, and the
+// counter of its healthy selections. Requests served while it is current look none of them up
+// again. Its slices are aligned with results and never modified.
+type selectionSnapshot struct {
+	entries    *map[string]*CacheEntry
+	nodes      []NodeConfig
+	quarantine uint64
+	expires    time.Time // when the first of the results expires
+
+	results      []*NodeHealth
+	configs      []*NodeConfig
+	serviceTypes []string
+	dials        []string
+	validDials   []bool
+	quarantined  []bool
+	candidates   []bool
+	included     []prometheus.Counter // nil without metrics
+
+	// Whether any node sends static headers
+	headers bool
+}
+
+// upstreamPick is a result selected for a request and the request limit of
+// its upstream
+type upstreamPick struct {
+	index       int
+	dial        string
+	maxRequests int
+}
+
+// sharedUpstreams are the upstream lists an upstream source returned for a
+// snapshot, by the picks they were built from
+type sharedUpstreams struct {
+	snapshot *selectionSnapshot
+	picks    [][]upstreamPick
+	lists    [][]*reverseproxy.Upstream
+}
+
+// currentSelection returns the snapshot of the cached node health, building
+// a new one once the cache, the node list or a quarantine changed or a
+// result expired. It returns nil unless every node has a fresh result.
+func (b *BlockchainHealthUpstream) currentSelection() *selectionSnapshot {
+	entries := b.cache.generation()
+	nodes := b.config.nodeList()
+	quarantine := b.healthChecker.quarantineVersion.Load()
+	if snap := b.selection.Load(); snap != nil && snap.entries == entries && snap.quarantine == quarantine &&
+		sameNodes(snap.nodes, nodes) && !time.Now().After(snap.expires) {
+		return snap
+	}
+
+	snap := b.buildSelection(entries, nodes, quarantine)
+	if snap != nil {
+		b.selection.Store(snap)
+	}
+	return snap
+}
+
+// buildSelection builds the snapshot of the cached results of the nodes
+func (b *BlockchainHealthUpstream) buildSelection(entries *map[string]*CacheEntry, nodes []NodeConfig, quarantine uint64) *selectionSnapshot {
+	if len(nodes) == 0 {
+		return nil
+	}
+	results, found, expires := b.cache.appendNodesFrom(entries, make([]*NodeHealth, 0, len(nodes)), nodes)
+	if found < len(nodes) {
+		// If any node doesn't have cached results, return nothing
+		// This forces a full health check to ensure consistency
+		if ce := b.logger.Check(zapcore.DebugLevel, "incomplete cached health results, forcing full health check"); ce != nil {
+			ce.Write(zap.String("missing_node", nodes[found].Name),
+				zap.Int("total_nodes", len(nodes)),
+				zap.Int("cached_results", found))
+		}
+		return nil
+	}
+
+	snap := &selectionSnapshot{
+		entries:      entries,
+		nodes:        nodes,
+		quarantine:   quarantine,
+		expires:      expires,
+		results:      results,
+		configs:      make([]*NodeConfig, len(results)),
+		serviceTypes: make([]string, len(results)),
+		dials:        make([]string, len(results)),
+		validDials:   make([]bool, len(results)),
+		quarantined:  make([]bool, len(results)),
+		candidates:   make([]bool, len(results)),
+	}
+	if b.metrics != nil {
+		snap.included = make([]prometheus.Counter, len(results))
+	}
+	for i, health := range results {
+		if node := findNode(nodes, health.Name); node != nil {
+			snap.configs[i] = node
+			snap.serviceTypes[i] = node.Metadata["service_type"]
+			snap.candidates[i] = node.isCandidate()
+		}
+		snap.dials[i], snap.validDials[i] = parseDial(health.URL)
+		snap.quarantined[i] = b.healthChecker.isQuarantined(health.Name)
+		if snap.included != nil {
+			snap.included[i] = b.metrics.upstreamsIncluded.WithLabelValues(health.Name, snap.serviceTypes[i], "healthy")
+		}
+	}
+
+	for _, node := range nodes {
+		if len(node.Headers) > 0 {
+			snap.headers = true
+			break
+		}
+	}
+
+	if ce := b.logger.Check(zapcore.DebugLevel, "retrieved complete cached health results"); ce != nil {
+		ce.Write(zap.Int("total_nodes", len(nodes)), zap.Int("cached_results", len(results)))
+	}
+	return snap
+}
+
+// sameNodes reports whether two node lists are the same list
+func sameNodes(a, b []NodeConfig) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
+// aligned reports whether results are the snapshot's own, so its slices
+// describe them
+func (s *selectionSnapshot) aligned(results []*NodeHealth) bool {
+	return s != nil && len(results) == len(s.results) && (len(results) == 0 || &results[0] == &s.results[0])
+}
+
+// upstreamsFor returns the upstreams of the picks, made from the results of
+// snap when it is not nil. b is the upstream source of one reverse proxy.
+// Its requests picking the same nodes with the same limits share one list,
+// so the cached path allocates nothing. The proxy fills in the upstreams for
+// each request, but with the same host, circuit breaker and passive health
+// policy every time, so they are only shared within it.
+func (b *BlockchainHealthUpstream) upstreamsFor(snap *selectionSnapshot, picks []upstreamPick) []*reverseproxy.Upstream {
+	if len(picks) == 0 {
+		return nil
+	}
+	shared := b.sharedUpstreams.Load()
+	if snap != nil && shared != nil && shared.snapshot == snap {
+		for i, listPicks := range shared.picks {
+			if slices.Equal(listPicks, picks) {
+				return shared.lists[i]
+			}
+		}
+	}
+
+	// The structs share one allocation
+	upstreams := make([]*reverseproxy.Upstream, len(picks))
+	structs := make([]reverseproxy.Upstream, len(picks))
+	for i, pick := range picks {
+		structs[i].Dial = pick.dial
+		structs[i].MaxRequests = pick.maxRequests
+		upstreams[i] = &structs[i]
+	}
+	if snap != nil {
+		b.shareUpstreams(snap, slices.Clone(picks), upstreams)
+	}
+	return upstreams
+}
+
+// shareUpstreams keeps an upstream list for later requests picking the same
+// nodes from the snapshot
+func (b *BlockchainHealthUpstream) shareUpstreams(snap *selectionSnapshot, picks []upstreamPick, upstreams []*reverseproxy.Upstream) {
+	for {
+		old := b.sharedUpstreams.Load()
+		shared := &sharedUpstreams{snapshot: snap}
+		if old != nil && old.snapshot == snap {
+			if len(old.lists) >= maxSharedUpstreams {
+				return
+			}
+			shared.picks = append(slices.Clip(old.picks), picks)
+			shared.lists = append(slices.Clip(old.lists), upstreams)
+		} else {
+			shared.picks = [][]upstreamPick{picks}
+			shared.lists = [][]*reverseproxy.Upstream{upstreams}
+		}
+		if b.sharedUpstreams.CompareAndSwap(old, shared) {
+			return
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package blockchain_health
+
+import (
+	"runtime"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// watchdogMultiple is how many check intervals the background checker may
+// go without completing a check before the watchdog reports it stalled
+const watchdogMultiple = 3
+
+// startSelfMonitor samples the health checker's own metrics and runs its
+// watchdog every check interval until the health checker is stopped
+func (h *HealthChecker) startSelfMonitor(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	h.recordTick()
+	if h.metrics != nil {
+		h.metrics.checkerStalled.WithLabelValues(h.poolName()).Set(0)
+	}
+
+	h.goBackground(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				h.sampleSelfMetrics(now)
+				h.checkWatchdog(now, interval)
+			case <-h.ctx.Done():
+				return
+			}
+		}
+	})
+}
+
+// recordTick notes that the background checker completed a check
+func (h *HealthChecker) recordTick() {
+	h.lastTick.Store(time.Now().UnixNano())
+}
+
+// sampleSelfMetrics exports the goroutine count, the cache hit ratio, the
+// probe queue depth and the age of the oldest cached result
+func (h *HealthChecker) sampleSelfMetrics(now time.Time) {
+	if h.metrics == nil {
+		return
+	}
+	pool := h.poolName()
+	h.metrics.goroutines.Set(float64(runtime.NumGoroutine()))
+	if ratio, ok := h.cache.hitRatio(); ok {
+		h.metrics.cacheHitRatio.WithLabelValues(pool).Set(ratio)
+	}
+
+	// The schedules start the workers on their first check anyway
+	h.startWorkers.Do(h.runWorkers)
+	h.metrics.probeQueueDepth.WithLabelValues(pool).Set(float64(len(h.jobs)))
+	h.metrics.oldestResultAge.WithLabelValues(pool).Set(h.oldestResultAge(now).Seconds())
+}
+
+// oldestResultAge returns how long ago the least recently checked node with
+// a cached result was checked. Expired results count until cleaned up, as
+// they are the ones going stale.
+func (h *HealthChecker) oldestResultAge(now time.Time) time.Duration {
+	entries := h.cache.snapshot()
+	var oldest time.Duration
+	for _, node := range h.config.nodeList() {
+		entry, ok := entries[node.key()]
+		if !ok || entry.Health == nil || entry.Health.LastCheck.IsZero() {
+			continue
+		}
+		oldest = max(oldest, now.Sub(entry.Health.LastCheck))
+	}
+	return oldest
+}
+
+// checkWatchdog reports the background checker as stalled when it has not
+// completed a check for watchdogMultiple intervals, or for an interval and a
+// full check timeout, whichever is longer. It logs an error and emits
+// checker_stalled once, and checker_resumed once checks complete again.
+func (h *HealthChecker) checkWatchdog(now time.Time, interval time.Duration) {
+	last := time.Unix(0, h.lastTick.Load())
+
+	// A pool without nodes, e.g. waiting for discovery, has nothing to check
+	if len(h.config.nodeList()) == 0 {
+		h.recordTick()
+		last = now
+	}
+
+	stallAfter := max(watchdogMultiple*interval, interval+nodeCheckTimeout)
+	stalled := now.Sub(last) > stallAfter
+	if stalled == h.stalled {
+		return
+	}
+	h.stalled = stalled
+
+	pool := h.poolName()
+	if h.metrics != nil {
+		value := 0.0
+		if stalled {
+			value = 1
+		}
+		h.metrics.checkerStalled.WithLabelValues(pool).Set(value)
+	}
+	if stalled {
+		h.logger.Error("health checker stalled, no node check completed",
+			zap.String("pool", pool),
+			zap.Duration("since_last_check", now.Sub(last)),
+			zap.Duration("check_interval", interval))
+		h.emit(eventCheckerStalled, map[string]any{
+			"pool":             pool,
+			"last_check":       last,
+			"since_last_check": now.Sub(last).String(),
+		})
+		return
+	}
+	h.logger.Info("health checker resumed", zap.String("pool", pool))
+	h.emit(eventCheckerResumed, map[string]any{"pool": pool})
+}
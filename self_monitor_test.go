@@ -0,0 +1,94 @@
+package blockchain_health
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap/zaptest"
+)
+
+func gaugeValue(t *testing.T, gauge prometheus.Gauge) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := gauge.Write(&m); err != nil {
+		t.Fatalf("failed to read gauge: %v", err)
+	}
+	return m.GetGauge().GetValue()
+}
+
+func TestSelfMonitor_Metrics(t *testing.T) {
+	server := createCosmosServer(t, 1000, false)
+	defer server.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "node", URL: server.URL, Type: NodeTypeCosmos, Weight: 1},
+	}, zaptest.NewLogger(t))
+	h := upstream.healthChecker
+	defer h.Stop()
+
+	// A cold cache misses, the next sweep hits
+	for i := 0; i < 2; i++ {
+		if _, err := h.CheckAllNodes(context.Background()); err != nil {
+			t.Fatalf("CheckAllNodes failed: %v", err)
+		}
+	}
+
+	now := time.Now().Add(time.Minute)
+	h.sampleSelfMetrics(now)
+
+	pool := h.poolName()
+	if ratio := gaugeValue(t, h.metrics.cacheHitRatio.WithLabelValues(pool)); ratio <= 0 || ratio >= 1 {
+		t.Errorf("expected a hit ratio between 0 and 1, got %v", ratio)
+	}
+	if age := gaugeValue(t, h.metrics.oldestResultAge.WithLabelValues(pool)); age < 59 || age > 61 {
+		t.Errorf("expected the cached result to be about a minute old, got %vs", age)
+	}
+	if depth := gaugeValue(t, h.metrics.probeQueueDepth.WithLabelValues(pool)); depth != 0 {
+		t.Errorf("expected an empty probe queue, got %v", depth)
+	}
+	if goroutines := gaugeValue(t, h.metrics.goroutines); goroutines <= 0 {
+		t.Errorf("expected the goroutine count, got %v", goroutines)
+	}
+
+	var m dto.Metric
+	if err := h.metrics.sweepDuration.WithLabelValues(pool).(prometheus.Histogram).Write(&m); err != nil || m.GetHistogram().GetSampleCount() == 0 {
+		t.Errorf("expected a sweep duration sample, got %v (%v)", m.GetHistogram().GetSampleCount(), err)
+	}
+}
+
+func TestSelfMonitor_Watchdog(t *testing.T) {
+	server := createCosmosServer(t, 1000, false)
+	defer server.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "node", URL: server.URL, Type: NodeTypeCosmos, Weight: 1},
+	}, zaptest.NewLogger(t))
+	h := upstream.healthChecker
+	defer h.Stop()
+	stalled := h.metrics.checkerStalled.WithLabelValues(h.poolName())
+
+	interval := time.Second
+	h.recordTick()
+	start := time.Now()
+
+	// Within an interval and a check timeout the checker is still ticking
+	h.checkWatchdog(start.Add(interval+nodeCheckTimeout-time.Second), interval)
+	if h.stalled || gaugeValue(t, stalled) != 0 {
+		t.Error("expected the checker not to be stalled yet")
+	}
+
+	h.checkWatchdog(start.Add(interval+nodeCheckTimeout+time.Second), interval)
+	if !h.stalled || gaugeValue(t, stalled) != 1 {
+		t.Error("expected the checker to be reported stalled")
+	}
+
+	// A completed check clears the stall
+	h.recordTick()
+	h.checkWatchdog(time.Now(), interval)
+	if h.stalled || gaugeValue(t, stalled) != 0 {
+		t.Error("expected the checker to resume")
+	}
+}
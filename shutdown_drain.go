@@ -0,0 +1,114 @@
+package blockchain_health
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyevents"
+	"go.uber.org/zap"
+)
+
+// reasonShuttingDown is the health endpoint reason while the gateway drains
+const reasonShuttingDown = "shutting_down"
+
+// exiting reports whether the Caddy process is exiting, replaced in tests
+var exiting = caddy.Exiting
+
+// eventStopping is emitted by Caddy before it stops the apps of a config
+const eventStopping = "stopping"
+
+// subscribeDrain drains the app's pools on Caddy's stopping event, which
+// comes before the HTTP servers stop, so load balancers still reach the
+// health endpoint while it reports shutting_down
+func (a *App) subscribeDrain(ctx caddy.Context) error {
+	module, ok := loadCaddyApp(ctx, "events")
+	if !ok {
+		return nil
+	}
+	events, ok := module.(*caddyevents.App)
+	if !ok {
+		return nil
+	}
+	return events.On(eventStopping, drainHandler{app: a})
+}
+
+// drainHandler drains the pools of an app when Caddy stops
+type drainHandler struct {
+	app *App
+}
+
+// Handle implements caddyevents.Handler. Caddy waits for it before stopping
+// the servers, so the drain holds the shutdown back.
+func (d drainHandler) Handle(context.Context, caddy.Event) error {
+	d.app.drain()
+	return nil
+}
+
+// drain makes every pool of the app report shutting_down and waits out the
+// longest shutdown_drain, draining the pools at once rather than one after
+// the other
+func (a *App) drain() {
+	a.mutex.Lock()
+	pools := make([]*BlockchainHealthUpstream, 0, len(a.Pools)+len(a.inline))
+	for _, name := range a.poolNames() {
+		pools = append(pools, a.Pools[name])
+	}
+	for _, pool := range a.inline {
+		pools = append(pools, pool)
+	}
+	a.mutex.Unlock()
+
+	var drain time.Duration
+	for _, pool := range pools {
+		// Pools that failed to provision have nothing to drain
+		if pool.logger != nil {
+			drain = max(drain, pool.startDrain())
+		}
+	}
+	time.Sleep(drain)
+}
+
+// startDrain makes the health endpoint report shutting_down and returns how
+// long to keep serving before Caddy stops. Pools only drain when Caddy
+// exits, not when a config reload replaces them.
+func (b *BlockchainHealthUpstream) startDrain() time.Duration {
+	if b.config == nil || b.config.Monitoring.ShutdownDrain == "" || !exiting() {
+		return 0
+	}
+	drain, err := time.ParseDuration(b.config.Monitoring.ShutdownDrain)
+	if err != nil || drain <= 0 {
+		return 0
+	}
+	if b.draining.Swap(true) {
+		return 0
+	}
+	if b.healthChecker != nil && b.healthChecker.state != nil {
+		b.healthChecker.state.draining.Store(true)
+	}
+	b.logger.Info("draining health endpoint before shutdown", zap.Duration("drain", drain))
+	return drain
+}
+
+// shuttingDown reports whether the health endpoint should tell load
+// balancers to drain this gateway: while the pool drains, or while the Caddy
+// server handling the request waits out its shutdown_delay
+func (b *BlockchainHealthUpstream) shuttingDown(r *http.Request) bool {
+	pool := b
+	if b.shared != nil {
+		pool = b.shared
+	}
+	return pool.draining.Load() || serverShuttingDown(r)
+}
+
+// serverShuttingDown reports whether the Caddy server handling the request
+// waits out its shutdown_delay
+func serverShuttingDown(r *http.Request) bool {
+	repl, ok := r.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer)
+	if !ok {
+		return false
+	}
+	shuttingDown, _ := repl.Get("http.shutting_down")
+	return shuttingDown == true
+}
@@ -0,0 +1,156 @@
+package blockchain_health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"go.uber.org/zap/zaptest"
+)
+
+func getHealth(t *testing.T, b *BlockchainHealthUpstream, r *http.Request) (int, HealthEndpointResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	b.ServeHealthEndpoint()(w, r)
+	var response HealthEndpointResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("decoding health response: %v", err)
+	}
+	return w.Code, response
+}
+
+func TestShutdownDrain_Stopping(t *testing.T) {
+	server := createEVMServer(t, 1000, false)
+	defer server.Close()
+
+	original := exiting
+	t.Cleanup(func() { exiting = original })
+
+	config := fmt.Sprintf(`{
+		"admin": {"disabled": true},
+		"apps": {
+			"blockchain_health": {
+				"pools": {
+					"evm-main": {
+						"nodes": [{"name": "a", "url": %q, "type": "evm", "weight": 1}],
+						"monitoring": {"shutdown_drain": "200ms"}
+					}
+				}
+			}
+		}
+	}`, server.URL)
+	load := func() *BlockchainHealthUpstream {
+		t.Helper()
+		if err := caddy.Load([]byte(config), true); err != nil {
+			t.Fatalf("loading config: %v", err)
+		}
+		app, err := caddy.ActiveContext().App("blockchain_health")
+		if err != nil {
+			t.Fatalf("blockchain_health app not loaded: %v", err)
+		}
+		return app.(*App).Pools["evm-main"]
+	}
+
+	// Config reloads replace the pool without draining it
+	exiting = func() bool { return false }
+	pool := load()
+	start := time.Now()
+	_ = caddy.Stop()
+	if pool.draining.Load() || time.Since(start) >= 200*time.Millisecond {
+		t.Fatalf("expected no drain outside of shutdown, stopped after %v", time.Since(start))
+	}
+
+	exiting = func() bool { return true }
+	pool = load()
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	if code, _ := getHealth(t, pool, req); code != http.StatusOK {
+		t.Fatalf("expected a healthy pool before shutdown, got %d", code)
+	}
+
+	start = time.Now()
+	done := make(chan struct{})
+	go func() {
+		_ = caddy.Stop()
+		close(done)
+	}()
+
+	deadline := time.After(time.Second)
+	for !pool.draining.Load() {
+		select {
+		case <-deadline:
+			t.Fatal("expected the pool to start draining")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	// The drain runs before the apps stop, while the servers still answer
+	if !isActiveApp() {
+		t.Error("expected the app to still be running while the pool drains")
+	}
+	code, response := getHealth(t, pool, req)
+	if code != http.StatusServiceUnavailable || response.Reason != reasonShuttingDown {
+		t.Errorf("expected 503 with reason %s while draining, got %d %q", reasonShuttingDown, code, response.Reason)
+	}
+
+	<-done
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("expected Caddy to stop after the 200ms drain, stopped after %v", elapsed)
+	}
+}
+
+// isActiveApp reports whether a blockchain_health app is running
+func isActiveApp() bool {
+	active := false
+	activeApps.Range(func(any, any) bool {
+		active = true
+		return false
+	})
+	return active
+}
+
+func TestShutdownDrain_CaddyShutdownDelay(t *testing.T) {
+	upstream := createTestUpstream(nil, zaptest.NewLogger(t))
+
+	repl := caddy.NewReplacer()
+	repl.Map(func(key string) (any, bool) {
+		return key == "http.shutting_down", key == "http.shutting_down"
+	})
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req = req.WithContext(context.WithValue(req.Context(), caddy.ReplacerCtxKey, repl))
+
+	code, response := getHealth(t, upstream, req)
+	if code != http.StatusServiceUnavailable || response.Reason != reasonShuttingDown {
+		t.Errorf("expected 503 with reason %s during the server's shutdown_delay, got %d %q", reasonShuttingDown, code, response.Reason)
+	}
+}
+
+func TestShutdownDrain_Config(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:8545
+			type evm
+		}
+		shutdown_drain 15s
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if b.Monitoring.ShutdownDrain != "15s" {
+		t.Errorf("expected shutdown_drain 15s, got %q", b.Monitoring.ShutdownDrain)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+
+	b.Monitoring.ShutdownDrain = "-1s"
+	if err := b.validate(); err == nil {
+		t.Error("expected a negative shutdown_drain to be rejected")
+	}
+}
@@ -0,0 +1,225 @@
+package blockchain_health
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// SLO defaults
+const (
+	defaultSLOAvailability  = 0.999
+	defaultSLOLatency       = 300 * time.Millisecond
+	defaultSLOLatencyTarget = 0.95
+	defaultSLOWindow        = time.Hour
+	defaultSLOBurnRate      = 2
+
+	// sloMinSamples outcomes are needed before a burn rate downweights a node
+	sloMinSamples = 10
+)
+
+// SLO objectives, as metric labels
+const (
+	sloAvailability = "availability"
+	sloLatency      = "latency"
+)
+
+// sloState holds the rolling outcomes of a node or chain: whether checks
+// succeeded, and whether successful ones were within the latency objective
+type sloState struct {
+	availability *errorRateWindow
+	latency      *errorRateWindow
+	burning      bool
+	burnRate     float64
+}
+
+// newSLOState creates the windows of a node or chain
+func newSLOState(window time.Duration) *sloState {
+	return &sloState{
+		availability: newErrorRateWindow(window),
+		latency:      newErrorRateWindow(window),
+	}
+}
+
+// sloWindow returns the configured SLO window
+func (h *HealthChecker) sloWindow() time.Duration {
+	if d, err := time.ParseDuration(h.config.SLO.Window); err == nil && d > 0 {
+		return d
+	}
+	return defaultSLOWindow
+}
+
+// sloLatency returns the configured latency objective
+func (h *HealthChecker) sloLatency() time.Duration {
+	if d, err := time.ParseDuration(h.config.SLO.Latency); err == nil && d > 0 {
+		return d
+	}
+	return defaultSLOLatency
+}
+
+// burnRate is how fast a failure ratio consumes the error budget of a target:
+// 1 uses up the budget exactly over the window, 2 in half of it
+func burnRate(failures, target float64) float64 {
+	budget := 1 - target
+	if budget <= 0 {
+		return 0
+	}
+	return failures / budget
+}
+
+// record adds a check outcome. Failed checks only count against
+// availability, since their latency says little about the node.
+func (s *sloState) record(now time.Time, healthy, slow bool) {
+	s.availability.recordAt(now, !healthy)
+	if healthy {
+		s.latency.recordAt(now, slow)
+	}
+}
+
+// evaluate returns the compliance and burn rate of each objective, and the
+// faster burn rate among objectives with enough samples
+func (s *sloState) evaluate(now time.Time, cfg SLOConfig) (compliance, burn map[string]float64, fastest float64, samples int) {
+	compliance = make(map[string]float64, 2)
+	burn = make(map[string]float64, 2)
+	for objective, window := range map[string]*errorRateWindow{sloAvailability: s.availability, sloLatency: s.latency} {
+		target := cfg.Availability
+		if objective == sloLatency {
+			target = cfg.LatencyTarget
+		}
+		failures, total := window.rateAt(now)
+		if total == 0 {
+			continue
+		}
+		compliance[objective] = 1 - failures
+		burn[objective] = burnRate(failures, target)
+		if total >= sloMinSamples && burn[objective] > fastest {
+			fastest = burn[objective]
+		}
+		samples = max(samples, total)
+	}
+	return compliance, burn, fastest, samples
+}
+
+// recordNodeSLO records the outcome of a node's check against the
+// objectives and updates its burn rate
+func (h *HealthChecker) recordNodeSLO(node NodeConfig, health *NodeHealth) {
+	if !h.config.SLO.Enabled {
+		return
+	}
+	now := time.Now()
+	slow := health.ResponseTime > h.sloLatency()
+
+	h.mutex.Lock()
+	state, ok := h.nodeSLOs[node.key()]
+	if !ok {
+		state = newSLOState(h.sloWindow())
+		h.nodeSLOs[node.key()] = state
+	}
+	h.mutex.Unlock()
+
+	state.record(now, health.Healthy, slow)
+	compliance, burn, fastest, samples := state.evaluate(now, h.config.SLO)
+	burning := samples >= sloMinSamples && fastest >= h.config.SLO.BurnRate
+
+	h.mutex.Lock()
+	if burning && !state.burning {
+		h.logger.Warn("node is burning its SLO error budget",
+			zap.String("node", node.Name),
+			zap.Float64("burn_rate", fastest))
+	} else if !burning && state.burning {
+		h.logger.Info("node back within its SLO error budget",
+			zap.String("node", node.Name),
+			zap.Float64("burn_rate", fastest))
+	}
+	state.burning = burning
+	state.burnRate = fastest
+	h.mutex.Unlock()
+
+	if h.metrics != nil {
+		for objective, value := range compliance {
+			h.metrics.nodeSLOCompliance.WithLabelValues(node.Name, objective).Set(value)
+			h.metrics.nodeSLOBurnRate.WithLabelValues(node.Name, objective).Set(burn[objective])
+		}
+	}
+}
+
+// recordChainSLOs records a round of results for each chain of the pool. A
+// chain is available when one of its nodes is healthy, and within the
+// latency objective when its fastest healthy node is, since that is the
+// best the pool can serve. Candidates are left out.
+func (h *HealthChecker) recordChainSLOs(results []*NodeHealth) {
+	if !h.config.SLO.Enabled {
+		return
+	}
+	now := time.Now()
+	objective := h.sloLatency()
+	nodes := h.config.nodeList()
+
+	type round struct {
+		healthy bool
+		fastest time.Duration
+	}
+	rounds := make(map[string]*round)
+	for _, health := range results {
+		if health == nil {
+			continue
+		}
+		node := findNode(nodes, health.Name)
+		if node == nil || node.isCandidate() {
+			continue
+		}
+		chain := nodeChain(*node)
+		r, ok := rounds[chain]
+		if !ok {
+			r = &round{}
+			rounds[chain] = r
+		}
+		if health.Healthy && (!r.healthy || health.ResponseTime < r.fastest) {
+			r.fastest = health.ResponseTime
+		}
+		r.healthy = r.healthy || health.Healthy
+	}
+
+	for chain, r := range rounds {
+		h.mutex.Lock()
+		state, ok := h.chainSLOs[chain]
+		if !ok {
+			state = newSLOState(h.sloWindow())
+			h.chainSLOs[chain] = state
+		}
+		h.mutex.Unlock()
+
+		state.record(now, r.healthy, r.fastest > objective)
+		if h.metrics == nil {
+			continue
+		}
+		compliance, burn, _, _ := state.evaluate(now, h.config.SLO)
+		for objective, value := range compliance {
+			h.metrics.chainSLOCompliance.WithLabelValues(chain, h.config.Chain.Network, objective).Set(value)
+			h.metrics.chainSLOBurnRate.WithLabelValues(chain, h.config.Chain.Network, objective).Set(burn[objective])
+		}
+	}
+}
+
+// applySLOs copies the burn state of the nodes onto the health results
+func (h *HealthChecker) applySLOs(results []*NodeHealth) {
+	if !h.config.SLO.Enabled {
+		return
+	}
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	for _, health := range results {
+		if health == nil {
+			continue
+		}
+		if state, ok := h.nodeSLOs[h.nodeKey(health.Name)]; ok {
+			health.SLOBurnRate = state.burnRate
+			health.SLOBurning = state.burning
+		} else {
+			health.SLOBurnRate = 0
+			health.SLOBurning = false
+		}
+	}
+}
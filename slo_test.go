@@ -0,0 +1,148 @@
+package blockchain_health
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestSLOState_Evaluate(t *testing.T) {
+	cfg := SLOConfig{Availability: 0.99, LatencyTarget: 0.9}
+	state := newSLOState(time.Hour)
+	now := time.Now()
+
+	// 2 failures in 20 checks, and 1 of the 18 successes slow
+	for i := range 20 {
+		state.record(now, i >= 2, i == 10)
+	}
+	compliance, burn, fastest, samples := state.evaluate(now, cfg)
+	if samples != 20 {
+		t.Errorf("expected 20 samples, got %d", samples)
+	}
+	if math.Abs(compliance[sloAvailability]-0.9) > 1e-9 || math.Abs(burn[sloAvailability]-10) > 1e-9 {
+		t.Errorf("expected availability 0.9 burning 10x, got %v burning %vx", compliance[sloAvailability], burn[sloAvailability])
+	}
+	if math.Abs(burn[sloLatency]-1.0/18/0.1) > 1e-9 {
+		t.Errorf("expected the latency burn rate of 1 slow check in 18, got %v", burn[sloLatency])
+	}
+	if fastest != burn[sloAvailability] {
+		t.Errorf("expected the fastest burn rate to be availability's, got %v", fastest)
+	}
+
+	// Outcomes leave the window
+	if _, _, _, samples := state.evaluate(now.Add(2*time.Hour), cfg); samples != 0 {
+		t.Errorf("expected the outcomes to expire, got %d samples", samples)
+	}
+}
+
+func TestSLO_SlowNodeDownWeighted(t *testing.T) {
+	fast := createEVMServer(t, 1000, false)
+	defer fast.Close()
+	evm := createEVMServer(t, 1000, false)
+	evm.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+		evm.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer slow.Close()
+
+	nodes := []NodeConfig{
+		{Name: "fast", URL: fast.URL, Type: NodeTypeEVM, ChainType: "test-evm", Weight: 100},
+		{Name: "slow", URL: slow.URL, Type: NodeTypeEVM, ChainType: "test-evm", Weight: 100},
+	}
+	upstream := createTestUpstream(nodes, zaptest.NewLogger(t))
+	upstream.cache = upstream.healthChecker.cache
+	upstream.config.SLO = SLOConfig{
+		Enabled: true, Availability: 0.999, Latency: "20ms", LatencyTarget: 0.95,
+		Window: "1h", BurnRate: 2, WeightFactor: 0.5,
+	}
+	checker := upstream.healthChecker
+
+	for range sloMinSamples {
+		for _, node := range nodes {
+			checker.probeNode(context.Background(), node)
+		}
+	}
+	results, err := checker.CheckAllNodes(context.Background())
+	if err != nil {
+		t.Fatalf("CheckAllNodes failed: %v", err)
+	}
+	for _, health := range results {
+		if burning := health.Name == "slow"; health.SLOBurning != burning {
+			t.Errorf("%s: expected burning=%v, got %+v", health.Name, burning, health)
+		}
+	}
+
+	var m dto.Metric
+	if err := checker.metrics.nodeSLOBurnRate.WithLabelValues("slow", sloLatency).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetGauge().GetValue(); math.Abs(got-20) > 1e-9 {
+		t.Errorf("expected the slow node to burn its latency budget 20x, got %v", got)
+	}
+	// The chain is served within the objective by its fastest node
+	if err := checker.metrics.chainSLOCompliance.WithLabelValues("test-evm", "", sloLatency).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetGauge().GetValue(); got != 1 {
+		t.Errorf("expected the chain to meet its latency objective, got %v", got)
+	}
+
+	upstreams, err := upstream.GetUpstreams(&http.Request{})
+	if err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+	for _, up := range upstreams {
+		want := 100
+		if up.Dial == getDynamicTestHostFromURL(slow.URL) {
+			want = 50
+		}
+		if up.MaxRequests != want {
+			t.Errorf("%s: expected weight %d, got %d", up.Dial, want, up.MaxRequests)
+		}
+	}
+}
+
+func TestSLO_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:8545
+			type evm
+		}
+		slo {
+			availability 99.9%
+			latency 250ms 90%
+			window 6h
+			burn_rate 5
+			weight_factor 0.25
+		}
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	want := SLOConfig{Enabled: true, Availability: 0.999, Latency: "250ms", LatencyTarget: 0.9, Window: "6h", BurnRate: 5, WeightFactor: 0.25}
+	if math.Abs(b.SLO.Availability-want.Availability) > 1e-9 {
+		t.Errorf("expected availability %v, got %v", want.Availability, b.SLO.Availability)
+	}
+	b.SLO.Availability = want.Availability
+	if b.SLO != want {
+		t.Errorf("expected %+v, got %+v", want, b.SLO)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+
+	b.SLO.Availability = 1
+	if err := b.validate(); err == nil {
+		t.Error("expected an availability of 100% to be rejected, leaving no error budget")
+	}
+}
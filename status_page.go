@@ -0,0 +1,269 @@
+package blockchain_health
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"go.uber.org/zap"
+)
+
+// Status page providers a pool can publish its state to
+const (
+	StatusPageStatuspage = "statuspage"
+	StatusPageInstatus   = "instatus"
+	StatusPageCachet     = "cachet"
+)
+
+// Status page defaults
+const (
+	defaultStatusPageAfter   = time.Minute
+	defaultStatusPageTimeout = 10 * time.Second
+)
+
+// statusPageAPIs are the public APIs of the hosted providers; Cachet is self
+// hosted, so its URL must be set
+var statusPageAPIs = map[string]string{
+	StatusPageStatuspage: "https://api.statuspage.io/v1",
+	StatusPageInstatus:   "https://api.instatus.com/v1",
+}
+
+// Component statuses, from best to worst, in each provider's terms
+var (
+	statuspageStatuses = []string{"operational", "degraded_performance", "partial_outage", "major_outage"}
+	instatusStatuses   = []string{"OPERATIONAL", "DEGRADEDPERFORMANCE", "PARTIALOUTAGE", "MAJOROUTAGE"}
+	cachetStatuses     = []int{1, 2, 3, 4}
+)
+
+// isValidStatusPageProvider reports whether s names a status page provider
+func isValidStatusPageProvider(s string) bool {
+	switch s {
+	case StatusPageStatuspage, StatusPageInstatus, StatusPageCachet:
+		return true
+	}
+	return false
+}
+
+// validate checks the status_page settings
+func (c *StatusPageConfig) validate() error {
+	if !isValidStatusPageProvider(c.Provider) {
+		return fmt.Errorf("invalid status_page provider %q (must be %s, %s or %s)", c.Provider,
+			StatusPageStatuspage, StatusPageInstatus, StatusPageCachet)
+	}
+	if c.Component == "" || c.APIKey == "" {
+		return fmt.Errorf("status_page requires a component and an api_key")
+	}
+	if c.Page == "" && c.Provider != StatusPageCachet {
+		return fmt.Errorf("status_page %s requires a page", c.Provider)
+	}
+	if c.URL == "" && c.Provider == StatusPageCachet {
+		return fmt.Errorf("status_page cachet requires the url of the Cachet installation")
+	}
+	if c.URL != "" {
+		if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid status_page url: %s", c.URL)
+		}
+	}
+	if c.After != "" {
+		if after, err := time.ParseDuration(c.After); err != nil || after < 0 {
+			return fmt.Errorf("invalid status_page after: %s", c.After)
+		}
+	}
+	if c.Timeout != "" {
+		if timeout, err := time.ParseDuration(c.Timeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid status_page timeout: %s", c.Timeout)
+		}
+	}
+	return nil
+}
+
+// statusLevel maps a pool state to a component status level, indexing the
+// provider statuses. A halted chain is a partial outage, as the pool still
+// serves reads of the chain up to the halt.
+func statusLevel(state PoolState) int {
+	switch state {
+	case PoolHealthy:
+		return 0
+	case PoolDegraded:
+		return 1
+	case PoolChainHalted, PoolCritical:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// statusPage publishes the state of a pool as the status of a status page
+// component. A status is sent once the pool has been in states mapping to it
+// for After, so a flapping pool doesn't flood the page.
+//
+// The status on the page is unknown on start, so after a reload the first
+// sustained status is sent even if an earlier instance already did.
+type statusPage struct {
+	config  *StatusPageConfig
+	after   time.Duration
+	client  *http.Client
+	logger  *zap.Logger
+	metrics *Metrics
+	now     func() time.Time
+
+	mutex   sync.Mutex
+	known   bool      // whether level reflects the page
+	level   int       // status level of the last successful update
+	pending int       // status level waiting for After
+	since   time.Time // when the pool entered the pending level
+	sending bool      // whether a provider request is in flight
+}
+
+// newStatusPage returns the status page exporter of a pool. The config must
+// have its defaults set.
+func newStatusPage(config *StatusPageConfig, logger *zap.Logger, metrics *Metrics) *statusPage {
+	after, _ := time.ParseDuration(config.After)
+	timeout, _ := time.ParseDuration(config.Timeout)
+	return &statusPage{
+		config:  config,
+		after:   after,
+		client:  &http.Client{Timeout: timeout},
+		logger:  logger,
+		metrics: metrics,
+		now:     time.Now,
+	}
+}
+
+// observe records the state of the pool after a health check. Once its
+// status has lasted long enough it returns the component update to send,
+// else nil.
+func (p *statusPage) observe(pool string, state PoolState) func(context.Context) {
+	level := statusLevel(state)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.known && p.level == level {
+		p.since = time.Time{}
+		return nil
+	}
+	now := p.now()
+	if p.since.IsZero() || p.pending != level {
+		p.pending, p.since = level, now
+	}
+	if now.Sub(p.since) < p.after || p.sending {
+		return nil
+	}
+
+	// Sent outside the health check; a failed request is retried after the
+	// next check
+	p.sending = true
+	return func(ctx context.Context) { p.send(ctx, pool, state, level) }
+}
+
+// send updates the component and records the outcome
+func (p *statusPage) send(ctx context.Context, pool string, state PoolState, level int) {
+	err := p.do(ctx, level)
+
+	p.mutex.Lock()
+	p.sending = false
+	if err == nil {
+		p.known, p.level = true, level
+		p.since = time.Time{}
+	}
+	p.mutex.Unlock()
+
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	if p.metrics != nil {
+		p.metrics.statusPageUpdates.WithLabelValues(pool, result).Inc()
+	}
+	if err != nil {
+		p.logger.Error("status page update failed",
+			zap.String("pool", pool),
+			zap.String("provider", p.config.Provider),
+			zap.Error(err))
+		return
+	}
+	p.logger.Info("status page component updated",
+		zap.String("pool", pool),
+		zap.String("provider", p.config.Provider),
+		zap.String("pool_state", string(state)))
+}
+
+// do sends the component status update of the provider
+func (p *statusPage) do(ctx context.Context, level int) error {
+	req, err := p.request(level)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("provider returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// request builds the component update of the provider. The API key may use
+// Caddy's global placeholders such as {env.STATUSPAGE_API_KEY}.
+func (p *statusPage) request(level int) (*http.Request, error) {
+	base := p.config.URL
+	if base == "" {
+		base = statusPageAPIs[p.config.Provider]
+	}
+	base = strings.TrimSuffix(base, "/")
+	page, component := url.PathEscape(p.config.Page), url.PathEscape(p.config.Component)
+	apiKey := caddy.NewReplacer().ReplaceKnown(p.config.APIKey, "")
+
+	var method, target, authName, authValue string
+	var body any
+	switch p.config.Provider {
+	case StatusPageStatuspage:
+		method, target = http.MethodPatch, base+"/pages/"+page+"/components/"+component
+		body = map[string]any{"component": map[string]any{"status": statuspageStatuses[level]}}
+		authName, authValue = "Authorization", "OAuth "+apiKey
+	case StatusPageInstatus:
+		method, target = http.MethodPut, base+"/"+page+"/components/"+component
+		body = map[string]any{"status": instatusStatuses[level]}
+		authName, authValue = "Authorization", "Bearer "+apiKey
+	case StatusPageCachet:
+		method, target = http.MethodPut, base+"/api/v1/components/"+component
+		body = map[string]any{"status": cachetStatuses[level]}
+		authName, authValue = "X-Cachet-Token", apiKey
+	default:
+		return nil, fmt.Errorf("unknown status page provider: %s", p.config.Provider)
+	}
+
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, target, bytes.NewReader(encoded))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(authName, authValue)
+	return req, nil
+}
+
+// applyStatusPage passes the pool state to the status page, if configured.
+// Its updates run in the background, which Stop cancels and waits for.
+func (h *HealthChecker) applyStatusPage(pool string, state PoolState) {
+	if h.statusPage == nil {
+		return
+	}
+	if send := h.statusPage.observe(pool, state); send != nil {
+		h.goBackground(func() { send(h.ctx) })
+	}
+}
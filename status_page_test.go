@@ -0,0 +1,212 @@
+package blockchain_health
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"go.uber.org/zap/zaptest"
+)
+
+// newTestStatusPage returns a Statuspage exporter against the provider with a
+// controllable clock
+func newTestStatusPage(t *testing.T, provider *httptest.Server, clock *time.Time) *statusPage {
+	t.Helper()
+	config := &StatusPageConfig{
+		Provider:  StatusPageStatuspage,
+		URL:       provider.URL + "/v1",
+		Page:      "page1",
+		Component: "osmosis-rpc",
+		APIKey:    "{env.STATUS_PAGE_TEST_KEY}",
+		After:     "1m",
+		Timeout:   "5s",
+	}
+	page := newStatusPage(config, zaptest.NewLogger(t), NewMetrics())
+	page.now = func() time.Time { return *clock }
+	return page
+}
+
+// observeStatusPage passes the pool state to the status page and sends its
+// update, if any, before returning
+func observeStatusPage(p *statusPage, pool string, state PoolState) {
+	if send := p.observe(pool, state); send != nil {
+		send(context.Background())
+	}
+}
+
+func TestStatusPage_Transitions(t *testing.T) {
+	t.Setenv("STATUS_PAGE_TEST_KEY", "secret")
+	provider := &fakeProvider{}
+	server := httptest.NewServer(provider)
+	defer server.Close()
+
+	clock := time.Now()
+	page := newTestStatusPage(t, server, &clock)
+	step := func(state PoolState, elapsed time.Duration) {
+		clock = clock.Add(elapsed)
+		observeStatusPage(page, "osmosis", state)
+	}
+
+	// The status on the page is unknown on start, so the first sustained
+	// status is sent
+	step(PoolHealthy, 0)
+	step(PoolHealthy, time.Minute)
+	got := provider.received()
+	want := providerRequest{http.MethodPatch, "/v1/pages/page1/components/osmosis-rpc", `{"component":{"status":"operational"}}`, "OAuth secret"}
+	if len(got) != 1 || got[0] != want {
+		t.Fatalf("expected request %v, got %v", want, got)
+	}
+
+	// A status must last a minute, and changing status restarts the wait
+	step(PoolDegraded, 0)
+	step(PoolCritical, 30*time.Second)
+	step(PoolCritical, 30*time.Second)
+	if got := provider.received(); len(got) != 1 {
+		t.Fatalf("expected no update before the status lasted, got %v", got)
+	}
+	// critical and chain_halted are both a partial outage
+	step(PoolChainHalted, 30*time.Second)
+	got = provider.received()
+	if len(got) != 2 || got[1].body != `{"component":{"status":"partial_outage"}}` {
+		t.Fatalf("expected a partial outage, got %v", got)
+	}
+
+	// A short recovery doesn't reach the page
+	step(PoolHealthy, time.Minute)
+	step(PoolCritical, 30*time.Second)
+	step(PoolCritical, time.Hour)
+	if got := provider.received(); len(got) != 2 {
+		t.Fatalf("expected the page to stay at partial outage, got %v", got)
+	}
+
+	step(PoolDown, 0)
+	step(PoolDown, time.Minute)
+	got = provider.received()
+	if len(got) != 3 || got[2].body != `{"component":{"status":"major_outage"}}` {
+		t.Fatalf("expected a major outage, got %v", got)
+	}
+}
+
+func TestStatusPage_RetriesFailedRequests(t *testing.T) {
+	provider := &fakeProvider{statuses: []int{http.StatusTooManyRequests}}
+	server := httptest.NewServer(provider)
+	defer server.Close()
+
+	clock := time.Now()
+	page := newTestStatusPage(t, server, &clock)
+	page.after = 0
+	observeStatusPage(page, "osmosis", PoolDown)
+	if page.known {
+		t.Fatal("expected a failed update not to count")
+	}
+
+	observeStatusPage(page, "osmosis", PoolDown)
+	if !page.known || page.level != statusLevel(PoolDown) {
+		t.Fatal("expected the retried update to succeed")
+	}
+	if got := provider.received(); len(got) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(got))
+	}
+}
+
+func TestStatusPage_Providers(t *testing.T) {
+	tests := []struct {
+		config     StatusPageConfig
+		method     string
+		url        string
+		authHeader string
+		auth       string
+		body       string
+	}{
+		{
+			config:     StatusPageConfig{Provider: StatusPageInstatus, Page: "page1", Component: "c1", APIKey: "key"},
+			method:     http.MethodPut,
+			url:        "https://api.instatus.com/v1/page1/components/c1",
+			authHeader: "Authorization",
+			auth:       "Bearer key",
+			body:       `{"status":"DEGRADEDPERFORMANCE"}`,
+		},
+		{
+			config:     StatusPageConfig{Provider: StatusPageCachet, URL: "https://status.example.com/", Component: "7", APIKey: "key"},
+			method:     http.MethodPut,
+			url:        "https://status.example.com/api/v1/components/7",
+			authHeader: "X-Cachet-Token",
+			auth:       "key",
+			body:       `{"status":2}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.config.Provider, func(t *testing.T) {
+			page := &statusPage{config: &tt.config}
+			req, err := page.request(statusLevel(PoolDegraded))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			body, _ := io.ReadAll(req.Body)
+			if req.Method != tt.method || req.URL.String() != tt.url || string(body) != tt.body {
+				t.Errorf("unexpected request %s %s %s", req.Method, req.URL, body)
+			}
+			if got := req.Header.Get(tt.authHeader); got != tt.auth {
+				t.Errorf("expected %s %q, got %q", tt.authHeader, tt.auth, got)
+			}
+		})
+	}
+}
+
+func TestStatusPage_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:8545
+			type evm
+		}
+		status_page statuspage {
+			page kctbh9vrtdwd
+			component 8kbf7d35c070
+			api_key {env.STATUSPAGE_API_KEY}
+			after 2m
+		}
+	}`)
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	sp := b.StatusPage
+	if sp.Provider != StatusPageStatuspage || sp.Page != "kctbh9vrtdwd" || sp.Component != "8kbf7d35c070" ||
+		sp.APIKey != "{env.STATUSPAGE_API_KEY}" || sp.After != "2m" {
+		t.Fatalf("unexpected status_page settings: %+v", sp)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+}
+
+func TestStatusPageConfig_Validate(t *testing.T) {
+	valid := StatusPageConfig{Provider: StatusPageStatuspage, Page: "page1", Component: "c1", APIKey: "key"}
+	if err := valid.validate(); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+
+	for name, mutate := range map[string]func(*StatusPageConfig){
+		"unknown provider":  func(c *StatusPageConfig) { c.Provider = "atlassian" },
+		"missing component": func(c *StatusPageConfig) { c.Component = "" },
+		"missing api_key":   func(c *StatusPageConfig) { c.APIKey = "" },
+		"missing page":      func(c *StatusPageConfig) { c.Page = "" },
+		"cachet without url": func(c *StatusPageConfig) {
+			c.Provider, c.Page = StatusPageCachet, ""
+		},
+		"invalid url":    func(c *StatusPageConfig) { c.URL = "status.example.com" },
+		"negative after": func(c *StatusPageConfig) { c.After = "-1s" },
+		"zero timeout":   func(c *StatusPageConfig) { c.Timeout = "0s" },
+	} {
+		config := valid
+		mutate(&config)
+		if err := config.validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
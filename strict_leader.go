@@ -0,0 +1,30 @@
+package blockchain_health
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// applyStrictLeader marks every node more than StrictLeaderThreshold blocks
+// behind the network head unhealthy. The head is the highest of the pool
+// leader and the enabled external references, so a pool that is behind the
+// network as a whole is excluded too, even if no node is left.
+func (h *HealthChecker) applyStrictLeader(nodes []*NodeHealth, networkHead uint64) {
+	threshold := uint64(h.config.BlockValidation.StrictLeaderThreshold)
+
+	for _, node := range nodes {
+		if !node.Healthy || node.BlockHeight+threshold >= networkHead {
+			continue
+		}
+
+		node.Healthy = false
+		node.LastError = fmt.Sprintf("strict leader mode: %d blocks behind network head %d",
+			networkHead-node.BlockHeight, networkHead)
+		h.logger.Debug("node excluded by strict leader mode",
+			zap.String("node", node.Name),
+			zap.Uint64("node_height", node.BlockHeight),
+			zap.Uint64("network_head", networkHead),
+			zap.Uint64("threshold", threshold))
+	}
+}
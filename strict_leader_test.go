@@ -0,0 +1,92 @@
+package blockchain_health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestStrictLeaderOnly(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	head := createCosmosServer(t, 1000, false)
+	defer head.Close()
+	lagging := createCosmosServer(t, 998, false)
+	defer lagging.Close()
+	reference := createCosmosServer(t, 1003, false)
+	defer reference.Close()
+
+	nodes := []NodeConfig{
+		{Name: "head", URL: head.URL, Type: NodeTypeCosmos, Weight: 1},
+		{Name: "lagging", URL: lagging.URL, Type: NodeTypeCosmos, Weight: 1},
+	}
+
+	tests := []struct {
+		name      string
+		threshold int
+		expected  map[string]bool
+	}{
+		{"WithinThresholdOfReference", 3, map[string]bool{"head": true, "lagging": false}},
+		{"WholePoolBehindReference", 0, map[string]bool{"head": false, "lagging": false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := createTestUpstream(nodes, logger)
+			upstream.config.BlockValidation.HeightThreshold = 5
+			upstream.config.BlockValidation.StrictLeaderOnly = true
+			upstream.config.BlockValidation.StrictLeaderThreshold = tt.threshold
+			upstream.config.ExternalReferences = []ExternalReference{
+				{Name: "reference", URL: reference.URL, Type: NodeTypeCosmos, Enabled: true},
+			}
+
+			results, err := upstream.healthChecker.CheckAllNodes(context.Background())
+			if err != nil {
+				t.Fatalf("CheckAllNodes failed: %v", err)
+			}
+			for _, result := range results {
+				if result.Healthy != tt.expected[result.Name] {
+					t.Errorf("Expected %s healthy=%v, got %v (%s)", result.Name, tt.expected[result.Name], result.Healthy, result.LastError)
+				}
+			}
+		})
+	}
+}
+
+func TestStrictLeaderOnly_AllLagging(t *testing.T) {
+	first := createCosmosServer(t, 998, false)
+	defer first.Close()
+	second := createCosmosServer(t, 997, false)
+	defer second.Close()
+	reference := createCosmosServer(t, 1003, false)
+	defer reference.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "first", URL: first.URL, Type: NodeTypeCosmos, ChainType: "akash", Weight: 1},
+		{Name: "second", URL: second.URL, Type: NodeTypeCosmos, ChainType: "akash", Weight: 1},
+	}, zaptest.NewLogger(t))
+	upstream.config.BlockValidation.HeightThreshold = 5
+	upstream.config.BlockValidation.StrictLeaderOnly = true
+	upstream.config.ExternalReferences = []ExternalReference{
+		{Name: "reference", URL: reference.URL, Type: NodeTypeCosmos, Enabled: true},
+	}
+
+	// The default fallback would route to the lagging nodes
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	upstreams, err := upstream.GetUpstreams(r)
+	var degraded *ErrChainDegraded
+	if !errors.As(err, &degraded) || degraded.Chain != "akash" {
+		t.Fatalf("expected ErrChainDegraded with no node at the network head, got %d upstreams and %v", len(upstreams), err)
+	}
+
+	// External providers are at the head, so they may still stand in
+	upstream.config.FailureHandling.FallbackStrategy = FallbackExternalProviders
+	upstreams, err = upstream.GetUpstreams(r)
+	if err != nil || len(upstreams) != 1 {
+		t.Errorf("expected the external provider as fallback, got %d upstreams and %v", len(upstreams), err)
+	}
+}
@@ -0,0 +1,116 @@
+package blockchain_health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// strictResolveTimeout bounds the lookup of each node host with resolve on
+const strictResolveTimeout = 5 * time.Second
+
+// metadataKeys are the node metadata keys the module reads or sets. Strict
+// validation rejects any other key unless it is listed in metadata_keys or
+// is the traffic split label.
+var metadataKeys = map[string]bool{
+	"service_type":     true,
+	"chain_type":       true,
+	"candidate":        true,
+	"backfill":         true,
+	"tier":             true,
+	"hot_weight":       true,
+	"cold_weight":      true,
+	"probe_mode":       true,
+	"rest_base_path":   true,
+	"max_logs_range":   true,
+	"event_stream":     true,
+	"beacon_client":    true,
+	"execution_client": true,
+	"http_url":         true,
+	"auto_generated":   true,
+	"source":           true,
+	costMetadataKey:    true,
+}
+
+// lookupHost resolves host names for the resolve check, replaced in tests
+var lookupHost = net.DefaultResolver.LookupHost
+
+// strictValidate checks the pool's nodes with strict_validation on. Unlike
+// validate, it sees the nodes generated from environment lists, so it runs
+// at provision.
+func (b *BlockchainHealthUpstream) strictValidate(ctx context.Context, nodes []NodeConfig) error {
+	allowed := make(map[string]bool, len(b.StrictValidation.MetadataKeys)+1)
+	for _, key := range b.StrictValidation.MetadataKeys {
+		allowed[key] = true
+	}
+	if b.TrafficSplit.Label != "" {
+		allowed[b.TrafficSplit.Label] = true
+	}
+
+	names := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		if names[node.Name] {
+			return fmt.Errorf("node name %s is used by more than one node", node.Name)
+		}
+		names[node.Name] = true
+
+		keys := make([]string, 0, len(node.Metadata))
+		for key := range node.Metadata {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if !metadataKeys[key] && !allowed[key] {
+				return fmt.Errorf("node %s: unknown metadata key %q (list custom keys in metadata_keys)", node.Name, key)
+			}
+		}
+
+		for _, chain := range []string{node.ChainType, node.Metadata["chain_type"]} {
+			if chain == "" {
+				continue
+			}
+			if protocol := knownChainProtocol(chain); protocol != "" && NodeType(protocol) != node.Type {
+				return fmt.Errorf("node %s: type %s does not match chain %s, which is a %s chain", node.Name, node.Type, chain, protocol)
+			}
+		}
+	}
+
+	if b.StrictValidation.Resolve {
+		return resolveNodeHosts(ctx, nodes)
+	}
+	return nil
+}
+
+// resolveNodeHosts looks up the host of every URL of the nodes, so a
+// misspelled host fails at startup instead of leaving its node down
+func resolveNodeHosts(ctx context.Context, nodes []NodeConfig) error {
+	resolved := make(map[string]bool)
+	for _, node := range nodes {
+		urls := append([]string{node.URL, node.APIURL, node.WebSocketURL}, node.AlternateURLs...)
+		for _, rawURL := range urls {
+			if rawURL == "" {
+				continue
+			}
+			parsedURL, err := url.Parse(rawURL)
+			if err != nil {
+				return fmt.Errorf("node %s: invalid URL: %w", node.Name, err)
+			}
+			host := parsedURL.Hostname()
+			if host == "" || net.ParseIP(host) != nil || resolved[host] {
+				continue
+			}
+
+			lookupCtx, cancel := context.WithTimeout(ctx, strictResolveTimeout)
+			_, err = lookupHost(lookupCtx, host)
+			cancel()
+			if err != nil {
+				return fmt.Errorf("node %s: host %s does not resolve: %w", node.Name, host, err)
+			}
+			resolved[host] = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package blockchain_health
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+)
+
+func TestStrictValidate(t *testing.T) {
+	tests := map[string]struct {
+		nodes []NodeConfig
+		err   string
+	}{
+		"valid": {
+			nodes: []NodeConfig{
+				{Name: "a", URL: "http://localhost:8545", Type: NodeTypeEVM, ChainType: "ethereum", Metadata: map[string]string{"service_type": "rpc", "region": "eu"}},
+				{Name: "b", URL: "http://localhost:26657", Type: NodeTypeCosmos, ChainType: "my-appchain"},
+			},
+		},
+		"unknown metadata key": {
+			nodes: []NodeConfig{{Name: "a", URL: "http://localhost:8545", Type: NodeTypeEVM, Metadata: map[string]string{"servce_type": "rpc"}}},
+			err:   `unknown metadata key "servce_type"`,
+		},
+		"duplicate name": {
+			nodes: []NodeConfig{
+				{Name: "a", URL: "http://localhost:8545", Type: NodeTypeEVM},
+				{Name: "a", URL: "http://localhost:8546", Type: NodeTypeEVM},
+			},
+			err: "node name a is used by more than one node",
+		},
+		"type contradicts chain": {
+			nodes: []NodeConfig{{Name: "a", URL: "http://localhost:26657", Type: NodeTypeCosmos, ChainType: "ethereum"}},
+			err:   "does not match chain ethereum",
+		},
+	}
+
+	b := &BlockchainHealthUpstream{
+		StrictValidation: StrictValidationConfig{Enabled: true, MetadataKeys: []string{"region"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := b.strictValidate(t.Context(), tt.nodes)
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("expected the nodes to pass, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestStrictValidate_TrafficSplitLabel(t *testing.T) {
+	b := &BlockchainHealthUpstream{
+		StrictValidation: StrictValidationConfig{Enabled: true},
+		TrafficSplit:     TrafficSplitConfig{Label: "provider"},
+	}
+	nodes := []NodeConfig{{Name: "a", URL: "http://localhost:8545", Type: NodeTypeEVM, Metadata: map[string]string{"provider": "alchemy"}}}
+	if err := b.strictValidate(t.Context(), nodes); err != nil {
+		t.Errorf("expected the traffic split label to be allowed, got %v", err)
+	}
+}
+
+func TestStrictValidate_Resolve(t *testing.T) {
+	original := lookupHost
+	t.Cleanup(func() { lookupHost = original })
+	var looked []string
+	lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		looked = append(looked, host)
+		if host == "rpc.example.invalid" {
+			return nil, errors.New("no such host")
+		}
+		return []string{"192.0.2.1"}, nil
+	}
+
+	b := &BlockchainHealthUpstream{StrictValidation: StrictValidationConfig{Enabled: true, Resolve: true}}
+	nodes := []NodeConfig{
+		{Name: "a", URL: "http://rpc.example.com:8545", WebSocketURL: "ws://rpc.example.com:8546", Type: NodeTypeEVM},
+		{Name: "b", URL: "http://127.0.0.1:8545", Type: NodeTypeEVM},
+	}
+	if err := b.strictValidate(t.Context(), nodes); err != nil {
+		t.Fatalf("expected the hosts to resolve, got %v", err)
+	}
+	if len(looked) != 1 {
+		t.Errorf("expected one lookup for one distinct host name, got %v", looked)
+	}
+
+	nodes = append(nodes, NodeConfig{Name: "c", URL: "http://rpc.example.invalid", Type: NodeTypeEVM})
+	if err := b.strictValidate(t.Context(), nodes); err == nil || !strings.Contains(err.Error(), "does not resolve") {
+		t.Errorf("expected an unresolvable host to be rejected, got %v", err)
+	}
+
+	// Without resolve, hosts are not looked up
+	looked = nil
+	b.StrictValidation.Resolve = false
+	if err := b.strictValidate(t.Context(), nodes); err != nil || len(looked) != 0 {
+		t.Errorf("expected no lookups without resolve, got %v, %v", looked, err)
+	}
+}
+
+func TestStrictValidation_Provision(t *testing.T) {
+	b := &BlockchainHealthUpstream{
+		Nodes: []NodeConfig{
+			{Name: "a", URL: "http://localhost:8545", Type: NodeTypeEVM, Weight: 1, Metadata: map[string]string{"regoin": "eu"}},
+		},
+	}
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	defer cancel()
+
+	b.StrictValidation.Enabled = true
+	if err := b.Provision(ctx); err == nil || !strings.Contains(err.Error(), "strict validation") {
+		t.Errorf("expected provisioning to fail strict validation, got %v", err)
+	}
+}
+
+func TestStrictValidation_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:8545
+			type evm
+		}
+		strict_validation on {
+			resolve
+			metadata_keys region provider
+		}
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	want := StrictValidationConfig{Enabled: true, Resolve: true, MetadataKeys: []string{"region", "provider"}}
+	if b.StrictValidation.Enabled != want.Enabled || b.StrictValidation.Resolve != want.Resolve ||
+		strings.Join(b.StrictValidation.MetadataKeys, " ") != "region provider" {
+		t.Errorf("expected %+v, got %+v", want, b.StrictValidation)
+	}
+
+	d = caddyfile.NewTestDispenser(`blockchain_health {
+		strict_validation maybe
+	}`)
+	if err := new(BlockchainHealthUpstream).UnmarshalCaddyfile(d); err == nil {
+		t.Error("expected an invalid strict_validation value to be rejected")
+	}
+}
@@ -0,0 +1,139 @@
+package blockchain_health
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Throttling defaults
+const (
+	defaultThrottleBackoff      = 60 * time.Second
+	defaultThrottleWeightFactor = 0.5
+
+	// maxThrottledChecks is how many consecutive throttled probes a node is
+	// kept in the pool for before it is marked unhealthy
+	maxThrottledChecks = 3
+)
+
+// throttleState tracks a node's consecutive throttled probes and the last
+// height it reported while not throttled
+type throttleState struct {
+	consecutive int
+	lastHeight  uint64
+}
+
+// errThrottled marks errors caused by a node rate limiting the health check
+var errThrottled = errors.New("rate limited by node")
+
+// statusError builds the error for an unexpected HTTP status, marking 429
+// responses as throttled
+func statusError(kind string, code int) error {
+	if code == http.StatusTooManyRequests {
+		return fmt.Errorf("%s status %d: %w", kind, code, errThrottled)
+	}
+	return fmt.Errorf("%s status %d", kind, code)
+}
+
+// isRateLimitRPCError reports whether a JSON-RPC error is a provider rate limit.
+// -32005 is the EIP-1474 "limit exceeded" code; several providers also use
+// -32029 or echo 429.
+func isRateLimitRPCError(code int, message string) bool {
+	switch code {
+	case -32005, -32029, 429:
+		return true
+	}
+	message = strings.ToLower(message)
+	return strings.Contains(message, "rate limit") || strings.Contains(message, "too many requests")
+}
+
+// throttleBackoff returns how long a throttled node is left unprobed
+func (h *HealthChecker) throttleBackoff() time.Duration {
+	if d, err := time.ParseDuration(h.config.FailureHandling.ThrottleBackoff); err == nil && d > 0 {
+		return d
+	}
+	return defaultThrottleBackoff
+}
+
+// handleThrottled keeps a rate limited node in the pool and backs off probing.
+// The node's fresh height is unknown while throttled, so it reports its last
+// known good height, is left out of height validation and does not count as a
+// failure for the circuit breaker. Nodes without a known height, or throttled
+// for more than maxThrottledChecks probes in a row, are marked unhealthy.
+func (h *HealthChecker) handleThrottled(node NodeConfig, health *NodeHealth) {
+	h.mutex.Lock()
+	state := h.getThrottleState(node.Name)
+	state.consecutive++
+	consecutive, lastHeight := state.consecutive, state.lastHeight
+	h.mutex.Unlock()
+
+	health.BlockHeight = lastHeight
+	health.Healthy = lastHeight > 0 && consecutive <= maxThrottledChecks
+
+	backoff := h.throttleBackoff()
+	if health.Healthy {
+		h.logger.Info("node is rate limiting health checks; keeping it at reduced weight",
+			zap.String("node", node.Name),
+			zap.Duration("backoff", backoff),
+			zap.Int("consecutive", consecutive),
+			zap.String("error", health.LastError))
+	} else {
+		h.logger.Warn("node is rate limiting health checks; marking it unhealthy",
+			zap.String("node", node.Name),
+			zap.Duration("backoff", backoff),
+			zap.Int("consecutive", consecutive),
+			zap.Uint64("last_known_height", lastHeight),
+			zap.String("error", health.LastError))
+	}
+
+	if h.metrics != nil {
+		h.metrics.throttledChecks.WithLabelValues(node.Name).Inc()
+		h.metrics.nodeThrottled.WithLabelValues(node.Name).Set(1)
+	}
+
+	// Cache for the backoff so the node is probed less often
+	h.cache.SetWithTTL(node.key(), health, backoff)
+}
+
+// recordUnthrottled clears a node's throttled streak and remembers its height
+func (h *HealthChecker) recordUnthrottled(node NodeConfig, health *NodeHealth) {
+	h.mutex.Lock()
+	state := h.getThrottleState(node.Name)
+	state.consecutive = 0
+	if health.Healthy && health.BlockHeight > 0 {
+		state.lastHeight = health.BlockHeight
+	}
+	h.mutex.Unlock()
+
+	if h.metrics != nil {
+		h.metrics.nodeThrottled.WithLabelValues(node.Name).Set(0)
+	}
+}
+
+// getThrottleState gets or creates the throttle state for a node.
+// Callers must hold h.mutex.
+func (h *HealthChecker) getThrottleState(nodeName string) *throttleState {
+	key := h.nodeKey(nodeName)
+	state, exists := h.throttleStates[key]
+	if !exists {
+		state = &throttleState{}
+		h.throttleStates[key] = state
+	}
+	return state
+}
+
+// throttledWeight scales a node's weight while it is throttled
+func throttledWeight(weight int, factor float64) int {
+	if factor <= 0 || factor > 1 {
+		factor = defaultThrottleWeightFactor
+	}
+	scaled := int(float64(weight) * factor)
+	if scaled < 1 {
+		scaled = 1
+	}
+	return scaled
+}
@@ -0,0 +1,202 @@
+package blockchain_health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestEVMHandler_ThrottledResponses(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		throttled bool
+	}{
+		{name: "http 429", status: http.StatusTooManyRequests, body: `rate limited`, throttled: true},
+		{name: "limit exceeded code", status: http.StatusOK, body: `{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"limit exceeded"}}`, throttled: true},
+		{name: "rate limit message", status: http.StatusOK, body: `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"Rate limit reached"}}`, throttled: true},
+		{name: "other rpc error", status: http.StatusOK, body: `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}`, throttled: false},
+		{name: "http 503", status: http.StatusServiceUnavailable, body: `unavailable`, throttled: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			handler := NewEVMHandler(5*time.Second, logger)
+			health, err := handler.CheckHealth(context.Background(), NodeConfig{Name: "evm", URL: server.URL, Type: NodeTypeEVM})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if health.Healthy {
+				t.Error("Expected handler to report the check as failed")
+			}
+			if health.Throttled != tt.throttled {
+				t.Errorf("Expected throttled=%v, got %v (%s)", tt.throttled, health.Throttled, health.LastError)
+			}
+		})
+	}
+}
+
+// createThrottlingEVMServer reports the given height until throttle is set,
+// then answers every request with 429 and counts it in throttledRequests
+func createThrottlingEVMServer(t *testing.T, height uint64, throttle *atomic.Bool, throttledRequests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if throttle.Load() {
+			throttledRequests.Add(1)
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":"0x%x"}`, height)
+	}))
+}
+
+func TestThrottledNodeKeptAtReducedWeight(t *testing.T) {
+	tests := []struct {
+		name           string
+		weight         int
+		expectedWeight int
+	}{
+		{name: "halved", weight: 100, expectedWeight: 50},
+		{name: "floored at one", weight: 2, expectedWeight: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := zaptest.NewLogger(t)
+
+			healthy := createEVMServer(t, 1000, false)
+			defer healthy.Close()
+
+			var throttle atomic.Bool
+			var requests atomic.Int32
+			throttled := createThrottlingEVMServer(t, 1000, &throttle, &requests)
+			defer throttled.Close()
+
+			upstream := createTestUpstream([]NodeConfig{
+				{Name: "healthy", URL: healthy.URL, Type: NodeTypeEVM, ChainType: "test-evm", Weight: tt.weight},
+				{Name: "throttled", URL: throttled.URL, Type: NodeTypeEVM, ChainType: "test-evm", Weight: tt.weight},
+			}, logger)
+			upstream.config.HealthCheck.RetryAttempts = 3
+			upstream.config.FailureHandling.ThrottleBackoff = "1m"
+
+			// Learn the node's height before it starts throttling
+			if _, err := upstream.healthChecker.CheckAllNodes(context.Background()); err != nil {
+				t.Fatalf("CheckAllNodes failed: %v", err)
+			}
+			throttle.Store(true)
+			upstream.healthChecker.cache.Clear()
+
+			upstreams, err := upstream.GetUpstreams(&http.Request{})
+			if err != nil {
+				t.Fatalf("GetUpstreams failed: %v", err)
+			}
+			if len(upstreams) != 2 {
+				t.Fatalf("Expected throttled node to stay in the pool, got %d upstreams", len(upstreams))
+			}
+
+			for _, up := range upstreams {
+				if up.Dial == getDynamicTestHostFromURL(throttled.URL) && up.MaxRequests != tt.expectedWeight {
+					t.Errorf("Expected throttled node weight %d, got %d", tt.expectedWeight, up.MaxRequests)
+				}
+			}
+
+			health := upstream.healthChecker.cache.Get(upstream.healthChecker.nodeKey("throttled"))
+			if health == nil || health.BlockHeight != 1000 {
+				t.Errorf("Expected throttled node to report its last known height, got %+v", health)
+			}
+
+			// Throttled checks are not retried and are not probed again during the backoff
+			if _, err := upstream.GetUpstreams(&http.Request{}); err != nil {
+				t.Fatalf("GetUpstreams failed: %v", err)
+			}
+			if n := requests.Load(); n != 1 {
+				t.Errorf("Expected a single probe of the throttled node, got %d", n)
+			}
+		})
+	}
+}
+
+func TestThrottledNodeStateIsCapped(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	var throttle atomic.Bool
+	var requests atomic.Int32
+	server := createThrottlingEVMServer(t, 1000, &throttle, &requests)
+	defer server.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "throttled", URL: server.URL, Type: NodeTypeEVM, ChainType: "test-evm", Weight: 1},
+	}, logger)
+	upstream.config.FailureHandling.MinHealthyNodes = 1
+	checker := upstream.healthChecker
+
+	// A node that has never reported a height cannot be kept in the pool
+	throttle.Store(true)
+	results, err := checker.CheckAllNodes(context.Background())
+	if err != nil {
+		t.Fatalf("CheckAllNodes failed: %v", err)
+	}
+	if results[0].Healthy {
+		t.Error("Expected throttled node without a known height to be unhealthy")
+	}
+
+	throttle.Store(false)
+	checker.cache.Clear()
+	if _, err := checker.CheckAllNodes(context.Background()); err != nil {
+		t.Fatalf("CheckAllNodes failed: %v", err)
+	}
+
+	// Throttled nodes are kept for maxThrottledChecks probes, then marked unhealthy
+	throttle.Store(true)
+	for i := 1; i <= maxThrottledChecks+1; i++ {
+		checker.cache.Clear()
+		results, err := checker.CheckAllNodes(context.Background())
+		if err != nil {
+			t.Fatalf("CheckAllNodes failed: %v", err)
+		}
+		if expected := i <= maxThrottledChecks; results[0].Healthy != expected {
+			t.Errorf("Throttled probe %d: expected healthy=%v, got %v", i, expected, results[0].Healthy)
+		}
+	}
+}
+
+func TestThrottledNodesDoNotSatisfyMinHealthyNodes(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	var throttle atomic.Bool
+	var requests atomic.Int32
+	server := createThrottlingEVMServer(t, 1000, &throttle, &requests)
+	defer server.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "throttled", URL: server.URL, Type: NodeTypeEVM, ChainType: "test-evm", Weight: 1},
+	}, logger)
+	upstream.config.FailureHandling.MinHealthyNodes = 1
+
+	if _, err := upstream.healthChecker.CheckAllNodes(context.Background()); err != nil {
+		t.Fatalf("CheckAllNodes failed: %v", err)
+	}
+	throttle.Store(true)
+	upstream.healthChecker.cache.Clear()
+
+	response := upstream.buildHealthResponse(context.Background(), false)
+	if response.Status != "unhealthy" || response.Nodes.Healthy != 0 || response.Nodes.Throttled != 1 {
+		t.Errorf("Expected unhealthy status with one throttled node, got %s %+v", response.Status, response.Nodes)
+	}
+}
@@ -0,0 +1,188 @@
+package blockchain_health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Request tiers: hot requests read recent blocks, cold ones deep history
+const (
+	tierHot  = "hot"
+	tierCold = "cold"
+)
+
+// defaultHotBlocks is how many blocks behind the head a request stays hot
+const defaultHotBlocks = 128
+
+// blockParamIndex is the position of the block number parameter of EVM
+// methods that read the chain at a block. eth_getLogs is read by its filter.
+var blockParamIndex = map[string]int{
+	"eth_getBlockByNumber":                    0,
+	"eth_getBlockReceipts":                    0,
+	"eth_getBlockTransactionCountByNumber":    0,
+	"eth_getTransactionByBlockNumberAndIndex": 0,
+	"eth_getUncleCountByBlockNumber":          0,
+	"eth_getUncleByBlockNumberAndIndex":       0,
+	"debug_traceBlockByNumber":                0,
+	"trace_block":                             0,
+	"trace_replayBlockTransactions":           0,
+	"eth_getBalance":                          1,
+	"eth_getCode":                             1,
+	"eth_getTransactionCount":                 1,
+	"eth_call":                                1,
+	"eth_estimateGas":                         1,
+	"debug_traceCall":                         1,
+	"eth_getStorageAt":                        2,
+	"eth_getProof":                            2,
+}
+
+// validTier reports whether a node's tier metadata names a tier
+func validTier(tier string) bool {
+	return tier == tierHot || tier == tierCold
+}
+
+// tier returns the tier a node serves (metadata tier), or "" for both
+func (n NodeConfig) tier() string {
+	return n.Metadata["tier"]
+}
+
+// tierWeight returns the node's weight for requests of a tier: its
+// hot_weight or cold_weight metadata, else its weight
+func (n NodeConfig) tierWeight(tier string) int {
+	if value, ok := n.Metadata[tier+"_weight"]; ok {
+		if weight, err := strconv.Atoi(value); err == nil && weight > 0 {
+			return weight
+		}
+	}
+	return n.Weight
+}
+
+// threshold returns the height threshold of a tier's sub-pool, if it has its
+// own rather than the pool's
+func (t TieringConfig) threshold(tier string) (int, bool) {
+	var threshold int
+	switch tier {
+	case tierHot:
+		threshold = t.Hot.HeightThreshold
+	case tierCold:
+		threshold = t.Cold.HeightThreshold
+	}
+	return threshold, threshold > 0
+}
+
+// heightLagOnly reports whether a node was ejected only for trailing the
+// pool leader, so a tier with a looser threshold can still use it
+func (n *NodeHealth) heightLagOnly() bool {
+	return !n.Healthy && !n.HeightValid && n.LastError == "" && !n.Throttled &&
+		n.BlockHeight > 0 && (n.CatchingUp == nil || !*n.CatchingUp)
+}
+
+// requestTier classifies a request as hot or cold by the oldest block it
+// reads, compared with the pool's highest block. Requests for no particular
+// block read the head and are hot. It returns "" when tiering is off.
+func (b *BlockchainHealthUpstream) requestTier(r *http.Request, results []*NodeHealth) string {
+	if !b.config.Tiering.Enabled || r == nil {
+		return ""
+	}
+	var head uint64
+	for _, health := range results {
+		if !health.Throttled {
+			head = max(head, health.BlockHeight)
+		}
+	}
+	hotBlocks := uint64(b.config.Tiering.HotBlocks)
+	if height, ok := requestedTierHeight(r, head); ok && head > hotBlocks && height < head-hotBlocks {
+		return tierCold
+	}
+	return tierHot
+}
+
+// requestedTierHeight returns the oldest block a request reads: a Cosmos
+// height (header, REST path or CometBFT height parameter), or the block
+// parameters of EVM JSON-RPC calls, with block tags resolved against head
+func requestedTierHeight(r *http.Request, head uint64) (uint64, bool) {
+	if height := requestedBlockHeight(r); height > 0 {
+		return height, true
+	}
+	if r.URL != nil {
+		if height, err := strconv.ParseUint(r.URL.Query().Get("height"), 10, 64); err == nil && height > 0 {
+			return height, true
+		}
+	}
+	if r.Method != http.MethodPost {
+		return 0, false
+	}
+	body, _, err := readRPCBody(r, 0)
+	if err != nil {
+		return 0, false
+	}
+	calls, _, err := parseRPCCalls(body)
+	if err != nil {
+		return 0, false
+	}
+
+	oldest, found := uint64(0), false
+	for _, call := range calls {
+		height, ok := callHeight(call, head)
+		if ok && (!found || height < oldest) {
+			oldest, found = height, true
+		}
+	}
+	return oldest, found
+}
+
+// callHeight returns the block a JSON-RPC call reads, if it names one
+func callHeight(call rpcMessage, head uint64) (uint64, bool) {
+	if _, from, _, ok := logsCallRange(call, head); ok {
+		return from, true
+	}
+
+	var params []json.RawMessage
+	if json.Unmarshal(call.Params, &params) != nil {
+		// CometBFT calls name the height in an object
+		var named struct {
+			Height string `json:"height"`
+		}
+		if json.Unmarshal(call.Params, &named) != nil {
+			return 0, false
+		}
+		height, err := strconv.ParseUint(named.Height, 10, 64)
+		return height, err == nil && height > 0
+	}
+
+	index, ok := blockParamIndex[call.Method]
+	if !ok {
+		return 0, false
+	}
+	if index >= len(params) {
+		return head, true
+	}
+	return resolveLogsBlock(params[index], head)
+}
+
+// validateTiering checks the tiering section and the tier metadata of nodes
+func (b *BlockchainHealthUpstream) validateTiering() error {
+	if b.Tiering.HotBlocks < 0 {
+		return fmt.Errorf("tiering hot_blocks must not be negative")
+	}
+	if b.Tiering.Hot.HeightThreshold < 0 || b.Tiering.Cold.HeightThreshold < 0 {
+		return fmt.Errorf("tiering height_threshold must not be negative")
+	}
+	for _, node := range b.Nodes {
+		if tier, ok := node.Metadata["tier"]; ok && !validTier(tier) {
+			return fmt.Errorf("node %s: invalid tier %q (must be hot or cold)", node.Name, tier)
+		}
+		for _, key := range []string{"hot_weight", "cold_weight"} {
+			value, ok := node.Metadata[key]
+			if !ok {
+				continue
+			}
+			if weight, err := strconv.Atoi(value); err != nil || weight <= 0 {
+				return fmt.Errorf("node %s: %s must be a positive integer", node.Name, key)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package blockchain_health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestRequestedTierHeight(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   uint64
+		found  bool
+	}{
+		{name: "block by number", body: `{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["0x10",false]}`, want: 16, found: true},
+		{name: "state at latest", body: `{"jsonrpc":"2.0","id":1,"method":"eth_getBalance","params":["0xabc","latest"]}`, want: 1000, found: true},
+		{name: "call without block", body: `{"jsonrpc":"2.0","id":1,"method":"eth_call","params":[{"to":"0xabc"}]}`, want: 1000, found: true},
+		{name: "storage at earliest", body: `{"jsonrpc":"2.0","id":1,"method":"eth_getStorageAt","params":["0xabc","0x0","earliest"]}`, want: 0, found: true},
+		{name: "log filter", body: `{"jsonrpc":"2.0","id":1,"method":"eth_getLogs","params":[{"fromBlock":"0x5","toBlock":"latest"}]}`, want: 5, found: true},
+		{name: "batch reads the oldest", body: `[{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["latest",false]},{"jsonrpc":"2.0","id":2,"method":"eth_getCode","params":["0xabc","0x20"]}]`, want: 32, found: true},
+		{name: "CometBFT height", body: `{"jsonrpc":"2.0","id":1,"method":"block","params":{"height":"100"}}`, want: 100, found: true},
+		{name: "no block", body: `{"jsonrpc":"2.0","id":1,"method":"eth_sendRawTransaction","params":["0x00"]}`},
+		{name: "REST height", method: http.MethodGet, target: "/cosmos/base/tendermint/v1beta1/blocks/42", want: 42, found: true},
+		{name: "RPC height parameter", method: http.MethodGet, target: "/block?height=7", want: 7, found: true},
+	}
+	for _, tt := range tests {
+		method, target := tt.method, tt.target
+		if method == "" {
+			method, target = http.MethodPost, "/"
+		}
+		r := httptest.NewRequest(method, target, strings.NewReader(tt.body))
+		height, found := requestedTierHeight(r, 1000)
+		if height != tt.want || found != tt.found {
+			t.Errorf("%s: expected %d (%v), got %d (%v)", tt.name, tt.want, tt.found, height, found)
+		}
+	}
+}
+
+func TestTiering_Routing(t *testing.T) {
+	hot := createEVMServer(t, 1000, false)
+	defer hot.Close()
+	archive := createEVMServer(t, 990, false)
+	defer archive.Close()
+	shared := createEVMServer(t, 1000, false)
+	defer shared.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "hot", URL: hot.URL, Type: NodeTypeEVM, ChainType: "test-evm", Weight: 1, Metadata: map[string]string{"tier": "hot"}},
+		{Name: "archive", URL: archive.URL, Type: NodeTypeEVM, ChainType: "test-evm", Weight: 1, Metadata: map[string]string{"tier": "cold"}},
+		{Name: "shared", URL: shared.URL, Type: NodeTypeEVM, ChainType: "test-evm", Weight: 1, Metadata: map[string]string{"cold_weight": "5"}},
+	}, zaptest.NewLogger(t))
+	upstream.config.Tiering = TieringConfig{Enabled: true, HotBlocks: 128, Cold: TierConfig{HeightThreshold: 50}}
+
+	weights := func(body string) map[string]int {
+		t.Helper()
+		upstreams, err := upstream.GetUpstreams(httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		if err != nil {
+			t.Fatalf("GetUpstreams failed: %v", err)
+		}
+		weights := make(map[string]int)
+		for _, up := range upstreams {
+			weights[up.Dial] = up.MaxRequests
+		}
+		return weights
+	}
+	host := getDynamicTestHostFromURL
+
+	// Hot requests skip the cold sub-pool, whose node also trails the leader
+	got := weights(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`)
+	if _, ok := got[host(hot.URL)]; len(got) != 2 || !ok {
+		t.Errorf("expected the hot and shared nodes for hot requests, got %v", got)
+	}
+
+	// Cold requests use the cold sub-pool, held to its own threshold, and the
+	// shared node at its cold weight
+	got = weights(`{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["0x10",false]}`)
+	if _, ok := got[host(archive.URL)]; len(got) != 2 || !ok || got[host(shared.URL)] != 5 {
+		t.Errorf("expected the archive and shared nodes for cold requests, got %v", got)
+	}
+}
+
+func TestTiering_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:8545
+			type evm
+			metadata {
+				tier cold
+				cold_weight 3
+			}
+		}
+		tiering 256 {
+			hot {
+				height_threshold 2
+			}
+			cold {
+				height_threshold 500
+			}
+		}
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	want := TieringConfig{Enabled: true, HotBlocks: 256, Hot: TierConfig{HeightThreshold: 2}, Cold: TierConfig{HeightThreshold: 500}}
+	if b.Tiering != want {
+		t.Errorf("expected %+v, got %+v", want, b.Tiering)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+
+	b.Nodes[0].Metadata["tier"] = "warm"
+	if err := b.validate(); err == nil {
+		t.Error("expected an unknown tier to be rejected")
+	}
+}
@@ -0,0 +1,205 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+)
+
+// trafficSplit is the live state of a traffic split. Pools configured with
+// the same split name share it, so one admin API call adjusts all of them.
+type trafficSplit struct {
+	name string
+
+	mutex   sync.RWMutex
+	label   string
+	weights map[string]int
+}
+
+// Destruct implements caddy.Destructor
+func (s *trafficSplit) Destruct() error {
+	return nil
+}
+
+// snapshot returns the label and a copy of the current weights
+func (s *trafficSplit) snapshot() (string, map[string]int) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	weights := make(map[string]int, len(s.weights))
+	for group, weight := range s.weights {
+		weights[group] = weight
+	}
+	return s.label, weights
+}
+
+// groupLabel returns the metadata key grouping the nodes
+func (s *trafficSplit) groupLabel() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.label
+}
+
+// setWeights replaces the weights
+func (s *trafficSplit) setWeights(weights map[string]int) error {
+	if err := validateSplitWeights(weights); err != nil {
+		return err
+	}
+	copied := make(map[string]int, len(weights))
+	for group, weight := range weights {
+		copied[group] = weight
+	}
+	s.mutex.Lock()
+	s.weights = copied
+	s.mutex.Unlock()
+	return nil
+}
+
+// pick chooses one of the available groups at random by weight, or returns
+// false if none of them has a positive weight
+func (s *trafficSplit) pick(available map[string]bool) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	// Sort for a deterministic walk over the cumulative weights
+	groups := make([]string, 0, len(available))
+	total := 0
+	for group := range available {
+		if s.weights[group] > 0 {
+			groups = append(groups, group)
+			total += s.weights[group]
+		}
+	}
+	if total == 0 {
+		return "", false
+	}
+	sort.Strings(groups)
+
+	n := rand.Intn(total)
+	for _, group := range groups {
+		n -= s.weights[group]
+		if n < 0 {
+			return group, true
+		}
+	}
+	return groups[len(groups)-1], true
+}
+
+// validateSplitWeights checks that weights are usable shares
+func validateSplitWeights(weights map[string]int) error {
+	if len(weights) == 0 {
+		return fmt.Errorf("traffic split requires at least one weight")
+	}
+	total := 0
+	for group, weight := range weights {
+		if weight < 0 {
+			return fmt.Errorf("traffic split weight for %s must not be negative", group)
+		}
+		total += weight
+	}
+	if total == 0 {
+		return fmt.Errorf("traffic split weights must not all be zero")
+	}
+	return nil
+}
+
+// trafficSplits holds the live traffic splits by name. It survives config
+// reloads as long as a pool uses the split, but a reload resets the label and
+// weights to the configured ones.
+var trafficSplits = caddy.NewUsagePool()
+
+// registerTrafficSplit returns the live split for the config, creating it if
+// needed, and applies the configured weights
+func registerTrafficSplit(cfg TrafficSplitConfig) (*trafficSplit, error) {
+	name := cfg.Name
+	if name == "" {
+		name = cfg.Label
+	}
+
+	value, _, err := trafficSplits.LoadOrNew(name, func() (caddy.Destructor, error) {
+		return &trafficSplit{name: name}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	split := value.(*trafficSplit)
+	if err := split.setWeights(cfg.Weights); err != nil {
+		_, _ = trafficSplits.Delete(name)
+		return nil, err
+	}
+	split.mutex.Lock()
+	split.label = cfg.Label
+	split.mutex.Unlock()
+	return split, nil
+}
+
+// releaseTrafficSplit drops a pool's reference to its split
+func releaseTrafficSplit(split *trafficSplit) {
+	_, _ = trafficSplits.Delete(split.name)
+}
+
+// lookupTrafficSplit returns the live split with the given name
+func lookupTrafficSplit(name string) (*trafficSplit, bool) {
+	var found *trafficSplit
+	trafficSplits.Range(func(key, value any) bool {
+		if key == name {
+			found = value.(*trafficSplit)
+			return false
+		}
+		return true
+	})
+	return found, found != nil
+}
+
+// applyTrafficSplit keeps the upstreams of one node group, chosen by the split
+// weights among the groups that have a selected upstream. Nodes whose label
+// value has no weight only receive traffic when no weighted group does.
+func (b *BlockchainHealthUpstream) applyTrafficSplit(upstreams []*reverseproxy.Upstream, infos []selectionInfo) ([]*reverseproxy.Upstream, []selectionInfo) {
+	label := b.split.groupLabel()
+	groups := make([]string, len(upstreams))
+	available := make(map[string]bool)
+	for i := range upstreams {
+		if i >= len(infos) {
+			break
+		}
+		if node := b.findNodeConfig(infos[i].name); node != nil {
+			groups[i] = node.Metadata[label]
+			if groups[i] != "" {
+				available[groups[i]] = true
+			}
+		}
+	}
+
+	group, ok := b.split.pick(available)
+	if !ok {
+		return upstreams, infos
+	}
+
+	var kept []*reverseproxy.Upstream
+	var keptInfos []selectionInfo
+	for i, upstream := range upstreams {
+		if groups[i] != group {
+			if b.metrics != nil && i < len(infos) {
+				b.metrics.upstreamsExcluded.WithLabelValues(infos[i].name, infos[i].serviceType, "traffic_split").Inc()
+			}
+			continue
+		}
+		kept = append(kept, upstream)
+		if i < len(infos) {
+			keptInfos = append(keptInfos, infos[i])
+		}
+	}
+
+	if b.metrics != nil {
+		b.metrics.trafficSplitRequests.WithLabelValues(b.split.name, group).Inc()
+	}
+	return kept, keptInfos
+}
+
+// Interface guards
+var (
+	_ caddy.Destructor = (*trafficSplit)(nil)
+)
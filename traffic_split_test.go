@@ -0,0 +1,152 @@
+package blockchain_health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestTrafficSplit_PickFollowsWeights(t *testing.T) {
+	split := &trafficSplit{name: "test", label: "provider"}
+	if err := split.setWeights(map[string]int{"hetzner": 80, "latitude": 20}); err != nil {
+		t.Fatalf("setWeights failed: %v", err)
+	}
+
+	counts := make(map[string]int)
+	available := map[string]bool{"hetzner": true, "latitude": true}
+	for i := 0; i < 10000; i++ {
+		group, _ := split.pick(available)
+		counts[group]++
+	}
+	if counts["hetzner"] < 7500 || counts["hetzner"] > 8500 {
+		t.Errorf("expected about 80%% of picks for hetzner, got %v", counts)
+	}
+
+	// A group without selected nodes gives its share to the others
+	if group, ok := split.pick(map[string]bool{"latitude": true}); !ok || group != "latitude" {
+		t.Errorf("expected latitude to take all traffic, got %q", group)
+	}
+	if _, ok := split.pick(map[string]bool{"other": true}); ok {
+		t.Error("expected no pick when no weighted group is available")
+	}
+
+	if err := split.setWeights(map[string]int{"hetzner": 0}); err == nil {
+		t.Error("expected all-zero weights to be rejected")
+	}
+}
+
+func TestSelectUpstreams_TrafficSplit(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	hetzner := createCosmosServer(t, 1000, false)
+	defer hetzner.Close()
+	latitude := createCosmosServer(t, 1000, false)
+	defer latitude.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "hetzner-1", URL: hetzner.URL, Type: NodeTypeCosmos, Weight: 1, Metadata: map[string]string{"provider": "hetzner"}},
+		{Name: "latitude-1", URL: latitude.URL, Type: NodeTypeCosmos, Weight: 1, Metadata: map[string]string{"provider": "latitude"}},
+	}, logger)
+
+	split, err := registerTrafficSplit(TrafficSplitConfig{Name: "split-test", Label: "provider", Weights: map[string]int{"hetzner": 100}})
+	if err != nil {
+		t.Fatalf("registerTrafficSplit failed: %v", err)
+	}
+	defer releaseTrafficSplit(split)
+	upstream.split = split
+
+	request := &http.Request{URL: &url.URL{Path: "/"}, Header: http.Header{}}
+	upstreams, err := upstream.GetUpstreams(request)
+	if err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+	if len(upstreams) != 1 || upstreams[0].Dial != getDynamicTestHostFromURL(hetzner.URL) {
+		t.Fatalf("expected only the hetzner node, got %v", upstreams)
+	}
+
+	// Shift all traffic through the admin API
+	rec := httptest.NewRecorder()
+	put := httptest.NewRequest(http.MethodPut, adminTrafficSplitPath+"split-test", strings.NewReader(`{"weights":{"hetzner":0,"latitude":100}}`))
+	if err := (AdminAPI{}).handleTrafficSplit(rec, put); err != nil {
+		t.Fatalf("admin PUT failed: %v", err)
+	}
+
+	upstreams, err = upstream.GetUpstreams(request)
+	if err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+	if len(upstreams) != 1 || upstreams[0].Dial != getDynamicTestHostFromURL(latitude.URL) {
+		t.Errorf("expected only the latitude node after the shift, got %v", upstreams)
+	}
+}
+
+func TestAdminAPI_TrafficSplit(t *testing.T) {
+	split, err := registerTrafficSplit(TrafficSplitConfig{Label: "provider-admin", Weights: map[string]int{"a": 50, "b": 50}})
+	if err != nil {
+		t.Fatalf("registerTrafficSplit failed: %v", err)
+	}
+	defer releaseTrafficSplit(split)
+
+	rec := httptest.NewRecorder()
+	if err := (AdminAPI{}).handleTrafficSplit(rec, httptest.NewRequest(http.MethodGet, adminTrafficSplitPath, nil)); err != nil {
+		t.Fatalf("admin GET failed: %v", err)
+	}
+	var states []trafficSplitState
+	if err := json.Unmarshal(rec.Body.Bytes(), &states); err != nil {
+		t.Fatalf("invalid admin response %s: %v", rec.Body.String(), err)
+	}
+	found := false
+	for _, state := range states {
+		if state.Name == "provider-admin" && state.Label == "provider-admin" && state.Weights["a"] == 50 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected the split to be listed, got %s", rec.Body.String())
+	}
+
+	put := httptest.NewRequest(http.MethodPut, adminTrafficSplitPath+"provider-admin", strings.NewReader(`{"weights":{"a":-1}}`))
+	if err := (AdminAPI{}).handleTrafficSplit(httptest.NewRecorder(), put); err == nil {
+		t.Error("expected negative weights to be rejected")
+	}
+	get := httptest.NewRequest(http.MethodGet, adminTrafficSplitPath+"missing", nil)
+	if err := (AdminAPI{}).handleTrafficSplit(httptest.NewRecorder(), get); err == nil {
+		t.Error("expected an unknown split to be reported")
+	}
+}
+
+func TestTrafficSplit_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:26657
+			type cosmos
+		}
+		traffic_split provider {
+			name cosmoshub
+			weight hetzner 80
+			weight latitude 20
+		}
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if b.TrafficSplit.Name != "cosmoshub" || b.TrafficSplit.Label != "provider" ||
+		b.TrafficSplit.Weights["hetzner"] != 80 || b.TrafficSplit.Weights["latitude"] != 20 {
+		t.Errorf("unexpected traffic split: %+v", b.TrafficSplit)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+
+	b.TrafficSplit.Weights["hetzner"] = -1
+	if err := b.validate(); err == nil {
+		t.Error("expected negative weights to be rejected")
+	}
+}
@@ -2,7 +2,11 @@ package blockchain_health
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -28,6 +32,70 @@ type NodeConfig struct {
 	ChainType    string            `json:"chain_type,omitempty"`
 	Weight       int               `json:"weight"`
 	Metadata     map[string]string `json:"metadata,omitempty"`
+
+	// AlternateURLs are equivalent URLs of the same node, such as its public
+	// address next to an internal one during a network migration. The node is
+	// healthy if any of its URLs is, and is routed to through the best one.
+	AlternateURLs []string `json:"alternate_urls,omitempty"`
+
+	// Headers are static headers proxied requests to this node must carry,
+	// such as an organization token or a Host override. reverse_proxy sends
+	// them through header_up and {blockchain_health.upstream.header.<name>}.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// The node's key, set when its pool is provisioned or its nodes change
+	memo keyMemo
+}
+
+// isCandidate reports whether the node is a shadow candidate (metadata
+// candidate=true). Candidates are health checked and compared against the
+// pool but never receive traffic; promote them by flipping the flag.
+func (n NodeConfig) isCandidate() bool {
+	value := n.Metadata["candidate"]
+	if value == "" {
+		return false
+	}
+	candidate, _ := strconv.ParseBool(value)
+	return candidate
+}
+
+// key identifies the node's internal state (cache, circuit breaker, error
+// window) by a hash of its URL and type, which unlike generated names such as
+// cosmos-rpc-0 doesn't change when nodes are reordered
+func (n NodeConfig) key() string {
+	id := nodeIdentity{nodeType: n.Type, url: n.URL}
+	if n.memo.key != "" && n.memo.id == id {
+		return n.memo.key
+	}
+	return id.key()
+}
+
+// nodeIdentity is what a node key is derived from
+type nodeIdentity struct {
+	nodeType NodeType
+	url      string
+}
+
+// key hashes the identity into a node key
+func (id nodeIdentity) key() string {
+	sum := sha256.Sum256([]byte(string(id.nodeType) + "|" + id.url))
+	return hex.EncodeToString(sum[:8])
+}
+
+// keyMemo holds a node key with the identity it was derived from, so a copy
+// of the node given another URL doesn't keep the original's key
+type keyMemo struct {
+	id  nodeIdentity
+	key string
+}
+
+// memoizeKeys stores the key of each node on it, as every request looks up
+// the keys of every node
+func memoizeKeys(nodes []NodeConfig) {
+	for i := range nodes {
+		id := nodeIdentity{nodeType: nodes[i].Type, url: nodes[i].URL}
+		nodes[i].memo = keyMemo{id: id, key: id.key()}
+	}
 }
 
 // ExternalReference represents an external blockchain endpoint for validation
@@ -36,65 +104,417 @@ type ExternalReference struct {
 	URL     string   `json:"url"`
 	Type    NodeType `json:"type"`
 	Enabled bool     `json:"enabled"`
+
+	// Explorer reads the height from a block explorer API at URL instead of
+	// a node: "etherscan", "blockscout" or "mintscan". APIKey is sent as the
+	// explorer expects it.
+	Explorer string `json:"explorer,omitempty"`
+	APIKey   string `json:"api_key,omitempty"`
+
+	// Request budget when used as a fallback provider; 0 means unlimited
+	RequestsPerMinute int `json:"requests_per_minute,omitempty"`
+	Burst             int `json:"burst,omitempty"` // defaults to RequestsPerMinute
 }
 
 // HealthCheckConfig holds health check configuration
 type HealthCheckConfig struct {
-	Interval      string `json:"interval"`
-	Timeout       string `json:"timeout"`
-	RetryAttempts int    `json:"retry_attempts"`
-	RetryDelay    string `json:"retry_delay"`
+	Interval      string `json:"interval"`       // how often nodes are checked; defaults to 15s
+	Timeout       string `json:"timeout"`        // per check; defaults to 5s
+	RetryAttempts int    `json:"retry_attempts"` // defaults to 3
+	RetryDelay    string `json:"retry_delay"`    // defaults to 1s
+
+	// ProbeMode selects the Cosmos RPC probe: "status" (default), "health"
+	// (/health liveness plus /abci_info height) or "abci_info" (app height only).
+	// Nodes can override it with a "probe_mode" metadata entry.
+	ProbeMode string `json:"probe_mode,omitempty"`
+
+	// UserAgent replaces the default caddy-blockchain-health/<version>
+	// User-Agent of health checks, which always carry an X-Health-Check header
+	UserAgent string `json:"user_agent,omitempty"`
 }
 
 // BlockValidationConfig holds block height validation configuration
 type BlockValidationConfig struct {
-	HeightThreshold            int `json:"height_threshold"`
-	ExternalReferenceThreshold int `json:"external_reference_threshold"`
+	HeightThreshold            int `json:"height_threshold"`             // blocks a node may trail the pool's highest; defaults to 5
+	ExternalReferenceThreshold int `json:"external_reference_threshold"` // blocks a node may trail external references; defaults to 10
+
+	// TrackEarliestBlock probes each EVM node's earliest available block in
+	// the background so its usable range can be reported
+	TrackEarliestBlock bool `json:"track_earliest_block,omitempty"`
+
+	// TrackLogsRange probes each EVM node's eth_getLogs block range limit in
+	// the background, unless set by its max_logs_range metadata, so log
+	// queries are only routed to nodes that accept their range
+	TrackLogsRange bool `json:"track_logs_range,omitempty"`
+
+	// StrictLeaderOnly routes only to nodes within StrictLeaderThreshold blocks
+	// of the network head (pool leader or external reference, whichever is
+	// higher), preferring correctness over availability
+	StrictLeaderOnly      bool `json:"strict_leader_only,omitempty"`
+	StrictLeaderThreshold int  `json:"strict_leader_threshold,omitempty"`
+
+	// HeightThresholdTime replaces HeightThreshold with a staleness, such as
+	// 30s, converted to blocks with the chain's block time: BlockTime, else
+	// the typical block time of the chain type or preset
+	HeightThresholdTime string `json:"height_threshold_time,omitempty"`
+	BlockTime           string `json:"block_time,omitempty"`
+
+	// TrackFinality reads each EVM node's finalized block, so requests for
+	// finalized data are routed by finalized lag instead of head lag, with
+	// FinalizedThreshold blocks of lag allowed (default 64)
+	TrackFinality      bool `json:"track_finality,omitempty"`
+	FinalizedThreshold int  `json:"finalized_threshold,omitempty"`
+
+	// FinalityEpochThreshold is how many epochs a Beacon node's finalized
+	// checkpoint may trail the pool's before validator requests avoid it
+	// (default 2)
+	FinalityEpochThreshold int `json:"finality_epoch_threshold,omitempty"`
+
+	// HaltMultiple is how many block times the pool leader may go without a
+	// new block before the chain is considered halted (default 10)
+	HaltMultiple int `json:"halt_multiple,omitempty"`
+
+	// ReferenceMaxAhead is how many blocks an external reference may be ahead
+	// of the pool leader before it is discarded as implausible (default 1000).
+	// A reference more than ExternalReferenceThreshold blocks behind the pool
+	// leader is discarded too.
+	ReferenceMaxAhead int `json:"reference_max_ahead,omitempty"`
+
+	// ExternalLagAction is what happens to nodes trailing an external
+	// reference: "warn" (default) only flags them, "downweight" scales their
+	// weight by ExternalLagWeightFactor (default 0.5), "exclude" stops
+	// routing to them
+	ExternalLagAction       string  `json:"external_lag_action,omitempty"`
+	ExternalLagWeightFactor float64 `json:"external_lag_weight_factor,omitempty"`
 }
 
 // PerformanceConfig holds performance-related configuration
 type PerformanceConfig struct {
-	CacheDuration       string `json:"cache_duration"`
-	MaxConcurrentChecks int    `json:"max_concurrent_checks"`
+	CacheDuration       string `json:"cache_duration"`        // how long health results are reused; defaults to 30s
+	MaxConcurrentChecks int    `json:"max_concurrent_checks"` // defaults to 10
 }
 
 // FailureHandlingConfig holds failure handling configuration
 type FailureHandlingConfig struct {
-	MinHealthyNodes         int     `json:"min_healthy_nodes"`
-	GracePeriod             string  `json:"grace_period"`
-	CircuitBreakerThreshold float64 `json:"circuit_breaker_threshold"`
+	MinHealthyNodes         int     `json:"min_healthy_nodes"`         // defaults to 1
+	GracePeriod             string  `json:"grace_period"`              // defaults to 60s
+	CircuitBreakerThreshold float64 `json:"circuit_breaker_threshold"` // error rate (0-1) opening a node's breaker; defaults to 0.8
+
+	// ErrorRateWindow is the sliding window over which each node's error rate
+	// is measured. The circuit breaker opens once the error rate within the
+	// window reaches CircuitBreakerThreshold over at least ErrorRateMinSamples
+	// outcomes.
+	ErrorRateWindow     string `json:"error_rate_window,omitempty"`
+	ErrorRateMinSamples int    `json:"error_rate_min_samples,omitempty"`
+
+	// ThrottleBackoff is how long a rate limited node is left unprobed, and
+	// ThrottleWeightFactor scales its weight while it is throttled
+	ThrottleBackoff      string  `json:"throttle_backoff,omitempty"`
+	ThrottleWeightFactor float64 `json:"throttle_weight_factor,omitempty"`
+
+	// MaxWait holds requests for up to this long while no node is healthy,
+	// retrying selection as soon as one recovers. Empty disables waiting.
+	MaxWait string `json:"max_wait,omitempty"`
+
+	// FallbackStrategy decides what to route to when no node is healthy:
+	// all (default), best_effort_highest, external_providers or error
+	FallbackStrategy string `json:"fallback_strategy,omitempty"`
+
+	// Enforce controls whether unhealthy nodes are actually removed from the
+	// upstream pool. When false the module only logs and records the exclusions
+	// it would have made (dry-run). Defaults to true.
+	Enforce *bool `json:"enforce,omitempty"`
+}
+
+// enforceExclusions reports whether health-based exclusions are applied
+func (f FailureHandlingConfig) enforceExclusions() bool {
+	return f.Enforce == nil || *f.Enforce
+}
+
+// ScoringConfig holds the weighted health scoring configuration. When enabled,
+// reachable nodes within the height threshold are scored from 0 to 1 by
+// combining height lag, latency, error rate, and peer count, and nodes below
+// the cutoff are excluded.
+type ScoringConfig struct {
+	Enabled         bool     `json:"enabled,omitempty"`
+	HeightWeight    float64  `json:"height_weight,omitempty"`
+	LatencyWeight   float64  `json:"latency_weight,omitempty"`
+	ErrorRateWeight float64  `json:"error_rate_weight,omitempty"`
+	PeerWeight      float64  `json:"peer_weight,omitempty"`
+	Cutoff          *float64 `json:"cutoff,omitempty"`      // nil uses the default; 0 never excludes by score
+	MaxLatency      string   `json:"max_latency,omitempty"` // latency that scores 0 (defaults to timeout)
+	MinPeers        int      `json:"min_peers,omitempty"`   // peer count that scores 1
+}
+
+// TrafficSplitConfig splits traffic between node groups that share a metadata
+// label, e.g. 80% to provider=hetzner and 20% to provider=latitude. Weights
+// can be changed at runtime through the admin API under Name.
+type TrafficSplitConfig struct {
+	Name    string         `json:"name,omitempty"`    // admin API key; defaults to Label
+	Label   string         `json:"label,omitempty"`   // metadata key grouping the nodes
+	Weights map[string]int `json:"weights,omitempty"` // share of traffic per label value
+}
+
+// CostConfig enables cost-aware routing. Nodes carry their price in the
+// cost_per_million metadata value (USD per million requests; missing means
+// free), and each request goes to the cheapest healthy nodes. Once the paid
+// nodes have spent MonthlyBudget in the current calendar month (UTC), they
+// stop receiving traffic until the next month.
+type CostConfig struct {
+	Enabled       bool    `json:"enabled,omitempty"`
+	Name          string  `json:"name,omitempty"`           // budget key shared by pools; defaults to the chain
+	MonthlyBudget float64 `json:"monthly_budget,omitempty"` // USD; 0 means no budget
+}
+
+// DockerDiscoveryConfig adds nodes for the Docker containers, or Swarm
+// services, labelled blockchain_health.chain, using their published ports.
+// The node list is refreshed from the Docker API every Refresh.
+type DockerDiscoveryConfig struct {
+	Enabled  bool   `json:"enabled,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"` // Docker API; defaults to unix:///var/run/docker.sock
+	Chain    string `json:"chain,omitempty"`    // only discover this blockchain_health.chain value
+	Host     string `json:"host,omitempty"`     // address for published ports; defaults to the bind IP or 127.0.0.1
+	Swarm    bool   `json:"swarm,omitempty"`    // discover Swarm services instead of containers
+	Refresh  string `json:"refresh,omitempty"`  // defaults to 30s
+}
+
+// InventoryConfig lets an inventory system push nodes for this pool through
+// the blockchain_inventory handler. Pools using the same name receive the
+// same nodes.
+type InventoryConfig struct {
+	Enabled bool   `json:"enabled,omitempty"`
+	Name    string `json:"name,omitempty"`  // pool name in the webhook path; defaults to the chain
+	Token   string `json:"token,omitempty"` // bearer token the inventory system must send
+}
+
+// PoolStateConfig sets when the pool counts as degraded or critical, by the
+// share of its nodes that are healthy. A pool without healthy nodes is down.
+type PoolStateConfig struct {
+	Name          string  `json:"name,omitempty"`           // matcher key shared by pools; defaults to the chain
+	DegradedBelow float64 `json:"degraded_below,omitempty"` // defaults to 0.75
+	CriticalBelow float64 `json:"critical_below,omitempty"` // defaults to 0.5
+}
+
+// DNSFailoverConfig withdraws this gateway from DNS or anycast rotation while
+// the pool stays degraded, and re-adds it once the pool has been healthy for
+// as long, by sending templated requests to a provider API
+type DNSFailoverConfig struct {
+	Enabled    bool               `json:"enabled,omitempty"`
+	WithdrawOn PoolState          `json:"withdraw_on,omitempty"` // pool state at or below which to withdraw; defaults to critical
+	After      string             `json:"after,omitempty"`       // how long a state must last before acting; defaults to 1m
+	Timeout    string             `json:"timeout,omitempty"`     // defaults to 10s
+	Headers    map[string]string  `json:"headers,omitempty"`     // sent with both requests
+	Withdraw   DNSFailoverRequest `json:"withdraw,omitempty"`
+	Restore    DNSFailoverRequest `json:"restore,omitempty"`
+}
+
+// DNSFailoverRequest is a provider API call. The URL, body and headers may
+// use {pool.name}, {pool.state} and Caddy's global placeholders such as
+// {env.CF_API_TOKEN}.
+type DNSFailoverRequest struct {
+	Method string `json:"method,omitempty"` // defaults to POST
+	URL    string `json:"url,omitempty"`
+	Body   string `json:"body,omitempty"`
+}
+
+// StatusPageConfig publishes the state of the pool as the status of a
+// component on a status page provider
+type StatusPageConfig struct {
+	Provider  string `json:"provider,omitempty"`  // statuspage, instatus or cachet
+	URL       string `json:"url,omitempty"`       // API base URL; defaults to the provider's public API, required for cachet
+	Page      string `json:"page,omitempty"`      // page ID; not used by cachet
+	Component string `json:"component,omitempty"` // component ID
+	APIKey    string `json:"api_key,omitempty"`
+	After     string `json:"after,omitempty"`   // how long a status must last before it is sent; defaults to 1m
+	Timeout   string `json:"timeout,omitempty"` // defaults to 10s
+}
+
+// AnycastSignalConfig writes the readiness of the chain to File after every
+// health check, for BGP health scripts such as bird or exabgp to withdraw
+// anycast routes while the gateway cannot serve the chain
+type AnycastSignalConfig struct {
+	File       string    `json:"file,omitempty"`
+	WithdrawOn PoolState `json:"withdraw_on,omitempty"` // pool state at or below which the chain is down; defaults to down
+}
+
+// ReportConfig writes a health report of the pool's nodes every day or
+// week, for provider scorecards and renewal decisions: their uptime, lag
+// behind the pool, incidents and circuit breaker trips. Reports are written
+// to Directory, POSTed to Webhook, or both.
+type ReportConfig struct {
+	Enabled   bool              `json:"enabled,omitempty"`
+	Name      string            `json:"name,omitempty"`      // report shared by pools; defaults to the chain
+	Period    string            `json:"period,omitempty"`    // daily or weekly, in UTC; defaults to daily
+	Format    string            `json:"format,omitempty"`    // json or markdown; defaults to json
+	Directory string            `json:"directory,omitempty"` // written as <name>-<period start date>.json or .md
+	Webhook   string            `json:"webhook,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"` // sent with webhook posts
+	Timeout   string            `json:"timeout,omitempty"` // webhook timeout; defaults to 10s
+}
+
+// RequestSigningConfig signs health checks and proxied requests for node
+// gateways that reject unsigned traffic, with an HMAC of the request or a
+// short-lived JWT
+type RequestSigningConfig struct {
+	Method   string `json:"method,omitempty"`   // hmac or jwt
+	Secret   string `json:"secret,omitempty"`   // placeholders such as {env.NODE_SECRET} are replaced
+	Header   string `json:"header,omitempty"`   // defaults to X-Signature for hmac and Authorization for jwt
+	Issuer   string `json:"issuer,omitempty"`   // jwt iss claim
+	Audience string `json:"audience,omitempty"` // jwt aud claim
+	TTL      string `json:"ttl,omitempty"`      // jwt lifetime; defaults to 1m
+}
+
+// ChaosConfig lets faults be injected into the health checks of the pool's
+// nodes through the admin API, to rehearse failover in staging. Pools using
+// the same name share their faults.
+type ChaosConfig struct {
+	Enabled bool   `json:"enabled,omitempty"`
+	Name    string `json:"name,omitempty"` // admin API pool name; defaults to the pool state name
+}
+
+// AccountAffinityConfig pins each EVM account to one node while it is
+// active, so the nonce it reads and the transactions it sends hit the same
+// mempool. Accounts are read from eth_getTransactionCount,
+// eth_sendTransaction and eth_sendRawTransaction calls.
+type AccountAffinityConfig struct {
+	Enabled bool   `json:"enabled,omitempty"`
+	Window  string `json:"window,omitempty"` // how long an idle account stays pinned; defaults to 60s
+}
+
+// MempoolDivergenceConfig samples the pending transactions of the EVM nodes
+// every Interval and flags nodes whose mempool keeps diverging from the rest
+// of the pool, such as nodes with broken transaction gossip.
+type MempoolDivergenceConfig struct {
+	Enabled      bool    `json:"enabled,omitempty"`
+	Interval     string  `json:"interval,omitempty"`      // defaults to 30s
+	Threshold    float64 `json:"threshold,omitempty"`     // divergence score (0-1) counting as divergent; defaults to 0.5
+	FlagAfter    int     `json:"flag_after,omitempty"`    // divergent samples in a row before a node is flagged; defaults to 3
+	WeightFactor float64 `json:"weight_factor,omitempty"` // scales a flagged node's weight; 0 only flags it
+}
+
+// SLOConfig tracks availability and latency objectives per node and per
+// chain over a rolling Window of health checks. A node burning its error
+// budget at least BurnRate times as fast as the objectives allow has its
+// weight scaled by WeightFactor.
+type SLOConfig struct {
+	Enabled       bool    `json:"enabled,omitempty"`
+	Availability  float64 `json:"availability,omitempty"`   // share (0-1) of checks that must succeed; defaults to 0.999
+	Latency       string  `json:"latency,omitempty"`        // defaults to 300ms
+	LatencyTarget float64 `json:"latency_target,omitempty"` // share (0-1) of successful checks that must be within Latency; defaults to 0.95
+	Window        string  `json:"window,omitempty"`         // defaults to 1h
+	BurnRate      float64 `json:"burn_rate,omitempty"`      // defaults to 2
+	WeightFactor  float64 `json:"weight_factor,omitempty"`  // scales a burning node's weight; 0 only reports
+}
+
+// ValidatorModeConfig tunes a Beacon pool for validator clients, for which a
+// missed attestation costs more than an unbalanced load. Every request needs
+// a node that is fully synced and finalizing, goes to the fastest of them,
+// and can be pinned to a named node with PinHeader.
+type ValidatorModeConfig struct {
+	Enabled          bool   `json:"enabled,omitempty"`
+	PinHeader        string `json:"pin_header,omitempty"`        // defaults to X-Beacon-Node
+	LatencyTolerance string `json:"latency_tolerance,omitempty"` // nodes this much slower than the fastest share requests
+}
+
+// TieringConfig splits the pool into hot and cold sub-pools. Requests are
+// classified by the oldest block they read: within HotBlocks of the head they
+// are hot, deeper history is cold. Nodes join a sub-pool with their tier
+// metadata (hot or cold; nodes without one serve both), may weigh in
+// differently per tier with hot_weight and cold_weight metadata, and are held
+// to the tier's height threshold.
+type TieringConfig struct {
+	Enabled   bool       `json:"enabled,omitempty"`
+	HotBlocks int        `json:"hot_blocks,omitempty"` // defaults to 128
+	Hot       TierConfig `json:"hot,omitempty"`
+	Cold      TierConfig `json:"cold,omitempty"`
+}
+
+// TierConfig holds the health settings of a tier's sub-pool
+type TierConfig struct {
+	// HeightThreshold replaces the pool's height threshold for requests of
+	// the tier; 0 keeps the pool's
+	HeightThreshold int `json:"height_threshold,omitempty"`
+}
+
+// FlapQuarantineConfig takes nodes that flap out of the pool. A node turning
+// healthy or unhealthy more than MaxTransitions times within Window is
+// quarantined for Cooldown, so the pool doesn't oscillate with it.
+type FlapQuarantineConfig struct {
+	Enabled        bool   `json:"enabled,omitempty"`
+	MaxTransitions int    `json:"max_transitions,omitempty"` // defaults to 5
+	Window         string `json:"window,omitempty"`          // defaults to 10m
+	Cooldown       string `json:"cooldown,omitempty"`        // defaults to 30m
+}
+
+// PrewarmConfig keeps warm connections from the reverse proxy's transport
+// to every healthy node with keep-alive pings, so the first requests to a
+// node after a failover skip the TCP and TLS handshakes
+type PrewarmConfig struct {
+	Enabled     bool   `json:"enabled,omitempty"`
+	Connections int    `json:"connections,omitempty"` // warm connections per node; defaults to 2
+	Interval    string `json:"interval,omitempty"`    // time between pings; defaults to 30s
+}
+
+// StrictValidationConfig rejects a pool at provision for mistakes that
+// otherwise only degrade it at runtime: metadata keys nothing reads, node
+// names used twice, node types that contradict a known chain and, with
+// Resolve, node hosts that do not resolve.
+type StrictValidationConfig struct {
+	Enabled      bool     `json:"enabled,omitempty"`
+	Resolve      bool     `json:"resolve,omitempty"`       // look up the host of every node URL
+	MetadataKeys []string `json:"metadata_keys,omitempty"` // custom metadata keys to allow, such as region
 }
 
 // MonitoringConfig holds monitoring configuration
 type MonitoringConfig struct {
 	MetricsEnabled bool   `json:"metrics_enabled"`
-	LogLevel       string `json:"log_level"`
-	HealthEndpoint string `json:"health_endpoint"`
+	LogLevel       string `json:"log_level"`       // empty keeps the Caddy logger level
+	HealthEndpoint string `json:"health_endpoint"` // defaults to /health
+
+	// ShutdownDrain is how long the health endpoint reports shutting_down
+	// with 503 before the servers stop when Caddy exits, so external load
+	// balancers move traffic off this gateway first
+	ShutdownDrain string `json:"shutdown_drain,omitempty"`
+
+	// HealthCacheTTL is how long a health endpoint response is reused for
+	// other requests (defaults to 1s, 0 disables)
+	HealthCacheTTL string `json:"health_cache_ttl,omitempty"`
+
+	// ComponentLogLevels overrides LogLevel per component ("upstream", "checker", "handlers")
+	ComponentLogLevels map[string]string `json:"component_log_levels,omitempty"`
+
+	// ClientDiversityWarning logs a warning while every node of the pool runs
+	// the same client, a correlated-failure risk
+	ClientDiversityWarning bool `json:"client_diversity_warning,omitempty"`
 }
 
-// EnvironmentConfig holds environment variable based configuration
+// EnvironmentConfig holds environment variable based configuration. Each
+// field is a server list separated by spaces, commas or newlines, usually
+// read from an environment variable: {$COSMOS_RPC_SERVERS} in a Caddyfile,
+// or {env.COSMOS_RPC_SERVERS} in JSON, which is replaced at provisioning.
 type EnvironmentConfig struct {
-	RPCServers       string `json:"rpc_servers,omitempty"`
-	APIServers       string `json:"api_servers,omitempty"`
-	WebSocketServers string `json:"websocket_servers,omitempty"`
-	EVMServers       string `json:"evm_servers,omitempty"`
-	EVMWSServers     string `json:"evm_ws_servers,omitempty"`
-	Servers          string `json:"servers,omitempty"` // Generic server list
+	RPCServers       string `json:"rpc_servers,omitempty"`       // Cosmos RPC servers
+	APIServers       string `json:"api_servers,omitempty"`       // Cosmos REST API servers
+	WebSocketServers string `json:"websocket_servers,omitempty"` // Cosmos WebSocket servers
+	EVMServers       string `json:"evm_servers,omitempty"`       // EVM JSON-RPC servers
+	EVMWSServers     string `json:"evm_ws_servers,omitempty"`    // EVM WebSocket servers, matched to the EVM servers by host
+	Servers          string `json:"servers,omitempty"`           // Generic server list
 }
 
 // ChainConfig holds chain-specific configuration
 type ChainConfig struct {
-	ChainType           string `json:"chain_type,omitempty"`             // Specific chain identifier for grouping ("ethereum", "base", "akash", etc.)
-	NodeType            string `json:"node_type,omitempty"`              // Protocol type for health checker selection ("cosmos", "evm")
-	ChainPreset         string `json:"chain_preset,omitempty"`           // "cosmos-hub", "ethereum", "althea"
-	AutoDiscoverFromEnv string `json:"auto_discover_from_env,omitempty"` // "COSMOS" looks for COSMOS_*_SERVERS
-	ServiceType         string `json:"service_type,omitempty"`           // "rpc", "api", "websocket"
+	ChainType           string      `json:"chain_type,omitempty"`             // Specific chain identifier for grouping ("ethereum", "base", "akash", etc.)
+	NodeType            string      `json:"node_type,omitempty"`              // Protocol type for health checker selection ("cosmos", "evm")
+	ChainPreset         string      `json:"chain_preset,omitempty"`           // "cosmos-hub", "ethereum", "althea"
+	AutoDiscoverFromEnv string      `json:"auto_discover_from_env,omitempty"` // "COSMOS" looks for COSMOS_*_SERVERS
+	ServiceType         ServiceType `json:"service_type,omitempty"`           // "rpc", "api", "websocket"
+	Network             string      `json:"network,omitempty"`                // "mainnet", "testnet"; keeps pools of one chain apart
 }
 
 // LegacyConfig holds backward compatibility settings
 type LegacyConfig struct {
 	LegacyMode       bool   `json:"legacy_mode,omitempty"`
-	FallbackBehavior string `json:"fallback_behavior,omitempty"` // "disable_health_checks", "fail_startup"
+	FallbackBehavior string `json:"fallback_behavior,omitempty"` // "disable_health_checks" (default), "fail_startup"
 	RequiredEnvVars  string `json:"required_env_vars,omitempty"`
 	OptionalEnvVars  string `json:"optional_env_vars,omitempty"`
 }
@@ -111,11 +531,51 @@ type Config struct {
 	Legacy      LegacyConfig      `json:"legacy,omitempty"`
 
 	// Configuration sections
-	HealthCheck     HealthCheckConfig     `json:"health_check"`
-	BlockValidation BlockValidationConfig `json:"block_validation"`
-	Performance     PerformanceConfig     `json:"performance"`
-	FailureHandling FailureHandlingConfig `json:"failure_handling"`
-	Monitoring      MonitoringConfig      `json:"monitoring"`
+	HealthCheck       HealthCheckConfig       `json:"health_check"`
+	BlockValidation   BlockValidationConfig   `json:"block_validation"`
+	Performance       PerformanceConfig       `json:"performance"`
+	FailureHandling   FailureHandlingConfig   `json:"failure_handling"`
+	Scoring           ScoringConfig           `json:"scoring,omitempty"`
+	TrafficSplit      TrafficSplitConfig      `json:"traffic_split,omitempty"`
+	Cost              CostConfig              `json:"cost,omitempty"`
+	DockerDiscovery   DockerDiscoveryConfig   `json:"docker_discovery,omitempty"`
+	Inventory         InventoryConfig         `json:"inventory,omitempty"`
+	PoolState         PoolStateConfig         `json:"pool_state,omitempty"`
+	DNSFailover       DNSFailoverConfig       `json:"dns_failover,omitempty"`
+	AnycastSignal     AnycastSignalConfig     `json:"anycast_signal,omitempty"`
+	StatusPage        StatusPageConfig        `json:"status_page,omitempty"`
+	Report            ReportConfig            `json:"report,omitempty"`
+	RequestSigning    RequestSigningConfig    `json:"request_signing,omitempty"`
+	Chaos             ChaosConfig             `json:"chaos,omitempty"`
+	AccountAffinity   AccountAffinityConfig   `json:"account_affinity,omitempty"`
+	MempoolDivergence MempoolDivergenceConfig `json:"mempool_divergence,omitempty"`
+	SLO               SLOConfig               `json:"slo,omitempty"`
+	ValidatorMode     ValidatorModeConfig     `json:"validator_mode,omitempty"`
+	Tiering           TieringConfig           `json:"tiering,omitempty"`
+	FlapQuarantine    FlapQuarantineConfig    `json:"flap_quarantine,omitempty"`
+	Monitoring        MonitoringConfig        `json:"monitoring"`
+
+	// The nodes once discovery replaced them at runtime. Each list is an
+	// immutable snapshot, so requests read it without locking.
+	nodes atomic.Pointer[[]NodeConfig]
+}
+
+// nodeList returns the current nodes. Discovery replaces the list at runtime,
+// so read it through here rather than through the Nodes field once the module
+// is provisioned.
+func (c *Config) nodeList() []NodeConfig {
+	if nodes := c.nodes.Load(); nodes != nil {
+		return *nodes
+	}
+	return c.Nodes
+}
+
+// setNodes replaces the nodes. The old list is left untouched, so callers
+// still holding it keep a consistent view; the new list must not be modified
+// afterwards.
+func (c *Config) setNodes(nodes []NodeConfig) {
+	memoizeKeys(nodes)
+	c.nodes.Store(&nodes)
 }
 
 // NodeHealth represents the health status of a node
@@ -129,12 +589,69 @@ type NodeHealth struct {
 	LastCheck    time.Time     `json:"last_check"`
 	ErrorCount   int           `json:"error_count"`
 	LastError    string        `json:"last_error,omitempty"`
+	PeerCount    *uint64       `json:"peer_count,omitempty"`
+
+	// Throttled is set when the node rate limited the health check (HTTP 429
+	// or a JSON-RPC rate limit error). Throttled nodes with a known height stay
+	// in the pool at a reduced weight for a few probes instead of being ejected,
+	// but do not count toward MinHealthyNodes.
+	Throttled bool `json:"throttled,omitempty"`
+
+	// Pruning horizon: the earliest block the node still serves (0 if unknown).
+	// Pruned is set when the node reports a horizon above block 1.
+	EarliestBlockHeight uint64 `json:"earliest_block_height,omitempty"`
+	Pruned              bool   `json:"pruned,omitempty"`
+
+	// MaxLogsRange is the widest eth_getLogs block range the node accepts,
+	// probed or from its max_logs_range metadata (0 if unlimited or unknown)
+	MaxLogsRange uint64 `json:"max_logs_range,omitempty"`
 
 	// Validation results
 	HeightValid            bool  `json:"height_valid"`
 	ExternalReferenceValid bool  `json:"external_reference_valid"`
 	BlocksBehindPool       int64 `json:"blocks_behind_pool"`
 	BlocksBehindExternal   int64 `json:"blocks_behind_external"`
+
+	// ExternalLagging is set when the node trails an external reference by
+	// more than the threshold; ExternalLagAction decides what it costs
+	ExternalLagging bool `json:"external_lagging,omitempty"`
+
+	// Client is the detected client implementation of EVM and Beacon nodes
+	Client string `json:"client,omitempty"`
+
+	// RESTBasePath is the base path of the REST status endpoints of Cosmos
+	// API nodes, detected or configured
+	RESTBasePath string `json:"rest_base_path,omitempty"`
+
+	// Beacon nodes that are optimistically synced, have their execution
+	// client offline or a finalized epoch behind the pool stay healthy for
+	// reads but do not serve validator requests
+	Optimistic     bool   `json:"optimistic,omitempty"`
+	ELOffline      bool   `json:"el_offline,omitempty"`
+	FinalizedEpoch uint64 `json:"finalized_epoch,omitempty"`
+	FinalityStale  bool   `json:"finality_stale,omitempty"`
+
+	// EventStreamBroken is set on Beacon nodes serving event consumers whose
+	// head event stream failed its probe, so event subscriptions avoid them
+	EventStreamBroken bool `json:"event_stream_broken,omitempty"`
+
+	// EVM nodes tracked with track_finality: the finalized block, and
+	// whether it trails the pool's so finalized requests avoid the node
+	FinalizedHeight  uint64 `json:"finalized_height,omitempty"`
+	FinalizedLagging bool   `json:"finalized_lagging,omitempty"`
+
+	// Score is the weighted health score (0-1) when scoring is enabled
+	Score float64 `json:"score,omitempty"`
+
+	// Mempool divergence from the rest of the pool (0-1), and whether the
+	// node is flagged for diverging persistently
+	MempoolDivergence float64 `json:"mempool_divergence,omitempty"`
+	MempoolDivergent  bool    `json:"mempool_divergent,omitempty"`
+
+	// SLOBurnRate is the faster of the node's availability and latency
+	// error budget burn rates, and SLOBurning whether it reached burn_rate
+	SLOBurnRate float64 `json:"slo_burn_rate,omitempty"`
+	SLOBurning  bool    `json:"slo_burning,omitempty"`
 }
 
 // CircuitState represents the state of a circuit breaker
@@ -150,6 +667,8 @@ const (
 type CircuitBreaker struct {
 	failureThreshold int
 	failureCount     int
+	failureRatio     float64 // opens on windowed error rate when > 0
+	minSamples       int
 	lastFailureTime  time.Time
 	state            CircuitState
 	mutex            sync.RWMutex
@@ -161,24 +680,75 @@ type CacheEntry struct {
 	ExpiresAt time.Time
 }
 
-// HealthCache provides TTL-based caching for health check results
+// HealthCache provides TTL-based caching for health check results. Requests
+// read the cache far more often than health checks write it, so reads load an
+// immutable snapshot of the entries without locking and writes replace it.
 type HealthCache struct {
-	cache    map[string]*CacheEntry
-	mutex    sync.RWMutex
+	entries  atomic.Pointer[map[string]*CacheEntry]
+	mutex    sync.Mutex // serializes writers
 	duration time.Duration
+
+	// Lookups that found a fresh result, and those that did not
+	hits   atomic.Uint64
+	misses atomic.Uint64
 }
 
 // Metrics holds prometheus metrics for the module
 type Metrics struct {
-	totalChecks       prometheus.Counter
-	healthyNodes      prometheus.Gauge
-	unhealthyNodes    prometheus.Gauge
-	checkDuration     prometheus.Histogram
-	blockHeightGauge  *prometheus.GaugeVec
-	errorCount        *prometheus.CounterVec
-	configuredNodes   prometheus.Gauge
-	upstreamsIncluded *prometheus.CounterVec
-	upstreamsExcluded *prometheus.CounterVec
+	totalChecks          prometheus.Counter
+	healthyNodes         prometheus.Gauge
+	unhealthyNodes       prometheus.Gauge
+	healthyCandidates    prometheus.Gauge
+	unhealthyCandidates  prometheus.Gauge
+	checkDuration        prometheus.Histogram
+	blockHeightGauge     *prometheus.GaugeVec
+	errorCount           *prometheus.CounterVec
+	configuredNodes      prometheus.Gauge
+	upstreamsIncluded    *prometheus.CounterVec
+	upstreamsExcluded    *prometheus.CounterVec
+	dryRunExclusions     *prometheus.CounterVec
+	nodeScore            *prometheus.GaugeVec
+	nodeErrorRate        *prometheus.GaugeVec
+	throttledChecks      *prometheus.CounterVec
+	nodeThrottled        *prometheus.GaugeVec
+	healthWaits          *prometheus.CounterVec
+	wsSessionsDrained    *prometheus.CounterVec
+	wsConnections        *prometheus.GaugeVec
+	upstreamErrors       *prometheus.CounterVec
+	trafficSplitRequests *prometheus.CounterVec
+	paidRequests         *prometheus.CounterVec
+	paidSpend            *prometheus.GaugeVec
+	checksSkipped        prometheus.Counter
+	nodeInfo             *prometheus.GaugeVec
+	poolState            *prometheus.GaugeVec
+	mempoolDivergence    *prometheus.GaugeVec
+	mempoolDivergent     *prometheus.GaugeVec
+	poolClients          *prometheus.GaugeVec
+	poolClientDominance  *prometheus.GaugeVec
+	dnsWithdrawn         *prometheus.GaugeVec
+	statusPageUpdates    *prometheus.CounterVec
+	blockTime            *prometheus.GaugeVec
+	prewarmPings         *prometheus.CounterVec
+	prewarmHandshake     *prometheus.HistogramVec
+	nodeSLOCompliance    *prometheus.GaugeVec
+	nodeSLOBurnRate      *prometheus.GaugeVec
+	chainSLOCompliance   *prometheus.GaugeVec
+	chainSLOBurnRate     *prometheus.GaugeVec
+	sweepDuration        *prometheus.HistogramVec
+	cacheHitRatio        *prometheus.GaugeVec
+	goroutines           prometheus.Gauge
+	probeQueueDepth      *prometheus.GaugeVec
+	oldestResultAge      *prometheus.GaugeVec
+	checkerStalled       *prometheus.GaugeVec
+	panicsRecovered      *prometheus.CounterVec
+	nodeQuarantined      *prometheus.GaugeVec
+	referencesDiscarded  *prometheus.CounterVec
+	blocksBehindPool     *prometheus.GaugeVec
+	blocksBehindExternal *prometheus.GaugeVec
+	heightValid          *prometheus.GaugeVec
+	leaderHeight         *prometheus.GaugeVec
+	networkHeight        *prometheus.GaugeVec
+	referenceHeight      *prometheus.GaugeVec
 }
 
 // ProtocolHandler defines the interface for protocol-specific health checks
@@ -187,12 +757,37 @@ type ProtocolHandler interface {
 	GetBlockHeight(ctx context.Context, url string) (uint64, error)
 }
 
+// PeerCounter is implemented by protocol handlers that can report how many
+// peers a node is connected to
+type PeerCounter interface {
+	GetPeerCount(ctx context.Context, url string) (uint64, error)
+}
+
+// EarliestBlockProber is implemented by protocol handlers that can find the
+// earliest block a node still serves
+type EarliestBlockProber interface {
+	GetEarliestBlock(ctx context.Context, url string, latest uint64) (uint64, error)
+}
+
+// LogsRangeProber is implemented by protocol handlers that can find the
+// widest eth_getLogs block range a node accepts
+type LogsRangeProber interface {
+	GetLogsRange(ctx context.Context, url string, latest uint64) (uint64, error)
+}
+
+// FinalityProber is implemented by protocol handlers that can report the
+// finalized block of a node
+type FinalityProber interface {
+	GetFinalizedHeight(ctx context.Context, url string) (uint64, error)
+}
+
 // HealthChecker manages health checking for all nodes
 type HealthChecker struct {
 	config        *Config
 	cosmosHandler ProtocolHandler
 	evmHandler    ProtocolHandler
 	beaconHandler ProtocolHandler
+	explorers     *explorerClient
 	cache         *HealthCache
 	metrics       *Metrics
 	logger        *zap.Logger
@@ -200,10 +795,120 @@ type HealthChecker struct {
 	// Circuit breakers per node
 	circuitBreakers map[string]*CircuitBreaker
 	mutex           sync.RWMutex
+
+	// Per-node sliding windows of health check outcomes
+	errorWindows map[string]*errorRateWindow
+
+	// Per-node last distinct health check errors
+	errorSamples map[string]*errorSamples
+
+	// Per-node health transitions and quarantine
+	quarantines map[string]*quarantineState
+
+	// Bumped whenever a node enters or leaves quarantine, so the request
+	// path knows when its quarantine view is stale
+	quarantineVersion atomic.Uint64
+
+	// Per-node earliest available block, probed in the background
+	earliestBlocks map[string]*earliestBlockState
+
+	// Per-node eth_getLogs block range limit, probed in the background
+	logsRanges map[string]*logsRangeState
+
+	// Per-node throttled streak and last known good height
+	throttleStates map[string]*throttleState
+
+	// Per-node mempool divergence, sampled in the background
+	mempoolStates map[string]*mempoolState
+
+	// Rolling SLO outcomes per node key and per chain
+	nodeSLOs  map[string]*sloState
+	chainSLOs map[string]*sloState
+
+	// Health of each node at the previous check, to detect transitions
+	lastHealthy map[string]bool
+
+	// Time of the last check of each node counted in the metrics, so results
+	// processed again with other nodes' checks are not counted twice
+	lastCounted map[string]time.Time
+
+	// Last fetched height of each external reference, by name
+	references      map[string]*referenceFetch
+	referencesMutex sync.Mutex
+
+	// Chains already warned about lacking a block time
+	blockTimeWarned sync.Map
+
+	// Per-chain block times measured from the pool leader's height
+	blockTimes      map[string]*blockTimeEstimate
+	blockTimesMutex sync.Mutex
+
+	// Per-chain pool leader and network heights of the last check
+	chainHeights      map[string]ChainHeight
+	chainHeightsMutex sync.Mutex
+
+	// Scheduler, worker and probe goroutines, waited for by Stop
+	background      sync.WaitGroup
+	backgroundMutex sync.Mutex
+
+	// Persistent workers running node checks, and the check in flight per
+	// node so overlapping runs share it
+	jobs          chan *nodeCheck
+	startWorkers  sync.Once
+	inflight      map[string]*nodeCheck
+	inflightMutex sync.Mutex
+	resultsMutex  sync.Mutex
+
+	// Background schedule per node key, kept in sync with the node list
+	scheduleMutex sync.Mutex
+	schedules     map[string]*nodeSchedule
+	interval      time.Duration
+
+	// State of the pool, shared with the pool state matcher
+	state *chainState
+
+	// Emits node and pool transitions to Caddy's events app
+	events *eventEmitter
+
+	// Withdraws the gateway from DNS rotation while the pool is degraded
+	dnsFailover *dnsFailover
+
+	// Publishes the pool state to a status page component
+	statusPage *statusPage
+
+	// Writes the readiness of the chain for BGP health scripts
+	anycastSignal *anycastSignal
+
+	// Collects the checks of the nodes into periodic health reports
+	report *healthReport
+
+	// Faults injected into health checks through the admin API
+	chaos *chaosPool
+
+	// Nodes per detected client, and the client every node runs if only one
+	clientMix    map[string]int
+	singleClient string
+
+	// When the background checker last completed a check (Unix nanoseconds),
+	// and whether the watchdog found it stalled
+	lastTick atomic.Int64
+	stalled  bool
+
+	// Cancelled by Stop to abort background probes on shutdown
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // BlockchainHealthUpstream implements the Caddy UpstreamSource interface
 type BlockchainHealthUpstream struct {
+	// Pool names a pool of the blockchain_health app to route to instead of
+	// configuring nodes here
+	Pool string `json:"pool,omitempty"`
+
+	// Hosts selects a pool of the blockchain_health app by the requested
+	// host, so one wildcard site can serve many chains
+	Hosts []HostPool `json:"hosts,omitempty"`
+
 	// Traditional configuration
 	Nodes              []NodeConfig        `json:"nodes,omitempty"`
 	ExternalReferences []ExternalReference `json:"external_references,omitempty"`
@@ -214,11 +919,31 @@ type BlockchainHealthUpstream struct {
 	Legacy      LegacyConfig      `json:"legacy,omitempty"`
 
 	// Configuration sections
-	HealthCheck     HealthCheckConfig     `json:"health_check,omitempty"`
-	BlockValidation BlockValidationConfig `json:"block_validation,omitempty"`
-	Performance     PerformanceConfig     `json:"performance,omitempty"`
-	FailureHandling FailureHandlingConfig `json:"failure_handling,omitempty"`
-	Monitoring      MonitoringConfig      `json:"monitoring,omitempty"`
+	HealthCheck       HealthCheckConfig       `json:"health_check,omitempty"`
+	BlockValidation   BlockValidationConfig   `json:"block_validation,omitempty"`
+	Performance       PerformanceConfig       `json:"performance,omitempty"`
+	FailureHandling   FailureHandlingConfig   `json:"failure_handling,omitempty"`
+	Scoring           ScoringConfig           `json:"scoring,omitempty"`
+	TrafficSplit      TrafficSplitConfig      `json:"traffic_split,omitempty"`
+	Cost              CostConfig              `json:"cost,omitempty"`
+	DockerDiscovery   DockerDiscoveryConfig   `json:"docker_discovery,omitempty"`
+	Inventory         InventoryConfig         `json:"inventory,omitempty"`
+	PoolState         PoolStateConfig         `json:"pool_state,omitempty"`
+	DNSFailover       DNSFailoverConfig       `json:"dns_failover,omitempty"`
+	AnycastSignal     AnycastSignalConfig     `json:"anycast_signal,omitempty"`
+	StatusPage        StatusPageConfig        `json:"status_page,omitempty"`
+	Report            ReportConfig            `json:"report,omitempty"`
+	RequestSigning    RequestSigningConfig    `json:"request_signing,omitempty"`
+	Chaos             ChaosConfig             `json:"chaos,omitempty"`
+	AccountAffinity   AccountAffinityConfig   `json:"account_affinity,omitempty"`
+	MempoolDivergence MempoolDivergenceConfig `json:"mempool_divergence,omitempty"`
+	SLO               SLOConfig               `json:"slo,omitempty"`
+	ValidatorMode     ValidatorModeConfig     `json:"validator_mode,omitempty"`
+	Tiering           TieringConfig           `json:"tiering,omitempty"`
+	FlapQuarantine    FlapQuarantineConfig    `json:"flap_quarantine,omitempty"`
+	Monitoring        MonitoringConfig        `json:"monitoring,omitempty"`
+	StrictValidation  StrictValidationConfig  `json:"strict_validation,omitempty"`
+	Prewarm           PrewarmConfig           `json:"prewarm,omitempty"`
 
 	// Runtime components
 	config        *Config
@@ -226,8 +951,37 @@ type BlockchainHealthUpstream struct {
 	cache         *HealthCache
 	metrics       *Metrics
 	logger        *zap.Logger
-
-	// Internal state
-	mutex    sync.RWMutex
-	shutdown chan struct{}
+	split         *trafficSplit
+	budget        *costBudget
+	providers     *providerRotation
+	inventory     *inventory
+	affinity      *accountAffinity
+	signer        *requestSigner
+	shared        *BlockchainHealthUpstream
+	hostPools     []hostPool
+
+	// Set while the pool drains before shutdown
+	draining atomic.Bool
+
+	// What requests precompute from the cached node health, and the
+	// upstream lists this source returned for it
+	selection       atomic.Pointer[selectionSnapshot]
+	sharedUpstreams atomic.Pointer[sharedUpstreams]
+
+	// Last health endpoint response, reused for health_cache_ttl
+	healthResponse healthResponseCache
+
+	// Warms connections of the reverse proxy this upstream feeds
+	prewarm *prewarmer
+
+	// Nodes from the config, and from each discovery source, less the nodes
+	// retired by a replacement, by node key
+	staticNodes    []NodeConfig
+	discovered     map[string][]NodeConfig
+	retired        map[string]bool
+	discoveryMutex sync.Mutex
+
+	// Blue/green node replacements, by the name of the node replaced
+	replacements     map[string]*nodeReplacement
+	replacementMutex sync.Mutex
 }
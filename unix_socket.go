@@ -0,0 +1,152 @@
+package blockchain_health
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// unixScheme is the URL scheme of nodes reached over a Unix domain socket,
+// as in unix:///var/run/geth.sock. Probes and the reverse proxy speak HTTP
+// over the socket; raw IPC endpoints, which speak bare JSON-RPC, are refused.
+const unixScheme = "unix"
+
+// rawIPCSuffix is the file extension of raw IPC endpoints such as geth.ipc
+const rawIPCSuffix = ".ipc"
+
+// upstreamDial returns the address the reverse proxy dials for a node URL:
+// host:port, or unix/<path> for a Unix domain socket. It is empty when the
+// URL names neither.
+func upstreamDial(u *url.URL) string {
+	if u.Scheme == unixScheme {
+		if u.Host != "" || u.Path == "" {
+			return ""
+		}
+		socket, _ := splitSocketPath(u.Path)
+		return "unix/" + socket
+	}
+	return u.Host
+}
+
+// nodeDial returns the upstream dial address of a node URL, or "" when the
+// URL is invalid
+func nodeDial(rawURL string) string {
+	dial, _ := parseDial(rawURL)
+	return dial
+}
+
+// parseDial returns the upstream dial address of a node URL, and false when
+// the URL does not parse. Results are memoized since every request resolves
+// every node.
+func parseDial(rawURL string) (string, bool) {
+	if dial, ok := dials.Load(rawURL); ok {
+		return dial.(string), true
+	}
+
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	dial := upstreamDial(parsedURL)
+	dials.Store(rawURL, dial)
+	return dial, true
+}
+
+// dials memoizes the dial addresses of node URLs
+var dials sync.Map // string -> string
+
+// sockets holds the known socket paths: those of the node URLs, registered
+// when the nodes are provisioned, and those found on disk since
+var sockets sync.Map // socket path -> struct{}
+
+// registerSocket resolves the socket of a node's unix URL path once, when
+// the node is provisioned, so dials and probes don't look it up on disk. A
+// path naming no socket yet is taken to be the socket, which the node has
+// not created yet.
+func registerSocket(p string) {
+	socket, _ := splitSocketPath(p)
+	sockets.Store(socket, struct{}{})
+}
+
+// splitSocketPath splits a unix URL path into the socket and the request path
+// after it, so probes can append API paths to a socket URL. The socket is the
+// shortest prefix of the path that is a known socket, else the shortest that
+// is a socket on disk, or the whole path.
+func splitSocketPath(p string) (string, string) {
+	for i := 1; i <= len(p); i++ {
+		if i < len(p) && p[i] != '/' {
+			continue
+		}
+		if _, ok := sockets.Load(p[:i]); ok {
+			return p[:i], requestPath(p[i:])
+		}
+	}
+	for i := 1; i < len(p); i++ {
+		if p[i] != '/' {
+			continue
+		}
+		if info, err := os.Stat(p[:i]); err == nil && info.Mode()&os.ModeSocket != 0 {
+			sockets.Store(p[:i], struct{}{})
+			return p[:i], p[i:]
+		}
+	}
+	return p, "/"
+}
+
+// requestPath returns the request path after a socket path, "/" when there
+// is none
+func requestPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	return p
+}
+
+// isRawIPC reports whether a unix URL path names a raw IPC endpoint, which
+// speaks bare JSON-RPC rather than HTTP
+func isRawIPC(p string) bool {
+	socket, _ := splitSocketPath(p)
+	return strings.HasSuffix(socket, rawIPCSuffix)
+}
+
+// unixRoundTripper sends HTTP requests for unix URLs over the socket. One
+// transport is kept per socket so connections are reused between probes.
+type unixRoundTripper struct {
+	transports sync.Map // socket path -> *http.Transport
+}
+
+// RoundTrip implements http.RoundTripper
+func (u *unixRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	socket, path := splitSocketPath(req.URL.Path)
+
+	transport, ok := u.transports.Load(socket)
+	if !ok {
+		dialer := &net.Dialer{Timeout: 10 * time.Second}
+		transport, _ = u.transports.LoadOrStore(socket, &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+			MaxIdleConnsPerHost: 4,
+			IdleConnTimeout:     90 * time.Second,
+		})
+	}
+
+	// The host is only used for the Host header
+	out := req.Clone(req.Context())
+	out.URL = &url.URL{Scheme: "http", Host: "localhost", Path: path, RawQuery: req.URL.RawQuery}
+	out.Host = "localhost"
+	return transport.(*http.Transport).RoundTrip(out)
+}
+
+// newProbeClient returns the HTTP client of a protocol handler, which also
+// reaches nodes over Unix domain sockets
+func newProbeClient(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.RegisterProtocol(unixScheme, &unixRoundTripper{})
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
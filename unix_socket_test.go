@@ -0,0 +1,143 @@
+package blockchain_health
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+// serveOnSocket serves handler on a Unix domain socket and returns its path
+func serveOnSocket(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	// Socket paths are limited to about 100 bytes, too short for t.TempDir
+	dir, err := os.MkdirTemp("", "bh")
+	if err != nil {
+		t.Fatalf("creating socket dir: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	socket := filepath.Join(dir, "node.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("listening on socket: %v", err)
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+	return socket
+}
+
+func TestUpstreamDial(t *testing.T) {
+	tests := map[string]string{
+		"http://localhost:8545":      "localhost:8545",
+		"unix:///var/run/geth.sock":  "unix//var/run/geth.sock",
+		"unix://host/var/run/x.sock": "",
+		"unix://":                    "",
+	}
+	for rawURL, want := range tests {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatalf("parsing %s: %v", rawURL, err)
+		}
+		if got := upstreamDial(u); got != want {
+			t.Errorf("%s: expected %q, got %q", rawURL, want, got)
+		}
+	}
+}
+
+func TestEVMHandler_UnixSocket(t *testing.T) {
+	server := createVersionedEVMServer(t, "Geth/v1.13.14")
+	server.Close()
+	socket := serveOnSocket(t, server.Config.Handler)
+
+	handler := NewEVMHandler(5*time.Second, zaptest.NewLogger(t))
+	health, err := handler.CheckHealth(context.Background(), NodeConfig{Name: "ipc", URL: "unix://" + socket, Type: NodeTypeEVM})
+	if err != nil {
+		t.Fatalf("CheckHealth failed: %v", err)
+	}
+	if !health.Healthy || health.BlockHeight != 1000 {
+		t.Errorf("expected a healthy node at 1000, got %+v", health)
+	}
+	if health.Client != string(ExecutionClientGeth) {
+		t.Errorf("expected geth, got %q", health.Client)
+	}
+}
+
+func TestBeaconHandler_UnixSocket(t *testing.T) {
+	// Probe paths are appended to the socket path
+	server := createFinalityBeaconServer(t, 3200, 98, false, false)
+	server.Close()
+	socket := serveOnSocket(t, server.Config.Handler)
+
+	handler := NewBeaconHandler(5*time.Second, zaptest.NewLogger(t))
+	health, err := handler.CheckHealth(context.Background(), NodeConfig{Name: "sock", URL: "unix://" + socket, Type: NodeTypeBeacon})
+	if err != nil {
+		t.Fatalf("CheckHealth failed: %v", err)
+	}
+	if !health.Healthy || health.FinalizedEpoch != 98 {
+		t.Errorf("expected a healthy node finalized at epoch 98, got %+v", health)
+	}
+}
+
+func TestGetUpstreams_UnixSocket(t *testing.T) {
+	server := createVersionedEVMServer(t, "Geth/v1.13.14")
+	server.Close()
+	socket := serveOnSocket(t, server.Config.Handler)
+
+	nodes := []NodeConfig{
+		{Name: "ipc", URL: "unix://" + socket, Type: NodeTypeEVM, ChainType: "test-evm", Weight: 1},
+	}
+	if err := (&BlockchainHealthUpstream{Nodes: nodes}).validate(); err != nil {
+		t.Fatalf("expected a valid config, got %v", err)
+	}
+
+	upstream := createTestUpstream(nodes, zaptest.NewLogger(t))
+
+	upstreams, err := upstream.GetUpstreams(httptest.NewRequest(http.MethodPost, "/", nil))
+	if err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+	if len(upstreams) != 1 || upstreams[0].Dial != "unix/"+socket {
+		t.Errorf("expected the socket upstream, got %v", upstreams)
+	}
+
+	nodes[0].URL = "unix://"
+	if err := (&BlockchainHealthUpstream{Nodes: nodes}).validate(); err == nil {
+		t.Error("expected a unix URL without a socket path to be rejected")
+	}
+	nodes[0].URL = "unix:///var/run/geth.ipc"
+	if err := (&BlockchainHealthUpstream{Nodes: nodes}).validate(); err == nil {
+		t.Error("expected a raw IPC endpoint to be rejected")
+	}
+}
+
+func TestSplitSocketPath_Registered(t *testing.T) {
+	// Sockets of provisioned nodes are known without looking them up on
+	// disk, even before the node creates them
+	nodes := []NodeConfig{
+		{Name: "sock", URL: "unix:///nonexistent/bh/beacon.sock", Type: NodeTypeBeacon, ChainType: "beacon", Weight: 1},
+	}
+	if err := (&BlockchainHealthUpstream{Nodes: nodes}).validate(); err != nil {
+		t.Fatalf("expected a valid config, got %v", err)
+	}
+
+	tests := map[string][2]string{
+		"/nonexistent/bh/beacon.sock":                     {"/nonexistent/bh/beacon.sock", "/"},
+		"/nonexistent/bh/beacon.sock/eth/v1/node/syncing": {"/nonexistent/bh/beacon.sock", "/eth/v1/node/syncing"},
+		"/nonexistent/bh/other.sock/eth/v1/node/syncing":  {"/nonexistent/bh/other.sock/eth/v1/node/syncing", "/"},
+	}
+	for p, want := range tests {
+		if socket, path := splitSocketPath(p); socket != want[0] || path != want[1] {
+			t.Errorf("%s: expected %q and %q, got %q and %q", p, want[0], want[1], socket, path)
+		}
+	}
+}
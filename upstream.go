@@ -3,33 +3,87 @@ package blockchain_health
 import (
 	"context"
 	"fmt"
+	"math"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
 	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 // GetUpstreams implements reverseproxy.UpstreamSource
 func (b *BlockchainHealthUpstream) GetUpstreams(r *http.Request) ([]*reverseproxy.Upstream, error) {
+	return b.getUpstreams(r, b)
+}
+
+// getUpstreams selects the upstreams of a request for source, the upstream
+// source of the reverse proxy the request goes through
+func (b *BlockchainHealthUpstream) getUpstreams(r *http.Request, source *BlockchainHealthUpstream) ([]*reverseproxy.Upstream, error) {
+	if b != nil && b.prewarm != nil {
+		b.prewarm.start()
+	}
+	if b != nil && b.shared != nil {
+		return b.shared.getUpstreams(r, source)
+	}
+	if b != nil && b.hostPools != nil {
+		pool, err := b.poolForHost(r)
+		if err != nil {
+			caddyhttp.SetVar(r.Context(), noUpstreamsVar, err)
+			return nil, err
+		}
+		return pool.getUpstreams(r, source)
+	}
+	upstreams, err := b.recoveredSelect(r, source)
+
+	// Let the backpressure and error middlewares tell a deliberate refusal
+	// from saturation, and why it happened
+	if err != nil {
+		caddyhttp.SetVar(r.Context(), noUpstreamsVar, err)
+		caddyhttp.SetVar(r.Context(), chainVar, b.chainName())
+		if b != nil && b.metrics != nil {
+			b.metrics.upstreamErrors.WithLabelValues(upstreamErrorCause(err)).Inc()
+		}
+	} else {
+		upstreams = excludeHedgedNode(r, upstreams)
+		caddyhttp.SetVar(r.Context(), noUpstreamsVar, nil)
+		if b.signer != nil {
+			b.signer.sign(r, time.Now())
+		}
+	}
+	return upstreams, err
+}
+
+// selectUpstreams picks the upstreams for a request from the latest node
+// health. Requests of source picking the same nodes share their upstreams.
+func (b *BlockchainHealthUpstream) selectUpstreams(r *http.Request, source *BlockchainHealthUpstream) ([]*reverseproxy.Upstream, error) {
 	// Defensive: ensure module is provisioned and logger present
 	if b == nil || b.config == nil || b.healthChecker == nil {
-		return nil, fmt.Errorf("blockchain_health upstream not provisioned")
+		return nil, ErrNotProvisioned
 	}
 	if b.logger == nil {
 		b.logger = zap.NewNop()
 	}
 
-	b.mutex.RLock()
-	defer b.mutex.RUnlock()
+	// Working slices are reused across requests
+	scratch := selectionScratchPool.Get().(*selectionScratch)
+	defer scratch.release()
 
 	// Get cached health results to avoid running health checks during request processing
 	// This prevents interference with WebSocket upgrades and improves performance
-	healthResults := b.getCachedHealthResults()
+	var healthResults []*NodeHealth
+	nodes := b.config.nodeList()
+	snap := b.currentSelection()
+	if snap != nil {
+		healthResults, nodes = snap.results, snap.nodes
+	}
 
 	// If no cached results available, fall back to a quick health check
 	if len(healthResults) == 0 {
@@ -52,198 +106,464 @@ func (b *BlockchainHealthUpstream) GetUpstreams(r *http.Request) ([]*reverseprox
 		}
 	}
 
+	// Requests routed to a chain group by path prefix only see its nodes
+	if chain := routedChain(r.Context()); chain != "" {
+		healthResults = b.routeResults(r.Context(), healthResults)
+		if len(healthResults) == 0 {
+			return nil, fmt.Errorf("%w: no nodes of chain %s", ErrNoHealthyUpstreams, chain)
+		}
+	}
+
 	// Detect if this is a WebSocket upgrade request
 	isWebSocketRequest := b.isWebSocketUpgradeRequest(r)
 
-	var upstreams []*reverseproxy.Upstream
+	// GraphQL requests are only routed to nodes serving the GraphQL endpoint
+	isGraphQL := !isWebSocketRequest && isGraphQLRequest(r)
+
+	// Validator duties are only routed to fully verified, finalizing Beacon
+	// nodes; in validator mode every request is
+	validatorRequest := b.config.ValidatorMode.Enabled || isValidatorRequest(r)
+
+	// Event subscriptions avoid Beacon nodes whose event stream is broken
+	eventStreamRequest := isEventStreamRequest(r)
+
+	// Requests for finalized data are checked against finalized lag instead
+	// of head lag
+	finalizedRequest := b.config.BlockValidation.TrackFinality && isFinalizedRequest(r)
+
+	// Log queries are only routed to nodes whose eth_getLogs limit covers
+	// their block range
+	logsRange, logsHead := requestedLogsRange(r, healthResults)
+
+	// Historical queries are only routed to nodes that have not pruned the height
+	requestedHeight := requestedHistoricalHeight(r, healthResults)
+
+	// Backfills are pinned to the backfill nodes, when the pool has any
+	backfillPinned := isBackfillRequest(r.Context()) && hasBackfillNodes(nodes)
+
+	// Hot and cold requests are served by their own sub-pools, each with its
+	// own height threshold
+	tier := b.requestTier(r, healthResults)
+	tierThreshold, tierHasThreshold := b.config.Tiering.threshold(tier)
+
+	// In dry-run mode unhealthy nodes stay in the pool; exclusions are only recorded
+	enforce := b.config.FailureHandling.enforceExclusions()
+	externalLagAction := b.config.BlockValidation.ExternalLagAction
+
+	// Hold the request briefly if no node is healthy right now
+	if enforce {
+		healthResults = b.waitForHealthyNodes(r.Context(), healthResults)
+	}
+
+	// The snapshot describes the results unless they were narrowed down
+	view := snap
+	if !view.aligned(healthResults) {
+		view = nil
+	}
+
+	picks := scratch.picks[:0]
+	selectedInfos := scratch.infos[:0]
 	healthyCount := 0
-	type selectionInfo struct {
-		name        string
-		serviceType string
-		reason      string
-	}
-	var selectedInfos []selectionInfo
-
-	for _, health := range healthResults {
-		if health.Healthy {
-			// Find the corresponding node config for weight and service type
-			weight := 1
-			var nodeConfig *NodeConfig
-			for _, node := range b.config.Nodes {
-				if node.Name == health.Name {
-					weight = node.Weight
-					nodeConfig = &node
-					break
+	prunedCount := 0 // healthy nodes skipped because they pruned the requested height
+	unsafeCount := 0 // healthy Beacon nodes skipped for validator requests
+	lagCount := 0    // healthy EVM nodes skipped for finalized requests
+	rangeCount := 0  // healthy EVM nodes skipped for eth_getLogs ranges past their limit
+	streamCount := 0 // healthy Beacon nodes skipped for event subscriptions
+	var logsLimit uint64
+
+	for i, health := range healthResults {
+		weight := 1
+		serviceType := ""
+		var nodeConfig *NodeConfig
+		if view != nil {
+			nodeConfig, serviceType = view.configs[i], view.serviceTypes[i]
+		} else if nodeConfig = findNode(nodes, health.Name); nodeConfig != nil {
+			serviceType = nodeConfig.Metadata["service_type"]
+		}
+		if nodeConfig != nil {
+			weight = nodeConfig.Weight
+		}
+
+		// Candidate nodes are only observed, never routed to
+		if candidateResult(view, i, nodeConfig) {
+			if ce := b.logger.Check(zapcore.DebugLevel, "Skipping candidate node"); ce != nil {
+				ce.Write(zap.String("node", health.Name), zap.Bool("healthy", health.Healthy))
+			}
+			if b.metrics != nil {
+				b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "candidate").Inc()
+			}
+			continue
+		}
+
+		// Backfills stay off the nodes serving interactive users
+		if backfillPinned && (nodeConfig == nil || !nodeConfig.isBackfill()) {
+			if b.metrics != nil {
+				b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "backfill").Inc()
+			}
+			continue
+		}
+
+		// Each tier only sees its sub-pool, at the node's weight for the tier
+		if tier != "" && nodeConfig != nil {
+			if nodeTier := nodeConfig.tier(); nodeTier != "" && nodeTier != tier {
+				if b.metrics != nil {
+					b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "tier").Inc()
 				}
+				continue
 			}
+			weight = nodeConfig.tierWeight(tier)
+		}
+
+		// Nodes ejected only for trailing the head still serve finalized data
+		healthy := health.Healthy
+		finalizedOnly := finalizedRequest && health.headLagOnly() && health.servesFinalized()
+		if finalizedOnly {
+			healthy = true
+		}
 
-			// Filter nodes based on request type
-			if nodeConfig != nil {
-				serviceType := nodeConfig.Metadata["service_type"]
-
-				// For WebSocket requests, only include WebSocket nodes
-				if isWebSocketRequest {
-					if serviceType != "websocket" {
-						b.logger.Debug("Skipping non-WebSocket node for WebSocket request",
-							zap.String("node", health.Name),
-							zap.String("service_type", serviceType))
-						if b.metrics != nil {
-							b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "filtered_websocket").Inc()
-						}
-						continue
-					}
-				} else {
-					// For HTTP requests, include RPC, API, and nodes without service_type (backward compatibility)
-					// but exclude WebSocket-only nodes
-					if serviceType == "websocket" {
-						b.logger.Debug("Skipping WebSocket node for HTTP request",
-							zap.String("node", health.Name),
-							zap.String("service_type", serviceType))
-						if b.metrics != nil {
-							b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "filtered_http").Inc()
-						}
-						continue
-					}
-					// Allow: "rpc", "api", "evm", "", or any other non-websocket service type
+		// A tier's threshold replaces the pool's for nodes trailing the leader
+		tierOnly := false
+		if tierHasThreshold && !health.Throttled {
+			withinTier := health.BlocksBehindPool <= int64(tierThreshold)
+			if !healthy && withinTier && health.heightLagOnly() {
+				healthy = true
+				tierOnly = true
+			} else if healthy && !withinTier {
+				if ce := b.logger.Check(zapcore.DebugLevel, "Skipping node behind the tier's height threshold"); ce != nil {
+					ce.Write(zap.String("node", health.Name),
+						zap.String("tier", tier),
+						zap.Int64("blocks_behind_pool", health.BlocksBehindPool))
 				}
+				if b.metrics != nil {
+					b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "tier_lag").Inc()
+				}
+				continue
 			}
+		}
 
-			healthyCount++
+		if !healthy && enforce {
+			if b.metrics != nil {
+				b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "unhealthy").Inc()
+			}
+			continue
+		}
 
-			// Determine the correct URL to use for upstream
-			upstreamURL := health.URL
+		// Quarantined nodes sit out their cool-down even while healthy
+		if enforce && b.quarantined(view, i, health) {
+			if ce := b.logger.Check(zapcore.DebugLevel, "Skipping quarantined node"); ce != nil {
+				ce.Write(zap.String("node", health.Name))
+			}
+			if b.metrics != nil {
+				b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "quarantined").Inc()
+			}
+			continue
+		}
 
-			// For WebSocket nodes, use the actual WebSocket URL for proxy
-			if nodeConfig != nil && nodeConfig.Metadata["service_type"] == "websocket" {
-				// health.URL should already be the WebSocket URL for WebSocket nodes
-				b.logger.Debug("Using WebSocket URL for upstream",
-					zap.String("node", health.Name),
-					zap.String("websocket_url", upstreamURL))
+		if health.ExternalLagging && enforce && externalLagAction == ExternalLagExclude {
+			if ce := b.logger.Check(zapcore.DebugLevel, "Skipping node behind the external references"); ce != nil {
+				ce.Write(zap.String("node", health.Name), zap.Int64("blocks_behind_external", health.BlocksBehindExternal))
+			}
+			if b.metrics != nil {
+				b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "external_lag").Inc()
 			}
+			continue
+		}
 
-			// Parse URL for upstream
-			parsedURL, err := url.Parse(upstreamURL)
-			if err != nil {
-				b.logger.Warn("invalid node URL", zap.String("node", health.Name), zap.String("url", upstreamURL))
+		// Filter nodes based on request type
+		if nodeConfig != nil {
+			// For WebSocket requests, only include WebSocket nodes
+			if isWebSocketRequest && serviceType != "websocket" {
+				if ce := b.logger.Check(zapcore.DebugLevel, "Skipping non-WebSocket node for WebSocket request"); ce != nil {
+					ce.Write(zap.String("node", health.Name), zap.String("service_type", serviceType))
+				}
 				if b.metrics != nil {
-					serviceType := ""
-					if nodeConfig != nil {
-						serviceType = nodeConfig.Metadata["service_type"]
-					}
-					b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "invalid_url").Inc()
+					b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "filtered_websocket").Inc()
 				}
 				continue
 			}
-			if parsedURL.Host == "" {
-				b.logger.Warn("parsed URL has empty host; skipping upstream", zap.String("node", health.Name), zap.String("url", upstreamURL))
+			// For GraphQL requests, only include GraphQL nodes
+			if isGraphQL && serviceType != "graphql" {
+				if ce := b.logger.Check(zapcore.DebugLevel, "Skipping non-GraphQL node for GraphQL request"); ce != nil {
+					ce.Write(zap.String("node", health.Name), zap.String("service_type", serviceType))
+				}
 				if b.metrics != nil {
-					serviceType := ""
-					if nodeConfig != nil {
-						serviceType = nodeConfig.Metadata["service_type"]
-					}
-					b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "empty_host").Inc()
+					b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "filtered_graphql").Inc()
 				}
 				continue
 			}
+			// For HTTP requests, include RPC, API, and nodes without service_type (backward compatibility)
+			// but exclude WebSocket-only and GraphQL-only nodes
+			if !isWebSocketRequest && !isGraphQL && (serviceType == "websocket" || serviceType == "graphql") {
+				if ce := b.logger.Check(zapcore.DebugLevel, "Skipping WebSocket node for HTTP request"); ce != nil {
+					ce.Write(zap.String("node", health.Name), zap.String("service_type", serviceType))
+				}
+				if b.metrics != nil {
+					b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "filtered_http").Inc()
+				}
+				continue
+			}
+		}
 
-			upstream := &reverseproxy.Upstream{
-				Dial: parsedURL.Host,
+		if !health.servesHeight(requestedHeight) {
+			if ce := b.logger.Check(zapcore.DebugLevel, "Skipping pruned node for historical request"); ce != nil {
+				ce.Write(zap.String("node", health.Name),
+					zap.Uint64("requested_height", requestedHeight),
+					zap.Uint64("earliest_block_height", health.EarliestBlockHeight))
+			}
+			if b.metrics != nil {
+				b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "pruned").Inc()
 			}
+			if healthy {
+				prunedCount++
+			}
+			continue
+		}
 
-			// Add weight if specified
-			if weight > 1 {
-				upstream.MaxRequests = weight
+		if validatorRequest && !health.validatorSafe() {
+			if ce := b.logger.Check(zapcore.DebugLevel, "Skipping Beacon node unsafe for validator request"); ce != nil {
+				ce.Write(zap.String("node", health.Name),
+					zap.Bool("optimistic", health.Optimistic),
+					zap.Bool("el_offline", health.ELOffline),
+					zap.Bool("finality_stale", health.FinalityStale))
+			}
+			if b.metrics != nil {
+				b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "validator_unsafe").Inc()
 			}
+			if healthy {
+				unsafeCount++
+			}
+			continue
+		}
 
-			upstreams = append(upstreams, upstream)
-			if nodeConfig != nil {
-				selectedInfos = append(selectedInfos, selectionInfo{
-					name:        health.Name,
-					serviceType: nodeConfig.Metadata["service_type"],
-					reason:      "healthy",
-				})
-			} else {
-				selectedInfos = append(selectedInfos, selectionInfo{
-					name:        health.Name,
-					serviceType: "",
-					reason:      "healthy",
-				})
+		if eventStreamRequest && health.EventStreamBroken {
+			if ce := b.logger.Check(zapcore.DebugLevel, "Skipping Beacon node with a broken event stream"); ce != nil {
+				ce.Write(zap.String("node", health.Name))
 			}
-		} else {
-			// Count exclusion for unhealthy node
 			if b.metrics != nil {
-				// Look up service type if available
-				st := ""
-				for _, node := range b.config.Nodes {
-					if node.Name == health.Name {
-						st = node.Metadata["service_type"]
-						break
-					}
-				}
-				b.metrics.upstreamsExcluded.WithLabelValues(health.Name, st, "unhealthy").Inc()
+				b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "event_stream").Inc()
 			}
+			if healthy {
+				streamCount++
+			}
+			continue
 		}
-	}
 
-	// Check minimum healthy nodes requirement
-	if healthyCount < b.config.FailureHandling.MinHealthyNodes {
-		b.logger.Warn("insufficient healthy nodes",
-			zap.Int("healthy", healthyCount),
-			zap.Int("minimum_required", b.config.FailureHandling.MinHealthyNodes))
+		if finalizedRequest && !health.servesFinalized() {
+			if ce := b.logger.Check(zapcore.DebugLevel, "Skipping EVM node lagging finality for finalized request"); ce != nil {
+				ce.Write(zap.String("node", health.Name),
+					zap.Uint64("finalized_height", health.FinalizedHeight))
+			}
+			if b.metrics != nil {
+				b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "finalized_lag").Inc()
+			}
+			if healthy {
+				lagCount++
+			}
+			continue
+		}
 
-		// Only fallback to unhealthy nodes if we have NO healthy nodes at all
-		if healthyCount == 0 {
-			b.logger.Info("no healthy nodes available, falling back to all nodes",
-				zap.Int("total_nodes", len(healthResults)),
-				zap.Int("healthy_nodes", healthyCount))
+		if !health.servesLogsRange(logsRange) {
+			if ce := b.logger.Check(zapcore.DebugLevel, "Skipping node limiting the eth_getLogs range"); ce != nil {
+				ce.Write(zap.String("node", health.Name),
+					zap.Uint64("logs_range", logsRange),
+					zap.Uint64("max_logs_range", health.MaxLogsRange))
+			}
+			if b.metrics != nil {
+				b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "logs_range").Inc()
+			}
+			if healthy {
+				rangeCount++
+				logsLimit = max(logsLimit, health.MaxLogsRange)
+			}
+			continue
+		}
 
-			// Return all nodes (including unhealthy ones) as last resort
-			upstreams = []*reverseproxy.Upstream{}
-			selectedInfos = selectedInfos[:0]
-			for _, health := range healthResults {
-				// Find the corresponding node config for weight
-				weight := 1
-				serviceType := ""
-				for _, node := range b.config.Nodes {
-					if node.Name == health.Name {
-						weight = node.Weight
-						serviceType = node.Metadata["service_type"]
-						break
-					}
-				}
+		// WebSocket nodes are checked and proxied on their WebSocket URL
+		var dial string
+		var valid bool
+		if view != nil {
+			dial, valid = view.dials[i], view.validDials[i]
+		} else {
+			dial, valid = parseDial(health.URL)
+		}
 
-				// Parse URL for upstream
-				parsedURL, err := url.Parse(health.URL)
-				if err != nil {
-					b.logger.Warn("invalid node URL", zap.String("node", health.Name), zap.String("url", health.URL))
-					if b.metrics != nil {
-						b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "invalid_url").Inc()
-					}
-					continue
+		// Nodes failing proxied requests are down until their failures expire
+		if dial != "" && passiveFailures.isDown(dial) {
+			if enforce {
+				if ce := b.logger.Check(zapcore.DebugLevel, "Skipping node marked down by passive health checks"); ce != nil {
+					ce.Write(zap.String("node", health.Name))
 				}
-				if parsedURL.Host == "" {
-					b.logger.Warn("parsed URL has empty host; skipping fallback upstream", zap.String("node", health.Name), zap.String("url", health.URL))
-					if b.metrics != nil {
-						b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "empty_host").Inc()
-					}
-					continue
+				if b.metrics != nil {
+					b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "passive_unhealthy").Inc()
 				}
+				continue
+			}
+			if b.metrics != nil {
+				b.metrics.dryRunExclusions.WithLabelValues(health.Name, serviceType, "passive_unhealthy").Inc()
+			}
+		}
 
-				upstream := &reverseproxy.Upstream{
-					Dial: parsedURL.Host,
-				}
+		reason := "healthy"
+		capped := false
+		if health.Throttled {
+			// Keep rate limited nodes but send them less traffic
+			reason = "throttled"
+			weight = throttledWeight(weight, b.config.FailureHandling.ThrottleWeightFactor)
+			capped = true
+		}
+		if health.MempoolDivergent && b.config.MempoolDivergence.WeightFactor > 0 {
+			// Nodes missing the pool's pending transactions get less traffic
+			if !health.Throttled {
+				reason = "mempool_divergent"
+			}
+			weight = throttledWeight(weight, b.config.MempoolDivergence.WeightFactor)
+			capped = true
+		}
+		if health.ExternalLagging && externalLagAction == ExternalLagDownweight {
+			// Nodes behind the external references get less traffic
+			if reason == "healthy" {
+				reason = "external_lag"
+			}
+			weight = throttledWeight(weight, b.config.BlockValidation.ExternalLagWeightFactor)
+			capped = true
+		}
+		if health.SLOBurning && b.config.SLO.WeightFactor > 0 {
+			// Nodes burning their error budget get less traffic
+			if reason == "healthy" {
+				reason = "slo_burning"
+			}
+			weight = throttledWeight(weight, b.config.SLO.WeightFactor)
+			capped = true
+		}
+		if finalizedOnly {
+			reason = "finalized"
+		}
+		if tierOnly {
+			reason = "tier_threshold"
+		}
+		if healthy {
+			// Throttled nodes are served but do not satisfy min_healthy_nodes
+			if !health.Throttled {
+				healthyCount++
+			}
+		} else {
+			reason = "dry_run"
+			if ce := b.logger.Check(zapcore.DebugLevel, "dry-run: node would be excluded from upstreams"); ce != nil {
+				ce.Write(zap.String("node", health.Name),
+					zap.String("service_type", serviceType),
+					zap.String("last_error", health.LastError))
+			}
+			if b.metrics != nil {
+				b.metrics.dryRunExclusions.WithLabelValues(health.Name, serviceType, "unhealthy").Inc()
+			}
+		}
 
-				// Add weight if specified
-				if weight > 1 {
-					upstream.MaxRequests = weight
-				}
+		if !valid {
+			b.logger.Warn("invalid node URL", zap.String("node", health.Name), zap.String("url", health.URL))
+			if b.metrics != nil {
+				b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "invalid_url").Inc()
+			}
+			continue
+		}
+		if dial == "" {
+			b.logger.Warn("parsed URL has empty host; skipping upstream", zap.String("node", health.Name), zap.String("url", health.URL))
+			if b.metrics != nil {
+				b.metrics.upstreamsExcluded.WithLabelValues(health.Name, serviceType, "empty_host").Inc()
+			}
+			continue
+		}
+
+		// Add weight if specified; down-weighted nodes are always capped
+		pick := upstreamPick{index: i, dial: dial}
+		if weight > 1 || capped {
+			pick.maxRequests = weight
+		}
+		picks = append(picks, pick)
+
+		info := selectionInfo{
+			name:        health.Name,
+			serviceType: serviceType,
+			reason:      reason,
+		}
+		if view != nil && view.included != nil && reason == "healthy" {
+			info.included = view.included[i]
+		}
+		selectedInfos = append(selectedInfos, info)
+	}
+	scratch.picks, scratch.infos = picks, selectedInfos
+	upstreams := source.upstreamsFor(view, picks)
+
+	// Healthy nodes that pruned the requested height are not a pool failure;
+	// falling back to unhealthy nodes would not help the historical query
+	if enforce && healthyCount == 0 && prunedCount > 0 {
+		b.logger.Debug("no healthy node serves the requested height",
+			zap.Uint64("requested_height", requestedHeight),
+			zap.Int("pruned_nodes", prunedCount))
+		return nil, fmt.Errorf("%w: no healthy node serves block height %d", ErrNoHealthyUpstreams, requestedHeight)
+	}
+
+	// Falling back to unhealthy nodes would be no safer for validator duties
+	if enforce && healthyCount == 0 && unsafeCount > 0 {
+		b.logger.Warn("no Beacon node is safe for validator requests",
+			zap.Int("unsafe_nodes", unsafeCount))
+		return nil, fmt.Errorf("%w: no node is fully synced and finalizing for validator requests", ErrNoHealthyUpstreams)
+	}
+
+	// Unhealthy nodes are no more likely to stream events
+	if enforce && healthyCount == 0 && streamCount > 0 {
+		b.logger.Warn("no Beacon node serves the event stream",
+			zap.Int("broken_nodes", streamCount))
+		return nil, fmt.Errorf("%w: no node serves the Beacon event stream", ErrNoHealthyUpstreams)
+	}
 
-				upstreams = append(upstreams, upstream)
-				selectedInfos = append(selectedInfos, selectionInfo{
-					name:        health.Name,
-					serviceType: serviceType,
-					reason:      "fallback_all",
-				})
+	// Falling back to unhealthy nodes could serve data that is not final
+	if enforce && healthyCount == 0 && lagCount > 0 {
+		b.logger.Warn("no EVM node keeps up with finality for finalized requests",
+			zap.Int("lagging_nodes", lagCount))
+		return nil, fmt.Errorf("%w: no node keeps up with the finalized block", ErrNoHealthyUpstreams)
+	}
+
+	// Unhealthy nodes would not accept the range either; blockchain_logs_split
+	// can split the request into ranges a node accepts
+	if enforce && healthyCount == 0 && rangeCount > 0 {
+		b.logger.Debug("no healthy node accepts the eth_getLogs range",
+			zap.Uint64("logs_range", logsRange),
+			zap.Uint64("max_logs_range", logsLimit))
+		caddyhttp.SetVar(r.Context(), logsRangeVar, logsRangeRefusal{limit: logsLimit, head: logsHead})
+		return nil, fmt.Errorf("%w: eth_getLogs range of %d blocks exceeds the limit of every node; query at most %d blocks at a time",
+			ErrNoHealthyUpstreams, logsRange, logsLimit)
+	}
+
+	// Falling back to every node would put the backfill on interactive nodes
+	if enforce && healthyCount == 0 && backfillPinned {
+		b.logger.Warn("no backfill node is healthy")
+		return nil, fmt.Errorf("%w: no backfill node is healthy", ErrNoHealthyUpstreams)
+	}
+
+	// Check minimum healthy nodes requirement
+	if healthyCount+prunedCount+unsafeCount+streamCount+lagCount+rangeCount < b.config.FailureHandling.MinHealthyNodes {
+		if enforce {
+			b.logger.Warn("insufficient healthy nodes",
+				zap.Int("healthy", healthyCount+prunedCount+unsafeCount+streamCount+lagCount+rangeCount),
+				zap.Int("minimum_required", b.config.FailureHandling.MinHealthyNodes))
+		}
+
+		// Only fallback to unhealthy nodes if we have NO healthy nodes at all
+		if !enforce {
+			// Dry-run already returns every node; nothing to fall back to
+			b.logger.Debug("dry-run: fallback would be triggered",
+				zap.Int("healthy_nodes", healthyCount),
+				zap.Int("minimum_required", b.config.FailureHandling.MinHealthyNodes))
+		} else if healthyCount == 0 {
+			b.logger.Info("no healthy nodes available, applying fallback strategy",
+				zap.String("fallback_strategy", b.config.fallbackStrategy()),
+				zap.Int("total_nodes", len(healthResults)),
+				zap.Int("healthy_nodes", healthyCount))
+
+			var err error
+			upstreams, selectedInfos, err = b.fallbackUpstreams(healthResults)
+			if err != nil {
+				return nil, err
 			}
 		} else {
 			// We have some healthy nodes, just log the warning but keep using only healthy nodes
@@ -253,19 +573,68 @@ func (b *BlockchainHealthUpstream) GetUpstreams(r *http.Request) ([]*reverseprox
 		}
 	}
 
-	b.logger.Debug("upstreams selected",
-		zap.Int("total_nodes", len(b.config.Nodes)),
-		zap.Int("healthy_nodes", healthyCount),
-		zap.Int("selected_upstreams", len(upstreams)))
+	if ce := b.logger.Check(zapcore.DebugLevel, "upstreams selected"); ce != nil {
+		ce.Write(zap.Int("total_nodes", len(nodes)),
+			zap.Int("healthy_nodes", healthyCount),
+			zap.Int("selected_upstreams", len(upstreams)))
+	}
 
 	// Never return an empty upstream list; signal error so caller can 502 gracefully
 	if len(upstreams) == 0 {
-		return nil, fmt.Errorf("no available upstreams selected")
+		return nil, fmt.Errorf("%w: no available upstreams selected", ErrNoHealthyUpstreams)
+	}
+
+	// Prefer the cheapest nodes and keep paid nodes within their budget
+	if b.budget != nil {
+		var err error
+		if upstreams, selectedInfos, err = b.applyCostPolicy(upstreams, selectedInfos); err != nil {
+			return nil, err
+		}
+	}
+
+	// Send the request to one node group of the traffic split
+	if b.split != nil {
+		upstreams, selectedInfos = b.applyTrafficSplit(upstreams, selectedInfos)
+	}
+
+	// Keep each account's nonce reads and transactions on one node
+	if b.affinity != nil && !isWebSocketRequest {
+		if account := affinityAccount(r); account != "" {
+			upstreams, selectedInfos = b.applyAccountAffinity(account, upstreams, selectedInfos)
+		}
+	}
+
+	// Send validator clients to their pinned node or the fastest safe nodes
+	if b.config.ValidatorMode.Enabled {
+		upstreams, selectedInfos = b.applyValidatorMode(r, upstreams, selectedInfos, healthResults)
+	}
+
+	// Spread new WebSocket sessions across the least loaded nodes
+	if isWebSocketRequest {
+		upstreams, selectedInfos = b.leastLoadedWebSocketUpstreams(upstreams, selectedInfos)
+	}
+
+	// Let the chain lag middleware flag responses from lagging nodes
+	if lags := laggingUpstreams(healthResults, upstreams, selectedInfos); lags != nil {
+		caddyhttp.SetVar(r.Context(), chainLagVar, lags)
+	} else {
+		caddyhttp.SetVar(r.Context(), chainLagVar, nil)
+	}
+
+	// Let reverse_proxy send each node its own static headers
+	if snap != nil && !snap.headers {
+		setUpstreamHeaders(r, nil)
+	} else {
+		setUpstreamHeaders(r, upstreamHeaders(nodes, upstreams, selectedInfos))
 	}
 
 	// Emit metrics for selected upstreams
 	if b.metrics != nil {
 		for _, sel := range selectedInfos {
+			if sel.included != nil {
+				sel.included.Inc()
+				continue
+			}
 			b.metrics.upstreamsIncluded.WithLabelValues(sel.name, sel.serviceType, sel.reason).Inc()
 		}
 	}
@@ -273,70 +642,162 @@ func (b *BlockchainHealthUpstream) GetUpstreams(r *http.Request) ([]*reverseprox
 	return upstreams, nil
 }
 
+// findNodeConfig returns the configuration for the named node, or nil if unknown
+func (b *BlockchainHealthUpstream) findNodeConfig(name string) *NodeConfig {
+	return findNode(b.config.nodeList(), name)
+}
+
+// selectionScratch holds the working slices of an upstream selection. Only
+// slices that do not outlive the selection may be kept here.
+type selectionScratch struct {
+	picks []upstreamPick
+	infos []selectionInfo
+}
+
+// selectionScratchPool reuses working slices across requests
+var selectionScratchPool = sync.Pool{
+	New: func() any { return new(selectionScratch) },
+}
+
+// release drops the references held by the slices and returns them to the pool
+func (s *selectionScratch) release() {
+	clear(s.picks[:cap(s.picks)])
+	clear(s.infos[:cap(s.infos)])
+	s.picks, s.infos = s.picks[:0], s.infos[:0]
+	selectionScratchPool.Put(s)
+}
+
+// quarantined reports whether the node of the i-th result is quarantined,
+// from the snapshot when it describes the results
+func (b *BlockchainHealthUpstream) quarantined(view *selectionSnapshot, i int, health *NodeHealth) bool {
+	if view != nil {
+		return view.quarantined[i]
+	}
+	return b.healthChecker.isQuarantined(health.Name)
+}
+
+// candidateResult reports whether the node of the i-th result is a
+// candidate, from the snapshot when it describes the results
+func candidateResult(view *selectionSnapshot, i int, node *NodeConfig) bool {
+	if view != nil {
+		return view.candidates[i]
+	}
+	return node != nil && node.isCandidate()
+}
+
+// findNode returns the named node of the list, or nil if it is not in it
+func findNode(nodes []NodeConfig, name string) *NodeConfig {
+	for i := range nodes {
+		if nodes[i].Name == name {
+			return &nodes[i]
+		}
+	}
+	return nil
+}
+
 // getCachedHealthResults retrieves cached health results for all nodes
 // Returns results only if ALL nodes have cached results, otherwise returns empty slice
 func (b *BlockchainHealthUpstream) getCachedHealthResults() []*NodeHealth {
+	return b.appendCachedHealthResults(nil)
+}
+
+// appendCachedHealthResults appends the cached health results of all nodes
+// to results, or returns nil unless every node has one
+func (b *BlockchainHealthUpstream) appendCachedHealthResults(results []*NodeHealth) []*NodeHealth {
 	if b.healthChecker == nil || b.config == nil {
 		return nil
 	}
 
-	var results []*NodeHealth
-	for _, node := range b.config.Nodes {
-		cached := b.cache.Get(node.Name)
-		if cached == nil {
-			// If any node doesn't have cached results, return empty slice
-			// This forces a full health check to ensure consistency
-			b.logger.Debug("incomplete cached health results, forcing full health check",
-				zap.String("missing_node", node.Name),
-				zap.Int("total_nodes", len(b.config.Nodes)),
-				zap.Int("cached_results", len(results)))
-			return nil
+	nodes := b.config.nodeList()
+	results, found := b.cache.appendNodes(slices.Grow(results, len(nodes)), nodes)
+	if found < len(nodes) {
+		// If any node doesn't have cached results, return empty slice
+		// This forces a full health check to ensure consistency
+		if ce := b.logger.Check(zapcore.DebugLevel, "incomplete cached health results, forcing full health check"); ce != nil {
+			ce.Write(zap.String("missing_node", nodes[found].Name),
+				zap.Int("total_nodes", len(nodes)),
+				zap.Int("cached_results", found))
 		}
-		results = append(results, cached)
+		return nil
 	}
 
-	b.logger.Debug("retrieved complete cached health results",
-		zap.Int("total_nodes", len(b.config.Nodes)),
-		zap.Int("cached_results", len(results)))
+	if ce := b.logger.Check(zapcore.DebugLevel, "retrieved complete cached health results"); ce != nil {
+		ce.Write(zap.Int("total_nodes", len(nodes)), zap.Int("cached_results", len(results)))
+	}
 
 	return results
 }
 
 // isWebSocketUpgradeRequest detects if the incoming request is a WebSocket upgrade request
 func (b *BlockchainHealthUpstream) isWebSocketUpgradeRequest(r *http.Request) bool {
-	// Check for WebSocket upgrade headers
-	connection := r.Header.Get("Connection")
-	upgrade := r.Header.Get("Upgrade")
+	// Check for WebSocket upgrade headers. The keys are canonical, so every
+	// request skips canonicalizing them.
+	connection := headerValue(r.Header, "Connection")
+	upgrade := headerValue(r.Header, "Upgrade")
 
-	// WebSocket upgrade requires both headers
+	// WebSocket upgrade requires both headers. The Connection header can
+	// contain multiple values; check if "upgrade" is one of them.
 	isUpgrade := false
-	if connection != "" {
-		// Connection header can contain multiple values, check if "upgrade" is one of them
-		for _, conn := range strings.Split(strings.ToLower(connection), ",") {
-			if strings.TrimSpace(conn) == "upgrade" {
-				isUpgrade = true
-				break
-			}
+	for conn := range strings.SplitSeq(connection, ",") {
+		if strings.EqualFold(strings.TrimSpace(conn), "upgrade") {
+			isUpgrade = true
+			break
 		}
 	}
 
-	isWebSocket := strings.ToLower(strings.TrimSpace(upgrade)) == "websocket"
+	isWebSocket := strings.EqualFold(strings.TrimSpace(upgrade), "websocket")
 
 	result := isUpgrade && isWebSocket
 
-	b.logger.Debug("WebSocket upgrade detection",
-		zap.Bool("is_websocket_request", result),
-		zap.String("connection", connection),
-		zap.String("upgrade", upgrade))
+	if ce := b.logger.Check(zapcore.DebugLevel, "WebSocket upgrade detection"); ce != nil {
+		ce.Write(zap.Bool("is_websocket_request", result),
+			zap.String("connection", connection),
+			zap.String("upgrade", upgrade))
+	}
 
 	return result
 }
 
-// provision sets up the module after configuration parsing
+// headerValue returns the first value of a header by its canonical key
+func headerValue(header http.Header, key string) string {
+	if values := header[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// provision sets up the module after configuration parsing. The pool the
+// upstream routes through belongs to the blockchain_health app, which checks
+// it once for every upstream using it.
 func (b *BlockchainHealthUpstream) provision(ctx caddy.Context) error {
 	// Set up logger
 	b.logger = ctx.Logger()
 
+	// Pools of the blockchain_health app are provisioned by the app
+	if b.Pool != "" {
+		return b.provisionShared(ctx)
+	}
+	if len(b.Hosts) > 0 {
+		return b.provisionHosts(ctx)
+	}
+	if err := b.applyDefaults(ctx); err != nil {
+		return err
+	}
+	if app, ok := loadApp(ctx); ok {
+		pool, err := app.inlinePool(b)
+		if err != nil {
+			return err
+		}
+		b.shared = pool
+		return nil
+	}
+	return b.provisionPool(ctx)
+}
+
+// provisionPool sets up the pool's health checks, metrics and discovery
+func (b *BlockchainHealthUpstream) provisionPool(ctx caddy.Context) error {
+	b.logger = ctx.Logger()
+
 	// If an existing config is already present (e.g., tests), preserve its nodes
 	if b.config != nil {
 		if len(b.Nodes) == 0 && len(b.config.Nodes) > 0 {
@@ -358,26 +819,66 @@ func (b *BlockchainHealthUpstream) provision(ctx caddy.Context) error {
 		BlockValidation:    b.BlockValidation,
 		Performance:        b.Performance,
 		FailureHandling:    b.FailureHandling,
+		Scoring:            b.Scoring,
+		TrafficSplit:       b.TrafficSplit,
+		Cost:               b.Cost,
+		DockerDiscovery:    b.DockerDiscovery,
+		Inventory:          b.Inventory,
+		PoolState:          b.PoolState,
+		DNSFailover:        b.DNSFailover,
+		AnycastSignal:      b.AnycastSignal,
+		StatusPage:         b.StatusPage,
+		Report:             b.Report,
+		RequestSigning:     b.RequestSigning,
+		Chaos:              b.Chaos,
+		AccountAffinity:    b.AccountAffinity,
+		MempoolDivergence:  b.MempoolDivergence,
+		SLO:                b.SLO,
+		ValidatorMode:      b.ValidatorMode,
+		Tiering:            b.Tiering,
+		FlapQuarantine:     b.FlapQuarantine,
 		Monitoring:         b.Monitoring,
 	}
 
 	// Process environment-based configuration before setting defaults
+	b.migrateChainTypes()
 	if err := b.processEnvironmentConfiguration(); err != nil {
-		if b.Legacy.FallbackBehavior == "fail_startup" {
+		if b.Legacy.FallbackBehavior == FallbackFailStartup {
 			return fmt.Errorf("environment configuration failed: %w", err)
 		}
 		b.logger.Warn("environment configuration failed, disabling health checks", zap.Error(err))
 		b.Legacy.LegacyMode = true
 	}
 
-	// Update config with processed nodes
-	b.config.Nodes = b.Nodes
+	// Update config with processed nodes in their canonical types, merging
+	// nodes listed twice
+	if err := migrateNodeTypes(b.Nodes, b.logger); err != nil {
+		return err
+	}
+	if b.StrictValidation.Enabled {
+		if err := b.strictValidate(ctx, b.Nodes); err != nil {
+			return fmt.Errorf("strict validation: %w", err)
+		}
+	}
+	nodes, duplicates, err := dedupeNodes(b.Nodes)
+	if err != nil {
+		return err
+	}
+	for _, duplicate := range duplicates {
+		b.logger.Warn("duplicate node merged into an earlier node with the same URL",
+			zap.String("node", duplicate.kept),
+			zap.String("duplicate", duplicate.dropped),
+			zap.String("url", duplicate.url))
+	}
+	b.config.Nodes = nodes
 	b.config.ExternalReferences = b.ExternalReferences
 
 	// Set default values
 	if err := b.setDefaults(); err != nil {
 		return fmt.Errorf("failed to set defaults: %w", err)
 	}
+	memoizeKeys(b.config.Nodes)
+	b.staticNodes = b.config.Nodes
 
 	// Initialize cache
 	cacheDuration, err := time.ParseDuration(b.config.Performance.CacheDuration)
@@ -399,10 +900,22 @@ func (b *BlockchainHealthUpstream) provision(ctx caddy.Context) error {
 		return fmt.Errorf("failed to register metrics: %w", err)
 	}
 	b.metrics = metrics
-	b.metrics.configuredNodes.Set(float64(len(b.config.Nodes)))
+	b.metrics.configuredNodes.Set(float64(len(b.config.nodeList())))
+	for _, node := range b.config.nodeList() {
+		b.metrics.nodeInfo.WithLabelValues(node.Name, node.key(), string(node.Type), b.config.Chain.Network).Set(1)
+	}
+
+	// Apply module log level overrides now that the config is final
+	baseLogger := ctx.Logger()
+	b.logger = newComponentLogger(baseLogger, b.config.Monitoring, LogComponentUpstream)
 
 	// Initialize health checker
-	b.healthChecker = NewHealthChecker(b.config, b.cache, b.metrics, b.logger)
+	b.healthChecker = NewHealthChecker(b.config, b.cache, b.metrics, baseLogger)
+
+	// Sign health checks and proxied requests for gateways requiring it
+	if b.signer = newRequestSigner(b.config.RequestSigning); b.signer != nil {
+		b.healthChecker.signProbes(b.signer)
+	}
 
 	// Log configuration details for debugging
 	b.logger.Info("blockchain health configuration",
@@ -411,12 +924,116 @@ func (b *BlockchainHealthUpstream) provision(ctx caddy.Context) error {
 		zap.String("check_interval", b.HealthCheck.Interval),
 		zap.Int("min_healthy_nodes", b.FailureHandling.MinHealthyNodes))
 
+	// Share the traffic split with the admin API
+	if b.config.TrafficSplit.Label != "" {
+		split, err := registerTrafficSplit(b.config.TrafficSplit)
+		if err != nil {
+			return fmt.Errorf("failed to register traffic split: %w", err)
+		}
+		b.split = split
+	}
+
+	// Share the month-to-date spend across pools using the same budget
+	if b.config.Cost.Enabled {
+		budget, err := registerCostBudget(b.costBudgetName())
+		if err != nil {
+			return fmt.Errorf("failed to register cost budget: %w", err)
+		}
+		b.budget = budget
+	}
+
+	// Share the pool state with the pool state matcher
+	state, err := registerChainState(b.poolStateName(), b.healthChecker)
+	if err != nil {
+		return fmt.Errorf("failed to register pool state: %w", err)
+	}
+	b.healthChecker.state = state
+
+	// Publish node and pool transitions as Caddy events
+	b.healthChecker.events = newEventEmitter(ctx)
+
+	// Pull the gateway out of DNS rotation while the pool is degraded
+	if b.config.DNSFailover.Enabled {
+		b.healthChecker.dnsFailover = newDNSFailover(&b.config.DNSFailover, b.logger, b.metrics)
+	}
+
+	// Publish the pool state to a status page
+	if b.config.StatusPage.Provider != "" {
+		b.healthChecker.statusPage = newStatusPage(&b.config.StatusPage, b.logger, b.metrics)
+	}
+
+	// Signal the readiness of the chain to BGP health scripts
+	if b.config.AnycastSignal.File != "" {
+		b.healthChecker.anycastSignal = &anycastSignal{config: &b.config.AnycastSignal, logger: b.logger}
+	}
+
+	// Collect the checks into daily or weekly health reports
+	if b.config.Report.Enabled {
+		report, err := registerHealthReport(b.reportName(), b.config.Report, b.logger)
+		if err != nil {
+			return fmt.Errorf("failed to register health report: %w", err)
+		}
+		b.healthChecker.report = report
+	}
+
+	// Accept injected faults through the admin API
+	if b.config.Chaos.Enabled {
+		name := b.config.Chaos.Name
+		if name == "" {
+			name = b.poolStateName()
+		}
+		chaos, err := registerChaosPool(name, b.healthChecker)
+		if err != nil {
+			return fmt.Errorf("failed to register chaos pool: %w", err)
+		}
+		b.healthChecker.chaos = chaos
+		b.logger.Warn("chaos fault injection enabled; do not use in production", zap.String("pool", name))
+	}
+
+	// Pin EVM accounts to nodes
+	if b.config.AccountAffinity.Enabled {
+		window := defaultAffinityWindow
+		if b.config.AccountAffinity.Window != "" {
+			if window, err = time.ParseDuration(b.config.AccountAffinity.Window); err != nil {
+				return fmt.Errorf("invalid account_affinity window: %w", err)
+			}
+		}
+		b.affinity = newAccountAffinity(window)
+	}
+
+	// Enforce the request budgets of the fallback providers
+	b.providers = newProviderRotation(b.config.ExternalReferences)
+
 	// Start background health checking
-	b.shutdown = make(chan struct{})
-	go b.backgroundHealthCheck()
+	interval, _ := time.ParseDuration(b.config.HealthCheck.Interval)
+	b.healthChecker.scheduleNodes(interval)
+
+	// Export the checker's own metrics and watch that it keeps checking
+	b.healthChecker.startSelfMonitor(interval)
+
+	// Compare the mempools of the EVM nodes in the background
+	if b.config.MempoolDivergence.Enabled {
+		b.healthChecker.startMempoolSampling()
+	}
+
+	// Add the nodes found in Docker, refreshing them in the background
+	if b.config.DockerDiscovery.Enabled {
+		if err := b.startDockerDiscovery(); err != nil {
+			return fmt.Errorf("failed to start docker discovery: %w", err)
+		}
+	}
+
+	// Accept nodes pushed by an inventory system
+	if b.config.Inventory.Enabled {
+		inv, err := registerInventory(b)
+		if err != nil {
+			return fmt.Errorf("failed to register inventory: %w", err)
+		}
+		b.inventory = inv
+	}
 
 	b.logger.Info("blockchain health upstream provisioned",
-		zap.Int("nodes", len(b.config.Nodes)),
+		zap.Int("nodes", len(b.config.nodeList())),
 		zap.Int("external_references", len(b.config.ExternalReferences)))
 
 	return nil
@@ -424,6 +1041,17 @@ func (b *BlockchainHealthUpstream) provision(ctx caddy.Context) error {
 
 // validate ensures the configuration is valid
 func (b *BlockchainHealthUpstream) validate() error {
+	// The app validates the pools this upstream references
+	if len(b.Hosts) > 0 {
+		return b.validateHosts()
+	}
+	if b.Pool != "" {
+		if len(b.Nodes) > 0 || len(b.ExternalReferences) > 0 {
+			return fmt.Errorf("pool %s: nodes belong in the blockchain_health app, not in an upstream referencing the pool", b.Pool)
+		}
+		return nil
+	}
+
 	// Temporarily process environment configuration for validation
 	// This is safe because it doesn't modify persistent state
 	tempNodes := make([]NodeConfig, len(b.Nodes))
@@ -432,13 +1060,13 @@ func (b *BlockchainHealthUpstream) validate() error {
 	// Process environment configuration to generate nodes for validation
 	if err := b.processEnvironmentConfiguration(); err != nil {
 		// If environment processing fails, only fail if no nodes are manually configured
-		if len(b.Nodes) == 0 {
+		if len(b.Nodes) == 0 && !b.DockerDiscovery.Enabled && !b.Inventory.Enabled {
 			return fmt.Errorf("no nodes configured and environment configuration failed: %w", err)
 		}
 	}
 
-	// Now validate that we have at least one node
-	if len(b.Nodes) == 0 {
+	// Now validate that we have at least one node, unless discovery supplies them
+	if len(b.Nodes) == 0 && !b.DockerDiscovery.Enabled && !b.Inventory.Enabled {
 		return fmt.Errorf("at least one node must be configured (either manually or via environment variables)")
 	}
 
@@ -455,17 +1083,42 @@ func (b *BlockchainHealthUpstream) validate() error {
 		if node.URL == "" {
 			return fmt.Errorf("node %s: URL is required", node.Name)
 		}
-		if node.Type != NodeTypeCosmos && node.Type != NodeTypeEVM && node.Type != NodeTypeBeacon {
-			return fmt.Errorf("node %s: invalid type %s", node.Name, node.Type)
+		if err := validateNodeTypes(node); err != nil {
+			return err
 		}
 		if node.Weight <= 0 {
 			return fmt.Errorf("node %s: weight must be positive", node.Name)
 		}
+		if _, err := nodeCost(node); err != nil {
+			return fmt.Errorf("node %s: %w", node.Name, err)
+		}
 
 		// Validate URL format
-		if _, err := url.Parse(node.URL); err != nil {
+		parsedURL, err := url.Parse(node.URL)
+		if err != nil {
 			return fmt.Errorf("node %s: invalid URL: %w", node.Name, err)
 		}
+		if parsedURL.Scheme == unixScheme {
+			if upstreamDial(parsedURL) == "" {
+				return fmt.Errorf("node %s: unix URL must name a socket path, as in unix:///var/run/node.sock", node.Name)
+			}
+			if isRawIPC(parsedURL.Path) {
+				return fmt.Errorf("node %s: raw IPC endpoints are not supported; serve the node's HTTP API on a unix socket instead", node.Name)
+			}
+			registerSocket(parsedURL.Path)
+		}
+		if err := validateNodeHeaders(node); err != nil {
+			return err
+		}
+		for _, alternate := range node.AlternateURLs {
+			parsedURL, err := url.Parse(alternate)
+			if err != nil {
+				return fmt.Errorf("node %s: invalid alternate URL: %w", node.Name, err)
+			}
+			if upstreamDial(parsedURL) == "" {
+				return fmt.Errorf("node %s: alternate URL %q has no host", node.Name, alternate)
+			}
+		}
 
 		// Validate API URL if provided
 		if node.APIURL != "" {
@@ -473,6 +1126,28 @@ func (b *BlockchainHealthUpstream) validate() error {
 				return fmt.Errorf("node %s: invalid API URL: %w", node.Name, err)
 			}
 		}
+		if mode, ok := node.Metadata["probe_mode"]; ok && !isValidCosmosProbeMode(mode) {
+			return fmt.Errorf("node %s: invalid probe mode: %s", node.Name, mode)
+		}
+		if path, ok := node.Metadata["rest_base_path"]; ok && !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("node %s: invalid REST base path %q (must start with /)", node.Name, path)
+		}
+		if value, ok := node.Metadata["max_logs_range"]; ok {
+			if _, valid := nodeLogsRange(node); !valid {
+				return fmt.Errorf("node %s: invalid max_logs_range %q (must be a positive block count)", node.Name, value)
+			}
+		}
+		if client, ok := node.Metadata["beacon_client"]; ok && !isValidBeaconClient(client) {
+			return fmt.Errorf("node %s: unknown beacon client: %s", node.Name, client)
+		}
+		if client, ok := node.Metadata["execution_client"]; ok && !isValidExecutionClient(client) {
+			return fmt.Errorf("node %s: unknown execution client: %s", node.Name, client)
+		}
+	}
+
+	// Nodes listed twice must agree on their type
+	if _, _, err := dedupeNodes(b.Nodes); err != nil {
+		return err
 	}
 
 	// Validate external references
@@ -491,6 +1166,30 @@ func (b *BlockchainHealthUpstream) validate() error {
 		if _, err := url.Parse(ref.URL); err != nil {
 			return fmt.Errorf("external reference %s: invalid URL: %w", ref.Name, err)
 		}
+
+		if ref.RequestsPerMinute < 0 || ref.Burst < 0 {
+			return fmt.Errorf("external reference %s: request budget must not be negative", ref.Name)
+		}
+		if ref.Burst > 0 && ref.RequestsPerMinute == 0 {
+			return fmt.Errorf("external reference %s: burst requires requests_per_minute", ref.Name)
+		}
+		if ref.Explorer != "" {
+			explorerType, known := explorerTypes[ref.Explorer]
+			if !known {
+				return fmt.Errorf("external reference %s: unknown explorer %s (must be 'etherscan', 'blockscout' or 'mintscan')", ref.Name, ref.Explorer)
+			}
+			if ref.Type != explorerType {
+				return fmt.Errorf("external reference %s: explorer %s reports %s heights, not %s", ref.Name, ref.Explorer, explorerType, ref.Type)
+			}
+			if ref.RequestsPerMinute > 0 {
+				return fmt.Errorf("external reference %s: explorers are not fallback providers and take no request budget", ref.Name)
+			}
+		}
+	}
+
+	// Validate settings limited to a fixed set of values
+	if err := b.validateChoices(); err != nil {
+		return err
 	}
 
 	// Validate timing configurations
@@ -504,6 +1203,9 @@ func (b *BlockchainHealthUpstream) validate() error {
 			return fmt.Errorf("invalid timeout: %w", err)
 		}
 	}
+	if strings.ContainsAny(b.HealthCheck.UserAgent, "\r\n") {
+		return fmt.Errorf("invalid user agent: %q", b.HealthCheck.UserAgent)
+	}
 	if b.HealthCheck.RetryDelay != "" {
 		if _, err := time.ParseDuration(b.HealthCheck.RetryDelay); err != nil {
 			return fmt.Errorf("invalid retry delay: %w", err)
@@ -519,6 +1221,249 @@ func (b *BlockchainHealthUpstream) validate() error {
 			return fmt.Errorf("invalid grace period: %w", err)
 		}
 	}
+	if b.Prewarm.Connections < 0 {
+		return fmt.Errorf("prewarm connections must not be negative")
+	}
+	if b.Prewarm.Interval != "" {
+		if interval, err := time.ParseDuration(b.Prewarm.Interval); err != nil || interval <= 0 {
+			return fmt.Errorf("invalid prewarm interval: %s", b.Prewarm.Interval)
+		}
+	}
+	if b.Monitoring.ShutdownDrain != "" {
+		if drain, err := time.ParseDuration(b.Monitoring.ShutdownDrain); err != nil || drain < 0 {
+			return fmt.Errorf("invalid shutdown drain: %s", b.Monitoring.ShutdownDrain)
+		}
+	}
+	if b.Monitoring.HealthCacheTTL != "" {
+		if ttl, err := time.ParseDuration(b.Monitoring.HealthCacheTTL); err != nil || ttl < 0 {
+			return fmt.Errorf("invalid health cache TTL: %s", b.Monitoring.HealthCacheTTL)
+		}
+	}
+	if b.FailureHandling.ErrorRateWindow != "" {
+		if _, err := time.ParseDuration(b.FailureHandling.ErrorRateWindow); err != nil {
+			return fmt.Errorf("invalid error rate window: %w", err)
+		}
+	}
+	if b.FailureHandling.ErrorRateMinSamples < 0 {
+		return fmt.Errorf("error rate min samples must not be negative")
+	}
+	if b.FailureHandling.ThrottleBackoff != "" {
+		if _, err := time.ParseDuration(b.FailureHandling.ThrottleBackoff); err != nil {
+			return fmt.Errorf("invalid throttle backoff: %w", err)
+		}
+	}
+	if b.FailureHandling.ThrottleWeightFactor < 0 || b.FailureHandling.ThrottleWeightFactor > 1 {
+		return fmt.Errorf("throttle weight factor must be between 0 and 1")
+	}
+	if b.BlockValidation.StrictLeaderThreshold < 0 {
+		return fmt.Errorf("strict leader threshold must not be negative")
+	}
+	if b.BlockValidation.HeightThresholdTime != "" {
+		if staleness, err := time.ParseDuration(b.BlockValidation.HeightThresholdTime); err != nil || staleness <= 0 {
+			return fmt.Errorf("invalid height threshold time: %s", b.BlockValidation.HeightThresholdTime)
+		}
+	}
+	if b.BlockValidation.BlockTime != "" {
+		if blockTime, err := time.ParseDuration(b.BlockValidation.BlockTime); err != nil || blockTime <= 0 {
+			return fmt.Errorf("invalid block time: %s", b.BlockValidation.BlockTime)
+		}
+	}
+	if b.BlockValidation.FinalityEpochThreshold < 0 {
+		return fmt.Errorf("finality epoch threshold must not be negative")
+	}
+	if b.BlockValidation.FinalizedThreshold < 0 {
+		return fmt.Errorf("finalized threshold must not be negative")
+	}
+	if b.BlockValidation.HaltMultiple < 0 {
+		return fmt.Errorf("halt multiple must not be negative")
+	}
+	if b.BlockValidation.ReferenceMaxAhead < 0 {
+		return fmt.Errorf("reference max ahead must not be negative")
+	}
+	if b.BlockValidation.ExternalLagWeightFactor < 0 || b.BlockValidation.ExternalLagWeightFactor > 1 {
+		return fmt.Errorf("external lag weight factor must be between 0 and 1")
+	}
+	if b.FailureHandling.MaxWait != "" {
+		if _, err := time.ParseDuration(b.FailureHandling.MaxWait); err != nil {
+			return fmt.Errorf("invalid max wait: %w", err)
+		}
+	}
+
+	// Validate scoring
+	if b.Scoring.Enabled {
+		weights := []float64{b.Scoring.HeightWeight, b.Scoring.LatencyWeight, b.Scoring.ErrorRateWeight, b.Scoring.PeerWeight}
+		for _, w := range weights {
+			if w < 0 {
+				return fmt.Errorf("scoring weights must not be negative")
+			}
+		}
+		if b.Scoring.Cutoff != nil && (*b.Scoring.Cutoff < 0 || *b.Scoring.Cutoff > 1) {
+			return fmt.Errorf("scoring cutoff must be between 0 and 1")
+		}
+		if b.Scoring.MaxLatency != "" {
+			if _, err := time.ParseDuration(b.Scoring.MaxLatency); err != nil {
+				return fmt.Errorf("invalid scoring max_latency: %w", err)
+			}
+		}
+		if b.Scoring.MinPeers < 0 {
+			return fmt.Errorf("scoring min_peers must not be negative")
+		}
+	}
+
+	// Validate traffic split
+	if b.TrafficSplit.Label != "" || len(b.TrafficSplit.Weights) > 0 {
+		if b.TrafficSplit.Label == "" {
+			return fmt.Errorf("traffic split requires a label")
+		}
+		if err := validateSplitWeights(b.TrafficSplit.Weights); err != nil {
+			return err
+		}
+	}
+
+	// Validate docker discovery
+	if b.DockerDiscovery.Enabled {
+		if _, _, err := dockerClient(b.DockerDiscovery.Endpoint); err != nil {
+			return err
+		}
+		if b.DockerDiscovery.Refresh != "" {
+			if refresh, err := time.ParseDuration(b.DockerDiscovery.Refresh); err != nil || refresh <= 0 {
+				return fmt.Errorf("invalid docker_discovery refresh: %s", b.DockerDiscovery.Refresh)
+			}
+		}
+	}
+
+	// Validate inventory pushes
+	if b.Inventory.Enabled && b.Inventory.Token == "" {
+		return fmt.Errorf("inventory requires a token")
+	}
+
+	// Validate pool state thresholds
+	for _, share := range []float64{b.PoolState.DegradedBelow, b.PoolState.CriticalBelow} {
+		if share < 0 || share > 1 {
+			return fmt.Errorf("pool_state thresholds must be between 0 and 1")
+		}
+	}
+	if b.PoolState.DegradedBelow != 0 && b.PoolState.CriticalBelow > b.PoolState.DegradedBelow {
+		return fmt.Errorf("pool_state critical_below must not exceed degraded_below")
+	}
+
+	// Validate DNS failover
+	if b.DNSFailover.Enabled {
+		if err := b.DNSFailover.validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate the status page exporter
+	if b.StatusPage.Provider != "" {
+		if err := b.StatusPage.validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate the anycast signal file
+	if b.AnycastSignal.File != "" {
+		if err := b.AnycastSignal.validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate health reports
+	if b.Report.Enabled {
+		if err := b.Report.validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate request signing
+	if b.RequestSigning.Method != "" || b.RequestSigning.Secret != "" {
+		if err := b.RequestSigning.validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate account affinity
+	if b.AccountAffinity.Window != "" {
+		if window, err := time.ParseDuration(b.AccountAffinity.Window); err != nil || window <= 0 {
+			return fmt.Errorf("invalid account_affinity window: %s", b.AccountAffinity.Window)
+		}
+	}
+
+	// Validate mempool divergence monitoring
+	if b.MempoolDivergence.Interval != "" {
+		if interval, err := time.ParseDuration(b.MempoolDivergence.Interval); err != nil || interval <= 0 {
+			return fmt.Errorf("invalid mempool_divergence interval: %s", b.MempoolDivergence.Interval)
+		}
+	}
+	if b.MempoolDivergence.Threshold < 0 || b.MempoolDivergence.Threshold > 1 {
+		return fmt.Errorf("mempool_divergence threshold must be between 0 and 1")
+	}
+	if b.MempoolDivergence.FlagAfter < 0 {
+		return fmt.Errorf("mempool_divergence flag_after must not be negative")
+	}
+	if b.MempoolDivergence.WeightFactor < 0 || b.MempoolDivergence.WeightFactor > 1 {
+		return fmt.Errorf("mempool_divergence weight_factor must be between 0 and 1")
+	}
+
+	// Validate SLO tracking
+	for name, share := range map[string]float64{"availability": b.SLO.Availability, "latency_target": b.SLO.LatencyTarget} {
+		if share < 0 || share >= 1 {
+			return fmt.Errorf("slo %s must be between 0 and 1, excluding 1", name)
+		}
+	}
+	for name, value := range map[string]string{"latency": b.SLO.Latency, "window": b.SLO.Window} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			return fmt.Errorf("invalid slo %s: %s", name, value)
+		}
+	}
+	if b.SLO.BurnRate < 0 {
+		return fmt.Errorf("slo burn_rate must not be negative")
+	}
+	if b.SLO.WeightFactor < 0 || b.SLO.WeightFactor > 1 {
+		return fmt.Errorf("slo weight_factor must be between 0 and 1")
+	}
+
+	// Validate validator mode
+	if b.ValidatorMode.LatencyTolerance != "" {
+		if tolerance, err := time.ParseDuration(b.ValidatorMode.LatencyTolerance); err != nil || tolerance < 0 {
+			return fmt.Errorf("invalid validator_mode latency_tolerance: %s", b.ValidatorMode.LatencyTolerance)
+		}
+	}
+
+	// Validate hot/cold tiering
+	if err := b.validateTiering(); err != nil {
+		return err
+	}
+
+	// Validate flap quarantine
+	if err := b.validateFlapQuarantine(); err != nil {
+		return err
+	}
+
+	// Validate cost-aware routing
+	if b.Cost.MonthlyBudget < 0 {
+		return fmt.Errorf("cost monthly_budget must not be negative")
+	}
+
+	// Validate the network, which becomes part of the pool's default names
+	if b.Chain.Network != "" && !chainTypePattern.MatchString(b.Chain.Network) {
+		return fmt.Errorf("invalid network %q (must be an identifier such as 'mainnet' or 'testnet')", b.Chain.Network)
+	}
+
+	// Validate log levels
+	if b.Monitoring.LogLevel != "" {
+		if _, err := parseLogLevel(b.Monitoring.LogLevel); err != nil {
+			return err
+		}
+	}
+	for component, level := range b.Monitoring.ComponentLogLevels {
+		if _, err := parseLogLevel(level); err != nil {
+			return fmt.Errorf("component %s: %w", component, err)
+		}
+	}
 
 	// Validate thresholds
 	if b.FailureHandling.CircuitBreakerThreshold != 0 && (b.FailureHandling.CircuitBreakerThreshold <= 0 || b.FailureHandling.CircuitBreakerThreshold > 1) {
@@ -530,14 +1475,47 @@ func (b *BlockchainHealthUpstream) validate() error {
 
 // cleanup stops background processes and cleans up resources
 func (b *BlockchainHealthUpstream) cleanup() error {
-	if b.shutdown != nil {
-		close(b.shutdown)
+	if b.prewarm != nil {
+		b.prewarm.stop()
+		b.prewarm = nil
+	}
+
+	// The app cleans up the pools it shares
+	if b.Pool != "" || b.shared != nil || len(b.Hosts) > 0 {
+		return nil
+	}
+
+	// The shared state is released but not cleared, as requests still in
+	// flight may read it
+	if b.healthChecker != nil {
+		b.healthChecker.Stop()
+		if b.healthChecker.state != nil {
+			releaseChainState(b.healthChecker.state, b.healthChecker)
+		}
+		if b.healthChecker.chaos != nil {
+			releaseChaosPool(b.healthChecker.chaos, b.healthChecker)
+		}
+		if b.healthChecker.report != nil {
+			releaseHealthReport(b.healthChecker.report)
+		}
 	}
 
 	if b.metrics != nil {
 		releaseGlobalMetrics()
 		b.metrics = nil
 	}
+	if b.split != nil {
+		releaseTrafficSplit(b.split)
+		b.split = nil
+	}
+	if b.budget != nil {
+		releaseCostBudget(b.budget)
+		b.budget = nil
+	}
+	if b.inventory != nil {
+		releaseInventory(b.inventory, b)
+		b.inventory = nil
+	}
 
 	b.logger.Info("blockchain health upstream cleaned up")
 	return nil
@@ -575,6 +1553,14 @@ func (b *BlockchainHealthUpstream) setDefaults() error {
 		b.config.Performance.MaxConcurrentChecks = 10
 	}
 
+	// Pool state defaults; critical never starts above degraded
+	if b.config.PoolState.DegradedBelow == 0 {
+		b.config.PoolState.DegradedBelow = defaultDegradedBelow
+	}
+	if b.config.PoolState.CriticalBelow == 0 {
+		b.config.PoolState.CriticalBelow = math.Min(defaultCriticalBelow, b.config.PoolState.DegradedBelow)
+	}
+
 	// Failure handling defaults
 	if b.config.FailureHandling.MinHealthyNodes == 0 {
 		b.config.FailureHandling.MinHealthyNodes = 1
@@ -585,44 +1571,151 @@ func (b *BlockchainHealthUpstream) setDefaults() error {
 	if b.config.FailureHandling.CircuitBreakerThreshold == 0 {
 		b.config.FailureHandling.CircuitBreakerThreshold = 0.8
 	}
+	if b.config.FailureHandling.ErrorRateWindow == "" {
+		b.config.FailureHandling.ErrorRateWindow = defaultErrorRateWindow.String()
+	}
+	if b.config.FailureHandling.ErrorRateMinSamples == 0 {
+		b.config.FailureHandling.ErrorRateMinSamples = defaultErrorRateMinSamples
+	}
+	if b.config.FailureHandling.ThrottleBackoff == "" {
+		b.config.FailureHandling.ThrottleBackoff = defaultThrottleBackoff.String()
+	}
+	if b.config.FailureHandling.ThrottleWeightFactor == 0 {
+		b.config.FailureHandling.ThrottleWeightFactor = defaultThrottleWeightFactor
+	}
+
+	// Scoring defaults: weigh height, latency and error rate equally unless configured
+	if b.config.Scoring.Enabled {
+		sc := &b.config.Scoring
+		if sc.HeightWeight == 0 && sc.LatencyWeight == 0 && sc.ErrorRateWeight == 0 && sc.PeerWeight == 0 {
+			sc.HeightWeight = 1
+			sc.LatencyWeight = 1
+			sc.ErrorRateWeight = 1
+		}
+		if sc.Cutoff == nil {
+			cutoff := defaultScoreCutoff
+			sc.Cutoff = &cutoff
+		}
+		if sc.MinPeers == 0 {
+			sc.MinPeers = defaultMinPeers
+		}
+	}
+
+	// Mempool divergence defaults; a zero weight_factor only flags nodes
+	if b.config.MempoolDivergence.Enabled {
+		md := &b.config.MempoolDivergence
+		if md.Interval == "" {
+			md.Interval = defaultMempoolInterval.String()
+		}
+		if md.Threshold == 0 {
+			md.Threshold = defaultMempoolThreshold
+		}
+		if md.FlagAfter == 0 {
+			md.FlagAfter = defaultMempoolFlagAfter
+		}
+	}
+
+	// Tiering defaults
+	if b.config.Tiering.Enabled && b.config.Tiering.HotBlocks == 0 {
+		b.config.Tiering.HotBlocks = defaultHotBlocks
+	}
+
+	// Flap quarantine defaults
+	if b.config.FlapQuarantine.Enabled {
+		fq := &b.config.FlapQuarantine
+		if fq.MaxTransitions == 0 {
+			fq.MaxTransitions = defaultFlapMaxTransitions
+		}
+		if fq.Window == "" {
+			fq.Window = defaultFlapWindow.String()
+		}
+		if fq.Cooldown == "" {
+			fq.Cooldown = defaultFlapCooldown.String()
+		}
+	}
+
+	// SLO defaults; a zero weight_factor only reports burn rates
+	if b.config.SLO.Enabled {
+		slo := &b.config.SLO
+		if slo.Availability == 0 {
+			slo.Availability = defaultSLOAvailability
+		}
+		if slo.Latency == "" {
+			slo.Latency = defaultSLOLatency.String()
+		}
+		if slo.LatencyTarget == 0 {
+			slo.LatencyTarget = defaultSLOLatencyTarget
+		}
+		if slo.Window == "" {
+			slo.Window = defaultSLOWindow.String()
+		}
+		if slo.BurnRate == 0 {
+			slo.BurnRate = defaultSLOBurnRate
+		}
+	}
 
-	// Monitoring defaults
-	if b.config.Monitoring.LogLevel == "" {
-		b.config.Monitoring.LogLevel = "info"
+	// DNS failover defaults
+	if b.config.DNSFailover.Enabled {
+		df := &b.config.DNSFailover
+		if df.WithdrawOn == "" {
+			df.WithdrawOn = PoolCritical
+		}
+		if df.After == "" {
+			df.After = defaultDNSFailoverAfter.String()
+		}
+		if df.Timeout == "" {
+			df.Timeout = defaultDNSFailoverTimeout.String()
+		}
+		for _, request := range []*DNSFailoverRequest{&df.Withdraw, &df.Restore} {
+			if request.Method == "" {
+				request.Method = http.MethodPost
+			}
+		}
 	}
+
+	// Status page defaults
+	if b.config.StatusPage.Provider != "" {
+		if b.config.StatusPage.After == "" {
+			b.config.StatusPage.After = defaultStatusPageAfter.String()
+		}
+		if b.config.StatusPage.Timeout == "" {
+			b.config.StatusPage.Timeout = defaultStatusPageTimeout.String()
+		}
+	}
+
+	// The chain is down for BGP only once no node is healthy by default
+	if b.config.AnycastSignal.File != "" && b.config.AnycastSignal.WithdrawOn == "" {
+		b.config.AnycastSignal.WithdrawOn = PoolDown
+	}
+
+	// Health report defaults
+	if b.config.Report.Enabled {
+		report := &b.config.Report
+		if report.Period == "" {
+			report.Period = reportDaily
+		}
+		if report.Format == "" {
+			report.Format = reportJSON
+		}
+		if report.Timeout == "" {
+			report.Timeout = defaultReportTimeout.String()
+		}
+	}
+
+	// Monitoring defaults (an empty log_level keeps the Caddy logger level)
 	if b.config.Monitoring.HealthEndpoint == "" {
 		b.config.Monitoring.HealthEndpoint = "/health"
 	}
+	if b.config.Monitoring.HealthCacheTTL == "" {
+		b.config.Monitoring.HealthCacheTTL = defaultHealthCacheTTL.String()
+	}
 
 	// Set default weights for nodes
 	for i := range b.config.Nodes {
 		if b.config.Nodes[i].Weight == 0 {
-			b.config.Nodes[i].Weight = 100
+			b.config.Nodes[i].Weight = defaultNodeWeight
 		}
 	}
 
 	return nil
 }
-
-// backgroundHealthCheck runs periodic health checks in the background
-func (b *BlockchainHealthUpstream) backgroundHealthCheck() {
-	interval, _ := time.ParseDuration(b.config.HealthCheck.Interval)
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-			_, err := b.healthChecker.CheckAllNodes(ctx)
-			if err != nil {
-				b.logger.Error("background health check failed", zap.Error(err))
-			}
-			cancel()
-
-		case <-b.shutdown:
-			b.logger.Debug("stopping background health checker")
-			return
-		}
-	}
-}
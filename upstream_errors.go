@@ -0,0 +1,176 @@
+package blockchain_health
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// errorCauseVar is the request variable holding the cause of an upstream
+// error, for handle_errors routes to match on
+const errorCauseVar = "blockchain_health.error_cause"
+
+// chainVar is the request variable holding the chain of a pool that refused
+// to select a node
+const chainVar = "blockchain_health.chain"
+
+// defaultErrorBody is the response body used with Respond when no Body
+// template is configured
+const defaultErrorBody = `{"error":{"code":{blockchain_health.status},"cause":"{blockchain_health.cause}","message":"{blockchain_health.message}","chain":"{blockchain_health.chain}","retry_after":{blockchain_health.retry_after},"request_id":"{blockchain_health.request_id}"}}`
+
+// errorBodyPlaceholder matches the placeholders of an error body template.
+// Keys are dotted names, so the braces of JSON objects are left alone.
+var errorBodyPlaceholder = regexp.MustCompile(`\{[\w.\-]+\}`)
+
+// UpstreamErrors is a middleware placed in front of reverse_proxy that turns
+// the generic 503 reverse_proxy answers when GetUpstreams fails into the
+// typed error behind it: 500 for ErrNotProvisioned, 502 for ErrChainDegraded
+// and 503 for ErrNoHealthyUpstreams. handle_errors routes see the mapped
+// status in {http.error.status_code} and the cause in
+// {http.vars.blockchain_health.error_cause}. With Respond (or a Body
+// template) it answers with a machine-readable error body instead.
+type UpstreamErrors struct {
+	Respond     bool           `json:"respond,omitempty"`
+	Body        string         `json:"body,omitempty"`
+	ContentType string         `json:"content_type,omitempty"`
+	RetryAfter  caddy.Duration `json:"retry_after,omitempty"`
+}
+
+func init() {
+	caddy.RegisterModule(&UpstreamErrors{})
+}
+
+// CaddyModule returns the Caddy module information.
+func (*UpstreamErrors) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.blockchain_upstream_errors",
+		New: func() caddy.Module { return new(UpstreamErrors) },
+	}
+}
+
+// Provision applies defaults
+func (h *UpstreamErrors) Provision(ctx caddy.Context) error {
+	if h.Body != "" {
+		h.Respond = true
+	}
+	if h.ContentType == "" {
+		h.ContentType = "application/json"
+	}
+	if h.RetryAfter == 0 {
+		h.RetryAfter = caddy.Duration(defaultBackpressureRetryAfter)
+	}
+	return nil
+}
+
+// Validate checks configuration correctness
+func (h *UpstreamErrors) Validate() error {
+	if h.RetryAfter < 0 {
+		return fmt.Errorf("retry_after must not be negative")
+	}
+	return nil
+}
+
+// ServeHTTP maps upstream selection failures to their typed errors
+func (h *UpstreamErrors) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	err := next.ServeHTTP(w, r)
+	cause := upstreamError(r, err)
+	if cause == nil {
+		return err
+	}
+
+	caddyhttp.SetVar(r.Context(), errorCauseVar, upstreamErrorCause(cause))
+	status := upstreamErrorStatus(cause)
+	if !h.Respond {
+		return caddyhttp.Error(status, cause)
+	}
+
+	// Only unavailability is worth retrying; a provisioning bug is not
+	if status != http.StatusInternalServerError {
+		w.Header().Set("Retry-After", retryAfterSeconds(time.Duration(h.RetryAfter)))
+	}
+	w.Header().Set("Content-Type", h.ContentType)
+	w.WriteHeader(status)
+	_, writeErr := w.Write([]byte(h.renderBody(r, status, cause)))
+	return writeErr
+}
+
+// renderBody fills the body template for an upstream error. Every value is
+// JSON string escaped so it can be placed inside quotes; unknown placeholders
+// are left as they are.
+func (h *UpstreamErrors) renderBody(r *http.Request, status int, cause error) string {
+	template := h.Body
+	if template == "" {
+		template = defaultErrorBody
+	}
+
+	chain, _ := caddyhttp.GetVar(r.Context(), chainVar).(string)
+	var degraded *ErrChainDegraded
+	if errors.As(cause, &degraded) && degraded.Chain != "" {
+		chain = degraded.Chain
+	}
+
+	values := map[string]string{
+		"blockchain_health.status":      strconv.Itoa(status),
+		"blockchain_health.cause":       upstreamErrorCause(cause),
+		"blockchain_health.message":     cause.Error(),
+		"blockchain_health.chain":       chain,
+		"blockchain_health.retry_after": retryAfterSeconds(time.Duration(h.RetryAfter)),
+		"blockchain_health.request_id":  requestID(r),
+	}
+	repl, _ := r.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer)
+
+	return errorBodyPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		key := placeholder[1 : len(placeholder)-1]
+		value, ok := values[key]
+		if !ok && repl != nil {
+			var v any
+			if v, ok = repl.Get(key); ok {
+				value = caddy.ToString(v)
+			}
+		}
+		if !ok {
+			return placeholder
+		}
+		escaped, _ := json.Marshal(value)
+		return string(escaped[1 : len(escaped)-1])
+	})
+}
+
+// requestID returns the client's X-Request-Id, or the request UUID Caddy
+// uses in its logs
+func requestID(r *http.Request) string {
+	if id := r.Header.Get("X-Request-Id"); id != "" {
+		return id
+	}
+	if repl, ok := r.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer); ok {
+		if id, ok := repl.GetString("http.request.uuid"); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+// upstreamError returns the GetUpstreams error behind a reverse_proxy error,
+// or nil if the pool did not refuse to select a node
+func upstreamError(r *http.Request, err error) error {
+	var handlerErr caddyhttp.HandlerError
+	if !errors.As(err, &handlerErr) || handlerErr.StatusCode != http.StatusServiceUnavailable {
+		return nil
+	}
+	cause, _ := caddyhttp.GetVar(r.Context(), noUpstreamsVar).(error)
+	return cause
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*UpstreamErrors)(nil)
+	_ caddy.Validator             = (*UpstreamErrors)(nil)
+	_ caddyhttp.MiddlewareHandler = (*UpstreamErrors)(nil)
+)
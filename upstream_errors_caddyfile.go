@@ -0,0 +1,78 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	httpcaddyfile "github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+func init() {
+	// Register Caddyfile directive for this handler
+	httpcaddyfile.RegisterHandlerDirective("blockchain_upstream_errors", parseUpstreamErrorsCaddyfile)
+	httpcaddyfile.RegisterDirectiveOrder("blockchain_upstream_errors", httpcaddyfile.Before, "reverse_proxy")
+}
+
+func parseUpstreamErrorsCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	ue := new(UpstreamErrors)
+	if err := ue.UnmarshalCaddyfile(h.Dispenser); err != nil {
+		return nil, err
+	}
+	return ue, nil
+}
+
+// UnmarshalCaddyfile implements caddyfile.Unmarshaler for blockchain_upstream_errors
+func (h *UpstreamErrors) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	for d.Next() {
+		if d.NextArg() {
+			return d.ArgErr()
+		}
+		for d.NextBlock(0) {
+			switch d.Val() {
+			case "respond":
+				if d.NextArg() {
+					return d.ArgErr()
+				}
+				h.Respond = true
+
+			case "body":
+				// Syntax: body <template>; heredocs keep JSON readable
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				h.Body = d.Val()
+				h.Respond = true
+
+			case "content_type":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				h.ContentType = d.Val()
+
+			case "retry_after":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				dur, err := time.ParseDuration(d.Val())
+				if err != nil {
+					return d.Errf("invalid retry_after: %v", err)
+				}
+				h.RetryAfter = caddy.Duration(dur)
+
+			default:
+				return d.Errf("unknown directive: %s", d.Val())
+			}
+		}
+	}
+
+	if err := h.Validate(); err != nil {
+		return fmt.Errorf("blockchain_upstream_errors validation: %w", err)
+	}
+	return nil
+}
+
+// Interface guard
+var _ caddyfile.Unmarshaler = (*UpstreamErrors)(nil)
@@ -0,0 +1,185 @@
+package blockchain_health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestGetUpstreams_TypedErrors(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if _, err := (&BlockchainHealthUpstream{}).GetUpstreams(r); !errors.Is(err, ErrNotProvisioned) {
+		t.Errorf("expected ErrNotProvisioned, got %v", err)
+	}
+
+	// The node is catching up, so it is not healthy
+	server := createCosmosServer(t, 1000, true)
+	defer server.Close()
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "node", URL: server.URL, Type: NodeTypeCosmos, ChainType: "akash", Weight: 1},
+	}, zaptest.NewLogger(t))
+
+	upstream.config.FailureHandling.FallbackStrategy = FallbackError
+	_, err := upstream.GetUpstreams(r)
+	var degraded *ErrChainDegraded
+	if !errors.As(err, &degraded) || degraded.Chain != "akash" {
+		t.Errorf("expected ErrChainDegraded for akash, got %v", err)
+	}
+
+	upstream.config.FailureHandling.FallbackStrategy = FallbackExternalProviders
+	if _, err := upstream.GetUpstreams(r); !errors.Is(err, ErrNoHealthyUpstreams) {
+		t.Errorf("expected ErrNoHealthyUpstreams, got %v", err)
+	}
+}
+
+func TestUpstreamErrors_MapsCauses(t *testing.T) {
+	tests := []struct {
+		name         string
+		cause        error
+		expectStatus int
+		expectCause  string
+	}{
+		{name: "not_provisioned", cause: ErrNotProvisioned, expectStatus: http.StatusInternalServerError, expectCause: causeNotProvisioned},
+		{name: "no_healthy", cause: ErrNoHealthyUpstreams, expectStatus: http.StatusServiceUnavailable, expectCause: causeNoHealthyUpstreams},
+		{name: "degraded", cause: &ErrChainDegraded{Chain: "ethereum"}, expectStatus: http.StatusBadGateway, expectCause: causeChainDegraded},
+		{name: "health_check", cause: errors.New("health check failed"), expectStatus: http.StatusServiceUnavailable, expectCause: causeHealthCheckFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r = r.WithContext(context.WithValue(r.Context(), caddyhttp.VarsCtxKey, map[string]any{}))
+
+			// reverse_proxy logs the GetUpstreams error and answers a generic 503
+			next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+				caddyhttp.SetVar(r.Context(), noUpstreamsVar, tt.cause)
+				return caddyhttp.Error(http.StatusServiceUnavailable, errors.New(noUpstreamsMessage))
+			})
+
+			err := (&UpstreamErrors{}).ServeHTTP(httptest.NewRecorder(), r, next)
+			var handlerErr caddyhttp.HandlerError
+			if !errors.As(err, &handlerErr) || handlerErr.StatusCode != tt.expectStatus {
+				t.Fatalf("expected status %d, got %v", tt.expectStatus, err)
+			}
+			if !errors.Is(err, tt.cause) {
+				t.Errorf("expected the typed error to be kept, got %v", err)
+			}
+			if cause := caddyhttp.GetVar(r.Context(), errorCauseVar); cause != tt.expectCause {
+				t.Errorf("expected cause %q, got %v", tt.expectCause, cause)
+			}
+		})
+	}
+}
+
+func TestUpstreamErrors_PassesSaturationThrough(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(context.WithValue(r.Context(), caddyhttp.VarsCtxKey, map[string]any{}))
+
+	// Every node is at its in-flight limit; GetUpstreams itself succeeded
+	saturated := caddyhttp.Error(http.StatusServiceUnavailable, errors.New(noUpstreamsMessage))
+	next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return saturated
+	})
+
+	if err := (&UpstreamErrors{}).ServeHTTP(httptest.NewRecorder(), r, next); !errors.Is(err, saturated.Err) {
+		t.Errorf("expected the saturation error to pass through, got %v", err)
+	}
+}
+
+func TestUpstreamErrors_RespondsWithJSON(t *testing.T) {
+	h := &UpstreamErrors{Respond: true}
+	if err := h.Provision(caddy.Context{}); err != nil {
+		t.Fatalf("Provision failed: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("X-Request-Id", "req-42")
+	r = r.WithContext(context.WithValue(r.Context(), caddyhttp.VarsCtxKey, map[string]any{}))
+	next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		caddyhttp.SetVar(r.Context(), noUpstreamsVar, &ErrChainDegraded{Chain: `osmo"sis`})
+		return caddyhttp.Error(http.StatusServiceUnavailable, errors.New(noUpstreamsMessage))
+	})
+
+	rec := httptest.NewRecorder()
+	if err := h.ServeHTTP(rec, r, next); err != nil {
+		t.Fatalf("ServeHTTP returned error: %v", err)
+	}
+	if rec.Code != http.StatusBadGateway || rec.Header().Get("Retry-After") != "1" || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected response: status=%d headers=%v", rec.Code, rec.Header())
+	}
+
+	var body struct {
+		Error struct {
+			Code       int    `json:"code"`
+			Cause      string `json:"cause"`
+			Chain      string `json:"chain"`
+			RetryAfter int    `json:"retry_after"`
+			RequestID  string `json:"request_id"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("expected a JSON body, got %s: %v", rec.Body.String(), err)
+	}
+	if body.Error.Code != 502 || body.Error.Cause != causeChainDegraded || body.Error.Chain != `osmo"sis` ||
+		body.Error.RetryAfter != 1 || body.Error.RequestID != "req-42" {
+		t.Errorf("unexpected body: %s", rec.Body.String())
+	}
+}
+
+func TestUpstreamErrors_RendersTemplate(t *testing.T) {
+	h := &UpstreamErrors{Body: `{"ok":false,"chain":"{blockchain_health.chain}","path":"{http.request.uri.path}","other":"{unknown.key}"}`}
+	if err := h.Provision(caddy.Context{}); err != nil {
+		t.Fatalf("Provision failed: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/cosmos/status", nil)
+	repl := caddy.NewReplacer()
+	repl.Set("http.request.uri.path", r.URL.Path)
+	ctx := context.WithValue(r.Context(), caddy.ReplacerCtxKey, repl)
+	ctx = context.WithValue(ctx, caddyhttp.VarsCtxKey, map[string]any{chainVar: "akash"})
+	r = r.WithContext(ctx)
+
+	got := h.renderBody(r, http.StatusServiceUnavailable, ErrNoHealthyUpstreams)
+	want := `{"ok":false,"chain":"akash","path":"/cosmos/status","other":"{unknown.key}"}`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestUpstreamErrors_UnmarshalCaddyfile(t *testing.T) {
+	var h UpstreamErrors
+	if err := h.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`blockchain_upstream_errors`)); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if h.Respond {
+		t.Error("expected errors to be returned to handle_errors by default")
+	}
+
+	d := caddyfile.NewTestDispenser(`blockchain_upstream_errors {
+		body "{\"error\":\"{blockchain_health.cause}\"}"
+		content_type application/problem+json
+		retry_after 5s
+	}`)
+	h = UpstreamErrors{}
+	if err := h.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if !h.Respond || h.Body != `{"error":"{blockchain_health.cause}"}` || h.ContentType != "application/problem+json" || time.Duration(h.RetryAfter) != 5*time.Second {
+		t.Errorf("unexpected config: respond=%v body=%q content_type=%q retry_after=%v", h.Respond, h.Body, h.ContentType, h.RetryAfter)
+	}
+
+	if err := h.UnmarshalCaddyfile(caddyfile.NewTestDispenser(`blockchain_upstream_errors {
+		status 500
+	}`)); err == nil {
+		t.Error("expected unknown options to be rejected")
+	}
+}
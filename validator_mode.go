@@ -0,0 +1,92 @@
+package blockchain_health
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+	"go.uber.org/zap"
+)
+
+// defaultValidatorPinHeader is the request header naming the node a
+// validator request is pinned to
+const defaultValidatorPinHeader = "X-Beacon-Node"
+
+// pinHeader returns the header naming the pinned node
+func (v ValidatorModeConfig) pinHeader() string {
+	if v.PinHeader != "" {
+		return v.PinHeader
+	}
+	return defaultValidatorPinHeader
+}
+
+// latencyTolerance returns how much slower than the fastest node a node may
+// be and still share requests
+func (v ValidatorModeConfig) latencyTolerance() time.Duration {
+	if d, err := time.ParseDuration(v.LatencyTolerance); err == nil && d > 0 {
+		return d
+	}
+	return 0
+}
+
+// applyValidatorMode narrows the upstreams for validator clients. A request
+// naming a selected node in the pin header goes to that node; otherwise it
+// goes to the fastest nodes by last health check response time. A pinned
+// node that is not selected, because it is unhealthy or unsafe for duties,
+// is ignored rather than failing the request.
+func (b *BlockchainHealthUpstream) applyValidatorMode(r *http.Request, upstreams []*reverseproxy.Upstream, infos []selectionInfo, healthResults []*NodeHealth) ([]*reverseproxy.Upstream, []selectionInfo) {
+	if len(upstreams) < 2 || len(infos) != len(upstreams) {
+		return upstreams, infos
+	}
+	cfg := b.config.ValidatorMode
+
+	keep := make([]bool, len(infos))
+	reason := "validator_latency"
+	if pinned := r.Header.Get(cfg.pinHeader()); pinned != "" {
+		found := false
+		for i, info := range infos {
+			if info.name == pinned {
+				keep[i], found = true, true
+			}
+		}
+		if found {
+			reason = "validator_pin"
+		} else {
+			b.logger.Debug("pinned node not available for validator request",
+				zap.String("node", pinned))
+		}
+	}
+
+	if reason == "validator_latency" {
+		latency := make(map[string]time.Duration, len(healthResults))
+		for _, health := range healthResults {
+			if health != nil {
+				latency[health.Name] = health.ResponseTime
+			}
+		}
+		fastest := time.Duration(-1)
+		for _, info := range infos {
+			if d := latency[info.name]; fastest < 0 || d < fastest {
+				fastest = d
+			}
+		}
+		limit := fastest + cfg.latencyTolerance()
+		for i, info := range infos {
+			keep[i] = latency[info.name] <= limit
+		}
+	}
+
+	var kept []*reverseproxy.Upstream
+	var keptInfos []selectionInfo
+	for i, upstream := range upstreams {
+		if !keep[i] {
+			if b.metrics != nil {
+				b.metrics.upstreamsExcluded.WithLabelValues(infos[i].name, infos[i].serviceType, reason).Inc()
+			}
+			continue
+		}
+		kept = append(kept, upstream)
+		keptInfos = append(keptInfos, infos[i])
+	}
+	return kept, keptInfos
+}
@@ -0,0 +1,118 @@
+package blockchain_health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestApplyValidatorMode(t *testing.T) {
+	upstream := &BlockchainHealthUpstream{
+		config:  &Config{ValidatorMode: ValidatorModeConfig{Enabled: true}},
+		metrics: NewMetrics(),
+		logger:  zap.NewNop(),
+	}
+	upstreams := []*reverseproxy.Upstream{{Dial: "a:5052"}, {Dial: "b:5052"}, {Dial: "c:5052"}}
+	infos := []selectionInfo{{name: "a"}, {name: "b"}, {name: "c"}}
+	results := []*NodeHealth{
+		{Name: "a", ResponseTime: 40 * time.Millisecond},
+		{Name: "b", ResponseTime: 12 * time.Millisecond},
+		{Name: "c", ResponseTime: 20 * time.Millisecond},
+	}
+	dials := func(ups []*reverseproxy.Upstream) []string {
+		var out []string
+		for _, up := range ups {
+			out = append(out, up.Dial)
+		}
+		return out
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/eth/v1/validator/duties/proposer/100", nil)
+	if got, _ := upstream.applyValidatorMode(r, upstreams, infos, results); len(got) != 1 || got[0].Dial != "b:5052" {
+		t.Errorf("expected the fastest node, got %v", dials(got))
+	}
+
+	upstream.config.ValidatorMode.LatencyTolerance = "10ms"
+	if got, _ := upstream.applyValidatorMode(r, upstreams, infos, results); len(got) != 2 {
+		t.Errorf("expected the nodes within the tolerance, got %v", dials(got))
+	}
+
+	// The pin header overrides latency
+	r.Header.Set("X-Beacon-Node", "a")
+	if got, _ := upstream.applyValidatorMode(r, upstreams, infos, results); len(got) != 1 || got[0].Dial != "a:5052" {
+		t.Errorf("expected the pinned node, got %v", dials(got))
+	}
+
+	// A pinned node that is not selected is ignored
+	r.Header.Set("X-Beacon-Node", "gone")
+	if got, _ := upstream.applyValidatorMode(r, upstreams, infos, results); len(got) != 2 {
+		t.Errorf("expected the fastest nodes, got %v", dials(got))
+	}
+
+	upstream.config.ValidatorMode.PinHeader = "X-Validator-Node"
+	r.Header.Set("X-Validator-Node", "c")
+	if got, _ := upstream.applyValidatorMode(r, upstreams, infos, results); len(got) != 1 || got[0].Dial != "c:5052" {
+		t.Errorf("expected the node pinned by the custom header, got %v", dials(got))
+	}
+}
+
+func TestValidatorModeRequiresSafeNodes(t *testing.T) {
+	safe := createFinalityBeaconServer(t, 3200, 98, false, false)
+	defer safe.Close()
+	optimistic := createFinalityBeaconServer(t, 3200, 98, true, false)
+	defer optimistic.Close()
+
+	upstream := createTestUpstream([]NodeConfig{
+		{Name: "safe", URL: safe.URL, Type: NodeTypeBeacon, ChainType: "test-beacon", Weight: 1},
+		{Name: "optimistic", URL: optimistic.URL, Type: NodeTypeBeacon, ChainType: "test-beacon", Weight: 1},
+	}, zaptest.NewLogger(t))
+	upstream.config.ValidatorMode = ValidatorModeConfig{Enabled: true}
+
+	// Every request needs a safe node in validator mode, and pins cannot
+	// reach an unsafe one
+	r := httptest.NewRequest(http.MethodGet, "/eth/v1/beacon/headers/head", nil)
+	r.Header.Set("X-Beacon-Node", "optimistic")
+	upstreams, err := upstream.GetUpstreams(r)
+	if err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+	if len(upstreams) != 1 || upstreams[0].Dial != getDynamicTestHostFromURL(safe.URL) {
+		t.Errorf("expected only the safe node, got %v", upstreams)
+	}
+}
+
+func TestValidatorMode_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_health {
+		node a {
+			url http://localhost:5052
+			type beacon
+		}
+		validator_mode {
+			pin_header X-Validator-Node
+			latency_tolerance 15ms
+		}
+	}`)
+
+	var b BlockchainHealthUpstream
+	if err := b.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	want := ValidatorModeConfig{Enabled: true, PinHeader: "X-Validator-Node", LatencyTolerance: "15ms"}
+	if b.ValidatorMode != want {
+		t.Errorf("unexpected validator mode: %+v", b.ValidatorMode)
+	}
+	if err := b.validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+
+	b.ValidatorMode.LatencyTolerance = "fast"
+	if err := b.validate(); err == nil {
+		t.Error("expected an invalid latency tolerance to be rejected")
+	}
+}
@@ -0,0 +1,99 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// rpcCodeTransactionRejected is the EIP-1474 "transaction rejected" code
+const rpcCodeTransactionRejected = -32003
+
+// defaultBroadcastMethods are the transaction broadcast methods refused by
+// write protection unless Methods is set
+var defaultBroadcastMethods = []string{
+	"eth_sendRawTransaction",
+	"eth_sendTransaction",
+	"broadcast_tx_sync",
+	"broadcast_tx_async",
+	"broadcast_tx_commit",
+}
+
+// cosmosBroadcastPath is the Cosmos REST endpoint broadcasting transactions
+const cosmosBroadcastPath = "/cosmos/tx/v1beta1/txs"
+
+// WriteProtect refuses transaction broadcasts while a pool is degraded, so
+// users do not sign against lagging nodes reporting stale nonces or
+// balances. Reads are still served.
+type WriteProtect struct {
+	// Pool state name of the pool, as used by blockchain_pool_state
+	Pool string `json:"pool"`
+
+	// States in which broadcasts are refused; defaults to degraded and critical
+	States []PoolState `json:"states,omitempty"`
+
+	// Broadcast methods or wildcards; defaults to the EVM and CometBFT ones
+	Methods []string `json:"methods,omitempty"`
+}
+
+// validate checks the write protection settings
+func (p *WriteProtect) validate() error {
+	if p.Pool == "" {
+		return fmt.Errorf("write_protect requires a pool name")
+	}
+	for _, state := range p.States {
+		if !isValidPoolState(string(state)) {
+			return fmt.Errorf("write_protect: invalid pool state %q (must be healthy, degraded, chain_halted, critical or down)", state)
+		}
+	}
+	return validateMethodPatterns(p.Methods)
+}
+
+// activeState returns the pool state while broadcasts are refused
+func (p *WriteProtect) activeState() (PoolState, bool) {
+	live, ok := lookupChainState(p.Pool)
+	if !ok {
+		return "", false
+	}
+	current, ok := live.state()
+	if !ok {
+		return "", false
+	}
+	states := p.States
+	if len(states) == 0 {
+		states = []PoolState{PoolDegraded, PoolCritical}
+	}
+	for _, state := range states {
+		if state == current {
+			return current, true
+		}
+	}
+	return "", false
+}
+
+// isBroadcast reports whether a JSON-RPC method broadcasts a transaction
+func (p *WriteProtect) isBroadcast(method string) bool {
+	methods := p.Methods
+	if len(methods) == 0 {
+		methods = defaultBroadcastMethods
+	}
+	_, ok := matchRPCMethod(methods, method)
+	return ok
+}
+
+// isBroadcastRequest reports whether a request that is not JSON-RPC
+// broadcasts a transaction: CometBFT URI calls such as
+// GET /broadcast_tx_sync?tx=..., or a POST to the Cosmos REST txs endpoint
+func (p *WriteProtect) isBroadcastRequest(r *http.Request) bool {
+	if r.Method == http.MethodPost && strings.TrimSuffix(r.URL.Path, "/") == cosmosBroadcastPath {
+		return true
+	}
+	name := path.Base(r.URL.Path)
+	return name != "/" && name != "." && p.isBroadcast(name)
+}
+
+// refusal returns the message for a refused broadcast
+func (p *WriteProtect) refusal(state PoolState) string {
+	return fmt.Sprintf("chain %s is %s: transaction broadcasts are disabled until it recovers, reads are still served", p.Pool, state)
+}
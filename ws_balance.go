@@ -0,0 +1,41 @@
+package blockchain_health
+
+import (
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+)
+
+// leastLoadedWebSocketUpstreams keeps only the upstreams with the fewest
+// active WebSocket sessions, so long-lived connections spread evenly instead
+// of piling onto whichever node the load balancing policy favours. Ties are
+// kept for the policy to choose from. Sessions are counted by the
+// blockchain_ws_drain handler; without it every node counts as idle.
+func (b *BlockchainHealthUpstream) leastLoadedWebSocketUpstreams(upstreams []*reverseproxy.Upstream, infos []selectionInfo) ([]*reverseproxy.Upstream, []selectionInfo) {
+	if len(upstreams) < 2 {
+		return upstreams, infos
+	}
+
+	counts := make([]int, len(upstreams))
+	least := -1
+	for i, upstream := range upstreams {
+		counts[i] = wsSessions.count(upstream.Dial)
+		if least < 0 || counts[i] < least {
+			least = counts[i]
+		}
+	}
+
+	var kept []*reverseproxy.Upstream
+	var keptInfos []selectionInfo
+	for i, upstream := range upstreams {
+		if counts[i] > least {
+			if b.metrics != nil && i < len(infos) {
+				b.metrics.upstreamsExcluded.WithLabelValues(infos[i].name, infos[i].serviceType, "websocket_load").Inc()
+			}
+			continue
+		}
+		kept = append(kept, upstream)
+		if i < len(infos) {
+			keptInfos = append(keptInfos, infos[i])
+		}
+	}
+	return kept, keptInfos
+}
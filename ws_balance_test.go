@@ -0,0 +1,49 @@
+package blockchain_health
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+func TestWebSocketRequestsPreferLeastLoadedNode(t *testing.T) {
+	nodes := []NodeConfig{
+		{Name: "ws-busy", URL: "ws://busy.example:8546", Type: NodeTypeEVM, Weight: 1, Metadata: map[string]string{"service_type": "websocket"}},
+		{Name: "ws-idle", URL: "ws://idle.example:8546", Type: NodeTypeEVM, Weight: 1, Metadata: map[string]string{"service_type": "websocket"}},
+	}
+	upstream := createTestUpstream(nodes, zaptest.NewLogger(t))
+	for _, node := range nodes {
+		upstream.cache.Set(node.key(), &NodeHealth{Name: node.Name, URL: node.URL, Healthy: true, LastCheck: time.Now()})
+	}
+
+	server, client := net.Pipe()
+	defer client.Close()
+	busy := wsSessions.track("busy.example:8546", server, defaultDrainCloseCode, time.Minute)
+	defer busy.Close()
+
+	r := httptest.NewRequest(http.MethodGet, "http://127.0.0.1/", nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+
+	upstreams, err := upstream.GetUpstreams(r)
+	if err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+	if len(upstreams) != 1 || upstreams[0].Dial != "idle.example:8546" {
+		t.Fatalf("expected only the idle node, got %+v", upstreams)
+	}
+
+	// Once the load evens out both nodes are eligible again
+	_ = busy.Close()
+	upstreams, err = upstream.GetUpstreams(r)
+	if err != nil {
+		t.Fatalf("GetUpstreams failed: %v", err)
+	}
+	if len(upstreams) != 2 {
+		t.Fatalf("expected both nodes after the session closed, got %d", len(upstreams))
+	}
+}
@@ -0,0 +1,369 @@
+package blockchain_health
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"go.uber.org/zap"
+)
+
+// WebSocket draining defaults
+const (
+	defaultDrainCloseCode = 1012 // Service Restart: clients should reconnect
+	defaultDrainGrace     = 5 * time.Second
+
+	// drainCloseReason is sent with the close frame
+	drainCloseReason = "upstream unhealthy"
+)
+
+// WebSocketDrain is a middleware placed in front of reverse_proxy that tracks
+// proxied WebSocket sessions by upstream. When the health checker sees a node
+// turn unhealthy, its sessions are sent a close frame with CloseCode and the
+// connection is dropped after Grace, so clients reconnect to healthy nodes.
+type WebSocketDrain struct {
+	CloseCode int            `json:"close_code,omitempty"`
+	Grace     caddy.Duration `json:"grace,omitempty"`
+}
+
+func init() {
+	caddy.RegisterModule(&WebSocketDrain{})
+}
+
+// CaddyModule returns the Caddy module information.
+func (*WebSocketDrain) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.blockchain_ws_drain",
+		New: func() caddy.Module { return new(WebSocketDrain) },
+	}
+}
+
+// Provision applies defaults
+func (h *WebSocketDrain) Provision(ctx caddy.Context) error {
+	if h.CloseCode == 0 {
+		h.CloseCode = defaultDrainCloseCode
+	}
+	if h.Grace == 0 {
+		h.Grace = caddy.Duration(defaultDrainGrace)
+	}
+	return nil
+}
+
+// Validate checks configuration correctness
+func (h *WebSocketDrain) Validate() error {
+	// 1000-4999 are the codes an endpoint may send (RFC 6455 section 7.4)
+	if h.CloseCode != 0 && (h.CloseCode < 1000 || h.CloseCode > 4999) {
+		return fmt.Errorf("close_code must be between 1000 and 4999")
+	}
+	if h.Grace < 0 {
+		return fmt.Errorf("grace must not be negative")
+	}
+	return nil
+}
+
+// ServeHTTP tracks WebSocket upgrades and passes everything else through
+func (h *WebSocketDrain) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	if !strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade") ||
+		!strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return next.ServeHTTP(w, r)
+	}
+
+	return next.ServeHTTP(&drainTrackingWriter{
+		ResponseWriterWrapper: &caddyhttp.ResponseWriterWrapper{ResponseWriter: w},
+		request:               r,
+		drain:                 h,
+	}, r)
+}
+
+// drainTrackingWriter registers the hijacked client connection under the
+// upstream reverse_proxy selected for it
+type drainTrackingWriter struct {
+	*caddyhttp.ResponseWriterWrapper
+	request *http.Request
+	drain   *WebSocketDrain
+}
+
+// Hijack implements http.Hijacker
+func (w *drainTrackingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	upstream := ""
+	if repl, ok := w.request.Context().Value(caddy.ReplacerCtxKey).(*caddy.Replacer); ok {
+		upstream = repl.ReplaceAll("{http.reverse_proxy.upstream.hostport}", "")
+	}
+	if upstream == "" {
+		return conn, brw, nil
+	}
+
+	return wsSessions.track(upstream, conn, w.drain.CloseCode, time.Duration(w.drain.Grace)), brw, nil
+}
+
+// wsSession is a live proxied WebSocket session that can be drained when its
+// upstream turns unhealthy
+type wsSession interface {
+	drain()
+}
+
+// wsSessionRegistry holds the live proxied WebSocket sessions per upstream
+// address. It is process-wide so the health checker can drain sessions that
+// any WebSocketDrain or WebSocketReplay handler tracked.
+type wsSessionRegistry struct {
+	mutex    sync.Mutex
+	sessions map[string]map[wsSession]struct{}
+}
+
+var wsSessions = &wsSessionRegistry{sessions: make(map[string]map[wsSession]struct{})}
+
+// track wraps a hijacked connection and registers it under the upstream
+func (reg *wsSessionRegistry) track(upstream string, conn net.Conn, closeCode int, grace time.Duration) *drainableConn {
+	dc := &drainableConn{Conn: conn, closeCode: closeCode, grace: grace}
+	dc.onClose = func() { reg.remove(upstream, dc) }
+	reg.add(upstream, dc)
+	return dc
+}
+
+// add registers a session under the upstream
+func (reg *wsSessionRegistry) add(upstream string, session wsSession) {
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+	if reg.sessions[upstream] == nil {
+		reg.sessions[upstream] = make(map[wsSession]struct{})
+	}
+	reg.sessions[upstream][session] = struct{}{}
+}
+
+// remove forgets a closed session
+func (reg *wsSessionRegistry) remove(upstream string, session wsSession) {
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+	delete(reg.sessions[upstream], session)
+	if len(reg.sessions[upstream]) == 0 {
+		delete(reg.sessions, upstream)
+	}
+}
+
+// count returns how many live sessions are proxied to the upstream
+func (reg *wsSessionRegistry) count(upstream string) int {
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+	return len(reg.sessions[upstream])
+}
+
+// drain starts closing every session proxied to the upstream and returns how
+// many were affected
+func (reg *wsSessionRegistry) drain(upstream string) int {
+	reg.mutex.Lock()
+	sessions := make([]wsSession, 0, len(reg.sessions[upstream]))
+	for session := range reg.sessions[upstream] {
+		sessions = append(sessions, session)
+	}
+	reg.mutex.Unlock()
+
+	for _, session := range sessions {
+		session.drain()
+	}
+	return len(sessions)
+}
+
+// drainableConn is a hijacked client connection that can be closed with a
+// WebSocket close frame. Writes from the upstream are tracked frame by frame
+// so the close frame is never injected in the middle of a frame.
+type drainableConn struct {
+	net.Conn
+	closeCode int
+	grace     time.Duration
+	onClose   func()
+
+	mutex     sync.Mutex
+	frames    wsFrameTracker
+	draining  bool
+	closeSent bool
+	closeOnce sync.Once
+}
+
+// Write forwards upstream data, switching to the close frame once draining
+func (c *drainableConn) Write(p []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Anything after our close frame would violate the protocol; drop it
+	if c.closeSent {
+		return len(p), nil
+	}
+
+	if !c.draining {
+		n, err := c.Conn.Write(p)
+		c.frames.advance(p[:n], false)
+		return n, err
+	}
+
+	// Finish the frame in flight, then close
+	end := c.frames.advance(p, true)
+	if end > 0 {
+		if _, err := c.Conn.Write(p[:end]); err != nil {
+			return 0, err
+		}
+	}
+	if c.frames.atBoundary() {
+		c.sendClose()
+	}
+	return len(p), nil
+}
+
+// Close closes the connection and unregisters the session
+func (c *drainableConn) Close() error {
+	c.closeOnce.Do(func() {
+		if c.onClose != nil {
+			c.onClose()
+		}
+	})
+	return c.Conn.Close()
+}
+
+// drain sends the close frame at the next frame boundary and drops the
+// connection after the grace period
+func (c *drainableConn) drain() {
+	c.mutex.Lock()
+	if c.draining {
+		c.mutex.Unlock()
+		return
+	}
+	c.draining = true
+	if c.frames.atBoundary() {
+		c.sendClose()
+	}
+	c.mutex.Unlock()
+
+	time.AfterFunc(c.grace, func() { _ = c.Close() })
+}
+
+// sendClose writes the close frame. Callers must hold c.mutex.
+func (c *drainableConn) sendClose() {
+	payload := make([]byte, 2, 2+len(drainCloseReason))
+	binary.BigEndian.PutUint16(payload, uint16(c.closeCode))
+	payload = append(payload, drainCloseReason...)
+
+	// Server frames are unmasked: FIN + close opcode, then the payload length
+	frame := append([]byte{0x88, byte(len(payload))}, payload...)
+	_, _ = c.Conn.Write(frame)
+	c.closeSent = true
+}
+
+// wsFrameTracker follows WebSocket frame boundaries in a server-to-client
+// byte stream
+type wsFrameTracker struct {
+	header    []byte
+	remaining uint64
+}
+
+// atBoundary reports whether the stream is between frames
+func (t *wsFrameTracker) atBoundary() bool {
+	return len(t.header) == 0 && t.remaining == 0
+}
+
+// advance consumes p and returns how many bytes were consumed. With
+// stopAtBoundary it stops at the first frame boundary.
+func (t *wsFrameTracker) advance(p []byte, stopAtBoundary bool) int {
+	i := 0
+	for i < len(p) {
+		if stopAtBoundary && t.atBoundary() {
+			return i
+		}
+
+		if t.remaining > 0 {
+			n := uint64(len(p) - i)
+			if n > t.remaining {
+				n = t.remaining
+			}
+			i += int(n)
+			t.remaining -= n
+			continue
+		}
+
+		t.header = append(t.header, p[i])
+		i++
+		if size := wsHeaderSize(t.header); size > 0 && len(t.header) == size {
+			t.remaining = wsPayloadLength(t.header)
+			t.header = t.header[:0]
+		}
+	}
+	return i
+}
+
+// wsHeaderSize returns the full header size once it is known, or 0
+func wsHeaderSize(header []byte) int {
+	if len(header) < 2 {
+		return 0
+	}
+	size := 2
+	switch header[1] & 0x7f {
+	case 126:
+		size += 2
+	case 127:
+		size += 8
+	}
+	if header[1]&0x80 != 0 {
+		size += 4 // masking key
+	}
+	return size
+}
+
+// wsPayloadLength decodes the payload length from a complete header
+func wsPayloadLength(header []byte) uint64 {
+	switch length := header[1] & 0x7f; length {
+	case 126:
+		return uint64(binary.BigEndian.Uint16(header[2:4]))
+	case 127:
+		return binary.BigEndian.Uint64(header[2:10])
+	default:
+		return uint64(length)
+	}
+}
+
+// drainUnhealthySessions drains the WebSocket sessions of the changed nodes
+// that turned unhealthy. Dry-run mode never drains.
+func (h *HealthChecker) drainUnhealthySessions(changed []*NodeHealth) {
+	if !h.config.FailureHandling.enforceExclusions() {
+		return
+	}
+
+	for _, health := range changed {
+		if health.Healthy {
+			continue
+		}
+		dial := nodeDial(health.URL)
+		if dial == "" {
+			continue
+		}
+		drained := wsSessions.drain(dial)
+		if drained == 0 {
+			continue
+		}
+
+		h.logger.Info("draining WebSocket sessions of unhealthy node",
+			zap.String("node", health.Name),
+			zap.Int("sessions", drained),
+			zap.String("last_error", health.LastError))
+		if h.metrics != nil {
+			h.metrics.wsSessionsDrained.WithLabelValues(health.Name).Add(float64(drained))
+		}
+	}
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*WebSocketDrain)(nil)
+	_ caddy.Validator             = (*WebSocketDrain)(nil)
+	_ caddyhttp.MiddlewareHandler = (*WebSocketDrain)(nil)
+	_ http.Hijacker               = (*drainTrackingWriter)(nil)
+)
@@ -0,0 +1,66 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	httpcaddyfile "github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+func init() {
+	// Register Caddyfile directive for this handler
+	httpcaddyfile.RegisterHandlerDirective("blockchain_ws_drain", parseWebSocketDrainCaddyfile)
+	httpcaddyfile.RegisterDirectiveOrder("blockchain_ws_drain", httpcaddyfile.Before, "reverse_proxy")
+}
+
+func parseWebSocketDrainCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	wd := new(WebSocketDrain)
+	if err := wd.UnmarshalCaddyfile(h.Dispenser); err != nil {
+		return nil, err
+	}
+	return wd, nil
+}
+
+// UnmarshalCaddyfile implements caddyfile.Unmarshaler for blockchain_ws_drain
+func (h *WebSocketDrain) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	for d.Next() {
+		for d.NextBlock(0) {
+			switch d.Val() {
+			case "close_code":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				code, err := strconv.Atoi(d.Val())
+				if err != nil {
+					return d.Errf("invalid close_code: %v", err)
+				}
+				h.CloseCode = code
+
+			case "grace":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				dur, err := time.ParseDuration(d.Val())
+				if err != nil {
+					return d.Errf("invalid grace: %v", err)
+				}
+				h.Grace = caddy.Duration(dur)
+
+			default:
+				return d.Errf("unknown directive: %s", d.Val())
+			}
+		}
+	}
+
+	if err := h.Validate(); err != nil {
+		return fmt.Errorf("blockchain_ws_drain validation: %w", err)
+	}
+	return nil
+}
+
+// Interface guard
+var _ caddyfile.Unmarshaler = (*WebSocketDrain)(nil)
@@ -0,0 +1,141 @@
+package blockchain_health
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"go.uber.org/zap/zaptest"
+)
+
+// wsFrame builds an unmasked server frame with the given payload
+func wsFrame(opcode byte, payload []byte) []byte {
+	frame := []byte{0x80 | opcode}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, 126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(payload)))
+	default:
+		frame = append(frame, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(len(payload)))
+	}
+	return append(frame, payload...)
+}
+
+func TestWSFrameTracker_Boundaries(t *testing.T) {
+	stream := append(wsFrame(0x1, []byte("hello")), wsFrame(0x2, bytes.Repeat([]byte{1}, 300))...)
+	stream = append(stream, wsFrame(0x9, nil)...)
+
+	// Feed the stream in awkward chunks and check the boundary at the end
+	var tracker wsFrameTracker
+	for i := 0; i < len(stream); i += 7 {
+		end := i + 7
+		if end > len(stream) {
+			end = len(stream)
+		}
+		tracker.advance(stream[i:end], false)
+	}
+	if !tracker.atBoundary() {
+		t.Fatal("expected tracker to end on a frame boundary")
+	}
+
+	// Stopping at a boundary mid-stream lands after the first frame
+	tracker = wsFrameTracker{}
+	tracker.advance(stream[:3], false)
+	if got := tracker.advance(stream[3:], true); got != len(wsFrame(0x1, []byte("hello")))-3 {
+		t.Errorf("expected to stop after the first frame, consumed %d", got)
+	}
+}
+
+func TestDrainableConn_ClosesAtFrameBoundary(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	dc := wsSessions.track("node.example:8546", server, 4000, 50*time.Millisecond)
+
+	received := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(client)
+		received <- data
+	}()
+
+	frame := wsFrame(0x1, []byte(`{"jsonrpc":"2.0","result":"0x1"}`))
+
+	// Half a frame is in flight when the node turns unhealthy
+	if _, err := dc.Write(frame[:10]); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if n := wsSessions.drain("node.example:8546"); n != 1 {
+		t.Fatalf("expected 1 drained session, got %d", n)
+	}
+	if _, err := dc.Write(append(frame[10:], wsFrame(0x1, []byte("dropped"))...)); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	var data []byte
+	select {
+	case data = <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection was not closed after the grace period")
+	}
+
+	closeFrame := data[len(frame):]
+	if !bytes.Equal(data[:len(frame)], frame) {
+		t.Fatal("expected the in-flight frame to be delivered intact")
+	}
+	if len(closeFrame) < 4 || closeFrame[0] != 0x88 || binary.BigEndian.Uint16(closeFrame[2:4]) != 4000 {
+		t.Fatalf("expected a close frame with code 4000, got %x", closeFrame)
+	}
+	if n := wsSessions.drain("node.example:8546"); n != 0 {
+		t.Errorf("expected closed session to be unregistered, got %d", n)
+	}
+}
+
+func TestHealthChecker_DrainsOnUnhealthyTransition(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go func() { _, _ = io.Copy(io.Discard, client) }()
+
+	config := &Config{}
+	checker := NewHealthChecker(config, NewHealthCache(time.Second), nil, zaptest.NewLogger(t))
+	dc := wsSessions.track("ws.example:8546", server, defaultDrainCloseCode, time.Minute)
+	defer dc.Close()
+
+	checker.drainUnhealthySessions(checker.healthChanges([]*NodeHealth{{Name: "ws", URL: "ws://ws.example:8546", Healthy: true}}))
+	checker.drainUnhealthySessions(checker.healthChanges([]*NodeHealth{{Name: "ws", URL: "ws://ws.example:8546", Healthy: false}}))
+
+	dc.mutex.Lock()
+	draining := dc.draining
+	dc.mutex.Unlock()
+	if !draining {
+		t.Error("expected the session to be draining after the node turned unhealthy")
+	}
+}
+
+func TestWebSocketDrain_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_ws_drain {
+		close_code 4001
+		grace 2s
+	}`)
+
+	var h WebSocketDrain
+	if err := h.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if h.CloseCode != 4001 || time.Duration(h.Grace) != 2*time.Second {
+		t.Errorf("unexpected config: %+v", h)
+	}
+
+	d = caddyfile.NewTestDispenser(`blockchain_ws_drain {
+		close_code 99
+	}`)
+	if err := new(WebSocketDrain).UnmarshalCaddyfile(d); err == nil {
+		t.Error("expected an invalid close code to be rejected")
+	}
+}
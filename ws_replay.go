@@ -0,0 +1,481 @@
+package blockchain_health
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// WebSocket replay defaults
+const (
+	defaultReplayReconnectAttempts = 3
+	defaultReplayReconnectDelay    = 500 * time.Millisecond
+
+	// replayIDPrefix marks the subscribe requests sent on the client's behalf
+	replayIDPrefix = "blockchain_health_replay_"
+)
+
+// WebSocketReplay is a stateful WebSocket proxy for JSON-RPC subscriptions.
+// It remembers each client's eth_subscribe calls and, when the upstream
+// connection is lost (for example because the node was drained after turning
+// unhealthy), reconnects to another node from Upstreams and replays them. The
+// new subscription IDs are rewritten to the ones the client already knows, so
+// subscribers keep receiving notifications without reconnecting.
+//
+// Requests other than WebSocket upgrades are passed to the next handler.
+type WebSocketReplay struct {
+	// UpstreamsRaw selects the nodes to proxy to, typically blockchain_health
+	UpstreamsRaw      json.RawMessage `json:"upstreams,omitempty" caddy:"namespace=http.reverse_proxy.upstreams inline_key=source"`
+	TLS               bool            `json:"tls,omitempty"`
+	ReconnectAttempts int             `json:"reconnect_attempts,omitempty"`
+	ReconnectDelay    caddy.Duration  `json:"reconnect_delay,omitempty"`
+
+	upstreams reverseproxy.UpstreamSource
+	logger    *zap.Logger
+}
+
+func init() {
+	caddy.RegisterModule(&WebSocketReplay{})
+}
+
+// CaddyModule returns the Caddy module information.
+func (*WebSocketReplay) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.blockchain_ws_replay",
+		New: func() caddy.Module { return new(WebSocketReplay) },
+	}
+}
+
+// Provision loads the upstream source and applies defaults
+func (h *WebSocketReplay) Provision(ctx caddy.Context) error {
+	h.logger = ctx.Logger()
+	if h.ReconnectAttempts == 0 {
+		h.ReconnectAttempts = defaultReplayReconnectAttempts
+	}
+	if h.ReconnectDelay == 0 {
+		h.ReconnectDelay = caddy.Duration(defaultReplayReconnectDelay)
+	}
+
+	if h.UpstreamsRaw == nil {
+		return fmt.Errorf("upstreams source is required")
+	}
+	mod, err := ctx.LoadModule(h, "UpstreamsRaw")
+	if err != nil {
+		return fmt.Errorf("loading upstream source module: %v", err)
+	}
+	source, ok := mod.(reverseproxy.UpstreamSource)
+	if !ok {
+		return fmt.Errorf("module %T is not an upstream source", mod)
+	}
+	h.upstreams = source
+	return nil
+}
+
+// Validate checks configuration correctness
+func (h *WebSocketReplay) Validate() error {
+	if h.ReconnectAttempts < 0 {
+		return fmt.Errorf("reconnect_attempts must not be negative")
+	}
+	if h.ReconnectDelay < 0 {
+		return fmt.Errorf("reconnect_delay must not be negative")
+	}
+	return nil
+}
+
+// ServeHTTP proxies WebSocket upgrades and passes everything else through
+func (h *WebSocketReplay) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	if !websocket.IsWebSocketUpgrade(r) {
+		return next.ServeHTTP(w, r)
+	}
+
+	upstream, dial, err := h.dialUpstream(r.Context(), r, "")
+	if err != nil {
+		return caddyhttp.Error(http.StatusBadGateway, err)
+	}
+
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	client, err := upgrader.Upgrade(hijackableWriter{w}, r, nil)
+	if err != nil {
+		// Upgrade has already answered the client
+		_ = upstream.Close()
+		return nil
+	}
+
+	session := &replaySession{
+		handler:    h,
+		request:    r,
+		client:     client,
+		upstream:   upstream,
+		dial:       dial,
+		subs:       make(map[string]*replayedSubscription),
+		byUpstream: make(map[string]string),
+		pending:    make(map[string]json.RawMessage),
+		replays:    make(map[string]string),
+	}
+	session.run()
+	return nil
+}
+
+// dialUpstream connects to one of the upstreams selected for the request,
+// avoiding exclude when another node is available
+func (h *WebSocketReplay) dialUpstream(ctx context.Context, r *http.Request, exclude string) (*websocket.Conn, string, error) {
+	upstreams, err := h.upstreams.GetUpstreams(r)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var candidates []string
+	for _, upstream := range upstreams {
+		if upstream.Dial != exclude {
+			candidates = append(candidates, upstream.Dial)
+		}
+	}
+	if len(candidates) == 0 {
+		if len(upstreams) == 0 {
+			return nil, "", fmt.Errorf("no upstreams available")
+		}
+		candidates = []string{upstreams[0].Dial}
+	}
+	dial := candidates[rand.Intn(len(candidates))]
+
+	target := url.URL{Scheme: "ws", Host: dial, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+	if h.TLS {
+		target.Scheme = "wss"
+	}
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, target.String(), nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("dialing %s: %w", dial, err)
+	}
+	return conn, dial, nil
+}
+
+// hijackableWriter exposes the hijacker of a wrapped Caddy response writer,
+// which the WebSocket upgrader looks up with a type assertion
+type hijackableWriter struct {
+	http.ResponseWriter
+}
+
+// Hijack implements http.Hijacker
+func (w hijackableWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// replayedSubscription is a client subscription and its current upstream ID
+type replayedSubscription struct {
+	params     json.RawMessage
+	upstreamID string
+}
+
+// replaySession proxies one client connection and replays its subscriptions
+// after failover. Subscriptions are keyed by the ID the client was given.
+type replaySession struct {
+	handler *WebSocketReplay
+	request *http.Request
+	client  *websocket.Conn
+
+	mutex      sync.Mutex
+	upstream   *websocket.Conn
+	dial       string
+	closed     bool
+	subs       map[string]*replayedSubscription // client ID -> subscription
+	byUpstream map[string]string                // upstream ID -> client ID
+	pending    map[string]json.RawMessage       // client request ID -> eth_subscribe params
+	replays    map[string]string                // replay request ID -> client ID
+	nextReplay int
+}
+
+// rpcMessage holds the JSON-RPC fields the session needs to inspect
+type rpcMessage struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// run proxies until the client disconnects or no upstream can be reached
+func (s *replaySession) run() {
+	wsSessions.add(s.dial, s)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.proxyUpstream()
+	}()
+
+	s.proxyClient()
+
+	s.mutex.Lock()
+	s.closed = true
+	wsSessions.remove(s.dial, s)
+	upstream := s.upstream
+	s.mutex.Unlock()
+
+	_ = upstream.Close()
+	<-done
+	_ = s.client.Close()
+}
+
+// drain drops the upstream connection so the session fails over
+func (s *replaySession) drain() {
+	s.mutex.Lock()
+	upstream := s.upstream
+	s.mutex.Unlock()
+	_ = upstream.Close()
+}
+
+// proxyClient forwards client messages, tracking subscribe and unsubscribe
+func (s *replaySession) proxyClient() {
+	for {
+		messageType, data, err := s.client.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		s.mutex.Lock()
+		if messageType == websocket.TextMessage {
+			data = s.trackClientMessage(data)
+		}
+		upstream := s.upstream
+		err = upstream.WriteMessage(messageType, data)
+		s.mutex.Unlock()
+
+		if err != nil {
+			// The upstream reader notices the broken connection and fails over
+			s.handler.logger.Debug("failed to forward WebSocket message", zap.String("upstream", s.dial), zap.Error(err))
+		}
+	}
+}
+
+// trackClientMessage records subscriptions and translates unsubscribe IDs.
+// Callers must hold s.mutex.
+func (s *replaySession) trackClientMessage(data []byte) []byte {
+	var msg rpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return data
+	}
+
+	switch msg.Method {
+	case "eth_subscribe":
+		if len(msg.ID) > 0 {
+			s.pending[string(msg.ID)] = msg.Params
+		}
+
+	case "eth_unsubscribe":
+		var params []string
+		if err := json.Unmarshal(msg.Params, &params); err != nil || len(params) == 0 {
+			return data
+		}
+		sub, ok := s.subs[params[0]]
+		if !ok {
+			return data
+		}
+		delete(s.subs, params[0])
+		delete(s.byUpstream, sub.upstreamID)
+		if sub.upstreamID != params[0] {
+			if rewritten, err := rewriteJSON(data, "params", []string{sub.upstreamID}); err == nil {
+				return rewritten
+			}
+		}
+	}
+	return data
+}
+
+// proxyUpstream forwards upstream messages and fails over when the upstream
+// connection is lost
+func (s *replaySession) proxyUpstream() {
+	for {
+		s.mutex.Lock()
+		upstream := s.upstream
+		s.mutex.Unlock()
+
+		messageType, data, err := upstream.ReadMessage()
+		if err != nil {
+			if !s.failover() {
+				_ = s.client.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(defaultDrainCloseCode, drainCloseReason),
+					time.Now().Add(time.Second))
+				_ = s.client.Close()
+				return
+			}
+			continue
+		}
+
+		if messageType == websocket.TextMessage {
+			s.mutex.Lock()
+			data = s.trackUpstreamMessage(data)
+			s.mutex.Unlock()
+			if data == nil {
+				continue
+			}
+		}
+		if err := s.client.WriteMessage(messageType, data); err != nil {
+			return
+		}
+	}
+}
+
+// trackUpstreamMessage records subscription IDs and rewrites notifications to
+// the ID the client knows. It returns nil for replies to replayed subscribe
+// requests, which the client never sent. Callers must hold s.mutex.
+func (s *replaySession) trackUpstreamMessage(data []byte) []byte {
+	var msg rpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return data
+	}
+
+	if len(msg.ID) > 0 {
+		var upstreamID string
+		if err := json.Unmarshal(msg.Result, &upstreamID); err != nil {
+			upstreamID = ""
+		}
+
+		if clientID, ok := s.replays[string(msg.ID)]; ok {
+			delete(s.replays, string(msg.ID))
+			if sub, ok := s.subs[clientID]; ok && upstreamID != "" {
+				sub.upstreamID = upstreamID
+				s.byUpstream[upstreamID] = clientID
+			}
+			return nil
+		}
+
+		if params, ok := s.pending[string(msg.ID)]; ok {
+			delete(s.pending, string(msg.ID))
+			if upstreamID != "" {
+				s.subs[upstreamID] = &replayedSubscription{params: params, upstreamID: upstreamID}
+				s.byUpstream[upstreamID] = upstreamID
+			}
+		}
+		return data
+	}
+
+	if msg.Method != "eth_subscription" {
+		return data
+	}
+	var params struct {
+		Subscription string `json:"subscription"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return data
+	}
+	clientID, ok := s.byUpstream[params.Subscription]
+	if !ok || clientID == params.Subscription {
+		return data
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(msg.Params, &fields); err != nil {
+		return data
+	}
+	fields["subscription"], _ = json.Marshal(clientID)
+	if rewritten, err := rewriteJSON(data, "params", fields); err == nil {
+		return rewritten
+	}
+	return data
+}
+
+// failover connects to another upstream and replays the client's
+// subscriptions. It reports whether the session can continue.
+func (s *replaySession) failover() bool {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return false
+	}
+	failed := s.dial
+	s.mutex.Unlock()
+
+	ctx := s.request.Context()
+	delay := time.Duration(s.handler.ReconnectDelay)
+	for attempt := 0; attempt < s.handler.ReconnectAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return false
+			case <-time.After(delay):
+			}
+		}
+
+		conn, dial, err := s.handler.dialUpstream(ctx, s.request, failed)
+		if err != nil {
+			s.handler.logger.Debug("WebSocket failover attempt failed",
+				zap.String("failed_upstream", failed),
+				zap.Int("attempt", attempt+1),
+				zap.Error(err))
+			continue
+		}
+
+		s.mutex.Lock()
+		if s.closed {
+			s.mutex.Unlock()
+			_ = conn.Close()
+			return false
+		}
+		wsSessions.remove(s.dial, s)
+		s.upstream = conn
+		s.dial = dial
+		wsSessions.add(dial, s)
+
+		// Requests in flight on the old connection are lost
+		s.pending = make(map[string]json.RawMessage)
+		s.replays = make(map[string]string)
+		s.byUpstream = make(map[string]string)
+		replayed := 0
+		for clientID, sub := range s.subs {
+			s.nextReplay++
+			id, _ := json.Marshal(fmt.Sprintf("%s%d", replayIDPrefix, s.nextReplay))
+			request, _ := json.Marshal(map[string]json.RawMessage{
+				"jsonrpc": json.RawMessage(`"2.0"`),
+				"id":      id,
+				"method":  json.RawMessage(`"eth_subscribe"`),
+				"params":  sub.params,
+			})
+			if err := conn.WriteMessage(websocket.TextMessage, request); err != nil {
+				break
+			}
+			s.replays[string(id)] = clientID
+			replayed++
+		}
+		s.mutex.Unlock()
+
+		s.handler.logger.Info("WebSocket session failed over",
+			zap.String("from", failed),
+			zap.String("to", dial),
+			zap.Int("subscriptions_replayed", replayed))
+		return true
+	}
+	return false
+}
+
+// rewriteJSON replaces one top-level field of a JSON object
+func rewriteJSON(data []byte, field string, value any) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	fields[field] = encoded
+	return json.Marshal(fields)
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*WebSocketReplay)(nil)
+	_ caddy.Validator             = (*WebSocketReplay)(nil)
+	_ caddyhttp.MiddlewareHandler = (*WebSocketReplay)(nil)
+	_ http.Hijacker               = hijackableWriter{}
+)
@@ -0,0 +1,93 @@
+package blockchain_health
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	httpcaddyfile "github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+)
+
+func init() {
+	// Register Caddyfile directive for this handler
+	httpcaddyfile.RegisterHandlerDirective("blockchain_ws_replay", parseWebSocketReplayCaddyfile)
+	httpcaddyfile.RegisterDirectiveOrder("blockchain_ws_replay", httpcaddyfile.Before, "reverse_proxy")
+}
+
+func parseWebSocketReplayCaddyfile(h httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	wr := new(WebSocketReplay)
+	if err := wr.UnmarshalCaddyfile(h.Dispenser); err != nil {
+		return nil, err
+	}
+	return wr, nil
+}
+
+// UnmarshalCaddyfile implements caddyfile.Unmarshaler for blockchain_ws_replay
+func (h *WebSocketReplay) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	for d.Next() {
+		for d.NextBlock(0) {
+			switch d.Val() {
+			case "dynamic":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				if h.UpstreamsRaw != nil {
+					return d.Err("dynamic upstreams already specified")
+				}
+				dynModule := d.Val()
+				modID := "http.reverse_proxy.upstreams." + dynModule
+				unm, err := caddyfile.UnmarshalModule(d, modID)
+				if err != nil {
+					return err
+				}
+				source, ok := unm.(reverseproxy.UpstreamSource)
+				if !ok {
+					return d.Errf("module %s (%T) is not an UpstreamSource", modID, unm)
+				}
+				h.UpstreamsRaw = caddyconfig.JSONModuleObject(source, "source", dynModule, nil)
+
+			case "tls":
+				h.TLS = true
+
+			case "reconnect_attempts":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				attempts, err := strconv.Atoi(d.Val())
+				if err != nil {
+					return d.Errf("invalid reconnect_attempts: %v", err)
+				}
+				h.ReconnectAttempts = attempts
+
+			case "reconnect_delay":
+				if !d.NextArg() {
+					return d.ArgErr()
+				}
+				dur, err := time.ParseDuration(d.Val())
+				if err != nil {
+					return d.Errf("invalid reconnect_delay: %v", err)
+				}
+				h.ReconnectDelay = caddy.Duration(dur)
+
+			default:
+				return d.Errf("unknown directive: %s", d.Val())
+			}
+		}
+	}
+
+	if h.UpstreamsRaw == nil {
+		return fmt.Errorf("blockchain_ws_replay requires a dynamic upstream source")
+	}
+	if err := h.Validate(); err != nil {
+		return fmt.Errorf("blockchain_ws_replay validation: %w", err)
+	}
+	return nil
+}
+
+// Interface guard
+var _ caddyfile.Unmarshaler = (*WebSocketReplay)(nil)
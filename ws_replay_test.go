@@ -0,0 +1,156 @@
+package blockchain_health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap/zaptest"
+)
+
+// upstreamSourceFunc adapts a function to reverseproxy.UpstreamSource
+type upstreamSourceFunc func(*http.Request) ([]*reverseproxy.Upstream, error)
+
+func (f upstreamSourceFunc) GetUpstreams(r *http.Request) ([]*reverseproxy.Upstream, error) {
+	return f(r)
+}
+
+// createSubscriptionServer serves eth_subscribe with the given subscription ID
+// and sends one notification. With closeAfterNotify the connection is dropped
+// afterwards, as a node going away would.
+func createSubscriptionServer(t *testing.T, subscriptionID, payload string, closeAfterNotify bool) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var req rpcMessage
+			if err := json.Unmarshal(data, &req); err != nil || req.Method != "eth_subscribe" {
+				continue
+			}
+
+			response := `{"jsonrpc":"2.0","id":` + string(req.ID) + `,"result":"` + subscriptionID + `"}`
+			notification := `{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"` + subscriptionID + `","result":"` + payload + `"}}`
+			_ = conn.WriteMessage(websocket.TextMessage, []byte(response))
+			_ = conn.WriteMessage(websocket.TextMessage, []byte(notification))
+			if closeAfterNotify {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWebSocketReplay_ReplaysSubscriptionsOnFailover(t *testing.T) {
+	failing := createSubscriptionServer(t, "0xa", "from-a", true)
+	healthy := createSubscriptionServer(t, "0xb", "from-b", false)
+
+	failingNode := &reverseproxy.Upstream{Dial: strings.TrimPrefix(failing.URL, "http://")}
+	healthyNode := &reverseproxy.Upstream{Dial: strings.TrimPrefix(healthy.URL, "http://")}
+
+	// The first dial lands on the failing node; failover finds both
+	var dials atomic.Int32
+	h := &WebSocketReplay{
+		ReconnectAttempts: 3,
+		ReconnectDelay:    caddy.Duration(10 * time.Millisecond),
+		upstreams: upstreamSourceFunc(func(*http.Request) ([]*reverseproxy.Upstream, error) {
+			if dials.Add(1) == 1 {
+				return []*reverseproxy.Upstream{failingNode}, nil
+			}
+			return []*reverseproxy.Upstream{failingNode, healthyNode}, nil
+		}),
+		logger: zaptest.NewLogger(t),
+	}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = h.ServeHTTP(w, r, caddyhttp.HandlerFunc(func(http.ResponseWriter, *http.Request) error { return nil }))
+	}))
+	defer proxy.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(proxy.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to connect through the proxy: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["newHeads"]}`)); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+
+	expected := []string{`"result":"0xa"`, `"from-a"`, `"from-b"`}
+	for _, want := range expected {
+		_ = client.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, data, err := client.ReadMessage()
+		if err != nil {
+			t.Fatalf("expected a message containing %s: %v", want, err)
+		}
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("expected a message containing %s, got %s", want, data)
+		}
+		// Notifications always carry the subscription ID the client was given
+		if strings.Contains(string(data), "eth_subscription") && !strings.Contains(string(data), `"subscription":"0xa"`) {
+			t.Fatalf("expected the subscription ID to be rewritten, got %s", data)
+		}
+	}
+}
+
+func TestWebSocketReplay_PassesThroughPlainRequests(t *testing.T) {
+	h := &WebSocketReplay{logger: zaptest.NewLogger(t)}
+
+	called := false
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://127.0.0.1/", nil)
+	err := h.ServeHTTP(rec, r, caddyhttp.HandlerFunc(func(http.ResponseWriter, *http.Request) error {
+		called = true
+		return nil
+	}))
+	if err != nil || !called {
+		t.Fatalf("expected the request to reach the next handler, err=%v", err)
+	}
+}
+
+func TestWebSocketReplay_UnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`blockchain_ws_replay {
+		dynamic blockchain_health {
+			probe_mode abci_info
+		}
+		tls
+		reconnect_attempts 5
+		reconnect_delay 250ms
+	}`)
+
+	var h WebSocketReplay
+	if err := h.UnmarshalCaddyfile(d); err != nil {
+		t.Fatalf("Failed to parse Caddyfile: %v", err)
+	}
+	if !h.TLS || h.ReconnectAttempts != 5 || time.Duration(h.ReconnectDelay) != 250*time.Millisecond {
+		t.Errorf("unexpected config: %+v", h)
+	}
+	if !strings.Contains(string(h.UpstreamsRaw), `"source":"blockchain_health"`) {
+		t.Errorf("expected the blockchain_health source, got %s", h.UpstreamsRaw)
+	}
+
+	d = caddyfile.NewTestDispenser(`blockchain_ws_replay {
+		reconnect_attempts 5
+	}`)
+	if err := new(WebSocketReplay).UnmarshalCaddyfile(d); err == nil {
+		t.Error("expected an error without an upstream source")
+	}
+}